@@ -1,33 +1,163 @@
 package aks
 
+import "context"
+import "fmt"
+import "iter"
 import "log"
 import "math/big"
+import "time"
 
 // Returns whether (X + a)^n = X^n + a mod (n, X^r - 1). tmp1, tmp2,
-// and tmp3 must be bigIntPoly objects constructed with N, R = n, r,
+// and tmp3 must be polys made by the same polyRing for N, R = n, r,
 // and they must not alias each other.
-func isAKSWitness(n, a big.Int, tmp1, tmp2, tmp3 *bigIntPoly) bool {
+func isAKSWitness(n, a big.Int, tmp1, tmp2, tmp3 poly) bool {
+	isWitness, _ := isAKSWitnessCancelable(
+		n, a, tmp1, tmp2, tmp3, nil, nil, nil, nil)
+	return isWitness
+}
+
+// Like isAKSWitness, but gives up as soon as possible once done is
+// closed, in which case the second return value is false. done may
+// be nil. If power is non-nil, (X + a)^n is computed with it instead
+// of with poly.powCancelable. If debugLogger is non-nil, each
+// polynomial multiplication is logged to it. If yield is non-nil, it
+// is called after each polynomial multiplication.
+func isAKSWitnessCancelable(
+	n, a big.Int,
+	tmp1, tmp2, tmp3 poly,
+	power *chainPower,
+	done <-chan struct{},
+	debugLogger *log.Logger,
+	yield func()) (isWitness, completed bool) {
+	onMul := yield
+	if debugLogger != nil {
+		var chain *AdditionChain
+		if power != nil {
+			chain = &power.chain
+		}
+		mulCount := calculateWitnessMultiplicationCount(&n, chain)
+		i := int64(0)
+		onMul = func() {
+			i++
+			debugLogger.Printf("Testing %v: finished multiplication "+
+				"%d/%d\n", &a, i, mulCount)
+			if yield != nil {
+				yield()
+			}
+		}
+	}
+
 	// Left-hand side: (X + a)^n mod (n, X^r - 1).
 	tmp1.Set(a, *big.NewInt(1), n)
-	tmp1.Pow(n, tmp2, tmp3)
+	if power != nil {
+		if !power.powCancelable(tmp1, n, tmp2, done, onMul) {
+			return false, false
+		}
+	} else if !tmp1.powCancelable(n, tmp2, tmp3, done, onMul) {
+		return false, false
+	}
 
 	// Right-hand side: (X^n + a) mod (n, X^r - 1).
 	tmp2.Set(a, n, n)
 
-	isWitness := !tmp1.Eq(tmp2)
-	return isWitness
+	isWitness = !tmp1.Eq(tmp2)
+	return isWitness, true
+}
+
+// Returns whether a is an AKS witness of n with parameter r, i.e.
+// whether (X + a)^n != X^n + a mod (n, X^r - 1). n and r must be >= 2
+// and r must fit into an int. To test many numbers, make an
+// AKSParams once and use its IsAKSWitness method instead.
+func IsAKSWitness(n, r, a *big.Int) (bool, error) {
+	params, err := NewAKSParams(n, r, nil)
+	if err != nil {
+		return false, err
+	}
+	return params.IsAKSWitness(a), nil
+}
+
+// Returns a bitmask whose ith bit is set if and only if as[i] is an
+// AKS witness of n with parameter r, like calling IsAKSWitness on
+// each number but with the parameters and polynomials set up only
+// once for the whole batch. n and r must be >= 2 and r must fit into
+// an int.
+func TestWitnessBatch(n, r *big.Int, as []*big.Int) (*big.Int, error) {
+	params, err := NewAKSParams(n, r, nil)
+	if err != nil {
+		return nil, err
+	}
+	return params.TestWitnessBatch(as), nil
+}
+
+// Holds both sides of the AKS congruence (X + a)^n = X^n + a mod (n,
+// X^r - 1), for debugging claimed AKS witnesses.
+type WitnessExplanation struct {
+	N, R, A *big.Int
+	// (X + a)^n and X^n + a mod (n, X^r - 1), formatted as
+	// polynomials in x.
+	LHS, RHS string
+	// The lowest degree whose coefficients differ between the
+	// two sides, or -1 if the sides are equal (i.e., a is not
+	// an AKS witness).
+	FirstDifference int
+	// The coefficients of x^FirstDifference on each side, if
+	// FirstDifference >= 0.
+	LHSCoefficient, RHSCoefficient *big.Int
+}
+
+// Returns whether e.A is an AKS witness of e.N.
+func (e *WitnessExplanation) IsWitness() bool {
+	return e.FirstDifference >= 0
+}
+
+// Like IsAKSWitness, but returns both sides of the congruence and
+// where they first differ.
+func ExplainAKSWitness(n, r, a *big.Int) (*WitnessExplanation, error) {
+	params, err := NewAKSParams(n, r, nil)
+	if err != nil {
+		return nil, err
+	}
+	lhs := params.polys.newPoly()
+	rhs := params.polys.newPoly()
+	defer params.polys.put(lhs)
+	defer params.polys.put(rhs)
+	lhs.Set(*a, *big.NewInt(1), *n)
+	params.polys.pow(lhs, *n)
+	rhs.Set(*a, *n, *n)
+
+	e := &WitnessExplanation{
+		N:               n,
+		R:               r,
+		A:               a,
+		LHS:             fmt.Sprint(lhs),
+		RHS:             fmt.Sprint(rhs),
+		FirstDifference: -1,
+	}
+	rInt := int(r.Int64())
+	for i := 0; i < rInt; i++ {
+		lhsC := lhs.coefficient(i)
+		rhsC := rhs.coefficient(i)
+		if lhsC.Cmp(rhsC) != 0 {
+			e.FirstDifference = i
+			e.LHSCoefficient = lhsC
+			e.RHSCoefficient = rhsC
+			break
+		}
+	}
+	return e, nil
 }
 
 // Returns the first AKS witness of n with the parameters r and M, or
 // nil if there isn't one.
 func getFirstAKSWitness(n, r, M *big.Int, logger *log.Logger) *big.Int {
-	tmp1 := newBigIntPoly(*n, *r)
-	tmp2 := newBigIntPoly(*n, *r)
-	tmp3 := newBigIntPoly(*n, *r)
+	// The automatic backend always works.
+	params, _ := NewAKSParams(n, r, nil)
+	tester := newWitnessTester(params)
+	defer tester.release()
 
 	for a := big.NewInt(1); a.Cmp(M) < 0; a.Add(a, big.NewInt(1)) {
 		logger.Printf("Testing %v (M = %v)...\n", a, M)
-		isWitness := isAKSWitness(*n, *a, tmp1, tmp2, tmp3)
+		isWitness, _ := tester.test(*a, nil, nil, nil)
 		if isWitness {
 			return a
 		}
@@ -39,78 +169,320 @@ func getFirstAKSWitness(n, r, M *big.Int, logger *log.Logger) *big.Int {
 type witnessResult struct {
 	a         *big.Int
 	isWitness bool
+	// How long the test took.
+	duration time.Duration
 }
 
-// Tests all numbers received on numberCh if they are witnesses of n
-// with parameter r. Sends the results to resultCh.
+// Tests all numbers in the batches received on batchCh if they are
+// witnesses of params.N(), and sends the results to resultCh, one
+// per number. Returns early if ctx is cancelled. Records its memory
+// usage in stats if it is non-nil, logs each multiplication to
+// debugLogger if it is non-nil, and calls yield after each
+// multiplication if it is non-nil.
 func testAKSWitnesses(
-	n, r *big.Int,
-	numberCh chan *big.Int,
+	ctx context.Context,
+	params *AKSParams,
+	batchCh chan []*big.Int,
 	resultCh chan witnessResult,
-	logger *log.Logger) {
-	tmp1 := newBigIntPoly(*n, *r)
-	tmp2 := newBigIntPoly(*n, *r)
-	tmp3 := newBigIntPoly(*n, *r)
+	logger *log.Logger,
+	debugLogger *log.Logger,
+	yield func(),
+	stats *statsCollector) {
+	tester := newWitnessTester(params)
+	defer tester.release()
+	if stats != nil {
+		phiBytes := tester.getPhiBytes()
+		stats.phiAllocated(phiBytes)
+		defer stats.phiAllocated(-phiBytes)
+	}
 
-	for a := range numberCh {
-		logger.Printf("Testing %v...\n", a)
-		isWitness := isAKSWitness(*n, *a, tmp1, tmp2, tmp3)
-		logger.Printf("Finished testing %v (isWitness=%t)\n",
-			a, isWitness)
-		resultCh <- witnessResult{a, isWitness}
+	for batch := range batchCh {
+		for _, a := range batch {
+			if ctx.Err() != nil {
+				return
+			}
+			logger.Printf("Testing %v...\n", a)
+			startTime := time.Now()
+			isWitness, completed := tester.test(
+				*a, ctx.Done(), debugLogger, yield)
+			if !completed {
+				return
+			}
+			duration := time.Since(startTime)
+			logger.Printf("Finished testing %v (isWitness=%t)\n",
+				a, isWitness)
+			select {
+			case resultCh <- witnessResult{a, isWitness, duration}:
+			case <-ctx.Done():
+				return
+			}
+		}
 	}
 }
 
+// The most numbers sent to a goroutine testing AKS witnesses at once.
+const maxWitnessBatchSize = 16
+
+// Returns how many of the count numbers of a witness search with the
+// given number of jobs to send to a goroutine at once. Batching
+// numbers cuts down on channel traffic when each test is quick, but
+// batches are kept small enough that each job gets at least 64 of
+// them, so that no job is left with much more work than the others
+// at the end of the search.
+func getWitnessBatchSize(count *big.Int, jobs int) int {
+	var batchSize big.Int
+	batchSize.Quo(count, big.NewInt(int64(jobs)*64))
+	if batchSize.Cmp(big.NewInt(maxWitnessBatchSize)) >= 0 {
+		return maxWitnessBatchSize
+	}
+	if batchSize.Sign() == 0 {
+		return 1
+	}
+	return int(batchSize.Int64())
+}
+
 // Returns an AKS witness of n with the parameters r, start, and end,
 // or nil if there isn't one. Tests up to maxOutstanding numbers at
-// once.
+// once. n and r must be >= 2, r must fit into an int, and
+// maxOutstanding must be positive.
 func GetAKSWitness(
 	n, r, start, end *big.Int,
 	maxOutstanding int,
-	logger *log.Logger) *big.Int {
-	numberCh := make(chan *big.Int, maxOutstanding)
-	defer close(numberCh)
-	resultCh := make(chan witnessResult, maxOutstanding)
-	for i := 0; i < maxOutstanding; i++ {
-		go testAKSWitnesses(n, r, numberCh, resultCh, logger)
-	}
-
-	// Send off all numbers for testing (counted by i), draining
-	// any results that come in (counted by j) while we're doing
-	// so.
-	var i, j big.Int
-	i.Set(start)
-	j.Set(start)
-	logResult := func(result witnessResult) {
-		logger.Printf("%v isWitness=%t\n", result.a, result.isWitness)
-	}
-	for i.Cmp(end) < 0 {
+	logger *log.Logger) (*big.Int, error) {
+	return GetAKSWitnessCtx(
+		context.Background(), n, r, start, end, maxOutstanding, logger)
+}
+
+// Returns an error if the arguments to GetAKSWitnessCtx are invalid.
+func checkAKSWitnessArgs(n, r *big.Int, maxOutstanding int) error {
+	two := big.NewInt(2)
+	if n.Cmp(two) < 0 {
+		return fmt.Errorf("%w: n = %v must be >= 2", ErrBadInput, n)
+	}
+	if r.Cmp(two) < 0 {
+		return fmt.Errorf("%w: r = %v must be >= 2", ErrBadInput, r)
+	}
+	if !fitsInInt(r) {
+		return fmt.Errorf("%w: r = %v", ErrRTooLarge, r)
+	}
+	if maxOutstanding <= 0 {
+		return fmt.Errorf("%w: maxOutstanding = %d must be positive",
+			ErrBadInput, maxOutstanding)
+	}
+	return nil
+}
+
+// Like GetAKSWitness, but stops dispatching numbers and returns nil
+// and ctx.Err() as soon as ctx is cancelled or its deadline
+// expires. Workers still in the middle of testing a number give up
+// within one polynomial multiplication.
+func GetAKSWitnessCtx(
+	ctx context.Context,
+	n, r, start, end *big.Int,
+	maxOutstanding int,
+	logger *log.Logger) (*big.Int, error) {
+	return GetAKSWitnessWithStride(
+		ctx, n, r, start, end, nil, nil, maxOutstanding, logger)
+}
+
+// Like GetAKSWitnessCtx, but only tests the numbers in [start, end)
+// that are congruent to offset modulo stride, i.e. offset, offset +
+// stride, offset + 2*stride, and so on. This lets k machines split
+// up the search by using stride = k and offset = 0, ..., k-1. stride
+// and offset default to 1 and 0 if nil, and stride must be positive.
+func GetAKSWitnessWithStride(
+	ctx context.Context,
+	n, r, start, end, stride, offset *big.Int,
+	maxOutstanding int,
+	logger *log.Logger) (*big.Int, error) {
+	if err := checkAKSWitnessArgs(n, r, maxOutstanding); err != nil {
+		return nil, err
+	}
+	prog, err := newProgression(stride, offset)
+	if err != nil {
+		return nil, err
+	}
+	params, err := NewAKSParams(n, r, nil)
+	if err != nil {
+		return nil, err
+	}
+	s := witnessSearch{
+		params: params,
+		start:  start,
+		end:    end,
+		prog:   prog,
+		jobs:   maxOutstanding,
+		logger: logger,
+	}
+	return s.run(ctx)
+}
+
+// Holds the parameters of a parallel search for AKS witnesses of
+// params.N() with parameter params.R() in [start, end). jobs must
+// be positive.
+type witnessSearch struct {
+	// Shared by all the goroutines testing witnesses.
+	params     *AKSParams
+	start, end *big.Int
+	// Only members of prog in [start, end) are tested, in the
+	// given order.
+	prog  progression
+	order WitnessOrder
+	// Used if order is WitnessOrderRandom.
+	seed int64
+	// If set, only the primes among the members of prog in
+	// [start, end) are tested.
+	primesOnly bool
+	jobs       int
+	logger     *log.Logger
+	// If non-nil, each polynomial multiplication is logged to
+	// debugLogger.
+	debugLogger *log.Logger
+	// If non-nil, called after each polynomial multiplication.
+	yield func()
+	// If non-nil, numbers in completed are skipped, and numbers
+	// found not to be witnesses are added to it.
+	completed *rangeSet
+	// If non-nil, notified of each result and of updated
+	// estimates.
+	observer ProgressObserver
+	// If non-nil, accumulates statistics about the search.
+	stats *statsCollector
+	// If non-nil, called from the dispatching goroutine with each
+	// result after it is added to completed.
+	onResult func(witnessResult)
+}
+
+// Sends candidates to batchCh in batches of up to batchSize numbers,
+// blocking as necessary, and then closes batchCh and sends the count
+// of numbers sent to countCh. Returns early without sending the
+// count if ctx is cancelled.
+func (s *witnessSearch) produce(
+	ctx context.Context,
+	candidates iter.Seq[*big.Int],
+	batchSize int,
+	batchCh chan<- []*big.Int,
+	countCh chan<- int) {
+	defer close(batchCh)
+	count := 0
+	var batch []*big.Int
+	send := func() bool {
 		select {
-		case result := <-resultCh:
-			j.Add(&j, big.NewInt(1))
-			logResult(result)
-			if result.isWitness {
-				return result.a
-			}
-		default:
-			var a big.Int
-			a.Set(&i)
-			numberCh <- &a
-			i.Add(&i, big.NewInt(1))
+		case batchCh <- batch:
+			count += len(batch)
+			currentAVar.Set(batch[len(batch)-1].String())
+			batch = nil
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}
+	for a := range candidates {
+		batch = append(batch, a)
+		if len(batch) == batchSize && !send() {
+			return
+		}
+	}
+	if len(batch) > 0 && !send() {
+		return
+	}
+	countCh <- count
+}
+
+// Runs the search described by s. Returns the witness found, if
+// any, or ctx.Err() if ctx is cancelled first.
+func (s *witnessSearch) run(ctx context.Context) (*big.Int, error) {
+	// Cancelling workerCtx on return stops the producer and makes
+	// any workers that are still testing numbers give up, whether
+	// we returned because of a witness or because ctx was
+	// cancelled.
+	workerCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// The producer reads from its own copy of s.completed, since
+	// we add to the latter as results come in.
+	var skip *rangeSet
+	if s.completed != nil {
+		skip = &rangeSet{s.completed.getRanges()}
+	}
+	var primes []uint64
+	if s.primesOnly {
+		var err error
+		primes, err = s.getPrimeCandidates()
+		if err != nil {
+			return nil, err
 		}
 	}
+	candidates, err := s.candidates(skip, primes)
+	if err != nil {
+		return nil, err
+	}
+	count := s.prog.countIn(s.start, s.end)
+	if s.primesOnly {
+		count.SetInt64(int64(len(primes)))
+	}
+	batchSize := getWitnessBatchSize(count, s.jobs)
+	batchCh := make(chan []*big.Int, s.jobs)
+	countCh := make(chan int, 1)
+	go s.produce(workerCtx, candidates, batchSize, batchCh, countCh)
 
-	// Drain any remaining results.
-	for j.Cmp(end) < 0 {
-		result := <-resultCh
-		j.Add(&j, big.NewInt(1))
-		logResult(result)
-		if result.isWitness {
-			return result.a
+	resultCh := make(chan witnessResult, s.jobs)
+	for i := 0; i < s.jobs; i++ {
+		go testAKSWitnesses(
+			workerCtx, s.params, batchCh, resultCh, s.logger,
+			s.debugLogger, s.yield, s.stats)
+	}
+
+	var est *estimator
+	if s.observer != nil {
+		if s.primesOnly {
+			est = newPrimesEstimator(primes, s.completed)
+		} else {
+			est = newEstimator(
+				s.start, s.end, s.prog, s.completed)
+		}
+	}
+	// Collect results until we've received as many as the
+	// producer sent, which we only know once it's done.
+	received := 0
+	sent := -1
+	for sent < 0 || received < sent {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case sent = <-countCh:
+		case result := <-resultCh:
+			received++
+			testedVar.Add(1)
+			s.logger.Printf("%v isWitness=%t\n",
+				result.a, result.isWitness)
+			if s.completed != nil && !result.isWitness {
+				s.completed.add(result.a)
+			}
+			if s.stats != nil {
+				s.stats.witnessTested(result.duration)
+			}
+			if s.observer != nil {
+				s.observer.OnWitnessTested(
+					result.a, result.isWitness,
+					result.duration)
+				s.observer.OnEstimate(est.update())
+			}
+			if o, ok := s.observer.(StatsObserver); ok &&
+				s.stats != nil {
+				o.OnStats(s.stats.getStats())
+			}
+			if s.onResult != nil {
+				s.onResult(result)
+			}
+			if result.isWitness {
+				witnessVar.Set(result.a.String())
+				return result.a, nil
+			}
 		}
 	}
 
-	return nil
+	return nil, nil
 }
 
 // Returns an upper bound for r such that o_r(n) > ceil(lg(n))^2 that
@@ -140,44 +512,257 @@ func calculateAKSModulusUpperBound(n *big.Int) *big.Int {
 }
 
 // Returns the least r such that o_r(n) > ceil(lg(n))^2 >= ceil(lg(n)^2).
-func CalculateAKSModulus(n *big.Int) *big.Int {
-	one := big.NewInt(1)
+// n must be >= 2.
+func CalculateAKSModulus(n *big.Int) (*big.Int, error) {
 	two := big.NewInt(2)
 
+	if n.Cmp(two) < 0 {
+		return nil, fmt.Errorf("%w: n = %v must be >= 2", ErrBadInput, n)
+	}
+
 	ceilLgNSq := big.NewInt(int64(n.BitLen()))
 	ceilLgNSq.Mul(ceilLgNSq, ceilLgNSq)
+	r := findAKSModulus(n, ceilLgNSq)
+	if r == nil {
+		return nil, fmt.Errorf("%w: n = %v", ErrModulusNotFound, n)
+	}
+	return r, nil
+}
+
+// Returns the least r coprime to n such that o_r(n) > minOrder, or
+// nil if there isn't one below calculateAKSModulusUpperBound(n).
+// minOrder must be at most ceil(lg(n))^2.
+func findAKSModulus(n, minOrder *big.Int) *big.Int {
+	one := big.NewInt(1)
+	// o_r(n) < r, so start with the least r that could work.
 	var r big.Int
-	r.Add(ceilLgNSq, two)
+	r.Add(minOrder, big.NewInt(2))
 	rUpperBound := calculateAKSModulusUpperBound(n)
 	for ; r.Cmp(rUpperBound) < 0; r.Add(&r, one) {
-		var gcd big.Int
-		gcd.GCD(nil, nil, n, &r)
-		if gcd.Cmp(one) != 0 {
+		if gcd(n, &r).Cmp(one) != 0 {
 			continue
 		}
-		o := calculateMultiplicativeOrder(n, &r)
-		if o.Cmp(ceilLgNSq) > 0 {
+		if isMultiplicativeOrderAbove(n, &r, minOrder) {
 			return &r
 		}
 	}
+	return nil
+}
 
-	panic("Could not find AKS modulus")
+// isMultiplicativeOrderAbove only factors r - 1 by trial division up
+// to this bound at first.
+var orderTrialDivisionBound = big.NewInt(1 << 16)
+
+// Returns whether o_r(n) > minOrder, for coprime n and r. If r is
+// prime, r - 1 is only factored as far as needed to decide that,
+// which is usually no further than orderTrialDivisionBound.
+func isMultiplicativeOrderAbove(n, r, minOrder *big.Int) bool {
+	// big.Int.ProbablyPrime is exact below 2^64, which is
+	// important, since the bounds below are wrong if r isn't
+	// prime.
+	if r.BitLen() > 64 || !r.ProbablyPrime(0) {
+		return calculateMultiplicativeOrder(n, r).Cmp(minOrder) > 0
+	}
+	var rMinusOne big.Int
+	rMinusOne.Sub(r, big.NewInt(1))
+	f := calculatePartialFactorization(&rMinusOne, orderTrialDivisionBound)
+	lower, upper := calculateMultiplicativeOrderBounds(
+		n, r, f, orderTrialDivisionBound)
+	switch {
+	case lower.Cmp(minOrder) > 0:
+		return true
+	case upper.Cmp(minOrder) <= 0:
+		return false
+	}
+	return calculateMultiplicativeOrder(n, r).Cmp(minOrder) > 0
+}
+
+// The number of fractional bits in the upper bounds for lg(n)
+// returned by calculateLgUpperBound.
+const lgFractionBits = 32
+
+// Returns an upper bound for lg(n) as a fixed-point number with
+// lgFractionBits fractional bits, i.e. an integer U such that U /
+// 2^lgFractionBits > lg(n), which is within about
+// 2^-lgFractionBits of lg(n). n must be positive.
+func calculateLgUpperBound(n *big.Int) *big.Int {
+	// The precision, in fractional bits, of the mantissa below.
+	const precision = 2*lgFractionBits + 64
+
+	// Compute the fractional part of lg(n) bit by bit: if x =
+	// n/2^(b - 1) is in [1, 2), the next bit of lg(x) is 1 if and
+	// only if x^2 >= 2, in which case x^2/2 is the x for the
+	// remaining bits, and otherwise x^2 is. Rounding x up at each
+	// step can only make the bits larger.
+	b := n.BitLen()
+	var x big.Int
+	if shift := precision - (b - 1); shift >= 0 {
+		x.Lsh(n, uint(shift))
+	} else {
+		x.Rsh(n, uint(-shift))
+		x.Add(&x, big.NewInt(1))
+	}
+	var two big.Int
+	two.Lsh(big.NewInt(2), precision)
+	U := big.NewInt(int64(b - 1))
+	for i := 0; i < lgFractionBits; i++ {
+		x.Mul(&x, &x)
+		x.Rsh(&x, precision)
+		x.Add(&x, big.NewInt(1))
+		U.Lsh(U, 1)
+		if x.Cmp(&two) >= 0 {
+			U.Add(U, big.NewInt(1))
+			x.Rsh(&x, 1)
+			x.Add(&x, big.NewInt(1))
+		}
+	}
+	// The bits computed are at most the truncated fractional part
+	// of lg(n), so add one in the last place.
+	return U.Add(U, big.NewInt(1))
+}
+
+// Like CalculateAKSModulus, but returns the least r such that o_r(n) >
+// lg(n)^2 instead of ceil(lg(n))^2, which is what the AKS theorem
+// actually needs. Since o_r(n) < r, this is smaller by up to about
+// 2*lg(n) when n is just above a power of 2, and since the cost of
+// testing each AKS witness grows with r, the witness search speeds up
+// accordingly. Use CalculateSharpAKSUpperBound to get the matching M.
+func CalculateSharpAKSModulus(n *big.Int) (*big.Int, error) {
+	if n.Cmp(big.NewInt(2)) < 0 {
+		return nil, fmt.Errorf("%w: n = %v must be >= 2", ErrBadInput, n)
+	}
+
+	if r := findAKSModulus(n, calculateMinAKSOrder(n)); r != nil {
+		return r, nil
+	}
+	// This shouldn't happen, since the r that CalculateAKSModulus
+	// returns would have been found, but fall back to it anyway.
+	return CalculateAKSModulus(n)
+}
+
+// Returns floor(U^2/2^(2*lgFractionBits)) for the U returned by
+// calculateLgUpperBound(n). Since orders are integers, o_r(n) is
+// greater than this if and only if it is greater than
+// (U/2^lgFractionBits)^2 > lg(n)^2. This is at most ceil(lg(n))^2.
+func calculateMinAKSOrder(n *big.Int) *big.Int {
+	lgNSq := calculateLgUpperBound(n)
+	lgNSq.Mul(lgNSq, lgNSq)
+	return lgNSq.Rsh(lgNSq, 2*lgFractionBits)
+}
+
+// Returns o_r(n) after checking that r is a valid AKS modulus for n,
+// i.e. that n and r are coprime and o_r(n) > lg(n)^2, which is what
+// the AKS theorem needs. Every r returned by CalculateAKSModulus or
+// CalculateSharpAKSModulus passes, but the moduli of the other
+// variants generally don't. The order is checked directly against its
+// definition, independently of how it was calculated. The comparison
+// uses a bound for lg(n) that is slightly too large, so it errs on
+// the side of rejecting r. Returns an error wrapping ErrBadModulus if
+// r isn't valid, or one wrapping ErrBadInput if n or r is less than
+// 2.
+func CheckAKSModulus(n, r *big.Int) (*big.Int, error) {
+	one := big.NewInt(1)
+	two := big.NewInt(2)
+	if n.Cmp(two) < 0 {
+		return nil, fmt.Errorf("%w: n = %v must be >= 2", ErrBadInput, n)
+	}
+	if r.Cmp(two) < 0 {
+		return nil, fmt.Errorf("%w: r = %v must be >= 2", ErrBadInput, r)
+	}
+
+	if g := gcd(n, r); g.Cmp(one) != 0 {
+		return nil, fmt.Errorf(
+			"%w: n = %v and r = %v have common factor %v",
+			ErrBadModulus, n, r, g)
+	}
+
+	// o is the order of n mod r if and only if n^o = 1 (mod r)
+	// but n^(o/q) != 1 (mod r) for every prime q dividing o. If
+	// either check fails, the order calculation has a bug, but
+	// that's still reported as r being invalid, since it can't be
+	// shown to be valid.
+	o := calculateMultiplicativeOrder(n, r)
+	var x big.Int
+	if x.Exp(n, o, r).Cmp(one) != 0 {
+		return nil, fmt.Errorf(
+			"%w: could not verify o_r(n): n^%v != 1 (mod %v) "+
+				"for n = %v", ErrBadModulus, o, r, n)
+	}
+	var orderErr error
+	factorize(o, func(q, e *big.Int) bool {
+		x.Div(o, q)
+		if x.Exp(n, &x, r).Cmp(one) == 0 {
+			orderErr = fmt.Errorf(
+				"%w: could not verify o_r(n): "+
+					"n^(%v/%v) = 1 (mod %v) for n = %v",
+				ErrBadModulus, o, q, r, n)
+			return false
+		}
+		return true
+	})
+	if orderErr != nil {
+		return nil, orderErr
+	}
+
+	if minOrder := calculateMinAKSOrder(n); o.Cmp(minOrder) <= 0 {
+		return nil, fmt.Errorf(
+			"%w: o_r(n) = %v <= lg(n)^2 for n = %v, r = %v",
+			ErrBadModulus, o, n, r)
+	}
+	return o, nil
 }
 
 // Returns floor(sqrt(Phi(r))) * ceil(lg(n)) + 1 > floor(sqrt(Phi(r))) * lg(n).
-func CalculateAKSUpperBound(n, r *big.Int) *big.Int {
+// n and r must be >= 2.
+func CalculateAKSUpperBound(n, r *big.Int) (*big.Int, error) {
 	one := big.NewInt(1)
 	two := big.NewInt(2)
 
-	M := calculateEulerPhi(r)
-	M = floorRoot(M, two)
+	if n.Cmp(two) < 0 {
+		return nil, fmt.Errorf("%w: n = %v must be >= 2", ErrBadInput, n)
+	}
+	if r.Cmp(two) < 0 {
+		return nil, fmt.Errorf("%w: r = %v must be >= 2", ErrBadInput, r)
+	}
+
+	M, _ := sqrtExact(calculateEulerPhi(r))
 	M.Mul(M, big.NewInt(int64(n.BitLen())))
 	M.Add(M, one)
-	return M
+	return M, nil
 }
 
-// Returns the first factor of n less than M.
-func GetFirstFactorBelow(n, M *big.Int) *big.Int {
+// Like CalculateAKSUpperBound, but returns floor(sqrt(Phi(r)) * lg(n))
+// + 1, to go with CalculateSharpAKSModulus. n and r must be >= 2.
+func CalculateSharpAKSUpperBound(n, r *big.Int) (*big.Int, error) {
+	two := big.NewInt(2)
+	if n.Cmp(two) < 0 {
+		return nil, fmt.Errorf("%w: n = %v must be >= 2", ErrBadInput, n)
+	}
+	if r.Cmp(two) < 0 {
+		return nil, fmt.Errorf("%w: r = %v must be >= 2", ErrBadInput, r)
+	}
+
+	// With U from calculateLgUpperBound, sqrt(Phi(r)) * lg(n) <=
+	// sqrt(Phi(r) * U^2)/2^lgFractionBits, whose floor is
+	// floor(floor(sqrt(Phi(r) * U^2))/2^lgFractionBits).
+	U := calculateLgUpperBound(n)
+	M := calculateEulerPhi(r)
+	M.Mul(M, U)
+	M.Mul(M, U)
+	M, _ = sqrtExact(M)
+	M.Rsh(M, lgFractionBits)
+	M.Add(M, big.NewInt(1))
+	return M, nil
+}
+
+// Returns the first factor of n less than M, or nil if there isn't
+// one. n must be non-negative. Use FactorBounded instead to find all
+// the factors of n below a bound along with what's left of n.
+func GetFirstFactorBelow(n, M *big.Int) (*big.Int, error) {
+	if n.Sign() < 0 {
+		return nil, fmt.Errorf(
+			"%w: n = %v must be non-negative", ErrBadInput, n)
+	}
 	var factor *big.Int
 	var mMinusOne big.Int
 	mMinusOne.Sub(M, big.NewInt(1))
@@ -187,5 +772,5 @@ func GetFirstFactorBelow(n, M *big.Int) *big.Int {
 		}
 		return false
 	}, &mMinusOne)
-	return factor
+	return factor, nil
 }
@@ -1,7 +1,11 @@
 package aks
 
+import "context"
+import "errors"
+import "fmt"
 import "io/ioutil"
 import "log"
+import "math"
 import "math/big"
 import "runtime"
 import "testing"
@@ -20,12 +24,31 @@ func getFirstPrimeWithDigits(numDigits int64) *big.Int {
 	return n
 }
 
+// Returns the AKS modulus for n, failing tb if there is an error.
+func mustCalculateAKSModulus(n *big.Int, tb testing.TB) *big.Int {
+	r, err := CalculateAKSModulus(n)
+	if err != nil {
+		tb.Fatal(n, err)
+	}
+	return r
+}
+
+// Returns the AKS upper bound for n and r, failing tb if there is an
+// error.
+func mustCalculateAKSUpperBound(n, r *big.Int, tb testing.TB) *big.Int {
+	M, err := CalculateAKSUpperBound(n, r)
+	if err != nil {
+		tb.Fatal(n, r, err)
+	}
+	return M
+}
+
 // Benchmark isAKSWitness for the first prime number of the given
 // number of decimal digits.
 func runIsAKSWitnessBenchmark(b *testing.B, numDigits int64) {
 	b.StopTimer()
 	n := getFirstPrimeWithDigits(numDigits)
-	r := CalculateAKSModulus(n)
+	r := mustCalculateAKSModulus(n, b)
 	// Any a > 1 suffices.
 	a := big.NewInt(2)
 
@@ -68,7 +91,7 @@ func BenchmarkIsAKSWitness8Digits(b *testing.B) {
 func BenchmarkIsAKSWitnessMax32(b *testing.B) {
 	b.StopTimer()
 	n := big.NewInt(4294967291)
-	r := CalculateAKSModulus(n)
+	r := mustCalculateAKSModulus(n, b)
 	// Any a > 1 suffices.
 	a := big.NewInt(2)
 
@@ -97,7 +120,7 @@ var nullLogger *log.Logger = log.New(ioutil.Discard, "", 0)
 func runGetFirstAKSWitnessBenchmark(b *testing.B, numDigits int64) {
 	b.StopTimer()
 	n := getFirstPrimeWithDigits(numDigits)
-	r := CalculateAKSModulus(n)
+	r := mustCalculateAKSModulus(n, b)
 	M := big.NewInt(10)
 
 	b.StartTimer()
@@ -145,7 +168,7 @@ func BenchmarkGetFirstAKSWitness12Digits(b *testing.B) {
 func runGetAKSWitnessBenchmark(b *testing.B, numDigits int64) {
 	b.StopTimer()
 	n := getFirstPrimeWithDigits(numDigits)
-	r := CalculateAKSModulus(n)
+	r := mustCalculateAKSModulus(n, b)
 	M := big.NewInt(10)
 
 	b.StartTimer()
@@ -188,3 +211,352 @@ func BenchmarkGetAKSWitness11Digits(b *testing.B) {
 func BenchmarkGetAKSWitness12Digits(b *testing.B) {
 	runGetAKSWitnessBenchmark(b, 12)
 }
+
+// GetAKSWitnessCtx should find no witnesses for a prime.
+func TestGetAKSWitnessCtxPrime(t *testing.T) {
+	n := big.NewInt(101)
+	r := mustCalculateAKSModulus(n, t)
+	M := mustCalculateAKSUpperBound(n, r, t)
+	a, err := GetAKSWitnessCtx(
+		context.Background(), n, r, big.NewInt(1), M, 2, nullLogger)
+	if a != nil || err != nil {
+		t.Error(a, err)
+	}
+}
+
+// GetAKSWitnessCtx should return immediately with the context's
+// error if the context is already cancelled.
+func TestGetAKSWitnessCtxCancelled(t *testing.T) {
+	n := getFirstPrimeWithDigits(8)
+	r := mustCalculateAKSModulus(n, t)
+	M := mustCalculateAKSUpperBound(n, r, t)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	a, err := GetAKSWitnessCtx(ctx, n, r, big.NewInt(1), M, 2, nullLogger)
+	if a != nil || err != context.Canceled {
+		t.Error(a, err)
+	}
+}
+
+// The exported functions should return ErrBadInput instead of
+// panicking on out-of-range arguments.
+func TestBadInput(t *testing.T) {
+	one := big.NewInt(1)
+	two := big.NewInt(2)
+	if _, err := CalculateAKSModulus(one); !errors.Is(err, ErrBadInput) {
+		t.Error(err)
+	}
+	if _, err := CalculateAKSUpperBound(one, two); !errors.Is(
+		err, ErrBadInput) {
+		t.Error(err)
+	}
+	if _, err := CalculateAKSUpperBound(two, one); !errors.Is(
+		err, ErrBadInput) {
+		t.Error(err)
+	}
+	if _, err := CalculateSharpAKSModulus(one); !errors.Is(
+		err, ErrBadInput) {
+		t.Error(err)
+	}
+	if _, err := CalculateSharpAKSUpperBound(two, one); !errors.Is(
+		err, ErrBadInput) {
+		t.Error(err)
+	}
+	if _, err := GetFirstFactorBelow(big.NewInt(-1), two); !errors.Is(
+		err, ErrBadInput) {
+		t.Error(err)
+	}
+	if _, err := GetAKSWitness(
+		two, one, one, two, 1, nullLogger); !errors.Is(
+		err, ErrBadInput) {
+		t.Error(err)
+	}
+	if _, err := GetAKSWitness(
+		two, two, one, two, 0, nullLogger); !errors.Is(
+		err, ErrBadInput) {
+		t.Error(err)
+	}
+}
+
+// calculateLgUpperBound should be just above lg(n).
+func TestCalculateLgUpperBound(t *testing.T) {
+	for _, s := range []string{
+		"1", "2", "3", "4", "5", "7", "1000003", "4294967295",
+		"4294967296", "4294967297", "1000000000000000000000000000057",
+	} {
+		n, _ := new(big.Int).SetString(s, 10)
+		U := calculateLgUpperBound(n)
+		f, _ := new(big.Float).SetInt(n).Float64()
+		lg := math.Log2(f)
+		u, _ := new(big.Float).SetInt(U).Float64()
+		u = math.Ldexp(u, -lgFractionBits)
+		if u <= lg || u > lg+1e-6 {
+			t.Error(n, u, lg)
+		}
+	}
+
+	// For a power of 2, the bound is exactly one more than
+	// lg(n) in the last place.
+	n := new(big.Int).Lsh(big.NewInt(1), 100)
+	expected := new(big.Int).Lsh(big.NewInt(100), lgFractionBits)
+	expected.Add(expected, big.NewInt(1))
+	if U := calculateLgUpperBound(n); U.Cmp(expected) != 0 {
+		t.Error(U, expected)
+	}
+}
+
+// The sharp AKS parameters should satisfy the AKS theorem and have r
+// no larger than the classic ones.
+func TestCalculateSharpAKSParams(t *testing.T) {
+	for _, s := range []string{
+		"2", "3", "31", "1000003", "4294967311", "18446744073709551629",
+		"1000000000000000000000000000057",
+	} {
+		n, _ := new(big.Int).SetString(s, 10)
+		r, err := CalculateSharpAKSModulus(n)
+		if err != nil {
+			t.Fatal(n, err)
+		}
+		classicR := mustCalculateAKSModulus(n, t)
+		if r.Cmp(classicR) > 0 {
+			t.Error(n, r, classicR)
+		}
+
+		f, _ := new(big.Float).SetInt(n).Float64()
+		lg := math.Log2(f)
+		o, _ := calculateMultiplicativeOrder(n, r).Float64()
+		if o <= lg*lg {
+			t.Error(n, r, o, lg)
+		}
+
+		M, err := CalculateSharpAKSUpperBound(n, r)
+		if err != nil {
+			t.Fatal(n, r, err)
+		}
+		phi, _ := calculateEulerPhi(r).Float64()
+		expected := int64(math.Sqrt(phi)*lg) + 1
+		if M.Int64() != expected {
+			t.Error(n, r, M, expected)
+		}
+	}
+
+	// Just above a power of 2, lg(n) is much less than its
+	// ceiling, so r should be noticeably smaller.
+	n := big.NewInt(4294967311)
+	r, err := CalculateSharpAKSModulus(n)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if classicR := mustCalculateAKSModulus(n, t); r.Cmp(classicR) >= 0 {
+		t.Error(r, classicR)
+	}
+}
+
+// CheckAKSModulus should accept the classic and sharp moduli and
+// reject others.
+func TestCheckAKSModulus(t *testing.T) {
+	for _, s := range []string{
+		"2", "31", "65537", "4294967311", "18446744073709551629",
+	} {
+		n, _ := new(big.Int).SetString(s, 10)
+		sharpR, err := CalculateSharpAKSModulus(n)
+		if err != nil {
+			t.Fatal(n, err)
+		}
+		for _, r := range []*big.Int{
+			mustCalculateAKSModulus(n, t), sharpR,
+		} {
+			o, err := CheckAKSModulus(n, r)
+			if err != nil {
+				t.Error(n, r, err)
+			} else if o.Cmp(calculateMultiplicativeOrder(n, r)) != 0 {
+				t.Error(n, r, o)
+			}
+		}
+
+		// The sharp modulus is the least valid one.
+		var rMinusOne big.Int
+		rMinusOne.Sub(sharpR, big.NewInt(1))
+		if _, err := CheckAKSModulus(n, &rMinusOne); !errors.Is(
+			err, ErrBadModulus) {
+			t.Error(n, &rMinusOne, err)
+		}
+	}
+
+	if _, err := CheckAKSModulus(
+		big.NewInt(15), big.NewInt(6)); !errors.Is(err, ErrBadModulus) {
+		t.Error(err)
+	}
+	if _, err := CheckAKSModulus(
+		big.NewInt(1), big.NewInt(6)); !errors.Is(err, ErrBadInput) {
+		t.Error(err)
+	}
+	if _, err := CheckAKSModulus(
+		big.NewInt(15), big.NewInt(1)); !errors.Is(err, ErrBadInput) {
+		t.Error(err)
+	}
+}
+
+// RunAKS with SharpBounds should use the sharp parameters and still
+// get the right verdicts.
+func TestRunAKSSharpBounds(t *testing.T) {
+	n := big.NewInt(65537)
+	result, err := RunAKS(
+		context.Background(), n, &AKSOptions{SharpBounds: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := CalculateSharpAKSModulus(n)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Verdict != Prime || result.R.Cmp(r) != 0 {
+		t.Error(result.Verdict, result.R, r)
+	}
+
+	result, err = RunAKS(
+		context.Background(), big.NewInt(2993374621),
+		&AKSOptions{SharpBounds: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Verdict != Composite {
+		t.Error(result.Verdict)
+	}
+}
+
+// GetAKSWitness should handle an empty range and find witnesses of
+// composites with more jobs than numbers to test.
+func TestGetAKSWitnessSmallRanges(t *testing.T) {
+	n := big.NewInt(2993374621)
+	r := mustCalculateAKSModulus(n, t)
+	a, err := GetAKSWitness(
+		n, r, big.NewInt(5), big.NewInt(5), 4, nullLogger)
+	if a != nil || err != nil {
+		t.Error(a, err)
+	}
+	a, err = GetAKSWitness(
+		n, r, big.NewInt(1), big.NewInt(3), 8, nullLogger)
+	if a == nil || err != nil {
+		t.Error(a, err)
+	}
+}
+
+// IsAKSWitness should agree with the witness search.
+func TestIsAKSWitness(t *testing.T) {
+	n := big.NewInt(2993374621)
+	r := mustCalculateAKSModulus(n, t)
+	M := mustCalculateAKSUpperBound(n, r, t)
+	a := getFirstAKSWitness(n, r, M, nullLogger)
+	if a == nil {
+		t.Fatal("no witness found")
+	}
+	isWitness, err := IsAKSWitness(n, r, a)
+	if !isWitness || err != nil {
+		t.Error(a, isWitness, err)
+	}
+
+	p := big.NewInt(1000003)
+	pR := mustCalculateAKSModulus(p, t)
+	isWitness, err = IsAKSWitness(p, pR, big.NewInt(5))
+	if isWitness || err != nil {
+		t.Error(isWitness, err)
+	}
+
+	_, err = IsAKSWitness(p, big.NewInt(1), big.NewInt(5))
+	if !errors.Is(err, ErrBadInput) {
+		t.Error(err)
+	}
+}
+
+// TestWitnessBatch should agree with IsAKSWitness for each number
+// in the batch.
+func TestTestWitnessBatch(t *testing.T) {
+	n := big.NewInt(1961)
+	r := big.NewInt(5)
+	var as []*big.Int
+	var expected big.Int
+	for a := int64(0); a < 70; a++ {
+		as = append(as, big.NewInt(a))
+		isWitness, err := IsAKSWitness(n, r, big.NewInt(a))
+		if err != nil {
+			t.Fatal(a, err)
+		}
+		if isWitness {
+			expected.SetBit(&expected, int(a), 1)
+		}
+	}
+	if expected.Sign() == 0 {
+		t.Fatal("no witnesses")
+	}
+	mask, err := TestWitnessBatch(n, r, as)
+	if err != nil || mask.Cmp(&expected) != 0 {
+		t.Errorf("%x %x %v", mask, &expected, err)
+	}
+
+	mask, err = TestWitnessBatch(n, r, nil)
+	if err != nil || mask.Sign() != 0 {
+		t.Error(mask, err)
+	}
+	_, err = TestWitnessBatch(n, big.NewInt(1), as)
+	if !errors.Is(err, ErrBadInput) {
+		t.Error(err)
+	}
+}
+
+// Witness searches should batch numbers only when there are plenty
+// of them per job.
+func TestGetWitnessBatchSize(t *testing.T) {
+	tests := []struct {
+		count           int64
+		jobs, batchSize int
+	}{
+		{0, 1, 1},
+		{100, 1, 1},
+		{128, 1, 2},
+		{1000, 4, 3},
+		{1000000, 4, maxWitnessBatchSize},
+	}
+	for _, test := range tests {
+		batchSize := getWitnessBatchSize(
+			big.NewInt(test.count), test.jobs)
+		if batchSize != test.batchSize {
+			t.Error(test.count, test.jobs, batchSize)
+		}
+	}
+	var huge big.Int
+	huge.Lsh(big.NewInt(1), 100)
+	batchSize := getWitnessBatchSize(&huge, 1)
+	if batchSize != maxWitnessBatchSize {
+		t.Error(batchSize)
+	}
+}
+
+// ExplainAKSWitness should agree with IsAKSWitness and show where
+// the two sides differ.
+func TestExplainAKSWitness(t *testing.T) {
+	n := big.NewInt(2993374621)
+	r := mustCalculateAKSModulus(n, t)
+	e, err := ExplainAKSWitness(n, r, big.NewInt(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !e.IsWitness() || e.LHS == e.RHS ||
+		e.LHSCoefficient.Cmp(e.RHSCoefficient) == 0 {
+		t.Error(e.FirstDifference, e.LHSCoefficient, e.RHSCoefficient)
+	}
+
+	// For a prime, both sides are X^(n mod r) + a.
+	p := big.NewInt(1000003)
+	pR := mustCalculateAKSModulus(p, t)
+	e, err = ExplainAKSWitness(p, pR, big.NewInt(5))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var nModR big.Int
+	nModR.Mod(p, pR)
+	expected := fmt.Sprintf("x^%v + 5", &nModR)
+	if e.IsWitness() || e.LHS != expected || e.RHS != expected {
+		t.Error(e.FirstDifference, e.LHS, e.RHS)
+	}
+}
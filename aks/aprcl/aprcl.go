@@ -0,0 +1,325 @@
+// Package aprcl decides primality exactly with the Jacobi sum test of
+// Adleman, Pomerance, and Rumely, as improved by Cohen and Lenstra
+// (APR-CL). Its running time is (log n)^O(log log log n), which isn't
+// quite polynomial, but in practice it is much faster than AKS, and
+// proves numbers of a few hundred digits prime in seconds to minutes.
+// Unlike ECPP, it produces no certificate; its result has to be
+// trusted or recomputed.
+//
+// The test picks t such that e(t) = 2 prod_{q prime, q - 1 | t}
+// q^(v_q(t) + 1) is above sqrt(n), and checks, for each such q and
+// each prime power p^k exactly dividing q - 1, a condition on Jacobi
+// sums of characters of order p^k mod q that every prime satisfies.
+// If n passes all of them, every divisor of n is congruent to a power
+// of n mod e(t), so at most t - 1 trial divisions finish the test.
+package aprcl
+
+import "github.com/akalin/aks-go/aks"
+import "context"
+import "fmt"
+import "math/big"
+
+// The values of t to pick from, in ascending order. Each is chosen to
+// make e(t) large for its size; the last one handles n of up to about
+// 6000 digits.
+var tValues = []int{
+	2, 12, 60, 180, 840, 1260, 1680, 2520, 5040, 15120, 55440,
+	110880, 720720, 1441440, 4324320, 24504480, 73513440,
+}
+
+// The number of extra primes q tried for each prime p for which the
+// conditions checked by the main Jacobi sum tests don't imply Lenstra's
+// condition L_p. Running out, which is very unlikely for prime n,
+// means the test can't decide.
+const maxExtraPrimes = 500
+
+// Returns whether n is prime, exactly, with the APR-CL test. Returns
+// false, nil quickly for most composites, which fail the Baillie-PSW
+// test. Returns an error wrapping aks.ErrProofNotFound if n is too
+// large for the table of values of t or the test can't decide (which
+// is very unlikely), or aks.ErrBadInput if n < 0.
+func IsPrime(n *big.Int) (bool, error) {
+	return IsPrimeContext(context.Background(), n)
+}
+
+// Like IsPrime, but returns false and ctx.Err() if ctx is cancelled
+// before the test is done.
+func IsPrimeContext(ctx context.Context, n *big.Int) (bool, error) {
+	if n.Sign() < 0 {
+		return false, fmt.Errorf(
+			"%w: n = %v must be >= 0", aks.ErrBadInput, n)
+	}
+	if n.IsUint64() {
+		return aks.IsPrimeUint64(n.Uint64()), nil
+	}
+	if isPrime, _ := aks.BailliePSW(n); !isPrime {
+		return false, nil
+	}
+	return aprcl(ctx, n)
+}
+
+// Like IsPrimeContext, but runs the APR-CL test on n >= 2^64 without
+// checking it with the Baillie-PSW test first.
+func aprcl(ctx context.Context, n *big.Int) (bool, error) {
+	t, e := chooseT(n)
+	if t == 0 {
+		return false, fmt.Errorf(
+			"%w: n = %v is too large for APR-CL",
+			aks.ErrProofNotFound, n)
+	}
+	var te big.Int
+	te.Mul(big.NewInt(int64(t)), e)
+	if te.GCD(nil, nil, &te, n).Cmp(big.NewInt(1)) != 0 {
+		return false, nil
+	}
+
+	s := newAPRCLState(n, t)
+	qs := getEulerPrimes(t)
+	for _, q := range qs {
+		if err := ctx.Err(); err != nil {
+			return false, err
+		}
+		primes, exponents := factorSmall(q - 1)
+		for i, p := range primes {
+			if !s.testJacobiSums(p, exponents[i], q) {
+				return false, nil
+			}
+		}
+	}
+
+	isPrime, err := s.checkLenstraConditions(ctx, qs)
+	if err != nil || !isPrime {
+		return false, err
+	}
+	return !hasDivisorInPowers(n, t, e), nil
+}
+
+// Returns the smallest t in tValues with e(t)^2 > n, and e(t), or 0
+// and nil if there is none.
+func chooseT(n *big.Int) (int, *big.Int) {
+	var eSq big.Int
+	for _, t := range tValues {
+		e := getE(t)
+		if eSq.Mul(e, e).Cmp(n) > 0 {
+			return t, e
+		}
+	}
+	return 0, nil
+}
+
+// Returns whether the int n > 1 is prime.
+func isPrimeInt(n int) bool {
+	return n > 1 && aks.IsPrimeUint64(uint64(n))
+}
+
+// Returns the primes q >= 3 with q - 1 dividing t, in ascending order.
+func getEulerPrimes(t int) []int {
+	var qs []int
+	for d := 2; d <= t; d += 2 {
+		if t%d == 0 && isPrimeInt(d+1) {
+			qs = append(qs, d+1)
+		}
+	}
+	return qs
+}
+
+// Returns e(t) = 2 prod_{q prime, q - 1 | t} q^(v_q(t) + 1).
+func getE(t int) *big.Int {
+	e := big.NewInt(2)
+	for _, q := range append([]int{2}, getEulerPrimes(t)...) {
+		k := 1
+		for u := t; u%q == 0; u /= q {
+			k++
+		}
+		e.Mul(e, new(big.Int).Exp(
+			big.NewInt(int64(q)), big.NewInt(int64(k)), nil))
+	}
+	return e
+}
+
+// Returns whether n^i mod e, for some 1 <= i < t, is a proper divisor
+// of n greater than 1. If n passes the Jacobi sum tests and Lenstra's
+// conditions hold, every divisor of n is such a power, so n is prime
+// if there are none.
+func hasDivisorInPowers(n *big.Int, t int, e *big.Int) bool {
+	one := big.NewInt(1)
+	r := big.NewInt(1)
+	var nModE, rem big.Int
+	nModE.Mod(n, e)
+	for i := 1; i < t; i++ {
+		r.Mul(r, &nModE)
+		r.Mod(r, e)
+		if r.Cmp(one) != 0 && r.Cmp(n) < 0 &&
+			rem.Rem(n, r).Sign() == 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// Holds the state of the APR-CL test for n.
+type aprclState struct {
+	n *big.Int
+	// Whether Lenstra's condition L_p is known to hold, for each
+	// prime p dividing t.
+	lenstra map[int]bool
+}
+
+// Returns the initial state for n and t, where L_p is known to hold
+// for the primes p >= 3 dividing t with n^(p - 1) != 1 (mod p^2).
+func newAPRCLState(n *big.Int, t int) *aprclState {
+	s := &aprclState{n: n, lenstra: make(map[int]bool)}
+	primes, _ := factorSmall(t)
+	var x big.Int
+	for _, p := range primes {
+		pBig := big.NewInt(int64(p))
+		pSq := new(big.Int).Mul(pBig, pBig)
+		s.lenstra[p] = p >= 3 && x.Exp(n, big.NewInt(int64(p-1)), pSq).
+			Cmp(big.NewInt(1)) != 0
+	}
+	return s
+}
+
+// Runs the Jacobi sum test for the prime q and the prime power p^k
+// exactly dividing q - 1, and returns false if it shows n is
+// composite. Records in s if the test also shows that L_p holds.
+func (s *aprclState) testJacobiSums(p, k, q int) bool {
+	n := s.n
+	qBig := big.NewInt(int64(q))
+	nMinusOne := new(big.Int).Sub(n, big.NewInt(1))
+	halfNMinusOne := new(big.Int).Rsh(nMinusOne, 1)
+	// For p = 2, L_2 also needs q^((n - 1)/2) = -1 (mod n).
+	isQNonResidue := func() bool {
+		var x big.Int
+		return x.Exp(qBig, halfNMinusOne, n).Cmp(nMinusOne) == 0
+	}
+
+	if p == 2 && k == 1 {
+		// The characters are quadratic, and the test reduces
+		// to (-q)^((n - 1)/2) = +-1 (mod n).
+		x := new(big.Int).Sub(n, qBig)
+		x.Exp(x, halfNMinusOne, n)
+		if x.Cmp(big.NewInt(1)) != 0 && x.Cmp(nMinusOne) != 0 {
+			return false
+		}
+		if x.Cmp(nMinusOne) == 0 && n.Bit(1) == 0 {
+			s.lenstra[2] = true
+		}
+		return true
+	}
+
+	r := newCyclotomicRing(p, k, n)
+	f := getJacobiSumTable(q)
+	var S ringElement
+	if p == 2 && k == 2 {
+		// S = (J^2 q)^floor(n/4), times J^2 if n = 3 (mod 4),
+		// for J = J(chi, chi).
+		J := jacobiSum(r, f, 1, 1)
+		JSq := r.mul(J, J)
+		S = r.exp(r.mulScalar(JSq, qBig), new(big.Int).Rsh(n, 2))
+		if n.Bit(1) != 0 {
+			S = r.mul(S, JSq)
+		}
+	} else {
+		S = s.getStickelbergerProduct(r, f)
+	}
+
+	h, ok := r.findRootOfUnity(S)
+	if !ok {
+		return false
+	}
+	if h%p != 0 && (p != 2 || isQNonResidue()) {
+		s.lenstra[p] = true
+	}
+	return true
+}
+
+// Returns S = (J^Theta)^floor(n/p^k) J^alpha for p >= 3, where J =
+// J(chi, chi), Theta = sum_{x in E} x sigma_x^-1, alpha = sum_{x in E}
+// floor((n mod p^k) x/p^k) sigma_x^-1, and E is the set of x in [1,
+// p^k) coprime to p. For p = 2 and k >= 3, J is J(chi, chi) J(chi^2,
+// chi), E is the set of x = 1 or 3 (mod 8), and S has an extra factor
+// of J(chi^(3 2^(k-3)), chi^(2^(k-3)))^2 unless n = 1 or 3 (mod 8).
+// For prime n, S is a p^k-th root of unity.
+func (s *aprclState) getStickelbergerProduct(
+	r *cyclotomicRing, f []int) ringElement {
+	pkBig := big.NewInt(int64(r.pk))
+	var quo, rem big.Int
+	quo.QuoRem(s.n, pkBig, &rem)
+	nModPK := int(rem.Int64())
+
+	J := jacobiSum(r, f, 1, 1)
+	inE := func(x int) bool { return x%r.p != 0 }
+	var extra ringElement
+	if r.p == 2 {
+		J = r.mul(J, jacobiSum(r, f, 2, 1))
+		inE = func(x int) bool { return x%8 == 1 || x%8 == 3 }
+		if !inE(nModPK) {
+			j2 := jacobiSum(r, f, 3*r.pk/8, r.pk/8)
+			extra = r.mul(j2, j2)
+		}
+	}
+
+	theta := r.zetaPower(0)
+	alpha := r.zetaPower(0)
+	var xInv big.Int
+	for x := 1; x < r.pk; x++ {
+		if !inE(x) {
+			continue
+		}
+		xInv.ModInverse(big.NewInt(int64(x)), pkBig)
+		sigmaJ := r.sigma(J, int(xInv.Int64()))
+		theta = r.mul(theta, r.exp(sigmaJ, big.NewInt(int64(x))))
+		alpha = r.mul(alpha, r.exp(
+			sigmaJ, big.NewInt(int64(nModPK*x/r.pk))))
+	}
+	S := r.mul(r.exp(theta, &quo), alpha)
+	if extra != nil {
+		S = r.mul(S, extra)
+	}
+	return S
+}
+
+// Makes sure L_p holds for every prime p dividing t, by running more
+// Jacobi sum tests with primes q = 1 (mod p) other than qs for the
+// ones that aren't yet known to. Returns false if one of the tests
+// shows n is composite.
+func (s *aprclState) checkLenstraConditions(
+	ctx context.Context, qs []int) (bool, error) {
+	used := make(map[int]bool)
+	for _, q := range qs {
+		used[q] = true
+	}
+	var rem big.Int
+	for p, holds := range s.lenstra {
+		tries := 0
+		for q := p + 1; !holds; q += p {
+			if q%2 == 0 || used[q] || !isPrimeInt(q) {
+				continue
+			}
+			if tries >= maxExtraPrimes {
+				return false, fmt.Errorf(
+					"%w: L_%d couldn't be shown to hold "+
+						"for n = %v",
+					aks.ErrProofNotFound, p, s.n)
+			}
+			tries++
+			if err := ctx.Err(); err != nil {
+				return false, err
+			}
+			qBig := big.NewInt(int64(q))
+			if rem.Rem(s.n, qBig).Sign() == 0 {
+				return false, nil
+			}
+			k := 0
+			for u := q - 1; u%p == 0; u /= p {
+				k++
+			}
+			if !s.testJacobiSums(p, k, q) {
+				return false, nil
+			}
+			holds = s.lenstra[p]
+		}
+	}
+	return true, nil
+}
@@ -0,0 +1,118 @@
+package aprcl
+
+import "github.com/akalin/aks-go/aks"
+import "context"
+import "errors"
+import "math/big"
+import "testing"
+
+// Returns the smallest prime >= n.
+func nextPrime(n *big.Int) *big.Int {
+	p := new(big.Int).Set(n)
+	for !p.ProbablyPrime(20) {
+		p.Add(p, big.NewInt(1))
+	}
+	return p
+}
+
+// Returns 2^k - 1.
+func mersenne(k uint) *big.Int {
+	m := new(big.Int).Lsh(big.NewInt(1), k)
+	return m.Sub(m, big.NewInt(1))
+}
+
+// IsPrime should agree with big.Int.ProbablyPrime on numbers of
+// various sizes.
+func TestIsPrime(t *testing.T) {
+	for _, n := range []*big.Int{
+		big.NewInt(0), big.NewInt(1), big.NewInt(2), big.NewInt(561),
+		big.NewInt(1000003), mersenne(61), mersenne(64),
+		mersenne(89), mersenne(127), mersenne(128), mersenne(521),
+		nextPrime(new(big.Int).Lsh(big.NewInt(1), 64)),
+		nextPrime(new(big.Int).Lsh(big.NewInt(1), 200)),
+		nextPrime(new(big.Int).Lsh(big.NewInt(1), 300)),
+	} {
+		isPrime, err := IsPrime(n)
+		if err != nil {
+			t.Fatal(n, err)
+		}
+		if isPrime != n.ProbablyPrime(20) {
+			t.Error(n, isPrime)
+		}
+	}
+}
+
+// The APR-CL test itself, without Baillie-PSW, should reject
+// composites, including Carmichael numbers and squares of primes.
+func TestAPRCLComposites(t *testing.T) {
+	p := nextPrime(new(big.Int).Lsh(big.NewInt(1), 40))
+	q := nextPrime(new(big.Int).Lsh(big.NewInt(1), 60))
+	composites := []*big.Int{
+		new(big.Int).Mul(p, q),
+		new(big.Int).Mul(q, q),
+		new(big.Int).Lsh(big.NewInt(1000003), 60),
+	}
+	// Carmichael numbers of the form (6k + 1)(12k + 1)(18k + 1).
+	for k := int64(1 << 22); len(composites) < 6; k++ {
+		n := big.NewInt(1)
+		for _, m := range []int64{6, 12, 18} {
+			f := big.NewInt(m*k + 1)
+			if !f.ProbablyPrime(20) {
+				n = nil
+				break
+			}
+			n.Mul(n, f)
+		}
+		if n != nil {
+			composites = append(composites, n)
+		}
+	}
+	for _, n := range composites {
+		isPrime, err := aprcl(context.Background(), n)
+		if isPrime || err != nil {
+			t.Error(n, isPrime, err)
+		}
+	}
+}
+
+// IsPrime should reject bad input and stop when its context is
+// cancelled.
+func TestIsPrimeErrors(t *testing.T) {
+	if isPrime, err := IsPrime(big.NewInt(-1)); !errors.Is(
+		err, aks.ErrBadInput) {
+		t.Error(isPrime, err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if isPrime, err := IsPrimeContext(ctx, mersenne(127)); err !=
+		context.Canceled {
+		t.Error(isPrime, err)
+	}
+}
+
+// getE should match known values of e(t), and chooseT should pick the
+// smallest t with e(t)^2 > n.
+func TestGetE(t *testing.T) {
+	for _, c := range []struct {
+		t int
+		e int64
+	}{
+		{2, 24},
+		{12, 65520},
+		{60, 6814407600},
+	} {
+		if e := getE(c.t); e.Cmp(big.NewInt(c.e)) != 0 {
+			t.Error(c.t, e, c.e)
+		}
+	}
+
+	n := new(big.Int).Mul(getE(60), getE(60))
+	if tt, e := chooseT(n); tt != 180 || e.Cmp(getE(180)) != 0 {
+		t.Error(tt, e)
+	}
+	n.Sub(n, big.NewInt(1))
+	if tt, _ := chooseT(n); tt != 60 {
+		t.Error(tt)
+	}
+}
@@ -0,0 +1,80 @@
+package aprcl
+
+import "math/big"
+
+// Returns the prime factors of n > 0 and their multiplicities, in
+// ascending order of prime, by trial division.
+func factorSmall(n int) (primes, exponents []int) {
+	for p := 2; p*p <= n; p++ {
+		if n%p != 0 {
+			continue
+		}
+		k := 0
+		for ; n%p == 0; n /= p {
+			k++
+		}
+		primes = append(primes, p)
+		exponents = append(exponents, k)
+	}
+	if n > 1 {
+		primes = append(primes, n)
+		exponents = append(exponents, 1)
+	}
+	return primes, exponents
+}
+
+// Returns the smallest primitive root mod the prime q.
+func findPrimitiveRoot(q int) int {
+	primes, _ := factorSmall(q - 1)
+	bigQ := big.NewInt(int64(q))
+	var x big.Int
+	for g := 2; ; g++ {
+		isPrimitive := true
+		for _, p := range primes {
+			x.Exp(big.NewInt(int64(g)),
+				big.NewInt(int64((q-1)/p)), bigQ)
+			if x.Cmp(big.NewInt(1)) == 0 {
+				isPrimitive = false
+				break
+			}
+		}
+		if isPrimitive {
+			return g
+		}
+	}
+}
+
+// Returns f with g^f[x] = 1 - g^x (mod q) for 1 <= x <= q - 2, where
+// g is the smallest primitive root mod the odd prime q. For a
+// character chi of (Z/qZ)* with chi(g) = zeta, the Jacobi sum
+// J(chi^a, chi^b) = sum_x chi^a(x) chi^b(1 - x) is then sum_{1 <= x
+// <= q - 2} zeta^(ax + bf[x]).
+func getJacobiSumTable(q int) []int {
+	g := findPrimitiveRoot(q)
+	// log[y] = x if g^x = y (mod q).
+	log := make([]int, q)
+	y := 1
+	for x := 0; x < q-1; x++ {
+		log[y] = x
+		y = y * g % q
+	}
+	f := make([]int, q-1)
+	y = 1
+	for x := 1; x <= q-2; x++ {
+		y = y * g % q
+		f[x] = log[(1-y+q)%q]
+	}
+	return f
+}
+
+// Returns the Jacobi sum J(chi^a, chi^b) in r, where chi is the
+// character of order r.pk mod q with chi(g) = zeta for the primitive
+// root g used by f, which is from getJacobiSumTable(q).
+func jacobiSum(r *cyclotomicRing, f []int, a, b int) ringElement {
+	x := r.zero()
+	for i := 1; i < len(f); i++ {
+		e := (a*i + b*f[i]) % r.pk
+		x[e].Add(x[e], big.NewInt(1))
+	}
+	return r.reduce(x)
+}
@@ -0,0 +1,138 @@
+package aprcl
+
+import "math/big"
+
+// Holds the ring Z[zeta]/nZ[zeta] for a primitive p^k-th root of
+// unity zeta, i.e., Z[x]/(n, Phi_{p^k}(x)), where Phi_{p^k}(x) =
+// sum_{0 <= i < p} x^(i p^(k-1)) is the p^k-th cyclotomic polynomial.
+type cyclotomicRing struct {
+	p, pk int
+	// The degree (p - 1)p^(k-1) of Phi_{p^k}.
+	phi int
+	n   *big.Int
+}
+
+// Holds an element of a cyclotomicRing as the coefficients of a
+// polynomial in zeta of degree less than pk, in ascending order of
+// degree. Reduced elements have coefficients in [0, n) and degree
+// less than phi.
+type ringElement []*big.Int
+
+// Returns the ring Z[zeta]/nZ[zeta] for a primitive p^k-th root of
+// unity zeta, for prime p and k >= 1.
+func newCyclotomicRing(p, k int, n *big.Int) *cyclotomicRing {
+	pk := 1
+	for i := 0; i < k; i++ {
+		pk *= p
+	}
+	return &cyclotomicRing{p, pk, pk / p * (p - 1), n}
+}
+
+// Returns the zero element of r.
+func (r *cyclotomicRing) zero() ringElement {
+	x := make(ringElement, r.pk)
+	for i := range x {
+		x[i] = new(big.Int)
+	}
+	return x
+}
+
+// Returns zeta^h, reduced.
+func (r *cyclotomicRing) zetaPower(h int) ringElement {
+	x := r.zero()
+	x[h%r.pk].SetInt64(1)
+	return r.reduce(x)
+}
+
+// Reduces x in place mod n and Phi_{p^k}(zeta), and returns it.
+func (r *cyclotomicRing) reduce(x ringElement) ringElement {
+	step := r.pk / r.p
+	// zeta^((p - 1)p^(k-1)) = -sum_{0 <= j < p - 1} zeta^(j
+	// p^(k-1)), so each coefficient at or above phi can be pushed
+	// down onto lower ones.
+	for i := r.pk - 1; i >= r.phi; i-- {
+		if x[i].Sign() == 0 {
+			continue
+		}
+		base := i - r.phi
+		for j := 0; j < r.p-1; j++ {
+			x[base+j*step].Sub(x[base+j*step], x[i])
+		}
+		x[i].SetInt64(0)
+	}
+	for _, c := range x[:r.phi] {
+		c.Mod(c, r.n)
+	}
+	return x
+}
+
+// Returns x*y, reduced, for reduced x and y.
+func (r *cyclotomicRing) mul(x, y ringElement) ringElement {
+	z := r.zero()
+	var t big.Int
+	for i, a := range x[:r.phi] {
+		if a.Sign() == 0 {
+			continue
+		}
+		for j, b := range y[:r.phi] {
+			k := i + j
+			if k >= r.pk {
+				k -= r.pk
+			}
+			z[k].Add(z[k], t.Mul(a, b))
+		}
+	}
+	return r.reduce(z)
+}
+
+// Returns c*x, reduced, for reduced x.
+func (r *cyclotomicRing) mulScalar(x ringElement, c *big.Int) ringElement {
+	z := r.zero()
+	for i, a := range x[:r.phi] {
+		z[i].Mul(a, c)
+	}
+	return r.reduce(z)
+}
+
+// Returns x^e, reduced, for reduced x and e >= 0.
+func (r *cyclotomicRing) exp(x ringElement, e *big.Int) ringElement {
+	z := r.zetaPower(0)
+	for i := e.BitLen() - 1; i >= 0; i-- {
+		z = r.mul(z, z)
+		if e.Bit(i) != 0 {
+			z = r.mul(z, x)
+		}
+	}
+	return z
+}
+
+// Returns the image of the reduced x under the automorphism zeta ->
+// zeta^u, for u coprime to p, reduced.
+func (r *cyclotomicRing) sigma(x ringElement, u int) ringElement {
+	z := r.zero()
+	for i, a := range x[:r.phi] {
+		z[i*u%r.pk].Set(a)
+	}
+	return r.reduce(z)
+}
+
+// Returns h in [0, pk) such that the reduced x = zeta^h, or false if
+// there is none.
+func (r *cyclotomicRing) findRootOfUnity(x ringElement) (int, bool) {
+	for h := 0; h < r.pk; h++ {
+		if r.equal(x, r.zetaPower(h)) {
+			return h, true
+		}
+	}
+	return 0, false
+}
+
+// Returns whether the reduced x and y are equal.
+func (r *cyclotomicRing) equal(x, y ringElement) bool {
+	for i := 0; i < r.phi; i++ {
+		if x[i].Cmp(y[i]) != 0 {
+			return false
+		}
+	}
+	return true
+}
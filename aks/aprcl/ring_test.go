@@ -0,0 +1,63 @@
+package aprcl
+
+import "math/big"
+import "testing"
+
+// J(chi, chi) times its complex conjugate should be q, for characters
+// chi of each prime power order p^k > 2 dividing q - 1.
+func TestJacobiSumNorm(t *testing.T) {
+	n := mersenne(127)
+	for _, q := range []int{5, 7, 11, 13, 17, 29, 37, 41, 73, 97} {
+		f := getJacobiSumTable(q)
+		primes, exponents := factorSmall(q - 1)
+		for i, p := range primes {
+			for k := 1; k <= exponents[i]; k++ {
+				r := newCyclotomicRing(p, k, n)
+				if r.pk == 2 {
+					// J(chi, chi) = -chi(-1) for
+					// quadratic chi.
+					continue
+				}
+				J := jacobiSum(r, f, 1, 1)
+				norm := r.mul(J, r.sigma(J, r.pk-1))
+				want := r.mulScalar(
+					r.zetaPower(0), big.NewInt(int64(q)))
+				if !r.equal(norm, want) {
+					t.Error(q, p, k, norm)
+				}
+			}
+		}
+	}
+}
+
+// findRootOfUnity should find exactly the powers of zeta.
+func TestFindRootOfUnity(t *testing.T) {
+	n := mersenne(127)
+	for _, c := range []struct{ p, k int }{
+		{2, 1}, {2, 2}, {2, 4}, {3, 1}, {3, 2}, {5, 1}, {7, 1},
+	} {
+		r := newCyclotomicRing(c.p, c.k, n)
+		for h := 0; h < r.pk; h++ {
+			x := r.zetaPower(h)
+			if got, ok := r.findRootOfUnity(x); !ok || got != h {
+				t.Error(c, h, got, ok)
+			}
+			// sigma_u(zeta^h) = zeta^(hu).
+			u := r.pk - 1
+			y := r.sigma(x, u)
+			if got, ok := r.findRootOfUnity(y); !ok ||
+				got != h*u%r.pk {
+				t.Error(c, h, got, ok)
+			}
+		}
+		x := r.mulScalar(r.zetaPower(1), big.NewInt(2))
+		if h, ok := r.findRootOfUnity(x); ok {
+			t.Error(c, h)
+		}
+		// zeta^pk = 1.
+		x = r.exp(r.zetaPower(1), big.NewInt(int64(r.pk)))
+		if h, ok := r.findRootOfUnity(x); !ok || h != 0 {
+			t.Error(c, h, ok)
+		}
+	}
+}
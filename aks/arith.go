@@ -1,5 +1,7 @@
 package aks
 
+import "fmt"
+import "math"
 import "math/big"
 
 // Returns the smaller of x and y. No copies are made, so the returned
@@ -20,9 +22,9 @@ func max(x, y *big.Int) *big.Int {
 	return y
 }
 
-// Returns the greatest number y such that y^k <= x. x must be
-// non-negative and k must be positive.
-func floorRoot(x, k *big.Int) *big.Int {
+// Returns the greatest number y such that y^k <= x, and whether y^k =
+// x. x must be non-negative and k must be positive.
+func floorRoot(x, k *big.Int) (*big.Int, bool) {
 	if x.Sign() < 0 {
 		panic("negative radicand")
 	}
@@ -30,7 +32,7 @@ func floorRoot(x, k *big.Int) *big.Int {
 		panic("non-negative index")
 	}
 	if x.Sign() == 0 {
-		return &big.Int{}
+		return &big.Int{}, true
 	}
 	one := big.NewInt(1)
 	var kMinusOne big.Int
@@ -50,21 +52,151 @@ func floorRoot(x, k *big.Int) *big.Int {
 		var z1 big.Int
 		z1.Mul(&kMinusOne, y)
 
-		var z2 big.Int
+		var z2, rem big.Int
 		var yPowKMinusOne big.Int
 		yPowKMinusOne.Exp(y, &kMinusOne, nil)
-		z2.Div(x, &yPowKMinusOne)
+		z2.QuoRem(x, &yPowKMinusOne, &rem)
 
 		var z big.Int
 		z.Add(&z1, &z2)
 		z.Div(&z, k)
 
 		if z.Cmp(y) >= 0 {
-			return y
+			// y^k = x exactly when x/y^(k-1) = y with no
+			// remainder.
+			return y, rem.Sign() == 0 && z2.Cmp(y) == 0
 		}
 		y = &z
 	}
-	return one
+	return one, x.Cmp(one) == 0
+}
+
+// Returns floor(sqrt(x)) and whether it's exact, for x >= 0. This is
+// much faster than floorRoot(x, 2), since x that fit in a uint64 are
+// handled with floorSqrt, and larger ones with big.Int.Sqrt, which
+// works on whole words.
+func sqrtExact(x *big.Int) (*big.Int, bool) {
+	if x.IsUint64() {
+		u := x.Uint64()
+		y := floorSqrt(u)
+		return new(big.Int).SetUint64(y), y*y == u
+	}
+	y := new(big.Int).Sqrt(x)
+	var ySq big.Int
+	return y, ySq.Mul(y, y).Cmp(x) == 0
+}
+
+// The moduli isSquare checks x against before taking its square root.
+// Only about 1 in 120 non-squares is a square mod all of them.
+var squareFilterModuli = []uint64{64, 63, 65, 11}
+
+// The product of squareFilterModuli.
+var squareFilterProduct = big.NewInt(64 * 63 * 65 * 11)
+
+// squareFilter[i][r] is whether r is a square mod
+// squareFilterModuli[i].
+var squareFilter = makeSquareFilter(squareFilterModuli)
+
+// Returns the table for squareFilter.
+func makeSquareFilter(moduli []uint64) [][]bool {
+	filter := make([][]bool, len(moduli))
+	for i, m := range moduli {
+		filter[i] = make([]bool, m)
+		for r := uint64(0); r < m; r++ {
+			filter[i][r*r%m] = true
+		}
+	}
+	return filter
+}
+
+// Returns whether x >= 0 is a square. Most non-squares are ruled out
+// by their residues mod squareFilterModuli, which only take a single
+// division by their product to find.
+func isSquare(x *big.Int) bool {
+	var rem big.Int
+	r := rem.Mod(x, squareFilterProduct).Uint64()
+	for i, m := range squareFilterModuli {
+		if !squareFilter[i][r%m] {
+			return false
+		}
+	}
+	_, exact := sqrtExact(x)
+	return exact
+}
+
+// Returns floor(sqrt(n)), or an error wrapping ErrBadInput if n is
+// negative.
+func Sqrt(n *big.Int) (*big.Int, error) {
+	if n.Sign() < 0 {
+		return nil, fmt.Errorf(
+			"%w: n = %v must be non-negative", ErrBadInput, n)
+	}
+	y, _ := sqrtExact(n)
+	return y, nil
+}
+
+// Returns whether n is a square, or an error wrapping ErrBadInput if
+// n is negative.
+func IsSquare(n *big.Int) (bool, error) {
+	if n.Sign() < 0 {
+		return false, fmt.Errorf(
+			"%w: n = %v must be non-negative", ErrBadInput, n)
+	}
+	return isSquare(n), nil
+}
+
+// Returns b and the largest k such that n = b^k. n must be positive.
+func getPerfectPower(n *big.Int) (*big.Int, int64) {
+	b := n
+	k := int64(1)
+	// Every k is a product of primes, so take prime roots for as
+	// long as they're exact.
+	for _, p := range getPrimesIn(2, uint64(n.BitLen())+1) {
+		bigP := new(big.Int).SetUint64(p)
+		for b.Cmp(big.NewInt(1)) > 0 {
+			root, exact := floorRoot(b, bigP)
+			if !exact {
+				break
+			}
+			b = root
+			k *= int64(p)
+		}
+	}
+	return b, k
+}
+
+// Returns b and the largest e such that n = b^e, so that n is a
+// perfect power exactly when e > 1, and b never is. The roots of n
+// for each prime up to lg(n) are taken for as long as they're exact.
+// Returns an error wrapping ErrBadInput if n isn't positive.
+func PerfectPowerDecompose(n *big.Int) (*big.Int, int64, error) {
+	if n.Sign() <= 0 {
+		return nil, 0, fmt.Errorf(
+			"%w: n = %v must be positive", ErrBadInput, n)
+	}
+	b, e := getPerfectPower(n)
+	return new(big.Int).Set(b), e, nil
+}
+
+// Returns whether n = b^e for some b and e > 1, as decided by
+// PerfectPowerDecompose. Returns an error wrapping ErrBadInput if n
+// isn't positive.
+func IsPerfectPower(n *big.Int) (bool, error) {
+	_, e, err := PerfectPowerDecompose(n)
+	return e > 1, err
+}
+
+// Like floorRoot(x, 2), but for uint64s. Fixes up the floating-point
+// estimate without overflowing.
+func floorSqrt(x uint64) uint64 {
+	y := uint64(math.Sqrt(float64(x)))
+	for y > 0 && y > x/y {
+		y--
+	}
+	for y+1 <= x/(y+1) {
+		y++
+	}
+	return y
 }
 
 // Assuming p is prime, calculates and returns Phi(p^k) quickly.
@@ -78,22 +210,44 @@ func calculateEulerPhiPrimePower(p, k *big.Int) *big.Int {
 	return &phi
 }
 
-// A factorFunction takes a prime and its multiplicity and returns
+// Trial division checks whether what's left to factor is prime once
+// it's past this bound; see TrialDivide.
+const trialDivisionPrimalityCheckStart = 1 << 12
+
+// A FactorFunction takes a prime and its multiplicity and returns
 // whether or not to continue trying to find more factors.
-type factorFunction func(p, m *big.Int) bool
+type FactorFunction func(p, m *big.Int) bool
+
+// Passes the prime factors of n and their multiplicities to factorFn
+// in ascending order, until it returns false, finding them by trial
+// division. If upperBound is not nil, only factors up to it are
+// tried, and whatever is left of n after dividing them out (if not 1)
+// is passed last with multiplicity 1, even though it may not be
+// prime. Once the factors below 2^12 are divided out, what's left is
+// also passed last as soon as it's below 2^64 and found to be prime
+// (which is exact there), instead of after trial dividing it up to
+// its square root. n and upperBound (if not nil) must be
+// non-negative; an error wrapping ErrBadInput is returned otherwise.
+// Nothing is passed for n = 0 or 1. Trial division is only practical
+// for n with small factors or up to around 10^20; Factorize,
+// EulerPhi, and MultiplicativeOrder switch to faster methods instead.
+func TrialDivide(n *big.Int, factorFn FactorFunction, upperBound *big.Int) error {
+	if n.Sign() < 0 {
+		return fmt.Errorf(
+			"%w: n = %v must be non-negative", ErrBadInput, n)
+	}
+	if upperBound != nil && upperBound.Sign() < 0 {
+		return fmt.Errorf(
+			"%w: upperBound = %v must be non-negative",
+			ErrBadInput, upperBound)
+	}
+	trialDivide(n, factorFn, upperBound)
+	return nil
+}
 
-// Does trial division to find factors of n and passes them to the
-// given factorFunction until it indicates otherwise. If upperBound is
-// not nil, only factors less than or equal to it will be tried.
-func trialDivide(n *big.Int, factorFn factorFunction, upperBound *big.Int) {
+// Like TrialDivide, but panics if n is negative.
+func trialDivide(n *big.Int, factorFn FactorFunction, upperBound *big.Int) {
 	one := big.NewInt(1)
-	two := big.NewInt(2)
-	three := big.NewInt(3)
-	four := big.NewInt(4)
-	five := big.NewInt(5)
-	six := big.NewInt(6)
-	seven := big.NewInt(7)
-	eleven := big.NewInt(11)
 
 	if n.Sign() < 0 {
 		panic("negative n")
@@ -102,25 +256,33 @@ func trialDivide(n *big.Int, factorFn factorFunction, upperBound *big.Int) {
 		return
 	}
 
+	// upperBound is lowered as t shrinks, so work on a copy.
 	if upperBound == nil {
-		upperBound = floorRoot(n, two)
+		upperBound, _ = sqrtExact(n)
+	} else {
+		upperBound = new(big.Int).Set(upperBound)
 	}
 
 	t := &big.Int{}
 	t.Set(n)
 
 	// Factors out d from t as much as possible and calls factorFn
-	// if d divides t.
+	// if d divides t. q and r are reused across calls, since most
+	// divisions leave a remainder.
+	q, r := &big.Int{}, &big.Int{}
+	tChanged := true
 	factorOut := func(d *big.Int) bool {
 		var m big.Int
 		for {
-			var q, r big.Int
-			q.QuoRem(t, d, &r)
+			q.QuoRem(t, d, r)
 			if r.Sign() != 0 {
 				break
 			}
-			t = &q
-			upperBound = min(upperBound, t)
+			t, q = q, t
+			if t.Cmp(upperBound) < 0 {
+				upperBound.Set(t)
+			}
+			tChanged = true
 			m.Add(&m, one)
 		}
 		if m.Sign() != 0 {
@@ -131,32 +293,34 @@ func trialDivide(n *big.Int, factorFn factorFunction, upperBound *big.Int) {
 		return true
 	}
 
-	// Try small primes first.
-	if two.Cmp(upperBound) <= 0 && !factorOut(two) {
-		return
-	}
-
-	if three.Cmp(upperBound) <= 0 && !factorOut(three) {
-		return
-	}
-
-	if five.Cmp(upperBound) <= 0 && !factorOut(five) {
-		return
-	}
-
-	if seven.Cmp(upperBound) <= 0 && !factorOut(seven) {
-		return
+	// Only try primes, which are much cheaper to look up or sieve
+	// than dividing by the composites a wheel would let through.
+	// They're only lifted to big.Ints to divide by. (Trial
+	// division past 2^64 would never finish anyway.)
+	end := uint64(math.MaxUint64)
+	if upperBound.IsUint64() && upperBound.Uint64() < end {
+		end = upperBound.Uint64() + 1
 	}
-
-	// Then run through a mod-30 wheel, which cuts the number of
-	// odd numbers to test roughly in half.
-	mod30Wheel := []*big.Int{four, two, four, two, four, six, two, six}
-	for i, d := 1, eleven; d.Cmp(upperBound) <= 0; {
-		if !factorOut(d) {
+	var d big.Int
+	for p := range Primes(2, end) {
+		if d.SetUint64(p).Cmp(upperBound) > 0 {
+			break
+		}
+		// Checking whether t is prime costs about as much as
+		// a few hundred divisions, so only do it once the
+		// factors most numbers have are gone, and then only
+		// when t changes. Above 2^64 the check would only be
+		// probabilistic, and callers like GetFirstFactorBelow
+		// need an exact answer, so don't stop early there.
+		if p > trialDivisionPrimalityCheckStart && tChanged {
+			tChanged = false
+			if t.IsUint64() && IsPrimeUint64(t.Uint64()) {
+				break
+			}
+		}
+		if !factorOut(&d) {
 			return
 		}
-		d.Add(d, mod30Wheel[i])
-		i = (i + 1) % len(mod30Wheel)
 	}
 	if t.Cmp(one) != 0 {
 		factorFn(t, one)
@@ -193,8 +357,24 @@ func calculateMultiplicativeOrderPrimePower(a, p, k *big.Int) *big.Int {
 
 	var pMinusOne big.Int
 	pMinusOne.Sub(p, one)
-	trialDivide(&pMinusOne, processPrimeFactor, nil)
+	c := factorizeBounded(&pMinusOne, processPrimeFactor)
+	if c.Cmp(one) == 0 {
+		return o
+	}
 
+	// p - 1 has a part c that's hard to factor, but the order of
+	// x = a^(t/c) divides c, so BSGS can find it without factoring
+	// c if it isn't too big. Failing that, factor c the slow way.
+	var x big.Int
+	x.Div(t, c)
+	x.Exp(a, &x, &n)
+	bsgsBound := big.NewInt(bsgsMaxSteps)
+	bsgsBound.Mul(bsgsBound, bsgsBound)
+	if oc := bsgsOrder(&x, &n, min(c, bsgsBound)); oc != nil {
+		o.Mul(o, oc)
+	} else {
+		factorize(c, processPrimeFactor)
+	}
 	return o
 }
 
@@ -202,24 +382,214 @@ func calculateMultiplicativeOrderPrimePower(a, p, k *big.Int) *big.Int {
 // a such that a^e = 1 (mod n).
 func calculateMultiplicativeOrder(a, n *big.Int) *big.Int {
 	o := big.NewInt(1)
-	trialDivide(n, func(q, e *big.Int) bool {
-		oq := calculateMultiplicativeOrderPrimePower(a, q, e)
+	for _, pk := range calculateFactorization(n).Factors {
+		oq := calculateMultiplicativeOrderPrimePower(a, pk.P, pk.K)
 		// Set o to lcm(o, oq).
-		var gcd big.Int
-		gcd.GCD(nil, nil, o, oq)
-		o.Div(o, &gcd)
+		o.Div(o, gcd(o, oq))
 		o.Mul(o, oq)
-		return true
-	}, nil)
+	}
 	return o
 }
 
+// Assuming that a and n are coprime, returns lower and upper bounds
+// for the smallest e > 0 such that a^e = 1 (mod n), given a
+// (possibly partial) factorization f of a multiple of e, such as
+// Phi(n), whose Cofactor is coprime to its Factors and has no prime
+// factors less than or equal to cofactorBound. The bounds are equal
+// if the order could be computed exactly, which is always the case if
+// f is complete.
+func calculateMultiplicativeOrderBounds(
+	a, n *big.Int, f *Factorization, cofactorBound *big.Int) (
+	lower, upper *big.Int) {
+	one := big.NewInt(1)
+	// If t = F*C with F and C coprime, then the order of a is the
+	// order of a^C, which divides F, times the order of a^F,
+	// which divides C.
+	var F big.Int
+	F.Div(f.N, f.Cofactor)
+	var y big.Int
+	y.Exp(a, f.Cofactor, n)
+	o := big.NewInt(1)
+	for _, pk := range f.Factors {
+		// Find the power of q dividing the order of y by
+		// starting from x = y^(F/q^k) and raising it to the
+		// qth power until it becomes 1.
+		var x big.Int
+		x.Exp(pk.P, pk.K, nil)
+		x.Div(&F, &x)
+		x.Exp(&y, &x, n)
+		for x.Cmp(one) != 0 {
+			o.Mul(o, pk.P)
+			x.Exp(&x, pk.P, n)
+		}
+	}
+
+	var z big.Int
+	if z.Exp(a, &F, n).Cmp(one) == 0 {
+		return o, o
+	}
+	// The order of a^F is a divisor of C greater than 1, so it's
+	// more than cofactorBound and at most C.
+	lower = new(big.Int).Add(cofactorBound, one)
+	lower.Mul(lower, o)
+	upper = new(big.Int).Mul(o, f.Cofactor)
+	return lower, upper
+}
+
 // Calculate Phi(n) by factorizing it.
 func calculateEulerPhi(n *big.Int) *big.Int {
-	phi := big.NewInt(1)
-	trialDivide(n, func(q, e *big.Int) bool {
-		phi.Mul(phi, calculateEulerPhiPrimePower(q, e))
-		return true
-	}, nil)
+	// The factorization is always complete.
+	phi, _ := calculateFactorization(n).EulerPhi()
 	return phi
 }
+
+// Returns Phi(n), the number of integers in [1, n] coprime to n,
+// which must be positive. n is factored by trial division, then
+// SQUFOF or Pollard's rho algorithm, then ECM (see ECM), so this is
+// only practical if all but one of n's prime factors are less than
+// around 10^25.
+func EulerPhi(n *big.Int) (*big.Int, error) {
+	if n.Sign() <= 0 {
+		return nil, fmt.Errorf(
+			"%w: n = %v must be positive", ErrBadInput, n)
+	}
+	return calculateEulerPhi(n), nil
+}
+
+// Returns the Mobius function mu(n) for positive n: 0 if n has a
+// square factor, and otherwise 1 or -1 as n has an even or odd number
+// of prime factors. n is factored as described in EulerPhi.
+func Mobius(n *big.Int) (int, error) {
+	if n.Sign() <= 0 {
+		return 0, fmt.Errorf(
+			"%w: n = %v must be positive", ErrBadInput, n)
+	}
+	// The factorization is always complete.
+	mu, _ := calculateFactorization(n).Mobius()
+	return mu, nil
+}
+
+// Returns the number of divisors of n, which must be positive. n is
+// factored as described in EulerPhi.
+func NumDivisors(n *big.Int) (*big.Int, error) {
+	if n.Sign() <= 0 {
+		return nil, fmt.Errorf(
+			"%w: n = %v must be positive", ErrBadInput, n)
+	}
+	return calculateFactorization(n).NumDivisors()
+}
+
+// Returns the sum of the divisors of n (including n), which must be
+// positive. n is factored as described in EulerPhi.
+func SumDivisors(n *big.Int) (*big.Int, error) {
+	if n.Sign() <= 0 {
+		return nil, fmt.Errorf(
+			"%w: n = %v must be positive", ErrBadInput, n)
+	}
+	return calculateFactorization(n).SumDivisors()
+}
+
+// Returns whether n is B-smooth, i.e., has no prime factor greater
+// than B. n must be positive and B non-negative; an error wrapping
+// ErrBadInput is returned otherwise. The primes up to B are divided
+// out of n until what's left is at most B, which makes n smooth, or
+// is found to be prime, which makes it not, so this is practical
+// for any n as long as B is below around 10^10 or n is smooth.
+func IsSmooth(n, B *big.Int) (bool, error) {
+	if n.Sign() <= 0 {
+		return false, fmt.Errorf(
+			"%w: n = %v must be positive", ErrBadInput, n)
+	}
+	if B.Sign() < 0 {
+		return false, fmt.Errorf(
+			"%w: B = %v must be non-negative", ErrBadInput, B)
+	}
+
+	one := big.NewInt(1)
+	t := new(big.Int).Set(n)
+	if t.Cmp(B) <= 0 || t.Cmp(one) == 0 {
+		return true, nil
+	}
+	end := uint64(math.MaxUint64)
+	if B.IsUint64() && B.Uint64() < end {
+		end = B.Uint64() + 1
+	}
+	tChanged := true
+	var d, dSq, q, r big.Int
+	for p := range Primes(2, end) {
+		d.SetUint64(p)
+		// t has no prime factors below p, so if it's less
+		// than p^2, it's a prime greater than B.
+		if dSq.Mul(&d, &d).Cmp(t) > 0 {
+			return false, nil
+		}
+		// As in trialDivide, only check whether t is prime
+		// once the factors most numbers have are gone.
+		if p > trialDivisionPrimalityCheckStart && tChanged {
+			tChanged = false
+			if isProbablePrime(t) {
+				return false, nil
+			}
+		}
+		for {
+			q.QuoRem(t, &d, &r)
+			if r.Sign() != 0 {
+				break
+			}
+			t.Set(&q)
+			tChanged = true
+		}
+		if t.Cmp(B) <= 0 {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// Returns the Jacobi symbol (a/n), which is 0 if a and n aren't
+// coprime, and otherwise 1 or -1. For prime n this is the Legendre
+// symbol, which is 1 exactly when a is a nonzero square mod n. n must
+// be odd and positive; an error wrapping ErrBadInput is returned
+// otherwise.
+func Jacobi(a, n *big.Int) (int, error) {
+	if n.Sign() <= 0 || n.Bit(0) == 0 {
+		return 0, fmt.Errorf(
+			"%w: n = %v must be odd and positive", ErrBadInput, n)
+	}
+	return big.Jacobi(a, n), nil
+}
+
+// Returns whether x^2 = a (mod p) has a solution x, where p must be
+// prime (as decided by big.Int.ProbablyPrime); an error wrapping
+// ErrBadInput is returned otherwise. a = 0 (mod p) counts as a
+// square.
+func IsQuadraticResidue(a, p *big.Int) (bool, error) {
+	if !p.ProbablyPrime(primalityRounds) {
+		return false, fmt.Errorf(
+			"%w: p = %v must be prime", ErrBadInput, p)
+	}
+	if p.Bit(0) == 0 {
+		// Every number is a square mod 2.
+		return true, nil
+	}
+	return big.Jacobi(a, p) >= 0, nil
+}
+
+// Returns the smallest positive e such that a^e = 1 (mod n), where n
+// must be positive and coprime to a. As with EulerPhi, n (and p - 1
+// for each prime p dividing n) is factored the same way.
+func MultiplicativeOrder(a, n *big.Int) (*big.Int, error) {
+	if n.Sign() <= 0 {
+		return nil, fmt.Errorf(
+			"%w: n = %v must be positive", ErrBadInput, n)
+	}
+	var aModN, gcd big.Int
+	aModN.Mod(a, n)
+	gcd.GCD(nil, nil, &aModN, n)
+	if n.Cmp(big.NewInt(1)) != 0 && gcd.Cmp(big.NewInt(1)) != 0 {
+		return nil, fmt.Errorf(
+			"%w: a = %v and n = %v are not coprime",
+			ErrBadInput, a, n)
+	}
+	return calculateMultiplicativeOrder(&aModN, n), nil
+}
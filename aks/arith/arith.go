@@ -0,0 +1,44 @@
+// Package arith exports the number-theoretic primitives that the aks
+// package uses to pick and check its parameters: trial division,
+// Euler's totient function, and multiplicative orders. They're
+// implemented in the aks package, which uses them itself and so
+// can't import this package, and are re-exported here for callers
+// that only want the arithmetic.
+package arith
+
+import "github.com/akalin/aks-go/aks"
+import "math/big"
+
+// Wrapped by the errors returned for invalid arguments; the same
+// value as aks.ErrBadInput.
+var ErrBadInput = aks.ErrBadInput
+
+// A FactorFunction takes a prime and its multiplicity and returns
+// whether or not to continue trying to find more factors.
+type FactorFunction = aks.FactorFunction
+
+// Passes each prime factor of n and its multiplicity to factorFn, in
+// ascending order, until it returns false, finding them by trial
+// division. If upperBound is not nil, only factors up to it are
+// tried, and whatever is left of n after dividing them out (if not 1)
+// is passed last with multiplicity 1, even though it may not be
+// prime. n and upperBound (if not nil) must be non-negative; an error
+// wrapping ErrBadInput is returned otherwise. See aks.TrialDivide.
+func TrialDivide(
+	n *big.Int, factorFn FactorFunction, upperBound *big.Int) error {
+	return aks.TrialDivide(n, factorFn, upperBound)
+}
+
+// Returns Phi(n), the number of integers in [1, n] coprime to n,
+// which must be positive; an error wrapping ErrBadInput is returned
+// otherwise. See aks.EulerPhi for how n is factored.
+func EulerPhi(n *big.Int) (*big.Int, error) {
+	return aks.EulerPhi(n)
+}
+
+// Returns the smallest positive e such that a^e = 1 (mod n), where n
+// must be positive and coprime to a; an error wrapping ErrBadInput is
+// returned otherwise. See aks.MultiplicativeOrder.
+func MultiplicativeOrder(a, n *big.Int) (*big.Int, error) {
+	return aks.MultiplicativeOrder(a, n)
+}
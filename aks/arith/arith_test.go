@@ -0,0 +1,63 @@
+package arith
+
+import "errors"
+import "fmt"
+import "math/big"
+import "testing"
+
+// TrialDivide should pass each prime factor with its multiplicity,
+// and the unfactored rest last when given an upper bound.
+func TestTrialDivide(t *testing.T) {
+	cases := []struct {
+		n, upperBound int64
+		expected      string
+	}{
+		{360, -1, "[2^3 3^2 5^1]"},
+		{1, -1, "[]"},
+		{1001 * 1009, 20, "[7^1 11^1 13^1 1009^1]"},
+	}
+	for _, c := range cases {
+		var upperBound *big.Int
+		if c.upperBound >= 0 {
+			upperBound = big.NewInt(c.upperBound)
+		}
+		var factors []string
+		err := TrialDivide(big.NewInt(c.n), func(p, m *big.Int) bool {
+			factors = append(factors, fmt.Sprintf("%v^%v", p, m))
+			return true
+		}, upperBound)
+		if err != nil {
+			t.Fatal(c.n, err)
+		}
+		if str := fmt.Sprint(factors); str != c.expected {
+			t.Error(c.n, str, c.expected)
+		}
+	}
+
+	err := TrialDivide(big.NewInt(-5), func(p, m *big.Int) bool {
+		return true
+	}, nil)
+	if !errors.Is(err, ErrBadInput) {
+		t.Error(err)
+	}
+}
+
+// EulerPhi and MultiplicativeOrder should give the expected values,
+// and reject bad input.
+func TestEulerPhiAndMultiplicativeOrder(t *testing.T) {
+	if phi, err := EulerPhi(big.NewInt(36)); err != nil ||
+		phi.Int64() != 12 {
+		t.Error(phi, err)
+	}
+	if _, err := EulerPhi(big.NewInt(0)); !errors.Is(err, ErrBadInput) {
+		t.Error(err)
+	}
+	if o, err := MultiplicativeOrder(
+		big.NewInt(2), big.NewInt(7)); err != nil || o.Int64() != 3 {
+		t.Error(o, err)
+	}
+	if _, err := MultiplicativeOrder(
+		big.NewInt(2), big.NewInt(6)); !errors.Is(err, ErrBadInput) {
+		t.Error(err)
+	}
+}
@@ -1,5 +1,6 @@
 package aks
 
+import "errors"
 import "math/big"
 import "testing"
 
@@ -9,15 +10,21 @@ func expSmall(x, y int64) int64 {
 	return z.Int64()
 }
 
-func floorRootSmall(x, y int64) int64 {
-	return floorRoot(big.NewInt(x), big.NewInt(y)).Int64()
+// Returns floorRoot(x, y), and fails t if it says the root is exact
+// when it isn't, or vice versa.
+func floorRootSmall(t *testing.T, x, y int64) int64 {
+	root, exact := floorRoot(big.NewInt(x), big.NewInt(y))
+	if exact != (expSmall(root.Int64(), y) == x) {
+		t.Error(x, y, root, exact)
+	}
+	return root.Int64()
 }
 
 // floorRoot(x^y, y) should always yield x.
 func TestFloorRootExactPowers(t *testing.T) {
 	for i := int64(0); i < 16; i++ {
 		for j := int64(1); j < 16; j++ {
-			k := floorRootSmall(expSmall(i, j), j)
+			k := floorRootSmall(t, expSmall(i, j), j)
 			if k != i {
 				t.Error(i, j, k)
 			}
@@ -29,7 +36,7 @@ func TestFloorRootExactPowers(t *testing.T) {
 func TestFloorRootSlightlyOverExactPower(t *testing.T) {
 	for i := int64(1); i < 16; i++ {
 		for j := int64(2); j < 16; j++ {
-			k := floorRootSmall(expSmall(i, j)+1, j)
+			k := floorRootSmall(t, expSmall(i, j)+1, j)
 			if k != i {
 				t.Error(i, j, k)
 			}
@@ -41,7 +48,7 @@ func TestFloorRootSlightlyOverExactPower(t *testing.T) {
 func TestFloorRootSlightlyUnderExactPower(t *testing.T) {
 	for i := int64(1); i < 16; i++ {
 		for j := int64(2); j < 16; j++ {
-			k := floorRootSmall(expSmall(i+1, j)-1, j)
+			k := floorRootSmall(t, expSmall(i+1, j)-1, j)
 			if k != i {
 				t.Error(i, j, k)
 			}
@@ -55,7 +62,7 @@ func TestFloorRootMidwayBetweenExactPowers(t *testing.T) {
 	for i := int64(1); i < 16; i++ {
 		for j := int64(2); j < 16; j++ {
 			m := (expSmall(i, j) + expSmall(i+1, j)) / 2
-			k := floorRootSmall(m, j)
+			k := floorRootSmall(t, m, j)
 			if k != i {
 				t.Error(i, j, k)
 			}
@@ -63,6 +70,46 @@ func TestFloorRootMidwayBetweenExactPowers(t *testing.T) {
 	}
 }
 
+// sqrtExact, Sqrt, and IsSquare should agree with floorRoot, both
+// below and above 2^64.
+func TestSqrt(t *testing.T) {
+	var xs []*big.Int
+	for i := int64(0); i < 1000; i++ {
+		xs = append(xs, big.NewInt(i))
+	}
+	for _, k := range []uint{31, 32, 63, 64, 100} {
+		r := new(big.Int).Lsh(big.NewInt(1), k)
+		r.Add(r, big.NewInt(12345))
+		for _, d := range []int64{-1, 0, 1} {
+			var x big.Int
+			x.Mul(r, r)
+			xs = append(xs, x.Add(&x, big.NewInt(d)))
+		}
+	}
+	for _, x := range xs {
+		expected, expectedExact := floorRoot(x, big.NewInt(2))
+		root, exact := sqrtExact(x)
+		if root.Cmp(expected) != 0 || exact != expectedExact {
+			t.Error(x, root, exact, expected, expectedExact)
+		}
+		if root, err := Sqrt(x); err != nil ||
+			root.Cmp(expected) != 0 {
+			t.Error(x, root, err)
+		}
+		if isSquare, err := IsSquare(x); err != nil ||
+			isSquare != expectedExact {
+			t.Error(x, isSquare, err)
+		}
+	}
+
+	if _, err := Sqrt(big.NewInt(-1)); !errors.Is(err, ErrBadInput) {
+		t.Error(err)
+	}
+	if _, err := IsSquare(big.NewInt(-4)); !errors.Is(err, ErrBadInput) {
+		t.Error(err)
+	}
+}
+
 // Phi(p) should return p-1 for prime p.
 func TestCalculateEulerPhiPrime(t *testing.T) {
 	one := big.NewInt(1)
@@ -101,13 +148,13 @@ func makeFactors(int64Factors [][2]int64) [][2]*big.Int {
 	return factors
 }
 
-// Returns a factorFunction which compares its given factors to each
+// Returns a FactorFunction which compares its given factors to each
 // successive element in the given list of factors.
 func makeExpectingFactorFunction(
 	n int64,
 	int64Factors [][2]int64,
 	comparedFactors *int,
-	t *testing.T) factorFunction {
+	t *testing.T) FactorFunction {
 	expectedFactors := makeFactors(int64Factors)
 	*comparedFactors = 0
 	return func(p, m *big.Int) bool {
@@ -166,8 +213,66 @@ func TestTrialDivideLarge(t *testing.T) {
 	testTrialDivide(1961, [][2]int64{{37, 1}, {53, 1}}, t)
 }
 
+// trialDivide should pass a large prime cofactor as soon as it's
+// found to be prime, instead of dividing it by every prime up to the
+// square root of n, which would take many seconds here.
+func TestTrialDividePrimeCofactor(t *testing.T) {
+	// 2^61 - 1 is prime.
+	testTrialDivide(4*(1<<61-1), [][2]int64{{2, 2}, {1<<61 - 1, 1}}, t)
+	testTrialDivide(
+		4099*(1<<50+55), [][2]int64{{4099, 1}, {1<<50 + 55, 1}}, t)
+}
+
+// IsSmooth should agree with the largest prime factor found by
+// trialDivide, and handle n with large prime factors quickly.
+func TestIsSmooth(t *testing.T) {
+	for n := int64(1); n <= 2000; n++ {
+		largest := int64(0)
+		trialDivide(big.NewInt(n), func(p, m *big.Int) bool {
+			largest = p.Int64()
+			return true
+		}, nil)
+		for B := int64(0); B <= 50; B++ {
+			isSmooth, err := IsSmooth(big.NewInt(n), big.NewInt(B))
+			if err != nil {
+				t.Fatal(n, B, err)
+			}
+			if isSmooth != (largest <= B) {
+				t.Error(n, B, isSmooth)
+			}
+		}
+	}
+
+	// 2^61 - 1 is prime.
+	m61 := big.NewInt(1<<61 - 1)
+	var n big.Int
+	n.Mul(m61, big.NewInt(720720))
+	for _, c := range []struct {
+		n, B     *big.Int
+		isSmooth bool
+	}{
+		{&n, big.NewInt(1 << 40), false},
+		{&n, m61, true},
+		{new(big.Int).Lsh(big.NewInt(3), 200), big.NewInt(3), true},
+		{new(big.Int).Lsh(big.NewInt(3), 200), big.NewInt(2), false},
+	} {
+		isSmooth, err := IsSmooth(c.n, c.B)
+		if err != nil || isSmooth != c.isSmooth {
+			t.Error(c.n, c.B, isSmooth, err)
+		}
+	}
+
+	for _, c := range [][2]int64{{0, 10}, {-1, 10}, {10, -1}} {
+		if _, err := IsSmooth(
+			big.NewInt(c[0]), big.NewInt(c[1])); !errors.Is(
+			err, ErrBadInput) {
+			t.Error(c, err)
+		}
+	}
+}
+
 // Make sure trialDivide respects the return value of its
-// factorFunction.
+// FactorFunction.
 func TestTrialDividePartial(t *testing.T) {
 	var n int64 = 100
 	expectedFactors := [][2]int64{{2, 2}}
@@ -187,6 +292,93 @@ func TestTrialDividePartial(t *testing.T) {
 	}
 }
 
+// getPerfectPower() should find the largest exponent.
+func TestGetPerfectPower(t *testing.T) {
+	tests := []struct {
+		n, b, k int64
+	}{
+		{1, 1, 1},
+		{2, 2, 1},
+		{12, 12, 1},
+		{1 << 12, 2, 12},
+		{36, 6, 2},
+		{6 * 6 * 6 * 6 * 6 * 6, 6, 6},
+		{3 * 3 * 3 * 3 * 3, 3, 5},
+	}
+	for _, test := range tests {
+		b, k := getPerfectPower(big.NewInt(test.n))
+		if b.Int64() != test.b || k != test.k {
+			t.Error(test, b, k)
+		}
+	}
+}
+
+// PerfectPowerDecompose and IsPerfectPower should agree with
+// getPerfectPower, including for large n, and reject bad input.
+func TestPerfectPowerDecompose(t *testing.T) {
+	m61 := big.NewInt(1<<61 - 1)
+	var m61Cubed big.Int
+	m61Cubed.Exp(m61, big.NewInt(3), nil)
+	var m61CubedPlusOne big.Int
+	m61CubedPlusOne.Add(&m61Cubed, big.NewInt(1))
+	for _, c := range []struct {
+		n, b *big.Int
+		e    int64
+	}{
+		{big.NewInt(1), big.NewInt(1), 1},
+		{big.NewInt(1 << 62), big.NewInt(2), 62},
+		{big.NewInt(1000000), big.NewInt(10), 6},
+		{big.NewInt(1000001), big.NewInt(1000001), 1},
+		{&m61Cubed, m61, 3},
+		{&m61CubedPlusOne, &m61CubedPlusOne, 1},
+	} {
+		b, e, err := PerfectPowerDecompose(c.n)
+		if err != nil || b.Cmp(c.b) != 0 || e != c.e {
+			t.Error(c.n, b, e, err)
+		}
+		isPerfectPower, err := IsPerfectPower(c.n)
+		if err != nil || isPerfectPower != (c.e > 1) {
+			t.Error(c.n, isPerfectPower, err)
+		}
+	}
+
+	for _, n := range []int64{0, -8} {
+		if _, _, err := PerfectPowerDecompose(
+			big.NewInt(n)); !errors.Is(err, ErrBadInput) {
+			t.Error(n, err)
+		}
+		if _, err := IsPerfectPower(big.NewInt(n)); !errors.Is(
+			err, ErrBadInput) {
+			t.Error(n, err)
+		}
+	}
+}
+
+// TrialDivide() should pass the unfactored part of n last when given
+// an upper bound, and reject negative arguments.
+func TestTrialDivide(t *testing.T) {
+	// 2^2 * 37 * 53.
+	n := int64(7844)
+	comparedFactors := 0
+	err := TrialDivide(big.NewInt(n), makeExpectingFactorFunction(
+		n, [][2]int64{{2, 2}, {1961, 1}}, &comparedFactors, t),
+		big.NewInt(36))
+	if err != nil || comparedFactors != 2 {
+		t.Error(err, comparedFactors)
+	}
+
+	for _, args := range [][2]int64{{-1, 10}, {10, -1}} {
+		err := TrialDivide(big.NewInt(args[0]),
+			func(p, m *big.Int) bool {
+				t.Error(args, p, m)
+				return true
+			}, big.NewInt(args[1]))
+		if !errors.Is(err, ErrBadInput) {
+			t.Error(args, err)
+		}
+	}
+}
+
 func calculateMultiplicativeOrderPrimePowerSmall(a, p, k int64) int64 {
 	return calculateMultiplicativeOrderPrimePower(
 		big.NewInt(a), big.NewInt(p), big.NewInt(k)).Int64()
@@ -228,3 +420,232 @@ func TestCalculateEulerPhi(t *testing.T) {
 		t.Error(phi)
 	}
 }
+
+// EulerPhi() should match calculateEulerPhi() and reject
+// non-positive numbers.
+func TestEulerPhi(t *testing.T) {
+	phi, err := EulerPhi(big.NewInt(3888))
+	if err != nil || phi.Cmp(big.NewInt(1296)) != 0 {
+		t.Error(phi, err)
+	}
+
+	phi, err = EulerPhi(big.NewInt(1))
+	if err != nil || phi.Cmp(big.NewInt(1)) != 0 {
+		t.Error(phi, err)
+	}
+
+	for _, n := range []int64{0, -5} {
+		phi, err = EulerPhi(big.NewInt(n))
+		if !errors.Is(err, ErrBadInput) {
+			t.Error(n, phi, err)
+		}
+	}
+}
+
+// calculateMultiplicativeOrderBounds() should return bounds
+// containing the order, which are exact for complete factorizations
+// or if the order is coprime to the cofactor.
+func TestCalculateMultiplicativeOrderBounds(t *testing.T) {
+	// 1000003 - 1 = 2 * 3 * 166667, and 166667 is prime.
+	p := big.NewInt(1000003)
+	pMinusOne := big.NewInt(1000002)
+	complete := calculatePartialFactorization(pMinusOne, big.NewInt(1000))
+	partial := &Factorization{
+		N: pMinusOne,
+		Factors: []PrimePower{
+			{big.NewInt(2), big.NewInt(1)},
+			{big.NewInt(3), big.NewInt(1)},
+		},
+		Cofactor: big.NewInt(166667),
+	}
+	for a := int64(2); a < 50; a++ {
+		bigA := big.NewInt(a)
+		o := calculateMultiplicativeOrder(bigA, p)
+		lower, upper := calculateMultiplicativeOrderBounds(
+			bigA, p, complete, big.NewInt(1000))
+		if lower.Cmp(o) != 0 || upper.Cmp(o) != 0 {
+			t.Error(a, o, lower, upper)
+		}
+
+		lower, upper = calculateMultiplicativeOrderBounds(
+			bigA, p, partial, big.NewInt(1000))
+		if lower.Cmp(o) > 0 || upper.Cmp(o) < 0 {
+			t.Error(a, o, lower, upper)
+		}
+		var m big.Int
+		if m.Mod(o, big.NewInt(166667)).Sign() != 0 &&
+			(lower.Cmp(o) != 0 || upper.Cmp(o) != 0) {
+			t.Error(a, o, lower, upper)
+		}
+	}
+}
+
+// isMultiplicativeOrderAbove() should agree with the exact order,
+// even when r - 1 is only partly factored.
+func TestIsMultiplicativeOrderAbove(t *testing.T) {
+	defer func(bound *big.Int) {
+		orderTrialDivisionBound = bound
+	}(orderTrialDivisionBound)
+	orderTrialDivisionBound = big.NewInt(5)
+
+	n := big.NewInt(1000003)
+	for r := int64(2); r < 3000; r++ {
+		bigR := big.NewInt(r)
+		var gcd big.Int
+		if gcd.GCD(nil, nil, n, bigR).Int64() != 1 {
+			continue
+		}
+		o := calculateMultiplicativeOrder(n, bigR)
+		for _, minOrder := range []int64{10, 100, 400} {
+			expected := o.Int64() > minOrder
+			if isMultiplicativeOrderAbove(
+				n, bigR, big.NewInt(minOrder)) != expected {
+				t.Error(r, minOrder, o)
+			}
+		}
+	}
+}
+
+// Mobius(), NumDivisors(), and SumDivisors() should match their
+// definitions and reject non-positive numbers.
+func TestMobiusAndDivisorFunctions(t *testing.T) {
+	for n := int64(1); n <= 300; n++ {
+		// Compute mu(n) from the definition by counting prime
+		// factors with multiplicity and looking for squares.
+		expectedMu := 1
+		for m, p := n, int64(2); m > 1; p++ {
+			for m%p == 0 {
+				m /= p
+				expectedMu = -expectedMu
+				if m%p == 0 {
+					expectedMu = 0
+					m = 1
+				}
+			}
+		}
+		var expectedCount, expectedSum int64
+		for d := int64(1); d <= n; d++ {
+			if n%d == 0 {
+				expectedCount++
+				expectedSum += d
+			}
+		}
+
+		mu, err := Mobius(big.NewInt(n))
+		if err != nil || mu != expectedMu {
+			t.Error(n, mu, expectedMu, err)
+		}
+		count, err := NumDivisors(big.NewInt(n))
+		if err != nil || count.Int64() != expectedCount {
+			t.Error(n, count, expectedCount, err)
+		}
+		sum, err := SumDivisors(big.NewInt(n))
+		if err != nil || sum.Int64() != expectedSum {
+			t.Error(n, sum, expectedSum, err)
+		}
+	}
+
+	for _, n := range []int64{0, -5} {
+		if _, err := Mobius(big.NewInt(n)); !errors.Is(err, ErrBadInput) {
+			t.Error(n, err)
+		}
+		if _, err := NumDivisors(big.NewInt(n)); !errors.Is(
+			err, ErrBadInput) {
+			t.Error(n, err)
+		}
+		if _, err := SumDivisors(big.NewInt(n)); !errors.Is(
+			err, ErrBadInput) {
+			t.Error(n, err)
+		}
+	}
+}
+
+// Jacobi() should be multiplicative in n, match Euler's criterion for
+// primes, and reject even or non-positive n.
+func TestJacobi(t *testing.T) {
+	for _, p := range []int64{3, 5, 7, 11, 13, 97} {
+		for a := int64(-p); a <= 2*p; a++ {
+			// Euler's criterion: a^((p-1)/2) = (a/p) (mod p).
+			var e big.Int
+			e.Exp(big.NewInt(a+p), big.NewInt((p-1)/2), big.NewInt(p))
+			expected := int(e.Int64())
+			if expected == int(p-1) {
+				expected = -1
+			}
+			j, err := Jacobi(big.NewInt(a), big.NewInt(p))
+			if err != nil || j != expected {
+				t.Error(a, p, j, expected, err)
+			}
+		}
+	}
+
+	// (a/15) = (a/3)(a/5).
+	for a := int64(0); a < 15; a++ {
+		j, err := Jacobi(big.NewInt(a), big.NewInt(15))
+		j3, _ := Jacobi(big.NewInt(a), big.NewInt(3))
+		j5, _ := Jacobi(big.NewInt(a), big.NewInt(5))
+		if err != nil || j != j3*j5 {
+			t.Error(a, j, j3, j5, err)
+		}
+	}
+
+	for _, n := range []int64{-3, 0, 2, 10} {
+		if j, err := Jacobi(big.NewInt(1), big.NewInt(n)); !errors.Is(
+			err, ErrBadInput) {
+			t.Error(n, j, err)
+		}
+	}
+}
+
+// IsQuadraticResidue() should match squaring everything mod p, and
+// reject non-primes.
+func TestIsQuadraticResidue(t *testing.T) {
+	for _, p := range []int64{2, 3, 5, 7, 11, 13, 97} {
+		isSquare := make(map[int64]bool)
+		for x := int64(0); x < p; x++ {
+			isSquare[x*x%p] = true
+		}
+		for a := int64(0); a < 2*p; a++ {
+			r, err := IsQuadraticResidue(big.NewInt(a), big.NewInt(p))
+			if err != nil || r != isSquare[a%p] {
+				t.Error(a, p, r, err)
+			}
+		}
+	}
+
+	for _, p := range []int64{-3, 0, 1, 9, 15} {
+		if r, err := IsQuadraticResidue(
+			big.NewInt(1), big.NewInt(p)); !errors.Is(err, ErrBadInput) {
+			t.Error(p, r, err)
+		}
+	}
+}
+
+// MultiplicativeOrder() should reduce a modulo n and reject a and n
+// that aren't coprime.
+func TestMultiplicativeOrder(t *testing.T) {
+	tests := []struct {
+		a, n, order int64
+	}{
+		{3, 25600, 1280},
+		{3 + 25600, 25600, 1280},
+		// -1 = 6 (mod 7), which has order 2.
+		{-1, 7, 2},
+		{5, 1, 1},
+	}
+	for _, test := range tests {
+		o, err := MultiplicativeOrder(
+			big.NewInt(test.a), big.NewInt(test.n))
+		if err != nil || o.Cmp(big.NewInt(test.order)) != 0 {
+			t.Error(test, o, err)
+		}
+	}
+
+	for _, args := range [][2]int64{{6, 9}, {0, 7}, {2, 0}} {
+		o, err := MultiplicativeOrder(
+			big.NewInt(args[0]), big.NewInt(args[1]))
+		if !errors.Is(err, ErrBadInput) {
+			t.Error(args, o, err)
+		}
+	}
+}
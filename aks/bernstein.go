@@ -0,0 +1,184 @@
+package aks
+
+import "fmt"
+import "math"
+import "math/big"
+
+// Returns a prime r and an M such that, for some prime q dividing r -
+// 1 with n^((r - 1)/q) mod r not 0 or 1, binom(q + s - 1, s) >=
+// n^(2*floor(sqrt(r))), where s = M - 1. By Bernstein's version of the
+// AKS theorem (as presented in Granville's "It is easy to determine
+// whether a given integer is prime"), if n has no factors less than M
+// and no number in [1, M) is an AKS witness of n with parameter r,
+// then n is a prime power. (As with the classic variant, RunAKS
+// doesn't rule out perfect powers separately.)
+//
+// Of the prime r < 2*ceil(lg(n))^2 that work, the one minimizing the
+// estimated cost r*s of the witness search is returned. This r is
+// about half the one from CalculateAKSModulus, so each AKS witness
+// takes about half as long to test and half as much memory, but s is
+// usually enough larger than the classic M that the whole search
+// takes longer. Falls back to CalculateAKSModulus and
+// CalculateAKSUpperBound if no r works. n must be >= 2.
+func CalculateBernsteinAKSParams(n *big.Int) (r, M *big.Int, err error) {
+	if n.Cmp(big.NewInt(2)) < 0 {
+		return nil, nil, fmt.Errorf(
+			"%w: n = %v must be >= 2", ErrBadInput, n)
+	}
+
+	ceilLgN := uint64(n.BitLen())
+	primes := getPrimesIn(3, 2*ceilLgN*ceilLgN)
+	// Work with floating-point lg(n) only to pick r; s is then
+	// calculated exactly.
+	lgN := lgFloat(n)
+	bestR, bestQ, bestS := uint64(0), uint64(0), uint64(0)
+	bestCost := math.Inf(1)
+	for _, p := range primes {
+		q := getBernsteinQ(n, p, primes)
+		if q == 0 {
+			continue
+		}
+		s := estimateBernsteinS(q, 2*float64(floorSqrt(p))*lgN)
+		if s == 0 {
+			continue
+		}
+		if cost := float64(p) * float64(s); cost < bestCost {
+			bestR, bestQ, bestS, bestCost = p, q, s, cost
+		}
+	}
+	if bestR == 0 {
+		r, err = CalculateAKSModulus(n)
+		if err != nil {
+			return nil, nil, err
+		}
+		M, err = CalculateAKSUpperBound(n, r)
+		if err != nil {
+			return nil, nil, err
+		}
+		return r, M, nil
+	}
+
+	var bound big.Int
+	bound.Exp(n, big.NewInt(int64(2*floorSqrt(bestR))), nil)
+	s := calculateBernsteinS(bestQ, bestS, &bound)
+	r = new(big.Int).SetUint64(bestR)
+	M = new(big.Int).SetUint64(s)
+	return r, M.Add(M, big.NewInt(1)), nil
+}
+
+// Returns the largest prime q dividing r - 1 such that n^((r - 1)/q)
+// mod r isn't 0 or 1, or 0 if there is none. r must be an odd prime,
+// and primes must hold the odd primes up to at least sqrt(r), in
+// ascending order.
+func getBernsteinQ(n *big.Int, r uint64, primes []uint64) uint64 {
+	// Find the distinct prime factors of r - 1, which is even.
+	m := r - 1
+	for m%2 == 0 {
+		m /= 2
+	}
+	factors := []uint64{2}
+	for _, p := range primes {
+		if p*p > m {
+			break
+		}
+		if m%p == 0 {
+			factors = append(factors, p)
+			for m%p == 0 {
+				m /= p
+			}
+		}
+	}
+	if m > 1 {
+		factors = append(factors, m)
+	}
+
+	bigR := new(big.Int).SetUint64(r)
+	var nModR, x big.Int
+	nModR.Mod(n, bigR)
+	for i := len(factors) - 1; i >= 0; i-- {
+		q := factors[i]
+		x.Exp(&nModR, new(big.Int).SetUint64((r-1)/q), bigR)
+		if x.BitLen() > 1 {
+			return q
+		}
+	}
+	return 0
+}
+
+// The largest s that estimateBernsteinS returns, which is the largest
+// float64 that can hold every smaller integer exactly.
+const maxBernsteinSEstimate = 1 << 53
+
+// Returns an estimate, computed with floating point, of the least s >=
+// 1 such that lg(binom(q + s - 1, s)) >= bits, or 0 if it is more than
+// maxBernsteinSEstimate.
+func estimateBernsteinS(q uint64, bits float64) uint64 {
+	lgBinom := func(s uint64) float64 {
+		a, _ := math.Lgamma(float64(q + s))
+		b, _ := math.Lgamma(float64(s + 1))
+		c, _ := math.Lgamma(float64(q))
+		return (a - b - c) / math.Ln2
+	}
+	// lg(binom(q + s - 1, s)) increases with s, so double s until
+	// it's large enough and then binary search.
+	hi := uint64(1)
+	for lgBinom(hi) < bits {
+		if hi >= maxBernsteinSEstimate {
+			return 0
+		}
+		hi *= 2
+	}
+	lo := hi/2 + 1
+	for lo < hi {
+		mid := lo + (hi-lo)/2
+		if lgBinom(mid) >= bits {
+			hi = mid
+		} else {
+			lo = mid + 1
+		}
+	}
+	return hi
+}
+
+// Returns the least s >= 1 such that binom(q + s - 1, s) >= bound,
+// starting from the estimate s0 >= 1.
+func calculateBernsteinS(q, s0 uint64, bound *big.Int) uint64 {
+	s := s0
+	var b big.Int
+	b.Binomial(int64(q+s-1), int64(s))
+	if b.Cmp(bound) >= 0 {
+		// binom(q + s - 2, s - 1) = binom(q + s - 1, s) * s /
+		// (q + s - 1).
+		for s > 1 {
+			var prev big.Int
+			prev.Mul(&b, new(big.Int).SetUint64(s))
+			prev.Quo(&prev, new(big.Int).SetUint64(q+s-1))
+			if prev.Cmp(bound) < 0 {
+				break
+			}
+			b.Set(&prev)
+			s--
+		}
+		return s
+	}
+	// binom(q + s, s + 1) = binom(q + s - 1, s) * (q + s) / (s + 1).
+	for b.Cmp(bound) < 0 {
+		b.Mul(&b, new(big.Int).SetUint64(q+s))
+		b.Quo(&b, new(big.Int).SetUint64(s+1))
+		s++
+	}
+	return s
+}
+
+// Returns lg(n) for n > 0 as a float64, even if n is too large to be
+// one.
+func lgFloat(n *big.Int) float64 {
+	shift := 0
+	if b := n.BitLen(); b > 64 {
+		shift = b - 64
+	}
+	var top big.Int
+	top.Rsh(n, uint(shift))
+	f, _ := new(big.Float).SetInt(&top).Float64()
+	return math.Log2(f) + float64(shift)
+}
@@ -0,0 +1,126 @@
+package aks
+
+import "context"
+import "errors"
+import "math/big"
+import "testing"
+
+// The Bernstein AKS parameters should satisfy the conditions of
+// Bernstein's theorem, with the least s for the chosen r.
+func TestCalculateBernsteinAKSParams(t *testing.T) {
+	for _, s := range []string{
+		"2", "31", "65537", "1000003", "2993374621",
+		"18446744073709551629",
+	} {
+		n, _ := new(big.Int).SetString(s, 10)
+		r, M, err := CalculateBernsteinAKSParams(n)
+		if err != nil {
+			t.Fatal(n, err)
+		}
+		if !r.ProbablyPrime(0) {
+			t.Fatal(n, r)
+		}
+		q := getBernsteinQ(n, r.Uint64(), getPrimesIn(3, r.Uint64()))
+		if q == 0 {
+			t.Fatal(n, r)
+		}
+
+		var bound big.Int
+		bound.Exp(n, big.NewInt(int64(2*floorSqrt(r.Uint64()))), nil)
+		sBig := new(big.Int).Sub(M, big.NewInt(1))
+		s := sBig.Int64()
+		var b, prev big.Int
+		b.Binomial(int64(q)+s-1, s)
+		prev.Binomial(int64(q)+s-2, s-1)
+		if b.Cmp(&bound) < 0 || (s > 1 && prev.Cmp(&bound) >= 0) {
+			t.Error(n, r, q, s)
+		}
+	}
+
+	if _, _, err := CalculateBernsteinAKSParams(
+		big.NewInt(1)); !errors.Is(err, ErrBadInput) {
+		t.Error(err)
+	}
+}
+
+// getBernsteinQ should skip prime factors q of r - 1 for which
+// n^((r - 1)/q) mod r is 0 or 1.
+func TestGetBernsteinQ(t *testing.T) {
+	primes := getPrimesIn(3, 100)
+	tests := []struct {
+		n, r, q uint64
+	}{
+		// 2 has order 5 mod 31.
+		{2, 31, 5},
+		// 5 has order 3 mod 31, so 5^(30/5) = 1 mod 31.
+		{5, 31, 3},
+		// 32 has order 1 mod 31.
+		{32, 31, 0},
+		// 31 divides n.
+		{62, 31, 0},
+		// 2^((11 - 1)/5) = 4 mod 11.
+		{2, 11, 5},
+	}
+	for _, test := range tests {
+		n := new(big.Int).SetUint64(test.n)
+		if q := getBernsteinQ(n, test.r, primes); q != test.q {
+			t.Error(test.n, test.r, q, test.q)
+		}
+	}
+}
+
+// calculateBernsteinS should find the least s no matter which side
+// of it the estimate is on.
+func TestCalculateBernsteinS(t *testing.T) {
+	q := uint64(101)
+	bound := new(big.Int).Lsh(big.NewInt(1), 300)
+	estimate := estimateBernsteinS(q, 300)
+	expected := calculateBernsteinS(q, 1, bound)
+	if estimate == 0 || estimate > expected+1 || estimate+1 < expected {
+		t.Error(estimate, expected)
+	}
+	for _, s0 := range []uint64{1, expected - 1, expected, 10 * expected} {
+		if s := calculateBernsteinS(q, s0, bound); s != expected {
+			t.Error(s0, s, expected)
+		}
+	}
+
+	if s := estimateBernsteinS(1, 1e300); s != 0 {
+		t.Error(s)
+	}
+}
+
+// RunAKS should get the same verdicts with the Bernstein variant as
+// with the classic one.
+func TestRunAKSBernstein(t *testing.T) {
+	for _, s := range []string{
+		"31", "65537", "65539", "16777259", "2993374621",
+	} {
+		n, _ := new(big.Int).SetString(s, 10)
+		classic, err := RunAKS(context.Background(), n, nil)
+		if err != nil {
+			t.Fatal(n, err)
+		}
+		result, err := RunAKS(context.Background(), n, &AKSOptions{
+			Variant: AKSVariantBernstein,
+		})
+		if err != nil {
+			t.Fatal(n, err)
+		}
+		r, M, err := CalculateBernsteinAKSParams(n)
+		if err != nil {
+			t.Fatal(n, err)
+		}
+		if result.Verdict != classic.Verdict ||
+			result.R.Cmp(r) != 0 || result.M.Cmp(M) != 0 {
+			t.Error(n, result.Verdict, classic.Verdict, result.R,
+				result.M)
+		}
+	}
+
+	_, err := RunAKS(context.Background(), big.NewInt(65537),
+		&AKSOptions{Variant: AKSVariantConjecture + 1})
+	if !errors.Is(err, ErrBadInput) {
+		t.Error(err)
+	}
+}
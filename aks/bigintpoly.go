@@ -1,7 +1,10 @@
 package aks
 
+import "encoding/json"
 import "fmt"
 import "math/big"
+import "math/bits"
+import "runtime"
 
 // A bigIntPoly represents a polynomial with big.Int coefficients mod
 // some (N, X^R - 1).
@@ -23,29 +26,177 @@ type bigIntPoly struct {
 	// bytes for the leading coefficient (if any) is guaranteed to
 	// be zeroed out.
 	phi big.Int
+	// Reduces coefficients mod N. Shared by all polynomials
+	// made by the same bigIntPolyRing.
+	reducer *barrettReducer
+	// Used instead of reducer during Pow if N is odd, and nil
+	// otherwise. Also shared.
+	montgomery *montgomeryReducer
+}
+
+// A coefficientReducer reduces the coefficients of the product of
+// two bigIntPolys mod N.
+type coefficientReducer interface {
+	// Sets c, a coefficient of a product, to its reduced value.
+	// q and qN are used as scratch space, and are reallocated
+	// unless they have capacity for twice as many words as a
+	// coefficient.
+	reduce(c, q, qN *big.Int)
+}
+
+// Holds what's needed to reduce numbers less than 2^t mod N with
+// Barrett reduction, i.e. with two multiplications and a subtraction
+// instead of a division. Never modified after it's made, so it may
+// be shared between goroutines.
+type barrettReducer struct {
+	N big.Int
+	t uint
+	// floor(2^t / N).
+	mu big.Int
+}
+
+// Makes a barrettReducer for numbers less than 2^t mod N.
+func newBarrettReducer(N big.Int, t uint) *barrettReducer {
+	b := &barrettReducer{N: N, t: t}
+	b.mu.Lsh(big.NewInt(1), t)
+	b.mu.Quo(&b.mu, &N)
+	return b
+}
+
+// coefficientReducer implementation. Sets c, which must be less than
+// 2^b.t, to c mod b.N.
+func (b *barrettReducer) reduce(c, q, qN *big.Int) {
+	if c.Cmp(&b.N) < 0 {
+		return
+	}
+	// Since mu > 2^t/N - 1, q = floor(c*mu/2^t) > c/N - 2, and
+	// so q is either floor(c/N) or one less.
+	q.Mul(c, &b.mu)
+	q.Rsh(q, b.t)
+	qN.Mul(q, &b.N)
+	c.Sub(c, qN)
+	if c.Cmp(&b.N) >= 0 {
+		c.Sub(c, &b.N)
+	}
+}
+
+// Holds what's needed to reduce the coefficients of products of
+// bigIntPolys mod (N, X^R - 1) with Montgomery reduction, for odd N.
+// Coefficients must then be in Montgomery form, i.e. each
+// coefficient x is represented by x*2^s mod N, where 2^s > R*N.
+//
+// Unlike Barrett reduction, which multiplies by numbers about as
+// large as R*N^2, Montgomery reduction only multiplies by numbers
+// about as large as R*N. Never modified after it's made, so it may
+// be shared between goroutines.
+type montgomeryReducer struct {
+	N big.Int
+	s uint
+	// -1/N mod 2^s.
+	nInv big.Int
+}
+
+// Makes a montgomeryReducer for bigIntPolys mod (N, X^R - 1). N must
+// be odd.
+func newMontgomeryReducer(N, R big.Int) *montgomeryReducer {
+	var rN big.Int
+	rN.Mul(&R, &N)
+	m := &montgomeryReducer{N: N, s: uint(len(rN.Bits()) * bits.UintSize)}
+	var twoToS big.Int
+	twoToS.Lsh(big.NewInt(1), m.s)
+	nInv, err := InvMod(&N, &twoToS)
+	if err != nil {
+		// Only if N is even, which callers rule out.
+		panic(err)
+	}
+	m.nInv.Sub(&twoToS, nInv)
+	return m
+}
+
+// coefficientReducer implementation. Sets c, which must be less than
+// N*2^s, to c/2^s mod N, which takes a product of coefficients in
+// Montgomery form to the product's Montgomery form.
+func (m *montgomeryReducer) reduce(c, q, qN *big.Int) {
+	// Find q < 2^s such that c + q*N = 0 mod 2^s. Then (c +
+	// q*N)/2^s < 2N, and is c/2^s mod N.
+	sWords := int(m.s / bits.UintSize)
+	var lo big.Int
+	if cBits := c.Bits(); len(cBits) > sWords {
+		lo.SetBits(cBits[:sWords])
+	} else {
+		lo.SetBits(cBits)
+	}
+	q.Mul(&lo, &m.nInv)
+	if qBits := q.Bits(); len(qBits) > sWords {
+		q.SetBits(qBits[:sWords])
+	}
+	qN.Mul(q, &m.N)
+	// c + q*N may not fit into c's words, so compute it in qN.
+	qN.Add(qN, c)
+	qN.Rsh(qN, m.s)
+	if qN.Cmp(&m.N) >= 0 {
+		qN.Sub(qN, &m.N)
+	}
+	c.Set(qN)
+}
+
+// Sets c, which must be less than N, to its Montgomery form c*2^s
+// mod N, using q and qN as scratch space as for reduce. The inverse
+// of this is reduce.
+func (m *montgomeryReducer) toMontgomery(c, q, qN *big.Int) {
+	q.Lsh(c, m.s)
+	qN.Mod(q, &m.N)
+	c.Set(qN)
 }
 
 // Only polynomials built with the same value of N and R may be used
 // together in one of the functions below.
 
-// Builds a new bigIntPoly representing the zero polynomial
-// mod (N, X^R - 1). R must fit into an int.
-func newBigIntPoly(N, R big.Int) *bigIntPoly {
+// Returns the number of big.Words needed to hold a coefficient of a
+// polynomial mod (N, X^R - 1) in calculations without overflowing.
+func calculateCoefficientWordCount(N, R big.Int) int {
 	// A coefficient can be up to R*(N - 1)^2 in intermediate
 	// calculations.
 	var maxCoefficient big.Int
 	maxCoefficient.Sub(&N, big.NewInt(1))
 	maxCoefficient.Mul(&maxCoefficient, &maxCoefficient)
 	maxCoefficient.Mul(&maxCoefficient, &R)
+	return len(maxCoefficient.Bits())
+}
+
+// Makes the reducers for the coefficients of bigIntPolys mod (N,
+// X^R - 1). The montgomeryReducer is nil if N is even.
+func newBigIntPolyReducers(N, R big.Int) (
+	*barrettReducer, *montgomeryReducer) {
+	k := calculateCoefficientWordCount(N, R)
+	reducer := newBarrettReducer(N, uint(k*bits.UintSize))
+	var montgomery *montgomeryReducer
+	if N.Bit(0) != 0 {
+		montgomery = newMontgomeryReducer(N, R)
+	}
+	return reducer, montgomery
+}
+
+// Builds a new bigIntPoly representing the zero polynomial
+// mod (N, X^R - 1). R must fit into an int.
+func newBigIntPoly(N, R big.Int) *bigIntPoly {
+	reducer, montgomery := newBigIntPolyReducers(N, R)
+	return newBigIntPolyWithReducers(R, reducer, montgomery)
+}
 
+// Like newBigIntPoly, but uses the given reducers, which must be made
+// by newBigIntPolyReducers with the same N and R.
+func newBigIntPolyWithReducers(
+	R big.Int, reducer *barrettReducer,
+	montgomery *montgomeryReducer) *bigIntPoly {
 	var phi big.Int
 	rInt := int(R.Int64())
-	k := len(maxCoefficient.Bits())
+	k := calculateCoefficientWordCount(reducer.N, R)
 	// Up to 2*R coefficients may be needed in intermediate
 	// calculations.
 	maxWordCount := 2 * rInt * k
 	phi.SetBits(make([]big.Word, maxWordCount))
-	return &bigIntPoly{rInt, k, phi}
+	return &bigIntPoly{rInt, k, phi, reducer, montgomery}
 }
 
 // Returns 1 + the degree of this polynomial, or 0 if the polynomial
@@ -117,17 +268,151 @@ func (p *bigIntPoly) Set(a, k, N big.Int) {
 	p.setCoefficientCount(kModR + 1)
 }
 
-// Returns whether p has the same coefficients as q.
-func (p *bigIntPoly) Eq(q *bigIntPoly) bool {
-	return p.phi.Cmp(&q.phi) == 0
+// Returns a copy of the ith coefficient of this polynomial, which is
+// zero if i is at least p.getCoefficientCount().
+func (p *bigIntPoly) coefficient(i int) *big.Int {
+	if i >= p.getCoefficientCount() {
+		return &big.Int{}
+	}
+	c := p.getCoefficient(i)
+	return new(big.Int).Set(&c)
+}
+
+// poly implementation.
+func (p *bigIntPoly) setCoefficient(i int, c *big.Int) {
+	coefficientCount := p.getCoefficientCount()
+	if i >= coefficientCount {
+		// Extend p with zero coefficients up to x^i. The unused
+		// bytes of the old leading coefficient are already
+		// zeroed out.
+		pBits := p.phi.Bits()[:(i+1)*p.k]
+		for j := coefficientCount * p.k; j < len(pBits); j++ {
+			pBits[j] = 0
+		}
+		coefficientCount = i + 1
+	}
+	ci := p.getCoefficient(i)
+	ci.Set(c)
+	p.commitCoefficient(ci)
+	// The leading coefficient may have changed length (or become
+	// zero), so let SetBits normalize phi.
+	p.setCoefficientCount(coefficientCount)
+}
+
+// poly implementation.
+func (p *bigIntPoly) copyFrom(q poly) {
+	qPoly := q.(*bigIntPoly)
+	p.checkModulus("copy", qPoly)
+	p.phi.Set(&qPoly.phi)
+	// Set only copies up to the leading word, so clear the unused
+	// bits of the leading coefficient.
+	pBits := p.phi.Bits()
+	if len(pBits)%p.k != 0 {
+		start := len(pBits)
+		end := start + p.k - start%p.k
+		unusedBits := pBits[start:end]
+		for i := 0; i < len(unusedBits); i++ {
+			unusedBits[i] = 0
+		}
+	}
+}
+
+// poly implementation.
+func (p *bigIntPoly) clone() poly {
+	c := *p
+	c.phi = big.Int{}
+	// Allocate as much as p has, which is at least as much as
+	// newBigIntPoly does.
+	c.phi.SetBits(make([]big.Word, cap(p.phi.Bits())))
+	c.copyFrom(p)
+	return &c
 }
 
-// Sets p to the product of p and q mod (N, X^R - 1). Assumes R >=
-// 2. tmp must not alias p or q.
+// poly implementation.
+func (p *bigIntPoly) Eq(q poly) bool {
+	qPoly := q.(*bigIntPoly)
+	p.checkModulus("compare", qPoly)
+	return p.phi.Cmp(&qPoly.phi) == 0
+}
+
+// Calls panicModulusMismatch if p and q aren't mod the same N and R.
+func (p *bigIntPoly) checkModulus(op string, q *bigIntPoly) {
+	// Polys made by the same ring share their reducer.
+	if q.reducer != p.reducer &&
+		(q.R != p.R || q.reducer.N.Cmp(&p.reducer.N) != 0) {
+		panicModulusMismatch(
+			op, &p.reducer.N, p.R, &q.reducer.N, q.R)
+	}
+}
+
+// poly implementation. Assumes R >= 2.
+func (p *bigIntPoly) Mul(q poly, N big.Int, tmp poly) {
+	qPoly := q.(*bigIntPoly)
+	p.checkModulus("multiply", qPoly)
+	p.mul(qPoly, N, tmp.(*bigIntPoly))
+}
+
+// Like Mul, but without the type assertions.
 func (p *bigIntPoly) mul(q *bigIntPoly, N big.Int, tmp *bigIntPoly) {
-	tmp.phi.Mul(&p.phi, &q.phi)
+	p.mulWith(q, tmp, p.reducer)
+}
+
+// Like mul, but reduces the coefficients of the product with r, so
+// that if r is p.montgomery, p and q must be in Montgomery form.
+func (p *bigIntPoly) mulWith(
+	q *bigIntPoly, tmp *bigIntPoly, r coefficientReducer) {
+	// big.Int.Mul (which parallelMul uses) uses a squaring
+	// algorithm, which takes about half the time of a general
+	// multiplication, when both of its arguments are the same
+	// big.Int, i.e. when q == p.
+	parallelMul(&tmp.phi, &p.phi, &q.phi)
 	p.phi, tmp.phi = tmp.phi, p.phi
+	p.reduce(tmp, r)
+}
 
+// Like mulWith, but only multiplies by the terms of q with the given
+// degrees, which must include all of q's non-zero coefficients (see
+// getSparseDegrees).
+func (p *bigIntPoly) mulSparseWith(
+	q *bigIntPoly, degrees []int, tmp *bigIntPoly,
+	r coefficientReducer) {
+	// Compute the coefficients of the product mod X^R - 1 into
+	// tmp, each as a sum of at most R products of coefficients,
+	// which fits into k words.
+	pCount := p.getCoefficientCount()
+	tmpBits := tmp.phi.Bits()[:p.R*p.k]
+	var c, product big.Int
+	for k := 0; k < p.R; k++ {
+		c.SetInt64(0)
+		for _, d := range degrees {
+			i := k - d
+			if i < 0 {
+				i += p.R
+			}
+			if i >= pCount {
+				continue
+			}
+			pi := p.getCoefficient(i)
+			qd := q.getCoefficient(d)
+			product.Mul(&pi, &qd)
+			c.Add(&c, &product)
+		}
+		ck := tmpBits[k*p.k : (k+1)*p.k]
+		n := copy(ck, c.Bits())
+		for j := n; j < len(ck); j++ {
+			ck[j] = 0
+		}
+	}
+	tmp.phi.SetBits(tmpBits)
+	p.phi, tmp.phi = tmp.phi, p.phi
+	// Every coefficient was fully written, so the leading one is
+	// already committed.
+	p.mapCoefficients(tmp, r.reduce)
+}
+
+// Reduces the product of two polynomials in p mod (N, X^R - 1) with
+// r. tmp must not alias p.
+func (p *bigIntPoly) reduce(tmp *bigIntPoly, r coefficientReducer) {
 	// Mod p by X^R - 1.
 	mid := p.R * p.k
 	pBits := p.phi.Bits()
@@ -150,77 +435,157 @@ func (p *bigIntPoly) mul(q *bigIntPoly, N big.Int, tmp *bigIntPoly) {
 		}
 	}
 	// Commit the leading coefficient before we access it.
-	oldCoefficientCount := p.getCoefficientCount()
-	if oldCoefficientCount > 0 {
-		p.commitCoefficient(p.getCoefficient(oldCoefficientCount - 1))
+	if coefficientCount := p.getCoefficientCount(); coefficientCount > 0 {
+		p.commitCoefficient(p.getCoefficient(coefficientCount - 1))
 	}
 
 	// Mod p by N.
-	newCoefficientCount := 0
-	tmp2 := tmp.getCoefficient(0)
-	tmp3 := tmp.getCoefficient(1)
-	for i := 0; i < oldCoefficientCount; i++ {
-		c := p.getCoefficient(i)
-		if c.Cmp(&N) >= 0 {
-			// Mod c by N. Use big.Int.QuoRem() instead of
-			// big.Int.Mod() since the latter allocates an
-			// extra big.Int.
-			tmp2.QuoRem(&c, &N, &tmp3)
-			c.Set(&tmp3)
+	p.mapCoefficients(tmp, r.reduce)
+}
+
+// The smallest number of words in a bigIntPoly's coefficients for
+// which mapCoefficients splits them across goroutines.
+const minParallelReductionWords = 1 << 12
+
+// Sets each coefficient c of p to f(c, q, qN), where q and qN are
+// scratch space for f using tmp's words, which must not alias p's.
+// If p is large enough, the coefficients are split evenly across
+// goroutines, each with its own scratch space, so f must only modify
+// c, q, and qN.
+func (p *bigIntPoly) mapCoefficients(
+	tmp *bigIntPoly, f func(c, q, qN *big.Int)) {
+	oldCoefficientCount := p.getCoefficientCount()
+	tmpBits := tmp.phi.Bits()
+	tmpBits = tmpBits[:cap(tmpBits)]
+	chunkCount := 1
+	parallel := oldCoefficientCount*p.k >= minParallelReductionWords
+	if parallel {
+		// tmp has room for at least R/2 chunks' scratch space.
+		chunkCount = runtime.GOMAXPROCS(0)
+		maxChunkCount := len(tmpBits) / (4 * p.k)
+		if chunkCount > maxChunkCount {
+			chunkCount = maxChunkCount
+		}
+		if chunkCount < 1 {
+			chunkCount = 1
+		}
+	}
+	// Each goroutine only touches the words of its own
+	// coefficients, and finds the count of coefficients up to
+	// its last non-zero one.
+	newCoefficientCounts := make([]int, chunkCount)
+	forEach(chunkCount, parallel, func(chunk int) {
+		var q, qN big.Int
+		if scratch := tmpBits[chunk*4*p.k:]; len(scratch) >= 4*p.k {
+			q.SetBits(scratch[0 : 0 : 2*p.k])
+			qN.SetBits(scratch[2*p.k : 2*p.k : 4*p.k])
+		}
+		start := oldCoefficientCount * chunk / chunkCount
+		end := oldCoefficientCount * (chunk + 1) / chunkCount
+		for i := start; i < end; i++ {
+			c := p.getCoefficient(i)
+			f(&c, &q, &qN)
 			p.commitCoefficient(c)
+			if c.Sign() != 0 {
+				newCoefficientCounts[chunk] = i + 1
+			}
 		}
-		if c.Sign() != 0 {
-			newCoefficientCount = i + 1
+	})
+	newCoefficientCount := 0
+	for _, count := range newCoefficientCounts {
+		if count > newCoefficientCount {
+			newCoefficientCount = count
 		}
 	}
 	p.setCoefficientCount(newCoefficientCount)
 }
 
-// Sets p to p^N mod (N, X^R - 1), where R is the size of p. tmp1 and
-// tmp2 must not alias each other or p.
-func (p *bigIntPoly) Pow(N big.Int, tmp1, tmp2 *bigIntPoly) {
-	tmp1.phi.Set(&p.phi)
+// poly implementation.
+func (p *bigIntPoly) Pow(N big.Int, tmp1, tmp2 poly) {
+	p.powCancelable(N, tmp1, tmp2, nil, nil)
+}
+
+// poly implementation. If N is odd, the coefficients are kept in
+// Montgomery form for the duration, so that they're reduced with
+// p.montgomery.
+func (p *bigIntPoly) powCancelable(
+	N big.Int, tmp1Poly, tmp2Poly poly,
+	done <-chan struct{}, onMul func()) bool {
+	tmp1 := tmp1Poly.(*bigIntPoly)
+	tmp2 := tmp2Poly.(*bigIntPoly)
+	var r coefficientReducer = p.reducer
+	if p.montgomery != nil {
+		r = p.montgomery
+		p.mapCoefficients(tmp1, p.montgomery.toMontgomery)
+	}
+	tmp1.copyFrom(p)
+	degrees := getSparseDegrees(p, p.R)
 
 	for i := N.BitLen() - 2; i >= 0; i-- {
-		tmp1.mul(tmp1, N, tmp2)
+		select {
+		case <-done:
+			if p.montgomery != nil {
+				p.mapCoefficients(tmp1, p.montgomery.reduce)
+			}
+			return false
+		default:
+		}
+		tmp1.mulWith(tmp1, tmp2, r)
+		if onMul != nil {
+			onMul()
+		}
 		if N.Bit(i) != 0 {
-			tmp1.mul(p, N, tmp2)
+			if degrees != nil {
+				tmp1.mulSparseWith(p, degrees, tmp2, r)
+			} else {
+				tmp1.mulWith(p, tmp2, r)
+			}
+			if onMul != nil {
+				onMul()
+			}
 		}
 	}
 
 	p.phi, tmp1.phi = tmp1.phi, p.phi
+	if p.montgomery != nil {
+		p.mapCoefficients(tmp1, p.montgomery.reduce)
+	}
+	return true
 }
 
 // fmt.Formatter implementation.
 func (p *bigIntPoly) Format(f fmt.State, c rune) {
-	if p.phi.Sign() == 0 {
-		fmt.Fprint(f, "0")
-		return
-	}
+	formatPoly(f, p.getCoefficientCount(), p.getCoefficient)
+}
 
-	// Formats coeff*x^deg.
-	formatNonZeroMonomial := func(
-		f fmt.State, c rune,
-		coeff big.Int, deg int) {
-		if coeff.Cmp(big.NewInt(1)) != 0 || deg == 0 {
-			fmt.Fprint(f, &coeff)
-		}
-		if deg != 0 {
-			fmt.Fprint(f, "x")
-			if deg > 1 {
-				fmt.Fprint(f, "^", deg)
-			}
-		}
-	}
+// gob.GobEncoder implementation.
+func (p *bigIntPoly) GobEncode() ([]byte, error) {
+	return marshalPoly(p, p.reducer.N, *big.NewInt(int64(p.R)), gobMarshal)
+}
 
-	i := p.getCoefficientCount() - 1
-	formatNonZeroMonomial(f, c, p.getCoefficient(i), i)
+// gob.GobDecoder implementation.
+func (p *bigIntPoly) GobDecode(data []byte) error {
+	return p.unmarshal(data, gobUnmarshal)
+}
 
-	for i--; i >= 0; i-- {
-		coeff := p.getCoefficient(i)
-		if coeff.Sign() != 0 {
-			fmt.Fprint(f, " + ")
-			formatNonZeroMonomial(f, c, coeff, i)
-		}
+// json.Marshaler implementation.
+func (p *bigIntPoly) MarshalJSON() ([]byte, error) {
+	return marshalPoly(p, p.reducer.N, *big.NewInt(int64(p.R)), json.Marshal)
+}
+
+// json.Unmarshaler implementation.
+func (p *bigIntPoly) UnmarshalJSON(data []byte) error {
+	return p.unmarshal(data, json.Unmarshal)
+}
+
+// Sets p to data, as marshaled by GobEncode or MarshalJSON (possibly
+// by another backend), with unmarshal.
+func (p *bigIntPoly) unmarshal(
+	data []byte, unmarshal func([]byte, interface{}) error) error {
+	q, err := unmarshalPoly(data, unmarshal, BigIntPolyBackend)
+	if err != nil {
+		return err
 	}
+	*p = *q.(*bigIntPoly)
+	return nil
 }
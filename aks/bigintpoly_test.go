@@ -2,6 +2,7 @@ package aks
 
 import "fmt"
 import "math/big"
+import "runtime"
 import "testing"
 
 const (
@@ -167,6 +168,34 @@ func TestBigIntPolyMul(t *testing.T) {
 	}
 }
 
+// Squaring, by multiplying with p aliased as q as powCancelable does,
+// should give the same result as multiplying by an equal polynomial.
+func TestBigIntPolySquare(t *testing.T) {
+	N := *big.NewInt(1000003)
+	R := *big.NewInt(53)
+
+	// Make p, q, and r all equal to the product of a few X^k + a.
+	p := newBigIntPoly(N, R)
+	q := newBigIntPoly(N, R)
+	r := newBigIntPoly(N, R)
+	s := newBigIntPoly(N, R)
+	tmp := newBigIntPoly(N, R)
+	fuzzBigIntPoly(tmp)
+	for _, x := range []*bigIntPoly{p, q, r} {
+		x.Set(*big.NewInt(1), *big.NewInt(1), N)
+		for i := int64(2); i < 10; i++ {
+			s.Set(*big.NewInt(i * 12345), *big.NewInt(i * 5), N)
+			x.mul(s, N, tmp)
+		}
+	}
+
+	p.mulWith(p, tmp, p.reducer)
+	q.mul(r, N, tmp)
+	if !p.Eq(q) {
+		t.Error(dumpBigIntPoly(p), dumpBigIntPoly(q))
+	}
+}
+
 // Multiplication should still work for large (multi-word) values of
 // N.
 func TestBigIntPolyMulLarge(t *testing.T) {
@@ -326,3 +355,142 @@ func TestBigIntPolyFormat(t *testing.T) {
 		t.Error(dumpBigIntPoly(p), str)
 	}
 }
+
+// powCancelable should give up and leave p unchanged if done is
+// closed.
+func TestBigIntPolyPowCancelable(t *testing.T) {
+	N := *big.NewInt(101)
+	R := *big.NewInt(53)
+
+	p := newBigIntPoly(N, R)
+	p.Set(*big.NewInt(2), *big.NewInt(1), N)
+	tmp1 := newBigIntPoly(N, R)
+	tmp2 := newBigIntPoly(N, R)
+	done := make(chan struct{})
+	close(done)
+	if p.powCancelable(N, tmp1, tmp2, done, nil) {
+		t.Error(dumpBigIntPoly(p))
+	}
+	if !bigIntPolyHasInt64Coefficients(p, []int64{2, 1}) {
+		t.Error(dumpBigIntPoly(p))
+	}
+}
+
+// A barrettReducer should reduce numbers less than 2^t mod N like
+// big.Int.Mod, including those just below multiples of N.
+func TestBarrettReducer(t *testing.T) {
+	var bigN big.Int
+	bigN.SetString("1000000000000000000000000000057", 10)
+	for _, N := range []*big.Int{big.NewInt(3), big.NewInt(101), &bigN} {
+		tBits := uint(2*N.BitLen() + 10)
+		b := newBarrettReducer(*N, tBits)
+		var max big.Int
+		max.Lsh(big.NewInt(1), tBits)
+		max.Sub(&max, big.NewInt(1))
+		var lastMultiple big.Int
+		lastMultiple.Sub(&max, new(big.Int).Mod(&max, N))
+		for _, x := range []*big.Int{
+			big.NewInt(0), big.NewInt(1), N,
+			new(big.Int).Sub(N, big.NewInt(1)),
+			new(big.Int).Mul(N, N),
+			new(big.Int).Sub(&lastMultiple, big.NewInt(1)),
+			&lastMultiple, &max,
+		} {
+			var c, q, qN, expected big.Int
+			c.Set(x)
+			b.reduce(&c, &q, &qN)
+			expected.Mod(x, N)
+			if c.Cmp(&expected) != 0 {
+				t.Error(N, x, &c, &expected)
+			}
+		}
+	}
+}
+
+// A montgomeryReducer should reduce products of numbers in
+// Montgomery form to the Montgomery form of the product mod N, and
+// converting to and from Montgomery form should round-trip.
+func TestMontgomeryReducer(t *testing.T) {
+	var bigN big.Int
+	bigN.SetString("1000000000000000000000000000057", 10)
+	R := *big.NewInt(53)
+	for _, N := range []*big.Int{big.NewInt(3), big.NewInt(101), &bigN} {
+		m := newMontgomeryReducer(*N, R)
+		var nMinus1 big.Int
+		nMinus1.Sub(N, big.NewInt(1))
+		for _, x := range []*big.Int{
+			big.NewInt(0), big.NewInt(1), big.NewInt(2), &nMinus1,
+		} {
+			var c, q, qN big.Int
+			c.Set(x)
+			m.toMontgomery(&c, &q, &qN)
+			// Square c as if it were the coefficient of
+			// a product of polynomials with R terms.
+			var product big.Int
+			product.Mul(&c, &c)
+			product.Mul(&product, &R)
+			m.reduce(&product, &q, &qN)
+			m.reduce(&product, &q, &qN)
+			var expected big.Int
+			expected.Mul(x, x)
+			expected.Mul(&expected, &R)
+			expected.Mod(&expected, N)
+			if product.Cmp(&expected) != 0 {
+				t.Error(N, x, &product, &expected)
+			}
+
+			m.reduce(&c, &q, &qN)
+			if c.Cmp(x) != 0 {
+				t.Error(N, x, &c)
+			}
+		}
+	}
+}
+
+// Pow should work for even N, which doesn't use Montgomery form.
+func TestBigIntPolyPowEvenN(t *testing.T) {
+	N := *big.NewInt(10)
+	R := *big.NewInt(5)
+	p := newBigIntPoly(N, R)
+	p.Set(*big.NewInt(3), *big.NewInt(1), N)
+	q := newBigIntPoly(N, R)
+	q.Set(*big.NewInt(3), *big.NewInt(1), N)
+	tmp1 := newBigIntPoly(N, R)
+	tmp2 := newBigIntPoly(N, R)
+	p.Pow(N, tmp1, tmp2)
+	x := newBigIntPoly(N, R)
+	x.Set(*big.NewInt(3), *big.NewInt(1), N)
+	for i := 1; i < 10; i++ {
+		q.mul(x, N, tmp1)
+	}
+	if !p.Eq(q) {
+		t.Error(dumpBigIntPoly(p), dumpBigIntPoly(q))
+	}
+}
+
+// Reducing large products across goroutines should give the same
+// result as reducing them serially.
+func TestBigIntPolyParallelReduction(t *testing.T) {
+	N := *getFirstPrimeWithDigits(100)
+	R := *big.NewInt(401)
+	defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(4))
+
+	p := newBigIntPoly(N, R)
+	tmp1 := newBigIntPoly(N, R)
+	tmp2 := newBigIntPoly(N, R)
+	p.Set(*big.NewInt(7), *big.NewInt(1), N)
+	p.Pow(*big.NewInt(1000003), tmp1, tmp2)
+	if p.R*p.k < minParallelReductionWords {
+		t.Fatal(p.R*p.k, minParallelReductionWords)
+	}
+
+	q := newBigIntPoly(N, R)
+	q.copyFrom(p)
+	p.mulWith(p, tmp1, p.reducer)
+
+	runtime.GOMAXPROCS(1)
+	q.mulWith(q, tmp1, q.reducer)
+	if !p.Eq(q) {
+		t.Error(dumpBigIntPoly(p), dumpBigIntPoly(q))
+	}
+}
@@ -0,0 +1,150 @@
+package aks
+
+import "fmt"
+import "math/big"
+
+// Returns whether n passes the Baillie-PSW test: a Miller-Rabin round
+// with base 2 followed by a strong Lucas probable prime test with
+// Selfridge's parameters. Every prime passes, and no composite that
+// passes is known (there are none below 2^64), so a false result is
+// a proof that n is composite which takes microseconds even for n
+// far too large for the AKS witness search. Returns an error wrapping
+// ErrBadInput if n < 0.
+func BailliePSW(n *big.Int) (bool, error) {
+	if n.Sign() < 0 {
+		return false, fmt.Errorf(
+			"%w: n = %v must be >= 0", ErrBadInput, n)
+	}
+	if n.IsUint64() {
+		return IsPrimeUint64(n.Uint64()), nil
+	}
+	if n.Bit(0) == 0 {
+		return false, nil
+	}
+	d, s := splitMillerRabinExponent(n)
+	if !isStrongProbablePrime(n, big.NewInt(2), d, s) {
+		return false, nil
+	}
+	return isStrongLucasProbablePrime(n), nil
+}
+
+// Returns whether n is a strong Lucas probable prime with the
+// parameters P = 1 and Q = (1 - D)/4, where D is the first of 5, -7,
+// 9, -11, ... with Jacobi symbol (D/n) = -1 (Selfridge's method A).
+// Every odd prime passes. Returns an error wrapping ErrBadInput if n
+// isn't odd and >= 3.
+func IsStrongLucasProbablePrime(n *big.Int) (bool, error) {
+	if n.Cmp(big.NewInt(3)) < 0 || n.Bit(0) == 0 {
+		return false, fmt.Errorf(
+			"%w: n = %v must be odd and >= 3", ErrBadInput, n)
+	}
+	return isStrongLucasProbablePrime(n), nil
+}
+
+// Like IsStrongLucasProbablePrime, but doesn't check its argument.
+func isStrongLucasProbablePrime(n *big.Int) bool {
+	D := findSelfridgeD(n)
+	if D == nil {
+		return false
+	}
+	// Q = (1 - D)/4, which is exact since D = 1 (mod 4).
+	Q := new(big.Int).Sub(big.NewInt(1), D)
+	Q.Rsh(Q, 2)
+	D.Mod(D, n)
+	Q.Mod(Q, n)
+
+	// Write n + 1 = d * 2^s with d odd.
+	d := new(big.Int).Add(n, big.NewInt(1))
+	s := d.TrailingZeroBits()
+	d.Rsh(d, s)
+
+	U, V, Qk := lucasSequence(big.NewInt(1), Q, D, d, n)
+	var t big.Int
+	if U.Sign() == 0 || V.Sign() == 0 {
+		return true
+	}
+	// Check V_(d 2^r) for 0 < r < s.
+	for r := uint(1); r < s; r++ {
+		V.Mul(V, V)
+		V.Sub(V, t.Lsh(Qk, 1))
+		V.Mod(V, n)
+		if V.Sign() == 0 {
+			return true
+		}
+		Qk.Mul(Qk, Qk)
+		Qk.Mod(Qk, n)
+	}
+	return false
+}
+
+// Returns the first D of 5, -7, 9, -11, ... with Jacobi symbol (D/n)
+// = -1 for odd n >= 3, or nil if n is found to be composite along the
+// way (including when n is a square, for which there is no such D).
+func findSelfridgeD(n *big.Int) *big.Int {
+	// No D works if n is a square, so the search below would
+	// never end.
+	if isSquare(n) {
+		return nil
+	}
+
+	D := big.NewInt(5)
+	var absD big.Int
+	for {
+		j := big.Jacobi(D, n)
+		if j == -1 {
+			return D
+		}
+		// D and n share a factor, which is proper unless it's
+		// n itself.
+		if j == 0 && absD.Abs(D).Cmp(n) != 0 {
+			return nil
+		}
+		if D.Sign() > 0 {
+			D.Add(D, big.NewInt(2))
+		} else {
+			D.Sub(D, big.NewInt(2))
+		}
+		D.Neg(D)
+	}
+}
+
+// Returns U_k, V_k, and Q^k mod n for the Lucas sequences with
+// parameters P and Q, whose discriminant D is P^2 - 4Q, for k >= 1
+// and odd n >= 3. P, Q, and D must be reduced mod n. Goes through the
+// bits of k from the top, using U_2k = U_k V_k, V_2k = V_k^2 - 2Q^k,
+// U_(k+1) = (P U_k + V_k)/2, and V_(k+1) = (D U_k + P V_k)/2.
+func lucasSequence(P, Q, D, k, n *big.Int) (U, V, Qk *big.Int) {
+	U = big.NewInt(1)
+	V = new(big.Int).Set(P)
+	Qk = new(big.Int).Set(Q)
+	var t big.Int
+	halve := func(x *big.Int) {
+		if x.Bit(0) != 0 {
+			x.Add(x, n)
+		}
+		x.Rsh(x, 1)
+	}
+	for i := k.BitLen() - 2; i >= 0; i-- {
+		U.Mul(U, V)
+		U.Mod(U, n)
+		V.Mul(V, V)
+		V.Sub(V, t.Lsh(Qk, 1))
+		V.Mod(V, n)
+		Qk.Mul(Qk, Qk)
+		Qk.Mod(Qk, n)
+		if k.Bit(i) != 0 {
+			t.Mul(D, U)
+			U.Mul(U, P)
+			U.Add(U, V)
+			U.Mod(U, n)
+			halve(U)
+			V.Mul(V, P)
+			V.Add(V, &t)
+			V.Mod(V, n)
+			halve(V)
+			Qk.Mul(Qk, Q)
+			Qk.Mod(Qk, n)
+		}
+	}
+	return U, V, Qk
+}
@@ -0,0 +1,85 @@
+package aks
+
+import "errors"
+import "math/big"
+import "math/rand"
+import "testing"
+
+// BailliePSW should agree with big.Int.ProbablyPrime, which also does
+// a Baillie-PSW test, both below and above 2^64.
+func TestBailliePSW(t *testing.T) {
+	for i := int64(0); i < 5000; i++ {
+		n := big.NewInt(i)
+		isPrime, err := BailliePSW(n)
+		if err != nil {
+			t.Fatal(n, err)
+		}
+		if isPrime != n.ProbablyPrime(0) {
+			t.Error(n, isPrime)
+		}
+	}
+
+	rnd := rand.New(rand.NewSource(1))
+	limit := new(big.Int).Lsh(big.NewInt(1), 100)
+	for i := 0; i < 2000; i++ {
+		n := new(big.Int).Rand(rnd, limit)
+		n.SetBit(n, 0, 1)
+		n.SetBit(n, 99, 1)
+		isPrime, err := BailliePSW(n)
+		if err != nil {
+			t.Fatal(n, err)
+		}
+		if isPrime != n.ProbablyPrime(0) {
+			t.Error(n, isPrime)
+		}
+	}
+
+	for _, c := range []struct {
+		s        string
+		expected bool
+	}{
+		{"170141183460469231731687303715884105727", true},
+		{"3317044064679887385961981", false},
+		{"318665857834031151167461", false},
+		{"340282366920938463463374607431768211457", false},
+		// The square of the largest prime below 2^64.
+		{"340282366920938461286658806734041124249", false},
+	} {
+		n, _ := new(big.Int).SetString(c.s, 10)
+		if isPrime, err := BailliePSW(n); isPrime != c.expected ||
+			err != nil {
+			t.Error(n, isPrime, c.expected, err)
+		}
+	}
+
+	if isPrime, err := BailliePSW(big.NewInt(-1)); !errors.Is(
+		err, ErrBadInput) {
+		t.Error(isPrime, err)
+	}
+}
+
+// IsStrongLucasProbablePrime should pass odd primes and the strong
+// Lucas pseudoprimes, and fail other odd composites.
+func TestIsStrongLucasProbablePrime(t *testing.T) {
+	pseudoprimes := map[int64]bool{
+		5459: true, 5777: true, 10877: true, 16109: true,
+		18971: true,
+	}
+	for n := int64(3); n < 20000; n += 2 {
+		actual, err := IsStrongLucasProbablePrime(big.NewInt(n))
+		if err != nil {
+			t.Fatal(n, err)
+		}
+		expected := big.NewInt(n).ProbablyPrime(0) || pseudoprimes[n]
+		if actual != expected {
+			t.Error(n, actual, expected)
+		}
+	}
+
+	for _, n := range []int64{-3, 1, 2, 10} {
+		actual, err := IsStrongLucasProbablePrime(big.NewInt(n))
+		if !errors.Is(err, ErrBadInput) {
+			t.Error(n, actual, err)
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package aks
+
+import "math/big"
+
+// The most baby steps calculateMultiplicativeOrderPrimePower lets
+// bsgsOrder take, which bounds both its time and its memory (a map
+// entry per step).
+const bsgsMaxSteps = 1 << 20
+
+// Returns the smallest e in [1, upperBound] such that a^e = 1 (mod
+// m), or nil if there is none, using Shanks' baby-step giant-step
+// algorithm, which takes about 2 sqrt(upperBound) multiplications
+// and sqrt(upperBound) memory. a and m must be coprime, m must be >=
+// 2, and upperBound must be positive.
+func bsgsOrder(a, m, upperBound *big.Int) *big.Int {
+	one := big.NewInt(1)
+	s, exact := sqrtExact(upperBound)
+	if !exact {
+		s.Add(s, one)
+	}
+	steps := s.Int64()
+
+	// Baby steps: remember a^j for j in [1, s], stopping early if
+	// one of them is 1.
+	var x big.Int
+	x.Mod(a, m)
+	baby := make(map[string]int64)
+	for j := int64(1); j <= steps; j++ {
+		if x.Cmp(one) == 0 {
+			return big.NewInt(j)
+		}
+		key := string(x.Bytes())
+		if _, ok := baby[key]; !ok {
+			baby[key] = j
+		}
+		x.Mul(&x, a)
+		x.Mod(&x, m)
+	}
+
+	// Giant steps: a^(is + j) = 1 if and only if a^j = a^(-is),
+	// so look for a^(-is) among the baby steps for i = 1, 2, ....
+	// Since no a^j is 1, the first match gives the smallest e.
+	var g, y big.Int
+	g.Exp(a, s, m)
+	g.ModInverse(&g, m)
+	y.Set(one)
+	e := new(big.Int)
+	for i := int64(1); i <= steps; i++ {
+		y.Mul(&y, &g)
+		y.Mod(&y, m)
+		if j, ok := baby[string(y.Bytes())]; ok {
+			e.Mul(big.NewInt(i), s)
+			e.Add(e, big.NewInt(j))
+			if e.Cmp(upperBound) > 0 {
+				return nil
+			}
+			return e
+		}
+	}
+	return nil
+}
@@ -0,0 +1,30 @@
+package aks
+
+import "math/big"
+import "testing"
+
+// bsgsOrder should match calculateMultiplicativeOrder when the order
+// is at most the upper bound, and return nil otherwise.
+func TestBSGSOrder(t *testing.T) {
+	for _, m := range []int64{2, 9, 101, 1000, 65537, 1000003} {
+		bigM := big.NewInt(m)
+		for _, a := range []int64{1, 2, 3, 7, 10, m - 1} {
+			bigA := big.NewInt(a)
+			var gcd big.Int
+			if gcd.GCD(nil, nil, bigA, bigM).Int64() != 1 {
+				continue
+			}
+			o := calculateMultiplicativeOrder(bigA, bigM)
+			for _, bound := range []int64{1, 2, 10, m} {
+				e := bsgsOrder(bigA, bigM, big.NewInt(bound))
+				if o.Int64() <= bound {
+					if e == nil || e.Cmp(o) != 0 {
+						t.Error(a, m, bound, e, o)
+					}
+				} else if e != nil {
+					t.Error(a, m, bound, e, o)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package aks
+
+import "crypto/sha256"
+import "encoding/hex"
+import "fmt"
+import "io"
+import "math/big"
+
+// A Certificate is a machine-readable record of a completed primality
+// test, suitable for archiving as a transcript of the run.
+type Certificate struct {
+	N       *big.Int
+	Verdict Verdict
+	Method  Method
+	// The AKS modulus and upper bound for N.
+	R, M *big.Int
+	// Trial division was done for factors less than
+	// TrialDivisionBound, and found Factor (if any; with
+	// MethodECM, Factor was found with ECM instead).
+	TrialDivisionBound *big.Int
+	Factor             *big.Int
+	// The range [Start, End) of numbers searched for AKS
+	// witnesses, and the witness found (if any).
+	Start, End *big.Int
+	Witness    *big.Int
+	// The number of AKS witness candidates tested.
+	TestedCount *big.Int
+	// The hex-encoded SHA-256 digest of the lines "<a> <outcome>\n",
+	// where <outcome> is "true" if a is an AKS witness and "false"
+	// otherwise, for every tested a in ascending order.
+	WitnessDigest string
+}
+
+// Builds a certificate for the given result, where completed is the
+// set of numbers found not to be AKS witnesses.
+func newCertificate(result *PrimalityResult, completed *rangeSet) *Certificate {
+	c := &Certificate{
+		N:                  result.N,
+		Verdict:            result.Verdict,
+		Method:             result.Method,
+		R:                  result.R,
+		M:                  result.M,
+		TrialDivisionBound: result.TrialDivisionBound,
+		Factor:             result.Factor,
+		Start:              result.Start,
+		End:                result.End,
+		Witness:            result.Witness,
+		TestedCount:        &big.Int{},
+	}
+
+	one := big.NewInt(1)
+	h := sha256.New()
+	writeOutcome := func(a *big.Int, isWitness bool) {
+		fmt.Fprintf(h, "%v %t\n", a, isWitness)
+		c.TestedCount.Add(c.TestedCount, one)
+	}
+	var ranges []Range
+	if result.Method == MethodAKS || result.Method == MethodAKSConjecture {
+		ranges = completed.getRanges()
+	}
+	witnessWritten := false
+	for _, r := range ranges {
+		start := max(r.Start, result.Start)
+		end := min(r.End, result.End)
+		for a := new(big.Int).Set(start); a.Cmp(end) < 0; a.Add(a, one) {
+			if !witnessWritten && result.Witness != nil &&
+				result.Witness.Cmp(a) < 0 {
+				writeOutcome(result.Witness, true)
+				witnessWritten = true
+			}
+			writeOutcome(a, false)
+		}
+	}
+	if !witnessWritten && result.Witness != nil {
+		writeOutcome(result.Witness, true)
+	}
+	c.WitnessDigest = hex.EncodeToString(h.Sum(nil))
+	return c
+}
+
+// Writes c to w as indented JSON.
+func (c *Certificate) WriteJSON(w io.Writer) error {
+	return writeIndentedJSON(w, c)
+}
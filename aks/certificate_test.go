@@ -0,0 +1,84 @@
+package aks
+
+import "bytes"
+import "context"
+import "crypto/sha256"
+import "encoding/hex"
+import "encoding/json"
+import "fmt"
+import "math/big"
+import "testing"
+
+// The certificate for a prime proven by the AKS witness search
+// should cover every number in [1, M).
+func TestCertificatePrime(t *testing.T) {
+	n := big.NewInt(1000003)
+	result, err := RunAKS(context.Background(), n, &AKSOptions{
+		GenerateCertificate: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := result.Certificate
+	if c == nil {
+		t.Fatal(c)
+	}
+	if c.Verdict != Prime || c.Method != MethodAKS {
+		t.Error(c.Verdict, c.Method)
+	}
+
+	var expectedCount big.Int
+	expectedCount.Sub(result.M, big.NewInt(1))
+	if c.TestedCount.Cmp(&expectedCount) != 0 {
+		t.Error(c.TestedCount, &expectedCount)
+	}
+	h := sha256.New()
+	for a := int64(1); a < result.M.Int64(); a++ {
+		fmt.Fprintf(h, "%d false\n", a)
+	}
+	expectedDigest := hex.EncodeToString(h.Sum(nil))
+	if c.WitnessDigest != expectedDigest {
+		t.Error(c.WitnessDigest, expectedDigest)
+	}
+}
+
+// The certificate for a composite caught by trial division should
+// record the factor and no tested witnesses.
+func TestCertificateTrialDivision(t *testing.T) {
+	n := big.NewInt(1961)
+	result, err := RunAKS(context.Background(), n, &AKSOptions{
+		GenerateCertificate: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := result.Certificate
+	if c.Factor.Cmp(big.NewInt(37)) != 0 || c.TestedCount.Sign() != 0 {
+		t.Error(c.Factor, c.TestedCount)
+	}
+}
+
+// A certificate should survive a round trip through JSON.
+func TestCertificateJSON(t *testing.T) {
+	n := big.NewInt(2993374621)
+	result, err := RunAKS(context.Background(), n, &AKSOptions{
+		SkipBPSW:            true,
+		GenerateCertificate: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := result.Certificate.WriteJSON(&buf); err != nil {
+		t.Fatal(err)
+	}
+	var c Certificate
+	if err := json.Unmarshal(buf.Bytes(), &c); err != nil {
+		t.Fatal(err)
+	}
+	if c.N.Cmp(n) != 0 || c.Verdict != Composite ||
+		c.Method != MethodAKS || c.Witness == nil ||
+		c.WitnessDigest != result.Certificate.WitnessDigest {
+		t.Error(c)
+	}
+}
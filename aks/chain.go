@@ -0,0 +1,240 @@
+package aks
+
+import "fmt"
+import "math/big"
+
+// An AdditionChain describes how to compute x^e from x with a
+// sequence of multiplications, where the first power is x itself and
+// the ith multiplication computes the (i+1)st power as the product of
+// the powers with the indices in Steps[i], which must be at most i
+// (and may be equal, for a squaring). The last power is x^e.
+//
+// Short chains for e let Pow compute x^e with fewer multiplications
+// than binary exponentiation; see WindowAdditionChain.
+type AdditionChain struct {
+	Steps [][2]int
+}
+
+// Returns the exponent e computed by c, or an error if c is invalid.
+func (c AdditionChain) Exponent() (*big.Int, error) {
+	exponents := make([]big.Int, len(c.Steps)+1)
+	exponents[0].SetInt64(1)
+	for i, step := range c.Steps {
+		if step[0] < 0 || step[0] > i || step[1] < 0 || step[1] > i {
+			return nil, fmt.Errorf(
+				"%w: step %d refers to a later power",
+				ErrBadInput, i)
+		}
+		exponents[i+1].Add(&exponents[step[0]], &exponents[step[1]])
+	}
+	return &exponents[len(c.Steps)], nil
+}
+
+// Returns the number of multiplications done by c.
+func (c AdditionChain) MultiplicationCount() int {
+	return len(c.Steps)
+}
+
+// Returns the addition chain for e >= 1 made by sliding-window
+// exponentiation with windows of up to windowBits >= 1 bits, which
+// precomputes x^3, x^5, ..., x^(2^windowBits - 1) and then multiplies
+// by one of them for every window of e's bits. A windowBits of 1
+// gives binary exponentiation, and larger values trade memory for
+// about log2(e)/(windowBits + 1) multiplications instead of
+// log2(e)/2.
+func WindowAdditionChain(e *big.Int, windowBits int) (AdditionChain, error) {
+	if e.Sign() <= 0 {
+		return AdditionChain{}, fmt.Errorf(
+			"%w: e = %v must be positive", ErrBadInput, e)
+	}
+	if windowBits < 1 || windowBits > 16 {
+		return AdditionChain{}, fmt.Errorf(
+			"%w: windowBits = %d must be between 1 and 16",
+			ErrBadInput, windowBits)
+	}
+
+	var c AdditionChain
+	// Appends a step and returns the index of its power.
+	appendStep := func(i, j int) int {
+		c.Steps = append(c.Steps, [2]int{i, j})
+		return len(c.Steps)
+	}
+
+	// oddPowers[i] is the index of x^(2i + 1). Only precompute as
+	// many as e's length can use.
+	if windowBits > e.BitLen() {
+		windowBits = e.BitLen()
+	}
+	oddPowers := []int{0}
+	if windowBits > 1 {
+		square := appendStep(0, 0)
+		for v := 3; v < 1<<uint(windowBits); v += 2 {
+			oddPowers = append(
+				oddPowers, appendStep(oddPowers[len(oddPowers)-1], square))
+		}
+	}
+
+	// Scan e's bits from the top, squaring for each zero bit
+	// between windows, and for each window (which starts and ends
+	// with a one bit), squaring once per bit and multiplying by
+	// the window's odd power.
+	current := -1
+	for i := e.BitLen() - 1; i >= 0; {
+		if e.Bit(i) == 0 {
+			current = appendStep(current, current)
+			i--
+			continue
+		}
+		l := i - windowBits + 1
+		if l < 0 {
+			l = 0
+		}
+		for e.Bit(l) == 0 {
+			l++
+		}
+		v := 0
+		for j := i; j >= l; j-- {
+			v = 2*v + int(e.Bit(j))
+		}
+		if current < 0 {
+			current = oddPowers[v/2]
+		} else {
+			for j := i; j >= l; j-- {
+				current = appendStep(current, current)
+			}
+			current = appendStep(current, oddPowers[v/2])
+		}
+		i = l - 1
+	}
+
+	// Some precomputed odd powers may not have been used, and if
+	// e < 2^windowBits, x^e may not be the last power.
+	return trimAdditionChain(c, current), nil
+}
+
+// Returns the chain made of the steps of c needed to compute the
+// power with the given index, so that it's the last power.
+func trimAdditionChain(c AdditionChain, last int) AdditionChain {
+	needed := make([]bool, len(c.Steps)+1)
+	needed[last] = true
+	for i := last; i > 0; i-- {
+		if needed[i] {
+			needed[c.Steps[i-1][0]] = true
+			needed[c.Steps[i-1][1]] = true
+		}
+	}
+	newIndices := make([]int, last+1)
+	var trimmed AdditionChain
+	for i := 1; i <= last; i++ {
+		if needed[i] {
+			step := c.Steps[i-1]
+			trimmed.Steps = append(trimmed.Steps, [2]int{
+				newIndices[step[0]], newIndices[step[1]],
+			})
+			newIndices[i] = len(trimmed.Steps)
+		}
+	}
+	return trimmed
+}
+
+// Raises polys to a fixed power with an addition chain.
+type chainPower struct {
+	chain AdditionChain
+	// For each power of the chain, the index of the multiplication
+	// after which it's no longer needed.
+	lastUses []int
+	// Polys to hold the powers other than the base, since all
+	// powers that are used later must be kept around.
+	slots []poly
+}
+
+// Makes a chainPower for chain that gets its polys from ring. chain
+// must be valid.
+func newChainPower(chain AdditionChain, ring polyRing) *chainPower {
+	c := &chainPower{
+		chain:    chain,
+		lastUses: make([]int, len(chain.Steps)+1),
+	}
+	for i, step := range chain.Steps {
+		c.lastUses[step[0]] = i
+		c.lastUses[step[1]] = i
+	}
+	c.lastUses[len(chain.Steps)] = len(chain.Steps)
+
+	// Simulate the evaluation to find how many slots are needed.
+	live := 1
+	maxLive := 1
+	for i, step := range chain.Steps {
+		if c.lastUses[step[0]] != i && c.lastUses[step[1]] != i {
+			live++
+		} else if step[0] != step[1] &&
+			c.lastUses[step[0]] == i && c.lastUses[step[1]] == i {
+			live--
+		}
+		if live > maxLive {
+			maxLive = live
+		}
+	}
+	c.slots = make([]poly, maxLive-1)
+	for i := range c.slots {
+		c.slots[i] = ring.newPoly()
+	}
+	return c
+}
+
+// Returns the number of bytes allocated for c's polys.
+func (c *chainPower) getPhiBytes() int64 {
+	var phiBytes int64
+	for _, slot := range c.slots {
+		phiBytes += slot.getPhiBytes()
+	}
+	return phiBytes
+}
+
+// Like poly.powCancelable, but raises p to the power computed by c's
+// chain, which must be N, using tmp as scratch space.
+func (c *chainPower) powCancelable(
+	p poly, N big.Int, tmp poly,
+	done <-chan struct{}, onMul func()) bool {
+	free := append([]poly{}, c.slots...)
+	powers := make([]poly, len(c.chain.Steps)+1)
+	powers[0] = p
+
+	for i, step := range c.chain.Steps {
+		select {
+		case <-done:
+			return false
+		default:
+		}
+		x, y := powers[step[0]], powers[step[1]]
+		// Multiply in place into an operand that isn't needed
+		// after this step if there is one, and into a free
+		// poly otherwise.
+		var product poly
+		switch {
+		case c.lastUses[step[0]] == i:
+			product = x
+		case c.lastUses[step[1]] == i:
+			product, y = y, x
+		default:
+			product = free[len(free)-1]
+			free = free[:len(free)-1]
+			product.copyFrom(x)
+		}
+		product.Mul(y, N, tmp)
+		if onMul != nil {
+			onMul()
+		}
+		for _, j := range step {
+			if c.lastUses[j] == i && powers[j] != product {
+				free = append(free, powers[j])
+			}
+		}
+		powers[i+1] = product
+	}
+
+	if last := powers[len(c.chain.Steps)]; last != p {
+		p.copyFrom(last)
+	}
+	return true
+}
@@ -0,0 +1,142 @@
+package aks
+
+import "context"
+import "errors"
+import "math/big"
+import "testing"
+
+// Returns a window addition chain for e, failing t if there is an
+// error.
+func mustWindowAdditionChain(
+	t *testing.T, e *big.Int, windowBits int) AdditionChain {
+	chain, err := WindowAdditionChain(e, windowBits)
+	if err != nil {
+		t.Fatal(e, windowBits, err)
+	}
+	return chain
+}
+
+// WindowAdditionChain should make valid chains for the given
+// exponent, and a window size of 1 should give binary
+// exponentiation.
+func TestWindowAdditionChain(t *testing.T) {
+	var big1 big.Int
+	big1.SetString("1000000000000000000000000000057", 10)
+	for _, e := range []*big.Int{
+		big.NewInt(1), big.NewInt(2), big.NewInt(5), big.NewInt(7),
+		big.NewInt(1961), big.NewInt(1000003), &big1,
+	} {
+		for windowBits := 1; windowBits <= 6; windowBits++ {
+			chain := mustWindowAdditionChain(t, e, windowBits)
+			exponent, err := chain.Exponent()
+			if err != nil {
+				t.Fatal(e, windowBits, err)
+			}
+			if exponent.Cmp(e) != 0 {
+				t.Error(e, windowBits, exponent)
+			}
+		}
+		binary := mustWindowAdditionChain(t, e, 1)
+		mulCount := int64(binary.MultiplicationCount())
+		if mulCount != calculatePowMultiplicationCount(e) {
+			t.Error(e, mulCount)
+		}
+	}
+}
+
+// Larger windows should need fewer multiplications for large
+// exponents.
+func TestWindowAdditionChainShorter(t *testing.T) {
+	e := getFirstPrimeWithDigits(100)
+	binary := mustWindowAdditionChain(t, e, 1)
+	window := mustWindowAdditionChain(t, e, 5)
+	if window.MultiplicationCount() >= binary.MultiplicationCount() {
+		t.Error(window.MultiplicationCount(),
+			binary.MultiplicationCount())
+	}
+}
+
+// WindowAdditionChain and Exponent should reject bad input.
+func TestAdditionChainBadInput(t *testing.T) {
+	if _, err := WindowAdditionChain(big.NewInt(0), 4); !errors.Is(
+		err, ErrBadInput) {
+		t.Error(err)
+	}
+	if _, err := WindowAdditionChain(big.NewInt(5), 0); !errors.Is(
+		err, ErrBadInput) {
+		t.Error(err)
+	}
+	chain := AdditionChain{[][2]int{{0, 0}, {0, 2}}}
+	if _, err := chain.Exponent(); !errors.Is(err, ErrBadInput) {
+		t.Error(err)
+	}
+}
+
+// Raising a polynomial to the nth power with an addition chain
+// should give the same result as Pow, with all backends.
+func TestChainPower(t *testing.T) {
+	n := big.NewInt(1961)
+	r := big.NewInt(5)
+	for _, backend := range []PolyBackend{
+		BigIntPolyBackend, WordPolyBackend, NTTPolyBackend,
+	} {
+		ring, err := newPolyRing(n, r, backend)
+		if err != nil {
+			t.Fatal(backend, err)
+		}
+		for windowBits := 1; windowBits <= 4; windowBits++ {
+			chain := mustWindowAdditionChain(t, n, windowBits)
+			power := newChainPower(chain, ring)
+			p := ring.newPoly()
+			q := ring.newPoly()
+			tmp1 := ring.newPoly()
+			tmp2 := ring.newPoly()
+			p.Set(*big.NewInt(3), *big.NewInt(1), *n)
+			q.Set(*big.NewInt(3), *big.NewInt(1), *n)
+			mulCount := 0
+			if !power.powCancelable(p, *n, tmp1, nil, func() {
+				mulCount++
+			}) {
+				t.Fatal(backend, windowBits)
+			}
+			q.Pow(*n, tmp1, tmp2)
+			if !p.Eq(q) {
+				t.Errorf("%v %d %v %v", backend, windowBits, p, q)
+			}
+			if mulCount != chain.MultiplicationCount() {
+				t.Error(backend, windowBits, mulCount)
+			}
+		}
+	}
+}
+
+// RunAKS should give the same verdicts with addition chains.
+func TestRunAKSAdditionChain(t *testing.T) {
+	for _, n := range []int64{1000003, 2993374621} {
+		nBig := big.NewInt(n)
+		chain := mustWindowAdditionChain(t, nBig, 3)
+		for _, o := range []*AKSOptions{
+			{PowWindowBits: 4},
+			{AdditionChain: &chain},
+		} {
+			result, err := RunAKS(context.Background(), nBig, o)
+			if err != nil {
+				t.Fatal(n, err)
+			}
+			expected := Prime
+			if n == 2993374621 {
+				expected = Composite
+			}
+			if result.Verdict != expected {
+				t.Error(n, result.Verdict)
+			}
+		}
+	}
+
+	chain := mustWindowAdditionChain(t, big.NewInt(1000033), 3)
+	_, err := RunAKS(context.Background(), big.NewInt(1000003),
+		&AKSOptions{AdditionChain: &chain})
+	if !errors.Is(err, ErrBadInput) {
+		t.Error(err)
+	}
+}
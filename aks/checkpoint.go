@@ -0,0 +1,196 @@
+package aks
+
+import "encoding/json"
+import "fmt"
+import "io/ioutil"
+import "math/big"
+import "os"
+import "path/filepath"
+import "sort"
+
+// A Range is the half-open range [Start, End) of integers.
+type Range struct {
+	Start, End *big.Int
+}
+
+// fmt.Stringer implementation.
+func (r Range) String() string {
+	return fmt.Sprintf("[%v, %v)", r.Start, r.End)
+}
+
+// A rangeSet is a set of integers stored as a sorted list of
+// disjoint, non-adjacent ranges.
+//
+// The zero value for a rangeSet is the empty set.
+type rangeSet struct {
+	ranges []Range
+}
+
+// Returns the index of the first range in s that ends at or after
+// x, or len(s.ranges) if there is none.
+func (s *rangeSet) search(x *big.Int) int {
+	return sort.Search(len(s.ranges), func(i int) bool {
+		return s.ranges[i].End.Cmp(x) >= 0
+	})
+}
+
+// Adds all integers in [start, end) to s.
+func (s *rangeSet) addRange(start, end *big.Int) {
+	if start.Cmp(end) >= 0 {
+		return
+	}
+	newRange := Range{new(big.Int).Set(start), new(big.Int).Set(end)}
+	// Find the ranges that overlap or are adjacent to newRange
+	// and merge them into it.
+	i := s.search(start)
+	j := i
+	for ; j < len(s.ranges) && s.ranges[j].Start.Cmp(end) <= 0; j++ {
+		newRange.Start = min(newRange.Start, s.ranges[j].Start)
+		newRange.End = max(newRange.End, s.ranges[j].End)
+	}
+	ranges := append([]Range{}, s.ranges[:i]...)
+	ranges = append(ranges, newRange)
+	s.ranges = append(ranges, s.ranges[j:]...)
+}
+
+// Adds x to s.
+func (s *rangeSet) add(x *big.Int) {
+	var xPlusOne big.Int
+	xPlusOne.Add(x, big.NewInt(1))
+	s.addRange(x, &xPlusOne)
+}
+
+// Returns the smallest integer >= x that is not in s. The returned
+// value may alias x or an endpoint of a range in s, so it must not
+// be modified.
+func (s *rangeSet) nextAbsent(x *big.Int) *big.Int {
+	i := s.search(x)
+	if i < len(s.ranges) && s.ranges[i].Start.Cmp(x) <= 0 &&
+		s.ranges[i].End.Cmp(x) > 0 {
+		return s.ranges[i].End
+	}
+	return x
+}
+
+// Returns whether s contains all integers in [start, end).
+func (s *rangeSet) containsRange(start, end *big.Int) bool {
+	return s.nextAbsent(start).Cmp(end) >= 0
+}
+
+// Returns the number of members of p in [start, end) that are in s.
+func (s *rangeSet) countInRange(
+	start, end *big.Int, p progression) *big.Int {
+	count := &big.Int{}
+	for i := s.search(start); i < len(s.ranges); i++ {
+		r := s.ranges[i]
+		if r.Start.Cmp(end) >= 0 {
+			break
+		}
+		count.Add(count, p.countIn(max(r.Start, start), min(r.End, end)))
+	}
+	return count
+}
+
+// Returns a copy of the ranges in s.
+func (s *rangeSet) getRanges() []Range {
+	ranges := make([]Range, len(s.ranges))
+	for i, r := range s.ranges {
+		ranges[i] = Range{
+			new(big.Int).Set(r.Start), new(big.Int).Set(r.End),
+		}
+	}
+	return ranges
+}
+
+// Holds the state needed to resume an AKS witness search for N with
+// modulus R.
+type Checkpoint struct {
+	N, R *big.Int
+	// The ranges of numbers already known not to be AKS
+	// witnesses, in ascending order.
+	Completed []Range
+}
+
+// Reads a checkpoint previously written by Checkpoint.Save.
+func LoadCheckpoint(path string) (*Checkpoint, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var c Checkpoint
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	if c.N == nil || c.R == nil {
+		return nil, fmt.Errorf(
+			"%w: %s: missing N or R", ErrBadInput, path)
+	}
+	for _, r := range c.Completed {
+		if r.Start == nil || r.End == nil {
+			return nil, fmt.Errorf(
+				"%w: %s: invalid range", ErrBadInput, path)
+		}
+	}
+	return &c, nil
+}
+
+// Writes c to the given path. The file is replaced atomically, so an
+// interruption leaves either the old or the new checkpoint in place.
+func (c *Checkpoint) Save(path string) error {
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	f, err := ioutil.TempFile(filepath.Dir(path), ".aks-checkpoint")
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), path)
+	}
+	if err != nil {
+		os.Remove(f.Name())
+	}
+	return err
+}
+
+// Adds [start, end) to c.Completed, merging ranges as necessary.
+func (c *Checkpoint) AddCompleted(start, end *big.Int) {
+	s := c.completedRanges()
+	s.addRange(start, end)
+	c.Completed = s.getRanges()
+}
+
+// Returns whether all numbers in [start, end) are in c.Completed.
+func (c *Checkpoint) IsCompleted(start, end *big.Int) bool {
+	return c.completedRanges().containsRange(start, end)
+}
+
+// Returns the smallest number >= x that is not in c.Completed.
+func (c *Checkpoint) NextIncomplete(x *big.Int) *big.Int {
+	return new(big.Int).Set(c.completedRanges().nextAbsent(x))
+}
+
+// Returns a rangeSet holding c's completed ranges.
+func (c *Checkpoint) completedRanges() *rangeSet {
+	s := &rangeSet{}
+	for _, r := range c.Completed {
+		s.addRange(r.Start, r.End)
+	}
+	return s
+}
+
+// Returns a rangeSet holding c's completed ranges, or an error if c
+// is not a checkpoint for n and r.
+func (c *Checkpoint) completedSet(n, r *big.Int) (*rangeSet, error) {
+	if c.N.Cmp(n) != 0 || c.R.Cmp(r) != 0 {
+		return nil, fmt.Errorf(
+			"%w: checkpoint is for n = %v, r = %v, not n = %v, r = %v",
+			ErrBadInput, c.N, c.R, n, r)
+	}
+	return c.completedRanges(), nil
+}
@@ -0,0 +1,167 @@
+package aks
+
+import "context"
+import "io/ioutil"
+import "math/big"
+import "os"
+import "path/filepath"
+import "testing"
+
+// Returns whether s consists of exactly the given int64 ranges.
+func rangeSetHasRanges(s *rangeSet, int64Ranges [][2]int64) bool {
+	if len(s.ranges) != len(int64Ranges) {
+		return false
+	}
+	for i, r := range s.ranges {
+		if r.Start.Int64() != int64Ranges[i][0] ||
+			r.End.Int64() != int64Ranges[i][1] {
+			return false
+		}
+	}
+	return true
+}
+
+// Adding numbers to a rangeSet should merge adjacent and overlapping
+// ranges.
+func TestRangeSetAdd(t *testing.T) {
+	s := &rangeSet{}
+	s.add(big.NewInt(5))
+	s.add(big.NewInt(3))
+	if !rangeSetHasRanges(s, [][2]int64{{3, 4}, {5, 6}}) {
+		t.Error(s.ranges)
+	}
+	s.add(big.NewInt(4))
+	if !rangeSetHasRanges(s, [][2]int64{{3, 6}}) {
+		t.Error(s.ranges)
+	}
+	s.addRange(big.NewInt(10), big.NewInt(20))
+	s.addRange(big.NewInt(1), big.NewInt(2))
+	if !rangeSetHasRanges(s, [][2]int64{{1, 2}, {3, 6}, {10, 20}}) {
+		t.Error(s.ranges)
+	}
+	s.addRange(big.NewInt(2), big.NewInt(12))
+	if !rangeSetHasRanges(s, [][2]int64{{1, 20}}) {
+		t.Error(s.ranges)
+	}
+}
+
+// nextAbsent should skip over the range containing its argument.
+func TestRangeSetNextAbsent(t *testing.T) {
+	s := &rangeSet{}
+	s.addRange(big.NewInt(3), big.NewInt(6))
+	expected := []int64{0, 1, 2, 6, 6, 6, 6, 7}
+	for i, e := range expected {
+		x := s.nextAbsent(big.NewInt(int64(i))).Int64()
+		if x != e {
+			t.Error(i, x, e)
+		}
+	}
+	if !s.containsRange(big.NewInt(3), big.NewInt(6)) {
+		t.Error(s.ranges)
+	}
+	if s.containsRange(big.NewInt(3), big.NewInt(7)) {
+		t.Error(s.ranges)
+	}
+}
+
+// A saved checkpoint should load back unchanged.
+func TestCheckpointSaveLoad(t *testing.T) {
+	dir, err := ioutil.TempDir("", "aks")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "checkpoint")
+
+	c := &Checkpoint{
+		N: big.NewInt(2685241991),
+		R: big.NewInt(1061),
+		Completed: []Range{
+			{big.NewInt(1), big.NewInt(10)},
+			{big.NewInt(12), big.NewInt(13)},
+		},
+	}
+	if err := c.Save(path); err != nil {
+		t.Fatal(err)
+	}
+	c2, err := LoadCheckpoint(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c2.N.Cmp(c.N) != 0 || c2.R.Cmp(c.R) != 0 ||
+		len(c2.Completed) != len(c.Completed) {
+		t.Fatal(c2)
+	}
+	for i, r := range c2.Completed {
+		if r.Start.Cmp(c.Completed[i].Start) != 0 ||
+			r.End.Cmp(c.Completed[i].End) != 0 {
+			t.Error(i, r, c.Completed[i])
+		}
+	}
+}
+
+// Resuming from a checkpoint should skip the completed numbers, and
+// the checkpoint written at the end should cover the whole range.
+func TestRunAKSCheckpointResume(t *testing.T) {
+	dir, err := ioutil.TempDir("", "aks")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "checkpoint")
+
+	n := big.NewInt(2685241991)
+	r := mustCalculateAKSModulus(n, t)
+	M := mustCalculateAKSUpperBound(n, r, t)
+	// Pretend that everything but the last number was tested.
+	var mMinusOne big.Int
+	mMinusOne.Sub(M, big.NewInt(1))
+	resume := &Checkpoint{n, r, []Range{{big.NewInt(1), &mMinusOne}}}
+	result, err := RunAKS(context.Background(), n, &AKSOptions{
+		CheckpointPath: path,
+		Resume:         resume,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Verdict != Prime {
+		t.Error(result.Verdict)
+	}
+
+	c, err := LoadCheckpoint(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(c.Completed) != 1 ||
+		c.Completed[0].Start.Cmp(big.NewInt(1)) != 0 ||
+		c.Completed[0].End.Cmp(M) != 0 {
+		t.Error(c.Completed)
+	}
+}
+
+// Resuming from a checkpoint for a different number should fail.
+func TestRunAKSResumeMismatch(t *testing.T) {
+	n := big.NewInt(2685241991)
+	resume := &Checkpoint{big.NewInt(2993374621), big.NewInt(1061), nil}
+	_, err := RunAKS(context.Background(), n, &AKSOptions{Resume: resume})
+	if err == nil {
+		t.Error(err)
+	}
+}
+
+// The exported Checkpoint helpers should merge and query ranges.
+func TestCheckpointAddCompleted(t *testing.T) {
+	c := &Checkpoint{N: big.NewInt(1000003), R: big.NewInt(431)}
+	c.AddCompleted(big.NewInt(5), big.NewInt(10))
+	c.AddCompleted(big.NewInt(1), big.NewInt(5))
+	if len(c.Completed) != 1 || !c.IsCompleted(big.NewInt(1), big.NewInt(10)) {
+		t.Error(c.Completed)
+	}
+	if c.IsCompleted(big.NewInt(1), big.NewInt(11)) {
+		t.Error(c.Completed)
+	}
+	next := c.NextIncomplete(big.NewInt(3))
+	if next.Int64() != 10 {
+		t.Error(next)
+	}
+}
@@ -0,0 +1,34 @@
+package aks
+
+import "fmt"
+import "math/big"
+
+// Returns the least prime r that divides neither n nor n^2 - 1. The
+// AKS conjecture (from section 6 of the AKS paper) is that if such an
+// r satisfies (X - 1)^n = X^n - 1 mod (n, X^r - 1), i.e. n - 1 isn't
+// an AKS witness of n with parameter r, then n is prime. This is
+// unproven, and there are heuristic arguments that it has (very large)
+// counterexamples, although none is known. r is O(log n), and usually
+// tiny. n must be >= 2.
+func CalculateConjectureAKSModulus(n *big.Int) (*big.Int, error) {
+	one := big.NewInt(1)
+	if n.Cmp(big.NewInt(2)) < 0 {
+		return nil, fmt.Errorf("%w: n = %v must be >= 2", ErrBadInput, n)
+	}
+
+	// By the prime number theorem, the primes dividing n(n^2 - 1)
+	// run out before r gets much past 3*ln(n), so this terminates.
+	r := big.NewInt(2)
+	for ; ; r.Add(r, one) {
+		if !r.ProbablyPrime(0) {
+			continue
+		}
+		var nModR, nSqModR big.Int
+		nModR.Mod(n, r)
+		nSqModR.Mul(&nModR, &nModR)
+		nSqModR.Mod(&nSqModR, r)
+		if nModR.Sign() != 0 && nSqModR.Cmp(one) != 0 {
+			return r, nil
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package aks
+
+import "context"
+import "errors"
+import "math/big"
+import "testing"
+
+// CalculateConjectureAKSModulus should return the least prime r
+// dividing neither n nor n^2 - 1.
+func TestCalculateConjectureAKSModulus(t *testing.T) {
+	tests := []struct {
+		n, r int64
+	}{
+		{2, 5},
+		{7, 5},
+		// 31 = 1 mod 5.
+		{31, 7},
+		// 2101 = 11 * 191 = 1 mod 2, 3, 5, and 7.
+		{2101, 13},
+	}
+	for _, test := range tests {
+		r, err := CalculateConjectureAKSModulus(big.NewInt(test.n))
+		if err != nil {
+			t.Fatal(test.n, err)
+		}
+		if r.Int64() != test.r {
+			t.Error(test.n, r, test.r)
+		}
+	}
+
+	if _, err := CalculateConjectureAKSModulus(
+		big.NewInt(1)); !errors.Is(err, ErrBadInput) {
+		t.Error(err)
+	}
+}
+
+// RunAKS with AKSVariantConjecture should test just n - 1 and label
+// its verdicts as heuristic.
+func TestRunAKSConjecture(t *testing.T) {
+	n := new(big.Int).Lsh(big.NewInt(1), 127)
+	n.Sub(n, big.NewInt(1))
+	result, err := RunAKS(context.Background(), n, &AKSOptions{
+		Variant:             AKSVariantConjecture,
+		GenerateCertificate: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	nMinusOne := new(big.Int).Sub(n, big.NewInt(1))
+	if result.Verdict != Prime || result.Method != MethodAKSConjecture ||
+		result.Start.Cmp(nMinusOne) != 0 || result.End.Cmp(n) != 0 ||
+		result.Stats.WitnessesTested != 1 ||
+		result.Certificate.TestedCount.Int64() != 1 {
+		t.Error(result.Verdict, result.Method, result.Start, result.End,
+			result.Stats.WitnessesTested)
+	}
+
+	n = big.NewInt(2993374621)
+	result, err = RunAKS(context.Background(), n, &AKSOptions{
+		Variant:  AKSVariantConjecture,
+		SkipBPSW: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	nMinusOne.Sub(n, big.NewInt(1))
+	if result.Verdict != Composite || result.Witness.Cmp(nMinusOne) != 0 {
+		t.Error(result.Verdict, result.Witness)
+	}
+
+	// A factor dividing n should be found by trial division.
+	result, err = RunAKS(context.Background(), big.NewInt(2101),
+		&AKSOptions{Variant: AKSVariantConjecture})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Verdict != Composite ||
+		result.Method != MethodTrialDivision {
+		t.Error(result.Verdict, result.Method)
+	}
+}
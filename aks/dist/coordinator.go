@@ -0,0 +1,308 @@
+// Package dist splits the AKS witness search for a single number
+// across multiple workers, local or remote.
+//
+// A Coordinator hands out leases on ranges of witness candidates,
+// tracks which ranges have been completed, and reassigns the ranges
+// of leases that expire before being completed. Workers (see
+// RunWorker) repeatedly take a lease, search its range with the aks
+// package's goroutine-based search, and report the result. Remote
+// workers talk to the coordinator via net/rpc (see Serve and Dial);
+// local workers use the Coordinator directly.
+package dist
+
+import "github.com/akalin/aks-go/aks"
+import "errors"
+import "fmt"
+import "math/big"
+import "sync"
+import "time"
+
+// Returned by Scheduler.Complete for a result that doesn't match the
+// coordinator's number.
+var ErrWrongNumber = errors.New("dist: result is for the wrong number")
+
+// Returned by Scheduler.Lease when all remaining work is leased out
+// but not yet completed. The worker should try again later, since
+// outstanding leases may expire.
+var ErrNoWorkAvailable = errors.New("dist: no work available yet")
+
+// Returned by Scheduler.Complete for a lease ID that the coordinator
+// never handed out, or whose result was already recorded.
+var ErrUnknownLease = errors.New("dist: unknown lease")
+
+// Returned by Scheduler.Complete for a result whose witness isn't
+// actually an AKS witness.
+var ErrNotWitness = errors.New("dist: reported witness is not a witness")
+
+// A Lease grants a worker the right to search Unit until Expires.
+type Lease struct {
+	ID      int64
+	Unit    aks.WorkUnit
+	Expires time.Time
+}
+
+// A Scheduler hands out leases and collects their results. It is
+// implemented by *Coordinator (for local workers) and *Client (for
+// remote workers).
+type Scheduler interface {
+	// Returns a new lease for the given worker, or nil if there
+	// is no more work to do, or ErrNoWorkAvailable if there might
+	// be more work later.
+	Lease(workerID string) (*Lease, error)
+	// Records the result of searching the unit of the lease with
+	// the given ID.
+	Complete(leaseID int64, result aks.WorkUnitResult) error
+}
+
+// Configures a Coordinator.
+type CoordinatorOptions struct {
+	// The number of candidates in each lease. Defaults to
+	// DefaultChunkSize if nil or not positive.
+	ChunkSize *big.Int
+	// How long a worker has to complete a lease before its range
+	// is reassigned. Defaults to DefaultLeaseDuration if not
+	// positive.
+	LeaseDuration time.Duration
+	// If non-empty, the progress is saved as an aks.Checkpoint to
+	// this path after every completed lease.
+	CheckpointPath string
+	// If non-nil, the ranges in Resume.Completed are not leased
+	// out again. Resume must be a checkpoint for the same number.
+	Resume *aks.Checkpoint
+}
+
+// The default value for CoordinatorOptions.ChunkSize.
+var DefaultChunkSize = big.NewInt(100)
+
+// The default value for CoordinatorOptions.LeaseDuration.
+const DefaultLeaseDuration = time.Hour
+
+// A Coordinator schedules the AKS witness search for a number across
+// workers. It is safe for concurrent use.
+type Coordinator struct {
+	unit          aks.WorkUnit
+	chunkSize     *big.Int
+	leaseDuration time.Duration
+
+	lock sync.Mutex
+	// Signalled whenever the coordinator becomes done.
+	doneCond       *sync.Cond
+	progress       aks.Checkpoint
+	checkpointPath string
+	// The start of the next range to lease out, if there are no
+	// expired ranges to reassign.
+	next        *big.Int
+	nextLeaseID int64
+	outstanding map[int64]*Lease
+	// Leases that expired before being completed, by ID, so that
+	// their results are still accepted if they come in late.
+	expiredLeases map[int64]*Lease
+	// Ranges from expired leases, to be leased out again.
+	expired []aks.WorkUnit
+	witness *big.Int
+}
+
+// Makes a Coordinator for the AKS witness search of n >= 2.
+func NewCoordinator(n *big.Int, opts *CoordinatorOptions) (*Coordinator, error) {
+	var o CoordinatorOptions
+	if opts != nil {
+		o = *opts
+	}
+	if o.ChunkSize == nil || o.ChunkSize.Sign() <= 0 {
+		o.ChunkSize = DefaultChunkSize
+	}
+	if o.LeaseDuration <= 0 {
+		o.LeaseDuration = DefaultLeaseDuration
+	}
+
+	units, err := aks.MakeWorkUnits(n, 1)
+	if err != nil {
+		return nil, err
+	}
+	c := &Coordinator{
+		unit:           units[0],
+		chunkSize:      o.ChunkSize,
+		leaseDuration:  o.LeaseDuration,
+		checkpointPath: o.CheckpointPath,
+		next:           new(big.Int).Set(units[0].Start),
+		outstanding:    make(map[int64]*Lease),
+		expiredLeases:  make(map[int64]*Lease),
+	}
+	c.doneCond = sync.NewCond(&c.lock)
+	c.progress = aks.Checkpoint{N: c.unit.N, R: c.unit.R}
+	if o.Resume != nil {
+		if o.Resume.N.Cmp(c.unit.N) != 0 ||
+			o.Resume.R.Cmp(c.unit.R) != 0 {
+			return nil, fmt.Errorf(
+				"%w: checkpoint is for n = %v, r = %v",
+				aks.ErrBadInput, o.Resume.N, o.Resume.R)
+		}
+		for _, r := range o.Resume.Completed {
+			c.progress.AddCompleted(r.Start, r.End)
+		}
+	}
+	return c, nil
+}
+
+// Returns the work unit covering the whole search.
+func (c *Coordinator) GetWorkUnit() aks.WorkUnit {
+	return c.unit
+}
+
+// Returns whether there's nothing left to lease out. c.lock must be
+// held.
+func (c *Coordinator) isDoneLocked() bool {
+	return c.witness != nil ||
+		c.progress.IsCompleted(c.unit.Start, c.unit.End)
+}
+
+// Returns whether the search is finished, i.e. a witness was found or
+// all candidates were searched.
+func (c *Coordinator) IsDone() bool {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.isDoneLocked()
+}
+
+// Blocks until the search is finished.
+func (c *Coordinator) Wait() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	for !c.isDoneLocked() {
+		c.doneCond.Wait()
+	}
+}
+
+// Moves expired leases to c.expiredLeases, and their ranges to
+// c.expired. c.lock must be held.
+func (c *Coordinator) expireLeasesLocked(now time.Time) {
+	for id, lease := range c.outstanding {
+		if now.After(lease.Expires) {
+			c.expired = append(c.expired, lease.Unit)
+			c.expiredLeases[id] = lease
+			delete(c.outstanding, id)
+		}
+	}
+}
+
+// Scheduler implementation. Reassigns the ranges of expired leases
+// before leasing out new ranges.
+func (c *Coordinator) Lease(workerID string) (*Lease, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.isDoneLocked() {
+		return nil, nil
+	}
+	now := time.Now()
+	c.expireLeasesLocked(now)
+
+	// Skip the ranges of expired leases whose results came in
+	// late.
+	for len(c.expired) > 0 &&
+		c.progress.IsCompleted(c.expired[0].Start, c.expired[0].End) {
+		c.expired = c.expired[1:]
+	}
+	var unit aks.WorkUnit
+	if len(c.expired) > 0 {
+		unit = c.expired[0]
+		c.expired = c.expired[1:]
+	} else {
+		start := c.progress.NextIncomplete(c.next)
+		if start.Cmp(c.unit.End) >= 0 {
+			return nil, ErrNoWorkAvailable
+		}
+		end := new(big.Int).Add(start, c.chunkSize)
+		if end.Cmp(c.unit.End) > 0 {
+			end.Set(c.unit.End)
+		}
+		c.next = end
+		unit = c.unit
+		unit.Start = start
+		unit.End = end
+	}
+
+	c.nextLeaseID++
+	lease := &Lease{c.nextLeaseID, unit, now.Add(c.leaseDuration)}
+	c.outstanding[lease.ID] = lease
+	return lease, nil
+}
+
+// Scheduler implementation. Results are accepted even if the lease
+// has expired, since the work is still valid, but only the parts of
+// them within the lease's range are recorded. Returns an error
+// wrapping ErrUnknownLease if leaseID wasn't handed out by c, or was
+// already completed. Returns an error wrapping ErrNotWitness, and
+// records nothing, if the result's witness doesn't check out; the
+// lease then expires as usual, and its unit is leased out again.
+func (c *Coordinator) Complete(leaseID int64, result aks.WorkUnitResult) error {
+	if result.N == nil || result.N.Cmp(c.unit.N) != 0 {
+		return fmt.Errorf("%w: %v", ErrWrongNumber, result.N)
+	}
+	// A bogus witness would make GetResult call a prime
+	// composite, and checking it only takes one witness test.
+	if w := result.Witness; w != nil {
+		isWitness, err := aks.IsAKSWitness(c.unit.N, c.unit.R, w)
+		if err != nil {
+			return fmt.Errorf("%w: %v: %v", ErrNotWitness, w, err)
+		}
+		if !isWitness {
+			return fmt.Errorf("%w: %v", ErrNotWitness, w)
+		}
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	lease := c.outstanding[leaseID]
+	if lease == nil {
+		lease = c.expiredLeases[leaseID]
+	}
+	if lease == nil {
+		return fmt.Errorf("%w: %d", ErrUnknownLease, leaseID)
+	}
+	delete(c.outstanding, leaseID)
+	delete(c.expiredLeases, leaseID)
+
+	// Don't trust the worker with anything outside the range it
+	// was leased.
+	leaseStart, leaseEnd := lease.Unit.Start, lease.Unit.End
+	for _, r := range result.Completed {
+		start, end := r.Start, r.End
+		if start == nil || end == nil {
+			continue
+		}
+		if start.Cmp(leaseStart) < 0 {
+			start = leaseStart
+		}
+		if end.Cmp(leaseEnd) > 0 {
+			end = leaseEnd
+		}
+		if start.Cmp(end) < 0 {
+			c.progress.AddCompleted(start, end)
+		}
+	}
+	if w := result.Witness; w != nil &&
+		w.Cmp(leaseStart) >= 0 && w.Cmp(leaseEnd) < 0 &&
+		(c.witness == nil || w.Cmp(c.witness) < 0) {
+		c.witness = w
+	}
+	var err error
+	if len(c.checkpointPath) > 0 {
+		err = c.progress.Save(c.checkpointPath)
+	}
+	if c.isDoneLocked() {
+		c.doneCond.Broadcast()
+	}
+	return err
+}
+
+// Returns the result of the search so far, as if all completed
+// leases were merged with aks.MergeWorkUnitResults.
+func (c *Coordinator) GetResult() (*aks.PrimalityResult, error) {
+	c.lock.Lock()
+	result := aks.WorkUnitResult{
+		WorkUnit:  c.unit,
+		Witness:   c.witness,
+		Completed: c.progress.Completed,
+	}
+	c.lock.Unlock()
+	return aks.MergeWorkUnitResults([]aks.WorkUnitResult{result})
+}
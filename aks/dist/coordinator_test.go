@@ -0,0 +1,234 @@
+package dist
+
+import "github.com/akalin/aks-go/aks"
+import "context"
+import "errors"
+import "math/big"
+import "net"
+import "testing"
+import "time"
+
+// Local workers should prove a prime prime.
+func TestRunLocalPrime(t *testing.T) {
+	c, err := NewCoordinator(big.NewInt(1000003), &CoordinatorOptions{
+		ChunkSize: big.NewInt(50),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := RunLocal(context.Background(), c, 3, &WorkerOptions{
+		RetryInterval: time.Millisecond,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Verdict != aks.Prime || result.Method != aks.MethodAKS {
+		t.Error(result.Verdict, result.Method)
+	}
+	if !c.IsDone() {
+		t.Error("not done")
+	}
+}
+
+// Local workers should find a witness for a composite.
+func TestRunLocalComposite(t *testing.T) {
+	c, err := NewCoordinator(big.NewInt(2993374621), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := RunLocal(context.Background(), c, 2, &WorkerOptions{
+		RetryInterval: time.Millisecond,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Verdict != aks.Composite || result.Witness == nil {
+		t.Error(result.Verdict, result.Witness)
+	}
+}
+
+// The range of an expired lease should be leased out again.
+func TestLeaseExpiry(t *testing.T) {
+	c, err := NewCoordinator(big.NewInt(1000003), &CoordinatorOptions{
+		ChunkSize:     big.NewInt(1000),
+		LeaseDuration: time.Nanosecond,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	lease1, err := c.Lease("a")
+	if err != nil || lease1 == nil {
+		t.Fatal(lease1, err)
+	}
+	time.Sleep(time.Millisecond)
+	lease2, err := c.Lease("b")
+	if err != nil || lease2 == nil {
+		t.Fatal(lease2, err)
+	}
+	if lease2.ID == lease1.ID ||
+		lease2.Unit.Start.Cmp(lease1.Unit.Start) != 0 ||
+		lease2.Unit.End.Cmp(lease1.Unit.End) != 0 {
+		t.Error(lease1, lease2)
+	}
+}
+
+// Complete should reject lease IDs it never handed out and witnesses
+// that don't check out, and only record the parts of a result within
+// the lease's range.
+func TestCompleteUntrustedResults(t *testing.T) {
+	n := big.NewInt(1000003)
+	c, err := NewCoordinator(n, &CoordinatorOptions{
+		ChunkSize: big.NewInt(10),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	unit := c.GetWorkUnit()
+	everything := aks.WorkUnitResult{
+		WorkUnit:  unit,
+		Completed: []aks.Range{{Start: unit.Start, End: unit.End}},
+	}
+
+	if err := c.Complete(12345, everything); !errors.Is(
+		err, ErrUnknownLease) {
+		t.Error(err)
+	}
+	if result, err := c.GetResult(); err != nil ||
+		result.Verdict != aks.Undetermined {
+		t.Fatal(result, err)
+	}
+
+	lease, err := c.Lease("a")
+	if err != nil || lease == nil {
+		t.Fatal(lease, err)
+	}
+	// n is prime, so it has no witnesses, and a result claiming
+	// one should be rejected without recording anything.
+	bogus := everything
+	bogus.Witness = new(big.Int).Set(lease.Unit.Start)
+	if err := c.Complete(lease.ID, bogus); !errors.Is(
+		err, ErrNotWitness) {
+		t.Fatal(err)
+	}
+	c.lock.Lock()
+	completed := c.progress.Completed
+	c.lock.Unlock()
+	if len(completed) != 0 {
+		t.Error(completed)
+	}
+
+	if err := c.Complete(lease.ID, everything); err != nil {
+		t.Fatal(err)
+	}
+	if c.IsDone() {
+		t.Fatal("done after out-of-range result")
+	}
+	result, err := c.GetResult()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Verdict != aks.Undetermined {
+		t.Error(result.Verdict)
+	}
+	c.lock.Lock()
+	completed = c.progress.Completed
+	c.lock.Unlock()
+	if len(completed) != 1 ||
+		completed[0].Start.Cmp(lease.Unit.Start) != 0 ||
+		completed[0].End.Cmp(lease.Unit.End) != 0 {
+		t.Error(completed)
+	}
+
+	// A lease can only be completed once.
+	if err := c.Complete(lease.ID, everything); !errors.Is(
+		err, ErrUnknownLease) {
+		t.Error(err)
+	}
+}
+
+// The late result of an expired lease should still be recorded.
+func TestCompleteExpiredLease(t *testing.T) {
+	c, err := NewCoordinator(big.NewInt(1000003), &CoordinatorOptions{
+		ChunkSize:     big.NewInt(1000),
+		LeaseDuration: time.Nanosecond,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	lease1, err := c.Lease("a")
+	if err != nil || lease1 == nil {
+		t.Fatal(lease1, err)
+	}
+	time.Sleep(time.Millisecond)
+	lease2, err := c.Lease("b")
+	if err != nil || lease2 == nil {
+		t.Fatal(lease2, err)
+	}
+	result := aks.WorkUnitResult{
+		WorkUnit: lease1.Unit,
+		Completed: []aks.Range{
+			{Start: lease1.Unit.Start, End: lease1.Unit.End},
+		},
+	}
+	if err := c.Complete(lease1.ID, result); err != nil {
+		t.Fatal(err)
+	}
+	if !c.IsDone() {
+		t.Error("not done")
+	}
+}
+
+// With all work leased out, Lease should ask the worker to retry.
+func TestLeaseNoWorkAvailable(t *testing.T) {
+	c, err := NewCoordinator(big.NewInt(1000003), &CoordinatorOptions{
+		ChunkSize: big.NewInt(1000),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Lease("a"); err != nil {
+		t.Fatal(err)
+	}
+	if lease, err := c.Lease("b"); lease != nil ||
+		err != ErrNoWorkAvailable {
+		t.Error(lease, err)
+	}
+}
+
+// A remote worker should be able to complete the search over RPC.
+func TestRemoteWorker(t *testing.T) {
+	c, err := NewCoordinator(big.NewInt(1000003), &CoordinatorOptions{
+		ChunkSize: big.NewInt(100),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	go Serve(l, c)
+
+	client, err := Dial(l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	completed, err := RunWorker(
+		context.Background(), client, "remote", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if completed != 4 {
+		t.Error(completed)
+	}
+	c.Wait()
+	result, err := c.GetResult()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Verdict != aks.Prime {
+		t.Error(result.Verdict)
+	}
+}
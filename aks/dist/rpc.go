@@ -0,0 +1,113 @@
+package dist
+
+import "github.com/akalin/aks-go/aks"
+import "net"
+import "net/rpc"
+
+// The name under which the coordinator's RPC service is registered.
+const serviceName = "Coordinator"
+
+// Exposes a Coordinator via net/rpc. Its methods follow the net/rpc
+// conventions and are not meant to be called directly.
+type CoordinatorService struct {
+	c *Coordinator
+}
+
+// The arguments to CoordinatorService.Lease.
+type LeaseArgs struct {
+	WorkerID string
+}
+
+// The reply from CoordinatorService.Lease.
+type LeaseReply struct {
+	// Nil if there is no more work to do.
+	Lease *Lease
+	// Whether Coordinator.Lease returned ErrNoWorkAvailable.
+	NoWorkAvailable bool
+}
+
+// The arguments to CoordinatorService.Complete.
+type CompleteArgs struct {
+	LeaseID int64
+	Result  aks.WorkUnitResult
+}
+
+// The (empty) reply from CoordinatorService.Complete.
+type CompleteReply struct{}
+
+// Calls Coordinator.Lease.
+func (s *CoordinatorService) Lease(args *LeaseArgs, reply *LeaseReply) error {
+	lease, err := s.c.Lease(args.WorkerID)
+	if err == ErrNoWorkAvailable {
+		reply.NoWorkAvailable = true
+		return nil
+	}
+	reply.Lease = lease
+	return err
+}
+
+// Calls Coordinator.Complete.
+func (s *CoordinatorService) Complete(
+	args *CompleteArgs, reply *CompleteReply) error {
+	return s.c.Complete(args.LeaseID, args.Result)
+}
+
+// Serves c to remote workers on connections accepted from l until l
+// is closed, and then returns the error from l.Accept().
+func Serve(l net.Listener, c *Coordinator) error {
+	server := rpc.NewServer()
+	if err := server.RegisterName(
+		serviceName, &CoordinatorService{c}); err != nil {
+		return err
+	}
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return err
+		}
+		go server.ServeConn(conn)
+	}
+}
+
+// A Client is a Scheduler that talks to a remote Coordinator.
+type Client struct {
+	client *rpc.Client
+}
+
+// Connects to the coordinator being served at the given TCP
+// address.
+func Dial(address string) (*Client, error) {
+	client, err := rpc.Dial("tcp", address)
+	if err != nil {
+		return nil, err
+	}
+	return &Client{client}, nil
+}
+
+// Scheduler implementation.
+func (c *Client) Lease(workerID string) (*Lease, error) {
+	var reply LeaseReply
+	if err := c.client.Call(
+		serviceName+".Lease", &LeaseArgs{workerID}, &reply); err != nil {
+		return nil, err
+	}
+	if reply.NoWorkAvailable {
+		return nil, ErrNoWorkAvailable
+	}
+	return reply.Lease, nil
+}
+
+// Scheduler implementation.
+func (c *Client) Complete(leaseID int64, result aks.WorkUnitResult) error {
+	return c.client.Call(
+		serviceName+".Complete", &CompleteArgs{leaseID, result},
+		&CompleteReply{})
+}
+
+// Closes the connection to the coordinator.
+func (c *Client) Close() error {
+	return c.client.Close()
+}
+
+var _ Scheduler = (*Coordinator)(nil)
+var _ Scheduler = (*Client)(nil)
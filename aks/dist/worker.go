@@ -0,0 +1,95 @@
+package dist
+
+import "github.com/akalin/aks-go/aks"
+import "context"
+import "time"
+
+// Configures RunWorker.
+type WorkerOptions struct {
+	// Options for searching each leased range. Its Start, End,
+	// Resume, and trial division options are ignored.
+	AKSOptions *aks.AKSOptions
+	// How long to wait before asking for more work when none is
+	// available. Defaults to DefaultRetryInterval if not positive.
+	RetryInterval time.Duration
+}
+
+// The default value for WorkerOptions.RetryInterval.
+const DefaultRetryInterval = 10 * time.Second
+
+// Repeatedly leases ranges from s and searches them until there is
+// no more work, ctx is cancelled, or s returns an error. Returns the
+// number of leases completed.
+func RunWorker(
+	ctx context.Context,
+	s Scheduler,
+	workerID string,
+	opts *WorkerOptions) (int, error) {
+	var o WorkerOptions
+	if opts != nil {
+		o = *opts
+	}
+	if o.RetryInterval <= 0 {
+		o.RetryInterval = DefaultRetryInterval
+	}
+	var aksOpts aks.AKSOptions
+	if o.AKSOptions != nil {
+		aksOpts = *o.AKSOptions
+	}
+	aksOpts.Resume = nil
+
+	completed := 0
+	for {
+		lease, err := s.Lease(workerID)
+		if err == ErrNoWorkAvailable {
+			select {
+			case <-time.After(o.RetryInterval):
+				continue
+			case <-ctx.Done():
+				return completed, ctx.Err()
+			}
+		}
+		if err != nil {
+			return completed, err
+		}
+		if lease == nil {
+			return completed, nil
+		}
+
+		result, err := aks.RunWorkUnit(ctx, lease.Unit, &aksOpts)
+		if err != nil {
+			return completed, err
+		}
+		if err := s.Complete(lease.ID, *result); err != nil {
+			return completed, err
+		}
+		completed++
+	}
+}
+
+// Searches for AKS witnesses of c's number with the given number of
+// local workers, each running its own goroutine-based search with
+// the given options, and returns the merged result.
+func RunLocal(
+	ctx context.Context,
+	c *Coordinator,
+	workers int,
+	opts *WorkerOptions) (*aks.PrimalityResult, error) {
+	errCh := make(chan error, workers)
+	for i := 0; i < workers; i++ {
+		go func() {
+			_, err := RunWorker(ctx, c, "local", opts)
+			errCh <- err
+		}()
+	}
+	var firstErr error
+	for i := 0; i < workers; i++ {
+		if err := <-errCh; err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	return c.GetResult()
+}
@@ -0,0 +1,308 @@
+package aks
+
+import "context"
+import "fmt"
+import "math/big"
+import "math/rand"
+
+// The defaults for ECMOptions, which give a good chance of finding
+// factors of up to 20 digits. Good choices for other factor sizes
+// are (from GMP-ECM's tables) 25 curves with B1 = 2000 for 15
+// digits, and 300 curves with B1 = 50000 for 25 digits.
+const (
+	DefaultECMCurves = 90
+	DefaultECMB1     = 11000
+)
+
+// ECM runs stage 2 up to B2 = ecmB2Multiplier*B1 by default.
+const ecmB2Multiplier = 100
+
+// The number of stage 2 products ECM multiplies together between
+// gcds.
+const ecmGCDInterval = 1024
+
+// The giant step size of stage 2, which is 2*3*5*7.
+const ecmStage2D = 210
+
+// Holds the parameters for ECM.
+type ECMOptions struct {
+	// The number of curves to try. If not positive,
+	// DefaultECMCurves is used.
+	Curves int
+	// The stage 1 bound: each curve finds p if the order of the
+	// curve mod p is a product of primes up to B1 (and one more
+	// prime up to B2). If zero, DefaultECMB1 is used.
+	B1 uint64
+	// The stage 2 bound. If not greater than B1, 100*B1 is used.
+	B2 uint64
+	// The seed used to pick the curves.
+	Seed int64
+}
+
+// Returns a copy of opts, which may be nil, with defaults filled in.
+func (opts *ECMOptions) withDefaults() ECMOptions {
+	var o ECMOptions
+	if opts != nil {
+		o = *opts
+	}
+	if o.Curves <= 0 {
+		o.Curves = DefaultECMCurves
+	}
+	if o.B1 == 0 {
+		o.B1 = DefaultECMB1
+	}
+	if o.B2 <= o.B1 {
+		o.B2 = ecmB2Multiplier * o.B1
+	}
+	return o
+}
+
+// Returns a nontrivial factor of n found with Lenstra's elliptic curve
+// method as configured by opts, which may be nil, or nil if none of the
+// curves finds one. The time it takes to find a factor p depends
+// mostly on the size of p rather than of n. Returns nil and ctx.Err()
+// if ctx is cancelled first, or an error wrapping ErrBadInput if n < 2
+// or n is prime (as decided by big.Int.ProbablyPrime).
+func ECM(ctx context.Context, n *big.Int, opts *ECMOptions) (*big.Int, error) {
+	if n.Cmp(big.NewInt(2)) < 0 {
+		return nil, fmt.Errorf("%w: n = %v must be >= 2", ErrBadInput, n)
+	}
+	if n.ProbablyPrime(primalityRounds) {
+		return nil, fmt.Errorf("%w: n = %v is prime", ErrBadInput, n)
+	}
+	o := opts.withDefaults()
+	return ecm(ctx, n, &o)
+}
+
+// Like ECM, but assumes n is composite and that o has defaults filled
+// in.
+func ecm(ctx context.Context, n *big.Int, o *ECMOptions) (*big.Int, error) {
+	if n.Bit(0) == 0 {
+		return big.NewInt(2), nil
+	}
+	primes := getPrimesIn(2, o.B2+ecmStage2D+1)
+	rng := rand.New(rand.NewSource(o.Seed))
+	for i := 0; i < o.Curves; i++ {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		// Suyama's parametrization needs sigma in [6, n - 1).
+		var sigma big.Int
+		sigma.Sub(n, big.NewInt(7))
+		sigma.Rand(rng, &sigma)
+		sigma.Add(&sigma, big.NewInt(6))
+		if d := ecmCurve(ctx, n, &sigma, o.B1, o.B2, primes); d != nil {
+			return d, nil
+		}
+	}
+	return nil, ctx.Err()
+}
+
+// A point on a Montgomery curve By^2 = x^3 + Ax^2 + x mod n, in
+// projective coordinates without y, i.e. x = X/Z.
+type ecmPoint struct {
+	X, Z big.Int
+}
+
+// A Montgomery curve mod n, given by (A + 2)/4.
+type ecmCurveParams struct {
+	n, a24 *big.Int
+}
+
+// Sets p to 2q.
+func (c ecmCurveParams) double(p, q *ecmPoint) {
+	var s, d, t, x big.Int
+	s.Add(&q.X, &q.Z)
+	s.Mul(&s, &s)
+	d.Sub(&q.X, &q.Z)
+	d.Mul(&d, &d)
+	t.Sub(&s, &d)
+	x.Mul(&s, &d)
+	p.X.Mod(&x, c.n)
+	x.Mul(c.a24, &t)
+	x.Add(&x, &d)
+	x.Mul(&x, &t)
+	p.Z.Mod(&x, c.n)
+}
+
+// Sets p to q + r, given diff = q - r.
+func (c ecmCurveParams) add(p, q, r, diff *ecmPoint) {
+	var u, v, s, t big.Int
+	u.Sub(&q.X, &q.Z)
+	t.Add(&r.X, &r.Z)
+	u.Mul(&u, &t)
+	v.Add(&q.X, &q.Z)
+	t.Sub(&r.X, &r.Z)
+	v.Mul(&v, &t)
+	s.Add(&u, &v)
+	s.Mul(&s, &s)
+	t.Sub(&u, &v)
+	t.Mul(&t, &t)
+	// diff may alias p, so compute both coordinates first.
+	s.Mul(&s, &diff.Z)
+	t.Mul(&t, &diff.X)
+	p.X.Mod(&s, c.n)
+	p.Z.Mod(&t, c.n)
+}
+
+// Sets p to kq with the Montgomery ladder. k must be positive.
+func (c ecmCurveParams) mul(p, q *ecmPoint, k *big.Int) {
+	var r0, r1 ecmPoint
+	r0.X.Set(&q.X)
+	r0.Z.Set(&q.Z)
+	c.double(&r1, q)
+	for i := k.BitLen() - 2; i >= 0; i-- {
+		if k.Bit(i) == 1 {
+			c.add(&r0, &r0, &r1, q)
+			c.double(&r1, &r1)
+		} else {
+			c.add(&r1, &r0, &r1, q)
+			c.double(&r0, &r0)
+		}
+	}
+	p.X.Set(&r0.X)
+	p.Z.Set(&r0.Z)
+}
+
+// Returns gcd(x, n) if it is a nontrivial factor of n, or nil.
+func getNontrivialGCD(x, n *big.Int) *big.Int {
+	var g big.Int
+	g.GCD(nil, nil, x, n)
+	if g.Cmp(big.NewInt(1)) == 0 || g.Cmp(n) == 0 {
+		return nil
+	}
+	return &g
+}
+
+// Runs both stages of ECM on the curve given by sigma with Suyama's
+// parametrization, where primes holds the primes up to at least B2 +
+// ecmStage2D. Returns the factor of n found, or nil.
+func ecmCurve(
+	ctx context.Context,
+	n, sigma *big.Int,
+	B1, B2 uint64,
+	primes []uint64) *big.Int {
+	// u = sigma^2 - 5, v = 4*sigma, the starting point is
+	// (u^3 : v^3), and (A + 2)/4 = (v - u)^3 (3u + v)/(16 u^3 v).
+	var u, v, t, a24Num, a24Den big.Int
+	u.Mul(sigma, sigma)
+	u.Sub(&u, big.NewInt(5))
+	u.Mod(&u, n)
+	v.Lsh(sigma, 2)
+	v.Mod(&v, n)
+	var q ecmPoint
+	q.X.Exp(&u, big.NewInt(3), n)
+	q.Z.Exp(&v, big.NewInt(3), n)
+	t.Sub(&v, &u)
+	a24Num.Exp(&t, big.NewInt(3), n)
+	t.Mul(&u, big.NewInt(3))
+	t.Add(&t, &v)
+	a24Num.Mul(&a24Num, &t)
+	a24Den.Lsh(&q.X, 4)
+	a24Den.Mul(&a24Den, &v)
+	a24Den.Mod(&a24Den, n)
+	var a24 big.Int
+	if a24.ModInverse(&a24Den, n) == nil {
+		// The inverse doesn't exist, which may be luck.
+		return getNontrivialGCD(&a24Den, n)
+	}
+	a24.Mul(&a24, &a24Num)
+	a24.Mod(&a24, n)
+	c := ecmCurveParams{n, &a24}
+
+	// Stage 1: multiply q by every prime power up to B1.
+	var k big.Int
+	for _, p := range primes {
+		if p > B1 {
+			break
+		}
+		pk := p
+		for pk <= B1/p {
+			pk *= p
+		}
+		c.mul(&q, &q, k.SetUint64(pk))
+	}
+	if d := getNontrivialGCD(&q.Z, n); d != nil || q.Z.Sign() == 0 {
+		return d
+	}
+	if ctx.Err() != nil {
+		return nil
+	}
+
+	// Stage 2: find whether pq = 0 mod some factor of n for a
+	// prime p in (B1, B2], by writing p = mD +- j, where the
+	// baby steps j are the numbers less than D/2 coprime to D,
+	// and checking whether (mD)q and jq have the same x.
+	var baby [ecmStage2D / 2]*ecmPoint
+	var q2 ecmPoint
+	c.double(&q2, &q)
+	baby[1] = &ecmPoint{}
+	baby[1].X.Set(&q.X)
+	baby[1].Z.Set(&q.Z)
+	prevOdd := &q
+	for j := 3; j < ecmStage2D/2; j += 2 {
+		// jq = (j - 2)q + 2q, with difference (j - 4)q.
+		next := &ecmPoint{}
+		if j == 3 {
+			c.add(next, baby[1], &q2, baby[1])
+		} else {
+			c.add(next, prevOdd, &q2, baby[j-4])
+		}
+		baby[j] = next
+		prevOdd = next
+	}
+
+	isPrime := make([]bool, B2+ecmStage2D)
+	for _, p := range primes {
+		if p > B1 && p <= B2 {
+			isPrime[p] = true
+		}
+	}
+	m := B1 / ecmStage2D
+	if m == 0 {
+		m = 1
+	}
+	var giant, prevGiant, step ecmPoint
+	c.mul(&step, &q, k.SetUint64(ecmStage2D))
+	c.mul(&giant, &q, k.SetUint64(m*ecmStage2D))
+	if m > 1 {
+		c.mul(&prevGiant, &q, k.SetUint64((m-1)*ecmStage2D))
+	}
+	acc := big.NewInt(1)
+	products := 0
+	for ; m*ecmStage2D < B2+ecmStage2D/2; m++ {
+		for j := uint64(1); j < ecmStage2D/2; j += 2 {
+			// Only j coprime to D give primes > B1.
+			if !isPrime[m*ecmStage2D+j] &&
+				!isPrime[m*ecmStage2D-j] {
+				continue
+			}
+			// X_g Z_j - X_j Z_g = 0 mod p iff the x
+			// coordinates match mod p.
+			t.Mul(&giant.X, &baby[j].Z)
+			u.Mul(&baby[j].X, &giant.Z)
+			t.Sub(&t, &u)
+			acc.Mul(acc, &t)
+			acc.Mod(acc, n)
+			products++
+			if products%ecmGCDInterval == 0 {
+				if d := getNontrivialGCD(acc, n); d != nil {
+					return d
+				}
+				if ctx.Err() != nil {
+					return nil
+				}
+			}
+		}
+		// (m + 1)Dq = mDq + Dq, with difference (m - 1)Dq.
+		var nextGiant ecmPoint
+		if m == 1 {
+			c.double(&nextGiant, &giant)
+		} else {
+			c.add(&nextGiant, &giant, &step, &prevGiant)
+		}
+		prevGiant = giant
+		giant = nextGiant
+	}
+	return getNontrivialGCD(acc, n)
+}
@@ -0,0 +1,90 @@
+package aks
+
+import "context"
+import "errors"
+import "math/big"
+import "testing"
+
+// ECM should find the factors of products of two primes quickly,
+// even when rho would take a while.
+func TestECM(t *testing.T) {
+	for _, factors := range [][]string{
+		{"1000000007", "1000000000000000003"},
+		{"99999999977", "2305843009213693951"},
+		{"10000000000037", "2305843009213693951"},
+	} {
+		n := mustMultiply(t, factors...)
+		d, err := ECM(context.Background(), n, &ECMOptions{Seed: 1})
+		if err != nil {
+			t.Fatal(n, err)
+		}
+		if d == nil || (d.String() != factors[0] &&
+			d.String() != factors[1]) {
+			t.Error(n, d)
+		}
+	}
+
+	// Even numbers don't need any curves.
+	d, err := ECM(context.Background(), big.NewInt(1<<20), nil)
+	if err != nil || d.Int64() != 2 {
+		t.Error(d, err)
+	}
+
+	for _, n := range []int64{-1, 0, 1, 2, 13, 1000003} {
+		if d, err := ECM(context.Background(), big.NewInt(n),
+			nil); !errors.Is(err, ErrBadInput) {
+			t.Error(n, d, err)
+		}
+	}
+}
+
+// ECM should stop if its context is cancelled.
+func TestECMCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	n := mustMultiply(t, "1000000007", "1000000000000000003")
+	if d, err := ECM(ctx, n, nil); d != nil || err != context.Canceled {
+		t.Error(d, err)
+	}
+}
+
+// factorize should fall back to ECM for factors too large for rho to
+// find within rhoMaxSteps.
+func TestFactorizeECM(t *testing.T) {
+	n := mustMultiply(t, "10000000000037", "2305843009213693951")
+	expected := "10000000000037^1 2305843009213693951^1 "
+	if s := getFactorString(n, factorize); s != expected {
+		t.Error(s)
+	}
+}
+
+// RunAKS with ECM set should find factors with ECM before searching
+// for AKS witnesses.
+func TestRunAKSECM(t *testing.T) {
+	n := mustMultiply(t, "1000000007", "1000000000000000003")
+	result, err := RunAKS(context.Background(), n, &AKSOptions{
+		ECM: &ECMOptions{},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Verdict != Composite || result.Method != MethodECM ||
+		result.Factor.Int64() != 1000000007 ||
+		result.Stats != nil {
+		t.Error(result.Verdict, result.Method, result.Factor)
+	}
+
+	// Primes should go on to the witness search.
+	n = new(big.Int).Lsh(big.NewInt(1), 127)
+	n.Sub(n, big.NewInt(1))
+	result, err = RunAKS(context.Background(), n, &AKSOptions{
+		Variant: AKSVariantConjecture,
+		ECM:     &ECMOptions{},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Verdict != Prime || result.Method != MethodAKSConjecture {
+		t.Error(result.Verdict, result.Method)
+	}
+}
@@ -0,0 +1,63 @@
+package ecpp
+
+import "math/big"
+
+// Returns u, v >= 0 with 4n = u^2 + |D| v^2 for the odd prime n and
+// the discriminant D < 0 (which must be a square mod n), using
+// Cornacchia's algorithm, or false if there are none. If such u and
+// v exist, the curves mod n with complex multiplication by the order
+// of discriminant D have n + 1 - t points for one of the traces t
+// returned by getTraces.
+func cornacchia(n *big.Int, D int64) (u, v *big.Int, ok bool) {
+	x := new(big.Int).Mod(big.NewInt(D), n)
+	if x.ModSqrt(x, n) == nil {
+		return nil, nil, false
+	}
+	// x must have the same parity as D.
+	if x.Bit(0) != uint(D&1) {
+		x.Sub(n, x)
+	}
+
+	fourN := new(big.Int).Lsh(n, 2)
+	limit := new(big.Int).Sqrt(fourN)
+	a := new(big.Int).Lsh(n, 1)
+	b := x
+	for b.Cmp(limit) > 0 {
+		a, b = b, a.Mod(a, b)
+	}
+
+	absD := big.NewInt(-D)
+	c := new(big.Int).Mul(b, b)
+	c.Sub(fourN, c)
+	var r big.Int
+	if c.QuoRem(c, absD, &r); r.Sign() != 0 {
+		return nil, nil, false
+	}
+	v = new(big.Int).Sqrt(c)
+	if r.Mul(v, v).Cmp(c) != 0 {
+		return nil, nil, false
+	}
+	return b, v, true
+}
+
+// Returns the possible traces t of the curves mod the prime n with
+// complex multiplication by the order of discriminant D, given the u
+// and v returned by cornacchia. D = -3 and D = -4 have six and four
+// twists, respectively, and all other discriminants have two.
+func getTraces(D int64, u, v *big.Int) []*big.Int {
+	traces := []*big.Int{new(big.Int).Set(u)}
+	switch D {
+	case -3:
+		threeV := new(big.Int).Mul(v, big.NewInt(3))
+		t := new(big.Int).Add(u, threeV)
+		traces = append(traces, t.Rsh(t, 1))
+		t = new(big.Int).Sub(u, threeV)
+		traces = append(traces, t.Rsh(t, 1))
+	case -4:
+		traces = append(traces, new(big.Int).Lsh(v, 1))
+	}
+	for _, t := range traces {
+		traces = append(traces, new(big.Int).Neg(t))
+	}
+	return traces
+}
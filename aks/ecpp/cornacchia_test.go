@@ -0,0 +1,51 @@
+package ecpp
+
+import "math/big"
+import "testing"
+
+// Returns whether 4n = u^2 + |D|v^2 for some u and v, by brute force.
+func hasNormSolution(n, D int64) bool {
+	for v := int64(0); -D*v*v <= 4*n; v++ {
+		uSq := 4*n + D*v*v
+		u := int64(0)
+		for u*u < uSq {
+			u++
+		}
+		if u*u == uSq {
+			return true
+		}
+	}
+	return false
+}
+
+// cornacchia should find u and v with 4n = u^2 + |D|v^2 exactly when
+// they exist, and the traces should satisfy Hasse's bound.
+func TestCornacchia(t *testing.T) {
+	for n := int64(5); n < 2000; n += 2 {
+		nBig := big.NewInt(n)
+		if !nBig.ProbablyPrime(20) {
+			continue
+		}
+		for _, D := range []int64{-3, -4, -7, -8, -15, -20, -23, -47} {
+			if big.Jacobi(big.NewInt(D), nBig) != 1 {
+				continue
+			}
+			u, v, ok := cornacchia(nBig, D)
+			if ok != hasNormSolution(n, D) {
+				t.Error(n, D, ok)
+				continue
+			}
+			if !ok {
+				continue
+			}
+			if u.Int64()*u.Int64()-D*v.Int64()*v.Int64() != 4*n {
+				t.Error(n, D, u, v)
+			}
+			for _, tr := range getTraces(D, u, v) {
+				if tr.Int64()*tr.Int64() > 4*n {
+					t.Error(n, D, tr)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package ecpp
+
+import "math/big"
+
+// Holds the elliptic curve y^2 = x^3 + ax + b over Z/nZ, where n
+// is coprime to 6. The arithmetic below works for composite n, too,
+// as long as no denominator shares a factor with n, and then agrees
+// with the arithmetic mod each prime factor of n.
+type curve struct {
+	a, b, n *big.Int
+}
+
+// Holds a point on a curve in affine coordinates, or the point at
+// infinity O if x and y are nil.
+type point struct {
+	x, y *big.Int
+}
+
+// Returns whether p is the point at infinity.
+func (p point) isInfinity() bool {
+	return p.x == nil
+}
+
+// Returns whether p is on c.
+func (c *curve) contains(p point) bool {
+	if p.isInfinity() {
+		return true
+	}
+	var lhs, rhs big.Int
+	lhs.Mul(p.y, p.y)
+	lhs.Mod(&lhs, c.n)
+	return lhs.Cmp(c.evaluate(p.x, &rhs)) == 0
+}
+
+// Sets z to x^3 + ax + b mod n and returns it.
+func (c *curve) evaluate(x, z *big.Int) *big.Int {
+	var t big.Int
+	t.Mul(x, x)
+	t.Add(&t, c.a)
+	t.Mul(&t, x)
+	z.Add(&t, c.b)
+	return z.Mod(z, c.n)
+}
+
+// Returns whether the discriminant 4a^3 + 27b^2 of c is invertible
+// mod n, i.e., whether c is an elliptic curve mod every prime factor
+// of n.
+func (c *curve) isNonsingular() bool {
+	var d, t big.Int
+	d.Exp(c.a, big.NewInt(3), c.n)
+	d.Mul(&d, big.NewInt(4))
+	t.Mul(c.b, c.b)
+	t.Mul(&t, big.NewInt(27))
+	d.Add(&d, &t)
+	return t.GCD(nil, nil, &d, c.n).Cmp(big.NewInt(1)) == 0
+}
+
+// Returns p + q on c, or the point at infinity and a non-trivial
+// factor of n if a denominator turns out not to be invertible.
+func (c *curve) add(p, q point) (point, *big.Int) {
+	if p.isInfinity() {
+		return q, nil
+	}
+	if q.isInfinity() {
+		return p, nil
+	}
+
+	var num, den big.Int
+	if p.x.Cmp(q.x) == 0 {
+		num.Add(p.y, q.y)
+		if num.Mod(&num, c.n).Sign() == 0 {
+			return point{}, nil
+		}
+		if p.y.Cmp(q.y) != 0 {
+			// y1^2 = y2^2 but y1 != +-y2, so n is
+			// composite.
+			num.Sub(p.y, q.y)
+			return point{}, num.GCD(nil, nil, &num, c.n)
+		}
+		// The tangent at p has slope (3x^2 + a)/2y.
+		num.Mul(p.x, p.x)
+		num.Mul(&num, big.NewInt(3))
+		num.Add(&num, c.a)
+		den.Lsh(p.y, 1)
+	} else {
+		num.Sub(q.y, p.y)
+		den.Sub(q.x, p.x)
+	}
+	den.Mod(&den, c.n)
+	if den.ModInverse(&den, c.n) == nil {
+		return point{}, den.GCD(nil, nil, &den, c.n)
+	}
+	lambda := num.Mul(&num, &den)
+	lambda.Mod(lambda, c.n)
+
+	x := new(big.Int).Mul(lambda, lambda)
+	x.Sub(x, p.x)
+	x.Sub(x, q.x)
+	x.Mod(x, c.n)
+	y := new(big.Int).Sub(p.x, x)
+	y.Mul(y, lambda)
+	y.Sub(y, p.y)
+	y.Mod(y, c.n)
+	return point{x, y}, nil
+}
+
+// Returns [k]p on c for k >= 0, or the point at infinity and a
+// non-trivial factor of n if a denominator turns out not to be
+// invertible.
+func (c *curve) mul(p point, k *big.Int) (point, *big.Int) {
+	r := point{}
+	for i := k.BitLen() - 1; i >= 0; i-- {
+		var factor *big.Int
+		if r, factor = c.add(r, r); factor != nil {
+			return point{}, factor
+		}
+		if k.Bit(i) != 0 {
+			if r, factor = c.add(r, p); factor != nil {
+				return point{}, factor
+			}
+		}
+	}
+	return r, nil
+}
@@ -0,0 +1,88 @@
+package ecpp
+
+import "math/big"
+import "testing"
+
+// Returns the points of c other than O by brute force.
+func getPoints(c *curve) []point {
+	var points []point
+	var rhs big.Int
+	p := c.n.Int64()
+	for x := int64(0); x < p; x++ {
+		c.evaluate(big.NewInt(x), &rhs)
+		for y := int64(0); y < p; y++ {
+			if y*y%p == rhs.Int64() {
+				points = append(points, point{
+					big.NewInt(x), big.NewInt(y),
+				})
+			}
+		}
+	}
+	return points
+}
+
+// Multiplying any point by the number of points on a curve mod a
+// prime should give O, and addition should be commutative and
+// associative.
+func TestCurveGroup(t *testing.T) {
+	c := curve{big.NewInt(2), big.NewInt(3), big.NewInt(101)}
+	if !c.isNonsingular() {
+		t.Fatal(c)
+	}
+	points := getPoints(&c)
+	order := big.NewInt(int64(len(points) + 1))
+	for i, p := range points {
+		if !c.contains(p) {
+			t.Error(p)
+		}
+		if q, factor := c.mul(p, order); !q.isInfinity() ||
+			factor != nil {
+			t.Error(p, q, factor)
+		}
+		q := points[(i*7)%len(points)]
+		r := points[(i*13)%len(points)]
+		pq, _ := c.add(p, q)
+		qp, _ := c.add(q, p)
+		if !c.contains(pq) || !equalPoints(pq, qp) {
+			t.Error(p, q, pq, qp)
+		}
+		pqr, _ := c.add(pq, r)
+		qr, _ := c.add(q, r)
+		pqr2, _ := c.add(p, qr)
+		if !equalPoints(pqr, pqr2) {
+			t.Error(p, q, r, pqr, pqr2)
+		}
+	}
+
+	singular := curve{big.NewInt(0), big.NewInt(0), big.NewInt(101)}
+	if singular.isNonsingular() {
+		t.Error(singular)
+	}
+}
+
+// Returns whether p and q are the same point.
+func equalPoints(p, q point) bool {
+	if p.isInfinity() || q.isInfinity() {
+		return p.isInfinity() == q.isInfinity()
+	}
+	return p.x.Cmp(q.x) == 0 && p.y.Cmp(q.y) == 0
+}
+
+// Multiplying a point mod n = pq by the number of points mod p should
+// find the factor p.
+func TestCurveMulFindsFactor(t *testing.T) {
+	a, b := big.NewInt(2), big.NewInt(3)
+	cp := curve{a, b, big.NewInt(101)}
+	order := big.NewInt(int64(len(getPoints(&cp)) + 1))
+
+	// (3, 6) is on y^2 = x^3 + 2x + 3 over the integers.
+	c := curve{a, b, big.NewInt(101 * 103)}
+	p := point{big.NewInt(3), big.NewInt(6)}
+	if !c.contains(p) {
+		t.Fatal(p)
+	}
+	q, factor := c.mul(p, order)
+	if !q.isInfinity() || factor == nil || factor.Int64() != 101 {
+		t.Error(q, factor)
+	}
+}
@@ -0,0 +1,473 @@
+// Package ecpp proves numbers prime with elliptic curve primality
+// proving (ECPP), as described by Atkin and Morain. Unlike AKS, which
+// is impractical above a few dozen digits, ECPP proves primes of
+// hundreds of digits in seconds to minutes, and its certificates can
+// be checked much faster than they were found.
+//
+// ECPP rests on the Goldwasser-Kilian theorem: if E is an elliptic
+// curve mod N with gcd(N, 6) = 1, m = kq for a prime q > (N^(1/4) +
+// 1)^2, and P is a point on E with [k]P != O and [q]([k]P) = O, then
+// N is prime. Prove finds such a curve for N by picking a
+// discriminant D for which 4N = u^2 + |D|v^2, so that some curve with
+// complex multiplication by D has N + 1 +- u points (or a few other
+// counts for D = -3 and -4), and building that curve from a root of
+// the Hilbert class polynomial of D mod N. It then repeats that for q
+// until the numbers are below 2^64, where aks.IsPrimeUint64 decides.
+package ecpp
+
+import "github.com/akalin/aks-go/aks"
+import "context"
+import "encoding/json"
+import "fmt"
+import "io"
+import "math/big"
+import "math/rand"
+import "sync"
+
+// The seed for the random choices Prove makes, which is fixed so that
+// proofs are reproducible.
+const proveSeed = 1
+
+// The number of random points tried on each curve before deciding it
+// doesn't have the wanted number of points.
+const maxPointTries = 8
+
+// The number of random x-coordinates tried when looking for a point
+// on a curve. Half of them work for prime N.
+const maxXTries = 100
+
+// The cofactor k of a curve order m = kq must factor over the primes
+// below this bound.
+const smallPrimeBound = 1 << 20
+
+// A Step shows that N is prime if Q is, by the Goldwasser-Kilian
+// theorem applied to the curve y^2 = x^3 + Ax + B mod N and the point
+// (X, Y) on it.
+type Step struct {
+	N *big.Int
+	// The discriminant the curve was built from. It isn't needed
+	// to verify the step.
+	D    int64
+	A, B *big.Int
+	// The number of points on the curve if N is prime, which is
+	// a multiple of Q.
+	M *big.Int
+	Q *big.Int
+	X *big.Int
+	Y *big.Int
+}
+
+// A Certificate proves N prime with a chain of Steps, the first for
+// N, and each after that for the Q of the one before. The Q of the
+// last step is below 2^64, and is prime as decided by
+// aks.IsPrimeUint64. Steps is empty if N is itself below 2^64.
+type Certificate struct {
+	N     *big.Int
+	Steps []Step
+}
+
+// Returns a certificate proving n prime with ECPP. Returns an error
+// wrapping aks.ErrComposite if n turns out to be composite,
+// aks.ErrProofNotFound if no usable curve is found for some number in
+// the chain (which is rare), or aks.ErrBadInput if n < 2.
+func Prove(n *big.Int) (*Certificate, error) {
+	return ProveContext(context.Background(), n)
+}
+
+// Like Prove, but returns nil and ctx.Err() if ctx is cancelled
+// before the proof is done.
+func ProveContext(ctx context.Context, n *big.Int) (*Certificate, error) {
+	if n.Cmp(big.NewInt(2)) < 0 {
+		return nil, fmt.Errorf(
+			"%w: n = %v must be >= 2", aks.ErrBadInput, n)
+	}
+	// This also makes sure n is prime before the steps below,
+	// some of which (like big.Int.ModSqrt) assume it.
+	if isPrime, _ := aks.BailliePSW(n); !isPrime {
+		return nil, fmt.Errorf("%w: n = %v", aks.ErrComposite, n)
+	}
+	c := &Certificate{N: new(big.Int).Set(n)}
+	rnd := rand.New(rand.NewSource(proveSeed))
+	for N := c.N; !N.IsUint64(); N = c.Steps[len(c.Steps)-1].Q {
+		step, err := proveStep(ctx, N, rnd)
+		if err != nil {
+			return nil, err
+		}
+		c.Steps = append(c.Steps, *step)
+	}
+	return c, nil
+}
+
+// Returns (floor(n^(1/4)) + 2)^2, which is at least (n^(1/4) + 1)^2,
+// so that a prime q above it is large enough for the Goldwasser-Kilian
+// theorem.
+func getMinQ(n *big.Int) *big.Int {
+	r := new(big.Int).Sqrt(n)
+	r.Sqrt(r)
+	r.Add(r, big.NewInt(2))
+	return r.Mul(r, r)
+}
+
+// Returns a step for the prime n >= 2^64 whose Q is a probable prime
+// (as decided by aks.BailliePSW) less than n, trying the
+// discriminants from getDiscriminants in order.
+func proveStep(ctx context.Context, n *big.Int, rnd *rand.Rand) (
+	*Step, error) {
+	minQ := getMinQ(n)
+	nPlusOne := new(big.Int).Add(n, big.NewInt(1))
+	ds := getDiscriminants()
+	for i := range ds {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		d := &ds[i]
+		if big.Jacobi(big.NewInt(d.D), n) != 1 {
+			continue
+		}
+		u, v, ok := cornacchia(n, d.D)
+		if !ok {
+			continue
+		}
+		// The j-invariant is only computed if some order works.
+		var j *big.Int
+		for _, t := range getTraces(d.D, u, v) {
+			m := new(big.Int).Sub(nPlusOne, t)
+			q := removeSmallFactors(m)
+			if q.Cmp(m) == 0 || q.Cmp(minQ) <= 0 {
+				continue
+			}
+			if isPrime, _ := aks.BailliePSW(q); !isPrime {
+				continue
+			}
+			if j == nil {
+				if j = findJInvariant(n, d, rnd); j == nil {
+					break
+				}
+			}
+			step, err := findCurve(n, d.D, j, m, q, rnd)
+			if err != nil {
+				return nil, err
+			}
+			if step != nil {
+				return step, nil
+			}
+		}
+	}
+	return nil, fmt.Errorf(
+		"%w: no discriminant up to %d gave a usable curve for n = %v",
+		aks.ErrProofNotFound, maxDiscriminant, n)
+}
+
+var smallPrimesOnce sync.Once
+var smallPrimes []uint64
+
+// Returns the primes below smallPrimeBound.
+func getSmallPrimes() []uint64 {
+	smallPrimesOnce.Do(func() {
+		isComposite := make([]bool, smallPrimeBound)
+		for i := uint64(2); i < smallPrimeBound; i++ {
+			if isComposite[i] {
+				continue
+			}
+			smallPrimes = append(smallPrimes, i)
+			for j := i * i; j < smallPrimeBound; j += i {
+				isComposite[j] = true
+			}
+		}
+	})
+	return smallPrimes
+}
+
+// Returns m > 0 with all its prime factors below smallPrimeBound
+// divided out.
+func removeSmallFactors(m *big.Int) *big.Int {
+	q := new(big.Int).Set(m)
+	primes := getSmallPrimes()
+	var x, r, quo big.Int
+	// Divide by products of primes that fit in a uint64 at a time,
+	// and then only by the primes whose product left a remainder
+	// with a common factor.
+	for i := 0; i < len(primes); {
+		product := uint64(1)
+		start := i
+		for ; i < len(primes) && product <= (1<<64-1)/primes[i]; i++ {
+			product *= primes[i]
+		}
+		rem := r.Rem(q, x.SetUint64(product)).Uint64()
+		for _, p := range primes[start:i] {
+			if rem%p != 0 {
+				continue
+			}
+			x.SetUint64(p)
+			for {
+				quo.QuoRem(q, &x, &r)
+				if r.Sign() != 0 {
+					break
+				}
+				q.Set(&quo)
+			}
+		}
+	}
+	return q
+}
+
+// Returns a root mod n of the Hilbert class polynomial of d, i.e.,
+// the j-invariant of a curve mod n with complex multiplication by the
+// order of discriminant d.D, or nil if none is found, which can only
+// happen if the polynomial has repeated roots mod n.
+func findJInvariant(n *big.Int, d *discriminant, rnd *rand.Rand) *big.Int {
+	H := newPolyMod(getHilbertClassPolynomial(d), n)
+	return findRoot(H, n, rnd)
+}
+
+// Returns the smallest g >= 2 that is a quadratic non-residue mod the
+// prime n, and also not a cubic residue if cubic is set, which needs n
+// = 1 (mod 3).
+func findNonResidue(n *big.Int, cubic bool) *big.Int {
+	var e, x big.Int
+	e.Sub(n, big.NewInt(1))
+	e.Quo(&e, big.NewInt(3))
+	for g := int64(2); ; g++ {
+		gBig := big.NewInt(g)
+		if big.Jacobi(gBig, n) != -1 {
+			continue
+		}
+		if cubic && x.Exp(gBig, &e, n).Cmp(big.NewInt(1)) == 0 {
+			continue
+		}
+		return gBig
+	}
+}
+
+// Returns the twists mod the prime n of the curve with the
+// j-invariant j and complex multiplication by the order of
+// discriminant D, one for each of the traces from getTraces.
+func getTwists(n, j *big.Int, D int64) []curve {
+	var curves []curve
+	switch D {
+	case -3:
+		// y^2 = x^3 + b for b in the six classes of
+		// (Z/nZ)*/((Z/nZ)*)^6.
+		g := findNonResidue(n, true)
+		b := big.NewInt(1)
+		for i := 0; i < 6; i++ {
+			curves = append(curves, curve{big.NewInt(0), b, n})
+			b = new(big.Int).Mul(b, g)
+			b.Mod(b, n)
+		}
+	case -4:
+		// y^2 = x^3 + ax for a in the four classes of
+		// (Z/nZ)*/((Z/nZ)*)^4.
+		g := findNonResidue(n, false)
+		a := big.NewInt(1)
+		for i := 0; i < 4; i++ {
+			curves = append(curves, curve{a, big.NewInt(0), n})
+			a = new(big.Int).Mul(a, g)
+			a.Mod(a, n)
+		}
+	default:
+		// y^2 = x^3 + 3kx + 2k for k = j/(1728 - j) has
+		// j-invariant j, and multiplying a by c^2 and b by c^3
+		// for a non-residue c gives its quadratic twist.
+		k := new(big.Int).Sub(big.NewInt(1728), j)
+		if k.ModInverse(k, n) == nil {
+			return nil
+		}
+		k.Mul(k, j)
+		k.Mod(k, n)
+		a := new(big.Int).Mul(k, big.NewInt(3))
+		a.Mod(a, n)
+		b := new(big.Int).Lsh(k, 1)
+		b.Mod(b, n)
+		curves = append(curves, curve{a, b, n})
+
+		c := findNonResidue(n, false)
+		cSq := new(big.Int).Mul(c, c)
+		a = new(big.Int).Mul(a, cSq)
+		a.Mod(a, n)
+		b = new(big.Int).Mul(b, cSq)
+		b.Mul(b, c)
+		b.Mod(b, n)
+		curves = append(curves, curve{a, b, n})
+	}
+	return curves
+}
+
+// Returns a random point other than O on c, whose n must be prime.
+// Returns an error wrapping aks.ErrComposite if n turns out to be
+// composite.
+func findRandomPoint(c *curve, rnd *rand.Rand) (point, error) {
+	var rhs big.Int
+	for tries := 0; tries < maxXTries; tries++ {
+		x := new(big.Int).Rand(rnd, c.n)
+		c.evaluate(x, &rhs)
+		if big.Jacobi(&rhs, c.n) != 1 {
+			continue
+		}
+		y := new(big.Int).ModSqrt(&rhs, c.n)
+		p := point{x, y}
+		if y == nil || !c.contains(p) {
+			break
+		}
+		return p, nil
+	}
+	return point{}, fmt.Errorf(
+		"%w: no point found on y^2 = x^3 + %vx + %v mod n = %v",
+		aks.ErrComposite, c.a, c.b, c.n)
+}
+
+// Returns a step for n with the given m = kq, built from the twist of
+// the curve with j-invariant j that has m points, or nil if none of
+// them seem to. Returns an error wrapping aks.ErrComposite if n turns
+// out to be composite.
+func findCurve(n *big.Int, D int64, j, m, q *big.Int, rnd *rand.Rand) (
+	*Step, error) {
+	k := new(big.Int).Quo(m, q)
+	for _, c := range getTwists(n, j, D) {
+		if !c.isNonsingular() {
+			continue
+		}
+		for tries := 0; tries < maxPointTries; tries++ {
+			p, err := findRandomPoint(&c, rnd)
+			if err != nil {
+				return nil, err
+			}
+			kp, factor := c.mul(p, k)
+			if factor == nil && kp.isInfinity() {
+				// Unlucky; try another point.
+				continue
+			}
+			var mp point
+			if factor == nil {
+				mp, factor = c.mul(kp, q)
+			}
+			if factor != nil {
+				return nil, fmt.Errorf(
+					"%w: n = %v has factor %v",
+					aks.ErrComposite, n, factor)
+			}
+			if !mp.isInfinity() {
+				// This twist has some other number
+				// of points.
+				break
+			}
+			return &Step{
+				N: new(big.Int).Set(n),
+				D: D,
+				A: c.a,
+				B: c.b,
+				M: m,
+				Q: q,
+				X: p.x,
+				Y: p.y,
+			}, nil
+		}
+	}
+	return nil, nil
+}
+
+// Returns nil if c proves c.N prime, and an error wrapping
+// aks.ErrInvalidCertificate otherwise.
+func (c *Certificate) Verify() error {
+	if c.N == nil || c.N.Cmp(big.NewInt(2)) < 0 {
+		return fmt.Errorf(
+			"%w: N = %v must be >= 2",
+			aks.ErrInvalidCertificate, c.N)
+	}
+	N := c.N
+	for i := range c.Steps {
+		s := &c.Steps[i]
+		if s.N == nil || s.N.Cmp(N) != 0 {
+			return fmt.Errorf(
+				"%w: step %d is for %v instead of %v",
+				aks.ErrInvalidCertificate, i, s.N, N)
+		}
+		if err := s.Verify(); err != nil {
+			return err
+		}
+		N = s.Q
+	}
+	if !N.IsUint64() || !aks.IsPrimeUint64(N.Uint64()) {
+		return fmt.Errorf(
+			"%w: the last number %v isn't a prime below 2^64",
+			aks.ErrInvalidCertificate, N)
+	}
+	return nil
+}
+
+// Returns nil if s shows that s.N is prime if s.Q is, and an error
+// wrapping aks.ErrInvalidCertificate otherwise.
+func (s *Step) Verify() error {
+	for _, x := range []*big.Int{s.N, s.A, s.B, s.M, s.Q, s.X, s.Y} {
+		if x == nil {
+			return fmt.Errorf(
+				"%w: N, A, B, M, Q, X, and Y must be set",
+				aks.ErrInvalidCertificate)
+		}
+	}
+	var g big.Int
+	if s.N.Cmp(big.NewInt(5)) < 0 ||
+		g.GCD(nil, nil, s.N, big.NewInt(6)).Cmp(big.NewInt(1)) != 0 {
+		return fmt.Errorf(
+			"%w: N = %v must be >= 5 and coprime to 6",
+			aks.ErrInvalidCertificate, s.N)
+	}
+	for _, x := range []*big.Int{s.A, s.B, s.X, s.Y} {
+		if x.Sign() < 0 || x.Cmp(s.N) >= 0 {
+			return fmt.Errorf(
+				"%w: A, B, X, and Y must be in [0, N = %v)",
+				aks.ErrInvalidCertificate, s.N)
+		}
+	}
+
+	c := curve{s.A, s.B, s.N}
+	p := point{s.X, s.Y}
+	if !c.isNonsingular() {
+		return fmt.Errorf(
+			"%w: y^2 = x^3 + %vx + %v is singular mod N = %v",
+			aks.ErrInvalidCertificate, s.A, s.B, s.N)
+	}
+	if !c.contains(p) {
+		return fmt.Errorf(
+			"%w: (%v, %v) isn't on y^2 = x^3 + %vx + %v mod N = %v",
+			aks.ErrInvalidCertificate, s.X, s.Y, s.A, s.B, s.N)
+	}
+
+	if s.Q.Cmp(getMinQ(s.N)) <= 0 {
+		return fmt.Errorf(
+			"%w: Q = %v isn't above (floor(N^(1/4)) + 2)^2 "+
+				"for N = %v",
+			aks.ErrInvalidCertificate, s.Q, s.N)
+	}
+	var k, r big.Int
+	k.QuoRem(s.M, s.Q, &r)
+	if s.M.Sign() <= 0 || r.Sign() != 0 {
+		return fmt.Errorf(
+			"%w: Q = %v doesn't divide M = %v",
+			aks.ErrInvalidCertificate, s.Q, s.M)
+	}
+
+	kp, factor := c.mul(p, &k)
+	if factor != nil || kp.isInfinity() {
+		return fmt.Errorf(
+			"%w: [M/Q](X, Y) = O mod some factor of N = %v",
+			aks.ErrInvalidCertificate, s.N)
+	}
+	mp, factor := c.mul(kp, s.Q)
+	if factor != nil || !mp.isInfinity() {
+		return fmt.Errorf(
+			"%w: [M](X, Y) != O mod N = %v",
+			aks.ErrInvalidCertificate, s.N)
+	}
+	return nil
+}
+
+// Writes c to w as indented JSON.
+func (c *Certificate) WriteJSON(w io.Writer) error {
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(data, '\n'))
+	return err
+}
@@ -0,0 +1,175 @@
+package ecpp
+
+import "github.com/akalin/aks-go/aks"
+import "bytes"
+import "context"
+import "encoding/json"
+import "errors"
+import "math/big"
+import "testing"
+
+// Returns the smallest prime >= n.
+func nextPrime(n *big.Int) *big.Int {
+	p := new(big.Int).Set(n)
+	for !p.ProbablyPrime(20) {
+		p.Add(p, big.NewInt(1))
+	}
+	return p
+}
+
+// Returns the smallest prime >= 2^k.
+func nextPrimeAfterPowerOfTwo(k uint) *big.Int {
+	return nextPrime(new(big.Int).Lsh(big.NewInt(1), k))
+}
+
+// Prove should prove primes of various sizes, and its certificates
+// should verify and have steps with decreasing Q.
+func TestProve(t *testing.T) {
+	m127 := new(big.Int).Lsh(big.NewInt(1), 127)
+	m127.Sub(m127, big.NewInt(1))
+	for _, n := range []*big.Int{
+		big.NewInt(2), big.NewInt(1000003),
+		nextPrimeAfterPowerOfTwo(64), m127,
+		nextPrimeAfterPowerOfTwo(200),
+		nextPrimeAfterPowerOfTwo(300),
+	} {
+		c, err := Prove(n)
+		if err != nil {
+			t.Fatal(n, err)
+		}
+		if c.N.Cmp(n) != 0 {
+			t.Error(n, c.N)
+		}
+		if err := c.Verify(); err != nil {
+			t.Error(n, err)
+		}
+		if n.IsUint64() != (len(c.Steps) == 0) {
+			t.Error(n, len(c.Steps))
+		}
+		for _, s := range c.Steps {
+			if s.Q.Cmp(s.N) >= 0 {
+				t.Error(n, s.N, s.Q)
+			}
+		}
+	}
+}
+
+// Prove should reject composites and bad input, and stop when its
+// context is cancelled.
+func TestProveErrors(t *testing.T) {
+	f7 := new(big.Int).Lsh(big.NewInt(1), 128)
+	f7.Add(f7, big.NewInt(1))
+	semiprime := new(big.Int).Mul(
+		nextPrimeAfterPowerOfTwo(100), nextPrimeAfterPowerOfTwo(101))
+	for _, n := range []*big.Int{big.NewInt(561), f7, semiprime} {
+		if c, err := Prove(n); !errors.Is(err, aks.ErrComposite) {
+			t.Error(n, c, err)
+		}
+	}
+	for _, n := range []*big.Int{big.NewInt(-1), big.NewInt(1)} {
+		if c, err := Prove(n); !errors.Is(err, aks.ErrBadInput) {
+			t.Error(n, c, err)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	n := nextPrimeAfterPowerOfTwo(128)
+	if c, err := ProveContext(ctx, n); err != context.Canceled {
+		t.Error(c, err)
+	}
+}
+
+// removeSmallFactors should divide out exactly the prime factors below
+// smallPrimeBound.
+func TestRemoveSmallFactors(t *testing.T) {
+	p := nextPrimeAfterPowerOfTwo(80)
+	m := new(big.Int).Mul(p, big.NewInt(2*2*3*1000003))
+	m.Mul(m, big.NewInt(smallPrimeBound-3))
+	if q := removeSmallFactors(m); q.Cmp(p) != 0 {
+		t.Error(m, q, p)
+	}
+
+	// 1048583 is the smallest prime above smallPrimeBound.
+	m.Mul(p, big.NewInt(1048583*1048583))
+	m.Mul(m, big.NewInt(7))
+	q := removeSmallFactors(m)
+	if q.Quo(q, p).Cmp(big.NewInt(1048583*1048583)) != 0 {
+		t.Error(m, q)
+	}
+}
+
+// Tampering with a certificate should make it fail verification, and
+// it should survive a round trip through JSON.
+func TestCertificateVerify(t *testing.T) {
+	c, err := Prove(nextPrimeAfterPowerOfTwo(128))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := c.WriteJSON(&buf); err != nil {
+		t.Fatal(err)
+	}
+	var decoded Certificate
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if err := decoded.Verify(); err != nil {
+		t.Error(err)
+	}
+
+	tamperings := []func(c *Certificate){
+		func(c *Certificate) { c.N.Add(c.N, big.NewInt(2)) },
+		func(c *Certificate) { c.N = nil },
+		func(c *Certificate) { c.Steps = c.Steps[:len(c.Steps)-1] },
+		func(c *Certificate) { c.Steps = c.Steps[1:] },
+		func(c *Certificate) {
+			s := &c.Steps[0]
+			s.A.Add(s.A, big.NewInt(1))
+		},
+		func(c *Certificate) {
+			s := &c.Steps[0]
+			s.X.Add(s.X, big.NewInt(1))
+		},
+		func(c *Certificate) { c.Steps[0].Y.Neg(c.Steps[0].Y) },
+		func(c *Certificate) { c.Steps[0].Y = nil },
+		func(c *Certificate) {
+			s := &c.Steps[0]
+			s.M.Add(s.M, s.Q)
+		},
+		func(c *Certificate) {
+			s := &c.Steps[0]
+			s.M.Add(s.M, big.NewInt(1))
+		},
+		func(c *Certificate) {
+			// M = Q with [M/Q](X, Y) = (X, Y) != O and [Q](X,
+			// Y) != O.
+			c.Steps[0].M.Set(c.Steps[0].Q)
+		},
+		func(c *Certificate) {
+			// Q = 2M with [Q](X, Y) = O, but Q isn't prime.
+			c.Steps[0].Q.Set(c.Steps[0].M)
+		},
+		func(c *Certificate) {
+			// A small Q dividing M.
+			s := &c.Steps[0]
+			s.Q.Quo(s.M, s.Q)
+		},
+		func(c *Certificate) {
+			last := &c.Steps[len(c.Steps)-1]
+			last.Q.Add(last.Q, big.NewInt(2))
+		},
+	}
+	for i, tamper := range tamperings {
+		var tampered Certificate
+		if err := json.Unmarshal(buf.Bytes(), &tampered); err != nil {
+			t.Fatal(err)
+		}
+		tamper(&tampered)
+		if err := tampered.Verify(); !errors.Is(
+			err, aks.ErrInvalidCertificate) {
+			t.Error(i, err)
+		}
+	}
+}
@@ -0,0 +1,425 @@
+package ecpp
+
+import "fmt"
+import "math"
+import "math/big"
+import "sort"
+import "sync"
+
+// The largest |D| of the discriminants D that Prove tries. Larger
+// discriminants give more chances of finding a curve with a usable
+// order, but have larger class numbers and so take longer.
+const maxDiscriminant = 5000
+
+// Holds the binary quadratic form ax^2 + bxy + cy^2.
+type form struct {
+	a, b, c int64
+}
+
+// Holds a fundamental discriminant D < 0 and the reduced forms of
+// discriminant D, one for each element of the class group.
+type discriminant struct {
+	D     int64
+	forms []form
+}
+
+// Returns the class number of d.
+func (d *discriminant) classNumber() int {
+	return len(d.forms)
+}
+
+var discriminantsOnce sync.Once
+var discriminants []discriminant
+
+// Returns the fundamental discriminants D with |D| <= maxDiscriminant
+// in ascending order of class number, and then of |D|.
+func getDiscriminants() []discriminant {
+	discriminantsOnce.Do(func() {
+		for D := int64(-3); D >= -maxDiscriminant; D-- {
+			if !isFundamentalDiscriminant(D) {
+				continue
+			}
+			discriminants = append(discriminants, discriminant{
+				D:     D,
+				forms: getReducedForms(D),
+			})
+		}
+		sort.SliceStable(discriminants, func(i, j int) bool {
+			return discriminants[i].classNumber() <
+				discriminants[j].classNumber()
+		})
+	})
+	return discriminants
+}
+
+// Returns whether n > 0 has no square factors other than 1.
+func isSquarefree(n int64) bool {
+	for p := int64(2); p*p <= n; p++ {
+		if n%(p*p) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// Returns whether D < 0 is a fundamental discriminant, i.e., either D
+// = 1 (mod 4) and squarefree, or D = 4m with m = 2 or 3 (mod 4) and
+// squarefree.
+func isFundamentalDiscriminant(D int64) bool {
+	switch {
+	case -D%4 == 3:
+		return isSquarefree(-D)
+	case -D%4 == 0:
+		m := -D / 4
+		return (m%4 == 1 || m%4 == 2) && isSquarefree(m)
+	}
+	return false
+}
+
+// Returns the gcd of the non-negative a and b.
+func gcdInt64(a, b int64) int64 {
+	for b != 0 {
+		a, b = b, a%b
+	}
+	return a
+}
+
+// Returns the primitive reduced forms (a, b, c) of discriminant D =
+// b^2 - 4ac < 0, i.e., those with |b| <= a <= c and b >= 0 if |b| = a
+// or a = c.
+func getReducedForms(D int64) []form {
+	var forms []form
+	for a := int64(1); 3*a*a <= -D; a++ {
+		for b := -a + 1; b <= a; b++ {
+			num := b*b - D
+			if num%(4*a) != 0 {
+				continue
+			}
+			c := num / (4 * a)
+			if c < a || (b < 0 && a == c) {
+				continue
+			}
+			if b < 0 {
+				if gcdInt64(gcdInt64(a, -b), c) != 1 {
+					continue
+				}
+			} else if gcdInt64(gcdInt64(a, b), c) != 1 {
+				continue
+			}
+			forms = append(forms, form{a, b, c})
+		}
+	}
+	return forms
+}
+
+var hilbertCacheLock sync.Mutex
+var hilbertCache = make(map[int64][]*big.Int)
+
+// Returns the coefficients of the Hilbert class polynomial of d, in
+// ascending order of degree, i.e., the monic polynomial whose roots
+// are the j-invariants j((-b + sqrt(D))/2a) of the reduced forms (a,
+// b, c) of d. It is computed numerically the first time it is needed
+// and cached after that.
+func getHilbertClassPolynomial(d *discriminant) []*big.Int {
+	hilbertCacheLock.Lock()
+	H, ok := hilbertCache[d.D]
+	hilbertCacheLock.Unlock()
+	if ok {
+		return H
+	}
+
+	// |j(tau)| is about e^(pi sqrt(|D|)/a), so that's about how
+	// many bits each root contributes to the coefficients.
+	bits := 64.0
+	for _, f := range d.forms {
+		bits += math.Pi*math.Sqrt(float64(-d.D))/float64(f.a)/
+			math.Ln2 + 2
+	}
+	// The precision estimate should be enough, but retry with more
+	// if the coefficients don't come out close to integers.
+	for prec := uint(bits) + 64; ; prec *= 2 {
+		H, ok = computeHilbertClassPolynomial(d, prec)
+		if ok {
+			break
+		}
+	}
+
+	hilbertCacheLock.Lock()
+	hilbertCache[d.D] = H
+	hilbertCacheLock.Unlock()
+	return H
+}
+
+// Computes the Hilbert class polynomial of d with prec bits of
+// precision. Returns false if the computed coefficients aren't close
+// enough to integers for prec to have been enough.
+func computeHilbertClassPolynomial(
+	d *discriminant, prec uint) ([]*big.Int, bool) {
+	pi := computePi(prec)
+	// Start with the constant polynomial 1 and multiply in x - j
+	// for each root j.
+	coeffs := []complexFloat{newComplexFloat(prec).setInt64(1)}
+	for _, f := range d.forms {
+		j := computeJ(f, d.D, pi, prec)
+		next := make([]complexFloat, len(coeffs)+1)
+		for i := range next {
+			next[i] = newComplexFloat(prec)
+		}
+		t := newComplexFloat(prec)
+		for i, c := range coeffs {
+			next[i+1].add(next[i+1], c)
+			next[i].sub(next[i], t.mul(c, j))
+		}
+		coeffs = next
+	}
+
+	quarter := big.NewFloat(0.25)
+	H := make([]*big.Int, len(coeffs))
+	var r, diff big.Float
+	for i, c := range coeffs {
+		if diff.Abs(c.im).Cmp(quarter) >= 0 {
+			return nil, false
+		}
+		r.SetPrec(prec).Add(c.re, roundingOffset(c.re))
+		H[i], _ = r.Int(nil)
+		diff.SetPrec(prec).SetInt(H[i])
+		diff.Sub(&diff, c.re)
+		if diff.Abs(&diff).Cmp(quarter) >= 0 {
+			return nil, false
+		}
+	}
+	return H, true
+}
+
+// Returns 1/2 with the sign of x, so that truncating x plus it rounds
+// x to the nearest integer.
+func roundingOffset(x *big.Float) *big.Float {
+	if x.Sign() < 0 {
+		return big.NewFloat(-0.5)
+	}
+	return big.NewFloat(0.5)
+}
+
+// Returns j(tau) for tau = (-b + sqrt(D))/2a with prec bits of
+// precision, where (a, b, c) is a reduced form of discriminant D and
+// pi is pi to at least prec bits, as E4(q)^3 / Delta(q) for q =
+// e^(2 pi i tau).
+func computeJ(f form, D int64, pi *big.Float, prec uint) complexFloat {
+	// 2 pi i tau = -pi sqrt(|D|)/a - (pi b/a) i.
+	w := newComplexFloat(prec)
+	w.re.SetInt64(-D)
+	w.re.Sqrt(w.re)
+	w.re.Mul(w.re, pi)
+	w.re.Quo(w.re, newFloat(prec).SetInt64(f.a))
+	w.re.Neg(w.re)
+	w.im.SetInt64(-f.b)
+	w.im.Mul(w.im, pi)
+	w.im.Quo(w.im, newFloat(prec).SetInt64(f.a))
+	q := expComplex(w, prec)
+
+	// |q|^n drops below 2^-prec after this many terms.
+	logQ := math.Pi * math.Sqrt(float64(-D)) / float64(f.a)
+	terms := int(float64(prec)*math.Ln2/logQ) + 2
+	powers := make([]complexFloat, terms+1)
+	powers[0] = newComplexFloat(prec).setInt64(1)
+	for n := 1; n <= terms; n++ {
+		powers[n] = newComplexFloat(prec).mul(powers[n-1], q)
+	}
+
+	// E4(q) = 1 + 240 sum_{n >= 1} sigma_3(n) q^n.
+	sigma3 := make([]int64, terms+1)
+	for d := int64(1); d <= int64(terms); d++ {
+		for n := d; n <= int64(terms); n += d {
+			sigma3[n] += d * d * d
+		}
+	}
+	E4 := newComplexFloat(prec)
+	t := newComplexFloat(prec)
+	for n := 1; n <= terms; n++ {
+		E4.add(E4, t.mulFloat(powers[n],
+			newFloat(prec).SetInt64(sigma3[n])))
+	}
+	E4.mulFloat(E4, newFloat(prec).SetInt64(240))
+	E4.re.Add(E4.re, newFloat(prec).SetInt64(1))
+
+	// prod_{n >= 1} (1 - q^n) = 1 + sum_{k >= 1} (-1)^k (q^(k(3k -
+	// 1)/2) + q^(k(3k + 1)/2)) by the pentagonal number theorem.
+	P := newComplexFloat(prec).setInt64(1)
+	for k := 1; k*(3*k-1)/2 <= terms; k++ {
+		t.set(powers[k*(3*k-1)/2])
+		if k*(3*k+1)/2 <= terms {
+			t.add(t, powers[k*(3*k+1)/2])
+		}
+		if k%2 == 1 {
+			P.sub(P, t)
+		} else {
+			P.add(P, t)
+		}
+	}
+
+	// Delta(q) = q prod_{n >= 1} (1 - q^n)^24.
+	P2 := newComplexFloat(prec).mul(P, P)
+	P4 := newComplexFloat(prec).mul(P2, P2)
+	P8 := newComplexFloat(prec).mul(P4, P4)
+	P16 := newComplexFloat(prec).mul(P8, P8)
+	delta := newComplexFloat(prec).mul(P16, P8)
+	delta.mul(delta, q)
+
+	E4Cubed := newComplexFloat(prec).mul(E4, E4)
+	E4Cubed.mul(E4Cubed, E4)
+	return E4Cubed.quo(E4Cubed, delta)
+}
+
+// Returns a zero big.Float with the given precision.
+func newFloat(prec uint) *big.Float {
+	return new(big.Float).SetPrec(prec)
+}
+
+// Returns whether |x| < 2^-prec.
+func isNegligible(x *big.Float, prec uint) bool {
+	return x.Sign() == 0 || x.MantExp(nil) < -int(prec)
+}
+
+// Returns arctan(1/x) for x > 1 with prec bits of precision, from its
+// Taylor series sum_{k >= 0} (-1)^k / ((2k + 1) x^(2k + 1)).
+func arctanInverse(x int64, prec uint) *big.Float {
+	sum := newFloat(prec)
+	power := newFloat(prec).SetInt64(1)
+	xFloat := newFloat(prec).SetInt64(x)
+	xSq := newFloat(prec).SetInt64(x * x)
+	power.Quo(power, xFloat)
+	term := newFloat(prec)
+	for k := int64(0); !isNegligible(power, prec); k++ {
+		term.Quo(power, newFloat(prec).SetInt64(2*k+1))
+		if k%2 == 0 {
+			sum.Add(sum, term)
+		} else {
+			sum.Sub(sum, term)
+		}
+		power.Quo(power, xSq)
+	}
+	return sum
+}
+
+// Returns pi with prec bits of precision, using Machin's formula pi =
+// 16 arctan(1/5) - 4 arctan(1/239).
+func computePi(prec uint) *big.Float {
+	workPrec := prec + 32
+	pi := arctanInverse(5, workPrec)
+	pi.Mul(pi, newFloat(workPrec).SetInt64(16))
+	t := arctanInverse(239, workPrec)
+	t.Mul(t, newFloat(workPrec).SetInt64(4))
+	pi.Sub(pi, t)
+	return pi.SetPrec(prec)
+}
+
+// Holds a complex number re + im*i with big.Float components. The
+// methods set their receiver, like those of big.Float, and return it.
+type complexFloat struct {
+	re, im *big.Float
+}
+
+// Returns a zero complexFloat with the given precision.
+func newComplexFloat(prec uint) complexFloat {
+	return complexFloat{newFloat(prec), newFloat(prec)}
+}
+
+// fmt.Stringer implementation.
+func (z complexFloat) String() string {
+	return fmt.Sprintf("%g + %gi", z.re, z.im)
+}
+
+func (z complexFloat) setInt64(x int64) complexFloat {
+	z.re.SetInt64(x)
+	z.im.SetInt64(0)
+	return z
+}
+
+func (z complexFloat) set(x complexFloat) complexFloat {
+	z.re.Set(x.re)
+	z.im.Set(x.im)
+	return z
+}
+
+func (z complexFloat) add(x, y complexFloat) complexFloat {
+	z.re.Add(x.re, y.re)
+	z.im.Add(x.im, y.im)
+	return z
+}
+
+func (z complexFloat) sub(x, y complexFloat) complexFloat {
+	z.re.Sub(x.re, y.re)
+	z.im.Sub(x.im, y.im)
+	return z
+}
+
+func (z complexFloat) mul(x, y complexFloat) complexFloat {
+	prec := z.re.Prec()
+	ac := newFloat(prec).Mul(x.re, y.re)
+	bd := newFloat(prec).Mul(x.im, y.im)
+	ad := newFloat(prec).Mul(x.re, y.im)
+	bc := newFloat(prec).Mul(x.im, y.re)
+	z.re.Sub(ac, bd)
+	z.im.Add(ad, bc)
+	return z
+}
+
+// Sets z to x times the real number y.
+func (z complexFloat) mulFloat(x complexFloat, y *big.Float) complexFloat {
+	z.re.Mul(x.re, y)
+	z.im.Mul(x.im, y)
+	return z
+}
+
+func (z complexFloat) quo(x, y complexFloat) complexFloat {
+	prec := z.re.Prec()
+	// x/y = x conj(y) / |y|^2.
+	normSq := newFloat(prec).Mul(y.re, y.re)
+	normSq.Add(normSq, newFloat(prec).Mul(y.im, y.im))
+	conj := complexFloat{
+		newFloat(prec).Set(y.re), newFloat(prec).Neg(y.im),
+	}
+	z.mul(x, conj)
+	z.re.Quo(z.re, normSq)
+	z.im.Quo(z.im, normSq)
+	return z
+}
+
+// Returns e^z with prec bits of precision, by summing the Taylor
+// series for e^(z/2^s) and squaring the result s times, where s is
+// chosen to make |z/2^s| small.
+func expComplex(z complexFloat, prec uint) complexFloat {
+	s := 0
+	for _, x := range []*big.Float{z.re, z.im} {
+		if x.Sign() != 0 {
+			if e := x.MantExp(nil) + 8; e > s {
+				s = e
+			}
+		}
+	}
+	// Each squaring loses about a bit.
+	workPrec := prec + uint(s) + 32
+	w := newComplexFloat(workPrec).set(z)
+	w.re.SetMantExp(w.re, -s)
+	w.im.SetMantExp(w.im, -s)
+
+	sum := newComplexFloat(workPrec).setInt64(1)
+	term := newComplexFloat(workPrec).setInt64(1)
+	for k := int64(1); ; k++ {
+		term.mul(term, w)
+		term.mulFloat(term, newFloat(workPrec).Quo(
+			newFloat(workPrec).SetInt64(1),
+			newFloat(workPrec).SetInt64(k)))
+		if isNegligible(term.re, workPrec) &&
+			isNegligible(term.im, workPrec) {
+			break
+		}
+		sum.add(sum, term)
+	}
+	for i := 0; i < s; i++ {
+		sum.mul(sum, sum)
+	}
+	sum.re.SetPrec(prec)
+	sum.im.SetPrec(prec)
+	return sum
+}
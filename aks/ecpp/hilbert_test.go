@@ -0,0 +1,73 @@
+package ecpp
+
+import "math/big"
+import "testing"
+
+// The class numbers of some small discriminants should match the
+// known values.
+func TestClassNumber(t *testing.T) {
+	for _, c := range []struct {
+		D int64
+		h int
+	}{
+		{-3, 1}, {-4, 1}, {-7, 1}, {-8, 1}, {-15, 2}, {-20, 2},
+		{-23, 3}, {-163, 1}, {-47, 5}, {-71, 7}, {-4 * 14, 4},
+	} {
+		if !isFundamentalDiscriminant(c.D) {
+			t.Error(c.D)
+		}
+		if h := len(getReducedForms(c.D)); h != c.h {
+			t.Error(c.D, h, c.h)
+		}
+	}
+	for _, D := range []int64{-1, -2, -5, -12, -16, -27, -63} {
+		if isFundamentalDiscriminant(D) {
+			t.Error(D)
+		}
+	}
+}
+
+// getDiscriminants should be sorted by class number and then by |D|.
+func TestGetDiscriminants(t *testing.T) {
+	ds := getDiscriminants()
+	if ds[0].D != -3 || ds[1].D != -4 || ds[2].D != -7 {
+		t.Error(ds[0].D, ds[1].D, ds[2].D)
+	}
+	for i := 1; i < len(ds); i++ {
+		hPrev, h := ds[i-1].classNumber(), ds[i].classNumber()
+		if hPrev > h || (hPrev == h && ds[i-1].D < ds[i].D) {
+			t.Error(ds[i-1].D, ds[i].D)
+		}
+	}
+}
+
+// getHilbertClassPolynomial should match known Hilbert class
+// polynomials.
+func TestGetHilbertClassPolynomial(t *testing.T) {
+	for _, c := range []struct {
+		D      int64
+		coeffs []string
+	}{
+		{-3, []string{"0", "1"}},
+		{-4, []string{"-1728", "1"}},
+		{-7, []string{"3375", "1"}},
+		{-163, []string{"262537412640768000", "1"}},
+		{-15, []string{"-121287375", "191025", "1"}},
+		{-23, []string{
+			"12771880859375", "-5151296875", "3491750", "1",
+		}},
+	} {
+		d := discriminant{c.D, getReducedForms(c.D)}
+		H := getHilbertClassPolynomial(&d)
+		if len(H) != len(c.coeffs) {
+			t.Error(c.D, H)
+			continue
+		}
+		for i, s := range c.coeffs {
+			x, _ := new(big.Int).SetString(s, 10)
+			if H[i].Cmp(x) != 0 {
+				t.Error(c.D, i, H[i], x)
+			}
+		}
+	}
+}
@@ -0,0 +1,166 @@
+package ecpp
+
+import "math/big"
+import "math/rand"
+
+// The number of random shifts findRoot tries before giving up, which
+// only happens if the polynomial doesn't split into distinct linear
+// factors.
+const maxRootFindingTries = 100
+
+// Holds a polynomial over Z/nZ as its coefficients in [0, n), in
+// ascending order of degree. The leading coefficient is non-zero,
+// except for the zero polynomial, which is empty.
+type polyMod []*big.Int
+
+// Returns p with its leading zero coefficients dropped.
+func (p polyMod) trim() polyMod {
+	for len(p) > 0 && p[len(p)-1].Sign() == 0 {
+		p = p[:len(p)-1]
+	}
+	return p
+}
+
+// Returns the degree of p, or -1 if p is zero.
+func (p polyMod) degree() int {
+	return len(p) - 1
+}
+
+// Returns the polynomial with the given integer coefficients reduced
+// mod n.
+func newPolyMod(coeffs []*big.Int, n *big.Int) polyMod {
+	p := make(polyMod, len(coeffs))
+	for i, c := range coeffs {
+		p[i] = new(big.Int).Mod(c, n)
+	}
+	return p.trim()
+}
+
+// Returns x*y mod n.
+func mulPolyMod(x, y polyMod, n *big.Int) polyMod {
+	if len(x) == 0 || len(y) == 0 {
+		return nil
+	}
+	product := make(polyMod, len(x)+len(y)-1)
+	for i := range product {
+		product[i] = new(big.Int)
+	}
+	var t big.Int
+	for i, a := range x {
+		for j, b := range y {
+			product[i+j].Add(product[i+j], t.Mul(a, b))
+		}
+	}
+	for _, c := range product {
+		c.Mod(c, n)
+	}
+	return product.trim()
+}
+
+// Returns the quotient and remainder of x divided by y mod n, which
+// must be prime. Returns false if the leading coefficient of y isn't
+// invertible mod n, which means n is composite.
+func divModPolyMod(x, y polyMod, n *big.Int) (q, r polyMod, ok bool) {
+	inv := new(big.Int).ModInverse(y[len(y)-1], n)
+	if inv == nil {
+		return nil, nil, false
+	}
+	r = make(polyMod, len(x))
+	for i, c := range x {
+		r[i] = new(big.Int).Set(c)
+	}
+	if len(x) < len(y) {
+		return nil, r, true
+	}
+	q = make(polyMod, len(x)-len(y)+1)
+	var t big.Int
+	for i := len(q) - 1; i >= 0; i-- {
+		c := new(big.Int).Mul(r[i+len(y)-1], inv)
+		c.Mod(c, n)
+		q[i] = c
+		for j, b := range y {
+			r[i+j].Sub(r[i+j], t.Mul(c, b))
+			r[i+j].Mod(r[i+j], n)
+		}
+	}
+	return q.trim(), r.trim(), true
+}
+
+// Returns x^e mod (m, n), where m is monic.
+func expPolyMod(x polyMod, e *big.Int, m polyMod, n *big.Int) polyMod {
+	result := polyMod{big.NewInt(1)}
+	for i := e.BitLen() - 1; i >= 0; i-- {
+		result = mulPolyMod(result, result, n)
+		_, result, _ = divModPolyMod(result, m, n)
+		if e.Bit(i) != 0 {
+			result = mulPolyMod(result, x, n)
+			_, result, _ = divModPolyMod(result, m, n)
+		}
+	}
+	return result
+}
+
+// Returns the monic gcd of x and y mod n, which must be prime, or
+// false if n turns out to be composite.
+func gcdPolyMod(x, y polyMod, n *big.Int) (polyMod, bool) {
+	for len(y) > 0 {
+		_, r, ok := divModPolyMod(x, y, n)
+		if !ok {
+			return nil, false
+		}
+		x, y = y, r
+	}
+	if len(x) == 0 {
+		return x, true
+	}
+	inv := new(big.Int).ModInverse(x[len(x)-1], n)
+	if inv == nil {
+		return nil, false
+	}
+	monic := make(polyMod, len(x))
+	for i, c := range x {
+		monic[i] = new(big.Int).Mul(c, inv)
+		monic[i].Mod(monic[i], n)
+	}
+	return monic, true
+}
+
+// Returns a root mod n of the monic polynomial p, which must split
+// into distinct linear factors mod n, which must be an odd prime,
+// using the Cantor-Zassenhaus algorithm: for a random d, about half
+// of the roots r of p have r + d a quadratic residue, so gcd((x +
+// d)^((n - 1)/2) - 1, p) is usually a proper factor of p. Returns
+// nil if no root is found, which means the requirements weren't met.
+func findRoot(p polyMod, n *big.Int, rnd *rand.Rand) *big.Int {
+	e := new(big.Int).Rsh(n, 1)
+	for tries := 0; p.degree() > 1; tries++ {
+		if tries >= maxRootFindingTries {
+			return nil
+		}
+		x := polyMod{new(big.Int).Rand(rnd, n), big.NewInt(1)}
+		y := expPolyMod(x.trim(), e, p, n)
+		if len(y) == 0 {
+			continue
+		}
+		y[0] = new(big.Int).Sub(y[0], big.NewInt(1))
+		y[0].Mod(y[0], n)
+		g, ok := gcdPolyMod(p, y.trim(), n)
+		if !ok {
+			return nil
+		}
+		if g.degree() <= 0 || g.degree() == p.degree() {
+			continue
+		}
+		// Continue with the smaller factor.
+		if 2*g.degree() > p.degree() {
+			g, _, _ = divModPolyMod(p, g, n)
+		}
+		p = g
+	}
+	if p.degree() != 1 {
+		return nil
+	}
+	// p = x + c, which is monic, so its root is -c.
+	r := new(big.Int).Neg(p[0])
+	return r.Mod(r, n)
+}
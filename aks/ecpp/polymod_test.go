@@ -0,0 +1,71 @@
+package ecpp
+
+import "math/big"
+import "math/rand"
+import "testing"
+
+// findRoot should find one of the roots of a product of distinct
+// linear factors.
+func TestFindRoot(t *testing.T) {
+	n, _ := new(big.Int).SetString(
+		"340282366920938463463374607431768211507", 10)
+	rnd := rand.New(rand.NewSource(1))
+	for degree := 1; degree <= 10; degree++ {
+		p := polyMod{big.NewInt(1)}
+		roots := make(map[string]bool)
+		for i := 0; i < degree; i++ {
+			r := new(big.Int).Rand(rnd, n)
+			roots[r.String()] = true
+			c := new(big.Int).Sub(n, r)
+			p = mulPolyMod(p, polyMod{c, big.NewInt(1)}, n)
+		}
+		r := findRoot(p, n, rnd)
+		if r == nil || !roots[r.String()] {
+			t.Error(degree, r)
+		}
+	}
+
+	// x^2 + 1 has no roots mod 2^127 - 1, which is 3 mod 4.
+	m127 := new(big.Int).Lsh(big.NewInt(1), 127)
+	m127.Sub(m127, big.NewInt(1))
+	p := polyMod{big.NewInt(1), big.NewInt(0), big.NewInt(1)}
+	if r := findRoot(p, m127, rnd); r != nil {
+		t.Error(r)
+	}
+}
+
+// divModPolyMod should give q and r with x = qy + r and deg r < deg y.
+func TestDivModPolyMod(t *testing.T) {
+	n := big.NewInt(1000003)
+	rnd := rand.New(rand.NewSource(1))
+	randomPoly := func(degree int) polyMod {
+		p := make(polyMod, degree+1)
+		for i := range p {
+			p[i] = new(big.Int).Rand(rnd, n)
+		}
+		p[degree].SetInt64(int64(rnd.Intn(1000) + 1))
+		return p
+	}
+	for i := 0; i < 20; i++ {
+		x := randomPoly(rnd.Intn(10))
+		y := randomPoly(rnd.Intn(5))
+		q, r, ok := divModPolyMod(x, y, n)
+		if !ok || r.degree() >= y.degree() {
+			t.Fatal(x, y, q, r, ok)
+		}
+		qy := mulPolyMod(q, y, n)
+		for j := range x {
+			var c big.Int
+			if j < len(qy) {
+				c.Set(qy[j])
+			}
+			if j < len(r) {
+				c.Add(&c, r[j])
+			}
+			if c.Mod(&c, n).Cmp(x[j]) != 0 {
+				t.Error(x, y, q, r)
+				break
+			}
+		}
+	}
+}
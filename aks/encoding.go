@@ -0,0 +1,136 @@
+package aks
+
+import "bytes"
+import "encoding/gob"
+import "encoding/json"
+import "fmt"
+import "math/big"
+import "time"
+
+// Marshals v with encoding/gob, like json.Marshal.
+func gobMarshal(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Unmarshals data with encoding/gob into v, like json.Unmarshal.
+func gobUnmarshal(data []byte, v interface{}) error {
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(v)
+}
+
+// The form in which polys are encoded with encoding/gob and
+// encoding/json. It's the same for all backends, so a poly encoded by
+// one backend may be decoded by another.
+type encodedPoly struct {
+	N, R *big.Int
+	// The coefficients of x^0, x^1, ..., up to the leading one.
+	Coefficients []*big.Int
+}
+
+// Marshals p, a poly mod (N, X^R - 1), with marshal.
+func marshalPoly(
+	p poly, N, R big.Int,
+	marshal func(interface{}) ([]byte, error)) ([]byte, error) {
+	e := encodedPoly{N: &N, R: &R}
+	rInt := int(R.Int64())
+	for i := 0; i < rInt; i++ {
+		e.Coefficients = append(e.Coefficients, p.coefficient(i))
+	}
+	for len(e.Coefficients) > 0 &&
+		e.Coefficients[len(e.Coefficients)-1].Sign() == 0 {
+		e.Coefficients = e.Coefficients[:len(e.Coefficients)-1]
+	}
+	return marshal(e)
+}
+
+// Unmarshals data, as marshaled by marshalPoly, with unmarshal, and
+// returns it as a poly of the given backend. Returns an error
+// wrapping ErrBadInput if the encoded poly is invalid or unsupported
+// by the backend.
+func unmarshalPoly(
+	data []byte, unmarshal func([]byte, interface{}) error,
+	backend PolyBackend) (poly, error) {
+	var e encodedPoly
+	if err := unmarshal(data, &e); err != nil {
+		return nil, err
+	}
+	return e.decode(backend)
+}
+
+// Returns e as a poly of the given backend. Returns an error wrapping
+// ErrBadInput if e is invalid or unsupported by the backend.
+func (e *encodedPoly) decode(backend PolyBackend) (poly, error) {
+	if e.N == nil || e.R == nil {
+		return nil, fmt.Errorf("%w: missing N or R", ErrBadInput)
+	}
+	if err := checkAKSWitnessArgs(e.N, e.R, 1); err != nil {
+		return nil, err
+	}
+	if int64(len(e.Coefficients)) > e.R.Int64() {
+		return nil, fmt.Errorf(
+			"%w: %d coefficients is more than R = %v",
+			ErrBadInput, len(e.Coefficients), e.R)
+	}
+	for i, c := range e.Coefficients {
+		if c == nil || c.Sign() < 0 || c.Cmp(e.N) >= 0 {
+			return nil, fmt.Errorf(
+				"%w: coefficient %d = %v is not in [0, %v)",
+				ErrBadInput, i, c, e.N)
+		}
+	}
+
+	ring, err := newPolyRing(e.N, e.R, backend)
+	if err != nil {
+		return nil, err
+	}
+	p := ring.newPoly()
+	for i, c := range e.Coefficients {
+		if c.Sign() != 0 {
+			p.setCoefficient(i, c)
+		}
+	}
+	return p, nil
+}
+
+// The form in which witnessResults are encoded with encoding/gob and
+// encoding/json.
+type encodedWitnessResult struct {
+	A         *big.Int
+	IsWitness bool
+	Duration  time.Duration
+}
+
+// gob.GobEncoder implementation.
+func (r witnessResult) GobEncode() ([]byte, error) {
+	return gobMarshal(encodedWitnessResult{r.a, r.isWitness, r.duration})
+}
+
+// gob.GobDecoder implementation.
+func (r *witnessResult) GobDecode(data []byte) error {
+	return r.unmarshal(data, gobUnmarshal)
+}
+
+// json.Marshaler implementation.
+func (r witnessResult) MarshalJSON() ([]byte, error) {
+	return json.Marshal(encodedWitnessResult{r.a, r.isWitness, r.duration})
+}
+
+// json.Unmarshaler implementation.
+func (r *witnessResult) UnmarshalJSON(data []byte) error {
+	return r.unmarshal(data, json.Unmarshal)
+}
+
+// Sets r to data, as marshaled by GobEncode or MarshalJSON, with
+// unmarshal.
+func (r *witnessResult) unmarshal(
+	data []byte, unmarshal func([]byte, interface{}) error) error {
+	var e encodedWitnessResult
+	if err := unmarshal(data, &e); err != nil {
+		return err
+	}
+	*r = witnessResult{e.A, e.IsWitness, e.Duration}
+	return nil
+}
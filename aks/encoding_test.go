@@ -0,0 +1,190 @@
+package aks
+
+import "context"
+import "encoding/json"
+import "errors"
+import "fmt"
+import "math/big"
+import "reflect"
+import "testing"
+import "time"
+
+// The marshal and unmarshal functions of each supported encoding.
+var encodings = []struct {
+	name      string
+	marshal   func(interface{}) ([]byte, error)
+	unmarshal func([]byte, interface{}) error
+}{
+	{"gob", gobMarshal, gobUnmarshal},
+	{"json", json.Marshal, json.Unmarshal},
+}
+
+// A poly encoded by any backend should decode to an equal poly with
+// any backend.
+func TestPolyEncodingRoundTrip(t *testing.T) {
+	N := big.NewInt(1000003)
+	R := big.NewInt(53)
+	backends := []PolyBackend{
+		BigIntPolyBackend, WordPolyBackend, NTTPolyBackend,
+	}
+	for _, backend := range backends {
+		ring, err := newPolyRing(N, R, backend)
+		if err != nil {
+			t.Fatal(backend, err)
+		}
+		p := ring.newPoly()
+		q := ring.newPoly()
+		tmp := ring.newPoly()
+		p.Set(*big.NewInt(999999), *big.NewInt(7), *N)
+		q.Set(*big.NewInt(5), *big.NewInt(30), *N)
+		p.Mul(q, *N, tmp)
+		expected := fmt.Sprint(p)
+
+		for _, encoding := range encodings {
+			data, err := encoding.marshal(p)
+			if err != nil {
+				t.Fatal(backend, encoding.name, err)
+			}
+			for _, decoded := range []poly{
+				&bigIntPoly{}, &wordPoly{}, &nttPoly{},
+			} {
+				if err := encoding.unmarshal(data, decoded); err != nil {
+					t.Fatal(backend, encoding.name, err)
+				}
+				if str := fmt.Sprint(decoded); str != expected {
+					t.Errorf("%v %s %T %s %s",
+						backend, encoding.name, decoded, str, expected)
+				}
+			}
+		}
+	}
+}
+
+// An encoded poly of a ring made by makeRing should decode to an equal
+// bigIntPoly, and an encoded bigIntPoly to an equal poly of the same
+// backend, whether decoding into the zero value returned by newZero
+// or into a poly that already has a value.
+func checkPolyEncoding(t *testing.T,
+	makeRing func(N, R big.Int) polyRing, newZero func() poly) {
+	N := *big.NewInt(1000003)
+	p := makeRing(N, *big.NewInt(53)).newPoly()
+	p.Set(*big.NewInt(999999), *big.NewInt(7), N)
+	p.setCoefficient(30, big.NewInt(12345))
+	for _, encoding := range encodings {
+		data, err := encoding.marshal(p)
+		if err != nil {
+			t.Fatal(encoding.name, err)
+		}
+		var decoded bigIntPoly
+		if err := encoding.unmarshal(data, &decoded); err != nil {
+			t.Fatal(encoding.name, err)
+		}
+		if fmt.Sprint(&decoded) != fmt.Sprint(p) {
+			t.Error(encoding.name, &decoded, p)
+		}
+
+		data, err = encoding.marshal(&decoded)
+		if err != nil {
+			t.Fatal(encoding.name, err)
+		}
+		q := newZero()
+		// The second time, q already has a value to replace.
+		for i := 0; i < 2; i++ {
+			if err := encoding.unmarshal(data, q); err != nil {
+				t.Fatal(encoding.name, i, err)
+			}
+			if !q.Eq(p) || fmt.Sprint(q) != fmt.Sprint(p) {
+				t.Error(encoding.name, i, q, p)
+			}
+		}
+	}
+}
+
+// A decoded poly should be usable like any other.
+func TestPolyEncodingDecodedMul(t *testing.T) {
+	N := *big.NewInt(101)
+	R := *big.NewInt(53)
+	ring := newBigIntPolyRing(N, R)
+	p := ring.newPoly()
+	p.Set(*big.NewInt(2), *big.NewInt(1), N)
+	data, err := json.Marshal(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded bigIntPoly
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	decoded.Mul(p, N, ring.newPoly())
+	if str := fmt.Sprint(&decoded); str != "x^2 + 4x + 4" {
+		t.Error(str)
+	}
+}
+
+// Decoding should reject invalid polys, and polys that don't fit
+// into the backend.
+func TestPolyEncodingBadInput(t *testing.T) {
+	for _, data := range []string{
+		`{"N": 101, "Coefficients": [1]}`,
+		`{"N": 1, "R": 5, "Coefficients": [0]}`,
+		`{"N": 101, "R": 2, "Coefficients": [1, 2, 3]}`,
+		`{"N": 101, "R": 5, "Coefficients": [1, 101]}`,
+		`{"N": 101, "R": 5, "Coefficients": [-1]}`,
+		`{"N": 101, "R": 5, "Coefficients": [null]}`,
+	} {
+		var p bigIntPoly
+		if err := json.Unmarshal(
+			[]byte(data), &p); !errors.Is(err, ErrBadInput) {
+			t.Error(data, err)
+		}
+	}
+
+	var p wordPoly
+	data := `{"N": 18446744073709551629, "R": 5, "Coefficients": [1]}`
+	if err := json.Unmarshal([]byte(data), &p); !errors.Is(err, ErrBadInput) {
+		t.Error(err)
+	}
+}
+
+// witnessResults should survive being encoded and decoded.
+func TestWitnessResultEncoding(t *testing.T) {
+	result := witnessResult{big.NewInt(12345), true, 3 * time.Second}
+	for _, encoding := range encodings {
+		data, err := encoding.marshal(result)
+		if err != nil {
+			t.Fatal(encoding.name, err)
+		}
+		var decoded witnessResult
+		if err := encoding.unmarshal(data, &decoded); err != nil {
+			t.Fatal(encoding.name, err)
+		}
+		if !reflect.DeepEqual(decoded, result) {
+			t.Error(encoding.name, decoded, result)
+		}
+	}
+}
+
+// PrimalityResults should survive being encoded and decoded.
+func TestPrimalityResultEncoding(t *testing.T) {
+	for _, n := range []int64{1000003, 1961} {
+		result, err := RunAKS(context.Background(), big.NewInt(n),
+			&AKSOptions{GenerateCertificate: true})
+		if err != nil {
+			t.Fatal(n, err)
+		}
+		for _, encoding := range encodings {
+			data, err := encoding.marshal(result)
+			if err != nil {
+				t.Fatal(n, encoding.name, err)
+			}
+			var decoded PrimalityResult
+			if err := encoding.unmarshal(data, &decoded); err != nil {
+				t.Fatal(n, encoding.name, err)
+			}
+			if !reflect.DeepEqual(&decoded, result) {
+				t.Errorf("%d %s %+v %+v",
+					n, encoding.name, decoded, result)
+			}
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package aks
+
+import "errors"
+import "math/big"
+
+// Returned (possibly wrapped) when an argument to an exported
+// function is out of range.
+var ErrBadInput = errors.New("aks: bad input")
+
+// Returned (possibly wrapped) when no AKS modulus could be found
+// below the upper bound that is guaranteed to contain one. This
+// indicates a bug.
+var ErrModulusNotFound = errors.New("aks: could not find AKS modulus")
+
+// Returned (possibly wrapped) by CheckAKSModulus when r isn't a valid
+// AKS modulus for n.
+var ErrBadModulus = errors.New("aks: invalid AKS modulus")
+
+// Returned (possibly wrapped) when r is too large to be used as the
+// size of a polynomial.
+var ErrRTooLarge = errors.New("aks: r does not fit into an int")
+
+// Returned (possibly wrapped) when a PolyBackend that isn't available
+// in this build is used; see PolyBackend.IsAvailable.
+var ErrBackendUnavailable = errors.New(
+	"aks: polynomial backend not available in this build")
+
+// Returned (possibly wrapped) when a WitnessTester is used after
+// it's closed.
+var ErrClosed = errors.New("aks: WitnessTester is closed")
+
+// Returned (possibly wrapped) by the methods of Factorization that
+// need every prime factor when called on an incomplete one.
+var ErrIncompleteFactorization = errors.New(
+	"aks: factorization is incomplete")
+
+// Returned (possibly wrapped) by the primality provers, such as
+// ProvePocklington, when n turns out to be composite.
+var ErrComposite = errors.New("aks: n is composite")
+
+// Returned (possibly wrapped) by the primality provers when they
+// can't find a proof that n is prime, e.g., because not enough of
+// n - 1 could be factored.
+var ErrProofNotFound = errors.New("aks: could not find a primality proof")
+
+// Returned (possibly wrapped) by the Verify methods of primality
+// certificates when the certificate doesn't prove its N prime.
+var ErrInvalidCertificate = errors.New("aks: invalid certificate")
+
+// Returned (possibly wrapped) by InvMod when a shares a factor with n,
+// so that it has no inverse mod n.
+var ErrNotInvertible = errors.New("aks: not invertible")
+
+// The largest value of an int.
+const maxInt = int64(^uint(0) >> 1)
+
+// Returns whether x fits into an int, assuming x is non-negative.
+func fitsInInt(x *big.Int) bool {
+	return x.IsInt64() && x.Int64() <= maxInt
+}
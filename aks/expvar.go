@@ -0,0 +1,23 @@
+package aks
+
+import "expvar"
+
+// Live variables describing the AKS witness searches in this
+// process, published under "aks" so that they show up in
+// /debug/vars if expvar.Handler is being served:
+//
+//   - current_a: the candidate most recently handed to a job.
+//   - tested: the number of candidates tested so far.
+//   - witness: the last AKS witness found, if any.
+var (
+	searchVars  = expvar.NewMap("aks")
+	currentAVar expvar.String
+	testedVar   expvar.Int
+	witnessVar  expvar.String
+)
+
+func init() {
+	searchVars.Set("current_a", &currentAVar)
+	searchVars.Set("tested", &testedVar)
+	searchVars.Set("witness", &witnessVar)
+}
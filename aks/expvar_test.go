@@ -0,0 +1,33 @@
+package aks
+
+import "math/big"
+import "testing"
+
+// The expvar variables should reflect a witness search.
+func TestExpvar(t *testing.T) {
+	n := big.NewInt(2993374621)
+	r := mustCalculateAKSModulus(n, t)
+	M := mustCalculateAKSUpperBound(n, r, t)
+	testedBefore := testedVar.Value()
+	a, err := GetAKSWitness(n, r, big.NewInt(1), M, 1, nullLogger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a == nil {
+		t.Fatal("no witness found")
+	}
+	// With one job, every candidate up to the witness is tested
+	// in order.
+	var expectedTested big.Int
+	expectedTested.SetInt64(testedBefore)
+	expectedTested.Add(&expectedTested, a)
+	if testedVar.Value() != expectedTested.Int64() {
+		t.Error(testedVar.Value(), &expectedTested)
+	}
+	if witnessVar.Value() != a.String() {
+		t.Error(witnessVar.Value(), a)
+	}
+	if len(currentAVar.Value()) == 0 {
+		t.Error("current_a not set")
+	}
+}
@@ -0,0 +1,96 @@
+// Package factor finds factors of composite numbers too large for the
+// aks package's trial division, for callers that want an actual
+// factor of a composite rather than just an AKS witness.
+//
+// Factor uses SQUFOF (or Pollard's rho algorithm) for numbers below
+// 2^62, and the self-initializing quadratic sieve (SIQS) for larger
+// ones. The running time of SIQS depends only on the size of n, not
+// on the size of its factors: numbers of 50 digits take about a
+// second, 65 digits under a minute, and 80 digits much longer. For n
+// with a factor of up to 20 digits or so, aks.ECM is much faster.
+package factor
+
+import "github.com/akalin/aks-go/aks"
+import "context"
+import "fmt"
+import "math/big"
+
+// The number of rounds passed to big.Int.ProbablyPrime to decide
+// whether a number is prime.
+const primalityRounds = 20
+
+// Returns a nontrivial factor of n, which must be composite. Returns
+// an error wrapping aks.ErrBadInput if n < 2 or n is prime (as
+// decided by big.Int.ProbablyPrime).
+func Factor(n *big.Int) (*big.Int, error) {
+	return FactorContext(context.Background(), n)
+}
+
+// Like Factor, but returns nil and ctx.Err() if ctx is cancelled
+// before a factor is found.
+func FactorContext(ctx context.Context, n *big.Int) (*big.Int, error) {
+	if n.Cmp(big.NewInt(2)) < 0 {
+		return nil, fmt.Errorf(
+			"%w: n = %v must be >= 2", aks.ErrBadInput, n)
+	}
+	if n.ProbablyPrime(primalityRounds) {
+		return nil, fmt.Errorf(
+			"%w: n = %v is prime", aks.ErrBadInput, n)
+	}
+	if n.Bit(0) == 0 {
+		return big.NewInt(2), nil
+	}
+	// The quadratic sieve can't split prime powers, since every
+	// square root of 1 mod p^k is +-1.
+	if b := getPerfectPowerBase(n); b != nil {
+		return b, nil
+	}
+	if n.BitLen() <= 62 {
+		d, err := aks.SQUFOF(n)
+		if d != nil || err != nil {
+			return d, err
+		}
+		return aks.PollardRho(n)
+	}
+	return siqs(ctx, n)
+}
+
+// Returns floor(x^(1/k)) for x >= 0 and k >= 1, using Newton's method.
+func floorRoot(x *big.Int, k int) *big.Int {
+	if x.Sign() == 0 {
+		return &big.Int{}
+	}
+	// Start with a power of 2 above the root, so that the
+	// iterates decrease to it.
+	y := new(big.Int).Lsh(big.NewInt(1), uint(x.BitLen()/k+1))
+	bigK := big.NewInt(int64(k))
+	bigKMinusOne := big.NewInt(int64(k - 1))
+	var z, t big.Int
+	for {
+		// z = ((k - 1)y + x/y^(k-1))/k.
+		t.Exp(y, bigKMinusOne, nil)
+		t.Quo(x, &t)
+		z.Mul(y, bigKMinusOne)
+		z.Add(&z, &t)
+		z.Quo(&z, bigK)
+		if z.Cmp(y) >= 0 {
+			return y
+		}
+		y.Set(&z)
+	}
+}
+
+// Returns b if n = b^k for some k > 1, or nil otherwise.
+func getPerfectPowerBase(n *big.Int) *big.Int {
+	var t big.Int
+	for k := 2; k <= n.BitLen(); k++ {
+		b := floorRoot(n, k)
+		if b.Cmp(big.NewInt(1)) <= 0 {
+			break
+		}
+		if t.Exp(b, big.NewInt(int64(k)), nil).Cmp(n) == 0 {
+			return b
+		}
+	}
+	return nil
+}
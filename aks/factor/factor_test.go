@@ -0,0 +1,85 @@
+package factor
+
+import "github.com/akalin/aks-go/aks"
+import "context"
+import "errors"
+import "math/big"
+import "testing"
+
+// Returns the product of the given numbers, which are in decimal.
+func mustMultiply(t *testing.T, factors ...string) *big.Int {
+	product := big.NewInt(1)
+	for _, s := range factors {
+		x, ok := new(big.Int).SetString(s, 10)
+		if !ok {
+			t.Fatal(s)
+		}
+		product.Mul(product, x)
+	}
+	return product
+}
+
+// Factor should find a nontrivial factor of composites of all sizes.
+func TestFactor(t *testing.T) {
+	for _, n := range []*big.Int{
+		big.NewInt(4),
+		big.NewInt(1 << 40),
+		big.NewInt(10403),
+		mustMultiply(t, "1000003", "1000033"),
+		mustMultiply(t, "4294967311", "4294967311", "4294967311"),
+		// Too big for SQUFOF, but has a factor that turns up
+		// while building the factor base.
+		mustMultiply(t, "1009", "2305843009213693951"),
+		mustMultiply(t, "1000000000000000003", "1000000000000000009"),
+		mustMultiply(t, "99999999999999999989",
+			"100000000000000000039"),
+		mustMultiply(t, "3", "5", "7", "99999999999999999989",
+			"100000000000000000039"),
+	} {
+		d, err := Factor(n)
+		if err != nil {
+			t.Fatal(n, err)
+		}
+		var m big.Int
+		if d.Cmp(big.NewInt(1)) <= 0 || d.Cmp(n) >= 0 ||
+			m.Mod(n, d).Sign() != 0 {
+			t.Error(n, d)
+		}
+	}
+
+	for _, n := range []int64{-1, 0, 1, 2, 13, 1000003} {
+		if d, err := Factor(big.NewInt(n)); !errors.Is(
+			err, aks.ErrBadInput) {
+			t.Error(n, d, err)
+		}
+	}
+}
+
+// FactorContext should stop if its context is cancelled.
+func TestFactorContextCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	n := mustMultiply(t, "1000000000000000003", "1000000000000000009")
+	if d, err := FactorContext(ctx, n); d != nil ||
+		err != context.Canceled {
+		t.Error(d, err)
+	}
+}
+
+// getPerfectPowerBase should find the base of perfect powers only.
+func TestGetPerfectPowerBase(t *testing.T) {
+	tests := []struct {
+		n, b int64
+	}{
+		{4, 2}, {8, 2}, {27, 3}, {1 << 62, 1 << 31}, {6, 0}, {1000001, 0},
+		// 3^4 = 9^2, and the smallest power is found first.
+		{81, 9},
+	}
+	for _, test := range tests {
+		b := getPerfectPowerBase(big.NewInt(test.n))
+		if (b == nil && test.b != 0) ||
+			(b != nil && b.Int64() != test.b) {
+			t.Error(test.n, b, test.b)
+		}
+	}
+}
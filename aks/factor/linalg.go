@@ -0,0 +1,78 @@
+package factor
+
+// A vector over GF(2), packed into words.
+type bitVector []uint64
+
+// Returns a zero vector with room for n bits.
+func newBitVector(n int) bitVector {
+	return make(bitVector, (n+63)/64)
+}
+
+// Returns bit i of v.
+func (v bitVector) get(i int) bool {
+	return v[i/64]&(1<<uint(i%64)) != 0
+}
+
+// Flips bit i of v.
+func (v bitVector) flip(i int) {
+	v[i/64] ^= 1 << uint(i%64)
+}
+
+// Sets v to v + w.
+func (v bitVector) add(w bitVector) {
+	for i := range w {
+		v[i] ^= w[i]
+	}
+}
+
+// Returns the subsets of rows that sum to zero found by Gaussian
+// elimination, each as a list of row indices. Every row must have
+// room for ncols bits. There are at least len(rows) - ncols of them.
+// rows is modified.
+func findDependencies(rows []bitVector, ncols int) [][]int {
+	// history[i] holds the original rows that were added up to
+	// make rows[i].
+	history := make([]bitVector, len(rows))
+	for i := range rows {
+		history[i] = newBitVector(len(rows))
+		history[i].flip(i)
+	}
+
+	isPivot := make([]bool, len(rows))
+	for col := 0; col < ncols; col++ {
+		pivot := -1
+		for i := range rows {
+			if !isPivot[i] && rows[i].get(col) {
+				pivot = i
+				break
+			}
+		}
+		if pivot < 0 {
+			continue
+		}
+		isPivot[pivot] = true
+		// Only rows that aren't pivots need to be eliminated,
+		// since those are the ones that end up zero.
+		for i := range rows {
+			if !isPivot[i] && rows[i].get(col) {
+				rows[i].add(rows[pivot])
+				history[i].add(history[pivot])
+			}
+		}
+	}
+
+	var deps [][]int
+	for i := range rows {
+		if isPivot[i] {
+			continue
+		}
+		var dep []int
+		for j := range rows {
+			if history[i].get(j) {
+				dep = append(dep, j)
+			}
+		}
+		deps = append(deps, dep)
+	}
+	return deps
+}
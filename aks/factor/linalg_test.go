@@ -0,0 +1,43 @@
+package factor
+
+import "testing"
+
+// Returns a bitVector with the given bits set.
+func makeBitVector(n int, bits ...int) bitVector {
+	v := newBitVector(n)
+	for _, i := range bits {
+		v.flip(i)
+	}
+	return v
+}
+
+// findDependencies should return subsets of rows that sum to zero.
+func TestFindDependencies(t *testing.T) {
+	const ncols = 70
+	makeRows := func() []bitVector {
+		return []bitVector{
+			makeBitVector(ncols, 0, 1),
+			makeBitVector(ncols, 1, 69),
+			makeBitVector(ncols, 2),
+			makeBitVector(ncols, 0, 69),
+			makeBitVector(ncols, 2, 64),
+			makeBitVector(ncols, 64),
+		}
+	}
+	deps := findDependencies(makeRows(), ncols)
+	if len(deps) != 2 {
+		t.Fatal(deps)
+	}
+	rows := makeRows()
+	for _, dep := range deps {
+		sum := newBitVector(ncols)
+		for _, i := range dep {
+			sum.add(rows[i])
+		}
+		for col := 0; col < ncols; col++ {
+			if sum.get(col) {
+				t.Error(dep, col)
+			}
+		}
+	}
+}
@@ -0,0 +1,610 @@
+package factor
+
+import "context"
+import "fmt"
+import "math"
+import "math/big"
+import "math/bits"
+import "math/rand"
+import "sort"
+
+// Holds the SIQS parameters for numbers of up to the given bit
+// length.
+type siqsParams struct {
+	bits int
+	// The number of primes in the factor base.
+	fbSize int
+	// Each polynomial is sieved over [-sieveHalf, sieveHalf).
+	sieveHalf int
+	// Partial relations are kept if their leftover large prime is
+	// at most this times the largest prime in the factor base.
+	lpMultiplier int
+}
+
+// The SIQS parameters by size of n. Numbers larger than the last
+// entry use its parameters.
+var siqsParamTable = []siqsParams{
+	{80, 100, 1 << 15, 30},
+	{100, 200, 1 << 15, 30},
+	{130, 500, 1 << 15, 40},
+	{160, 1000, 1 << 16, 50},
+	{190, 2000, 1 << 16, 60},
+	{220, 4500, 1 << 16, 80},
+	{250, 9000, 1 << 17, 100},
+	{280, 16000, 1 << 17, 100},
+}
+
+// Returns the SIQS parameters for numbers with the given bit length.
+func getSIQSParams(bitLen int) siqsParams {
+	for _, p := range siqsParamTable {
+		if bitLen <= p.bits {
+			return p
+		}
+	}
+	return siqsParamTable[len(siqsParamTable)-1]
+}
+
+// The multipliers k that the sieve picks from for factoring kn
+// instead of n, which can make many more small primes quadratic
+// residues.
+var siqsMultipliers = []int64{
+	1, 3, 5, 7, 11, 13, 15, 17, 19, 21, 23, 29, 31, 33, 35, 37, 39,
+	41, 43, 47, 51, 53, 55, 57, 59, 61, 65, 67, 69, 71, 73,
+}
+
+// Primes less than this aren't sieved with, since they take the most
+// time to sieve and add the least to the logs; the sieve threshold is
+// lowered to make up for them.
+const siqsMinSievedPrime = 30
+
+// The number of relations collected beyond the number needed to
+// guarantee a dependency, each of which has about an even chance of
+// giving a factor.
+const siqsExtraRelations = 32
+
+// The number of random choices of a the sieve tries before settling
+// for one it has used already.
+const siqsMaxATries = 100
+
+// A relation y^2 = (product of factor base entries) * L^2 (mod n).
+type siqsRelation struct {
+	y *big.Int
+	// The columns of the factor base entries, with multiplicity:
+	// 0 for -1, and i + 1 for the ith prime.
+	cols []int
+	// L, or 1 if there is none.
+	large *big.Int
+}
+
+// Holds the state of the self-initializing quadratic sieve.
+type siqsState struct {
+	n, kn *big.Int
+	rng   *rand.Rand
+	// The factor base: primes p with kn a square mod p, with the
+	// square roots of kn and the rounded base-2 logs of p.
+	primes    []int
+	bigPrimes []*big.Int
+	sqrts     []int
+	logs      []byte
+	// The index of the first prime sieved with.
+	firstSieved int
+	sieveHalf   int
+	threshold   byte
+	lpMax       *big.Int
+
+	// How to pick a, the product of aCount primes from the factor
+	// base near exp(lnTarget/aCount).
+	lnTarget    float64
+	aCount      int
+	aCandidates []int
+	usedA       map[string]bool
+
+	relations []siqsRelation
+	partials  map[string]siqsRelation
+}
+
+// Returns a nontrivial factor of n, which must be odd, composite, and
+// not a perfect power, with the self-initializing quadratic sieve.
+func siqs(ctx context.Context, n *big.Int) (*big.Int, error) {
+	s, d := newSIQSState(n, getSIQSParams(n.BitLen()))
+	if d != nil {
+		return d, nil
+	}
+	wanted := len(s.primes) + 1 + siqsExtraRelations
+	for {
+		for len(s.relations) < wanted {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+			s.sieveNextA()
+		}
+		if d := s.findFactor(); d != nil {
+			return d, nil
+		}
+		// Every dependency gave a trivial factor, which is
+		// unlikely unless there were duplicate relations.
+		wanted = len(s.relations) + siqsExtraRelations
+	}
+}
+
+// Returns the primes less than limit.
+func getPrimesBelow(limit int) []int {
+	isComposite := make([]bool, limit)
+	var primes []int
+	for i := 2; i < limit; i++ {
+		if isComposite[i] {
+			continue
+		}
+		primes = append(primes, i)
+		for j := i * i; j < limit; j += i {
+			isComposite[j] = true
+		}
+	}
+	return primes
+}
+
+// Returns x*y mod p, for x, y in [0, p).
+func mulMod(x, y, p int) int {
+	return int(int64(x) * int64(y) % int64(p))
+}
+
+// Returns x^e mod p.
+func powMod(x, e, p int) int {
+	r := 1
+	for ; e > 0; e >>= 1 {
+		if e&1 == 1 {
+			r = mulMod(r, x, p)
+		}
+		x = mulMod(x, x, p)
+	}
+	return r
+}
+
+// Returns the inverse of x mod p, which must be coprime to x.
+func invMod(x, p int) int {
+	// Invariant: r0 = s0*x (mod p) and r1 = s1*x (mod p).
+	r0, r1 := p, x%p
+	s0, s1 := 0, 1
+	for r1 != 0 {
+		q := r0 / r1
+		r0, r1 = r1, r0-q*r1
+		s0, s1 = s1, s0-q*s1
+	}
+	if s0 < 0 {
+		s0 += p
+	}
+	return s0
+}
+
+// Returns x mod p for p > 0 as an int.
+func bigMod(x *big.Int, p int) int {
+	var m big.Int
+	return int(m.Mod(x, big.NewInt(int64(p))).Int64())
+}
+
+// Returns an approximation of the natural log of x > 0.
+func lnBig(x *big.Int) float64 {
+	shift := x.BitLen() - 53
+	if shift < 0 {
+		shift = 0
+	}
+	top := new(big.Int).Rsh(x, uint(shift))
+	return math.Log(float64(top.Int64())) + float64(shift)*math.Ln2
+}
+
+// Returns the multiplier k from siqsMultipliers that maximizes the
+// Knuth-Schroeppel function, which estimates how much the small
+// primes contribute to the sieve for kn.
+func chooseMultiplier(n *big.Int, primes []int) int64 {
+	bestK := int64(1)
+	bestScore := math.Inf(-1)
+	var kn big.Int
+	for _, k := range siqsMultipliers {
+		kn.Mul(n, big.NewInt(k))
+		score := -0.5 * math.Log(float64(k))
+		switch bigMod(&kn, 8) {
+		case 1:
+			score += 2 * math.Ln2
+		case 5:
+			score += math.Ln2
+		default:
+			score += 0.5 * math.Ln2
+		}
+		for _, p := range primes[1:] {
+			r := bigMod(&kn, p)
+			lnP := math.Log(float64(p))
+			if r == 0 {
+				score += lnP / float64(p)
+			} else if powMod(r, (p-1)/2, p) == 1 {
+				score += 2 * lnP / float64(p-1)
+			}
+		}
+		if score > bestScore {
+			bestK, bestScore = k, score
+		}
+	}
+	return bestK
+}
+
+// Sets up the sieve for n with the given parameters, or returns a
+// factor of n if one turns up while doing so.
+func newSIQSState(n *big.Int, params siqsParams) (*siqsState, *big.Int) {
+	// About half of all primes are quadratic residues, so
+	// this is usually plenty.
+	var primes []int
+	for limit := 32 * params.fbSize; len(primes) < 2*params.fbSize; {
+		limit *= 2
+		primes = getPrimesBelow(limit)
+	}
+	for _, p := range primes {
+		if bigMod(n, p) == 0 {
+			return nil, big.NewInt(int64(p))
+		}
+	}
+
+	ksPrimes := primes
+	if len(ksPrimes) > 300 {
+		ksPrimes = ksPrimes[:300]
+	}
+	k := chooseMultiplier(n, ksPrimes)
+	s := &siqsState{
+		n:         n,
+		kn:        new(big.Int).Mul(n, big.NewInt(k)),
+		rng:       rand.New(rand.NewSource(1)),
+		sieveHalf: params.sieveHalf,
+		usedA:     make(map[string]bool),
+		partials:  make(map[string]siqsRelation),
+	}
+	var r big.Int
+	for _, p := range primes {
+		if len(s.primes) >= params.fbSize {
+			break
+		}
+		knModP := bigMod(s.kn, p)
+		var sqrt int
+		switch {
+		case p == 2:
+			// 2 isn't sieved with, so its root is unused.
+		case knModP == 0:
+			// p divides k.
+		case powMod(knModP, (p-1)/2, p) == 1:
+			r.ModSqrt(big.NewInt(int64(knModP)), big.NewInt(int64(p)))
+			sqrt = int(r.Int64())
+		default:
+			continue
+		}
+		s.primes = append(s.primes, p)
+		s.bigPrimes = append(s.bigPrimes, big.NewInt(int64(p)))
+		s.sqrts = append(s.sqrts, sqrt)
+		s.logs = append(s.logs, byte(math.Round(math.Log2(float64(p)))))
+		if p < siqsMinSievedPrime {
+			s.firstSieved = len(s.primes)
+		}
+	}
+	pMax := s.primes[len(s.primes)-1]
+	s.lpMax = big.NewInt(int64(pMax) * int64(params.lpMultiplier))
+
+	// |Q(x)| is at most about M sqrt(kn/2) over the sieve
+	// interval [-M, M) (see sieveNextA). Candidates need most of
+	// that to be made up of sieved primes, leaving room for a
+	// large prime and the unsieved small primes.
+	lgQMax := math.Log2(float64(s.sieveHalf)) +
+		0.5*(lnBig(s.kn)/math.Ln2-1)
+	lgLarge := math.Log2(float64(s.lpMax.Int64()))
+	const unsievedBits = 4
+	s.threshold = byte(math.Max(lgQMax-lgLarge-unsievedBits, 1))
+
+	// a should be about sqrt(2kn)/M, made of primes of a
+	// moderate size so that there are many choices, and so that
+	// they don't take the place of the more useful small primes.
+	s.lnTarget = 0.5*(math.Ln2+lnBig(s.kn)) -
+		math.Log(float64(s.sieveHalf))
+	idealP := 2000.0
+	if float64(pMax) < 2*idealP {
+		idealP = float64(s.primes[len(s.primes)/2])
+	}
+	s.aCount = int(math.Max(math.Round(s.lnTarget/math.Log(idealP)), 1))
+	lnP := s.lnTarget / float64(s.aCount)
+	for i := s.firstSieved; i < len(s.primes); i++ {
+		if s.sqrts[i] != 0 &&
+			math.Abs(math.Log(float64(s.primes[i]))-lnP) < math.Ln2 {
+			s.aCandidates = append(s.aCandidates, i)
+		}
+	}
+	if len(s.aCandidates) < s.aCount+4 {
+		s.aCandidates = nil
+		for i := s.firstSieved; i < len(s.primes); i++ {
+			if s.sqrts[i] != 0 {
+				s.aCandidates = append(s.aCandidates, i)
+			}
+		}
+	}
+	return s, nil
+}
+
+// Returns the factor base indices of the primes making up a new a,
+// in ascending order.
+func (s *siqsState) chooseA() []int {
+	var aIdx []int
+	for try := 0; try < siqsMaxATries; try++ {
+		aIdx = aIdx[:0]
+		chosen := make(map[int]bool)
+		if s.aCount == 1 {
+			i := s.aCandidates[s.rng.Intn(len(s.aCandidates))]
+			aIdx = append(aIdx, i)
+		} else {
+			// Pick all but one at random, then the last
+			// one to bring a as close to the target as
+			// possible.
+			lnA := 0.0
+			for len(aIdx) < s.aCount-1 {
+				i := s.aCandidates[s.rng.Intn(len(s.aCandidates))]
+				if chosen[i] {
+					continue
+				}
+				chosen[i] = true
+				aIdx = append(aIdx, i)
+				lnA += math.Log(float64(s.primes[i]))
+			}
+			lnLast := s.lnTarget - lnA
+			best := -1
+			bestDist := math.Inf(1)
+			for i := s.firstSieved; i < len(s.primes); i++ {
+				if s.sqrts[i] == 0 || chosen[i] {
+					continue
+				}
+				d := math.Abs(
+					math.Log(float64(s.primes[i])) - lnLast)
+				if d < bestDist {
+					best, bestDist = i, d
+				}
+			}
+			aIdx = append(aIdx, best)
+		}
+		sort.Ints(aIdx)
+		key := fmt.Sprint(aIdx)
+		if !s.usedA[key] {
+			s.usedA[key] = true
+			break
+		}
+	}
+	return aIdx
+}
+
+// Picks a new a and sieves each of the 2^(aCount - 1) polynomials
+// Q(x) = ((ax + b)^2 - kn)/a for it, adding the relations found.
+func (s *siqsState) sieveNextA() {
+	aIdx := s.chooseA()
+	a := big.NewInt(1)
+	isAFactor := make([]bool, len(s.primes))
+	for _, i := range aIdx {
+		a.Mul(a, s.bigPrimes[i])
+		isAFactor[i] = true
+	}
+
+	// b = sum B_l, where B_l = sqrt(kn) mod q_l and 0 mod q_j
+	// for j != l, so that b^2 = kn (mod a) for every choice of
+	// signs.
+	B := make([]*big.Int, len(aIdx))
+	b := &big.Int{}
+	for l, i := range aIdx {
+		q := s.primes[i]
+		aOverQ := new(big.Int).Quo(a, s.bigPrimes[i])
+		g := mulMod(s.sqrts[i], invMod(bigMod(aOverQ, q), q), q)
+		if g > q/2 {
+			g = q - g
+		}
+		B[l] = aOverQ.Mul(aOverQ, big.NewInt(int64(g)))
+		b.Add(b, B[l])
+	}
+
+	// The sieve roots are the indices x + M with
+	// x = a^-1 (+-sqrt(kn) - b) (mod p), and bAInv2[l] holds the
+	// amount they change by when the sign of B_l flips.
+	M := s.sieveHalf
+	roots1 := make([]int, len(s.primes))
+	roots2 := make([]int, len(s.primes))
+	bAInv2 := make([][]int, len(aIdx))
+	for l := range bAInv2 {
+		bAInv2[l] = make([]int, len(s.primes))
+	}
+	for i := 1; i < len(s.primes); i++ {
+		if isAFactor[i] {
+			continue
+		}
+		p := s.primes[i]
+		aInv := invMod(bigMod(a, p), p)
+		bModP := bigMod(b, p)
+		t := s.sqrts[i]
+		roots1[i] = (mulMod(aInv, (t-bModP+p)%p, p) + M) % p
+		roots2[i] = (mulMod(aInv, (2*p-t-bModP)%p, p) + M) % p
+		for l := range B {
+			bAInv2[l][i] = mulMod(2*bigMod(B[l], p)%p, aInv, p)
+		}
+	}
+
+	sieve := make([]byte, 2*M)
+	c := &big.Int{}
+	for poly := 0; poly < 1<<uint(len(aIdx)-1); poly++ {
+		if poly > 0 {
+			// Flip the signs of the B_l in Gray code
+			// order, so each step flips just one.
+			l := bits.TrailingZeros(uint(poly))
+			e := (poly + 1<<uint(l+1) - 1) >> uint(l+1)
+			var twoB big.Int
+			twoB.Lsh(B[l], 1)
+			for i := 1; i < len(s.primes); i++ {
+				if isAFactor[i] {
+					continue
+				}
+				p := s.primes[i]
+				delta := bAInv2[l][i]
+				if e%2 == 0 {
+					delta = p - delta
+				}
+				roots1[i] = (roots1[i] + delta) % p
+				roots2[i] = (roots2[i] + delta) % p
+			}
+			if e%2 == 1 {
+				b.Sub(b, &twoB)
+			} else {
+				b.Add(b, &twoB)
+			}
+		}
+		c.Mul(b, b)
+		c.Sub(c, s.kn)
+		c.Quo(c, a)
+
+		for i := range sieve {
+			sieve[i] = 0
+		}
+		for i := s.firstSieved; i < len(s.primes); i++ {
+			if isAFactor[i] {
+				continue
+			}
+			p := s.primes[i]
+			lg := s.logs[i]
+			for j := roots1[i]; j < len(sieve); j += p {
+				sieve[j] += lg
+			}
+			if roots2[i] == roots1[i] {
+				continue
+			}
+			for j := roots2[i]; j < len(sieve); j += p {
+				sieve[j] += lg
+			}
+		}
+		for j, v := range sieve {
+			if v >= s.threshold {
+				s.checkCandidate(
+					j, a, b, c, aIdx, isAFactor, roots1, roots2)
+			}
+		}
+	}
+}
+
+// Factors Q(x) = (ax + 2b)x + c for x = j - M over the factor base,
+// and adds a relation for it if it's smooth, or smooth except for a
+// large prime.
+func (s *siqsState) checkCandidate(
+	j int, a, b, c *big.Int, aIdx []int, isAFactor []bool,
+	roots1, roots2 []int) {
+	x := big.NewInt(int64(j - s.sieveHalf))
+	var q big.Int
+	q.Mul(a, x)
+	q.Add(&q, b)
+	q.Add(&q, b)
+	q.Mul(&q, x)
+	q.Add(&q, c)
+	if q.Sign() == 0 {
+		return
+	}
+
+	// aQ(x) = (ax + b)^2 - kn, so y = ax + b.
+	y := new(big.Int).Mul(a, x)
+	y.Add(y, b)
+	y.Mod(y, s.n)
+	var cols []int
+	if q.Sign() < 0 {
+		cols = append(cols, 0)
+		q.Neg(&q)
+	}
+	for k := q.TrailingZeroBits(); k > 0; k-- {
+		cols = append(cols, 1)
+	}
+	q.Rsh(&q, q.TrailingZeroBits())
+	var quo, rem big.Int
+	for i := 1; i < len(s.primes); i++ {
+		p := s.primes[i]
+		if !isAFactor[i] && j%p != roots1[i] && j%p != roots2[i] {
+			continue
+		}
+		for {
+			quo.QuoRem(&q, s.bigPrimes[i], &rem)
+			if rem.Sign() != 0 {
+				break
+			}
+			q.Set(&quo)
+			cols = append(cols, i+1)
+		}
+	}
+	for _, i := range aIdx {
+		cols = append(cols, i+1)
+	}
+
+	one := big.NewInt(1)
+	if q.Cmp(one) == 0 {
+		s.relations = append(s.relations, siqsRelation{y, cols, one})
+		return
+	}
+	if q.Cmp(s.lpMax) > 0 {
+		return
+	}
+	// q has no factor less than the largest prime in the factor
+	// base (since any prime dividing Q(x) either divides kn or is
+	// in the factor base), so q is prime. Two partial relations
+	// with the same q make a full one.
+	key := q.String()
+	other, ok := s.partials[key]
+	if !ok {
+		s.partials[key] = siqsRelation{y, cols, new(big.Int).Set(&q)}
+		return
+	}
+	if other.y.Cmp(y) == 0 {
+		return
+	}
+	y.Mul(y, other.y)
+	y.Mod(y, s.n)
+	combined := append(append([]int{}, other.cols...), cols...)
+	s.relations = append(s.relations, siqsRelation{
+		y, combined, new(big.Int).Set(&q),
+	})
+}
+
+// Finds the subsets of the relations whose products are squares on
+// both sides, and returns the first nontrivial factor of n that one of
+// them gives, or nil if none does.
+func (s *siqsState) findFactor() *big.Int {
+	ncols := len(s.primes) + 1
+	rows := make([]bitVector, len(s.relations))
+	for i, r := range s.relations {
+		rows[i] = newBitVector(ncols)
+		for _, col := range r.cols {
+			rows[i].flip(col)
+		}
+	}
+
+	one := big.NewInt(1)
+	for _, dep := range findDependencies(rows, ncols) {
+		// x^2 = prod y_i^2 = prod (factor base entries) L_i^2,
+		// whose square root is y.
+		x := big.NewInt(1)
+		y := big.NewInt(1)
+		counts := make([]int, ncols)
+		for _, i := range dep {
+			r := s.relations[i]
+			x.Mul(x, r.y)
+			x.Mod(x, s.n)
+			y.Mul(y, r.large)
+			y.Mod(y, s.n)
+			for _, col := range r.cols {
+				counts[col]++
+			}
+		}
+		var t big.Int
+		for col := 1; col < ncols; col++ {
+			if counts[col] == 0 {
+				continue
+			}
+			t.Exp(s.bigPrimes[col-1], big.NewInt(int64(counts[col]/2)), s.n)
+			y.Mul(y, &t)
+			y.Mod(y, s.n)
+		}
+		t.Sub(x, y)
+		t.GCD(nil, nil, &t, s.n)
+		if t.Cmp(one) != 0 && t.Cmp(s.n) != 0 {
+			return &t
+		}
+	}
+	return nil
+}
@@ -0,0 +1,43 @@
+package factor
+
+import "context"
+import "math/big"
+import "testing"
+
+// invMod and powMod should agree with math/big.
+func TestModArithmetic(t *testing.T) {
+	for _, p := range []int{3, 101, 65537, 1000003} {
+		bigP := big.NewInt(int64(p))
+		for _, x := range []int{1, 2, 5, p - 1} {
+			var expected big.Int
+			expected.ModInverse(big.NewInt(int64(x)), bigP)
+			if y := invMod(x, p); int64(y) != expected.Int64() {
+				t.Error(x, p, y, &expected)
+			}
+			expected.Exp(big.NewInt(int64(x)), big.NewInt(12345), bigP)
+			if y := powMod(x, 12345, p); int64(y) != expected.Int64() {
+				t.Error(x, p, y, &expected)
+			}
+		}
+	}
+}
+
+// siqs should factor numbers at the boundaries of the parameter
+// table.
+func TestSIQS(t *testing.T) {
+	for _, factors := range [][]string{
+		// 80 bits.
+		{"1099511627791", "824633720837"},
+		// 130 bits.
+		{"36893488147419103363", "27670116110564327479"},
+	} {
+		n := mustMultiply(t, factors...)
+		d, err := siqs(context.Background(), n)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if d.String() != factors[0] && d.String() != factors[1] {
+			t.Error(n, d)
+		}
+	}
+}
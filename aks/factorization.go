@@ -0,0 +1,248 @@
+package aks
+
+import "fmt"
+import "iter"
+import "math/big"
+import "sort"
+
+// A PrimePower is a prime P raised to the power K >= 1.
+type PrimePower struct {
+	P, K *big.Int
+}
+
+// Holds what is known about the prime factors of N > 0, which is the
+// product of Factors and Cofactor.
+type Factorization struct {
+	N *big.Int
+	// The prime factors found, in ascending order of P (which are
+	// all distinct).
+	Factors []PrimePower
+	// The part of N not factored yet, which has no factors in
+	// common with Factors. It is 1 if Complete.
+	Cofactor *big.Int
+	// Whether Factors lists every prime factor of N.
+	Complete bool
+}
+
+// Returns the complete factorization of n, which must be positive,
+// found as described in EulerPhi. Returns an error wrapping
+// ErrBadInput if n isn't positive.
+func Factorize(n *big.Int) (*Factorization, error) {
+	if n.Sign() <= 0 {
+		return nil, fmt.Errorf(
+			"%w: n = %v must be positive", ErrBadInput, n)
+	}
+	return calculateFactorization(n), nil
+}
+
+// Like Factorize, but assumes n is positive.
+func calculateFactorization(n *big.Int) *Factorization {
+	f := &Factorization{
+		N:        n,
+		Cofactor: big.NewInt(1),
+		Complete: true,
+	}
+	for p, k := range Factors(n) {
+		f.Factors = append(f.Factors, PrimePower{p, k})
+	}
+	return f
+}
+
+// Returns an iterator over the distinct prime factors of n and their
+// multiplicities, in ascending order of prime, found as described in
+// EulerPhi. Factoring stops when the loop over the iterator does, so
+// breaking out early saves the work of finding the larger factors.
+// Each prime and multiplicity is a new big.Int, which the caller may
+// keep. Nothing is yielded for n < 2.
+func Factors(n *big.Int) iter.Seq2[*big.Int, *big.Int] {
+	return func(yield func(*big.Int, *big.Int) bool) {
+		if n.Cmp(big.NewInt(2)) < 0 {
+			return
+		}
+		factorize(n, func(p, k *big.Int) bool {
+			// trialDivide reuses p and k.
+			return yield(new(big.Int).Set(p), new(big.Int).Set(k))
+		})
+	}
+}
+
+// Returns the factorization of n found by trial division up to bound,
+// which may be incomplete: its Cofactor is what's left of n after
+// dividing out the primes up to bound, unless that is 1 or prime (as
+// decided by big.Int.ProbablyPrime, which is exact below 2^64), in
+// which case it's in Factors instead and the factorization is
+// Complete. Returns an error wrapping ErrBadInput if n isn't positive
+// or bound is negative.
+func FactorBounded(n, bound *big.Int) (*Factorization, error) {
+	if n.Sign() <= 0 {
+		return nil, fmt.Errorf(
+			"%w: n = %v must be positive", ErrBadInput, n)
+	}
+	if bound.Sign() < 0 {
+		return nil, fmt.Errorf(
+			"%w: bound = %v must be non-negative", ErrBadInput,
+			bound)
+	}
+	return calculatePartialFactorization(n, bound), nil
+}
+
+// Returns the factorization of n > 0 found by trial division up to
+// bound >= 0. Its Cofactor has no prime factors up to bound, and is
+// only left unfactored if it's composite (as decided by
+// big.Int.ProbablyPrime, which is exact below 2^64).
+func calculatePartialFactorization(n, bound *big.Int) *Factorization {
+	f := &Factorization{
+		N:        n,
+		Cofactor: big.NewInt(1),
+		Complete: true,
+	}
+	trialDivide(n, func(p, k *big.Int) bool {
+		// Only what is left after trial division can be
+		// larger than bound.
+		if p.Cmp(bound) > 0 && !p.ProbablyPrime(primalityRounds) {
+			f.Cofactor.Set(p)
+			f.Complete = false
+			return true
+		}
+		f.Factors = append(f.Factors, PrimePower{
+			new(big.Int).Set(p), new(big.Int).Set(k),
+		})
+		return true
+	}, bound)
+	return f
+}
+
+// Returns the product of f.Factors and f.Cofactor, which should equal
+// f.N.
+func (f *Factorization) Recombine() *big.Int {
+	n := new(big.Int).Set(f.Cofactor)
+	var t big.Int
+	for _, pk := range f.Factors {
+		n.Mul(n, t.Exp(pk.P, pk.K, nil))
+	}
+	return n
+}
+
+// Returns an error wrapping ErrIncompleteFactorization if f isn't
+// complete.
+func (f *Factorization) checkComplete() error {
+	if !f.Complete {
+		return fmt.Errorf(
+			"%w: cofactor %v of %v is unfactored",
+			ErrIncompleteFactorization, f.Cofactor, f.N)
+	}
+	return nil
+}
+
+// Returns the divisors of f.N in ascending order, or an error
+// wrapping ErrIncompleteFactorization if f isn't complete. There are
+// prod (K + 1) of them, so this is only practical for N with few
+// prime factors.
+func (f *Factorization) Divisors() ([]*big.Int, error) {
+	if err := f.checkComplete(); err != nil {
+		return nil, err
+	}
+	divisors := []*big.Int{big.NewInt(1)}
+	one := big.NewInt(1)
+	for _, pk := range f.Factors {
+		count := len(divisors)
+		pi := big.NewInt(1)
+		for i := big.NewInt(1); i.Cmp(pk.K) <= 0; i.Add(i, one) {
+			pi = new(big.Int).Mul(pi, pk.P)
+			for _, d := range divisors[:count] {
+				divisors = append(
+					divisors, new(big.Int).Mul(d, pi))
+			}
+		}
+	}
+	sort.Slice(divisors, func(i, j int) bool {
+		return divisors[i].Cmp(divisors[j]) < 0
+	})
+	return divisors, nil
+}
+
+// Returns Phi(f.N), or an error wrapping ErrIncompleteFactorization
+// if f isn't complete.
+func (f *Factorization) EulerPhi() (*big.Int, error) {
+	if err := f.checkComplete(); err != nil {
+		return nil, err
+	}
+	phi := big.NewInt(1)
+	for _, pk := range f.Factors {
+		phi.Mul(phi, calculateEulerPhiPrimePower(pk.P, pk.K))
+	}
+	return phi, nil
+}
+
+// Returns the Mobius function mu(f.N): 0 if N has a square factor,
+// and otherwise (-1)^(the number of prime factors of N). Returns an
+// error wrapping ErrIncompleteFactorization if f isn't complete.
+func (f *Factorization) Mobius() (int, error) {
+	if err := f.checkComplete(); err != nil {
+		return 0, err
+	}
+	mu := 1
+	for _, pk := range f.Factors {
+		if pk.K.Cmp(big.NewInt(1)) > 0 {
+			return 0, nil
+		}
+		mu = -mu
+	}
+	return mu, nil
+}
+
+// Returns the number of divisors of f.N, which is prod (K + 1), or an
+// error wrapping ErrIncompleteFactorization if f isn't complete.
+func (f *Factorization) NumDivisors() (*big.Int, error) {
+	if err := f.checkComplete(); err != nil {
+		return nil, err
+	}
+	d := big.NewInt(1)
+	var t big.Int
+	for _, pk := range f.Factors {
+		d.Mul(d, t.Add(pk.K, big.NewInt(1)))
+	}
+	return d, nil
+}
+
+// Returns the sum of the divisors of f.N, which is prod (P^(K+1) -
+// 1)/(P - 1), or an error wrapping ErrIncompleteFactorization if f
+// isn't complete.
+func (f *Factorization) SumDivisors() (*big.Int, error) {
+	if err := f.checkComplete(); err != nil {
+		return nil, err
+	}
+	one := big.NewInt(1)
+	sigma := big.NewInt(1)
+	var num, den big.Int
+	for _, pk := range f.Factors {
+		num.Add(pk.K, one)
+		num.Exp(pk.P, &num, nil)
+		num.Sub(&num, one)
+		den.Sub(pk.P, one)
+		sigma.Mul(sigma, num.Quo(&num, &den))
+	}
+	return sigma, nil
+}
+
+// Returns Carmichael's function lambda(f.N), the exponent of the
+// multiplicative group mod f.N (so that a^lambda(N) = 1 (mod N) for
+// every a coprime to N), or an error wrapping
+// ErrIncompleteFactorization if f isn't complete.
+func (f *Factorization) Lambda() (*big.Int, error) {
+	if err := f.checkComplete(); err != nil {
+		return nil, err
+	}
+	lambda := big.NewInt(1)
+	for _, pk := range f.Factors {
+		// lambda(p^k) = Phi(p^k), except that lambda(2^k) =
+		// 2^(k-2) for k >= 3.
+		l := calculateEulerPhiPrimePower(pk.P, pk.K)
+		if pk.P.Cmp(big.NewInt(2)) == 0 && pk.K.Cmp(big.NewInt(3)) >= 0 {
+			l.Rsh(l, 1)
+		}
+		lambda.Div(lambda, gcd(lambda, l))
+		lambda.Mul(lambda, l)
+	}
+	return lambda, nil
+}
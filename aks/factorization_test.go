@@ -0,0 +1,211 @@
+package aks
+
+import "errors"
+import "fmt"
+import "math/big"
+import "testing"
+
+// Factorize should return complete factorizations in ascending order.
+func TestFactorizeResult(t *testing.T) {
+	tests := []struct {
+		n        int64
+		expected string
+	}{
+		{1, "[]"},
+		{2, "[{2 1}]"},
+		{720720, "[{2 4} {3 2} {5 1} {7 1} {11 1} {13 1}]"},
+		{1000003 * 1000033, "[{1000003 1} {1000033 1}]"},
+	}
+	for _, test := range tests {
+		f, err := Factorize(big.NewInt(test.n))
+		if err != nil {
+			t.Fatal(test.n, err)
+		}
+		if s := fmt.Sprint(f.Factors); s != test.expected ||
+			!f.Complete || f.Cofactor.Int64() != 1 ||
+			f.Recombine().Int64() != test.n {
+			t.Error(test.n, s, f.Complete, f.Cofactor)
+		}
+	}
+
+	for _, n := range []int64{-1, 0} {
+		if _, err := Factorize(big.NewInt(n)); !errors.Is(
+			err, ErrBadInput) {
+			t.Error(n, err)
+		}
+	}
+}
+
+// Factors should yield the same factors as Factorize, and stop
+// factoring when the loop over it does.
+func TestFactors(t *testing.T) {
+	for _, n := range []int64{
+		-6, 0, 1, 2, 720720, 1000003 * 1000033, 1 << 62,
+	} {
+		var factors []PrimePower
+		for p, k := range Factors(big.NewInt(n)) {
+			factors = append(factors, PrimePower{p, k})
+		}
+		var expected []PrimePower
+		if n > 0 {
+			f, err := Factorize(big.NewInt(n))
+			if err != nil {
+				t.Fatal(n, err)
+			}
+			expected = f.Factors
+		}
+		if fmt.Sprint(factors) != fmt.Sprint(expected) {
+			t.Error(n, factors, expected)
+		}
+	}
+
+	// The rest of n would take far too long to factor.
+	n := new(big.Int).Lsh(big.NewInt(1), 521)
+	n.Sub(n, big.NewInt(1))
+	n.Mul(n, new(big.Int).Add(n, big.NewInt(2)))
+	n.Mul(n, big.NewInt(12))
+	var primes []*big.Int
+	for p := range Factors(n) {
+		primes = append(primes, p)
+		if len(primes) == 2 {
+			break
+		}
+	}
+	if s := fmt.Sprint(primes); s != "[2 3]" {
+		t.Error(s)
+	}
+}
+
+// FactorBounded should report what it couldn't factor, and reject
+// bad input.
+func TestFactorBounded(t *testing.T) {
+	// 2^64 + 1 = 274177 * 67280421310721.
+	n := new(big.Int).Lsh(big.NewInt(1), 64)
+	n.Add(n, big.NewInt(1))
+	tests := []struct {
+		bound    int64
+		factors  string
+		cofactor string
+	}{
+		{0, "[]", n.String()},
+		{1000, "[]", n.String()},
+		{274177, "[{274177 1} {67280421310721 1}]", "1"},
+	}
+	for _, test := range tests {
+		f, err := FactorBounded(n, big.NewInt(test.bound))
+		if err != nil {
+			t.Fatal(test.bound, err)
+		}
+		if s := fmt.Sprint(f.Factors); s != test.factors ||
+			f.Cofactor.String() != test.cofactor ||
+			f.Complete != (test.cofactor == "1") ||
+			f.Recombine().Cmp(n) != 0 {
+			t.Error(test.bound, s, f.Cofactor, f.Complete)
+		}
+	}
+
+	for _, c := range [][2]int64{{0, 10}, {-1, 10}, {10, -1}} {
+		if _, err := FactorBounded(
+			big.NewInt(c[0]), big.NewInt(c[1])); !errors.Is(
+			err, ErrBadInput) {
+			t.Error(c, err)
+		}
+	}
+}
+
+// calculatePartialFactorization should only leave composites with no
+// small factors unfactored.
+func TestCalculatePartialFactorization(t *testing.T) {
+	tests := []struct {
+		n, bound int64
+		factors  string
+		cofactor int64
+	}{
+		{720720, 5, "[{2 4} {3 2} {5 1}]", 7 * 11 * 13},
+		{720720, 13, "[{2 4} {3 2} {5 1} {7 1} {11 1} {13 1}]", 1},
+		{2 * 1009 * 1013, 100, "[{2 1}]", 1009 * 1013},
+		{2 * 1009 * 1013, 1009, "[{2 1} {1009 1} {1013 1}]", 1},
+		// The cofactor 1000003 is prime, even though it's
+		// larger than the bound squared.
+		{4 * 1000003, 10, "[{2 2} {1000003 1}]", 1},
+	}
+	for _, test := range tests {
+		f := calculatePartialFactorization(
+			big.NewInt(test.n), big.NewInt(test.bound))
+		if s := fmt.Sprint(f.Factors); s != test.factors ||
+			f.Cofactor.Int64() != test.cofactor ||
+			f.Complete != (test.cofactor == 1) ||
+			f.Recombine().Int64() != test.n {
+			t.Error(test.n, test.bound, s, f.Cofactor, f.Complete)
+		}
+	}
+}
+
+// The methods of Factorization should match the definitions of the
+// functions they compute.
+func TestFactorizationMethods(t *testing.T) {
+	tests := []struct {
+		n, phi, lambda int64
+		divisors       string
+	}{
+		{1, 1, 1, "[1]"},
+		{2, 1, 1, "[1 2]"},
+		{8, 4, 2, "[1 2 4 8]"},
+		{12, 4, 2, "[1 2 3 4 6 12]"},
+		{45, 24, 12, "[1 3 5 9 15 45]"},
+		{720720, 138240, 60, ""},
+	}
+	for _, test := range tests {
+		f, err := Factorize(big.NewInt(test.n))
+		if err != nil {
+			t.Fatal(test.n, err)
+		}
+		phi, err := f.EulerPhi()
+		if err != nil || phi.Int64() != test.phi {
+			t.Error(test.n, phi, err)
+		}
+		lambda, err := f.Lambda()
+		if err != nil || lambda.Int64() != test.lambda {
+			t.Error(test.n, lambda, err)
+		}
+		divisors, err := f.Divisors()
+		if err != nil {
+			t.Fatal(test.n, err)
+		}
+		if test.divisors != "" && fmt.Sprint(divisors) != test.divisors {
+			t.Error(test.n, divisors)
+		}
+		var m big.Int
+		for i, d := range divisors {
+			if m.Mod(big.NewInt(test.n), d).Sign() != 0 ||
+				(i > 0 && divisors[i-1].Cmp(d) >= 0) {
+				t.Error(test.n, d)
+			}
+		}
+	}
+
+	// 720720 has (4 + 1)(2 + 1)(1 + 1)^4 divisors.
+	f, _ := Factorize(big.NewInt(720720))
+	if divisors, _ := f.Divisors(); len(divisors) != 240 {
+		t.Error(len(divisors))
+	}
+
+	// Only Recombine works on incomplete factorizations.
+	f = &Factorization{
+		N:        big.NewInt(60),
+		Factors:  []PrimePower{{big.NewInt(2), big.NewInt(2)}},
+		Cofactor: big.NewInt(15),
+	}
+	if n := f.Recombine(); n.Int64() != 60 {
+		t.Error(n)
+	}
+	if _, err := f.Divisors(); !errors.Is(err, ErrIncompleteFactorization) {
+		t.Error(err)
+	}
+	if _, err := f.EulerPhi(); !errors.Is(err, ErrIncompleteFactorization) {
+		t.Error(err)
+	}
+	if _, err := f.Lambda(); !errors.Is(err, ErrIncompleteFactorization) {
+		t.Error(err)
+	}
+}
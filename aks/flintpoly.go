@@ -0,0 +1,354 @@
+//go:build flint && cgo
+
+package aks
+
+/*
+#cgo LDFLAGS: -lflint -lgmp
+#include <flint/fmpz.h>
+#include <flint/fmpz_mod.h>
+#include <flint/fmpz_mod_poly.h>
+#include <gmp.h>
+#include <stdlib.h>
+
+// Holds what's shared by the polys of a flintPolyRing.
+typedef struct {
+  fmpz_mod_ctx_t ctx;
+  // X^r - 1.
+  fmpz_mod_poly_t modulus;
+  long r;
+} aks_flint_ring;
+
+static aks_flint_ring *aks_flint_ring_new(mpz_srcptr n, long r) {
+  aks_flint_ring *ring = malloc(sizeof(aks_flint_ring));
+  fmpz_t nf;
+  fmpz_init(nf);
+  fmpz_set_mpz(nf, n);
+  fmpz_mod_ctx_init(ring->ctx, nf);
+  fmpz_clear(nf);
+  fmpz_mod_poly_init(ring->modulus, ring->ctx);
+  fmpz_mod_poly_set_coeff_ui(ring->modulus, r, 1, ring->ctx);
+  fmpz_mod_poly_set_coeff_si(ring->modulus, 0, -1, ring->ctx);
+  ring->r = r;
+  return ring;
+}
+
+static void aks_flint_ring_free(aks_flint_ring *ring) {
+  fmpz_mod_poly_clear(ring->modulus, ring->ctx);
+  fmpz_mod_ctx_clear(ring->ctx);
+  free(ring);
+}
+
+static fmpz_mod_poly_struct *aks_flint_poly_new(aks_flint_ring *ring) {
+  fmpz_mod_poly_struct *p = malloc(sizeof(fmpz_mod_poly_struct));
+  fmpz_mod_poly_init2(p, ring->r, ring->ctx);
+  return p;
+}
+
+static void aks_flint_poly_free(
+    fmpz_mod_poly_struct *p, aks_flint_ring *ring) {
+  fmpz_mod_poly_clear(p, ring->ctx);
+  free(p);
+}
+
+// Sets p to X^k + a, where a < n and k < r.
+static void aks_flint_poly_set(
+    fmpz_mod_poly_struct *p, mpz_srcptr a, long k, aks_flint_ring *ring) {
+  fmpz_t c, af;
+  fmpz_init(c);
+  fmpz_init(af);
+  fmpz_set_mpz(af, a);
+  fmpz_mod_poly_zero(p, ring->ctx);
+  fmpz_mod_poly_set_coeff_ui(p, k, 1, ring->ctx);
+  fmpz_mod_poly_get_coeff_fmpz(c, p, 0, ring->ctx);
+  fmpz_mod_add(c, c, af, ring->ctx);
+  fmpz_mod_poly_set_coeff_fmpz(p, 0, c, ring->ctx);
+  fmpz_clear(af);
+  fmpz_clear(c);
+}
+
+static void aks_flint_poly_copy(
+    fmpz_mod_poly_struct *p, const fmpz_mod_poly_struct *q,
+    aks_flint_ring *ring) {
+  fmpz_mod_poly_set(p, q, ring->ctx);
+}
+
+static int aks_flint_poly_equal(
+    const fmpz_mod_poly_struct *p, const fmpz_mod_poly_struct *q,
+    aks_flint_ring *ring) {
+  return fmpz_mod_poly_equal(p, q, ring->ctx);
+}
+
+// Sets out to p*q mod (n, X^r - 1).
+static void aks_flint_poly_mul(
+    fmpz_mod_poly_struct *out, const fmpz_mod_poly_struct *p,
+    const fmpz_mod_poly_struct *q, aks_flint_ring *ring) {
+  fmpz_mod_poly_mulmod(out, p, q, ring->modulus, ring->ctx);
+}
+
+// Sets out to p^e mod (n, X^r - 1).
+static void aks_flint_poly_pow(
+    fmpz_mod_poly_struct *out, const fmpz_mod_poly_struct *p,
+    mpz_srcptr e, aks_flint_ring *ring) {
+  fmpz_t ef;
+  fmpz_init(ef);
+  fmpz_set_mpz(ef, e);
+  fmpz_mod_poly_powmod_fmpz_binexp(out, p, ef, ring->modulus, ring->ctx);
+  fmpz_clear(ef);
+}
+
+// Sets out to the coefficient of X^i in p.
+static void aks_flint_poly_get_coeff(
+    mpz_ptr out, const fmpz_mod_poly_struct *p, long i,
+    aks_flint_ring *ring) {
+  fmpz_t c;
+  fmpz_init(c);
+  fmpz_mod_poly_get_coeff_fmpz(c, p, i, ring->ctx);
+  fmpz_get_mpz(out, c);
+  fmpz_clear(c);
+}
+
+// Sets the coefficient of X^i in p to c, which must be less than N.
+static void aks_flint_poly_set_coeff(
+    fmpz_mod_poly_struct *p, long i, mpz_srcptr c, aks_flint_ring *ring) {
+  fmpz_t cf;
+  fmpz_init(cf);
+  fmpz_set_mpz(cf, c);
+  fmpz_mod_poly_set_coeff_fmpz(p, i, cf, ring->ctx);
+  fmpz_clear(cf);
+}
+
+static long aks_flint_poly_length(
+    const fmpz_mod_poly_struct *p, aks_flint_ring *ring) {
+  return fmpz_mod_poly_length(p, ring->ctx);
+}
+*/
+import "C"
+
+import "encoding/json"
+import "fmt"
+import "math/big"
+import "runtime"
+
+// Whether FLINTPolyBackend is available in this build.
+const haveFLINTPolyBackend = true
+
+// A flintPolyRing makes flintPolys.
+type flintPolyRing struct {
+	N, R big.Int
+	ring *C.aks_flint_ring
+}
+
+// Makes a flintPolyRing for polynomials mod (N, X^R - 1).
+func newFLINTPolyRing(N, R big.Int) (*flintPolyRing, error) {
+	n := newMPZs(1, N.BitLen())
+	defer freeMPZs(n)
+	setMPZ(&n[0], &N)
+	r := &flintPolyRing{
+		N:    N,
+		R:    R,
+		ring: C.aks_flint_ring_new(&n[0], C.long(R.Int64())),
+	}
+	runtime.SetFinalizer(r, func(r *flintPolyRing) {
+		C.aks_flint_ring_free(r.ring)
+	})
+	return r, nil
+}
+
+// polyRing implementation.
+func (r *flintPolyRing) newPoly() poly {
+	p := &flintPoly{ring: r, p: C.aks_flint_poly_new(r.ring)}
+	runtime.SetFinalizer(p, (*flintPoly).free)
+	return p
+}
+
+// polyRing implementation. This is only an estimate, since FLINT
+// allocates coefficients as needed.
+func (r *flintPolyRing) getPolyBytes() int64 {
+	return calculateBigWordBytes(r.R.Int64() * int64(len(r.N.Bits())))
+}
+
+// A flintPoly represents a polynomial mod (N, X^R - 1) as a FLINT
+// fmpz_mod_poly.
+//
+// Unlike the other backends, a flintPoly is raised to a power
+// entirely by FLINT (see Pow), which is one of the fastest
+// implementations of polynomial arithmetic available.
+type flintPoly struct {
+	ring *flintPolyRing
+	// Allocated by aks_flint_poly_new and freed by a finalizer,
+	// so the flintPoly must be kept alive (with runtime.KeepAlive)
+	// until C is done with it.
+	p *C.fmpz_mod_poly_struct
+}
+
+// Frees p's poly. Used as p's finalizer.
+func (p *flintPoly) free() {
+	C.aks_flint_poly_free(p.p, p.ring.ring)
+}
+
+// Calls panicModulusMismatch if p and q aren't mod the same N and R.
+func (p *flintPoly) checkModulus(op string, q *flintPoly) {
+	if q.ring != p.ring && (q.ring.N.Cmp(&p.ring.N) != 0 ||
+		q.ring.R.Cmp(&p.ring.R) != 0) {
+		panicModulusMismatch(
+			op, &p.ring.N, int(p.ring.R.Int64()),
+			&q.ring.N, int(q.ring.R.Int64()))
+	}
+}
+
+// poly implementation.
+func (p *flintPoly) Set(a, k, N big.Int) {
+	var aModN, kModR big.Int
+	aModN.Mod(&a, &N)
+	kModR.Mod(&k, &p.ring.R)
+	mpzA := newMPZs(1, aModN.BitLen())
+	defer freeMPZs(mpzA)
+	setMPZ(&mpzA[0], &aModN)
+	C.aks_flint_poly_set(p.p, &mpzA[0], C.long(kModR.Int64()), p.ring.ring)
+	runtime.KeepAlive(p)
+}
+
+// poly implementation.
+func (p *flintPoly) copyFrom(q poly) {
+	qPoly := q.(*flintPoly)
+	p.checkModulus("copy", qPoly)
+	C.aks_flint_poly_copy(p.p, qPoly.p, p.ring.ring)
+	runtime.KeepAlive(p)
+	runtime.KeepAlive(qPoly)
+}
+
+// poly implementation.
+func (p *flintPoly) clone() poly {
+	c := p.ring.newPoly()
+	c.copyFrom(p)
+	return c
+}
+
+// poly implementation.
+func (p *flintPoly) Eq(q poly) bool {
+	qPoly := q.(*flintPoly)
+	p.checkModulus("compare", qPoly)
+	eq := C.aks_flint_poly_equal(p.p, qPoly.p, p.ring.ring) != 0
+	runtime.KeepAlive(p)
+	runtime.KeepAlive(qPoly)
+	return eq
+}
+
+// poly implementation.
+func (p *flintPoly) Mul(q poly, N big.Int, tmp poly) {
+	qPoly := q.(*flintPoly)
+	p.checkModulus("multiply", qPoly)
+	tmpPoly := tmp.(*flintPoly)
+	C.aks_flint_poly_mul(tmpPoly.p, p.p, qPoly.p, p.ring.ring)
+	runtime.KeepAlive(qPoly)
+	p.p, tmpPoly.p = tmpPoly.p, p.p
+}
+
+// poly implementation.
+func (p *flintPoly) Pow(N big.Int, tmp1, tmp2 poly) {
+	p.powCancelable(N, tmp1, tmp2, nil, nil)
+}
+
+// poly implementation. Since FLINT does the whole computation, done
+// is only checked before starting, and onMul is called as many times
+// as Pow would multiply with square-and-multiply after FLINT is
+// finished.
+func (p *flintPoly) powCancelable(
+	N big.Int, tmp1Poly, tmp2Poly poly,
+	done <-chan struct{}, onMul func()) bool {
+	select {
+	case <-done:
+		return false
+	default:
+	}
+
+	tmp1 := tmp1Poly.(*flintPoly)
+	e := newMPZs(1, N.BitLen())
+	defer freeMPZs(e)
+	setMPZ(&e[0], &N)
+	C.aks_flint_poly_pow(tmp1.p, p.p, &e[0], p.ring.ring)
+	p.p, tmp1.p = tmp1.p, p.p
+
+	if onMul != nil {
+		for i := N.BitLen() - 2; i >= 0; i-- {
+			onMul()
+			if N.Bit(i) != 0 {
+				onMul()
+			}
+		}
+	}
+	return true
+}
+
+// poly implementation.
+func (p *flintPoly) coefficient(i int) *big.Int {
+	c := newMPZs(1, p.ring.N.BitLen())
+	defer freeMPZs(c)
+	C.aks_flint_poly_get_coeff(&c[0], p.p, C.long(i), p.ring.ring)
+	runtime.KeepAlive(p)
+	return getMPZ(&c[0])
+}
+
+// poly implementation.
+func (p *flintPoly) setCoefficient(i int, c *big.Int) {
+	mpzC := newMPZs(1, c.BitLen())
+	defer freeMPZs(mpzC)
+	setMPZ(&mpzC[0], c)
+	C.aks_flint_poly_set_coeff(p.p, C.long(i), &mpzC[0], p.ring.ring)
+	runtime.KeepAlive(p)
+}
+
+// poly implementation.
+func (p *flintPoly) getPhiBytes() int64 {
+	return p.ring.getPolyBytes()
+}
+
+// fmt.Formatter implementation.
+func (p *flintPoly) Format(f fmt.State, c rune) {
+	coefficientCount := int(C.aks_flint_poly_length(p.p, p.ring.ring))
+	formatPoly(f, coefficientCount, func(i int) big.Int {
+		return *p.coefficient(i)
+	})
+}
+
+var _ poly = (*flintPoly)(nil)
+
+// gob.GobEncoder implementation.
+func (p *flintPoly) GobEncode() ([]byte, error) {
+	return marshalPoly(p, p.ring.N, p.ring.R, gobMarshal)
+}
+
+// gob.GobDecoder implementation.
+func (p *flintPoly) GobDecode(data []byte) error {
+	return p.unmarshal(data, gobUnmarshal)
+}
+
+// json.Marshaler implementation.
+func (p *flintPoly) MarshalJSON() ([]byte, error) {
+	return marshalPoly(p, p.ring.N, p.ring.R, json.Marshal)
+}
+
+// json.Unmarshaler implementation.
+func (p *flintPoly) UnmarshalJSON(data []byte) error {
+	return p.unmarshal(data, json.Unmarshal)
+}
+
+// Sets p to data, as marshaled by GobEncode or MarshalJSON (possibly
+// by another backend), with unmarshal.
+func (p *flintPoly) unmarshal(
+	data []byte, unmarshal func([]byte, interface{}) error) error {
+	q, err := unmarshalPoly(data, unmarshal, FLINTPolyBackend)
+	if err != nil {
+		return err
+	}
+	// Swap p and q, so that q's finalizer frees p's old poly,
+	// as in mpzPoly.unmarshal.
+	qp := q.(*flintPoly)
+	*p, *qp = *qp, *p
+	if qp.p == nil {
+		runtime.SetFinalizer(qp, nil)
+	}
+	runtime.SetFinalizer(p, nil)
+	runtime.SetFinalizer(p, (*flintPoly).free)
+	return nil
+}
@@ -0,0 +1,17 @@
+//go:build !flint || !cgo
+
+package aks
+
+import "fmt"
+import "math/big"
+
+// Whether FLINTPolyBackend is available in this build.
+const haveFLINTPolyBackend = false
+
+// Returns an error, since FLINTPolyBackend needs the package to be
+// built with cgo and the flint build tag.
+func newFLINTPolyRing(N, R big.Int) (polyRing, error) {
+	return nil, fmt.Errorf(
+		"%w: the %v backend requires building with cgo and -tags flint",
+		ErrBackendUnavailable, FLINTPolyBackend)
+}
@@ -0,0 +1,49 @@
+//go:build flint && cgo
+
+package aks
+
+import "context"
+import "math/big"
+import "testing"
+
+// flintPolyRing should pass checkPolyRing.
+func TestFLINTPolyRing(t *testing.T) {
+	checkPolyRing(t, func(N, R big.Int) polyRing {
+		ring, err := newFLINTPolyRing(N, R)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return ring
+	})
+}
+
+// flintPolyRing should pass checkPolyEncoding.
+func TestFLINTPolyEncoding(t *testing.T) {
+	checkPolyEncoding(t, func(N, R big.Int) polyRing {
+		ring, err := newFLINTPolyRing(N, R)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return ring
+	}, func() poly { return &flintPoly{} })
+}
+
+// RunAKS should reach the same verdicts with FLINTPolyBackend as with
+// the pure-Go backends.
+func TestRunAKSFLINT(t *testing.T) {
+	for _, n := range []int64{1000003, 2993374621} {
+		result, err := RunAKS(
+			context.Background(), big.NewInt(n),
+			&AKSOptions{Backend: FLINTPolyBackend})
+		if err != nil {
+			t.Fatal(n, err)
+		}
+		expected := Prime
+		if n == 2993374621 {
+			expected = Composite
+		}
+		if result.Verdict != expected {
+			t.Error(n, result.Verdict)
+		}
+	}
+}
@@ -0,0 +1,235 @@
+package aks
+
+import "fmt"
+import "math/big"
+import "math/bits"
+
+// Returns gcd(a, b) with Stein's binary GCD algorithm, which only
+// shifts and subtracts, and so is much faster on words than Euclid's
+// algorithm with its divisions. gcd(0, 0) = 0.
+func binaryGCD(a, b uint64) uint64 {
+	if a == 0 {
+		return b
+	}
+	if b == 0 {
+		return a
+	}
+	// The power of 2 dividing both is put back at the end, and
+	// after that only odd factors matter.
+	shift := bits.TrailingZeros64(a | b)
+	a >>= bits.TrailingZeros64(a)
+	for b != 0 {
+		b >>= bits.TrailingZeros64(b)
+		if a > b {
+			a, b = b, a
+		}
+		b -= a
+	}
+	return a << shift
+}
+
+// Returns gcd(n, r) for n >= 0, with n reduced mod r by a single
+// division, and the rest done by binaryGCD. This is the common case
+// of a GCD of a large number and a small one, as in the search for
+// the AKS modulus.
+func gcdUint64(n *big.Int, r uint64) uint64 {
+	if r == 0 {
+		// gcd(n, 0) = n, which is only representable if it
+		// fits.
+		return n.Uint64()
+	}
+	var m big.Int
+	return binaryGCD(m.Mod(n, m.SetUint64(r)).Uint64(), r)
+}
+
+// A uint128 is an unsigned 128-bit integer, for lehmerGCD.
+type uint128 struct {
+	hi, lo uint64
+}
+
+// Returns x, which must be in [0, 2^128), as a uint128.
+func uint128FromInt(x *big.Int) uint128 {
+	var u uint128
+	for i, w := range x.Bits() {
+		shift := uint(i * bits.UintSize)
+		if shift < 64 {
+			u.lo |= uint64(w) << shift
+		} else {
+			u.hi |= uint64(w) << (shift - 64)
+		}
+	}
+	return u
+}
+
+// Returns x as a big.Int.
+func (x uint128) toInt() *big.Int {
+	z := new(big.Int).SetUint64(x.hi)
+	z.Lsh(z, 64)
+	return z.Or(z, new(big.Int).SetUint64(x.lo))
+}
+
+// Returns whether x < y.
+func (x uint128) less(y uint128) bool {
+	return x.hi < y.hi || (x.hi == y.hi && x.lo < y.lo)
+}
+
+// Returns x + y mod 2^128.
+func (x uint128) add(y uint128) uint128 {
+	lo, carry := bits.Add64(x.lo, y.lo, 0)
+	hi, _ := bits.Add64(x.hi, y.hi, carry)
+	return uint128{hi, lo}
+}
+
+// Returns x - y mod 2^128.
+func (x uint128) sub(y uint128) uint128 {
+	lo, borrow := bits.Sub64(x.lo, y.lo, 0)
+	hi, _ := bits.Sub64(x.hi, y.hi, borrow)
+	return uint128{hi, lo}
+}
+
+// Returns the low word of x >> s.
+func (x uint128) rsh(s uint) uint64 {
+	if s >= 64 {
+		return x.hi >> (s - 64)
+	}
+	return x.hi<<(64-s) | x.lo>>s
+}
+
+// Returns x*c mod 2^128, for c > math.MinInt64.
+func (x uint128) mulInt64(c int64) uint128 {
+	m := uint64(c)
+	if c < 0 {
+		m = uint64(-c)
+	}
+	hi, lo := bits.Mul64(x.lo, m)
+	z := uint128{hi + x.hi*m, lo}
+	if c < 0 {
+		return uint128{}.sub(z)
+	}
+	return z
+}
+
+// Returns x mod y, where y >= 2^64.
+func (x uint128) mod(y uint128) uint128 {
+	// Estimate the quotient, which fits in a word, from the
+	// leading words of x and y shifted so that y's top bit is
+	// set. The estimate is at most 2 too large (see Knuth, TAOCP
+	// Vol. 2, 4.3.1, Theorem B).
+	s := uint(bits.LeadingZeros64(y.hi))
+	yHi := y.hi<<s | y.lo>>(64-s)
+	q, _ := bits.Div64(x.hi>>(64-s), x.hi<<s|x.lo>>(64-s), yHi)
+	// q*y = (p2, p), which is at most 2y too large.
+	loHi, loLo := bits.Mul64(q, y.lo)
+	hiHi, hiLo := bits.Mul64(q, y.hi)
+	pHi, carry := bits.Add64(hiLo, loHi, 0)
+	p2 := hiHi + carry
+	p := uint128{pHi, loLo}
+	for p2 != 0 || x.less(p) {
+		var borrow uint64
+		p.lo, borrow = bits.Sub64(p.lo, y.lo, 0)
+		p.hi, borrow = bits.Sub64(p.hi, y.hi, borrow)
+		p2 -= borrow
+	}
+	return x.sub(p)
+}
+
+// The number of leading bits lehmerGCD simulates Euclid's algorithm
+// on, which is small enough that the cofactors and the sums in the
+// quotient tests fit in an int64.
+const lehmerBits = 62
+
+// Returns gcd(x, y) with Lehmer's algorithm, which runs Euclid's
+// algorithm on the leading lehmerBits bits of x and y for as long as
+// the quotients are sure to be the same as for x and y themselves,
+// and then applies all those steps to x and y at once. Once y fits in
+// a word, binaryGCD finishes.
+//
+// big.Int.GCD also uses Lehmer's algorithm, but working on two words
+// instead of big.Ints saves its allocations, which makes this about
+// twice as fast for 128-bit numbers (see BenchmarkGCD128By128).
+func lehmerGCD(x, y uint128) uint128 {
+	if x.less(y) {
+		x, y = y, x
+	}
+	for y.hi != 0 {
+		shift := uint(64 + bits.Len64(x.hi) - lehmerBits)
+		xHat := int64(x.rsh(shift))
+		yHat := int64(y.rsh(shift))
+		// x = A*x' + B*y' and y = C*x' + D*y' for the x', y'
+		// at the start of this step.
+		A, B, C, D := int64(1), int64(0), int64(0), int64(1)
+		for yHat+C != 0 && yHat+D != 0 {
+			q1 := (xHat + A) / (yHat + C)
+			q2 := (xHat + B) / (yHat + D)
+			if q1 != q2 {
+				break
+			}
+			A, C = C, A-q1*C
+			B, D = D, B-q1*D
+			xHat, yHat = yHat, xHat-q1*yHat
+		}
+
+		if B == 0 {
+			// Not even one quotient could be found, so
+			// do a full step of Euclid's algorithm.
+			x, y = y, x.mod(y)
+			continue
+		}
+		// Both of these are in [0, 2^128), so computing them
+		// mod 2^128 is exact.
+		x, y = x.mulInt64(A).add(y.mulInt64(B)),
+			x.mulInt64(C).add(y.mulInt64(D))
+	}
+	if y.lo == 0 {
+		return x
+	}
+	return uint128{0, binaryGCD(bits.Rem64(x.hi, x.lo, y.lo), y.lo)}
+}
+
+// Returns gcd(a, b) for a, b >= 0, using gcdUint64 if either fits in
+// a uint64, which is usually the case for the GCDs of orders taken
+// to compute LCMs, lehmerGCD if both fit in 128 bits, and
+// big.Int.GCD otherwise.
+func gcd(a, b *big.Int) *big.Int {
+	switch {
+	case b.IsUint64():
+		if b.Sign() == 0 {
+			return new(big.Int).Set(a)
+		}
+		return new(big.Int).SetUint64(gcdUint64(a, b.Uint64()))
+	case a.IsUint64():
+		return gcd(b, a)
+	case a.BitLen() <= 128 && b.BitLen() <= 128:
+		g := lehmerGCD(uint128FromInt(a), uint128FromInt(b))
+		if g.hi == 0 {
+			return new(big.Int).SetUint64(g.lo)
+		}
+		return g.toInt()
+	}
+	return new(big.Int).GCD(nil, nil, a, b)
+}
+
+// Returns g = gcd(a, b) >= 0 and x and y such that a*x + b*y = g. a
+// and b can be any integers, and gcd(0, 0) = 0.
+func ExtGCD(a, b *big.Int) (g, x, y *big.Int) {
+	g, x, y = new(big.Int), new(big.Int), new(big.Int)
+	g.GCD(x, y, a, b)
+	return g, x, y
+}
+
+// Returns the x in [0, n) with a*x = 1 mod n. Returns an error
+// wrapping ErrBadInput if n < 1, or ErrNotInvertible if gcd(a, n) !=
+// 1.
+func InvMod(a, n *big.Int) (*big.Int, error) {
+	if n.Sign() <= 0 {
+		return nil, fmt.Errorf(
+			"%w: n = %v must be >= 1", ErrBadInput, n)
+	}
+	g, x, _ := ExtGCD(a, n)
+	if g.Cmp(big.NewInt(1)) != 0 {
+		return nil, fmt.Errorf(
+			"%w: gcd(%v, %v) = %v", ErrNotInvertible, a, n, g)
+	}
+	// x may be negative, and is only in [0, n) after this.
+	return x.Mod(x, n), nil
+}
@@ -0,0 +1,254 @@
+package aks
+
+import "errors"
+import "math/big"
+import "math/rand"
+import "testing"
+
+// Returns a random number of the given number of bits, with its top
+// bit set.
+func randomBits(rng *rand.Rand, bits int) *big.Int {
+	x := new(big.Int).Rand(rng, new(big.Int).Lsh(big.NewInt(1), uint(bits)))
+	return x.SetBit(x, bits-1, 1)
+}
+
+// binaryGCD and gcdUint64 should agree with big.Int.GCD.
+func TestBinaryGCD(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	cases := [][2]uint64{
+		{0, 0}, {0, 5}, {5, 0}, {1, 1}, {12, 18}, {1 << 63, 1 << 40},
+		{3 << 62, 9 << 40}, {1<<64 - 1, 1<<32 - 1},
+	}
+	for i := 0; i < 1000; i++ {
+		// Share a random factor half the time.
+		g := uint64(1)
+		if i%2 == 0 {
+			g = uint64(rng.Int63n(1 << 20))
+		}
+		a := g * uint64(rng.Int63n(1<<40))
+		b := g * uint64(rng.Int63n(1<<40))
+		cases = append(cases, [2]uint64{a, b})
+	}
+	for _, c := range cases {
+		a := new(big.Int).SetUint64(c[0])
+		b := new(big.Int).SetUint64(c[1])
+		expected := new(big.Int).GCD(nil, nil, a, b).Uint64()
+		if g := binaryGCD(c[0], c[1]); g != expected {
+			t.Error(c, g, expected)
+		}
+		if c[1] == 0 {
+			continue
+		}
+		var n big.Int
+		n.Mul(a, randomBits(rng, 200))
+		expected = new(big.Int).GCD(nil, nil, &n, b).Uint64()
+		if g := gcdUint64(&n, c[1]); g != expected {
+			t.Error(&n, c[1], g, expected)
+		}
+	}
+}
+
+// uint128.mod should agree with big.Int.Mod, including when its
+// estimate of the quotient is too large.
+func TestUint128Mod(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	for i := 0; i < 10000; i++ {
+		x := randomBits(rng, 65+rng.Intn(64))
+		y := randomBits(rng, 65+rng.Intn(x.BitLen()-64))
+		// Make y's second word small half the time, which
+		// makes the estimate more likely to be off.
+		if i%2 == 0 {
+			y.SetBit(y, 63, 0)
+		}
+		expected := new(big.Int).Mod(x, y)
+		r := uint128FromInt(x).mod(uint128FromInt(y))
+		if r.toInt().Cmp(expected) != 0 {
+			t.Error(x, y, r.toInt(), expected)
+		}
+	}
+}
+
+// lehmerGCD should agree with big.Int.GCD for numbers up to 2^128,
+// including Fibonacci numbers, which take the most steps, and pairs
+// of very different sizes, which need full steps of Euclid's
+// algorithm.
+func TestLehmerGCD(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	one := big.NewInt(1)
+	max := new(big.Int).Lsh(one, 128)
+	max.Sub(max, one)
+	cases := [][2]*big.Int{
+		{max, max}, {max, new(big.Int).Sub(max, one)},
+		{max, new(big.Int).Lsh(one, 64)},
+		{new(big.Int).Lsh(one, 127), new(big.Int).Lsh(one, 64)},
+		{new(big.Int).Lsh(one, 100), big.NewInt(0)},
+		{big.NewInt(12), big.NewInt(18)},
+	}
+	for i := 0; i < 2000; i++ {
+		g := randomBits(rng, 1+rng.Intn(64))
+		a := randomBits(rng, 65+rng.Intn(64-g.BitLen()+1))
+		b := randomBits(rng, 1+rng.Intn(a.BitLen()))
+		a.Rsh(a, uint(g.BitLen()))
+		b.Rsh(b, uint(g.BitLen()))
+		cases = append(cases, [2]*big.Int{a, b},
+			[2]*big.Int{a.Mul(a, g), b.Mul(b, g)})
+	}
+	f1, f2 := big.NewInt(1), big.NewInt(1)
+	for f2.BitLen() <= 128 {
+		cases = append(cases, [2]*big.Int{f2, f1})
+		f1.Add(f1, f2)
+		f1, f2 = f2, f1
+	}
+
+	for _, c := range cases {
+		expected := new(big.Int).GCD(nil, nil, c[0], c[1])
+		g := lehmerGCD(uint128FromInt(c[0]), uint128FromInt(c[1]))
+		if g.toInt().Cmp(expected) != 0 {
+			t.Error(c[0], c[1], g.toInt(), expected)
+		}
+	}
+}
+
+// gcd should agree with big.Int.GCD, including for Fibonacci
+// numbers, which take the most steps.
+func TestGCD(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	var cases [][2]*big.Int
+	for _, bits := range []int{10, 64, 65, 100, 128, 129, 500, 2000} {
+		for i := 0; i < 20; i++ {
+			g := randomBits(rng, 1+rng.Intn(bits))
+			a := randomBits(rng, bits)
+			b := randomBits(rng, 1+rng.Intn(bits))
+			ag := new(big.Int).Mul(a, g)
+			bg := new(big.Int).Mul(b, g)
+			cases = append(cases,
+				[2]*big.Int{a, b}, [2]*big.Int{ag, bg})
+		}
+	}
+	f1, f2 := big.NewInt(1), big.NewInt(1)
+	for i := 0; i < 3000; i++ {
+		f1.Add(f1, f2)
+		f1, f2 = f2, f1
+	}
+	cases = append(cases, [2]*big.Int{f2, f1},
+		[2]*big.Int{big.NewInt(0), f1}, [2]*big.Int{f1, big.NewInt(0)},
+		[2]*big.Int{big.NewInt(0), big.NewInt(0)})
+
+	for _, c := range cases {
+		expected := new(big.Int).GCD(nil, nil, c[0], c[1])
+		if g := gcd(c[0], c[1]); g.Cmp(expected) != 0 {
+			t.Error(c[0], c[1], g, expected)
+		}
+	}
+}
+
+// ExtGCD should return the GCD and its cofactors, for any signs.
+func TestExtGCD(t *testing.T) {
+	for a := int64(-30); a <= 30; a++ {
+		for b := int64(-30); b <= 30; b++ {
+			A, B := big.NewInt(a), big.NewInt(b)
+			g, x, y := ExtGCD(A, B)
+			expected := new(big.Int).GCD(nil, nil,
+				new(big.Int).Abs(A), new(big.Int).Abs(B))
+			var ax, by big.Int
+			ax.Mul(A, x)
+			by.Mul(B, y)
+			if g.Cmp(expected) != 0 || ax.Add(&ax, &by).Cmp(g) != 0 {
+				t.Error(a, b, g, x, y)
+			}
+		}
+	}
+}
+
+// InvMod should return the inverse of a mod n exactly when a is
+// coprime to n.
+func TestInvMod(t *testing.T) {
+	for n := int64(1); n <= 50; n++ {
+		N := big.NewInt(n)
+		for a := -2 * n; a <= 2*n; a++ {
+			A := big.NewInt(a)
+			x, err := InvMod(A, N)
+			g := new(big.Int).GCD(nil, nil, new(big.Int).Abs(A), N)
+			if g.Cmp(big.NewInt(1)) != 0 {
+				if !errors.Is(err, ErrNotInvertible) {
+					t.Error(a, n, x, err)
+				}
+				continue
+			}
+			if err != nil {
+				t.Fatal(a, n, err)
+			}
+			var ax big.Int
+			ax.Mod(ax.Mul(A, x), N)
+			if x.Sign() < 0 || x.Cmp(N) >= 0 ||
+				ax.Int64() != 1%n {
+				t.Error(a, n, x)
+			}
+		}
+	}
+
+	for _, n := range []int64{0, -1, -7} {
+		if x, err := InvMod(big.NewInt(3), big.NewInt(n)); !errors.Is(
+			err, ErrBadInput) {
+			t.Error(n, x, err)
+		}
+	}
+}
+
+// Runs a benchmark of gcdFn on pairs of random numbers of the given
+// sizes.
+func runGCDBenchmark(
+	b *testing.B, aBits, bBits int, gcdFn func(x, y *big.Int) *big.Int) {
+	b.StopTimer()
+	rng := rand.New(rand.NewSource(1))
+	var pairs [][2]*big.Int
+	for i := 0; i < 100; i++ {
+		pairs = append(pairs, [2]*big.Int{
+			randomBits(rng, aBits), randomBits(rng, bBits),
+		})
+	}
+	b.StartTimer()
+	for i := 0; i < b.N; i++ {
+		p := pairs[i%len(pairs)]
+		gcdFn(p[0], p[1])
+	}
+}
+
+func bigGCD(x, y *big.Int) *big.Int {
+	return new(big.Int).GCD(nil, nil, x, y)
+}
+
+// As in the search for the AKS modulus.
+func BenchmarkGCD1024By20(b *testing.B) {
+	runGCDBenchmark(b, 1024, 20, gcd)
+}
+
+func BenchmarkBigGCD1024By20(b *testing.B) {
+	runGCDBenchmark(b, 1024, 20, bigGCD)
+}
+
+// As in the LCMs in order finding.
+func BenchmarkGCD64By64(b *testing.B) {
+	runGCDBenchmark(b, 64, 64, gcd)
+}
+
+func BenchmarkBigGCD64By64(b *testing.B) {
+	runGCDBenchmark(b, 64, 64, bigGCD)
+}
+
+// As in the LCMs in order finding for n above 2^64.
+func BenchmarkGCD128By128(b *testing.B) {
+	runGCDBenchmark(b, 128, 128, gcd)
+}
+
+func BenchmarkBigGCD128By128(b *testing.B) {
+	runGCDBenchmark(b, 128, 128, bigGCD)
+}
+
+func BenchmarkGCD1024By1024(b *testing.B) {
+	runGCDBenchmark(b, 1024, 1024, gcd)
+}
+
+func BenchmarkBigGCD1024By1024(b *testing.B) {
+	runGCDBenchmark(b, 1024, 1024, bigGCD)
+}
@@ -0,0 +1,80 @@
+//go:build (gmp || flint) && cgo
+
+package aks
+
+/*
+#cgo LDFLAGS: -lgmp
+#include <gmp.h>
+#include <stdlib.h>
+
+// Returns a new array of count mpz_ts, each initialized with space for
+// bitCount bits. The array is allocated in C memory so that it can be
+// passed to GMP freely.
+static mpz_ptr aks_mpz_array_new(long count, mp_bitcnt_t bitCount) {
+  mpz_ptr array = malloc(count * sizeof(__mpz_struct));
+  for (long i = 0; i < count; ++i) {
+    mpz_init2(&array[i], bitCount);
+  }
+  return array;
+}
+
+// Frees an array returned by aks_mpz_array_new.
+static void aks_mpz_array_free(mpz_ptr array, long count) {
+  for (long i = 0; i < count; ++i) {
+    mpz_clear(&array[i]);
+  }
+  free(array);
+}
+
+// mpz_sgn is a macro, so it can't be called from Go directly.
+static int aks_mpz_sgn(mpz_srcptr x) {
+  return mpz_sgn(x);
+}
+*/
+import "C"
+
+import "math/big"
+import "math/bits"
+import "unsafe"
+
+// Sets z to x, which must be non-negative.
+func setMPZ(z *C.__mpz_struct, x *big.Int) {
+	words := x.Bits()
+	if len(words) == 0 {
+		C.mpz_set_ui(z, 0)
+		return
+	}
+	C.mpz_import(
+		z, C.size_t(len(words)), -1, C.size_t(bits.UintSize/8), 0, 0,
+		unsafe.Pointer(&words[0]))
+}
+
+// Returns whether x is zero.
+func isZeroMPZ(x *C.__mpz_struct) bool {
+	return C.aks_mpz_sgn(x) == 0
+}
+
+// Returns a new big.Int equal to x, which must be non-negative.
+func getMPZ(x *C.__mpz_struct) *big.Int {
+	if isZeroMPZ(x) {
+		return &big.Int{}
+	}
+	bitLen := int(C.mpz_sizeinbase(x, 2))
+	words := make([]big.Word, (bitLen+bits.UintSize-1)/bits.UintSize)
+	C.mpz_export(
+		unsafe.Pointer(&words[0]), nil, -1, C.size_t(bits.UintSize/8),
+		0, 0, x)
+	return new(big.Int).SetBits(words)
+}
+
+// Returns a slice of count new mpz_ts, each with space for bitCount
+// bits, which must be freed with freeMPZs.
+func newMPZs(count int, bitCount int) []C.__mpz_struct {
+	array := C.aks_mpz_array_new(C.long(count), C.mp_bitcnt_t(bitCount))
+	return unsafe.Slice(array, count)
+}
+
+// Frees a slice returned by newMPZs.
+func freeMPZs(mpzs []C.__mpz_struct) {
+	C.aks_mpz_array_free(&mpzs[0], C.long(len(mpzs)))
+}
@@ -0,0 +1,120 @@
+package aks
+
+import "fmt"
+import "io"
+import "math/big"
+import "net/http"
+import "sync"
+import "time"
+
+// A Metrics is a StatsObserver that exports the progress of AKS
+// witness searches as Prometheus metrics. It is also an http.Handler
+// serving those metrics in the Prometheus text exposition format, so
+// it can be registered as /metrics.
+type Metrics struct {
+	jobs int
+
+	lock sync.Mutex
+	// Totals over all completed searches, and the stats of the
+	// current search.
+	tested, multiplications int64
+	stats                   Stats
+	phase                   Phase
+	witnessesFound          int64
+}
+
+// Makes a Metrics for searches that use the given number of jobs,
+// which is used to calculate worker utilization.
+func NewMetrics(jobs int) *Metrics {
+	return &Metrics{jobs: jobs}
+}
+
+// ProgressObserver implementation.
+func (m *Metrics) OnWitnessTested(
+	a *big.Int, isWitness bool, d time.Duration) {
+	if isWitness {
+		m.lock.Lock()
+		defer m.lock.Unlock()
+		m.witnessesFound++
+	}
+}
+
+// ProgressObserver implementation. Starting a new witness search
+// folds the counters of the previous one into the totals.
+func (m *Metrics) OnPhaseChange(phase Phase) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.phase = phase
+	switch phase {
+	case PhaseWitnessSearch:
+		m.tested += m.stats.WitnessesTested
+		m.multiplications += m.stats.Multiplications
+		m.stats = Stats{}
+	case PhaseDone:
+		// The workers are on their way out, so don't report
+		// their polynomials as still allocated.
+		m.stats.PhiBytes = 0
+	}
+}
+
+// ProgressObserver implementation.
+func (m *Metrics) OnEstimate(e Estimate) {}
+
+// StatsObserver implementation.
+func (m *Metrics) OnStats(stats *Stats) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.stats = *stats
+}
+
+// Writes a single metric in the Prometheus text exposition format.
+func writeMetric(w io.Writer, name, kind, help string, value interface{}) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n%s %v\n",
+		name, help, name, kind, name, value)
+}
+
+// Writes the current metrics to w in the Prometheus text exposition
+// format.
+func (m *Metrics) WriteMetrics(w io.Writer) {
+	m.lock.Lock()
+	stats := m.stats
+	tested := m.tested + stats.WitnessesTested
+	multiplications := m.multiplications + stats.Multiplications
+	witnessesFound := m.witnessesFound
+	phase := m.phase
+	m.lock.Unlock()
+
+	utilization := 0.0
+	if stats.Elapsed > 0 && m.jobs > 0 {
+		utilization = stats.MulTime.Seconds() /
+			(stats.Elapsed.Seconds() * float64(m.jobs))
+	}
+
+	writeMetric(w, "aks_witnesses_tested_total", "counter",
+		"The number of AKS witness candidates tested.", tested)
+	writeMetric(w, "aks_witnesses_found_total", "counter",
+		"The number of AKS witnesses found.", witnessesFound)
+	writeMetric(w, "aks_multiplications_total", "counter",
+		"The number of polynomial multiplications performed.",
+		multiplications)
+	writeMetric(w, "aks_worker_utilization", "gauge",
+		"The fraction of time the jobs of the current search "+
+			"have spent testing witnesses.", utilization)
+	writeMetric(w, "aks_phi_bytes", "gauge",
+		"The number of bytes currently allocated for polynomial "+
+			"coefficients.", stats.PhiBytes)
+	writeMetric(w, "aks_phi_bytes_peak", "gauge",
+		"The largest number of bytes allocated for polynomial "+
+			"coefficients in the current search.",
+		stats.PeakPhiBytes)
+	writeMetric(w, "aks_phase", "gauge",
+		"The phase of the current primality test (0 = "+
+			"parameters, 1 = trial division, 2 = witness "+
+			"search, 3 = done).", int(phase))
+}
+
+// http.Handler implementation.
+func (m *Metrics) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	m.WriteMetrics(w)
+}
@@ -0,0 +1,67 @@
+package aks
+
+import "bytes"
+import "context"
+import "fmt"
+import "math/big"
+import "strings"
+import "testing"
+
+// After a run, the metrics should reflect the stats of the run.
+func TestMetrics(t *testing.T) {
+	n := big.NewInt(1000003)
+	m := NewMetrics(2)
+	result, err := RunAKS(context.Background(), n, &AKSOptions{
+		Jobs:     2,
+		Observer: m,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	m.WriteMetrics(&buf)
+	text := buf.String()
+	expectedLines := []string{
+		"# TYPE aks_witnesses_tested_total counter",
+		fmt.Sprintf("aks_witnesses_tested_total %d",
+			result.Stats.WitnessesTested),
+		fmt.Sprintf("aks_multiplications_total %d",
+			result.Stats.Multiplications),
+		"aks_witnesses_found_total 0",
+		"aks_phi_bytes 0",
+		"aks_phase 3",
+	}
+	for _, line := range expectedLines {
+		if !strings.Contains(text, line+"\n") {
+			t.Error(line, text)
+		}
+	}
+}
+
+// Counters should accumulate over multiple runs.
+func TestMetricsMultipleRuns(t *testing.T) {
+	m := NewMetrics(1)
+	var tested int64
+	for _, n := range []int64{1000003, 2993374621} {
+		result, err := RunAKS(
+			context.Background(), big.NewInt(n),
+			&AKSOptions{Observer: m, SkipBPSW: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		tested += result.Stats.WitnessesTested
+	}
+
+	var buf bytes.Buffer
+	m.WriteMetrics(&buf)
+	text := buf.String()
+	for _, line := range []string{
+		fmt.Sprintf("aks_witnesses_tested_total %d", tested),
+		"aks_witnesses_found_total 1",
+	} {
+		if !strings.Contains(text, line+"\n") {
+			t.Error(line, text)
+		}
+	}
+}
@@ -0,0 +1,164 @@
+package aks
+
+import "fmt"
+import "math/big"
+import "math/bits"
+import "math/rand"
+
+// Returns whether n passes the Miller-Rabin test with the given number
+// of rounds, each with a base chosen uniformly from [2, n - 2] using
+// rnd. Primes always pass, and a composite n passes with probability
+// at most 4^(-rounds); unlike big.Int.ProbablyPrime, no Baillie-PSW
+// test is done in addition, so the bases and the number of rounds
+// are entirely up to the caller. Returns an error wrapping
+// ErrBadInput if n < 0, rounds < 0, or rnd is nil.
+func MillerRabin(n *big.Int, rounds int, rnd *rand.Rand) (bool, error) {
+	if n.Sign() < 0 || rounds < 0 || rnd == nil {
+		return false, fmt.Errorf(
+			"%w: n = %v must be >= 0, rounds = %d must be >= 0, "+
+				"and rnd must be non-nil",
+			ErrBadInput, n, rounds)
+	}
+	if n.Cmp(big.NewInt(4)) < 0 {
+		return n.Cmp(big.NewInt(2)) >= 0, nil
+	}
+	if n.Bit(0) == 0 {
+		return false, nil
+	}
+
+	d, s := splitMillerRabinExponent(n)
+	// Bases are 2 + [0, n - 3).
+	var span, base big.Int
+	span.Sub(n, big.NewInt(3))
+	for i := 0; i < rounds; i++ {
+		base.Rand(rnd, &span)
+		base.Add(&base, big.NewInt(2))
+		if !isStrongProbablePrime(n, &base, d, s) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// Returns whether n is a strong probable prime to the given base,
+// i.e. whether it passes a single Miller-Rabin round with that base.
+// Every odd prime not dividing base is one, so a false result proves
+// n composite. Returns an error wrapping ErrBadInput if n isn't odd
+// and >= 3, or if base isn't in [1, n - 1].
+func IsStrongProbablePrime(n, base *big.Int) (bool, error) {
+	if err := checkProbabilisticTestArgs(n, base); err != nil {
+		return false, err
+	}
+	d, s := splitMillerRabinExponent(n)
+	return isStrongProbablePrime(n, base, d, s), nil
+}
+
+// Returns d and s such that n - 1 = d * 2^s with d odd, for odd n >= 3.
+func splitMillerRabinExponent(n *big.Int) (*big.Int, uint) {
+	d := new(big.Int).Sub(n, big.NewInt(1))
+	s := d.TrailingZeroBits()
+	d.Rsh(d, s)
+	return d, s
+}
+
+// Like IsStrongProbablePrime, but takes n - 1 = d * 2^s as returned by
+// splitMillerRabinExponent, and doesn't check its arguments.
+func isStrongProbablePrime(n, base, d *big.Int, s uint) bool {
+	var nMinusOne, x big.Int
+	nMinusOne.Sub(n, big.NewInt(1))
+	x.Exp(base, d, n)
+	if x.Cmp(big.NewInt(1)) == 0 || x.Cmp(&nMinusOne) == 0 {
+		return true
+	}
+	for i := uint(1); i < s; i++ {
+		x.Mul(&x, &x)
+		x.Mod(&x, n)
+		if x.Cmp(&nMinusOne) == 0 {
+			return true
+		}
+		if x.Cmp(big.NewInt(1)) == 0 {
+			return false
+		}
+	}
+	return false
+}
+
+// The first 12 primes, which as Miller-Rabin bases suffice to decide
+// the primality of any n < 3.3 * 10^24, and so of any uint64.
+var uint64MillerRabinBases = []uint64{
+	2, 3, 5, 7, 11, 13, 17, 19, 23, 29, 31, 37,
+}
+
+// Returns whether n is prime, exactly, using Miller-Rabin with a
+// fixed set of bases known to have no strong pseudoprimes below
+// 2^64. Products are done in 128 bits, so this is much faster than
+// converting n to a big.Int.
+func IsPrimeUint64(n uint64) bool {
+	if n < 2 {
+		return false
+	}
+	for _, p := range uint64MillerRabinBases {
+		if n%p == 0 {
+			return n == p
+		}
+	}
+	if n < 41*41 {
+		return true
+	}
+
+	d := n - 1
+	s := bits.TrailingZeros64(d)
+	d >>= uint(s)
+	for _, base := range uint64MillerRabinBases {
+		if !isStrongProbablePrimeUint64(n, base, d, s) {
+			return false
+		}
+	}
+	return true
+}
+
+// Like isStrongProbablePrime, but for uint64s.
+func isStrongProbablePrimeUint64(n, base, d uint64, s int) bool {
+	x := expModUint64(base, d, n)
+	if x == 1 || x == n-1 {
+		return true
+	}
+	for i := 1; i < s; i++ {
+		x = mulModUint64(x, x, n)
+		if x == n-1 {
+			return true
+		}
+		if x == 1 {
+			return false
+		}
+	}
+	return false
+}
+
+// Returns a * b mod m for a, b < m.
+func mulModUint64(a, b, m uint64) uint64 {
+	hi, lo := bits.Mul64(a, b)
+	_, r := bits.Div64(hi, lo, m)
+	return r
+}
+
+// Returns a^e mod m for a < m.
+func expModUint64(a, e, m uint64) uint64 {
+	r := uint64(1)
+	for ; e > 0; e >>= 1 {
+		if e&1 != 0 {
+			r = mulModUint64(r, a, m)
+		}
+		a = mulModUint64(a, a, m)
+	}
+	return r
+}
+
+// Returns whether n >= 0 is prime, exactly via IsPrimeUint64 if it
+// fits in a uint64, and according to big.Int.ProbablyPrime otherwise.
+func isProbablePrime(n *big.Int) bool {
+	if n.IsUint64() {
+		return IsPrimeUint64(n.Uint64())
+	}
+	return n.ProbablyPrime(primalityRounds)
+}
@@ -0,0 +1,140 @@
+package aks
+
+import "errors"
+import "math/big"
+import "math/rand"
+import "testing"
+
+// MillerRabin should agree with trial division on small numbers, and
+// should catch Carmichael numbers and large composites.
+func TestMillerRabin(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	for i := int64(0); i < 2000; i++ {
+		n := big.NewInt(i)
+		isPrime, err := MillerRabin(n, 10, rnd)
+		if err != nil {
+			t.Fatal(n, err)
+		}
+		if isPrime != n.ProbablyPrime(0) {
+			t.Error(n, isPrime)
+		}
+	}
+
+	for _, s := range []string{
+		"561", "1105", "1729", "2047", "3215031751",
+		"3825123056546413051",
+		"170141183460469231731687303715884105727" +
+			"170141183460469231731687303715884105727",
+	} {
+		n, _ := new(big.Int).SetString(s, 10)
+		if isPrime, err := MillerRabin(n, 20, rnd); isPrime || err != nil {
+			t.Error(n, isPrime, err)
+		}
+	}
+
+	n, _ := new(big.Int).SetString(
+		"170141183460469231731687303715884105727", 10)
+	if isPrime, err := MillerRabin(n, 20, rnd); !isPrime || err != nil {
+		t.Error(n, isPrime, err)
+	}
+
+	// With no rounds, only the trivial checks are done.
+	if isPrime, err := MillerRabin(big.NewInt(561), 0, rnd); !isPrime ||
+		err != nil {
+		t.Error(isPrime, err)
+	}
+
+	for _, c := range []struct {
+		n      int64
+		rounds int
+		rnd    *rand.Rand
+	}{
+		{-1, 1, rnd},
+		{7, -1, rnd},
+		{7, 1, nil},
+	} {
+		isPrime, err := MillerRabin(big.NewInt(c.n), c.rounds, c.rnd)
+		if !errors.Is(err, ErrBadInput) {
+			t.Error(c.n, c.rounds, isPrime, err)
+		}
+	}
+}
+
+// IsStrongProbablePrime should pass primes for every base, and pass
+// strong pseudoprimes only for the bases they fool.
+func TestIsStrongProbablePrime(t *testing.T) {
+	for _, c := range []struct {
+		n, base  int64
+		expected bool
+	}{
+		{3, 2, true},
+		{97, 5, true},
+		{97, 96, true},
+		{2047, 2, true},
+		{2047, 3, false},
+		{1373653, 2, true},
+		{1373653, 3, true},
+		{1373653, 5, false},
+		{561, 2, false},
+		{9, 8, true},
+		{9, 2, false},
+	} {
+		actual, err := IsStrongProbablePrime(
+			big.NewInt(c.n), big.NewInt(c.base))
+		if err != nil {
+			t.Fatal(c.n, c.base, err)
+		}
+		if actual != c.expected {
+			t.Error(c.n, c.base, actual, c.expected)
+		}
+	}
+
+	for _, c := range []struct{ n, base int64 }{
+		{1, 1}, {2, 1}, {10, 3}, {7, 0}, {7, 7}, {7, -1},
+	} {
+		actual, err := IsStrongProbablePrime(
+			big.NewInt(c.n), big.NewInt(c.base))
+		if !errors.Is(err, ErrBadInput) {
+			t.Error(c.n, c.base, actual, err)
+		}
+	}
+}
+
+// IsPrimeUint64 should agree with big.Int.ProbablyPrime, including on
+// strong pseudoprimes to many bases and near 2^64.
+func TestIsPrimeUint64(t *testing.T) {
+	for n := uint64(0); n < 5000; n++ {
+		expected := new(big.Int).SetUint64(n).ProbablyPrime(0)
+		if actual := IsPrimeUint64(n); actual != expected {
+			t.Error(n, actual, expected)
+		}
+	}
+
+	for _, c := range []struct {
+		n        uint64
+		expected bool
+	}{
+		{2047, false},
+		{3215031751, false},
+		{3825123056546413051, false},
+		{4294967291, true},
+		{4294967297, false},
+		{1000000000000000003, true},
+		{18446744073709551557, true},
+		{18446744073709551615, false},
+		{18446744030759878681, false},
+	} {
+		if actual := IsPrimeUint64(c.n); actual != c.expected {
+			t.Error(c.n, actual, c.expected)
+		}
+	}
+
+	rnd := rand.New(rand.NewSource(1))
+	for i := 0; i < 10000; i++ {
+		n := rnd.Uint64() | 1
+		expected := new(big.Int).SetUint64(n).ProbablyPrime(0)
+		if actual := IsPrimeUint64(n); actual != expected {
+			t.Error(n, actual, expected)
+		}
+	}
+}
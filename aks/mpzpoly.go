@@ -0,0 +1,305 @@
+//go:build gmp && cgo
+
+package aks
+
+/*
+#cgo LDFLAGS: -lgmp
+#include <gmp.h>
+
+// Sets out to p*q mod (n, X^r - 1), where out must not alias p or q.
+static void aks_mpz_poly_mul(
+    mpz_ptr out, mpz_srcptr p, mpz_srcptr q, long r, mpz_srcptr n) {
+  for (long k = 0; k < r; ++k) {
+    mpz_set_ui(&out[k], 0);
+  }
+  for (long i = 0; i < r; ++i) {
+    if (mpz_sgn(&p[i]) == 0) {
+      continue;
+    }
+    for (long j = 0; j < r; ++j) {
+      if (mpz_sgn(&q[j]) == 0) {
+        continue;
+      }
+      long k = i + j;
+      if (k >= r) {
+        k -= r;
+      }
+      mpz_addmul(&out[k], &p[i], &q[j]);
+    }
+  }
+  for (long k = 0; k < r; ++k) {
+    mpz_mod(&out[k], &out[k], n);
+  }
+}
+*/
+import "C"
+
+import "encoding/json"
+import "fmt"
+import "math/big"
+import "math/bits"
+import "runtime"
+
+// Whether MPZPolyBackend is available in this build.
+const haveMPZPolyBackend = true
+
+// An mpzPolyRing makes mpzPolys.
+type mpzPolyRing struct {
+	N, R big.Int
+	// N as a single mpz_t, shared by the ring's polys.
+	n []C.__mpz_struct
+}
+
+// Makes an mpzPolyRing for polynomials mod (N, X^R - 1).
+func newMPZPolyRing(N, R big.Int) (*mpzPolyRing, error) {
+	ring := &mpzPolyRing{N: N, R: R, n: newMPZs(1, N.BitLen())}
+	setMPZ(&ring.n[0], &N)
+	runtime.SetFinalizer(ring, func(ring *mpzPolyRing) {
+		freeMPZs(ring.n)
+	})
+	return ring, nil
+}
+
+// Returns the number of bits each coefficient of an mpzPoly mod (N,
+// X^R - 1) is allocated, which is enough to hold a coefficient of a
+// product before it's reduced mod N.
+func calculateMPZCoefficientBits(N, R big.Int) int {
+	return calculateCoefficientWordCount(N, R) * bits.UintSize
+}
+
+// polyRing implementation.
+func (r *mpzPolyRing) newPoly() poly {
+	rInt := int(r.R.Int64())
+	coefficientBits := calculateMPZCoefficientBits(r.N, r.R)
+	p := &mpzPoly{
+		ring:         r,
+		coefficients: newMPZs(rInt, coefficientBits),
+	}
+	runtime.SetFinalizer(p, (*mpzPoly).free)
+	return p
+}
+
+// polyRing implementation.
+func (r *mpzPolyRing) getPolyBytes() int64 {
+	coefficientBits := calculateMPZCoefficientBits(r.N, r.R)
+	return r.R.Int64() * int64(coefficientBits/8)
+}
+
+// An mpzPoly represents a polynomial mod (N, X^R - 1) with each
+// coefficient stored in its own GMP mpz_t.
+//
+// Unlike the other backends, an mpzPoly is multiplied coefficient by
+// coefficient (with mpz_addmul), which takes O(R^2) multiplications
+// of coefficients. It's mainly useful as a straightforward reference
+// to compare the packed backends against.
+type mpzPoly struct {
+	ring *mpzPolyRing
+	// The R coefficients of the polynomial, in order of
+	// increasing degree, each less than N. The mpz_ts are
+	// allocated by newMPZs and freed by a finalizer.
+	coefficients []C.__mpz_struct
+}
+
+// Frees p's coefficients. Used as p's finalizer.
+func (p *mpzPoly) free() {
+	freeMPZs(p.coefficients)
+}
+
+// Calls panicModulusMismatch if p and q aren't mod the same N and R.
+func (p *mpzPoly) checkModulus(op string, q *mpzPoly) {
+	if q.ring != p.ring && (q.ring.N.Cmp(&p.ring.N) != 0 ||
+		q.ring.R.Cmp(&p.ring.R) != 0) {
+		panicModulusMismatch(
+			op, &p.ring.N, int(p.ring.R.Int64()),
+			&q.ring.N, int(q.ring.R.Int64()))
+	}
+}
+
+// Returns the ith coefficient of p. Since p's finalizer frees the
+// coefficients' limbs, p must be kept alive (with runtime.KeepAlive)
+// until C is done with the result.
+func (p *mpzPoly) get(i int) *C.__mpz_struct {
+	return &p.coefficients[i]
+}
+
+// poly implementation.
+func (p *mpzPoly) Set(a, k, N big.Int) {
+	for i := range p.coefficients {
+		C.mpz_set_ui(p.get(i), 0)
+	}
+	var c0, kModR big.Int
+	c0.Mod(&a, &N)
+	kModR.Mod(&k, big.NewInt(int64(len(p.coefficients))))
+	i := int(kModR.Int64())
+	setMPZ(p.get(0), &c0)
+	C.mpz_add_ui(p.get(i), p.get(i), 1)
+	C.mpz_mod(p.get(i), p.get(i), &p.ring.n[0])
+	// This also keeps p.ring, and so p.ring.n, alive.
+	runtime.KeepAlive(p)
+}
+
+// poly implementation.
+func (p *mpzPoly) copyFrom(q poly) {
+	qp := q.(*mpzPoly)
+	p.checkModulus("copy", qp)
+	for i := range p.coefficients {
+		C.mpz_set(p.get(i), qp.get(i))
+	}
+	runtime.KeepAlive(p)
+	runtime.KeepAlive(qp)
+}
+
+// poly implementation.
+func (p *mpzPoly) clone() poly {
+	c := p.ring.newPoly()
+	c.copyFrom(p)
+	return c
+}
+
+// poly implementation.
+func (p *mpzPoly) Eq(q poly) bool {
+	qp := q.(*mpzPoly)
+	p.checkModulus("compare", qp)
+	defer runtime.KeepAlive(qp)
+	defer runtime.KeepAlive(p)
+	for i := range p.coefficients {
+		if C.mpz_cmp(p.get(i), qp.get(i)) != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// poly implementation.
+func (p *mpzPoly) Mul(q poly, N big.Int, tmp poly) {
+	qp := q.(*mpzPoly)
+	p.checkModulus("multiply", qp)
+	p.mul(qp, tmp.(*mpzPoly))
+}
+
+// Like Mul, but without the type assertions.
+func (p *mpzPoly) mul(q *mpzPoly, tmp *mpzPoly) {
+	C.aks_mpz_poly_mul(
+		tmp.get(0), p.get(0), q.get(0),
+		C.long(len(p.coefficients)), &p.ring.n[0])
+	runtime.KeepAlive(p)
+	runtime.KeepAlive(q)
+	runtime.KeepAlive(tmp)
+	p.coefficients, tmp.coefficients = tmp.coefficients, p.coefficients
+}
+
+// poly implementation.
+func (p *mpzPoly) Pow(N big.Int, tmp1, tmp2 poly) {
+	p.powCancelable(N, tmp1, tmp2, nil, nil)
+}
+
+// poly implementation.
+func (p *mpzPoly) powCancelable(
+	N big.Int, tmp1Poly, tmp2Poly poly,
+	done <-chan struct{}, onMul func()) bool {
+	tmp1 := tmp1Poly.(*mpzPoly)
+	tmp2 := tmp2Poly.(*mpzPoly)
+	for i := range p.coefficients {
+		C.mpz_set(tmp1.get(i), p.get(i))
+	}
+	runtime.KeepAlive(p)
+
+	for i := N.BitLen() - 2; i >= 0; i-- {
+		select {
+		case <-done:
+			return false
+		default:
+		}
+		tmp1.mul(tmp1, tmp2)
+		if onMul != nil {
+			onMul()
+		}
+		if N.Bit(i) != 0 {
+			tmp1.mul(p, tmp2)
+			if onMul != nil {
+				onMul()
+			}
+		}
+	}
+
+	p.coefficients, tmp1.coefficients = tmp1.coefficients, p.coefficients
+	return true
+}
+
+// poly implementation.
+func (p *mpzPoly) coefficient(i int) *big.Int {
+	c := getMPZ(p.get(i))
+	runtime.KeepAlive(p)
+	return c
+}
+
+// poly implementation.
+func (p *mpzPoly) setCoefficient(i int, c *big.Int) {
+	setMPZ(p.get(i), c)
+	runtime.KeepAlive(p)
+}
+
+// poly implementation.
+func (p *mpzPoly) getPhiBytes() int64 {
+	var bytes int64
+	for i := range p.coefficients {
+		bytes += int64(p.coefficients[i]._mp_alloc) * 8
+	}
+	return bytes
+}
+
+// fmt.Formatter implementation.
+func (p *mpzPoly) Format(f fmt.State, c rune) {
+	coefficientCount := len(p.coefficients)
+	for coefficientCount > 0 &&
+		isZeroMPZ(p.get(coefficientCount-1)) {
+		coefficientCount--
+	}
+	formatPoly(f, coefficientCount, func(i int) big.Int {
+		return *p.coefficient(i)
+	})
+}
+
+var _ poly = (*mpzPoly)(nil)
+
+// gob.GobEncoder implementation.
+func (p *mpzPoly) GobEncode() ([]byte, error) {
+	return marshalPoly(p, p.ring.N, p.ring.R, gobMarshal)
+}
+
+// gob.GobDecoder implementation.
+func (p *mpzPoly) GobDecode(data []byte) error {
+	return p.unmarshal(data, gobUnmarshal)
+}
+
+// json.Marshaler implementation.
+func (p *mpzPoly) MarshalJSON() ([]byte, error) {
+	return marshalPoly(p, p.ring.N, p.ring.R, json.Marshal)
+}
+
+// json.Unmarshaler implementation.
+func (p *mpzPoly) UnmarshalJSON(data []byte) error {
+	return p.unmarshal(data, json.Unmarshal)
+}
+
+// Sets p to data, as marshaled by GobEncode or MarshalJSON (possibly
+// by another backend), with unmarshal.
+func (p *mpzPoly) unmarshal(
+	data []byte, unmarshal func([]byte, interface{}) error) error {
+	q, err := unmarshalPoly(data, unmarshal, MPZPolyBackend)
+	if err != nil {
+		return err
+	}
+	// Swap p and q, so that q's finalizer frees p's old
+	// coefficients. If p was the zero value, it had no
+	// coefficients and no finalizer, so q mustn't have one
+	// either.
+	qp := q.(*mpzPoly)
+	*p, *qp = *qp, *p
+	if qp.coefficients == nil {
+		runtime.SetFinalizer(qp, nil)
+	}
+	runtime.SetFinalizer(p, nil)
+	runtime.SetFinalizer(p, (*mpzPoly).free)
+	return nil
+}
@@ -0,0 +1,17 @@
+//go:build !gmp || !cgo
+
+package aks
+
+import "fmt"
+import "math/big"
+
+// Whether MPZPolyBackend is available in this build.
+const haveMPZPolyBackend = false
+
+// Returns an error, since MPZPolyBackend needs the package to be built
+// with cgo and the gmp build tag.
+func newMPZPolyRing(N, R big.Int) (polyRing, error) {
+	return nil, fmt.Errorf(
+		"%w: the %v backend requires building with cgo and -tags gmp",
+		ErrBackendUnavailable, MPZPolyBackend)
+}
@@ -0,0 +1,85 @@
+//go:build gmp && cgo
+
+package aks
+
+import "math/big"
+import "math/rand"
+import "testing"
+
+// mpzPolyRing should pass checkPolyRing.
+func TestMPZPolyRing(t *testing.T) {
+	checkPolyRing(t, func(N, R big.Int) polyRing {
+		ring, err := newMPZPolyRing(N, R)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return ring
+	})
+}
+
+// Multiplying random mpzPolys should give the same results as
+// multiplying the equivalent bigIntPolys.
+func TestMPZPolyMulMatchesBigIntPoly(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	var large big.Int
+	large.Lsh(big.NewInt(1), 200)
+	large.Sub(&large, big.NewInt(1))
+	for _, N := range []big.Int{
+		*big.NewInt(2), *big.NewInt(4294967291), large,
+	} {
+		R := *big.NewInt(53)
+		ring, err := newMPZPolyRing(N, R)
+		if err != nil {
+			t.Fatal(err)
+		}
+		p := ring.newPoly()
+		q := ring.newPoly()
+		tmp := ring.newPoly()
+		pBig := newBigIntPoly(N, R)
+		qBig := newBigIntPoly(N, R)
+		tmpBig := newBigIntPoly(N, R)
+		for i := 0; i < 5; i++ {
+			var a, b big.Int
+			a.Rand(rng, &N)
+			b.Rand(rng, &N)
+			k := *big.NewInt(1 + rng.Int63n(52))
+			p.Set(a, k, N)
+			pBig.Set(a, k, N)
+			q.Set(b, *big.NewInt(1), N)
+			qBig.Set(b, *big.NewInt(1), N)
+			for j := 0; j < 5; j++ {
+				p.Mul(q, N, tmp)
+				pBig.Mul(qBig, N, tmpBig)
+				p.Mul(p, N, tmp)
+				pBig.Mul(pBig, N, tmpBig)
+			}
+			for j := 0; j < 53; j++ {
+				if p.coefficient(j).Cmp(pBig.coefficient(j)) != 0 {
+					t.Fatal(&N, i, j, p.coefficient(j),
+						pBig.coefficient(j))
+				}
+			}
+		}
+	}
+}
+
+// mpzPolyRing should pass checkPolyEncoding.
+func TestMPZPolyEncoding(t *testing.T) {
+	checkPolyEncoding(t, func(N, R big.Int) polyRing {
+		ring, err := newMPZPolyRing(N, R)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return ring
+	}, func() poly { return &mpzPoly{} })
+}
+
+// See runPolyBackendBenchmark.
+
+func BenchmarkMPZPolyAKSWitness20Bits(b *testing.B) {
+	runPolyBackendBenchmark(b, MPZPolyBackend, 20)
+}
+
+func BenchmarkMPZPolyAKSWitness38Bits(b *testing.B) {
+	runPolyBackendBenchmark(b, MPZPolyBackend, 38)
+}
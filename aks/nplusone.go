@@ -0,0 +1,403 @@
+package aks
+
+import "fmt"
+import "io"
+import "math/big"
+
+// The largest Lucas parameter P ProveNPlusOne tries for each prime
+// factor of n + 1. As with pocklingtonMaxBase, almost every P works
+// for prime n.
+const nPlusOneMaxP = 1000
+
+// An NPlusOneCertificate proves N prime by Morrison's N+1 theorem,
+// combined with Pocklington's theorem when not enough of N + 1 is
+// factored (the N^2-1 test). Every prime p dividing N satisfies
+//
+//   - p = 1 (mod F1), by the conditions on MinusFactors, which are
+//     those of PocklingtonCertificate.Factors, and
+//   - p = (D/p) (mod F2), by the conditions on PlusFactors: for every
+//     prime q dividing F2, N divides U_(N + 1) and gcd(U_((N + 1)/q),
+//     N) = 1 for the Lucas sequence with parameters P and Q = (P^2 -
+//     D)/4, where gcd(Q, N) = 1.
+//
+// So if G = lcm(F1, F2) > sqrt(N), every prime factor of N below
+// sqrt(N) is the s in [1, G) with s = 1 (mod F1) and s = -1 (mod F2),
+// and N is prime if s doesn't divide it.
+type NPlusOneCertificate struct {
+	N *big.Int
+	// The discriminant of the Lucas sequences, which is 1 mod 4
+	// and has Jacobi symbol (D/N) = -1.
+	D *big.Int
+	// The prime powers whose product is F2, in ascending order of
+	// P, where A is the Lucas parameter P for the prime. Empty if
+	// N < 2^64, in which case IsPrimeUint64 decides.
+	PlusFactors []NPlusOneFactor
+	// The prime powers whose product is F1, in ascending order of
+	// P, where A is the base for the prime as in
+	// PocklingtonFactor. Empty unless N + 1 alone isn't factored
+	// enough.
+	MinusFactors []NPlusOneFactor
+}
+
+// Holds a prime power dividing N + 1 or N - 1, and the Lucas
+// parameter P or Pocklington base a for its prime.
+type NPlusOneFactor struct {
+	PrimePower
+	A *big.Int
+	// Proves P prime. Nil if P < 2^64, in which case
+	// IsPrimeUint64 decides.
+	Certificate *NPlusOneCertificate
+}
+
+// Returns a certificate proving n prime with the N+1 test, after
+// factoring n + 1 as in factorizeBounded, and also n - 1 if not
+// enough of n + 1 can be factored. Like ProvePocklington, this is
+// much faster than AKS when it works, and the prime factors above
+// 2^64 that are needed are proven prime recursively. Returns an error
+// wrapping ErrComposite if n turns out to be composite,
+// ErrProofNotFound if not enough of n + 1 and n - 1 can be factored,
+// or ErrBadInput if n < 2.
+func ProveNPlusOne(n *big.Int) (*NPlusOneCertificate, error) {
+	if n.Cmp(big.NewInt(2)) < 0 {
+		return nil, fmt.Errorf(
+			"%w: n = %v must be >= 2", ErrBadInput, n)
+	}
+	return proveNPlusOne(n)
+}
+
+// Like ProveNPlusOne, but assumes n >= 2.
+func proveNPlusOne(n *big.Int) (*NPlusOneCertificate, error) {
+	p, err := newNPlusOneProver(n)
+	if err != nil {
+		return nil, err
+	}
+	if n.IsUint64() {
+		return p.c, nil
+	}
+	one := big.NewInt(1)
+	nPlusOne := new(big.Int).Add(n, one)
+	if err := p.addFactors(findBoundedPrimePowers(nPlusOne), true); err != nil {
+		return nil, err
+	}
+	if !p.isEnough() {
+		nMinusOne := new(big.Int).Sub(n, one)
+		err := p.addFactors(findBoundedPrimePowers(nMinusOne), false)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return p.finish()
+}
+
+// Builds an NPlusOneCertificate from the factors of N + 1 and N - 1
+// given to it.
+type nPlusOneProver struct {
+	c *NPlusOneCertificate
+	// The products of c.MinusFactors and c.PlusFactors.
+	F1, F2 *big.Int
+}
+
+// Returns a prover for n >= 2, or an error wrapping ErrComposite if
+// n is found to be composite. For n < 2^64, the certificate needs no
+// factors.
+func newNPlusOneProver(n *big.Int) (*nPlusOneProver, error) {
+	if isPrime, _ := BailliePSW(n); !isPrime {
+		return nil, fmt.Errorf("%w: n = %v", ErrComposite, n)
+	}
+	p := &nPlusOneProver{
+		c:  &NPlusOneCertificate{N: new(big.Int).Set(n)},
+		F1: big.NewInt(1),
+		F2: big.NewInt(1),
+	}
+	if !n.IsUint64() {
+		p.c.D = findSelfridgeD(n)
+		if p.c.D == nil {
+			return nil, fmt.Errorf("%w: n = %v", ErrComposite, n)
+		}
+	}
+	return p, nil
+}
+
+// Returns whether lcm(F1, F2) > sqrt(N).
+func (p *nPlusOneProver) isEnough() bool {
+	G, _ := getNSquaredMinusOneClass(p.F1, p.F2)
+	return G.Mul(G, G).Cmp(p.c.N) > 0
+}
+
+// Adds the given prime powers, which divide N + 1 if plus is true and
+// N - 1 otherwise, to the certificate in ascending order until
+// isEnough, so that as few large ones as possible need their own
+// proofs. Prime powers whose primes can't be proven prime are
+// skipped.
+func (p *nPlusOneProver) addFactors(factors []PrimePower, plus bool) error {
+	n := p.c.N
+	var pk big.Int
+	for _, f := range factors {
+		if p.isEnough() {
+			return nil
+		}
+		var pCert *NPlusOneCertificate
+		if !f.P.IsUint64() {
+			var err error
+			pCert, err = proveNPlusOne(f.P)
+			if err != nil {
+				continue
+			}
+		}
+		var a *big.Int
+		var err error
+		if plus {
+			a, err = findLucasParameter(n, p.c.D, f.P)
+		} else {
+			a, err = findPocklingtonBase(n, f.P)
+		}
+		if err != nil {
+			return err
+		}
+		factor := NPlusOneFactor{
+			PrimePower:  f,
+			A:           a,
+			Certificate: pCert,
+		}
+		if plus {
+			p.c.PlusFactors = append(p.c.PlusFactors, factor)
+			p.F2.Mul(p.F2, pk.Exp(f.P, f.K, nil))
+		} else {
+			p.c.MinusFactors = append(p.c.MinusFactors, factor)
+			p.F1.Mul(p.F1, pk.Exp(f.P, f.K, nil))
+		}
+	}
+	return nil
+}
+
+// Returns the certificate if it's complete. Returns an error wrapping
+// ErrProofNotFound if not enough factors were added, or ErrComposite
+// if N turns out to be composite.
+func (p *nPlusOneProver) finish() (*NPlusOneCertificate, error) {
+	n := p.c.N
+	if !p.isEnough() {
+		return nil, fmt.Errorf(
+			"%w: only %v of n + 1 and %v of n - 1 = %v - 1 "+
+				"could be factored and proven, which isn't "+
+				"enough", ErrProofNotFound, p.F2, p.F1, n)
+	}
+	if _, s := getNSquaredMinusOneClass(p.F1, p.F2); dividesProperly(s, n) {
+		return nil, fmt.Errorf(
+			"%w: n = %v has factor %v", ErrComposite, n, s)
+	}
+	return p.c, nil
+}
+
+// Returns G = lcm(F1, F2) and the s in [1, G) with s = 1 (mod F1) and
+// s = -1 (mod F2), for positive F1 and F2 with gcd(F1, F2) | 2.
+func getNSquaredMinusOneClass(F1, F2 *big.Int) (G, s *big.Int) {
+	var g, m, t big.Int
+	g.GCD(nil, nil, F1, F2)
+	G = new(big.Int).Quo(F1, &g)
+	G.Mul(G, F2)
+	// s = 1 + F1 t, where (F1/g) t = -2/g (mod F2/g).
+	m.Quo(F2, &g)
+	if m.Cmp(big.NewInt(1)) > 0 {
+		t.Quo(F1, &g)
+		t.ModInverse(&t, &m)
+		t.Mul(&t, big.NewInt(-2/g.Int64()))
+		t.Mod(&t, &m)
+	}
+	s = new(big.Int).Mul(F1, &t)
+	s.Add(s, big.NewInt(1))
+	s.Mod(s, G)
+	if s.Sign() == 0 {
+		s.Set(G)
+	}
+	return G, s
+}
+
+// Returns whether 1 < d < n and d divides n.
+func dividesProperly(d, n *big.Int) bool {
+	var r big.Int
+	return d.Cmp(big.NewInt(1)) > 0 && d.Cmp(n) < 0 &&
+		r.Rem(n, d).Sign() == 0
+}
+
+// Returns whether the Lucas parameter P satisfies the conditions of
+// the N+1 test for the prime q dividing n + 1, given the discriminant
+// D. Returns an error wrapping ErrComposite if n is found to be
+// composite along the way.
+func isLucasParameter(n, D, P, q *big.Int) (bool, error) {
+	one := big.NewInt(1)
+	var Q, g big.Int
+	Q.Mul(P, P)
+	Q.Sub(&Q, D)
+	Q.Rsh(&Q, 2)
+	g.GCD(nil, nil, new(big.Int).Abs(&Q), n)
+	if g.Cmp(one) != 0 {
+		if g.Cmp(n) != 0 {
+			return false, fmt.Errorf(
+				"%w: n = %v has factor %v", ErrComposite, n, &g)
+		}
+		return false, nil
+	}
+	var PMod, DMod, e big.Int
+	PMod.Mod(P, n)
+	DMod.Mod(D, n)
+	Q.Mod(&Q, n)
+	e.Add(n, one)
+	if U, _, _ := lucasSequence(&PMod, &Q, &DMod, &e, n); U.Sign() != 0 {
+		return false, fmt.Errorf(
+			"%w: n = %v doesn't divide U_(n + 1) with P = %v",
+			ErrComposite, n, P)
+	}
+	e.Quo(&e, q)
+	U, _, _ := lucasSequence(&PMod, &Q, &DMod, &e, n)
+	g.GCD(nil, nil, U, n)
+	if g.Cmp(one) == 0 {
+		return true, nil
+	}
+	if g.Cmp(n) != 0 {
+		return false, fmt.Errorf(
+			"%w: n = %v has factor %v", ErrComposite, n, &g)
+	}
+	return false, nil
+}
+
+// Returns the smallest odd Lucas parameter P >= 1 that satisfies the
+// conditions of the N+1 test for the prime q dividing n + 1, given
+// the discriminant D. Returns an error wrapping ErrComposite if n is
+// found to be composite, or ErrProofNotFound if no P up to
+// nPlusOneMaxP works.
+func findLucasParameter(n, D, q *big.Int) (*big.Int, error) {
+	// P must be odd, since P^2 = D = 1 (mod 4).
+	for p := int64(1); p <= nPlusOneMaxP; p += 2 {
+		P := big.NewInt(p)
+		ok, err := isLucasParameter(n, D, P, q)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			return P, nil
+		}
+	}
+	return nil, fmt.Errorf(
+		"%w: no Lucas parameter up to %d works for the factor %v "+
+			"of n + 1 = %v + 1",
+		ErrProofNotFound, nPlusOneMaxP, q, n)
+}
+
+// Returns nil if c proves c.N prime, and an error wrapping
+// ErrInvalidCertificate otherwise.
+func (c *NPlusOneCertificate) Verify() error {
+	if c.N == nil || c.N.Cmp(big.NewInt(2)) < 0 {
+		return fmt.Errorf(
+			"%w: N = %v must be >= 2", ErrInvalidCertificate, c.N)
+	}
+	if c.N.IsUint64() {
+		if !IsPrimeUint64(c.N.Uint64()) {
+			return fmt.Errorf(
+				"%w: N = %v is composite",
+				ErrInvalidCertificate, c.N)
+		}
+		return nil
+	}
+	if c.N.Bit(0) == 0 || c.D == nil ||
+		new(big.Int).And(c.D, big.NewInt(3)).Int64() != 1 ||
+		big.Jacobi(c.D, c.N) != -1 {
+		return fmt.Errorf(
+			"%w: N = %v must be odd, and D = %v must be 1 mod 4 "+
+				"with (D/N) = -1",
+			ErrInvalidCertificate, c.N, c.D)
+	}
+
+	one := big.NewInt(1)
+	nPlusOne := new(big.Int).Add(c.N, one)
+	nMinusOne := new(big.Int).Sub(c.N, one)
+	F2, err := c.verifyFactors(c.PlusFactors, nPlusOne, "+",
+		func(q, P *big.Int) bool {
+			ok, _ := isLucasParameter(c.N, c.D, P, q)
+			return ok
+		})
+	if err != nil {
+		return err
+	}
+	F1, err := c.verifyFactors(c.MinusFactors, nMinusOne, "-",
+		func(q, a *big.Int) bool {
+			return a.Sign() > 0 && a.Cmp(c.N) < 0 &&
+				isPocklingtonBase(c.N, q, a)
+		})
+	if err != nil {
+		return err
+	}
+
+	G, s := getNSquaredMinusOneClass(F1, F2)
+	if new(big.Int).Mul(G, G).Cmp(c.N) <= 0 {
+		return fmt.Errorf(
+			"%w: lcm(F1 = %v, F2 = %v) isn't above sqrt(N = %v)",
+			ErrInvalidCertificate, F1, F2, c.N)
+	}
+	if dividesProperly(s, c.N) {
+		return fmt.Errorf(
+			"%w: N = %v has factor %v",
+			ErrInvalidCertificate, c.N, s)
+	}
+	return nil
+}
+
+// Checks that the product F of factors divides m = N +- 1 (where sign
+// is "+" or "-", for error messages), that every prime in factors is
+// proven prime, and that isValidA holds for each prime and its A.
+// Returns F.
+func (c *NPlusOneCertificate) verifyFactors(
+	factors []NPlusOneFactor, m *big.Int, sign string,
+	isValidA func(q, a *big.Int) bool) (*big.Int, error) {
+	F := big.NewInt(1)
+	var pk, r big.Int
+	for _, f := range factors {
+		if f.P == nil || f.K == nil || f.A == nil ||
+			f.P.Cmp(big.NewInt(2)) < 0 || f.K.Sign() <= 0 {
+			return nil, fmt.Errorf(
+				"%w: factor %v^%v of N %s 1 = %v %s 1 is out "+
+					"of range", ErrInvalidCertificate,
+				f.P, f.K, sign, c.N, sign)
+		}
+		if f.P.IsUint64() {
+			if !IsPrimeUint64(f.P.Uint64()) {
+				return nil, fmt.Errorf(
+					"%w: factor %v of N %s 1 = %v %s 1 "+
+						"is composite",
+					ErrInvalidCertificate, f.P, sign,
+					c.N, sign)
+			}
+		} else {
+			if f.Certificate == nil ||
+				f.Certificate.N == nil ||
+				f.Certificate.N.Cmp(f.P) != 0 {
+				return nil, fmt.Errorf(
+					"%w: factor %v of N %s 1 = %v %s 1 "+
+						"has no certificate",
+					ErrInvalidCertificate, f.P, sign,
+					c.N, sign)
+			}
+			if err := f.Certificate.Verify(); err != nil {
+				return nil, err
+			}
+		}
+		if !isValidA(f.P, f.A) {
+			return nil, fmt.Errorf(
+				"%w: %v doesn't work for the factor %v of "+
+					"N %s 1 = %v %s 1",
+				ErrInvalidCertificate, f.A, f.P, sign, c.N,
+				sign)
+		}
+		F.Mul(F, pk.Exp(f.P, f.K, nil))
+	}
+	if r.Rem(m, F).Sign() != 0 {
+		return nil, fmt.Errorf(
+			"%w: F = %v doesn't divide N %s 1 = %v %s 1",
+			ErrInvalidCertificate, F, sign, c.N, sign)
+	}
+	return F, nil
+}
+
+// Writes c to w as indented JSON.
+func (c *NPlusOneCertificate) WriteJSON(w io.Writer) error {
+	return writeIndentedJSON(w, c)
+}
@@ -0,0 +1,195 @@
+package aks
+
+import "bytes"
+import "encoding/json"
+import "errors"
+import "math/big"
+import "testing"
+
+// Returns the smallest prime of the form 2kq - 1 with k >= 1.
+func findPrimeOneLessThanMultiple(q *big.Int) *big.Int {
+	var p big.Int
+	for k := int64(1); ; k++ {
+		p.Mul(q, big.NewInt(2*k))
+		p.Sub(&p, big.NewInt(1))
+		if p.ProbablyPrime(primalityRounds) {
+			return &p
+		}
+	}
+}
+
+// ProveNPlusOne should prove primes whose n + 1 factors easily,
+// including ones that need recursive proofs, and its certificates
+// should verify.
+func TestProveNPlusOne(t *testing.T) {
+	m127 := new(big.Int).Lsh(big.NewInt(1), 127)
+	m127.Sub(m127, big.NewInt(1))
+	// 2(2^127 - 1)k - 1 needs a proof that 2^127 - 1 is prime.
+	p := findPrimeOneLessThanMultiple(m127)
+	for _, n := range []*big.Int{
+		big.NewInt(2), big.NewInt(1000003), m127, p,
+	} {
+		c, err := ProveNPlusOne(n)
+		if err != nil {
+			t.Fatal(n, err)
+		}
+		if c.N.Cmp(n) != 0 {
+			t.Error(n, c.N)
+		}
+		if err := c.Verify(); err != nil {
+			t.Error(n, err)
+		}
+	}
+
+	c, err := ProveNPlusOne(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	last := c.PlusFactors[len(c.PlusFactors)-1]
+	if last.P.Cmp(m127) != 0 || last.Certificate == nil ||
+		len(c.MinusFactors) != 0 {
+		t.Error(last.P, last.Certificate, c.MinusFactors)
+	}
+}
+
+// ProveNPlusOne should reject composites and bad input.
+func TestProveNPlusOneErrors(t *testing.T) {
+	f7 := new(big.Int).Lsh(big.NewInt(1), 128)
+	f7.Add(f7, big.NewInt(1))
+	for _, n := range []*big.Int{big.NewInt(561), f7} {
+		if c, err := ProveNPlusOne(n); !errors.Is(err, ErrComposite) {
+			t.Error(n, c, err)
+		}
+	}
+	for _, n := range []*big.Int{big.NewInt(-1), big.NewInt(1)} {
+		if c, err := ProveNPlusOne(n); !errors.Is(err, ErrBadInput) {
+			t.Error(n, c, err)
+		}
+	}
+}
+
+// A prime with n - 1 divisible by 2 * 3^45 and n + 1 divisible by 2 *
+// 5^31, neither of which is above sqrt(n) by itself, should be
+// provable with both together.
+func TestNPlusOneProverCombined(t *testing.T) {
+	n, _ := new(big.Int).SetString(
+		"1606938044258990873333561059678047966444864869117736816406249",
+		10)
+	plus := []PrimePower{
+		{big.NewInt(2), big.NewInt(1)}, {big.NewInt(5), big.NewInt(31)},
+	}
+	minus := []PrimePower{
+		{big.NewInt(2), big.NewInt(1)}, {big.NewInt(3), big.NewInt(45)},
+	}
+
+	p, err := newNPlusOneProver(n)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.addFactors(plus, true); err != nil {
+		t.Fatal(err)
+	}
+	if c, err := p.finish(); !errors.Is(err, ErrProofNotFound) {
+		t.Error(c, err)
+	}
+	if err := p.addFactors(minus, false); err != nil {
+		t.Fatal(err)
+	}
+	c, err := p.finish()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(c.PlusFactors) != 2 || len(c.MinusFactors) != 2 {
+		t.Error(c.PlusFactors, c.MinusFactors)
+	}
+	if err := c.Verify(); err != nil {
+		t.Error(err)
+	}
+
+	// Neither half is enough by itself.
+	c.MinusFactors = nil
+	if err := c.Verify(); !errors.Is(err, ErrInvalidCertificate) {
+		t.Error(err)
+	}
+}
+
+// The class s of getNSquaredMinusOneClass should be 1 mod F1 and -1
+// mod F2.
+func TestGetNSquaredMinusOneClass(t *testing.T) {
+	for _, c := range []struct {
+		F1, F2, G, s int64
+	}{
+		{1, 1, 1, 1},
+		{1, 10, 10, 9},
+		{12, 1, 12, 1},
+		{12, 10, 60, 49},
+		{9, 4, 36, 19},
+		{4, 9, 36, 17},
+	} {
+		G, s := getNSquaredMinusOneClass(
+			big.NewInt(c.F1), big.NewInt(c.F2))
+		if G.Int64() != c.G || s.Int64() != c.s {
+			t.Error(c.F1, c.F2, G, s)
+		}
+	}
+}
+
+// Tampering with a certificate should make it fail verification, and
+// it should survive a round trip through JSON.
+func TestNPlusOneCertificateVerify(t *testing.T) {
+	m127 := new(big.Int).Lsh(big.NewInt(1), 127)
+	m127.Sub(m127, big.NewInt(1))
+	p := findPrimeOneLessThanMultiple(m127)
+	c, err := ProveNPlusOne(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := c.WriteJSON(&buf); err != nil {
+		t.Fatal(err)
+	}
+	var decoded NPlusOneCertificate
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if err := decoded.Verify(); err != nil {
+		t.Error(err)
+	}
+
+	tamperings := []func(c *NPlusOneCertificate){
+		func(c *NPlusOneCertificate) { c.N.Add(c.N, big.NewInt(2)) },
+		func(c *NPlusOneCertificate) { c.D.SetInt64(1) },
+		func(c *NPlusOneCertificate) { c.D = nil },
+		func(c *NPlusOneCertificate) {
+			c.PlusFactors = c.PlusFactors[:len(c.PlusFactors)-1]
+		},
+		func(c *NPlusOneCertificate) {
+			c.PlusFactors[len(c.PlusFactors)-1].Certificate = nil
+		},
+		func(c *NPlusOneCertificate) {
+			inner := c.PlusFactors[len(c.PlusFactors)-1].Certificate
+			inner.D.Add(inner.D, big.NewInt(4))
+		},
+		func(c *NPlusOneCertificate) {
+			c.PlusFactors[0].K.Add(c.PlusFactors[0].K, big.NewInt(5))
+		},
+		func(c *NPlusOneCertificate) {
+			c.PlusFactors[0].P.SetInt64(4)
+		},
+		func(c *NPlusOneCertificate) {
+			c.PlusFactors[len(c.PlusFactors)-1].A = nil
+		},
+	}
+	for i, tamper := range tamperings {
+		var tampered NPlusOneCertificate
+		if err := json.Unmarshal(buf.Bytes(), &tampered); err != nil {
+			t.Fatal(err)
+		}
+		tamper(&tampered)
+		if err := tampered.Verify(); !errors.Is(
+			err, ErrInvalidCertificate) {
+			t.Error(i, err)
+		}
+	}
+}
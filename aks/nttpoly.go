@@ -0,0 +1,690 @@
+package aks
+
+import "encoding/json"
+import "fmt"
+import "math/big"
+import "math/bits"
+import "runtime"
+import "sync"
+
+// An nttPrime is a prime p with 2^61 < p < 2^62 and p = 1 mod L,
+// where L is the transform length of its nttPolyRing, along with the
+// tables needed to do number-theoretic transforms (NTTs) of length L
+// mod p.
+//
+// Multiplications by twiddle factors are done with mulShoup, and all
+// others with Montgomery reduction (see mulMod), so the constants for
+// the latter are stored in Montgomery form, i.e. c is stored as
+// c*2^64 mod p.
+type nttPrime struct {
+	p uint64
+	// -1/p mod 2^64.
+	pInv uint64
+	// 2^128 mod p, i.e. 2^64 in Montgomery form.
+	r2 uint64
+	// A primitive Lth root of unity mod p.
+	w uint64
+	// The twiddle factors of each stage of the transform with
+	// the given half-length h, i.e. w^(j*L/(2h)) for j < h, are
+	// stored in roots[h:2h], and likewise with w^-1 in
+	// inverseRoots. The *Shoup slices hold the corresponding
+	// constants for mulShoup. These are only filled in by
+	// nttPolyRing.newPoly, since they take O(L) time and space.
+	roots, rootsShoup               []uint64
+	inverseRoots, inverseRootsShoup []uint64
+	// L^-1 * 2^64 in Montgomery form, to undo the scaling of
+	// the inverse transform and of mulMod on the transforms.
+	scale uint64
+	// For each earlier prime q of the ring, q^-1 mod p in
+	// Montgomery form, for CRT reconstruction.
+	crtInverses []uint64
+}
+
+// Returns a*b*2^-64 mod p, where a*b < p*2^64. If b is in Montgomery
+// form, this is just a*b mod p.
+func (q *nttPrime) mulMod(a, b uint64) uint64 {
+	hi, lo := bits.Mul64(a, b)
+	mhi, mlo := bits.Mul64(lo*q.pInv, q.p)
+	_, carry := bits.Add64(lo, mlo, 0)
+	t := hi + mhi + carry
+	if t >= q.p {
+		t -= q.p
+	}
+	return t
+}
+
+// Returns a + b mod p, where a, b < p.
+func (q *nttPrime) addMod(a, b uint64) uint64 {
+	t := a + b
+	if t >= q.p {
+		t -= q.p
+	}
+	return t
+}
+
+// Returns a - b mod p, where a, b < p.
+func (q *nttPrime) subMod(a, b uint64) uint64 {
+	t := a - b
+	if a < b {
+		t += q.p
+	}
+	return t
+}
+
+// Returns the number whose 64-bit limbs are given by words mod p,
+// where words holds limbCount limbs.
+func (q *nttPrime) remWords(words []big.Word, limbCount uint) uint64 {
+	var r uint64
+	for i := limbCount; i > 0; i-- {
+		r = q.shiftInLimb(r, getUint64(words, i-1))
+	}
+	return r
+}
+
+// Returns r*2^64 + w mod p, where r < p.
+func (q *nttPrime) shiftInLimb(r, w uint64) uint64 {
+	// Since r < p, Montgomery reduction gives (r*2^64 + w)*2^-64
+	// mod p, which is then multiplied by 2^64.
+	mhi, mlo := bits.Mul64(w*q.pInv, q.p)
+	_, carry := bits.Add64(w, mlo, 0)
+	t := r + mhi + carry
+	if t >= q.p {
+		t -= q.p
+	}
+	return q.mulMod(t, q.r2)
+}
+
+// Returns a*w mod p plus either 0 or p, where wShoup = floor(w*2^64/p)
+// and w < p. This is Shoup's method, which is faster than mulMod when
+// w is fixed.
+func (q *nttPrime) mulShoup(a, w, wShoup uint64) uint64 {
+	hi, _ := bits.Mul64(a, wShoup)
+	return a*w - hi*q.p
+}
+
+// Transforms a, whose length must be L and whose elements must be
+// less than p, in place. The result is in bit-reversed order, which
+// is what inverseTransform expects.
+//
+// The butterflies use Harvey's lazy reduction, keeping elements less
+// than 2p until the end.
+func (q *nttPrime) transform(a []uint64) {
+	L := len(a)
+	twoP := 2 * q.p
+	for half := L / 2; half >= 1; half /= 2 {
+		roots := q.roots[half : 2*half]
+		rootsShoup := q.rootsShoup[half : 2*half]
+		for start := 0; start < L; start += 2 * half {
+			x := a[start : start+half]
+			y := a[start+half : start+2*half]
+			for j := range x {
+				u := x[j]
+				v := y[j]
+				sum := u + v
+				if sum >= twoP {
+					sum -= twoP
+				}
+				x[j] = sum
+				y[j] = q.mulShoup(u-v+twoP, roots[j], rootsShoup[j])
+			}
+		}
+	}
+	for i, c := range a {
+		if c >= q.p {
+			a[i] = c - q.p
+		}
+	}
+}
+
+// Undoes transform on a, except that the result is scaled by L.
+func (q *nttPrime) inverseTransform(a []uint64) {
+	L := len(a)
+	twoP := 2 * q.p
+	for half := 1; half < L; half *= 2 {
+		roots := q.inverseRoots[half : 2*half]
+		rootsShoup := q.inverseRootsShoup[half : 2*half]
+		for start := 0; start < L; start += 2 * half {
+			x := a[start : start+half]
+			y := a[start+half : start+2*half]
+			for j := range x {
+				// u and y[j] are less than 4p.
+				u := x[j]
+				if u >= twoP {
+					u -= twoP
+				}
+				v := q.mulShoup(y[j], roots[j], rootsShoup[j])
+				x[j] = u + v
+				y[j] = u - v + twoP
+			}
+		}
+	}
+	for i, c := range a {
+		if c >= twoP {
+			c -= twoP
+		}
+		if c >= q.p {
+			c -= q.p
+		}
+		a[i] = c
+	}
+}
+
+// An nttPolyRing makes nttPolys. It holds the primes and tables
+// shared by its polys, which are read-only once it's made.
+type nttPolyRing struct {
+	N, R big.Int
+	// The number of big.Words per coefficient.
+	k int
+	// The transform length, i.e. the smallest power of two that
+	// is at least 2R - 1.
+	L int
+	// Primes whose product is greater than R*(N - 1)^2, which
+	// bounds the coefficients of the product of two polynomials
+	// mod X^R - 1 before reducing them mod N.
+	primes []nttPrime
+	// Used to fill in the twiddle factors of primes once.
+	twiddleOnce sync.Once
+}
+
+// Returns x^e mod p.
+func expMod(x, e, p uint64) uint64 {
+	var z big.Int
+	z.Exp(
+		new(big.Int).SetUint64(x), new(big.Int).SetUint64(e),
+		new(big.Int).SetUint64(p))
+	return z.Uint64()
+}
+
+// Returns x*2^64 mod p, i.e. x in Montgomery form.
+func toMontgomery(x, p uint64) uint64 {
+	_, r := bits.Div64(x%p, 0, p)
+	return r
+}
+
+// Returns the twiddle factors for the root of unity w of order L mod
+// p, and their constants for mulShoup, as described in nttPrime.
+func makeTwiddleFactors(w, p uint64, L int) (roots, rootsShoup []uint64) {
+	roots = make([]uint64, L)
+	rootsShoup = make([]uint64, L)
+	for half := 1; half < L; half *= 2 {
+		step := expMod(w, uint64(L/(2*half)), p)
+		root := uint64(1)
+		for j := 0; j < half; j++ {
+			roots[half+j] = root
+			rootsShoup[half+j], _ = bits.Div64(root, 0, p)
+			hi, lo := bits.Mul64(root, step)
+			root = bits.Rem64(hi, lo, p)
+		}
+	}
+	return roots, rootsShoup
+}
+
+// Makes the nttPrime for p = 1 mod L.
+func newNTTPrime(p uint64, L int, earlier []nttPrime) nttPrime {
+	q := nttPrime{
+		p:    p,
+		pInv: calculateMontgomeryInverse(p),
+	}
+	q.r2 = toMontgomery(toMontgomery(1, p), p)
+
+	// Find a primitive Lth root of unity w by raising a
+	// quadratic non-residue x to the (p - 1)/L power, so that
+	// w^(L/2) = x^((p - 1)/2) = -1.
+	w := uint64(1)
+	if L > 1 {
+		for x := uint64(2); ; x++ {
+			if expMod(x, (p-1)/2, p) == p-1 {
+				w = expMod(x, (p-1)/uint64(L), p)
+				break
+			}
+		}
+	}
+	q.w = w
+
+	lInv := expMod(uint64(L)%p, p-2, p)
+	q.scale = toMontgomery(toMontgomery(lInv, p), p)
+
+	q.crtInverses = make([]uint64, len(earlier))
+	for i, e := range earlier {
+		q.crtInverses[i] = toMontgomery(expMod(e.p%p, p-2, p), p)
+	}
+	return q
+}
+
+// Fills in the twiddle factors of q for transform length L.
+func (q *nttPrime) makeTwiddleFactors(L int) {
+	wInv := expMod(q.w, q.p-2, q.p)
+	q.roots, q.rootsShoup = makeTwiddleFactors(q.w, q.p, L)
+	q.inverseRoots, q.inverseRootsShoup = makeTwiddleFactors(wInv, q.p, L)
+}
+
+// The log2 of the largest transform length supported by
+// nttPolyRing. Since there are 2^(61 - s) candidates for nttPrimes
+// for transform length 2^s, making s bigger than this leaves too
+// few primes.
+const maxNTTLengthLog2 = 40
+
+// Makes an nttPolyRing for polynomials mod (N, X^R - 1), or returns
+// an error if R is too large. N must be positive.
+func newNTTPolyRing(N, R big.Int) (*nttPolyRing, error) {
+	rInt := int(R.Int64())
+	s := uint(bits.Len(uint(2*rInt - 2)))
+	if s > maxNTTLengthLog2 {
+		return nil, fmt.Errorf(
+			"%w: r = %v is too large for the %v backend",
+			ErrBadInput, &R, NTTPolyBackend)
+	}
+	ring := &nttPolyRing{N: N, R: R, k: len(N.Bits()), L: 1 << s}
+
+	var bound, product, candidate big.Int
+	bound.Sub(&N, big.NewInt(1))
+	bound.Mul(&bound, &bound)
+	bound.Mul(&bound, &R)
+	product.SetInt64(1)
+	// Try p = c*L + 1 for c from just below 2^62/L down to 2^61/L.
+	for c := uint64(1)<<(62-s) - 1; len(ring.primes) == 0 ||
+		product.Cmp(&bound) <= 0; c-- {
+		if c < uint64(1)<<(61-s) {
+			return nil, fmt.Errorf(
+				"%w: n = %v is too large for the %v backend",
+				ErrBadInput, &N, NTTPolyBackend)
+		}
+		p := c<<s + 1
+		candidate.SetUint64(p)
+		if !candidate.ProbablyPrime(0) {
+			continue
+		}
+		ring.primes = append(
+			ring.primes, newNTTPrime(p, ring.L, ring.primes))
+		product.Mul(&product, &candidate)
+	}
+	return ring, nil
+}
+
+// polyRing implementation.
+func (r *nttPolyRing) newPoly() poly {
+	r.twiddleOnce.Do(func() {
+		for i := range r.primes {
+			r.primes[i].makeTwiddleFactors(r.L)
+		}
+	})
+	rInt := int(r.R.Int64())
+	residues := make([][]uint64, len(r.primes))
+	for i := range residues {
+		residues[i] = make([]uint64, r.L)
+	}
+	return &nttPoly{
+		ring:         r,
+		coefficients: make([]big.Word, rInt*r.k),
+		residues:     residues,
+	}
+}
+
+// polyRing implementation.
+func (r *nttPolyRing) getPolyBytes() int64 {
+	return calculateNTTPolyBytes(
+		int(r.R.Int64())*r.k, len(r.primes)*r.L)
+}
+
+// An nttPoly represents a polynomial mod (N, X^R - 1) for any N.
+//
+// Two nttPolys are multiplied by reducing their coefficients mod
+// each prime of their nttPolyRing, multiplying them mod each prime
+// with number-theoretic transforms, and reconstructing the
+// coefficients of the product mod N with the Chinese remainder
+// theorem. This takes O(k*R*log(R) + k^2*R) word operations for
+// coefficients of k words, compared to the O((k*R)^1.58) of the
+// Karatsuba multiplication used for bigIntPoly, so it's faster for
+// large enough N and R.
+type nttPoly struct {
+	ring *nttPolyRing
+	// The R coefficients of the polynomial, in order of
+	// increasing degree, each less than N and stored in ring.k
+	// big.Words, least significant first.
+	coefficients []big.Word
+	// Scratch space for the transforms mod each of the ring's
+	// primes.
+	residues [][]uint64
+	// Whether residues holds the transforms of the coefficients,
+	// so that they needn't be recomputed when p is multiplied
+	// into another nttPoly repeatedly, e.g. by Pow.
+	transformed bool
+}
+
+// Calls panicModulusMismatch if p and q aren't mod the same N and R.
+func (p *nttPoly) checkModulus(op string, q *nttPoly) {
+	if q.ring != p.ring && (q.ring.N.Cmp(&p.ring.N) != 0 ||
+		q.ring.R.Cmp(&p.ring.R) != 0) {
+		panicModulusMismatch(
+			op, &p.ring.N, int(p.ring.R.Int64()),
+			&q.ring.N, int(q.ring.R.Int64()))
+	}
+}
+
+// Returns the ith coefficient of p as a slice of p.coefficients.
+func (p *nttPoly) getCoefficientWords(i int) []big.Word {
+	k := p.ring.k
+	return p.coefficients[i*k : (i+1)*k]
+}
+
+// Sets the ith coefficient of p to c, which must be less than N,
+// without invalidating p's transforms. Unlike setCoefficient, this
+// may be called on different coefficients from multiple goroutines.
+func (p *nttPoly) setCoefficientWords(i int, c *big.Int) {
+	words := p.getCoefficientWords(i)
+	n := copy(words, c.Bits())
+	for j := n; j < len(words); j++ {
+		words[j] = 0
+	}
+}
+
+// poly implementation.
+func (p *nttPoly) Set(a, k, N big.Int) {
+	p.transformed = false
+	for i := range p.coefficients {
+		p.coefficients[i] = 0
+	}
+	rInt := len(p.coefficients) / p.ring.k
+	var c0, ci, kModR big.Int
+	c0.Mod(&a, &N)
+	kModR.Mod(&k, big.NewInt(int64(rInt)))
+	i := int(kModR.Int64())
+	ci.Mod(big.NewInt(1), &N)
+	if i == 0 {
+		ci.Add(&ci, &c0)
+		ci.Mod(&ci, &N)
+	}
+	p.setCoefficientWords(0, &c0)
+	p.setCoefficientWords(i, &ci)
+}
+
+// poly implementation.
+func (p *nttPoly) copyFrom(q poly) {
+	qPoly := q.(*nttPoly)
+	p.checkModulus("copy", qPoly)
+	copy(p.coefficients, qPoly.coefficients)
+	p.transformed = false
+}
+
+// poly implementation.
+func (p *nttPoly) clone() poly {
+	c := p.ring.newPoly()
+	c.copyFrom(p)
+	return c
+}
+
+// poly implementation.
+func (p *nttPoly) Eq(q poly) bool {
+	qPoly := q.(*nttPoly)
+	p.checkModulus("compare", qPoly)
+	qCoefficients := qPoly.coefficients
+	for i, w := range p.coefficients {
+		if w != qCoefficients[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// The smallest transform length for which nttPoly.mul does the work
+// for each prime in its own goroutine.
+const minParallelNTTLength = 1 << 12
+
+// Runs f(i) for each i < count, in parallel if parallel is true.
+func forEach(count int, parallel bool, f func(i int)) {
+	if !parallel || count <= 1 {
+		for i := 0; i < count; i++ {
+			f(i)
+		}
+		return
+	}
+	var wg sync.WaitGroup
+	wg.Add(count)
+	for i := 0; i < count; i++ {
+		go func(i int) {
+			defer wg.Done()
+			f(i)
+		}(i)
+	}
+	wg.Wait()
+}
+
+// Sets residues to the coefficients of p mod prime, padded with
+// zeros, and transforms it.
+func (p *nttPoly) transform(prime *nttPrime, residues []uint64) {
+	rInt := len(p.coefficients) / p.ring.k
+	limbCount := uint(p.ring.k*bits.UintSize+63) / 64
+	for i := 0; i < rInt; i++ {
+		residues[i] = prime.remWords(p.getCoefficientWords(i), limbCount)
+	}
+	for i := rInt; i < len(residues); i++ {
+		residues[i] = 0
+	}
+	prime.transform(residues)
+}
+
+// poly implementation.
+func (p *nttPoly) Mul(q poly, N big.Int, tmp poly) {
+	qPoly := q.(*nttPoly)
+	p.checkModulus("multiply", qPoly)
+	p.mul(qPoly, tmp.(*nttPoly))
+}
+
+// Like Mul, but without the type assertions.
+func (p *nttPoly) mul(q *nttPoly, tmp *nttPoly) {
+	ring := p.ring
+	rInt := len(p.coefficients) / ring.k
+	parallel := ring.L >= minParallelNTTLength
+
+	// Multiply mod each prime, leaving the coefficients of the
+	// product mod X^R - 1 in the first R residues of p.
+	forEach(len(ring.primes), parallel, func(j int) {
+		prime := &ring.primes[j]
+		pResidues := p.residues[j]
+		p.transform(prime, pResidues)
+		qResidues := pResidues
+		if q != p {
+			qResidues = q.residues[j]
+			if !q.transformed {
+				q.transform(prime, qResidues)
+			}
+		}
+		for i := range pResidues {
+			pResidues[i] = prime.mulMod(pResidues[i], qResidues[i])
+		}
+		prime.inverseTransform(pResidues)
+		for i := 0; i < rInt; i++ {
+			c := pResidues[i]
+			if i+rInt < ring.L {
+				c = prime.addMod(c, pResidues[i+rInt])
+			}
+			pResidues[i] = prime.mulMod(c, prime.scale)
+		}
+	})
+
+	if q != p {
+		q.transformed = true
+	}
+
+	// Reconstruct the coefficients mod N into tmp, splitting them
+	// evenly across goroutines.
+	chunkCount := 1
+	if parallel {
+		chunkCount = runtime.GOMAXPROCS(0)
+	}
+	forEach(chunkCount, parallel, func(chunk int) {
+		start := rInt * chunk / chunkCount
+		end := rInt * (chunk + 1) / chunkCount
+		digits := make([]uint64, len(ring.primes))
+		var x, y, digit, prime, quotient, remainder big.Int
+		for i := start; i < end; i++ {
+			// Find the digits of the coefficient in the mixed
+			// radix p_0, p_1, ... with Garner's algorithm.
+			for j := range ring.primes {
+				pj := &ring.primes[j]
+				t := p.residues[j][i]
+				for l, inverse := range pj.crtInverses {
+					d := digits[l]
+					if d >= pj.p {
+						d -= pj.p
+					}
+					t = pj.mulMod(pj.subMod(t, d), inverse)
+				}
+				digits[j] = t
+			}
+
+			// Evaluate the digits with Horner's method and
+			// reduce mod N.
+			x.SetUint64(digits[len(digits)-1])
+			for j := len(digits) - 2; j >= 0; j-- {
+				prime.SetUint64(ring.primes[j].p)
+				digit.SetUint64(digits[j])
+				y.Mul(&x, &prime)
+				x.Add(&y, &digit)
+			}
+			quotient.QuoRem(&x, &ring.N, &remainder)
+			tmp.setCoefficientWords(i, &remainder)
+		}
+	})
+	p.coefficients, tmp.coefficients = tmp.coefficients, p.coefficients
+	p.transformed = false
+	tmp.transformed = false
+}
+
+// Like mul, but only multiplies by the terms of q with the given
+// degrees, which must include all of q's non-zero coefficients (see
+// getSparseDegrees).
+func (p *nttPoly) mulSparse(q *nttPoly, degrees []int, tmp *nttPoly) {
+	rInt := len(p.coefficients) / p.ring.k
+	var c, pi, qd, product, quotient, remainder big.Int
+	for k := 0; k < rInt; k++ {
+		c.SetInt64(0)
+		for _, d := range degrees {
+			i := k - d
+			if i < 0 {
+				i += rInt
+			}
+			pi.SetBits(p.getCoefficientWords(i))
+			qd.SetBits(q.getCoefficientWords(d))
+			product.Mul(&pi, &qd)
+			c.Add(&c, &product)
+		}
+		quotient.QuoRem(&c, &p.ring.N, &remainder)
+		tmp.setCoefficientWords(k, &remainder)
+	}
+	p.coefficients, tmp.coefficients = tmp.coefficients, p.coefficients
+	p.transformed = false
+	tmp.transformed = false
+}
+
+// poly implementation.
+func (p *nttPoly) Pow(N big.Int, tmp1, tmp2 poly) {
+	p.powCancelable(N, tmp1, tmp2, nil, nil)
+}
+
+// poly implementation.
+func (p *nttPoly) powCancelable(
+	N big.Int, tmp1Poly, tmp2Poly poly,
+	done <-chan struct{}, onMul func()) bool {
+	tmp1 := tmp1Poly.(*nttPoly)
+	tmp2 := tmp2Poly.(*nttPoly)
+	copy(tmp1.coefficients, p.coefficients)
+	tmp1.transformed = false
+	degrees := getSparseDegrees(p, len(p.coefficients)/p.ring.k)
+
+	for i := N.BitLen() - 2; i >= 0; i-- {
+		select {
+		case <-done:
+			return false
+		default:
+		}
+		tmp1.mul(tmp1, tmp2)
+		if onMul != nil {
+			onMul()
+		}
+		if N.Bit(i) != 0 {
+			if degrees != nil {
+				tmp1.mulSparse(p, degrees, tmp2)
+			} else {
+				tmp1.mul(p, tmp2)
+			}
+			if onMul != nil {
+				onMul()
+			}
+		}
+	}
+
+	p.coefficients, tmp1.coefficients = tmp1.coefficients, p.coefficients
+	p.transformed = false
+	return true
+}
+
+// poly implementation.
+func (p *nttPoly) coefficient(i int) *big.Int {
+	words := append([]big.Word{}, p.getCoefficientWords(i)...)
+	return new(big.Int).SetBits(words)
+}
+
+// poly implementation.
+func (p *nttPoly) setCoefficient(i int, c *big.Int) {
+	p.transformed = false
+	p.setCoefficientWords(i, c)
+}
+
+// poly implementation.
+func (p *nttPoly) getPhiBytes() int64 {
+	residueCount := 0
+	for _, residues := range p.residues {
+		residueCount += cap(residues)
+	}
+	return calculateNTTPolyBytes(cap(p.coefficients), residueCount)
+}
+
+// Returns the number of bytes allocated for an nttPoly with the
+// given number of coefficient words and residues.
+func calculateNTTPolyBytes(wordCount, residueCount int) int64 {
+	return int64(wordCount)*bits.UintSize/8 + int64(residueCount)*8
+}
+
+// fmt.Formatter implementation.
+func (p *nttPoly) Format(f fmt.State, c rune) {
+	coefficientCount := len(p.coefficients) / p.ring.k
+	for coefficientCount > 0 && p.coefficient(coefficientCount-1).Sign() == 0 {
+		coefficientCount--
+	}
+	formatPoly(f, coefficientCount, func(i int) big.Int {
+		return *p.coefficient(i)
+	})
+}
+
+// gob.GobEncoder implementation.
+func (p *nttPoly) GobEncode() ([]byte, error) {
+	return marshalPoly(p, p.ring.N, p.ring.R, gobMarshal)
+}
+
+// gob.GobDecoder implementation.
+func (p *nttPoly) GobDecode(data []byte) error {
+	return p.unmarshal(data, gobUnmarshal)
+}
+
+// json.Marshaler implementation.
+func (p *nttPoly) MarshalJSON() ([]byte, error) {
+	return marshalPoly(p, p.ring.N, p.ring.R, json.Marshal)
+}
+
+// json.Unmarshaler implementation.
+func (p *nttPoly) UnmarshalJSON(data []byte) error {
+	return p.unmarshal(data, json.Unmarshal)
+}
+
+// Sets p to data, as marshaled by GobEncode or MarshalJSON (possibly
+// by another backend), with unmarshal.
+func (p *nttPoly) unmarshal(
+	data []byte, unmarshal func([]byte, interface{}) error) error {
+	q, err := unmarshalPoly(data, unmarshal, NTTPolyBackend)
+	if err != nil {
+		return err
+	}
+	*p = *q.(*nttPoly)
+	return nil
+}
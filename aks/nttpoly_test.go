@@ -0,0 +1,61 @@
+package aks
+
+import "math/big"
+import "math/rand"
+import "testing"
+
+// Multiplying random nttPolys should give the same results as
+// multiplying the equivalent bigIntPolys, including for N and R large
+// enough to need many primes and the parallel code path.
+func TestNTTPolyMulMatchesBigIntPoly(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	var large big.Int
+	large.Lsh(big.NewInt(1), 1000)
+	large.Sub(&large, big.NewInt(1))
+	tests := []struct {
+		N big.Int
+		R int64
+	}{
+		{*big.NewInt(2), 3},
+		{*big.NewInt(101), 53},
+		{*big.NewInt(4294967291), 53},
+		{large, 53},
+		{*big.NewInt(1000003), minParallelNTTLength/2 + 1},
+	}
+	for _, test := range tests {
+		N := test.N
+		R := *big.NewInt(test.R)
+		ring, err := newNTTPolyRing(N, R)
+		if err != nil {
+			t.Fatal(&N, err)
+		}
+		p := ring.newPoly()
+		q := ring.newPoly()
+		tmp := ring.newPoly()
+		pBig := newBigIntPoly(N, R)
+		qBig := newBigIntPoly(N, R)
+		tmpBig := newBigIntPoly(N, R)
+		for i := 0; i < 3; i++ {
+			var a, b big.Int
+			a.Rand(rng, &N)
+			b.Rand(rng, &N)
+			k := *big.NewInt(1 + rng.Int63n(test.R-1))
+			p.Set(a, k, N)
+			pBig.Set(a, k, N)
+			q.Set(b, *big.NewInt(1), N)
+			qBig.Set(b, *big.NewInt(1), N)
+			for j := 0; j < 4; j++ {
+				p.Mul(q, N, tmp)
+				pBig.Mul(qBig, N, tmpBig)
+				p.Mul(p, N, tmp)
+				pBig.Mul(pBig, N, tmpBig)
+			}
+			for j := 0; j < int(test.R); j++ {
+				if p.coefficient(j).Cmp(pBig.coefficient(j)) != 0 {
+					t.Fatal(&N, i, j, p.coefficient(j),
+						pBig.coefficient(j))
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,658 @@
+//go:build opencl && cgo
+
+package aks
+
+/*
+#cgo CFLAGS: -DCL_TARGET_OPENCL_VERSION=120
+#cgo !darwin LDFLAGS: -lOpenCL
+#cgo darwin LDFLAGS: -framework OpenCL
+#ifdef __APPLE__
+#include <OpenCL/opencl.h>
+#else
+#include <CL/cl.h>
+#endif
+#include <stdlib.h>
+
+// Holds what's shared by the polys of an openCLPolyRing.
+typedef struct {
+  cl_context context;
+  cl_command_queue queue;
+  cl_program program;
+  cl_kernel mul;
+  // N, as many words as a coefficient.
+  cl_mem n;
+} aks_opencl_ring;
+
+// Sets *device to the first GPU of any platform, or the first device
+// of any type if there are no GPUs.
+static cl_int aks_opencl_get_device(cl_device_id *device) {
+  cl_platform_id platforms[16];
+  cl_uint count;
+  cl_int err = clGetPlatformIDs(16, platforms, &count);
+  if (err != CL_SUCCESS) {
+    return err;
+  }
+  if (count > 16) {
+    count = 16;
+  }
+  cl_device_type types[] = {CL_DEVICE_TYPE_GPU, CL_DEVICE_TYPE_ALL};
+  for (int t = 0; t < 2; t++) {
+    for (cl_uint i = 0; i < count; i++) {
+      if (clGetDeviceIDs(platforms[i], types[t], 1, device, NULL) ==
+          CL_SUCCESS) {
+        return CL_SUCCESS;
+      }
+    }
+  }
+  return CL_DEVICE_NOT_FOUND;
+}
+
+static void aks_opencl_ring_free(aks_opencl_ring *ring) {
+  if (ring->n != NULL) {
+    clReleaseMemObject(ring->n);
+  }
+  if (ring->mul != NULL) {
+    clReleaseKernel(ring->mul);
+  }
+  if (ring->program != NULL) {
+    clReleaseProgram(ring->program);
+  }
+  if (ring->queue != NULL) {
+    clReleaseCommandQueue(ring->queue);
+  }
+  if (ring->context != NULL) {
+    clReleaseContext(ring->context);
+  }
+  free(ring);
+}
+
+// Returns a new aks_opencl_ring whose program is built from source
+// with the given options, and whose n has the given words. Returns
+// NULL and sets *err on failure.
+static aks_opencl_ring *aks_opencl_ring_new(
+    const char *source, const char *options, cl_ulong *n, long words,
+    cl_int *err) {
+  aks_opencl_ring *ring = calloc(1, sizeof(aks_opencl_ring));
+  cl_device_id device;
+  *err = aks_opencl_get_device(&device);
+  if (*err != CL_SUCCESS) {
+    goto fail;
+  }
+  ring->context = clCreateContext(NULL, 1, &device, NULL, NULL, err);
+  if (*err != CL_SUCCESS) {
+    goto fail;
+  }
+  ring->queue = clCreateCommandQueue(ring->context, device, 0, err);
+  if (*err != CL_SUCCESS) {
+    goto fail;
+  }
+  ring->program =
+      clCreateProgramWithSource(ring->context, 1, &source, NULL, err);
+  if (*err != CL_SUCCESS) {
+    goto fail;
+  }
+  *err = clBuildProgram(ring->program, 1, &device, options, NULL, NULL);
+  if (*err != CL_SUCCESS) {
+    goto fail;
+  }
+  ring->mul = clCreateKernel(ring->program, "aks_mul", err);
+  if (*err != CL_SUCCESS) {
+    goto fail;
+  }
+  ring->n = clCreateBuffer(
+      ring->context, CL_MEM_READ_ONLY | CL_MEM_COPY_HOST_PTR,
+      words * sizeof(cl_ulong), n, err);
+  if (*err != CL_SUCCESS) {
+    goto fail;
+  }
+  return ring;
+
+fail:
+  aks_opencl_ring_free(ring);
+  return NULL;
+}
+
+// Returns a new device buffer of the given number of words, or NULL
+// and sets *err on failure.
+static cl_mem aks_opencl_buffer_new(
+    aks_opencl_ring *ring, long words, cl_int *err) {
+  return clCreateBuffer(
+      ring->context, CL_MEM_READ_WRITE, words * sizeof(cl_ulong), NULL, err);
+}
+
+// Copies the given words from src to buf, starting at word offset.
+static cl_int aks_opencl_buffer_write(
+    aks_opencl_ring *ring, cl_mem buf, long offset, long words,
+    cl_ulong *src) {
+  return clEnqueueWriteBuffer(
+      ring->queue, buf, CL_TRUE, offset * sizeof(cl_ulong),
+      words * sizeof(cl_ulong), src, 0, NULL, NULL);
+}
+
+// Copies the given words from buf, starting at word offset, to dst,
+// after all earlier commands finish.
+static cl_int aks_opencl_buffer_read(
+    aks_opencl_ring *ring, cl_mem buf, long offset, long words,
+    cl_ulong *dst) {
+  return clEnqueueReadBuffer(
+      ring->queue, buf, CL_TRUE, offset * sizeof(cl_ulong),
+      words * sizeof(cl_ulong), dst, 0, NULL, NULL);
+}
+
+static cl_int aks_opencl_buffer_copy(
+    aks_opencl_ring *ring, cl_mem dst, cl_mem src, long words) {
+  return clEnqueueCopyBuffer(
+      ring->queue, src, dst, 0, 0, words * sizeof(cl_ulong), 0, NULL, NULL);
+}
+
+// Enqueues the computation of the product of a and b into out, which
+// must not alias either. Not safe to call from multiple threads at
+// once, since it sets the arguments of the shared kernel.
+static cl_int aks_opencl_mul(
+    aks_opencl_ring *ring, cl_mem out, cl_mem a, cl_mem b, cl_ulong nInv,
+    cl_int r) {
+  cl_int err = clSetKernelArg(ring->mul, 0, sizeof(cl_mem), &out);
+  err |= clSetKernelArg(ring->mul, 1, sizeof(cl_mem), &a);
+  err |= clSetKernelArg(ring->mul, 2, sizeof(cl_mem), &b);
+  err |= clSetKernelArg(ring->mul, 3, sizeof(cl_mem), &ring->n);
+  err |= clSetKernelArg(ring->mul, 4, sizeof(cl_ulong), &nInv);
+  err |= clSetKernelArg(ring->mul, 5, sizeof(cl_int), &r);
+  if (err != CL_SUCCESS) {
+    return CL_INVALID_KERNEL_ARGS;
+  }
+  size_t global = r;
+  return clEnqueueNDRangeKernel(
+      ring->queue, ring->mul, 1, NULL, &global, NULL, 0, NULL, NULL);
+}
+
+static cl_int aks_opencl_finish(aks_opencl_ring *ring) {
+  return clFinish(ring->queue);
+}
+*/
+import "C"
+
+import "encoding/json"
+import "fmt"
+import "math/big"
+import "runtime"
+import "sync"
+import "unsafe"
+
+// Whether there is an OpenCL device, which is only checked once.
+var openCLDeviceOnce sync.Once
+var haveOpenCLDevice bool
+
+// Returns whether OpenCLPolyBackend is available, i.e. whether there
+// is an OpenCL device.
+func isOpenCLPolyBackendAvailable() bool {
+	openCLDeviceOnce.Do(func() {
+		var device C.cl_device_id
+		haveOpenCLDevice = C.aks_opencl_get_device(&device) == C.CL_SUCCESS
+	})
+	return haveOpenCLDevice
+}
+
+// The OpenCL program that multiplies polys, which is built with
+// WORDS defined as the number of words per coefficient.
+//
+// Each work item computes one coefficient of the product mod X^R - 1
+// by summing the products of the pairs of coefficients whose degrees
+// add up to its own mod R, which takes O(R) multiplications of
+// coefficients per work item and O(R^2) overall, but with no
+// communication between work items. Since the coefficients are in
+// Montgomery form, the sum is then reduced mod N with a single
+// Montgomery reduction.
+const openCLMulSource = `
+// Sets acc[offset:] += x*y, with carries.
+#define MUL_ACC(acc, offset, x, y) do {              \
+    ulong lo_ = (x) * (y);                           \
+    ulong hi_ = mul_hi((x), (y));                    \
+    lo_ += carry;                                    \
+    hi_ += (lo_ < carry);                            \
+    acc[offset] += lo_;                              \
+    hi_ += (acc[offset] < lo_);                      \
+    carry = hi_;                                     \
+  } while (0)
+
+// Adds carry to acc starting at index u.
+#define PROPAGATE(acc, u) do {                       \
+    for (int v = (u); carry != 0 && v < 2 * WORDS + 1; v++) { \
+      acc[v] += carry;                               \
+      carry = (acc[v] < carry);                      \
+    }                                                \
+  } while (0)
+
+__kernel void aks_mul(
+    __global ulong *out, __global const ulong *a, __global const ulong *b,
+    __constant ulong *n, ulong nInv, int r) {
+  int k = get_global_id(0);
+  ulong acc[2 * WORDS + 1];
+  for (int s = 0; s < 2 * WORDS + 1; s++) {
+    acc[s] = 0;
+  }
+
+  // The sum is less than R*N^2, which fits into 2*WORDS words.
+  for (int i = 0; i < r; i++) {
+    int j = k - i;
+    if (j < 0) {
+      j += r;
+    }
+    __global const ulong *x = a + i * WORDS;
+    __global const ulong *y = b + j * WORDS;
+    for (int s = 0; s < WORDS; s++) {
+      ulong carry = 0;
+      for (int t = 0; t < WORDS; t++) {
+        MUL_ACC(acc, s + t, x[s], y[t]);
+      }
+      PROPAGATE(acc, s + WORDS);
+    }
+  }
+
+  // Divide the sum by 2^(64*WORDS) mod N one word at a time, by
+  // adding the multiple of N that zeroes out the lowest word. Since
+  // the sum is less than N*2^(64*WORDS), this leaves it less than 2N.
+  for (int s = 0; s < WORDS; s++) {
+    ulong m = acc[s] * nInv;
+    ulong carry = 0;
+    for (int t = 0; t < WORDS; t++) {
+      MUL_ACC(acc, s + t, m, n[t]);
+    }
+    PROPAGATE(acc, s + WORDS);
+  }
+
+  // Subtract N if the result is at least N.
+  int geq = acc[2 * WORDS] != 0;
+  if (!geq) {
+    geq = 1;
+    for (int s = WORDS - 1; s >= 0; s--) {
+      if (acc[WORDS + s] != n[s]) {
+        geq = acc[WORDS + s] > n[s];
+        break;
+      }
+    }
+  }
+  if (geq) {
+    ulong borrow = 0;
+    for (int s = 0; s < WORDS; s++) {
+      ulong x = acc[WORDS + s];
+      ulong d = x - n[s] - borrow;
+      borrow = (x < n[s]) || (x - n[s] < borrow);
+      acc[WORDS + s] = d;
+    }
+  }
+
+  for (int s = 0; s < WORDS; s++) {
+    out[k * WORDS + s] = acc[WORDS + s];
+  }
+}
+`
+
+// Returns an error for the given OpenCL error code.
+func openCLError(op string, err C.cl_int) error {
+	return fmt.Errorf("OpenCL error %d in %s", int(err), op)
+}
+
+// An openCLPolyRing makes openCLPolys.
+type openCLPolyRing struct {
+	N, R big.Int
+	// The number of 64-bit words per coefficient, which is enough
+	// to hold R*N.
+	words int
+	// -1/N mod 2^64.
+	nInv uint64
+	// 2^(64*words) mod N and its inverse, to convert to and from
+	// Montgomery form.
+	montgomeryOne, montgomeryInverse big.Int
+	// Allocated by aks_opencl_ring_new and freed by a finalizer.
+	ring *C.aks_opencl_ring
+	// Guards the arguments of ring's kernel.
+	mulLock sync.Mutex
+}
+
+// Makes an openCLPolyRing for polynomials mod (N, X^R - 1) on the
+// first GPU found (or any OpenCL device if there is no GPU). N must
+// be odd.
+func newOpenCLPolyRing(N, R big.Int) (*openCLPolyRing, error) {
+	if N.Bit(0) == 0 {
+		return nil, fmt.Errorf(
+			"%w: the %v backend requires odd n, not %v",
+			ErrBadInput, OpenCLPolyBackend, &N)
+	}
+	var rN big.Int
+	rN.Mul(&R, &N)
+	r := &openCLPolyRing{N: N, R: R, words: (rN.BitLen() + 63) / 64}
+	n := make([]uint64, r.words)
+	intToUint64s(&N, n)
+	r.nInv = calculateMontgomeryInverse(n[0])
+	r.montgomeryOne.Lsh(big.NewInt(1), uint(64*r.words))
+	r.montgomeryOne.Mod(&r.montgomeryOne, &N)
+	// 2^(64*words) is coprime to N since N is odd, so this can't
+	// fail.
+	montgomeryInverse, invErr := InvMod(&r.montgomeryOne, &N)
+	if invErr != nil {
+		return nil, invErr
+	}
+	r.montgomeryInverse.Set(montgomeryInverse)
+
+	source := C.CString(openCLMulSource)
+	defer C.free(unsafe.Pointer(source))
+	options := C.CString(fmt.Sprintf("-DWORDS=%d", r.words))
+	defer C.free(unsafe.Pointer(options))
+	var err C.cl_int
+	r.ring = C.aks_opencl_ring_new(
+		source, options, (*C.cl_ulong)(unsafe.Pointer(&n[0])),
+		C.long(r.words), &err)
+	if r.ring == nil {
+		return nil, fmt.Errorf("%w: %v", ErrBackendUnavailable,
+			openCLError("aks_opencl_ring_new", err))
+	}
+	runtime.SetFinalizer(r, func(r *openCLPolyRing) {
+		C.aks_opencl_ring_free(r.ring)
+	})
+	return r, nil
+}
+
+// Sets words to x, least significant first. x must fit.
+func intToUint64s(x *big.Int, words []uint64) {
+	var t, w big.Int
+	t.Set(x)
+	mask := new(big.Int).SetUint64(^uint64(0))
+	for i := range words {
+		words[i] = w.And(&t, mask).Uint64()
+		t.Rsh(&t, 64)
+	}
+}
+
+// Returns the number with the given words, least significant first.
+func uint64sToInt(words []uint64) *big.Int {
+	var x, w big.Int
+	for i := len(words) - 1; i >= 0; i-- {
+		x.Lsh(&x, 64)
+		x.Or(&x, w.SetUint64(words[i]))
+	}
+	return &x
+}
+
+// polyRing implementation. Panics if the device runs out of memory.
+func (r *openCLPolyRing) newPoly() poly {
+	var err C.cl_int
+	buf := C.aks_opencl_buffer_new(
+		r.ring, C.long(int(r.R.Int64())*r.words), &err)
+	if err != C.CL_SUCCESS {
+		panic(openCLError("aks_opencl_buffer_new", err))
+	}
+	p := &openCLPoly{ring: r, buf: buf}
+	runtime.SetFinalizer(p, (*openCLPoly).free)
+	p.write(0, make([]uint64, int(r.R.Int64())*r.words))
+	return p
+}
+
+// polyRing implementation. These are bytes of device memory.
+func (r *openCLPolyRing) getPolyBytes() int64 {
+	return 8 * r.R.Int64() * int64(r.words)
+}
+
+// An openCLPoly represents a polynomial mod (N, X^R - 1) whose
+// coefficients are kept in the memory of an OpenCL device (usually a
+// GPU), in Montgomery form, and multiplied there. Only Set,
+// setCoefficient, and the methods that look at coefficients transfer
+// them to or from the device, so that Pow does all of its squarings
+// on the device.
+//
+// Multiplications are only enqueued, so Mul and powCancelable may
+// return (and call onMul) before the device finishes them; anything
+// that reads coefficients waits for them.
+type openCLPoly struct {
+	ring *openCLPolyRing
+	// R coefficients of ring.words words each. Allocated by
+	// aks_opencl_buffer_new and released by a finalizer.
+	buf C.cl_mem
+}
+
+// Frees p's buffer. Used as p's finalizer.
+func (p *openCLPoly) free() {
+	C.clReleaseMemObject(p.buf)
+}
+
+// Calls panicModulusMismatch if p and q aren't mod the same N and R.
+func (p *openCLPoly) checkModulus(op string, q *openCLPoly) {
+	if q.ring != p.ring && (q.ring.N.Cmp(&p.ring.N) != 0 ||
+		q.ring.R.Cmp(&p.ring.R) != 0) {
+		panicModulusMismatch(
+			op, &p.ring.N, int(p.ring.R.Int64()),
+			&q.ring.N, int(q.ring.R.Int64()))
+	}
+}
+
+// Copies words to p's buffer, starting at the given word offset.
+func (p *openCLPoly) write(offset int, words []uint64) {
+	err := C.aks_opencl_buffer_write(
+		p.ring.ring, p.buf, C.long(offset), C.long(len(words)),
+		(*C.cl_ulong)(unsafe.Pointer(&words[0])))
+	if err != C.CL_SUCCESS {
+		panic(openCLError("aks_opencl_buffer_write", err))
+	}
+}
+
+// Copies p's buffer, starting at the given word offset, to words.
+func (p *openCLPoly) read(offset int, words []uint64) {
+	err := C.aks_opencl_buffer_read(
+		p.ring.ring, p.buf, C.long(offset), C.long(len(words)),
+		(*C.cl_ulong)(unsafe.Pointer(&words[0])))
+	if err != C.CL_SUCCESS {
+		panic(openCLError("aks_opencl_buffer_read", err))
+	}
+}
+
+// Returns the words of c, which must be less than N, in Montgomery
+// form.
+func (r *openCLPolyRing) toMontgomery(c *big.Int) []uint64 {
+	var x big.Int
+	x.Mul(c, &r.montgomeryOne)
+	x.Mod(&x, &r.N)
+	words := make([]uint64, r.words)
+	intToUint64s(&x, words)
+	return words
+}
+
+// Returns the number whose Montgomery form has the given words.
+func (r *openCLPolyRing) fromMontgomery(words []uint64) *big.Int {
+	x := uint64sToInt(words)
+	x.Mul(x, &r.montgomeryInverse)
+	return x.Mod(x, &r.N)
+}
+
+// poly implementation.
+func (p *openCLPoly) Set(a, k, N big.Int) {
+	r := p.ring
+	rInt := int(r.R.Int64())
+	var c0, ci, kModR big.Int
+	c0.Mod(&a, &N)
+	kModR.Mod(&k, &r.R)
+	i := int(kModR.Int64())
+	ci.Mod(big.NewInt(1), &N)
+	if i == 0 {
+		ci.Add(&ci, &c0)
+		ci.Mod(&ci, &N)
+	}
+	words := make([]uint64, rInt*r.words)
+	copy(words, r.toMontgomery(&c0))
+	copy(words[i*r.words:], r.toMontgomery(&ci))
+	p.write(0, words)
+}
+
+// poly implementation.
+func (p *openCLPoly) copyFrom(q poly) {
+	qPoly := q.(*openCLPoly)
+	p.checkModulus("copy", qPoly)
+	err := C.aks_opencl_buffer_copy(
+		p.ring.ring, p.buf, qPoly.buf,
+		C.long(int(p.ring.R.Int64())*p.ring.words))
+	if err != C.CL_SUCCESS {
+		panic(openCLError("aks_opencl_buffer_copy", err))
+	}
+}
+
+// poly implementation.
+func (p *openCLPoly) clone() poly {
+	c := p.ring.newPoly()
+	c.copyFrom(p)
+	return c
+}
+
+// Returns all of p's words.
+func (p *openCLPoly) readAll() []uint64 {
+	words := make([]uint64, int(p.ring.R.Int64())*p.ring.words)
+	p.read(0, words)
+	return words
+}
+
+// poly implementation. Since each coefficient has a unique
+// Montgomery form, this compares the words directly.
+func (p *openCLPoly) Eq(q poly) bool {
+	qPoly := q.(*openCLPoly)
+	p.checkModulus("compare", qPoly)
+	pWords := p.readAll()
+	qWords := qPoly.readAll()
+	for i := range pWords {
+		if pWords[i] != qWords[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// Enqueues the product of a and b into out, which must not alias
+// either.
+func (r *openCLPolyRing) mul(out, a, b *openCLPoly) {
+	r.mulLock.Lock()
+	defer r.mulLock.Unlock()
+	err := C.aks_opencl_mul(
+		r.ring, out.buf, a.buf, b.buf, C.cl_ulong(r.nInv),
+		C.cl_int(r.R.Int64()))
+	if err != C.CL_SUCCESS {
+		panic(openCLError("aks_opencl_mul", err))
+	}
+}
+
+// poly implementation.
+func (p *openCLPoly) Mul(q poly, N big.Int, tmp poly) {
+	qPoly := q.(*openCLPoly)
+	p.checkModulus("multiply", qPoly)
+	tmpPoly := tmp.(*openCLPoly)
+	p.ring.mul(tmpPoly, p, qPoly)
+	p.buf, tmpPoly.buf = tmpPoly.buf, p.buf
+}
+
+// poly implementation.
+func (p *openCLPoly) Pow(N big.Int, tmp1, tmp2 poly) {
+	p.powCancelable(N, tmp1, tmp2, nil, nil)
+}
+
+// poly implementation. Waits for the device to finish before
+// returning, even if cancelled.
+func (p *openCLPoly) powCancelable(
+	N big.Int, tmp1Poly, tmp2Poly poly,
+	done <-chan struct{}, onMul func()) bool {
+	tmp1 := tmp1Poly.(*openCLPoly)
+	tmp2 := tmp2Poly.(*openCLPoly)
+	defer C.aks_opencl_finish(p.ring.ring)
+	tmp1.copyFrom(p)
+
+	for i := N.BitLen() - 2; i >= 0; i-- {
+		select {
+		case <-done:
+			return false
+		default:
+		}
+		tmp1.Mul(tmp1, N, tmp2)
+		if onMul != nil {
+			onMul()
+		}
+		if N.Bit(i) != 0 {
+			tmp1.Mul(p, N, tmp2)
+			if onMul != nil {
+				onMul()
+			}
+		}
+	}
+
+	p.buf, tmp1.buf = tmp1.buf, p.buf
+	return true
+}
+
+// poly implementation.
+func (p *openCLPoly) coefficient(i int) *big.Int {
+	words := make([]uint64, p.ring.words)
+	p.read(i*p.ring.words, words)
+	return p.ring.fromMontgomery(words)
+}
+
+// poly implementation.
+func (p *openCLPoly) setCoefficient(i int, c *big.Int) {
+	p.write(i*p.ring.words, p.ring.toMontgomery(c))
+}
+
+// poly implementation.
+func (p *openCLPoly) getPhiBytes() int64 {
+	return p.ring.getPolyBytes()
+}
+
+// fmt.Formatter implementation.
+func (p *openCLPoly) Format(f fmt.State, c rune) {
+	words := p.readAll()
+	k := p.ring.words
+	coefficients := make([]big.Int, len(words)/k)
+	coefficientCount := 0
+	for i := range coefficients {
+		coefficients[i] = *p.ring.fromMontgomery(words[i*k : (i+1)*k])
+		if coefficients[i].Sign() != 0 {
+			coefficientCount = i + 1
+		}
+	}
+	formatPoly(f, coefficientCount, func(i int) big.Int {
+		return coefficients[i]
+	})
+}
+
+var _ poly = (*openCLPoly)(nil)
+
+// gob.GobEncoder implementation.
+func (p *openCLPoly) GobEncode() ([]byte, error) {
+	return marshalPoly(p, p.ring.N, p.ring.R, gobMarshal)
+}
+
+// gob.GobDecoder implementation.
+func (p *openCLPoly) GobDecode(data []byte) error {
+	return p.unmarshal(data, gobUnmarshal)
+}
+
+// json.Marshaler implementation.
+func (p *openCLPoly) MarshalJSON() ([]byte, error) {
+	return marshalPoly(p, p.ring.N, p.ring.R, json.Marshal)
+}
+
+// json.Unmarshaler implementation.
+func (p *openCLPoly) UnmarshalJSON(data []byte) error {
+	return p.unmarshal(data, json.Unmarshal)
+}
+
+// Sets p to data, as marshaled by GobEncode or MarshalJSON (possibly
+// by another backend), with unmarshal.
+func (p *openCLPoly) unmarshal(
+	data []byte, unmarshal func([]byte, interface{}) error) error {
+	q, err := unmarshalPoly(data, unmarshal, OpenCLPolyBackend)
+	if err != nil {
+		return err
+	}
+	// Swap p and q, so that q's finalizer frees p's old buffer,
+	// as in mpzPoly.unmarshal.
+	qp := q.(*openCLPoly)
+	*p, *qp = *qp, *p
+	if qp.buf == nil {
+		runtime.SetFinalizer(qp, nil)
+	}
+	runtime.SetFinalizer(p, nil)
+	runtime.SetFinalizer(p, (*openCLPoly).free)
+	return nil
+}
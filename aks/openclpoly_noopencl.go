@@ -0,0 +1,19 @@
+//go:build !opencl || !cgo
+
+package aks
+
+import "fmt"
+import "math/big"
+
+// Returns false, since OpenCLPolyBackend isn't in this build.
+func isOpenCLPolyBackendAvailable() bool {
+	return false
+}
+
+// Returns an error, since OpenCLPolyBackend needs the package to be
+// built with cgo and the opencl build tag.
+func newOpenCLPolyRing(N, R big.Int) (polyRing, error) {
+	return nil, fmt.Errorf(
+		"%w: the %v backend requires building with cgo and -tags opencl",
+		ErrBackendUnavailable, OpenCLPolyBackend)
+}
@@ -0,0 +1,75 @@
+//go:build opencl && cgo
+
+package aks
+
+import "context"
+import "fmt"
+import "math/big"
+import "testing"
+
+// Makes an openCLPolyRing, skipping t if there is no OpenCL device.
+func newOpenCLPolyRingOrSkip(t *testing.T, N, R big.Int) *openCLPolyRing {
+	ring, err := newOpenCLPolyRing(N, R)
+	if err != nil {
+		t.Skip(err)
+	}
+	return ring
+}
+
+// openCLPolyRing should pass checkPolyRing.
+func TestOpenCLPolyRing(t *testing.T) {
+	checkPolyRing(t, func(N, R big.Int) polyRing {
+		return newOpenCLPolyRingOrSkip(t, N, R)
+	})
+}
+
+// openCLPolyRing should pass checkPolyEncoding.
+func TestOpenCLPolyEncoding(t *testing.T) {
+	checkPolyEncoding(t, func(N, R big.Int) polyRing {
+		return newOpenCLPolyRingOrSkip(t, N, R)
+	}, func() poly { return &openCLPoly{} })
+}
+
+// Raising openCLPolys to powers should give the same results as with
+// bigIntPolys, including for N with more than one word.
+func TestOpenCLPolyPowMatchesBigIntPoly(t *testing.T) {
+	var large big.Int
+	large.SetString("1000000000000000000000000000057", 10)
+	for _, N := range []big.Int{*big.NewInt(4294967291), large} {
+		R := *big.NewInt(53)
+		ring := newOpenCLPolyRingOrSkip(t, N, R)
+		p := ring.newPoly()
+		expected := newBigIntPoly(N, R)
+		for i := 0; i < 53; i += 5 {
+			c := big.NewInt(int64(i*i + 3))
+			p.setCoefficient(i, c)
+			expected.setCoefficient(i, c)
+		}
+		p.Pow(N, ring.newPoly(), ring.newPoly())
+		expected.Pow(N, newBigIntPoly(N, R), newBigIntPoly(N, R))
+		if fmt.Sprint(p) != fmt.Sprint(expected) {
+			t.Errorf("%v %v %v", &N, p, expected)
+		}
+	}
+}
+
+// RunAKS should reach the same verdicts with OpenCLPolyBackend as
+// with the pure-Go backends.
+func TestRunAKSOpenCL(t *testing.T) {
+	newOpenCLPolyRingOrSkip(t, *big.NewInt(1000003), *big.NewInt(431))
+	for _, n := range []int64{1000003, 2993374621} {
+		result, err := RunAKS(
+			context.Background(), big.NewInt(n),
+			&AKSOptions{Backend: OpenCLPolyBackend})
+		if err != nil {
+			t.Fatal(n, err)
+		}
+		expected := Prime
+		if n == 2993374621 {
+			expected = Composite
+		}
+		if result.Verdict != expected {
+			t.Error(n, result.Verdict)
+		}
+	}
+}
@@ -0,0 +1,274 @@
+package aks
+
+import "fmt"
+import "io/ioutil"
+import "log"
+import "math/big"
+import "runtime"
+import "time"
+
+// A PolyBackend selects the polynomial implementation used to test
+// AKS witnesses.
+type PolyBackend int
+
+const (
+	// Picks the backend that's fastest for n, as measured by the
+	// Benchmark*PolyAKSWitness* benchmarks: WordPolyBackend if n
+	// < 2^37, and NTTPolyBackend otherwise. The cgo backends are
+	// never picked: MPZPolyBackend is much slower than
+	// NTTPolyBackend, and the others are only in some builds.
+	AutoPolyBackend PolyBackend = iota
+	// Stores all coefficients packed into a single big.Int; see
+	// bigIntPoly. Works for any n.
+	BigIntPolyBackend
+	// Stores each coefficient in a machine word; see wordPoly.
+	// Only works for n < 2^64.
+	WordPolyBackend
+	// Stores each coefficient mod several word-sized primes and
+	// multiplies with number-theoretic transforms; see nttPoly.
+	// Works for any n.
+	NTTPolyBackend
+	// Stores each coefficient in a GMP mpz_t and multiplies them
+	// one by one; see mpzPoly. Works for any n, but only if the
+	// package is built with the gmp build tag (which needs cgo
+	// and libgmp), and is much slower than the other backends.
+	MPZPolyBackend
+	// Raises polynomials to powers with FLINT's fmpz_mod_poly;
+	// see flintPoly. Works for any n, but only if the package is
+	// built with the flint build tag (which needs cgo and FLINT
+	// 3).
+	FLINTPolyBackend
+	// Keeps coefficients in the memory of a GPU (or another
+	// OpenCL device) and raises polynomials to powers there; see
+	// openCLPoly. Only works for odd n, and only if the package is
+	// built with the opencl build tag (which needs cgo and an
+	// OpenCL implementation).
+	OpenCLPolyBackend
+)
+
+// fmt.Stringer implementation.
+func (b PolyBackend) String() string {
+	switch b {
+	case AutoPolyBackend:
+		return "auto"
+	case BigIntPolyBackend:
+		return "bigint"
+	case WordPolyBackend:
+		return "word"
+	case NTTPolyBackend:
+		return "ntt"
+	case MPZPolyBackend:
+		return "mpz"
+	case FLINTPolyBackend:
+		return "flint"
+	case OpenCLPolyBackend:
+		return "opencl"
+	}
+	return fmt.Sprintf("PolyBackend(%d)", int(b))
+}
+
+// Returns whether b can be used in this build. The cgo-based backends
+// are only available if the package is built with cgo and their
+// build tags (and OpenCLPolyBackend only if there is an OpenCL
+// device); the others are always available.
+func (b PolyBackend) IsAvailable() bool {
+	switch b {
+	case AutoPolyBackend, BigIntPolyBackend, WordPolyBackend,
+		NTTPolyBackend:
+		return true
+	case MPZPolyBackend:
+		return haveMPZPolyBackend
+	case FLINTPolyBackend:
+		return haveFLINTPolyBackend
+	case OpenCLPolyBackend:
+		return isOpenCLPolyBackendAvailable()
+	}
+	return false
+}
+
+// Returns the backends that are available in this build, in order.
+func AvailablePolyBackends() []PolyBackend {
+	var available []PolyBackend
+	for b := AutoPolyBackend; b <= OpenCLPolyBackend; b++ {
+		if b.IsAvailable() {
+			available = append(available, b)
+		}
+	}
+	return available
+}
+
+// A Verbosity controls how much RunAKS logs to AKSOptions.Logger.
+// Higher verbosities include the messages of lower ones. Errors that
+// don't stop the test (e.g., failing to save a checkpoint) are
+// logged at every verbosity.
+type Verbosity int
+
+const (
+	// Only errors are logged.
+	VerbositySilent Verbosity = iota - 2
+	// Phase changes are logged.
+	VerbosityNormal
+	// Each AKS witness test is logged. This is the zero value,
+	// since RunAKS always logged this much before verbosities
+	// were added.
+	VerbosityVerbose
+	// Each polynomial multiplication is logged.
+	VerbosityDebug
+)
+
+// Configures a primality test run by RunAKS. The zero value (or a
+// nil *AKSOptions) is valid and uses the defaults described below.
+type AKSOptions struct {
+	// The number of goroutines to use when testing AKS
+	// witnesses. Defaults to runtime.GOMAXPROCS(0) if not
+	// positive.
+	Jobs int
+	// The range [Start, End) of numbers to test as AKS
+	// witnesses. Start defaults to 1 and End defaults to M if
+	// they are nil or not positive (or to n - 1 and n with
+	// AKSVariantConjecture).
+	Start, End *big.Int
+	// If non-nil, only the numbers in [Start, End) congruent to
+	// Offset modulo Stride are tested, so that k machines can
+	// split up the search by using Stride = k and Offset = 0,
+	// ..., k-1. Stride must be positive, and defaults to 1 if
+	// nil, and Offset defaults to 0 if nil.
+	Stride, Offset *big.Int
+	// The order in which the numbers in [Start, End) are tested.
+	// Defaults to WitnessOrderAscending.
+	WitnessOrder WitnessOrder
+	// Determines the order if WitnessOrder is
+	// WitnessOrderRandom; the same seed always gives the same
+	// order for the same numbers.
+	WitnessOrderSeed int64
+	// If true, only the primes in [Start, End) are tested as AKS
+	// witnesses (in WitnessOrder), which in practice finds
+	// witnesses of composites with a fraction of the tests, since
+	// composite values of a rarely turn up witnesses when none of
+	// the primes do. However, the AKS theorem needs every number
+	// in [1, M) to be tested, so if no witness is found the
+	// verdict is Undetermined rather than Prime. Resuming from
+	// the result's Completed ranges without this option tests
+	// just the remaining numbers to finish the proof. End must
+	// fit into a uint64.
+	PrimeWitnessesOnly bool
+	// If set, r and M are computed with CalculateSharpAKSModulus
+	// and CalculateSharpAKSUpperBound, which use lg(n) instead of
+	// rounding it up to the bit length of n. This usually gives
+	// a smaller r, which makes every polynomial operation of the
+	// witness search cheaper. Resume needs a checkpoint from a
+	// run with the same setting, since its r must match.
+	SharpBounds bool
+	// The version of the AKS theorem used to pick r and M.
+	// Resume needs a checkpoint from a run with the same variant.
+	Variant AKSVariant
+	// Trial division is done for factors less than
+	// max(TrialDivisionBound, M). (The AKS witness search is only
+	// valid for n with no factors less than M, so smaller values
+	// have no effect.) Defaults to M if nil.
+	TrialDivisionBound *big.Int
+	// If false, n is checked with BailliePSW after trial division
+	// (and ECM, if requested, since that gives a factor), and is
+	// reported Composite (with MethodBPSW) if it fails,
+	// which takes microseconds instead of however long the AKS
+	// witness search would take to find a witness. Primes always
+	// pass, so this never changes the verdict, only how a
+	// composite is found to be one.
+	SkipBPSW bool
+	// If non-nil, and n survives trial division but isn't prime
+	// according to big.Int.ProbablyPrime, ECM is run on n with
+	// these options before the AKS witness search, which is much
+	// faster at finding a factor if n has one of up to 20 digits
+	// or so.
+	ECM *ECMOptions
+	// Where progress is logged. Defaults to discarding all log
+	// output if nil.
+	Logger *log.Logger
+	// How much progress is logged.
+	Verbosity Verbosity
+	// The polynomial implementation to use. Defaults to
+	// AutoPolyBackend.
+	Backend PolyBackend
+	// If non-empty, the progress of the AKS witness search is
+	// periodically saved to this path as a Checkpoint, and also
+	// when the search finishes or is cancelled.
+	CheckpointPath string
+	// How often to save checkpoints. Defaults to
+	// DefaultCheckpointInterval if not positive.
+	CheckpointInterval time.Duration
+	// If non-nil, the numbers in Resume.Completed are not tested
+	// again. Resume must be a checkpoint for the same n.
+	Resume *Checkpoint
+	// If non-nil, notified of the progress of the test.
+	Observer ProgressObserver
+	// If true, PrimalityResult.Certificate is filled in when the
+	// run completes.
+	GenerateCertificate bool
+	// If non-nil, (X + a)^n is computed with this addition chain,
+	// whose exponent must be n, instead of by binary
+	// exponentiation. This is useful when testing many numbers of
+	// a special form, for which short chains are known.
+	AdditionChain *AdditionChain
+	// If AdditionChain is nil and this is greater than 1, (X +
+	// a)^n is computed with WindowAdditionChain(n, PowWindowBits)
+	// instead of by binary exponentiation, which does fewer
+	// multiplications but keeps 2^(PowWindowBits - 1) more
+	// polynomials around per job.
+	PowWindowBits int
+	// If non-nil, called from the goroutines testing AKS
+	// witnesses after each polynomial multiplication, so that
+	// long tests can give other work a chance to run. This is
+	// needed under js/wasm, where goroutines aren't preempted and
+	// the browser's event loop only runs once every goroutine is
+	// blocked; there, Yield can just sleep for a millisecond.
+	// Yield must be safe to call from multiple goroutines at once
+	// if Jobs is greater than 1.
+	Yield func()
+}
+
+// The default value for AKSOptions.CheckpointInterval.
+const DefaultCheckpointInterval = 5 * time.Minute
+
+// Returns a copy of opts with all defaults filled in except for
+// those that depend on M.
+func (opts *AKSOptions) withDefaults() AKSOptions {
+	var o AKSOptions
+	if opts != nil {
+		o = *opts
+	}
+	if o.Jobs <= 0 {
+		o.Jobs = runtime.GOMAXPROCS(0)
+	}
+	if o.CheckpointInterval <= 0 {
+		o.CheckpointInterval = DefaultCheckpointInterval
+	}
+	if o.Logger == nil {
+		o.Logger = log.New(ioutil.Discard, "", 0)
+	}
+	return o
+}
+
+// Returns the addition chain that o says to raise polynomials to the
+// nth power with, or nil if binary exponentiation should be used.
+func (o *AKSOptions) getAdditionChain(n *big.Int) (*AdditionChain, error) {
+	if o.AdditionChain != nil {
+		e, err := o.AdditionChain.Exponent()
+		if err != nil {
+			return nil, err
+		}
+		if e.Cmp(n) != 0 {
+			return nil, fmt.Errorf(
+				"%w: addition chain computes %v, not n = %v",
+				ErrBadInput, e, n)
+		}
+		return o.AdditionChain, nil
+	}
+	if o.PowWindowBits > 1 {
+		chain, err := WindowAdditionChain(n, o.PowWindowBits)
+		if err != nil {
+			return nil, err
+		}
+		return &chain, nil
+	}
+	return nil, nil
+}
@@ -0,0 +1,209 @@
+package aks
+
+import "fmt"
+import "iter"
+import "math/big"
+import "math/bits"
+
+// A WitnessOrder is the order in which RunAKS tests the numbers in
+// its AKS witness range. Every order tests all of them (unless a
+// witness turns up first), so the verdict doesn't depend on the
+// order, but how soon a witness of a composite turns up may.
+type WitnessOrder int
+
+const (
+	// From smallest to largest. This is the zero value.
+	WitnessOrderAscending WitnessOrder = iota
+	// From largest to smallest.
+	WitnessOrderDescending
+	// In a pseudorandom order determined by
+	// AKSOptions.WitnessOrderSeed.
+	WitnessOrderRandom
+)
+
+// fmt.Stringer implementation.
+func (o WitnessOrder) String() string {
+	switch o {
+	case WitnessOrderAscending:
+		return "ascending"
+	case WitnessOrderDescending:
+		return "descending"
+	case WitnessOrderRandom:
+		return "random"
+	}
+	return fmt.Sprintf("WitnessOrder(%d)", int(o))
+}
+
+// Returns the members of s.prog in [s.start, s.end) that aren't in
+// skip (which may be nil), in the order s.order, as new big.Ints. If
+// s.primesOnly is set, the members are instead those of primes (as
+// returned by getPrimeCandidates) that aren't in skip. Returns an
+// error wrapping ErrBadInput if s.order is unknown, or if it isn't
+// WitnessOrderAscending and there are too many numbers to index with
+// a uint64.
+func (s *witnessSearch) candidates(
+	skip *rangeSet, primes []uint64) (iter.Seq[*big.Int], error) {
+	if s.order == WitnessOrderAscending && !s.primesOnly {
+		return s.ascendingCandidates(skip), nil
+	}
+
+	var n uint64
+	var at func(i uint64) *big.Int
+	if s.primesOnly {
+		n = uint64(len(primes))
+		at = func(i uint64) *big.Int {
+			return new(big.Int).SetUint64(primes[i])
+		}
+	} else {
+		count := s.prog.countIn(s.start, s.end)
+		if !count.IsUint64() {
+			return nil, fmt.Errorf(
+				"%w: %v numbers are too many to test in %v "+
+					"order", ErrBadInput, count, s.order)
+		}
+		n = count.Uint64()
+		first := s.prog.alignUp(s.start)
+		at = func(i uint64) *big.Int {
+			a := new(big.Int).SetUint64(i)
+			a.Mul(a, s.prog.stride)
+			return a.Add(a, first)
+		}
+	}
+	var permute func(i uint64) uint64
+	switch s.order {
+	case WitnessOrderAscending:
+		permute = func(i uint64) uint64 {
+			return i
+		}
+	case WitnessOrderDescending:
+		permute = func(i uint64) uint64 {
+			return n - 1 - i
+		}
+	case WitnessOrderRandom:
+		permute = newIndexPermutation(n, s.seed).at
+	default:
+		return nil, fmt.Errorf(
+			"%w: unknown witness order %v", ErrBadInput, s.order)
+	}
+
+	return func(yield func(*big.Int) bool) {
+		for i := uint64(0); i < n; i++ {
+			a := at(permute(i))
+			if skip != nil && skip.nextAbsent(a).Cmp(a) != 0 {
+				continue
+			}
+			if !yield(a) {
+				return
+			}
+		}
+	}, nil
+}
+
+// Like candidates, but always in ascending order, which lets skipped
+// ranges be jumped over instead of checked number by number.
+func (s *witnessSearch) ascendingCandidates(skip *rangeSet) iter.Seq[*big.Int] {
+	return func(yield func(*big.Int) bool) {
+		i := s.prog.alignUp(s.start)
+		for {
+			if skip != nil {
+				// Skipping may leave i outside of s.prog, so
+				// repeat until it doesn't.
+				for {
+					next := s.prog.alignUp(skip.nextAbsent(i))
+					if next.Cmp(i) == 0 {
+						break
+					}
+					i = next
+				}
+			}
+			if i.Cmp(s.end) >= 0 {
+				return
+			}
+			if !yield(new(big.Int).Set(i)) {
+				return
+			}
+			i.Add(i, s.prog.stride)
+		}
+	}
+}
+
+// Returns the primes in s.prog in [s.start, s.end), or an error
+// wrapping ErrBadInput if s.end doesn't fit into a uint64.
+func (s *witnessSearch) getPrimeCandidates() ([]uint64, error) {
+	if !s.end.IsUint64() {
+		return nil, fmt.Errorf(
+			"%w: end = %v is too large to test only primes",
+			ErrBadInput, s.end)
+	}
+	var start uint64
+	if s.start.Sign() > 0 {
+		start = s.start.Uint64()
+	}
+	var primes []uint64
+	var a big.Int
+	for p := range Primes(start, s.end.Uint64()) {
+		if !s.prog.isAll() &&
+			s.prog.alignUp(a.SetUint64(p)).Cmp(&a) != 0 {
+			continue
+		}
+		primes = append(primes, p)
+	}
+	return primes, nil
+}
+
+// A pseudorandom permutation of [0, n), made by cycle-walking a
+// balanced Feistel network on the smallest even number of bits that
+// can hold n - 1. Unlike a shuffled slice, it takes constant memory
+// no matter how large n is.
+type indexPermutation struct {
+	n        uint64
+	halfBits uint
+	seed     uint64
+}
+
+// The number of Feistel rounds an indexPermutation does.
+const indexPermutationRounds = 4
+
+// Makes the permutation of [0, n) for the given seed.
+func newIndexPermutation(n uint64, seed int64) indexPermutation {
+	halfBits := uint(bits.Len64(n-1)+1) / 2
+	if halfBits == 0 {
+		halfBits = 1
+	}
+	return indexPermutation{n, halfBits, uint64(seed)}
+}
+
+// Returns where p sends i, which must be less than p.n.
+func (p indexPermutation) at(i uint64) uint64 {
+	// The Feistel network permutes [0, 2^(2*p.halfBits)), which
+	// is less than 4*p.n, so this loops fewer than 4 times on
+	// average.
+	for {
+		i = p.feistel(i)
+		if i < p.n {
+			return i
+		}
+	}
+}
+
+// Applies p's Feistel network to x, which must be less than
+// 2^(2*p.halfBits).
+func (p indexPermutation) feistel(x uint64) uint64 {
+	mask := uint64(1)<<p.halfBits - 1
+	l, r := x>>p.halfBits, x&mask
+	for round := uint64(0); round < indexPermutationRounds; round++ {
+		f := mix64(p.seed + mix64(round<<p.halfBits|r))
+		l, r = r, l^(f&mask)
+	}
+	return l<<p.halfBits | r
+}
+
+// Returns a well-mixed hash of x (the SplitMix64 finalizer).
+func mix64(x uint64) uint64 {
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
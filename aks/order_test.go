@@ -0,0 +1,231 @@
+package aks
+
+import "context"
+import "errors"
+import "fmt"
+import "math/big"
+import "testing"
+
+// Returns the candidates of s in order as strings.
+func getCandidateStrings(
+	s *witnessSearch, skip *rangeSet, t *testing.T) []string {
+	var primes []uint64
+	if s.primesOnly {
+		var err error
+		primes, err = s.getPrimeCandidates()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	candidates, err := s.candidates(skip, primes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var strs []string
+	for a := range candidates {
+		strs = append(strs, a.String())
+	}
+	return strs
+}
+
+// Each order should give the members of the progression in the
+// range that aren't skipped, in the right order.
+func TestWitnessSearchCandidates(t *testing.T) {
+	prog, err := newProgression(big.NewInt(3), big.NewInt(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &witnessSearch{
+		start: big.NewInt(2),
+		end:   big.NewInt(20),
+		prog:  prog,
+	}
+	skip := &rangeSet{}
+	skip.addRange(big.NewInt(9), big.NewInt(14))
+
+	tests := []struct {
+		order    WitnessOrder
+		expected string
+	}{
+		{WitnessOrderAscending, "[4 7 16 19]"},
+		{WitnessOrderDescending, "[19 16 7 4]"},
+	}
+	for _, test := range tests {
+		s.order = test.order
+		strs := getCandidateStrings(s, skip, t)
+		if str := fmt.Sprint(strs); str != test.expected {
+			t.Error(test.order, str)
+		}
+	}
+
+	// A random order should have the same members, in an order
+	// that depends only on the seed.
+	s.order = WitnessOrderRandom
+	seen := make(map[string]bool)
+	for _, a := range getCandidateStrings(s, skip, t) {
+		seen[a] = true
+	}
+	if len(seen) != 4 || !seen["4"] || !seen["7"] ||
+		!seen["16"] || !seen["19"] {
+		t.Error(seen)
+	}
+	s.seed = 5
+	s.start = big.NewInt(1)
+	s.end = big.NewInt(1000)
+	strs1 := fmt.Sprint(getCandidateStrings(s, nil, t))
+	strs2 := fmt.Sprint(getCandidateStrings(s, nil, t))
+	s.seed = 6
+	strs3 := fmt.Sprint(getCandidateStrings(s, nil, t))
+	if strs1 != strs2 || strs1 == strs3 {
+		t.Error(strs1, strs2, strs3)
+	}
+
+	s.order = WitnessOrder(3)
+	if _, err := s.candidates(nil, nil); !errors.Is(err, ErrBadInput) {
+		t.Error(err)
+	}
+	s.order = WitnessOrderDescending
+	s.end = new(big.Int).Lsh(big.NewInt(1), 70)
+	if _, err := s.candidates(nil, nil); !errors.Is(err, ErrBadInput) {
+		t.Error(err)
+	}
+}
+
+// An indexPermutation should be a permutation that isn't the
+// identity.
+func TestIndexPermutation(t *testing.T) {
+	for _, n := range []uint64{1, 2, 3, 4, 5, 100, 1000, 1 << 12} {
+		p := newIndexPermutation(n, 12345)
+		seen := make([]bool, n)
+		fixed := uint64(0)
+		for i := uint64(0); i < n; i++ {
+			j := p.at(i)
+			if j >= n || seen[j] {
+				t.Fatal(n, i, j)
+			}
+			seen[j] = true
+			if i == j {
+				fixed++
+			}
+		}
+		if n >= 100 && fixed > n/10 {
+			t.Error(n, fixed)
+		}
+	}
+
+	p := newIndexPermutation(^uint64(0), 1)
+	if i := p.at(^uint64(0) - 1); i >= ^uint64(0) {
+		t.Error(i)
+	}
+}
+
+// Every order should give the same verdict, and should still test
+// every number when there is no witness.
+func TestRunAKSWitnessOrder(t *testing.T) {
+	for _, order := range []WitnessOrder{
+		WitnessOrderAscending, WitnessOrderDescending,
+		WitnessOrderRandom,
+	} {
+		result, err := RunAKS(
+			context.Background(), big.NewInt(1000003),
+			&AKSOptions{WitnessOrder: order, WitnessOrderSeed: 1})
+		if err != nil {
+			t.Fatal(order, err)
+		}
+		if result.Verdict != Prime || len(result.Completed) != 1 ||
+			result.Completed[0].Start.Cmp(result.Start) != 0 ||
+			result.Completed[0].End.Cmp(result.End) != 0 {
+			t.Error(order, result.Verdict, result.Completed)
+		}
+
+		n := big.NewInt(2993374621)
+		result, err = RunAKS(context.Background(), n, &AKSOptions{
+			WitnessOrder: order,
+			Stride:       big.NewInt(2),
+			Offset:       big.NewInt(1),
+			SkipBPSW:     true,
+		})
+		if err != nil {
+			t.Fatal(order, err)
+		}
+		if result.Verdict != Composite || result.Witness == nil ||
+			result.Witness.Bit(0) != 1 {
+			t.Error(order, result.Verdict, result.Witness)
+		}
+	}
+}
+
+// Testing only primes should give just the primes in the
+// progression, in each order.
+func TestWitnessSearchPrimeCandidates(t *testing.T) {
+	prog, err := newProgression(big.NewInt(2), big.NewInt(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &witnessSearch{
+		start:      big.NewInt(1),
+		end:        big.NewInt(30),
+		prog:       prog,
+		primesOnly: true,
+	}
+	skip := &rangeSet{}
+	skip.addRange(big.NewInt(10), big.NewInt(14))
+	tests := []struct {
+		order    WitnessOrder
+		expected string
+	}{
+		{WitnessOrderAscending, "[3 5 7 17 19 23 29]"},
+		{WitnessOrderDescending, "[29 23 19 17 7 5 3]"},
+	}
+	for _, test := range tests {
+		s.order = test.order
+		strs := getCandidateStrings(s, skip, t)
+		if str := fmt.Sprint(strs); str != test.expected {
+			t.Error(test.order, str)
+		}
+	}
+
+	s.end = new(big.Int).Lsh(big.NewInt(1), 64)
+	if _, err := s.getPrimeCandidates(); !errors.Is(err, ErrBadInput) {
+		t.Error(err)
+	}
+}
+
+// Testing only prime witnesses should find witnesses of composites
+// but leave primes undetermined until a full search is resumed.
+func TestRunAKSPrimeWitnessesOnly(t *testing.T) {
+	n := big.NewInt(1000003)
+	result, err := RunAKS(context.Background(), n, &AKSOptions{
+		PrimeWitnessesOnly: true,
+		WitnessOrder:       WitnessOrderRandom,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Verdict != Undetermined || len(result.Completed) == 0 ||
+		result.Stats.WitnessesTested >= result.M.Int64()/2 {
+		t.Error(result.Verdict, result.Stats.WitnessesTested)
+	}
+
+	resume := &Checkpoint{n, result.R, result.Completed}
+	tested := result.Stats.WitnessesTested
+	result, err = RunAKS(
+		context.Background(), n, &AKSOptions{Resume: resume})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Verdict != Prime ||
+		tested+result.Stats.WitnessesTested != result.M.Int64()-1 {
+		t.Error(result.Verdict, tested, result.Stats.WitnessesTested)
+	}
+
+	result, err = RunAKS(
+		context.Background(), big.NewInt(2993374621),
+		&AKSOptions{PrimeWitnessesOnly: true, SkipBPSW: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Verdict != Composite || !result.Witness.ProbablyPrime(0) {
+		t.Error(result.Verdict, result.Witness)
+	}
+}
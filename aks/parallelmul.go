@@ -0,0 +1,87 @@
+package aks
+
+import "math/big"
+import "math/bits"
+import "runtime"
+import "sync"
+
+// The smallest number of words in the shorter operand for which
+// parallelMul splits a multiplication across goroutines.
+const minParallelMulWords = 1 << 13
+
+// The most levels of splitting parallelMul does, i.e. it uses at most
+// 3^maxParallelMulDepth goroutines.
+const maxParallelMulDepth = 2
+
+// Sets z to x*y like z.Mul(x, y), where x and y must be non-negative.
+// For large enough x and y, splits the multiplication into smaller
+// ones with Karatsuba's method and does them on separate goroutines,
+// since big.Int.Mul only uses one. z must not alias x or y, although
+// x and y may be the same big.Int, in which case the smaller
+// multiplications are squarings, too.
+func parallelMul(z, x, y *big.Int) {
+	// Split until there are at least as many multiplications as
+	// procs.
+	depth := 0
+	for n := 1; n < runtime.GOMAXPROCS(0) &&
+		depth < maxParallelMulDepth; n *= 3 {
+		depth++
+	}
+	karatsubaMul(z, x, y, depth)
+}
+
+// Like parallelMul, but splits at most depth levels.
+func karatsubaMul(z, x, y *big.Int, depth int) {
+	xBits := x.Bits()
+	yBits := y.Bits()
+	n := len(xBits)
+	if len(yBits) < n {
+		n = len(yBits)
+	}
+	if depth <= 0 || n < minParallelMulWords {
+		z.Mul(x, y)
+		return
+	}
+
+	// With B = 2^(h*_W), x = x1*B + x0 and y = y1*B + y0, so
+	//
+	//   x*y = z2*B^2 + (z1 - z2 - z0)*B + z0,
+	//
+	// where z0 = x0*y0, z2 = x1*y1, and z1 = (x0 + x1)*(y0 + y1).
+	// x0 and y0 share words with x and y, which is fine since
+	// they're only read.
+	h := n / 2
+	var x0, x1, xSum big.Int
+	x0.SetBits(xBits[:h])
+	x1.SetBits(xBits[h:])
+	xSum.Add(&x0, &x1)
+	y0, y1, ySum := &x0, &x1, &xSum
+	if x != y {
+		y0, y1, ySum = new(big.Int), new(big.Int), new(big.Int)
+		y0.SetBits(yBits[:h])
+		y1.SetBits(yBits[h:])
+		ySum.Add(y0, y1)
+	}
+
+	var z0, z1, z2 big.Int
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		karatsubaMul(&z0, &x0, y0, depth-1)
+	}()
+	go func() {
+		defer wg.Done()
+		karatsubaMul(&z2, &x1, y1, depth-1)
+	}()
+	karatsubaMul(&z1, &xSum, ySum, depth-1)
+	wg.Wait()
+
+	z1.Sub(&z1, &z2)
+	z1.Sub(&z1, &z0)
+	shift := uint(h * bits.UintSize)
+	z.Lsh(&z2, shift)
+	z.Add(z, &z1)
+	z.Lsh(z, shift)
+	z.Add(z, &z0)
+}
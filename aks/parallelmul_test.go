@@ -0,0 +1,38 @@
+package aks
+
+import "math/big"
+import "math/rand"
+import "testing"
+
+// Returns a random non-negative big.Int with the given number of
+// words.
+func randomBigInt(rng *rand.Rand, wordCount int) *big.Int {
+	words := make([]big.Word, wordCount)
+	for i := range words {
+		words[i] = big.Word(rng.Uint64())
+	}
+	return new(big.Int).SetBits(words)
+}
+
+// karatsubaMul should match big.Int.Mul for products and squares of
+// operands large enough to be split, including unbalanced ones.
+func TestKaratsubaMul(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	x := randomBigInt(rng, minParallelMulWords*2+3)
+	y := randomBigInt(rng, minParallelMulWords*2+1)
+	small := randomBigInt(rng, minParallelMulWords+5)
+	for _, test := range []struct{ x, y *big.Int }{
+		{x, y}, {x, x}, {x, small}, {small, y}, {small, small},
+		{x, big.NewInt(0)},
+	} {
+		var expected big.Int
+		expected.Mul(test.x, test.y)
+		for depth := 0; depth <= 2; depth++ {
+			var z big.Int
+			karatsubaMul(&z, test.x, test.y, depth)
+			if z.Cmp(&expected) != 0 {
+				t.Error(depth, test.x.BitLen(), test.y.BitLen())
+			}
+		}
+	}
+}
@@ -0,0 +1,211 @@
+package aks
+
+import "context"
+import "log"
+import "math/big"
+import "sync"
+
+// Holds what can be computed ahead of time for testing AKS witnesses
+// of n with parameter r: the polynomial backend along with its
+// constants (e.g., the number of words per coefficient and the
+// Barrett and Montgomery constants for BigIntPolyBackend, or the
+// primes and twiddle factors for NTTPolyBackend), and the addition
+// chain for raising polynomials to the nth power, if any.
+//
+// An AKSParams is never modified after it's made, so a single one is
+// shared by all the goroutines of a witness search, each of which
+// only needs its own polynomials. Those are taken from a pool shared
+// by all AKSParams for the same n, r, and backend, so that they're
+// reused across witnesses and calls.
+type AKSParams struct {
+	n, r    big.Int
+	backend PolyBackend
+	polys   *polyPool
+	// nil if binary exponentiation is used.
+	chain *AdditionChain
+}
+
+// Makes the AKSParams for n and r with the backend and
+// exponentiation options in o (the other options are ignored). o may
+// be nil. n and r must be >= 2 and r must fit into an int.
+func NewAKSParams(n, r *big.Int, o *AKSOptions) (*AKSParams, error) {
+	if err := checkAKSWitnessArgs(n, r, 1); err != nil {
+		return nil, err
+	}
+	var opts AKSOptions
+	if o != nil {
+		opts = *o
+	}
+	backend := opts.Backend
+	if backend == AutoPolyBackend {
+		backend = pickPolyBackend(n, r)
+	}
+	polys, err := getPolyPool(n, r, backend)
+	if err != nil {
+		return nil, err
+	}
+	chain, err := opts.getAdditionChain(n)
+	if err != nil {
+		return nil, err
+	}
+	p := &AKSParams{backend: backend, polys: polys, chain: chain}
+	p.n.Set(n)
+	p.r.Set(r)
+	return p, nil
+}
+
+// Returns a copy of n.
+func (p *AKSParams) N() *big.Int {
+	return new(big.Int).Set(&p.n)
+}
+
+// Returns a copy of r.
+func (p *AKSParams) R() *big.Int {
+	return new(big.Int).Set(&p.r)
+}
+
+// Returns the backend used, which is never AutoPolyBackend.
+func (p *AKSParams) Backend() PolyBackend {
+	return p.backend
+}
+
+// Returns whether a is an AKS witness of n with parameter r, like
+// IsAKSWitness. It's safe to call this from multiple goroutines at
+// once.
+func (p *AKSParams) IsAKSWitness(a *big.Int) bool {
+	tester := newWitnessTester(p)
+	defer tester.release()
+	isWitness, _ := tester.test(*a, nil, nil, nil)
+	return isWitness
+}
+
+// Like TestWitnessBatch, but with p's n and r. It's safe to call
+// this from multiple goroutines at once.
+func (p *AKSParams) TestWitnessBatch(as []*big.Int) *big.Int {
+	tester := newWitnessTester(p)
+	defer tester.release()
+	var mask big.Int
+	for i, a := range as {
+		if isWitness, _ := tester.test(*a, nil, nil, nil); isWitness {
+			mask.SetBit(&mask, i, 1)
+		}
+	}
+	return &mask
+}
+
+// Holds the polynomials that a single goroutine uses to test AKS
+// witnesses with the given params, which are taken from
+// params.polys until they're released.
+type witnessTester struct {
+	params           *AKSParams
+	tmp1, tmp2, tmp3 poly
+	// nil if params.chain is.
+	power *chainPower
+}
+
+// Makes a witnessTester for params. It must be released when it's
+// no longer needed.
+func newWitnessTester(params *AKSParams) *witnessTester {
+	w := &witnessTester{
+		params: params,
+		tmp1:   params.polys.newPoly(),
+		tmp2:   params.polys.newPoly(),
+		tmp3:   params.polys.newPoly(),
+	}
+	if params.chain != nil {
+		w.power = newChainPower(*params.chain, params.polys)
+	}
+	return w
+}
+
+// Returns w's polys to w.params.polys. w must not be used
+// afterwards.
+func (w *witnessTester) release() {
+	polys := w.params.polys
+	polys.put(w.tmp1)
+	polys.put(w.tmp2)
+	polys.put(w.tmp3)
+	if w.power != nil {
+		for _, slot := range w.power.slots {
+			polys.put(slot)
+		}
+	}
+	*w = witnessTester{}
+}
+
+// Returns the number of bytes allocated for w's polys.
+func (w *witnessTester) getPhiBytes() int64 {
+	phiBytes := w.tmp1.getPhiBytes() + w.tmp2.getPhiBytes() +
+		w.tmp3.getPhiBytes()
+	if w.power != nil {
+		phiBytes += w.power.getPhiBytes()
+	}
+	return phiBytes
+}
+
+// Like isAKSWitnessCancelable, but with w's params and polys.
+func (w *witnessTester) test(
+	a big.Int, done <-chan struct{},
+	debugLogger *log.Logger,
+	yield func()) (isWitness, completed bool) {
+	return isAKSWitnessCancelable(
+		w.params.n, a, w.tmp1, w.tmp2, w.tmp3, w.power, done,
+		debugLogger, yield)
+}
+
+// A WitnessTester tests AKS witnesses of n with parameter r using its
+// own polynomials, which it holds until it's closed instead of taking
+// them from the shared pool for each test. It's safe to use from
+// multiple goroutines at once, although tests are done one at a
+// time; to test witnesses in parallel, use one WitnessTester per
+// goroutine.
+type WitnessTester struct {
+	params *AKSParams
+	// Guards tester, and the polys it holds while a test is
+	// running.
+	lock sync.Mutex
+	// nil once the WitnessTester is closed.
+	tester *witnessTester
+}
+
+// Makes a WitnessTester for params. It should be closed when it's no
+// longer needed.
+func NewWitnessTester(params *AKSParams) *WitnessTester {
+	return &WitnessTester{params: params, tester: newWitnessTester(params)}
+}
+
+// Returns the params w was made with.
+func (w *WitnessTester) Params() *AKSParams {
+	return w.params
+}
+
+// Returns whether a is an AKS witness of w.Params().N() with
+// parameter w.Params().R(), like AKSParams.IsAKSWitness. Returns
+// early with ctx.Err() if ctx is cancelled, or returns ErrClosed if w
+// is closed.
+func (w *WitnessTester) Test(ctx context.Context, a *big.Int) (bool, error) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	if w.tester == nil {
+		return false, ErrClosed
+	}
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+	isWitness, completed := w.tester.test(*a, ctx.Done(), nil, nil)
+	if !completed {
+		return false, ctx.Err()
+	}
+	return isWitness, nil
+}
+
+// Returns w's polynomials to the shared pool, waiting for any running
+// test to finish first. Calling Close more than once has no effect.
+func (w *WitnessTester) Close() {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	if w.tester != nil {
+		w.tester.release()
+		w.tester = nil
+	}
+}
@@ -0,0 +1,194 @@
+package aks
+
+import "context"
+import "errors"
+import "math/big"
+import "sync"
+import "testing"
+
+// NewAKSParams should resolve AutoPolyBackend, copy n and r, and
+// reject bad input.
+func TestNewAKSParams(t *testing.T) {
+	n := big.NewInt(1000003)
+	r := mustCalculateAKSModulus(n, t)
+	params, err := NewAKSParams(n, r, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if params.Backend() != pickPolyBackend(n, r) {
+		t.Error(params.Backend())
+	}
+	n.SetInt64(5)
+	if params.N().Cmp(big.NewInt(1000003)) != 0 {
+		t.Error(params.N())
+	}
+	if params.R().Cmp(r) != 0 {
+		t.Error(params.R(), r)
+	}
+
+	if _, err := NewAKSParams(
+		big.NewInt(1), r, nil); !errors.Is(err, ErrBadInput) {
+		t.Error(err)
+	}
+	chain := mustWindowAdditionChain(t, big.NewInt(1000033), 2)
+	if _, err := NewAKSParams(
+		big.NewInt(1000003), r,
+		&AKSOptions{AdditionChain: &chain}); !errors.Is(
+		err, ErrBadInput) {
+		t.Error(err)
+	}
+}
+
+// AKSParams.IsAKSWitness should agree with IsAKSWitness when called
+// from several goroutines at once.
+func TestAKSParamsIsAKSWitnessConcurrent(t *testing.T) {
+	n := big.NewInt(1961)
+	r := big.NewInt(5)
+	params, err := NewAKSParams(n, r, &AKSOptions{PowWindowBits: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var wg sync.WaitGroup
+	for a := int64(1); a < 20; a++ {
+		wg.Add(1)
+		go func(a *big.Int) {
+			defer wg.Done()
+			expected, err := IsAKSWitness(n, r, a)
+			if err != nil {
+				t.Error(a, err)
+				return
+			}
+			if params.IsAKSWitness(a) != expected {
+				t.Error(a, expected)
+			}
+		}(big.NewInt(a))
+	}
+	wg.Wait()
+}
+
+// AKSParams for the same n, r, and backend should share a polyPool,
+// which should reuse released polys.
+func TestAKSParamsPolyPool(t *testing.T) {
+	n := big.NewInt(1000003)
+	r := mustCalculateAKSModulus(n, t)
+	params1, err := NewAKSParams(n, r, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	params2, err := NewAKSParams(n, r, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if params1.polys != params2.polys {
+		t.Error("polyPools not shared")
+	}
+	params3, err := NewAKSParams(
+		n, r, &AKSOptions{Backend: NTTPolyBackend})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if params3.polys == params1.polys {
+		t.Error("polyPools shared across backends")
+	}
+
+	// A released poly may or may not be reused, but released
+	// polys should still give correct results.
+	for a := int64(1); a < 5; a++ {
+		expected, err := IsAKSWitness(n, r, big.NewInt(a))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if params1.IsAKSWitness(big.NewInt(a)) != expected {
+			t.Error(a, expected)
+		}
+	}
+}
+
+// getPolyPool should keep at most maxPolyPools pools.
+func TestGetPolyPoolEviction(t *testing.T) {
+	r := big.NewInt(5)
+	for i := int64(0); i < 2*maxPolyPools; i++ {
+		if _, err := getPolyPool(
+			big.NewInt(101+i), r, BigIntPolyBackend); err != nil {
+			t.Fatal(err)
+		}
+	}
+	polyPools.Lock()
+	defer polyPools.Unlock()
+	if len(polyPools.pools) != maxPolyPools ||
+		len(polyPools.keys) != maxPolyPools {
+		t.Error(len(polyPools.pools), len(polyPools.keys))
+	}
+}
+
+// A WitnessTester should agree with IsAKSWitness when shared by
+// several goroutines, and closing it concurrently shouldn't break
+// any tests that are running.
+func TestWitnessTesterConcurrent(t *testing.T) {
+	n := big.NewInt(1961)
+	r := big.NewInt(5)
+	params, err := NewAKSParams(n, r, &AKSOptions{PowWindowBits: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := NewWitnessTester(params)
+	if w.Params() != params {
+		t.Error(w.Params(), params)
+	}
+	var wg sync.WaitGroup
+	for a := int64(1); a < 20; a++ {
+		wg.Add(1)
+		go func(a *big.Int) {
+			defer wg.Done()
+			expected, err := IsAKSWitness(n, r, a)
+			if err != nil {
+				t.Error(a, err)
+				return
+			}
+			isWitness, err := w.Test(context.Background(), a)
+			if err != nil {
+				t.Error(a, err)
+			} else if isWitness != expected {
+				t.Error(a, expected)
+			}
+		}(big.NewInt(a))
+	}
+	wg.Wait()
+
+	wg.Add(2)
+	for i := 0; i < 2; i++ {
+		go func() {
+			defer wg.Done()
+			_, err := w.Test(context.Background(), big.NewInt(1))
+			if err != nil && err != ErrClosed {
+				t.Error(err)
+			}
+		}()
+	}
+	w.Close()
+	wg.Wait()
+}
+
+// A WitnessTester should report cancellation and use after closing.
+func TestWitnessTesterErrors(t *testing.T) {
+	params, err := NewAKSParams(big.NewInt(1000003), big.NewInt(101), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := NewWitnessTester(params)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := w.Test(ctx, big.NewInt(1)); err != context.Canceled {
+		t.Error(err)
+	}
+	if _, err := w.Test(
+		context.Background(), big.NewInt(1)); err != nil {
+		t.Error(err)
+	}
+	w.Close()
+	w.Close()
+	if _, err := w.Test(
+		context.Background(), big.NewInt(1)); err != ErrClosed {
+		t.Error(err)
+	}
+}
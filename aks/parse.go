@@ -0,0 +1,305 @@
+package aks
+
+import "fmt"
+import "math/big"
+import "strings"
+import "unicode"
+
+// The largest exponent ParseNumber accepts, to keep typos like
+// 2^2^64 from exhausting memory.
+const maxParseExponent = 1 << 24
+
+// The largest number of bits ParseNumber lets a product or power
+// have, since a capped exponent alone doesn't stop nested powers like
+// (2^16777216)^16777216 from needing terabytes. This is enough for
+// 2^maxParseExponent, with room to spare.
+const maxParseBits = 1 << 26
+
+// Parses s as a number, which may be written as an expression using
+// integer literals (decimal, or with a 0x, 0o, or 0b prefix),
+// parentheses, unary minus, and the binary operators +, -, *, /
+// (truncated division), % (remainder, with the sign of the
+// dividend), and ^ (exponentiation, which is right-associative and
+// binds tighter than unary minus). For example, "2^127-1",
+// "10^100+267", and "(2^89-1)*3+4" are all valid. Exponents above
+// 2^24, and products and powers above 2^26 bits, are rejected with an
+// error wrapping ErrBadInput.
+func ParseNumber(s string) (*big.Int, error) {
+	p := &numberParser{s: s}
+	x, err := p.parseExpr()
+	if err != nil {
+		return nil, err
+	}
+	if p.skipSpace(); p.pos < len(p.s) {
+		return nil, p.errorf("unexpected %q", p.s[p.pos:])
+	}
+	return x, nil
+}
+
+// A numberParser is a recursive-descent parser for the expressions
+// accepted by ParseNumber.
+type numberParser struct {
+	s   string
+	pos int
+}
+
+// Returns an error wrapping ErrBadInput for the current position.
+func (p *numberParser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("%w: could not parse %q at position %d: %s",
+		ErrBadInput, p.s, p.pos, fmt.Sprintf(format, args...))
+}
+
+// Advances past any whitespace.
+func (p *numberParser) skipSpace() {
+	for p.pos < len(p.s) && unicode.IsSpace(rune(p.s[p.pos])) {
+		p.pos++
+	}
+}
+
+// Returns whether the next non-space character is c, consuming it if
+// so.
+func (p *numberParser) accept(c byte) bool {
+	p.skipSpace()
+	if p.pos < len(p.s) && p.s[p.pos] == c {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+// expr = term { ("+" | "-") term }
+func (p *numberParser) parseExpr() (*big.Int, error) {
+	x, err := p.parseTerm()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		var add bool
+		switch {
+		case p.accept('+'):
+			add = true
+		case p.accept('-'):
+			add = false
+		default:
+			return x, nil
+		}
+		y, err := p.parseTerm()
+		if err != nil {
+			return nil, err
+		}
+		if add {
+			x.Add(x, y)
+		} else {
+			x.Sub(x, y)
+		}
+	}
+}
+
+// term = unary { ("*" | "/" | "%") unary }
+func (p *numberParser) parseTerm() (*big.Int, error) {
+	x, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		var op byte
+		switch {
+		case p.accept('*'):
+			op = '*'
+		case p.accept('/'):
+			op = '/'
+		case p.accept('%'):
+			op = '%'
+		default:
+			return x, nil
+		}
+		y, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		if op != '*' && y.Sign() == 0 {
+			return nil, p.errorf("division by zero")
+		}
+		switch op {
+		case '*':
+			if x.BitLen()+y.BitLen() > maxParseBits {
+				return nil, p.errorf(
+					"product would have more than %d bits",
+					maxParseBits)
+			}
+			x.Mul(x, y)
+		case '/':
+			x.Quo(x, y)
+		case '%':
+			x.Rem(x, y)
+		}
+	}
+}
+
+// unary = "-" unary | power
+func (p *numberParser) parseUnary() (*big.Int, error) {
+	if p.accept('-') {
+		x, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return x.Neg(x), nil
+	}
+	return p.parsePower()
+}
+
+// power = primary [ "^" unary ]
+func (p *numberParser) parsePower() (*big.Int, error) {
+	x, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+	if !p.accept('^') {
+		return x, nil
+	}
+	y, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	if y.Sign() < 0 || y.Cmp(big.NewInt(maxParseExponent)) > 0 {
+		return nil, p.errorf(
+			"exponent %v must be between 0 and %d",
+			y, maxParseExponent)
+	}
+	if int64(x.BitLen())*y.Int64() > maxParseBits {
+		return nil, p.errorf(
+			"power would have more than %d bits", maxParseBits)
+	}
+	return x.Exp(x, y, nil), nil
+}
+
+// primary = number | "(" expr ")"
+func (p *numberParser) parsePrimary() (*big.Int, error) {
+	if p.accept('(') {
+		x, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		if !p.accept(')') {
+			return nil, p.errorf("expected )")
+		}
+		return x, nil
+	}
+
+	p.skipSpace()
+	start := p.pos
+	for p.pos < len(p.s) && (p.s[p.pos] == '_' ||
+		unicode.IsLetter(rune(p.s[p.pos])) ||
+		unicode.IsDigit(rune(p.s[p.pos]))) {
+		p.pos++
+	}
+	literal := p.s[start:p.pos]
+	if len(literal) == 0 {
+		if p.pos == len(p.s) {
+			return nil, p.errorf("unexpected end of input")
+		}
+		return nil, p.errorf("unexpected %q", p.s[p.pos])
+	}
+	// Base 0 handles the prefixes and underscores, but would also
+	// treat a leading 0 as octal, which is surprising for decimal
+	// input.
+	digits := literal
+	if !strings.ContainsAny(digits, "xXoObB") {
+		digits = strings.TrimLeft(digits, "0")
+		if len(digits) == 0 {
+			digits = "0"
+		}
+	}
+	x, ok := new(big.Int).SetString(digits, 0)
+	if !ok {
+		p.pos = start
+		return nil, p.errorf("invalid number %q", literal)
+	}
+	return x, nil
+}
+
+// Parses s as a polynomial mod (N, X^R - 1) in the notation that
+// Poly formats to, e.g. "3x^5 + 2x + 7", and returns it as a Poly with
+// the given backend, like NewPoly. Also accepts "X" for "x", "*"
+// between a coefficient and "x", subtraction, repeated terms, terms
+// in any order, and coefficients and exponents that aren't reduced
+// mod N and R, respectively.
+func ParsePoly(s string, N, R *big.Int, backend PolyBackend) (*Poly, error) {
+	poly, err := NewPoly(N, R, backend)
+	if err != nil {
+		return nil, err
+	}
+	coefficients := make([]big.Int, R.Int64())
+	p := &numberParser{s: s}
+	negate := p.accept('-')
+	for {
+		degree, c, err := p.parseMonomial()
+		if err != nil {
+			return nil, err
+		}
+		if negate {
+			c.Neg(c)
+		}
+		i := degree.Mod(degree, R).Int64()
+		coefficients[i].Add(&coefficients[i], c)
+
+		switch {
+		case p.accept('+'):
+			negate = false
+		case p.accept('-'):
+			negate = true
+		default:
+			if p.skipSpace(); p.pos < len(p.s) {
+				return nil, p.errorf("unexpected %q", p.s[p.pos:])
+			}
+			for i := range coefficients {
+				poly.SetCoefficient(i, &coefficients[i])
+			}
+			return poly, nil
+		}
+	}
+}
+
+// monomial = digits | [digits ["*"]] ("x" | "X") ["^" digits]
+//
+// Returns the degree and the coefficient.
+func (p *numberParser) parseMonomial() (degree, c *big.Int, err error) {
+	c = p.parseDigits()
+	hasCoefficient := c != nil
+	if !hasCoefficient {
+		c = big.NewInt(1)
+	}
+	hasStar := hasCoefficient && p.accept('*')
+	if !p.accept('x') && !p.accept('X') {
+		if hasCoefficient && !hasStar {
+			return &big.Int{}, c, nil
+		}
+		if p.pos == len(p.s) {
+			return nil, nil, p.errorf("unexpected end of input")
+		}
+		return nil, nil, p.errorf("unexpected %q", p.s[p.pos])
+	}
+	if !p.accept('^') {
+		return big.NewInt(1), c, nil
+	}
+	degree = p.parseDigits()
+	if degree == nil {
+		return nil, nil, p.errorf("expected an exponent")
+	}
+	return degree, c, nil
+}
+
+// Returns the decimal number at the current position, or nil if
+// there isn't one.
+func (p *numberParser) parseDigits() *big.Int {
+	p.skipSpace()
+	start := p.pos
+	for p.pos < len(p.s) && '0' <= p.s[p.pos] && p.s[p.pos] <= '9' {
+		p.pos++
+	}
+	if p.pos == start {
+		return nil
+	}
+	x, _ := new(big.Int).SetString(p.s[start:p.pos], 10)
+	return x
+}
@@ -0,0 +1,144 @@
+package aks
+
+import "errors"
+import "fmt"
+import "math/big"
+import "testing"
+
+// ParseNumber should evaluate expressions with the usual precedence.
+func TestParseNumber(t *testing.T) {
+	mersenne127, _ := new(big.Int).SetString(
+		"170141183460469231731687303715884105727", 10)
+	var googolPlus267 big.Int
+	googolPlus267.Exp(big.NewInt(10), big.NewInt(100), nil)
+	googolPlus267.Add(&googolPlus267, big.NewInt(267))
+	var expr big.Int
+	expr.Exp(big.NewInt(2), big.NewInt(89), nil)
+	expr.Sub(&expr, big.NewInt(1))
+	expr.Mul(&expr, big.NewInt(3))
+	expr.Add(&expr, big.NewInt(4))
+
+	tests := []struct {
+		s        string
+		expected *big.Int
+	}{
+		{"2685241991", big.NewInt(2685241991)},
+		{"  0017 ", big.NewInt(17)},
+		{"0x1F", big.NewInt(31)},
+		{"0b101", big.NewInt(5)},
+		{"0o17", big.NewInt(15)},
+		{"1_000_003", big.NewInt(1000003)},
+		{"2^127-1", mersenne127},
+		{"2 ^ 127 - 1", mersenne127},
+		{"10^100+267", &googolPlus267},
+		{"(2^89-1)*3+4", &expr},
+		{"2^3^2", big.NewInt(512)},
+		{"-2^2", big.NewInt(-4)},
+		{"(-2)^2", big.NewInt(4)},
+		{"1-2-3", big.NewInt(-4)},
+		{"7/2*2", big.NewInt(6)},
+		{"-7%3", big.NewInt(-1)},
+		{"1+2*3", big.NewInt(7)},
+		{"(2^16777216)^2/2^16777215/2^16777216", big.NewInt(2)},
+		{"(-1)^16777216", big.NewInt(1)},
+	}
+	for _, test := range tests {
+		x, err := ParseNumber(test.s)
+		if err != nil {
+			t.Error(test.s, err)
+		} else if x.Cmp(test.expected) != 0 {
+			t.Error(test.s, x, test.expected)
+		}
+	}
+}
+
+// ParseNumber should reject malformed expressions with ErrBadInput.
+func TestParseNumberErrors(t *testing.T) {
+	for _, s := range []string{
+		"", "foo", "1+", "(1", "1)", "1 2", "1/0", "5%0",
+		"2^-1", "2^(2^64)", "0xg", "1..2",
+		"(2^16777216)^16777216", "(2^16777216)^5",
+		"(2^16777216)*(2^16777216)*(2^16777216)*(2^16777216)",
+	} {
+		x, err := ParseNumber(s)
+		if !errors.Is(err, ErrBadInput) {
+			t.Error(s, x, err)
+		}
+	}
+}
+
+// ParsePoly should invert Poly's formatting, and accept the other
+// notations it documents.
+func TestParsePoly(t *testing.T) {
+	N := big.NewInt(101)
+	R := big.NewInt(53)
+	tests := []struct {
+		s, expected string
+	}{
+		{"0", "0"},
+		{"7", "7"},
+		{"x", "x"},
+		{"3x^5 + 2x + 7", "3x^5 + 2x + 7"},
+		{"  3 X ^ 5+2*x+7 ", "3x^5 + 2x + 7"},
+		{"7 + 2x + 3x^5", "3x^5 + 2x + 7"},
+		{"x^2 - 1", "x^2 + 100"},
+		{"-x + x^0", "100x + 1"},
+		{"x + x + 100x", "x"},
+		{"102x^54 + 202", "x"},
+		{"x^53", "1"},
+	}
+	for _, backend := range []PolyBackend{
+		BigIntPolyBackend, WordPolyBackend, NTTPolyBackend,
+	} {
+		for _, test := range tests {
+			p, err := ParsePoly(test.s, N, R, backend)
+			if err != nil {
+				t.Error(backend, test.s, err)
+			} else if str := fmt.Sprint(p); str != test.expected {
+				t.Error(backend, test.s, str, test.expected)
+			}
+		}
+	}
+}
+
+// Formatting a Poly and parsing the result should give the same
+// Poly.
+func TestParsePolyRoundTrip(t *testing.T) {
+	N := big.NewInt(1000003)
+	R := big.NewInt(53)
+	p, err := NewPoly(N, R, AutoPolyBackend)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 53; i += 3 {
+		p.SetCoefficient(i, big.NewInt(int64(i*i*i+1)))
+	}
+	q, err := ParsePoly(fmt.Sprint(p), N, R, NTTPolyBackend)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !p.Eq(q) {
+		t.Error(p, q)
+	}
+}
+
+// ParsePoly should reject malformed polynomials and bad moduli with
+// ErrBadInput.
+func TestParsePolyErrors(t *testing.T) {
+	N := big.NewInt(101)
+	R := big.NewInt(53)
+	for _, s := range []string{
+		"", "+", "x +", "3*", "3*7", "x^", "x^-1", "2 3", "y",
+		"x^(2)", "3xx",
+	} {
+		p, err := ParsePoly(s, N, R, AutoPolyBackend)
+		if !errors.Is(err, ErrBadInput) {
+			t.Error(s, p, err)
+		}
+	}
+	if _, err := ParsePoly(
+		"x", big.NewInt(1), R, AutoPolyBackend); !errors.Is(
+		err, ErrBadInput) {
+		t.Error(err)
+	}
+}
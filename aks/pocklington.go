@@ -0,0 +1,237 @@
+package aks
+
+import "fmt"
+import "io"
+import "math/big"
+
+// The largest base ProvePocklington tries for each prime factor of
+// n - 1. For prime n, almost every base works, so running out means
+// something is wrong.
+const pocklingtonMaxBase = 1000
+
+// A PocklingtonCertificate proves N prime by Pocklington's theorem:
+// if F divides N - 1, F > sqrt(N), and for every prime q dividing F
+// there is an a with a^(N - 1) = 1 (mod N) and gcd(a^((N - 1)/q) - 1,
+// N) = 1, then N is prime. Checking one takes a few modular
+// exponentiations per factor, no matter how long finding it took.
+type PocklingtonCertificate struct {
+	N *big.Int
+	// The prime powers whose product is F, in ascending order of
+	// P. Empty if N < 2^64, in which case IsPrimeUint64 decides.
+	Factors []PocklingtonFactor
+}
+
+// Holds a prime power dividing N - 1 and the base a for its prime.
+type PocklingtonFactor struct {
+	PrimePower
+	A *big.Int
+	// Proves P prime. Nil if P < 2^64, in which case
+	// IsPrimeUint64 decides.
+	Certificate *PocklingtonCertificate
+}
+
+// Returns a certificate proving n prime with Pocklington's theorem,
+// after factoring n - 1 as in factorizeBounded, which is much faster
+// than AKS when enough of n - 1 can be factored (as for most primes
+// constructed to be provable). The prime factors of n - 1 above 2^64
+// that are needed are proven prime recursively. Returns an error
+// wrapping ErrComposite if n turns out to be composite,
+// ErrProofNotFound if not enough of n - 1 can be factored, or
+// ErrBadInput if n < 2.
+func ProvePocklington(n *big.Int) (*PocklingtonCertificate, error) {
+	if n.Cmp(big.NewInt(2)) < 0 {
+		return nil, fmt.Errorf(
+			"%w: n = %v must be >= 2", ErrBadInput, n)
+	}
+	return provePocklington(n)
+}
+
+// Like ProvePocklington, but assumes n >= 2.
+func provePocklington(n *big.Int) (*PocklingtonCertificate, error) {
+	if isPrime, _ := BailliePSW(n); !isPrime {
+		return nil, fmt.Errorf("%w: n = %v", ErrComposite, n)
+	}
+	c := &PocklingtonCertificate{N: new(big.Int).Set(n)}
+	if n.IsUint64() {
+		return c, nil
+	}
+
+	nMinusOne := new(big.Int).Sub(n, big.NewInt(1))
+	factors := findBoundedPrimePowers(nMinusOne)
+
+	// Use the factors in ascending order until F > sqrt(n), so
+	// that as few large ones as possible need their own proofs.
+	F := big.NewInt(1)
+	var FSq, pk big.Int
+	for _, f := range factors {
+		if FSq.Mul(F, F).Cmp(n) > 0 {
+			break
+		}
+		var pCert *PocklingtonCertificate
+		if !f.P.IsUint64() {
+			var err error
+			pCert, err = provePocklington(f.P)
+			if err != nil {
+				// A factor we can't prove prime is
+				// just left out of F.
+				continue
+			}
+		}
+
+		a, err := findPocklingtonBase(n, f.P)
+		if err != nil {
+			return nil, err
+		}
+		c.Factors = append(c.Factors, PocklingtonFactor{
+			PrimePower:  f,
+			A:           a,
+			Certificate: pCert,
+		})
+		F.Mul(F, pk.Exp(f.P, f.K, nil))
+	}
+	if FSq.Mul(F, F).Cmp(n) <= 0 {
+		return nil, fmt.Errorf(
+			"%w: only %v of n - 1 = %v - 1 could be factored "+
+				"and proven, which isn't above sqrt(n)",
+			ErrProofNotFound, F, n)
+	}
+	return c, nil
+}
+
+// Returns the prime powers dividing n > 0 found by factorizeBounded.
+func findBoundedPrimePowers(n *big.Int) []PrimePower {
+	var factors []PrimePower
+	factorizeBounded(n, func(p, k *big.Int) bool {
+		// trialDivide reuses p and k.
+		factors = append(factors, PrimePower{
+			new(big.Int).Set(p), new(big.Int).Set(k),
+		})
+		return true
+	})
+	return factors
+}
+
+// Returns the smallest base a >= 2 for the prime q dividing n - 1
+// that satisfies the conditions of Pocklington's theorem. Returns an
+// error wrapping ErrComposite if n is found to be composite, or
+// ErrProofNotFound if no base up to pocklingtonMaxBase works.
+func findPocklingtonBase(n, q *big.Int) (*big.Int, error) {
+	one := big.NewInt(1)
+	nMinusOne := new(big.Int).Sub(n, one)
+	var e, x, g big.Int
+	e.Quo(nMinusOne, q)
+	for b := int64(2); b <= pocklingtonMaxBase; b++ {
+		a := big.NewInt(b)
+		if x.Exp(a, nMinusOne, n).Cmp(one) != 0 {
+			return nil, fmt.Errorf(
+				"%w: n = %v fails Fermat's test with base %d",
+				ErrComposite, n, b)
+		}
+		x.Exp(a, &e, n)
+		x.Sub(&x, one)
+		g.GCD(nil, nil, &x, n)
+		if g.Cmp(one) == 0 {
+			return a, nil
+		}
+		if g.Cmp(n) != 0 {
+			return nil, fmt.Errorf(
+				"%w: n = %v has factor %v", ErrComposite, n, &g)
+		}
+	}
+	return nil, fmt.Errorf(
+		"%w: no base up to %d works for the factor %v of "+
+			"n - 1 = %v - 1",
+		ErrProofNotFound, pocklingtonMaxBase, q, n)
+}
+
+// Returns whether a satisfies the conditions of Pocklington's theorem
+// for the prime q dividing n - 1.
+func isPocklingtonBase(n, q, a *big.Int) bool {
+	one := big.NewInt(1)
+	var nMinusOne, e, x big.Int
+	nMinusOne.Sub(n, one)
+	if x.Exp(a, &nMinusOne, n).Cmp(one) != 0 {
+		return false
+	}
+	e.Quo(&nMinusOne, q)
+	x.Exp(a, &e, n)
+	x.Sub(&x, one)
+	return x.GCD(nil, nil, &x, n).Cmp(one) == 0
+}
+
+// Returns nil if c proves c.N prime, and an error wrapping
+// ErrInvalidCertificate otherwise.
+func (c *PocklingtonCertificate) Verify() error {
+	if c.N == nil || c.N.Cmp(big.NewInt(2)) < 0 {
+		return fmt.Errorf(
+			"%w: N = %v must be >= 2", ErrInvalidCertificate, c.N)
+	}
+	if c.N.IsUint64() {
+		if !IsPrimeUint64(c.N.Uint64()) {
+			return fmt.Errorf(
+				"%w: N = %v is composite",
+				ErrInvalidCertificate, c.N)
+		}
+		return nil
+	}
+
+	one := big.NewInt(1)
+	nMinusOne := new(big.Int).Sub(c.N, one)
+	F := big.NewInt(1)
+	var pk, x, r big.Int
+	for _, f := range c.Factors {
+		if f.P == nil || f.K == nil || f.A == nil ||
+			f.P.Cmp(big.NewInt(2)) < 0 || f.K.Sign() <= 0 ||
+			f.A.Sign() <= 0 || f.A.Cmp(c.N) >= 0 {
+			return fmt.Errorf(
+				"%w: factor %v^%v with base %v of N - 1 = "+
+					"%v - 1 is out of range",
+				ErrInvalidCertificate, f.P, f.K, f.A, c.N)
+		}
+		if f.P.IsUint64() {
+			if !IsPrimeUint64(f.P.Uint64()) {
+				return fmt.Errorf(
+					"%w: factor %v of N - 1 = %v - 1 "+
+						"is composite",
+					ErrInvalidCertificate, f.P, c.N)
+			}
+		} else {
+			if f.Certificate == nil ||
+				f.Certificate.N == nil ||
+				f.Certificate.N.Cmp(f.P) != 0 {
+				return fmt.Errorf(
+					"%w: factor %v of N - 1 = %v - 1 "+
+						"has no certificate",
+					ErrInvalidCertificate, f.P, c.N)
+			}
+			if err := f.Certificate.Verify(); err != nil {
+				return err
+			}
+		}
+
+		if !isPocklingtonBase(c.N, f.P, f.A) {
+			return fmt.Errorf(
+				"%w: %v doesn't work for the factor %v of "+
+					"N - 1 = %v - 1",
+				ErrInvalidCertificate, f.A, f.P, c.N)
+		}
+		F.Mul(F, pk.Exp(f.P, f.K, nil))
+	}
+
+	if r.Rem(nMinusOne, F).Sign() != 0 {
+		return fmt.Errorf(
+			"%w: F = %v doesn't divide N - 1 = %v - 1",
+			ErrInvalidCertificate, F, c.N)
+	}
+	if x.Mul(F, F).Cmp(c.N) <= 0 {
+		return fmt.Errorf(
+			"%w: F = %v isn't above sqrt(N = %v)",
+			ErrInvalidCertificate, F, c.N)
+	}
+	return nil
+}
+
+// Writes c to w as indented JSON.
+func (c *PocklingtonCertificate) WriteJSON(w io.Writer) error {
+	return writeIndentedJSON(w, c)
+}
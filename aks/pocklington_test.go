@@ -0,0 +1,128 @@
+package aks
+
+import "bytes"
+import "encoding/json"
+import "errors"
+import "math/big"
+import "testing"
+
+// Returns the smallest prime of the form 2kq + 1 with k >= 1.
+func findPrimeOneMoreThanMultiple(q *big.Int) *big.Int {
+	var p big.Int
+	for k := int64(1); ; k++ {
+		p.Mul(q, big.NewInt(2*k))
+		p.Add(&p, big.NewInt(1))
+		if p.ProbablyPrime(primalityRounds) {
+			return &p
+		}
+	}
+}
+
+// ProvePocklington should prove primes whose n - 1 factors easily,
+// including ones that need recursive proofs, and its certificates
+// should verify.
+func TestProvePocklington(t *testing.T) {
+	m127 := new(big.Int).Lsh(big.NewInt(1), 127)
+	m127.Sub(m127, big.NewInt(1))
+	// 2(2^127 - 1)k + 1 needs a proof that 2^127 - 1 is prime.
+	p := findPrimeOneMoreThanMultiple(m127)
+	for _, n := range []*big.Int{
+		big.NewInt(2), big.NewInt(1000003), m127, p,
+	} {
+		c, err := ProvePocklington(n)
+		if err != nil {
+			t.Fatal(n, err)
+		}
+		if c.N.Cmp(n) != 0 {
+			t.Error(n, c.N)
+		}
+		if err := c.Verify(); err != nil {
+			t.Error(n, err)
+		}
+	}
+
+	c, err := ProvePocklington(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	last := c.Factors[len(c.Factors)-1]
+	if last.P.Cmp(m127) != 0 || last.Certificate == nil {
+		t.Error(last.P, last.Certificate)
+	}
+}
+
+// ProvePocklington should reject composites and bad input.
+func TestProvePocklingtonErrors(t *testing.T) {
+	f7 := new(big.Int).Lsh(big.NewInt(1), 128)
+	f7.Add(f7, big.NewInt(1))
+	for _, n := range []*big.Int{big.NewInt(561), f7} {
+		if c, err := ProvePocklington(n); !errors.Is(
+			err, ErrComposite) {
+			t.Error(n, c, err)
+		}
+	}
+	for _, n := range []*big.Int{big.NewInt(-1), big.NewInt(1)} {
+		if c, err := ProvePocklington(n); !errors.Is(
+			err, ErrBadInput) {
+			t.Error(n, c, err)
+		}
+	}
+}
+
+// Tampering with a certificate should make it fail verification, and
+// it should survive a round trip through JSON.
+func TestPocklingtonCertificateVerify(t *testing.T) {
+	m127 := new(big.Int).Lsh(big.NewInt(1), 127)
+	m127.Sub(m127, big.NewInt(1))
+	p := findPrimeOneMoreThanMultiple(m127)
+	c, err := ProvePocklington(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := c.WriteJSON(&buf); err != nil {
+		t.Fatal(err)
+	}
+	var decoded PocklingtonCertificate
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if err := decoded.Verify(); err != nil {
+		t.Error(err)
+	}
+
+	tamperings := []func(c *PocklingtonCertificate){
+		func(c *PocklingtonCertificate) { c.N.Add(c.N, big.NewInt(2)) },
+		func(c *PocklingtonCertificate) {
+			c.Factors = c.Factors[:len(c.Factors)-1]
+		},
+		func(c *PocklingtonCertificate) {
+			c.Factors[len(c.Factors)-1].Certificate = nil
+		},
+		func(c *PocklingtonCertificate) {
+			inner := c.Factors[len(c.Factors)-1].Certificate
+			inner.Factors[0].A.SetInt64(1)
+		},
+		func(c *PocklingtonCertificate) {
+			c.Factors[0].K.Add(c.Factors[0].K, big.NewInt(5))
+		},
+		func(c *PocklingtonCertificate) {
+			c.Factors[0].P.SetInt64(4)
+		},
+		func(c *PocklingtonCertificate) {
+			c.N = big.NewInt(1000001)
+		},
+	}
+	for i, tamper := range tamperings {
+		var tampered PocklingtonCertificate
+		if err := json.Unmarshal(buf.Bytes(), &tampered); err != nil {
+			t.Fatal(err)
+		}
+		tamper(&tampered)
+		if err := tampered.Verify(); !errors.Is(
+			err, ErrInvalidCertificate) {
+			t.Error(i, err)
+		}
+	}
+}
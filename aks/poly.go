@@ -0,0 +1,227 @@
+package aks
+
+import "fmt"
+import "math/big"
+
+// A poly is a polynomial mod (N, X^R - 1) as used by the AKS witness
+// test. Each polynomial backend (e.g., bigIntPoly) implements poly,
+// so that isAKSWitness and the witness search work with any of them.
+//
+// Only polys made by the same polyRing may be used together in the
+// methods below. Combining polys mod different N or R with Mul, Eq,
+// or copyFrom panics with an error wrapping ErrBadInput (see
+// panicModulusMismatch), and combining polys of different backends
+// panics too. A poly mustn't be
+// used from multiple goroutines at once, and neither may the
+// temporary polys passed to its methods, which must also not alias
+// it or each other. The exported wrappers (Poly and WitnessTester)
+// take care of this.
+type poly interface {
+	// Sets p to X^k + a mod (N, X^R - 1).
+	Set(a, k, N big.Int)
+	// Sets p to q.
+	copyFrom(q poly)
+	// Returns a new poly equal to p, which may be used with the
+	// same polys as p (i.e., as if it were made by p's polyRing).
+	clone() poly
+	// Returns whether p has the same coefficients as q.
+	Eq(q poly) bool
+	// Sets p to the product of p and q mod (N, X^R - 1). tmp must
+	// not alias p or q.
+	Mul(q poly, N big.Int, tmp poly)
+	// Sets p to p^N mod (N, X^R - 1). tmp1 and tmp2 must not
+	// alias each other or p.
+	Pow(N big.Int, tmp1, tmp2 poly)
+	// Like Pow, but checks done before each squaring and gives up
+	// if it is closed, in which case p is left unchanged and
+	// false is returned. done may be nil, in which case this
+	// never gives up. If onMul is non-nil, it is called after
+	// each multiplication.
+	powCancelable(
+		N big.Int, tmp1, tmp2 poly,
+		done <-chan struct{}, onMul func()) bool
+	// Returns a copy of the coefficient of x^i, where i must be
+	// less than R.
+	coefficient(i int) *big.Int
+	// Sets the coefficient of x^i to c, where i must be less than
+	// R and c must be in [0, N).
+	setCoefficient(i int, c *big.Int)
+	// Returns the number of bytes allocated for p's coefficients.
+	getPhiBytes() int64
+	// Formats p as a polynomial in x, e.g. "x^2 + 3".
+	fmt.Formatter
+}
+
+// A polyRing makes polys mod (N, X^R - 1) for a fixed N and R.
+type polyRing interface {
+	// Returns a new poly representing the zero polynomial.
+	newPoly() poly
+	// Returns what getPhiBytes returns for the polys made by
+	// newPoly, without making one.
+	getPolyBytes() int64
+}
+
+// The most non-zero coefficients the base of Pow may have for the
+// multiplications by the base to be done term by term, which takes
+// O(R) coefficient multiplications per term, instead of with a full
+// product.
+const maxSparseMulTerms = 8
+
+// Returns the degrees of the non-zero coefficients of p, a poly mod
+// (N, X^R - 1), in increasing order, or nil if p is zero or has more
+// than maxSparseMulTerms of them. For the AKS witness test, p is
+// X^n + a, so it has at most two.
+func getSparseDegrees(p poly, R int) []int {
+	var degrees []int
+	for i := 0; i < R; i++ {
+		if p.coefficient(i).Sign() != 0 {
+			if len(degrees) == maxSparseMulTerms {
+				return nil
+			}
+			degrees = append(degrees, i)
+		}
+	}
+	return degrees
+}
+
+// Formats the polynomial whose coefficientCount lowest-degree
+// coefficients are given by getCoefficient (and whose other
+// coefficients are zero) in standard notation, e.g. "x^2 + 3".
+func formatPoly(
+	f fmt.State, coefficientCount int, getCoefficient func(i int) big.Int) {
+	if coefficientCount == 0 {
+		fmt.Fprint(f, "0")
+		return
+	}
+
+	// Formats coeff*x^deg.
+	formatNonZeroMonomial := func(coeff big.Int, deg int) {
+		if coeff.Cmp(big.NewInt(1)) != 0 || deg == 0 {
+			fmt.Fprint(f, &coeff)
+		}
+		if deg != 0 {
+			fmt.Fprint(f, "x")
+			if deg > 1 {
+				fmt.Fprint(f, "^", deg)
+			}
+		}
+	}
+
+	i := coefficientCount - 1
+	formatNonZeroMonomial(getCoefficient(i), i)
+
+	for i--; i >= 0; i-- {
+		coeff := getCoefficient(i)
+		if coeff.Sign() != 0 {
+			fmt.Fprint(f, " + ")
+			formatNonZeroMonomial(coeff, i)
+		}
+	}
+}
+
+// A bigIntPolyRing makes bigIntPolys.
+type bigIntPolyRing struct {
+	N, R big.Int
+	// Shared by all polys made by the ring.
+	reducer    *barrettReducer
+	montgomery *montgomeryReducer
+}
+
+// Makes a bigIntPolyRing for polynomials mod (N, X^R - 1).
+func newBigIntPolyRing(N, R big.Int) *bigIntPolyRing {
+	reducer, montgomery := newBigIntPolyReducers(N, R)
+	return &bigIntPolyRing{N, R, reducer, montgomery}
+}
+
+// polyRing implementation.
+func (r *bigIntPolyRing) newPoly() poly {
+	return newBigIntPolyWithReducers(r.R, r.reducer, r.montgomery)
+}
+
+// polyRing implementation.
+func (r *bigIntPolyRing) getPolyBytes() int64 {
+	// See newBigIntPoly.
+	k := calculateCoefficientWordCount(r.N, r.R)
+	return calculateBigWordBytes(2 * r.R.Int64() * int64(k))
+}
+
+// A wordPolyRing makes wordPolys.
+type wordPolyRing struct {
+	N, R big.Int
+}
+
+// polyRing implementation.
+func (r *wordPolyRing) newPoly() poly {
+	return newWordPoly(r.N, r.R)
+}
+
+// polyRing implementation.
+func (r *wordPolyRing) getPolyBytes() int64 {
+	// See newWordPoly.
+	rInt := int(r.R.Int64())
+	b := calculateWordPolyCoefficientBits(r.N, r.R)
+	return calculateWordPolyBytes(rInt, 2*getPackedWordCount(rInt, b))
+}
+
+// AutoPolyBackend picks WordPolyBackend for n with at most this many
+// bits, and NTTPolyBackend for larger n. In the
+// Benchmark*PolyAKSWitness* benchmarks, wordPoly is the fastest
+// backend up to 36 bits, and nttPoly is from 38 bits on, and faster
+// still than wordPoly as n grows (about 2x at 63 bits). bigIntPoly is
+// never the fastest (1.2x to 3x slower than the faster of the two),
+// and neither is mpzPoly (25x to 75x slower than nttPoly from 20 to
+// 64 bits, and getting worse, since it multiplies in O(R^2)).
+const autoWordPolyMaxBits = 37
+
+// Returns the backend that AutoPolyBackend picks for n and r. Only n
+// matters, since r grows with n.
+func pickPolyBackend(n, r *big.Int) PolyBackend {
+	if n.BitLen() <= autoWordPolyMaxBits {
+		return WordPolyBackend
+	}
+	return NTTPolyBackend
+}
+
+// Returns the polyRing for the given backend to use for the AKS
+// witness test of n with parameter r, or an error if the backend
+// can't handle n. r must fit into an int.
+func newPolyRing(n, r *big.Int, backend PolyBackend) (polyRing, error) {
+	if backend == AutoPolyBackend {
+		backend = pickPolyBackend(n, r)
+	}
+	switch backend {
+	case BigIntPolyBackend:
+		return newBigIntPolyRing(*n, *r), nil
+	case WordPolyBackend:
+		if !fitsInWord(n) {
+			return nil, fmt.Errorf(
+				"%w: n = %v is too large for the %v backend",
+				ErrBadInput, n, backend)
+		}
+		return &wordPolyRing{*n, *r}, nil
+	case NTTPolyBackend:
+		return newNTTPolyRing(*n, *r)
+	case MPZPolyBackend:
+		return newMPZPolyRing(*n, *r)
+	case FLINTPolyBackend:
+		return newFLINTPolyRing(*n, *r)
+	case OpenCLPolyBackend:
+		return newOpenCLPolyRing(*n, *r)
+	}
+	return nil, fmt.Errorf("%w: unknown backend %v", ErrBadInput, backend)
+}
+
+// Panics with an error wrapping ErrBadInput saying that a poly mod
+// (pN, X^pR - 1) can't be combined by op with one mod (qN, X^qR - 1).
+// Each backend calls this when its polys are combined with ones made
+// for a different N or R, which would otherwise silently produce
+// garbage.
+func panicModulusMismatch(op string, pN *big.Int, pR int, qN *big.Int, qR int) {
+	panic(fmt.Errorf(
+		"%w: cannot %s a poly mod (%v, X^%d - 1) with one mod "+
+			"(%v, X^%d - 1)", ErrBadInput, op, pN, pR, qN, qR))
+}
+
+var _ poly = (*bigIntPoly)(nil)
+var _ poly = (*wordPoly)(nil)
+var _ poly = (*nttPoly)(nil)
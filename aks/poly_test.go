@@ -0,0 +1,377 @@
+package aks
+
+import "context"
+import "errors"
+import "fmt"
+import "math/big"
+import "testing"
+
+// Runs checks that every polyRing should pass, using makeRing to
+// make rings for a few (N, R).
+func checkPolyRing(t *testing.T, makeRing func(N, R big.Int) polyRing) {
+	N := *big.NewInt(101)
+	R := *big.NewInt(53)
+	ring := makeRing(N, R)
+
+	p := ring.newPoly()
+	if str := fmt.Sprint(p); str != "0" {
+		t.Error(str)
+	}
+
+	// (x + 2)^2 = x^2 + 4x + 4.
+	p.Set(*big.NewInt(2), *big.NewInt(1), N)
+	q := ring.newPoly()
+	q.Set(*big.NewInt(2), *big.NewInt(1), N)
+	tmp := ring.newPoly()
+	p.Mul(q, N, tmp)
+	if str := fmt.Sprint(p); str != "x^2 + 4x + 4" {
+		t.Error(str)
+	}
+	for i, c := range []int64{4, 4, 1, 0} {
+		if coeff := p.coefficient(i); coeff.Cmp(big.NewInt(c)) != 0 {
+			t.Error(i, coeff)
+		}
+	}
+
+	// setCoefficient should be able to raise and lower the
+	// degree.
+	p.Set(*big.NewInt(2), *big.NewInt(1), N)
+	p.setCoefficient(5, big.NewInt(100))
+	p.setCoefficient(0, big.NewInt(0))
+	if str := fmt.Sprint(p); str != "100x^5 + x" {
+		t.Error(str)
+	}
+	p.setCoefficient(5, big.NewInt(0))
+	if str := fmt.Sprint(p); str != "x" {
+		t.Error(str)
+	}
+	p.Mul(p, N, tmp)
+	if str := fmt.Sprint(p); str != "x^2" {
+		t.Error(str)
+	}
+
+	// Since N is prime, (x + a)^N = x^N + a mod (N, x^R - 1).
+	tmp2 := ring.newPoly()
+	p.Set(*big.NewInt(2), *big.NewInt(1), N)
+	p.Pow(N, tmp, tmp2)
+	q.Set(*big.NewInt(2), N, N)
+	if !p.Eq(q) {
+		t.Error(p, q)
+	}
+
+	// A clone should equal p, have at least the capacity of a
+	// new poly, and be independent of p, and copyFrom should make
+	// p equal to it again.
+	clone := p.clone()
+	if !clone.Eq(p) {
+		t.Error(p, clone)
+	}
+	if clone.getPhiBytes() < ring.getPolyBytes() {
+		t.Error(clone.getPhiBytes(), ring.getPolyBytes())
+	}
+	p.Mul(q, N, tmp)
+	if clone.Eq(p) || !clone.Eq(q) {
+		t.Error(p, clone)
+	}
+	clone.Mul(clone, N, tmp)
+	if !clone.Eq(p) {
+		t.Error(p, clone)
+	}
+	p.copyFrom(q)
+	if !p.Eq(q) {
+		t.Error(p, q)
+	}
+
+	// Polys from another ring with the same N and R may be
+	// combined with p, but ones with a different N or R may not.
+	other := makeRing(N, R).newPoly()
+	other.copyFrom(p)
+	if !other.Eq(p) {
+		t.Error(p, other)
+	}
+	p.Mul(other, N, tmp)
+	for _, mismatched := range []poly{
+		makeRing(*big.NewInt(103), R).newPoly(),
+		makeRing(N, *big.NewInt(59)).newPoly(),
+	} {
+		for name, op := range map[string]func(){
+			"Mul":      func() { p.Mul(mismatched, N, tmp) },
+			"Eq":       func() { p.Eq(mismatched) },
+			"copyFrom": func() { p.copyFrom(mismatched) },
+		} {
+			func() {
+				defer func() {
+					err, _ := recover().(error)
+					if !errors.Is(err, ErrBadInput) {
+						t.Error(name, mismatched, err)
+					}
+				}()
+				op()
+			}()
+		}
+	}
+
+	// But not for the composite 1961 = 37 * 53 with R = 5.
+	N = *big.NewInt(1961)
+	R = *big.NewInt(5)
+	ring = makeRing(N, R)
+	p = ring.newPoly()
+	q = ring.newPoly()
+	tmp = ring.newPoly()
+	if !isAKSWitness(N, *big.NewInt(1), p, q, tmp) {
+		t.Error(p)
+	}
+	if p.getPhiBytes() <= 0 {
+		t.Error(p.getPhiBytes())
+	}
+}
+
+// bigIntPolyRing should pass checkPolyRing.
+func TestBigIntPolyRing(t *testing.T) {
+	checkPolyRing(t, func(N, R big.Int) polyRing {
+		return newBigIntPolyRing(N, R)
+	})
+}
+
+// wordPolyRing should pass checkPolyRing.
+func TestWordPolyRing(t *testing.T) {
+	checkPolyRing(t, func(N, R big.Int) polyRing {
+		return &wordPolyRing{N, R}
+	})
+}
+
+// nttPolyRing should pass checkPolyRing.
+func TestNTTPolyRing(t *testing.T) {
+	checkPolyRing(t, func(N, R big.Int) polyRing {
+		ring, err := newNTTPolyRing(N, R)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return ring
+	})
+}
+
+// Each ring's getPolyBytes should match what its polys allocate.
+func TestGetPolyBytes(t *testing.T) {
+	n := big.NewInt(4294967291)
+	r := big.NewInt(1031)
+	nttRing, err := newNTTPolyRing(*n, *r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, ring := range []polyRing{
+		newBigIntPolyRing(*n, *r), &wordPolyRing{*n, *r}, nttRing,
+	} {
+		p := ring.newPoly()
+		if ring.getPolyBytes() != p.getPhiBytes() {
+			t.Error(ring.getPolyBytes(), p.getPhiBytes())
+		}
+	}
+}
+
+// newPolyRing should pick wordPoly for n < 2^37 and nttPoly
+// otherwise, unless overridden.
+func TestNewPolyRing(t *testing.T) {
+	// The largest prime less than 2^64, and the smallest prime
+	// greater than 2^64.
+	small, _ := new(big.Int).SetString("18446744073709551557", 10)
+	large, _ := new(big.Int).SetString("18446744073709551629", 10)
+	r := big.NewInt(1031)
+	tests := []struct {
+		n       *big.Int
+		backend PolyBackend
+		ring    polyRing
+	}{
+		{big.NewInt(1000003), AutoPolyBackend, &wordPolyRing{}},
+		{big.NewInt(1<<37 - 25), AutoPolyBackend, &wordPolyRing{}},
+		{big.NewInt(1<<37 + 9), AutoPolyBackend, &nttPolyRing{}},
+		{small, AutoPolyBackend, &nttPolyRing{}},
+		{large, AutoPolyBackend, &nttPolyRing{}},
+		{small, BigIntPolyBackend, &bigIntPolyRing{}},
+		{large, BigIntPolyBackend, &bigIntPolyRing{}},
+		{small, WordPolyBackend, &wordPolyRing{}},
+		{large, NTTPolyBackend, &nttPolyRing{}},
+	}
+	for _, test := range tests {
+		ring, err := newPolyRing(test.n, r, test.backend)
+		if err != nil ||
+			fmt.Sprintf("%T", ring) != fmt.Sprintf("%T", test.ring) {
+			t.Errorf("%v %v %T %v", test.n, test.backend, ring, err)
+		}
+	}
+
+	for _, backend := range []PolyBackend{WordPolyBackend, 7} {
+		ring, err := newPolyRing(large, r, backend)
+		if !errors.Is(err, ErrBadInput) {
+			t.Error(backend, ring, err)
+		}
+	}
+}
+
+// newPolyRing should succeed for each available backend, and fail
+// with ErrBackendUnavailable for the others.
+func TestPolyBackendAvailability(t *testing.T) {
+	available := AvailablePolyBackends()
+	if len(available) < 4 {
+		t.Error(available)
+	}
+	n := big.NewInt(1000003)
+	r := big.NewInt(431)
+	for _, backend := range []PolyBackend{
+		AutoPolyBackend, BigIntPolyBackend, WordPolyBackend,
+		NTTPolyBackend, MPZPolyBackend, FLINTPolyBackend,
+		OpenCLPolyBackend,
+	} {
+		_, err := newPolyRing(n, r, backend)
+		if backend.IsAvailable() {
+			if err != nil {
+				t.Error(backend, err)
+			}
+		} else if !errors.Is(err, ErrBackendUnavailable) {
+			t.Error(backend, err)
+		}
+	}
+	if PolyBackend(7).IsAvailable() {
+		t.Error("unknown backend is available")
+	}
+}
+
+// RunAKS should reach the same verdict with each backend.
+func TestRunAKSBackends(t *testing.T) {
+	for _, backend := range []PolyBackend{
+		AutoPolyBackend, BigIntPolyBackend, WordPolyBackend,
+		NTTPolyBackend,
+	} {
+		for _, n := range []int64{1000003, 2993374621} {
+			result, err := RunAKS(
+				context.Background(), big.NewInt(n),
+				&AKSOptions{Backend: backend})
+			if err != nil {
+				t.Fatal(backend, n, err)
+			}
+			expected := Prime
+			if n == 2993374621 {
+				expected = Composite
+			}
+			if result.Verdict != expected {
+				t.Error(backend, n, result.Verdict)
+			}
+		}
+	}
+}
+
+// Pow should give the same result as repeated multiplication for
+// bases sparse enough to be multiplied by term by term and for dense
+// ones, with odd and even N.
+func TestPowSparseBase(t *testing.T) {
+	R := big.NewInt(53)
+	for _, N := range []*big.Int{big.NewInt(1000003), big.NewInt(1000000)} {
+		for _, backend := range []PolyBackend{
+			BigIntPolyBackend, WordPolyBackend, NTTPolyBackend,
+		} {
+			ring, err := newPolyRing(N, R, backend)
+			if err != nil {
+				t.Fatal(backend, err)
+			}
+			for _, termCount := range []int{1, 2, 3, maxSparseMulTerms, 20} {
+				p := ring.newPoly()
+				for i := 0; i < termCount; i++ {
+					p.setCoefficient(
+						(i*7+1)%53, big.NewInt(int64(i*12345+6)))
+				}
+				tmp := ring.newPoly()
+				expected := p.clone()
+				for i := int64(1); i < 37; i++ {
+					expected.Mul(p, *N, tmp)
+				}
+				p.Pow(*big.NewInt(37), tmp, ring.newPoly())
+				if !p.Eq(expected) {
+					t.Errorf("%v %v %d %v %v",
+						N, backend, termCount, p, expected)
+				}
+			}
+		}
+	}
+}
+
+// Benchmarks isAKSWitness with the given backend for the first prime
+// with the given number of bits. These are what AutoPolyBackend's
+// choices are based on.
+func runPolyBackendBenchmark(b *testing.B, backend PolyBackend, bits uint) {
+	b.StopTimer()
+	n := new(big.Int).Lsh(big.NewInt(1), bits)
+	for !n.ProbablyPrime(_NUM_PROBABLY_PRIME_ROUNDS) {
+		n.Add(n, big.NewInt(1))
+	}
+	r := mustCalculateAKSModulus(n, b)
+	ring, err := newPolyRing(n, r, backend)
+	if err != nil {
+		b.Fatal(err)
+	}
+	tmp1 := ring.newPoly()
+	tmp2 := ring.newPoly()
+	tmp3 := ring.newPoly()
+	// Any a > 1 suffices.
+	a := big.NewInt(2)
+
+	b.StartTimer()
+	for i := 0; i < b.N; i++ {
+		isAKSWitness(*n, *a, tmp1, tmp2, tmp3)
+	}
+}
+
+func BenchmarkBigIntPolyAKSWitness20Bits(b *testing.B) {
+	runPolyBackendBenchmark(b, BigIntPolyBackend, 20)
+}
+
+func BenchmarkWordPolyAKSWitness20Bits(b *testing.B) {
+	runPolyBackendBenchmark(b, WordPolyBackend, 20)
+}
+
+func BenchmarkNTTPolyAKSWitness20Bits(b *testing.B) {
+	runPolyBackendBenchmark(b, NTTPolyBackend, 20)
+}
+
+func BenchmarkBigIntPolyAKSWitness36Bits(b *testing.B) {
+	runPolyBackendBenchmark(b, BigIntPolyBackend, 36)
+}
+
+func BenchmarkWordPolyAKSWitness36Bits(b *testing.B) {
+	runPolyBackendBenchmark(b, WordPolyBackend, 36)
+}
+
+func BenchmarkNTTPolyAKSWitness36Bits(b *testing.B) {
+	runPolyBackendBenchmark(b, NTTPolyBackend, 36)
+}
+
+func BenchmarkBigIntPolyAKSWitness38Bits(b *testing.B) {
+	runPolyBackendBenchmark(b, BigIntPolyBackend, 38)
+}
+
+func BenchmarkWordPolyAKSWitness38Bits(b *testing.B) {
+	runPolyBackendBenchmark(b, WordPolyBackend, 38)
+}
+
+func BenchmarkNTTPolyAKSWitness38Bits(b *testing.B) {
+	runPolyBackendBenchmark(b, NTTPolyBackend, 38)
+}
+
+func BenchmarkBigIntPolyAKSWitness63Bits(b *testing.B) {
+	runPolyBackendBenchmark(b, BigIntPolyBackend, 63)
+}
+
+func BenchmarkWordPolyAKSWitness63Bits(b *testing.B) {
+	runPolyBackendBenchmark(b, WordPolyBackend, 63)
+}
+
+func BenchmarkNTTPolyAKSWitness63Bits(b *testing.B) {
+	runPolyBackendBenchmark(b, NTTPolyBackend, 63)
+}
+
+func BenchmarkBigIntPolyAKSWitness80Bits(b *testing.B) {
+	runPolyBackendBenchmark(b, BigIntPolyBackend, 80)
+}
+
+func BenchmarkNTTPolyAKSWitness80Bits(b *testing.B) {
+	runPolyBackendBenchmark(b, NTTPolyBackend, 80)
+}
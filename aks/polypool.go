@@ -0,0 +1,129 @@
+package aks
+
+import "math/big"
+import "sync"
+
+// A polyPool is a polyRing that reuses the polys made by another
+// polyRing, so that testing many witnesses (or calling IsAKSWitness
+// many times) doesn't allocate new polys each time. The polys it
+// returns have arbitrary values.
+type polyPool struct {
+	ring polyRing
+	pool sync.Pool
+}
+
+// polyRing implementation.
+func (p *polyPool) newPoly() poly {
+	if q := p.pool.Get(); q != nil {
+		return q.(poly)
+	}
+	return p.ring.newPoly()
+}
+
+// polyRing implementation.
+func (p *polyPool) getPolyBytes() int64 {
+	return p.ring.getPolyBytes()
+}
+
+// Returns q, which must have been made by p, to p so that it can be
+// reused. q must not be used afterwards.
+func (p *polyPool) put(q poly) {
+	p.pool.Put(q)
+}
+
+// Sets x to x*y mod (N, X^R - 1), with a pooled poly as scratch
+// space. Unlike with poly.Mul, there are no restrictions on aliasing,
+// i.e. x and y may be the same poly.
+func (p *polyPool) mul(x, y poly, N big.Int) {
+	tmp := p.newPoly()
+	defer p.put(tmp)
+	x.Mul(y, N, tmp)
+}
+
+// Sets x to x^N mod (N, X^R - 1), with pooled polys as scratch space.
+func (p *polyPool) pow(x poly, N big.Int) {
+	p.powCancelable(x, N, nil, nil)
+}
+
+// Like pow, but like poly.powCancelable.
+func (p *polyPool) powCancelable(
+	x poly, N big.Int, done <-chan struct{}, onMul func()) bool {
+	tmp1 := p.newPoly()
+	tmp2 := p.newPoly()
+	defer p.put(tmp1)
+	defer p.put(tmp2)
+	return x.powCancelable(N, tmp1, tmp2, done, onMul)
+}
+
+// Identifies the polyPool for polys mod (n, X^r - 1) made by a
+// backend.
+type polyPoolKey struct {
+	n, r    string
+	backend PolyBackend
+}
+
+// The most polyPools that polyPools keeps. Since the polys for large
+// n and r take a lot of memory, only a few are kept.
+const maxPolyPools = 8
+
+// Holds the most recently used polyPools, so that they're shared by
+// all AKSParams (and so all API calls) for the same n, r, and
+// backend.
+var polyPools struct {
+	sync.Mutex
+	pools map[polyPoolKey]*polyPool
+	// The keys of pools, least recently used first.
+	keys []polyPoolKey
+}
+
+// Returns the polyPool for polys mod (n, X^r - 1) made by backend,
+// which must not be AutoPolyBackend, making it if necessary. The ring
+// is made without holding polyPools' lock, since that can take a
+// while (e.g., finding NTT primes or compiling OpenCL kernels).
+func getPolyPool(n, r *big.Int, backend PolyBackend) (*polyPool, error) {
+	key := polyPoolKey{n.String(), r.String(), backend}
+	polyPools.Lock()
+	pool := findPolyPoolLocked(key)
+	polyPools.Unlock()
+	if pool != nil {
+		return pool, nil
+	}
+
+	ring, err := newPolyRing(n, r, backend)
+	if err != nil {
+		return nil, err
+	}
+
+	polyPools.Lock()
+	defer polyPools.Unlock()
+	// Another goroutine may have made a pool for key in the
+	// meantime, in which case use that one instead.
+	if pool := findPolyPoolLocked(key); pool != nil {
+		return pool, nil
+	}
+	pool = &polyPool{ring: ring}
+	if polyPools.pools == nil {
+		polyPools.pools = make(map[polyPoolKey]*polyPool)
+	}
+	if len(polyPools.keys) >= maxPolyPools {
+		delete(polyPools.pools, polyPools.keys[0])
+		polyPools.keys = polyPools.keys[1:]
+	}
+	polyPools.pools[key] = pool
+	polyPools.keys = append(polyPools.keys, key)
+	return pool, nil
+}
+
+// Returns the polyPool for key, marking it as the most recently used,
+// or nil if there isn't one. polyPools must be locked.
+func findPolyPoolLocked(key polyPoolKey) *polyPool {
+	for i, k := range polyPools.keys {
+		if k == key {
+			// Move key to the end.
+			copy(polyPools.keys[i:], polyPools.keys[i+1:])
+			polyPools.keys[len(polyPools.keys)-1] = key
+			return polyPools.pools[key]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+package aks
+
+import "math/big"
+import "sync"
+import "testing"
+
+// polyPool.mul and polyPool.pow should match poly.Mul and poly.Pow,
+// even when mul's arguments alias.
+func TestPolyPoolMulPow(t *testing.T) {
+	N := *big.NewInt(1961)
+	R := *big.NewInt(5)
+	for _, backend := range []PolyBackend{
+		BigIntPolyBackend, WordPolyBackend, NTTPolyBackend,
+	} {
+		pool, err := getPolyPool(&N, &R, backend)
+		if err != nil {
+			t.Fatal(backend, err)
+		}
+		p := pool.newPoly()
+		q := pool.newPoly()
+		tmp1 := pool.newPoly()
+		tmp2 := pool.newPoly()
+
+		p.Set(*big.NewInt(3), *big.NewInt(1), N)
+		q.Set(*big.NewInt(3), *big.NewInt(1), N)
+		pool.mul(p, p, N)
+		q.Mul(q, N, tmp1)
+		if !p.Eq(q) {
+			t.Errorf("%v %v %v", backend, p, q)
+		}
+
+		p.Set(*big.NewInt(3), *big.NewInt(1), N)
+		q.Set(*big.NewInt(3), *big.NewInt(1), N)
+		pool.pow(p, N)
+		q.Pow(N, tmp1, tmp2)
+		if !p.Eq(q) {
+			t.Errorf("%v %v %v", backend, p, q)
+		}
+	}
+}
+
+// Concurrent calls to getPolyPool for the same n, r, and backend
+// should all get the same polyPool, even though each may make its own
+// ring.
+func TestGetPolyPoolConcurrent(t *testing.T) {
+	N := big.NewInt(1000033)
+	R := big.NewInt(7)
+	pools := make([]*polyPool, 8)
+	var wg sync.WaitGroup
+	for i := range pools {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			pool, err := getPolyPool(N, R, NTTPolyBackend)
+			if err != nil {
+				t.Error(err)
+			}
+			pools[i] = pool
+		}(i)
+	}
+	wg.Wait()
+	for _, pool := range pools {
+		if pool == nil || pool != pools[0] {
+			t.Error(pool, pools[0])
+		}
+	}
+}
@@ -0,0 +1,397 @@
+package aks
+
+import "context"
+import "fmt"
+import "io/ioutil"
+import "log"
+import "math/big"
+import "time"
+
+// A Verdict is the conclusion reached about the primality of a
+// number.
+type Verdict int
+
+const (
+	// The number may be prime or composite; this happens when
+	// only part of the AKS witness range was searched.
+	Undetermined Verdict = iota
+	Prime
+	Composite
+)
+
+// fmt.Stringer implementation.
+func (v Verdict) String() string {
+	switch v {
+	case Undetermined:
+		return "undetermined"
+	case Prime:
+		return "prime"
+	case Composite:
+		return "composite"
+	}
+	return fmt.Sprintf("Verdict(%d)", int(v))
+}
+
+// Returns the Verdict with the given string representation.
+func parseVerdict(s string) (Verdict, error) {
+	for v := Undetermined; v <= Composite; v++ {
+		if v.String() == s {
+			return v, nil
+		}
+	}
+	return Undetermined, fmt.Errorf("%w: unknown verdict %q", ErrBadInput, s)
+}
+
+// encoding.TextMarshaler implementation.
+func (v Verdict) MarshalText() ([]byte, error) {
+	return []byte(v.String()), nil
+}
+
+// encoding.TextUnmarshaler implementation.
+func (v *Verdict) UnmarshalText(text []byte) error {
+	parsed, err := parseVerdict(string(text))
+	if err != nil {
+		return err
+	}
+	*v = parsed
+	return nil
+}
+
+// A Method is the way a Verdict was reached.
+type Method int
+
+const (
+	// A factor of n less than the trial division bound (usually
+	// M) was found.
+	MethodTrialDivision Method = iota
+	// n has no factor less than B and B > sqrt(n), where B is
+	// the trial division bound (usually M).
+	MethodSqrtBound
+	// The AKS witnesses in [Start, End) were searched.
+	MethodAKS
+	// Like MethodAKS, but with AKSVariantConjecture, so a Prime
+	// verdict is only heuristic: it assumes the unproven AKS
+	// conjecture.
+	MethodAKSConjecture
+	// A factor of n was found with ECM; see AKSOptions.ECM.
+	MethodECM
+	// n failed the Baillie-PSW test, which proves it composite
+	// without giving a factor or an AKS witness; see
+	// AKSOptions.SkipBPSW.
+	MethodBPSW
+)
+
+// fmt.Stringer implementation.
+func (m Method) String() string {
+	switch m {
+	case MethodTrialDivision:
+		return "trial division"
+	case MethodSqrtBound:
+		return "M > sqrt(n)"
+	case MethodAKS:
+		return "AKS witness search"
+	case MethodAKSConjecture:
+		return "AKS conjecture (heuristic)"
+	case MethodECM:
+		return "ECM"
+	case MethodBPSW:
+		return "Baillie-PSW"
+	}
+	return fmt.Sprintf("Method(%d)", int(m))
+}
+
+// Returns the Method with the given string representation.
+func parseMethod(s string) (Method, error) {
+	for m := MethodTrialDivision; m <= MethodBPSW; m++ {
+		if m.String() == s {
+			return m, nil
+		}
+	}
+	return MethodTrialDivision, fmt.Errorf(
+		"%w: unknown method %q", ErrBadInput, s)
+}
+
+// encoding.TextMarshaler implementation.
+func (m Method) MarshalText() ([]byte, error) {
+	return []byte(m.String()), nil
+}
+
+// encoding.TextUnmarshaler implementation.
+func (m *Method) UnmarshalText(text []byte) error {
+	parsed, err := parseMethod(string(text))
+	if err != nil {
+		return err
+	}
+	*m = parsed
+	return nil
+}
+
+// Holds everything determined about n during a primality test.
+type PrimalityResult struct {
+	N       *big.Int
+	Verdict Verdict
+	Method  Method
+	// The AKS modulus and upper bound for n.
+	R, M *big.Int
+	// The range [Start, End) of numbers that were (to be)
+	// searched for AKS witnesses.
+	Start, End *big.Int
+	// Trial division was done for factors less than
+	// TrialDivisionBound.
+	TrialDivisionBound *big.Int
+	// The factor of n found by trial division (or ECM, if Method
+	// is MethodECM), if any.
+	Factor *big.Int
+	// The AKS witness of n found, if any.
+	Witness *big.Int
+	// The ranges of numbers known not to be AKS witnesses
+	// (including those from AKSOptions.Resume), in ascending
+	// order, if an AKS witness search was done. Along with N and
+	// R, this is enough to resume a cancelled search.
+	Completed []Range
+	// Statistics about the AKS witness search, if one was done.
+	Stats *Stats
+	// A record of the run, if requested with
+	// AKSOptions.GenerateCertificate.
+	Certificate *Certificate
+}
+
+// Determines whether n >= 2 is prime, first with trial division and
+// then by searching for AKS witnesses as configured by opts, which
+// may be nil. Returns the partial result along with ctx.Err() if ctx
+// is cancelled before the search completes, or a nil result and an
+// error wrapping ErrBadInput if n < 2.
+func RunAKS(
+	ctx context.Context,
+	n *big.Int,
+	opts *AKSOptions) (*PrimalityResult, error) {
+	o := opts.withDefaults()
+	result, completed, err := runAKS(ctx, n, &o)
+	if err == nil && o.GenerateCertificate {
+		result.Certificate = newCertificate(result, completed)
+	}
+	return result, err
+}
+
+// Does the work for RunAKS, given options with defaults filled
+// in. Also returns the set of numbers known not to be AKS witnesses.
+func runAKS(
+	ctx context.Context,
+	n *big.Int,
+	o *AKSOptions) (*PrimalityResult, *rangeSet, error) {
+	one := big.NewInt(1)
+	setPhase := func(phase Phase) {
+		if o.Verbosity >= VerbosityNormal {
+			o.Logger.Printf("Phase: %v\n", phase)
+		}
+		if o.Observer != nil {
+			o.Observer.OnPhaseChange(phase)
+		}
+	}
+	defer setPhase(PhaseDone)
+
+	setPhase(PhaseParameters)
+	r, M, err := calculateAKSParams(n, o)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	prog, err := newProgression(o.Stride, o.Offset)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Keep track of the numbers known not to be AKS witnesses,
+	// which is needed for checkpoints and certificates anyway,
+	// so we know whether the search covered all of the numbers
+	// that need to be tested.
+	completed := &rangeSet{}
+	if o.Resume != nil {
+		completed, err = o.Resume.completedSet(n, r)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	// The numbers that must be found not to be AKS witnesses for
+	// n to be prime: [1, M), or just n - 1 under the AKS
+	// conjecture.
+	witnessStart, witnessEnd := one, M
+	method := MethodAKS
+	if o.Variant == AKSVariantConjecture {
+		witnessStart = new(big.Int).Sub(n, one)
+		witnessEnd = n
+		method = MethodAKSConjecture
+	}
+
+	result := &PrimalityResult{
+		N:     n,
+		R:     r,
+		M:     M,
+		Start: &big.Int{},
+		End:   &big.Int{},
+	}
+	if o.Start != nil {
+		result.Start.Set(o.Start)
+	}
+	if result.Start.Cmp(one) < 0 {
+		result.Start.Set(witnessStart)
+	}
+	if o.End != nil {
+		result.End.Set(o.End)
+	}
+	if result.End.Sign() <= 0 {
+		result.End.Set(witnessEnd)
+	}
+	result.TrialDivisionBound = M
+	if o.TrialDivisionBound != nil {
+		result.TrialDivisionBound = max(o.TrialDivisionBound, M)
+	}
+
+	setPhase(PhaseTrialDivision)
+	result.Factor, err = GetFirstFactorBelow(n, result.TrialDivisionBound)
+	if err != nil {
+		return nil, nil, err
+	}
+	if result.Factor != nil {
+		result.Verdict = Composite
+		result.Method = MethodTrialDivision
+		return result, completed, nil
+	}
+
+	// B^2 > N iff B > floor(sqrt(N)).
+	var bSq big.Int
+	bSq.Mul(result.TrialDivisionBound, result.TrialDivisionBound)
+	if bSq.Cmp(n) > 0 {
+		result.Verdict = Prime
+		result.Method = MethodSqrtBound
+		return result, completed, nil
+	}
+
+	if o.ECM != nil && !n.ProbablyPrime(primalityRounds) {
+		ecmOpts := o.ECM.withDefaults()
+		result.Factor, err = ecm(ctx, n, &ecmOpts)
+		if err != nil {
+			return result, completed, err
+		}
+		if result.Factor != nil {
+			result.Verdict = Composite
+			result.Method = MethodECM
+			return result, completed, nil
+		}
+	}
+
+	if !o.SkipBPSW {
+		isPrime, err := BailliePSW(n)
+		if err != nil {
+			return nil, nil, err
+		}
+		if !isPrime {
+			result.Verdict = Composite
+			result.Method = MethodBPSW
+			return result, completed, nil
+		}
+	}
+
+	setPhase(PhaseWitnessSearch)
+	result.Method = method
+	chain, err := o.getAdditionChain(n)
+	if err != nil {
+		return result, completed, err
+	}
+	stats := newStatsCollector(n, chain)
+	a, err := searchAKSWitnesses(
+		ctx, n, r, result.Start, result.End, prog, o, completed, stats)
+	result.Stats = stats.getStats()
+	result.Completed = completed.getRanges()
+	if err != nil {
+		return result, completed, err
+	}
+	result.Witness = a
+	if a != nil {
+		result.Verdict = Composite
+	} else if completed.containsRange(witnessStart, witnessEnd) {
+		result.Verdict = Prime
+	} else {
+		result.Verdict = Undetermined
+	}
+	return result, completed, nil
+}
+
+// Searches the members of prog in [start, end) for AKS witnesses of
+// n with parameter r as configured by o, skipping and adding to the
+// numbers in completed, and saving checkpoints if requested.
+// Accumulates statistics in stats.
+func searchAKSWitnesses(
+	ctx context.Context,
+	n, r, start, end *big.Int,
+	prog progression,
+	o *AKSOptions,
+	completed *rangeSet,
+	stats *statsCollector) (*big.Int, error) {
+	if err := checkAKSWitnessArgs(n, r, o.Jobs); err != nil {
+		return nil, err
+	}
+	params, err := NewAKSParams(n, r, o)
+	if err != nil {
+		return nil, err
+	}
+	s := witnessSearch{
+		params:     params,
+		start:      start,
+		end:        end,
+		prog:       prog,
+		order:      o.WitnessOrder,
+		seed:       o.WitnessOrderSeed,
+		primesOnly: o.PrimeWitnessesOnly,
+		jobs:       o.Jobs,
+		logger:     o.Logger,
+		completed:  completed,
+		observer:   o.Observer,
+		stats:      stats,
+		yield:      o.Yield,
+	}
+	if o.Verbosity < VerbosityVerbose {
+		s.logger = log.New(ioutil.Discard, "", 0)
+	}
+	if o.Verbosity >= VerbosityDebug {
+		s.debugLogger = o.Logger
+	}
+	if len(o.CheckpointPath) == 0 {
+		return s.run(ctx)
+	}
+
+	saveCheckpoint := func() error {
+		c := Checkpoint{n, r, s.completed.getRanges()}
+		return c.Save(o.CheckpointPath)
+	}
+	lastSave := time.Now()
+	s.onResult = func(result witnessResult) {
+		if time.Since(lastSave) < o.CheckpointInterval {
+			return
+		}
+		if err := saveCheckpoint(); err != nil {
+			o.Logger.Printf("Could not save checkpoint: %v\n", err)
+		}
+		lastSave = time.Now()
+	}
+	a, err := s.run(ctx)
+	if saveErr := saveCheckpoint(); saveErr != nil && err == nil {
+		err = saveErr
+	}
+	return a, err
+}
+
+// Like RunAKS, but with the options passed positionally.
+func CheckPrimality(
+	ctx context.Context,
+	n, start, end *big.Int,
+	jobs int,
+	logger *log.Logger) (*PrimalityResult, error) {
+	return RunAKS(ctx, n, &AKSOptions{
+		Jobs:   jobs,
+		Start:  start,
+		End:    end,
+		Logger: logger,
+	})
+}
@@ -0,0 +1,234 @@
+package aks
+
+import "bytes"
+import "context"
+import "errors"
+import "log"
+import "math/big"
+import "strings"
+import "testing"
+import "time"
+
+// Runs CheckPrimality on n over the full witness range and checks the
+// verdict and method.
+func testCheckPrimality(
+	n *big.Int, expectedVerdict Verdict, expectedMethod Method,
+	t *testing.T) *PrimalityResult {
+	result, err := CheckPrimality(
+		context.Background(), n, nil, nil, 2, nullLogger)
+	if err != nil {
+		t.Fatal(n, err)
+	}
+	if result.Verdict != expectedVerdict {
+		t.Error(n, result.Verdict, expectedVerdict)
+	}
+	if result.Method != expectedMethod {
+		t.Error(n, result.Method, expectedMethod)
+	}
+	return result
+}
+
+// Small composites should be caught by trial division.
+func TestCheckPrimalityTrialDivision(t *testing.T) {
+	result := testCheckPrimality(
+		big.NewInt(1961), Composite, MethodTrialDivision, t)
+	if result.Factor == nil || result.Factor.Cmp(big.NewInt(37)) != 0 {
+		t.Error(result.Factor)
+	}
+}
+
+// Small primes should be caught by the M > sqrt(n) check.
+func TestCheckPrimalitySqrtBound(t *testing.T) {
+	testCheckPrimality(big.NewInt(101), Prime, MethodSqrtBound, t)
+}
+
+// Composites with no small factors should fail the Baillie-PSW test.
+func TestCheckPrimalityBPSW(t *testing.T) {
+	result := testCheckPrimality(
+		big.NewInt(2993374621), Composite, MethodBPSW, t)
+	if result.Witness != nil || result.Factor != nil {
+		t.Error(result.Witness, result.Factor)
+	}
+}
+
+// Composites with no small factors should have an AKS witness.
+func TestCheckPrimalityAKSWitness(t *testing.T) {
+	n := big.NewInt(2993374621)
+	result, err := RunAKS(
+		context.Background(), n, &AKSOptions{SkipBPSW: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Verdict != Composite || result.Method != MethodAKS ||
+		result.Witness == nil {
+		t.Error(result.Verdict, result.Method, result.Witness)
+	}
+}
+
+// Searching only part of the witness range of a prime should be
+// inconclusive.
+func TestCheckPrimalityUndetermined(t *testing.T) {
+	n := big.NewInt(2685241991)
+	result, err := CheckPrimality(
+		context.Background(), n, nil, big.NewInt(3), 2, nullLogger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Verdict != Undetermined || result.Method != MethodAKS {
+		t.Error(result.Verdict, result.Method)
+	}
+}
+
+// RunAKS should accept nil options.
+func TestRunAKSNilOptions(t *testing.T) {
+	result, err := RunAKS(context.Background(), big.NewInt(101), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Verdict != Prime {
+		t.Error(result.Verdict)
+	}
+}
+
+// A trial division bound above sqrt(n) should find the smallest
+// factor of n even if it is above M, and should prove primes
+// directly.
+func TestRunAKSTrialDivisionBound(t *testing.T) {
+	// 2993374621 = 50767 * 58963 and has M = 1025.
+	n := big.NewInt(2993374621)
+	opts := &AKSOptions{TrialDivisionBound: big.NewInt(60000)}
+	result, err := RunAKS(context.Background(), n, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Method != MethodTrialDivision ||
+		result.Factor.Cmp(big.NewInt(50767)) != 0 {
+		t.Error(result.Method, result.Factor)
+	}
+
+	n = big.NewInt(2685241991)
+	result, err = RunAKS(context.Background(), n, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Verdict != Prime || result.Method != MethodSqrtBound {
+		t.Error(result.Verdict, result.Method)
+	}
+}
+
+// RunAKS should reject n < 2.
+func TestRunAKSBadInput(t *testing.T) {
+	result, err := RunAKS(context.Background(), big.NewInt(1), nil)
+	if result != nil || !errors.Is(err, ErrBadInput) {
+		t.Error(result, err)
+	}
+}
+
+// A ProgressObserver that cancels the test after a number of
+// witnesses have been tested.
+type cancelingObserver struct {
+	recordingObserver
+	count  int
+	cancel context.CancelFunc
+}
+
+func (o *cancelingObserver) OnWitnessTested(
+	a *big.Int, isWitness bool, d time.Duration) {
+	o.recordingObserver.OnWitnessTested(a, isWitness, d)
+	if len(o.tested) == o.count {
+		o.cancel()
+	}
+}
+
+// A cancelled run should still return the completed ranges, so that
+// it can be resumed.
+func TestRunAKSCancelledCompleted(t *testing.T) {
+	n := big.NewInt(1000003)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	o := &cancelingObserver{count: 10, cancel: cancel}
+	result, err := RunAKS(ctx, n, &AKSOptions{Jobs: 1, Observer: o})
+	if err != context.Canceled {
+		t.Fatal(err)
+	}
+	// A result that was already in flight may have been
+	// collected before noticing the cancellation.
+	if result == nil || len(result.Completed) != 1 ||
+		result.Completed[0].Start.Cmp(big.NewInt(1)) != 0 ||
+		result.Completed[0].End.Cmp(big.NewInt(11)) < 0 {
+		t.Fatal(result)
+	}
+	end := result.Completed[0].End
+
+	resume := &Checkpoint{n, result.R, result.Completed}
+	result, err = RunAKS(context.Background(), n, &AKSOptions{
+		Resume: resume,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var count big.Int
+	count.Sub(result.M, end)
+	if result.Verdict != Prime ||
+		result.Stats.WitnessesTested != count.Int64() {
+		t.Error(result.Verdict, result.Stats.WitnessesTested, &count)
+	}
+}
+
+// Each verbosity should log its own messages and those of lower
+// verbosities.
+func TestRunAKSVerbosity(t *testing.T) {
+	n := big.NewInt(1000003)
+	tests := []struct {
+		verbosity                       Verbosity
+		hasPhases, hasWitnesses, hasMul bool
+	}{
+		{VerbositySilent, false, false, false},
+		{VerbosityNormal, true, false, false},
+		{VerbosityVerbose, true, true, false},
+		{VerbosityDebug, true, true, true},
+	}
+	for _, test := range tests {
+		var buf bytes.Buffer
+		_, err := RunAKS(context.Background(), n, &AKSOptions{
+			End:       big.NewInt(3),
+			Logger:    log.New(&buf, "", 0),
+			Verbosity: test.verbosity,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		out := buf.String()
+		hasPhases := strings.Contains(out, "Phase: witness search\n")
+		hasWitnesses := strings.Contains(out, "2 isWitness=false\n")
+		hasMul := strings.Contains(out, "finished multiplication")
+		if hasPhases != test.hasPhases ||
+			hasWitnesses != test.hasWitnesses ||
+			hasMul != test.hasMul {
+			t.Error(test.verbosity, hasPhases, hasWitnesses, hasMul)
+		}
+	}
+}
+
+// Yield should be called after each polynomial multiplication,
+// whether or not multiplications are logged.
+func TestRunAKSYield(t *testing.T) {
+	n := big.NewInt(1000003)
+	mulCount := calculatePowMultiplicationCount(n)
+	for _, verbosity := range []Verbosity{VerbosityNormal, VerbosityDebug} {
+		count := int64(0)
+		_, err := RunAKS(context.Background(), n, &AKSOptions{
+			Jobs:      1,
+			End:       big.NewInt(3),
+			Logger:    nullLogger,
+			Verbosity: verbosity,
+			Yield:     func() { count++ },
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if count != 2*mulCount {
+			t.Error(verbosity, count, 2*mulCount)
+		}
+	}
+}
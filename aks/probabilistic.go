@@ -0,0 +1,129 @@
+package aks
+
+import "fmt"
+import "math/big"
+import "math/rand"
+
+// A ProbabilisticTest is a primality test that takes a base: every
+// prime passes with every base, and a composite n that fails with
+// some base is proven composite, but some composites (e.g.,
+// Carmichael numbers for FermatTest) pass with many or even all
+// bases. A base that a composite passes with is called a liar.
+type ProbabilisticTest interface {
+	// Returns the name of the test.
+	Name() string
+	// Returns whether n passes the test with base. Returns an
+	// error wrapping ErrBadInput if n isn't odd and >= 3, or if
+	// base isn't in [1, n - 1].
+	Passes(n, base *big.Int) (bool, error)
+}
+
+// The test that n passes with base if base^(n - 1) = 1 (mod n), by
+// Fermat's little theorem.
+type FermatTest struct{}
+
+// The test that n passes with base if base is coprime to n and
+// base^((n - 1)/2) = (base/n) (mod n), where (base/n) is the Jacobi
+// symbol, by Euler's criterion. Unlike with FermatTest, at most half
+// of the bases are liars for any composite.
+type SolovayStrassenTest struct{}
+
+// The test that n passes with base if n is a strong probable prime
+// to base; see IsStrongProbablePrime. At most a quarter of the bases
+// are liars for any composite.
+type MillerRabinTest struct{}
+
+// All the implementations of ProbabilisticTest, from weakest to
+// strongest.
+var ProbabilisticTests = []ProbabilisticTest{
+	FermatTest{}, SolovayStrassenTest{}, MillerRabinTest{},
+}
+
+// Returns an error wrapping ErrBadInput unless n is odd and >= 3 and
+// base is in [1, n - 1].
+func checkProbabilisticTestArgs(n, base *big.Int) error {
+	if n.Cmp(big.NewInt(3)) < 0 || n.Bit(0) == 0 {
+		return fmt.Errorf(
+			"%w: n = %v must be odd and >= 3", ErrBadInput, n)
+	}
+	if base.Sign() <= 0 || base.Cmp(n) >= 0 {
+		return fmt.Errorf(
+			"%w: base = %v must be in [1, %v)", ErrBadInput, base, n)
+	}
+	return nil
+}
+
+// ProbabilisticTest implementation.
+func (FermatTest) Name() string {
+	return "Fermat"
+}
+
+// ProbabilisticTest implementation.
+func (FermatTest) Passes(n, base *big.Int) (bool, error) {
+	if err := checkProbabilisticTestArgs(n, base); err != nil {
+		return false, err
+	}
+	var e, x big.Int
+	e.Sub(n, big.NewInt(1))
+	x.Exp(base, &e, n)
+	return x.Cmp(big.NewInt(1)) == 0, nil
+}
+
+// ProbabilisticTest implementation.
+func (SolovayStrassenTest) Name() string {
+	return "Solovay-Strassen"
+}
+
+// ProbabilisticTest implementation.
+func (SolovayStrassenTest) Passes(n, base *big.Int) (bool, error) {
+	if err := checkProbabilisticTestArgs(n, base); err != nil {
+		return false, err
+	}
+	j := big.Jacobi(base, n)
+	if j == 0 {
+		return false, nil
+	}
+	var e, x, expected big.Int
+	e.Rsh(n, 1)
+	x.Exp(base, &e, n)
+	expected.SetInt64(int64(j))
+	expected.Mod(&expected, n)
+	return x.Cmp(&expected) == 0, nil
+}
+
+// ProbabilisticTest implementation.
+func (MillerRabinTest) Name() string {
+	return "Miller-Rabin"
+}
+
+// ProbabilisticTest implementation.
+func (MillerRabinTest) Passes(n, base *big.Int) (bool, error) {
+	return IsStrongProbablePrime(n, base)
+}
+
+// Returns whether n passes test with the given number of bases
+// chosen uniformly from [2, n - 2] using rnd, like MillerRabin does
+// for MillerRabinTest. Returns an error wrapping ErrBadInput if n
+// isn't odd and >= 5, rounds < 0, or rnd is nil.
+func RunProbabilisticTest(
+	test ProbabilisticTest, n *big.Int, rounds int,
+	rnd *rand.Rand) (bool, error) {
+	if n.Cmp(big.NewInt(5)) < 0 || n.Bit(0) == 0 || rounds < 0 ||
+		rnd == nil {
+		return false, fmt.Errorf(
+			"%w: n = %v must be odd and >= 5, rounds = %d must "+
+				"be >= 0, and rnd must be non-nil",
+			ErrBadInput, n, rounds)
+	}
+	var span, base big.Int
+	span.Sub(n, big.NewInt(3))
+	for i := 0; i < rounds; i++ {
+		base.Rand(rnd, &span)
+		base.Add(&base, big.NewInt(2))
+		passes, err := test.Passes(n, &base)
+		if err != nil || !passes {
+			return false, err
+		}
+	}
+	return true, nil
+}
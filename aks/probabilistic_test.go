@@ -0,0 +1,107 @@
+package aks
+
+import "errors"
+import "math/big"
+import "math/rand"
+import "testing"
+
+// Returns how many bases in [1, n) n passes test with.
+func countLiars(test ProbabilisticTest, n int64, t *testing.T) int64 {
+	var count int64
+	for a := int64(1); a < n; a++ {
+		passes, err := test.Passes(big.NewInt(n), big.NewInt(a))
+		if err != nil {
+			t.Fatal(test.Name(), n, a, err)
+		}
+		if passes {
+			count++
+		}
+	}
+	return count
+}
+
+// Each test should pass primes with every base, and should have
+// fewer liars than the tests before it on Carmichael numbers and
+// strong pseudoprimes.
+func TestProbabilisticTests(t *testing.T) {
+	tests := []struct {
+		n        int64
+		expected []int64
+	}{
+		{1009, []int64{1008, 1008, 1008}},
+		{561, []int64{320, 80, 10}},
+		{1105, []int64{768, 192, 30}},
+		{2047, []int64{484, 242, 242}},
+		{91, []int64{36, 18, 18}},
+		{9, []int64{2, 2, 2}},
+	}
+	for _, test := range tests {
+		for i, pt := range ProbabilisticTests {
+			actual := countLiars(pt, test.n, t)
+			if actual != test.expected[i] {
+				t.Error(pt.Name(), test.n, actual,
+					test.expected[i])
+			}
+		}
+	}
+
+	for _, pt := range ProbabilisticTests {
+		for _, c := range []struct{ n, base int64 }{
+			{1, 1}, {10, 3}, {7, 0}, {7, 7},
+		} {
+			passes, err := pt.Passes(
+				big.NewInt(c.n), big.NewInt(c.base))
+			if !errors.Is(err, ErrBadInput) {
+				t.Error(pt.Name(), c.n, c.base, passes, err)
+			}
+		}
+	}
+}
+
+// RunProbabilisticTest should tell Carmichael numbers apart from
+// primes with Solovay-Strassen, but not always with Fermat.
+func TestRunProbabilisticTest(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	n := big.NewInt(1000003)
+	for _, pt := range ProbabilisticTests {
+		passes, err := RunProbabilisticTest(pt, n, 20, rnd)
+		if !passes || err != nil {
+			t.Error(pt.Name(), passes, err)
+		}
+	}
+
+	// 8911 = 7 * 19 * 67 is a Carmichael number whose liars
+	// (the bases coprime to it) make up most of [2, n - 2].
+	n = big.NewInt(8911)
+	passes, err := RunProbabilisticTest(SolovayStrassenTest{}, n, 40, rnd)
+	if passes || err != nil {
+		t.Error(passes, err)
+	}
+	fermatPasses := 0
+	for i := 0; i < 100; i++ {
+		passes, err := RunProbabilisticTest(FermatTest{}, n, 1, rnd)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if passes {
+			fermatPasses++
+		}
+	}
+	if fermatPasses < 50 {
+		t.Error(fermatPasses)
+	}
+
+	for _, c := range []struct {
+		n      int64
+		rounds int
+		rnd    *rand.Rand
+	}{
+		{3, 1, rnd}, {8, 1, rnd}, {7, -1, rnd}, {7, 1, nil},
+	} {
+		passes, err := RunProbabilisticTest(
+			FermatTest{}, big.NewInt(c.n), c.rounds, c.rnd)
+		if !errors.Is(err, ErrBadInput) {
+			t.Error(c.n, c.rounds, passes, err)
+		}
+	}
+}
@@ -0,0 +1,196 @@
+package aks
+
+import "fmt"
+import "math/big"
+import "time"
+
+// A Phase is a stage of a primality test run by RunAKS.
+type Phase int
+
+const (
+	// Calculating r and M.
+	PhaseParameters Phase = iota
+	// Looking for small factors of n.
+	PhaseTrialDivision
+	// Searching for AKS witnesses.
+	PhaseWitnessSearch
+	// The test has finished or was cancelled.
+	PhaseDone
+)
+
+// fmt.Stringer implementation.
+func (p Phase) String() string {
+	switch p {
+	case PhaseParameters:
+		return "parameters"
+	case PhaseTrialDivision:
+		return "trial division"
+	case PhaseWitnessSearch:
+		return "witness search"
+	case PhaseDone:
+		return "done"
+	}
+	return fmt.Sprintf("Phase(%d)", int(p))
+}
+
+// Returns the Phase with the given string representation.
+func parsePhase(s string) (Phase, error) {
+	for p := PhaseParameters; p <= PhaseDone; p++ {
+		if p.String() == s {
+			return p, nil
+		}
+	}
+	return PhaseParameters, fmt.Errorf("%w: unknown phase %q", ErrBadInput, s)
+}
+
+// encoding.TextMarshaler implementation.
+func (p Phase) MarshalText() ([]byte, error) {
+	return []byte(p.String()), nil
+}
+
+// encoding.TextUnmarshaler implementation.
+func (p *Phase) UnmarshalText(text []byte) error {
+	parsed, err := parsePhase(string(text))
+	if err != nil {
+		return err
+	}
+	*p = parsed
+	return nil
+}
+
+// An Estimate describes the progress of an AKS witness search.
+type Estimate struct {
+	// The number of candidates in the search range, and how many
+	// of them are done (either tested in this run or skipped
+	// because they were already tested before).
+	Total, Done *big.Int
+	// The number of candidates tested per second in this run.
+	Rate float64
+	// The estimated time until all candidates are done, or -1 if
+	// it is not yet known.
+	Remaining time.Duration
+}
+
+// A ProgressObserver is notified of the progress of a primality test
+// run by RunAKS. Its methods are all called from the same goroutine,
+// so they need not be synchronized with each other, but they should
+// return quickly since they block the witness search.
+type ProgressObserver interface {
+	// Called after a is tested as an AKS witness, with whether
+	// it is one and how long the test took.
+	OnWitnessTested(a *big.Int, isWitness bool, d time.Duration)
+	// Called when the test moves on to the given phase.
+	OnPhaseChange(phase Phase)
+	// Called with an updated estimate after each witness is
+	// tested.
+	OnEstimate(e Estimate)
+}
+
+// A StatsObserver is a ProgressObserver that also wants the Stats of
+// the AKS witness search as it runs. If the Observer of an
+// AKSOptions implements StatsObserver, OnStats is called after each
+// witness is tested, from the same goroutine as the other methods.
+type StatsObserver interface {
+	ProgressObserver
+	// Called with the stats of the search so far.
+	OnStats(stats *Stats)
+}
+
+// A multiObserver forwards notifications to each of its observers.
+type multiObserver []ProgressObserver
+
+// Returns a StatsObserver that forwards notifications to each of the
+// given observers, in order. OnStats is only forwarded to those that
+// implement StatsObserver.
+func MultiObserver(observers ...ProgressObserver) StatsObserver {
+	return multiObserver(append([]ProgressObserver{}, observers...))
+}
+
+// ProgressObserver implementation.
+func (m multiObserver) OnWitnessTested(
+	a *big.Int, isWitness bool, d time.Duration) {
+	for _, o := range m {
+		o.OnWitnessTested(a, isWitness, d)
+	}
+}
+
+// ProgressObserver implementation.
+func (m multiObserver) OnPhaseChange(phase Phase) {
+	for _, o := range m {
+		o.OnPhaseChange(phase)
+	}
+}
+
+// ProgressObserver implementation.
+func (m multiObserver) OnEstimate(e Estimate) {
+	for _, o := range m {
+		o.OnEstimate(e)
+	}
+}
+
+// StatsObserver implementation.
+func (m multiObserver) OnStats(stats *Stats) {
+	for _, o := range m {
+		if so, ok := o.(StatsObserver); ok {
+			so.OnStats(stats)
+		}
+	}
+}
+
+// Tracks the progress of a witness search to make Estimates.
+type estimator struct {
+	total, done big.Int
+	tested      int64
+	startTime   time.Time
+}
+
+// Makes an estimator for a search of the members of p in [start,
+// end) where the numbers in completed (if non-nil) are skipped.
+func newEstimator(
+	start, end *big.Int, p progression, completed *rangeSet) *estimator {
+	e := &estimator{startTime: time.Now()}
+	e.total.Set(p.countIn(start, end))
+	if completed != nil {
+		e.done.Set(completed.countInRange(start, end, p))
+	}
+	return e
+}
+
+// Makes an estimator for a search of the given primes, where the
+// numbers in completed (if non-nil) are skipped.
+func newPrimesEstimator(primes []uint64, completed *rangeSet) *estimator {
+	e := &estimator{startTime: time.Now()}
+	e.total.SetInt64(int64(len(primes)))
+	if completed != nil {
+		var p big.Int
+		for _, prime := range primes {
+			p.SetUint64(prime)
+			if completed.nextAbsent(&p).Cmp(&p) != 0 {
+				e.done.Add(&e.done, big.NewInt(1))
+			}
+		}
+	}
+	return e
+}
+
+// Records that another number was tested and returns the updated
+// estimate.
+func (e *estimator) update() Estimate {
+	e.tested++
+	e.done.Add(&e.done, big.NewInt(1))
+	elapsed := time.Since(e.startTime)
+	rate := float64(e.tested) / elapsed.Seconds()
+	remaining := time.Duration(-1)
+	if rate > 0 {
+		var left big.Int
+		left.Sub(&e.total, &e.done)
+		leftFloat, _ := new(big.Float).SetInt(&left).Float64()
+		remaining = time.Duration(leftFloat / rate * float64(time.Second))
+	}
+	return Estimate{
+		Total:     new(big.Int).Set(&e.total),
+		Done:      new(big.Int).Set(&e.done),
+		Rate:      rate,
+		Remaining: remaining,
+	}
+}
@@ -0,0 +1,100 @@
+package aks
+
+import "context"
+import "math/big"
+import "testing"
+import "time"
+
+// A ProgressObserver that records everything it's told.
+type recordingObserver struct {
+	tested    []*big.Int
+	phases    []Phase
+	estimates []Estimate
+}
+
+func (o *recordingObserver) OnWitnessTested(
+	a *big.Int, isWitness bool, d time.Duration) {
+	o.tested = append(o.tested, a)
+}
+
+func (o *recordingObserver) OnPhaseChange(phase Phase) {
+	o.phases = append(o.phases, phase)
+}
+
+func (o *recordingObserver) OnEstimate(e Estimate) {
+	o.estimates = append(o.estimates, e)
+}
+
+// An observer should see every phase, every tested witness, and a
+// final estimate with everything done.
+func TestProgressObserver(t *testing.T) {
+	n := big.NewInt(1000003)
+	o := &recordingObserver{}
+	result, err := RunAKS(context.Background(), n, &AKSOptions{
+		Observer: o,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expectedPhases := []Phase{
+		PhaseParameters, PhaseTrialDivision,
+		PhaseWitnessSearch, PhaseDone,
+	}
+	if len(o.phases) != len(expectedPhases) {
+		t.Fatal(o.phases)
+	}
+	for i, phase := range o.phases {
+		if phase != expectedPhases[i] {
+			t.Error(i, phase, expectedPhases[i])
+		}
+	}
+
+	var count big.Int
+	count.Sub(result.M, big.NewInt(1))
+	if int64(len(o.tested)) != count.Int64() ||
+		len(o.estimates) != len(o.tested) {
+		t.Fatal(len(o.tested), len(o.estimates), &count)
+	}
+	last := o.estimates[len(o.estimates)-1]
+	if last.Total.Cmp(&count) != 0 || last.Done.Cmp(&count) != 0 ||
+		last.Remaining != 0 {
+		t.Error(last)
+	}
+}
+
+// Numbers skipped because of a checkpoint should count as done.
+func TestEstimatorSkipsCompleted(t *testing.T) {
+	completed := &rangeSet{}
+	completed.addRange(big.NewInt(0), big.NewInt(5))
+	completed.addRange(big.NewInt(8), big.NewInt(9))
+	e := newEstimator(
+		big.NewInt(2), big.NewInt(12), allIntegers(), completed)
+	estimate := e.update()
+	if estimate.Total.Int64() != 10 || estimate.Done.Int64() != 5 {
+		t.Error(estimate)
+	}
+}
+
+// MultiObserver should forward everything to all observers, and
+// stats only to StatsObservers.
+func TestMultiObserver(t *testing.T) {
+	n := big.NewInt(1000003)
+	o1 := &recordingObserver{}
+	o2 := &recordingObserver{}
+	m := NewMetrics(1)
+	result, err := RunAKS(context.Background(), n, &AKSOptions{
+		Observer: MultiObserver(o1, m, o2),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(o1.tested) != len(o2.tested) ||
+		len(o1.phases) != len(o2.phases) ||
+		len(o1.estimates) != len(o2.estimates) {
+		t.Error(len(o1.tested), len(o2.tested))
+	}
+	if m.stats.WitnessesTested != result.Stats.WitnessesTested {
+		t.Error(m.stats.WitnessesTested, result.Stats.WitnessesTested)
+	}
+}
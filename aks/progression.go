@@ -0,0 +1,65 @@
+package aks
+
+import "fmt"
+import "math/big"
+
+// A progression is the set of integers congruent to offset modulo
+// stride, i.e. the arithmetic progression ..., offset - stride,
+// offset, offset + stride, ...
+type progression struct {
+	stride, offset *big.Int
+}
+
+// Returns the progression of all integers.
+func allIntegers() progression {
+	return progression{big.NewInt(1), big.NewInt(0)}
+}
+
+// Returns the progression of integers congruent to offset modulo
+// stride, or an error if stride is not positive. stride and offset
+// default to 1 and 0 if nil.
+func newProgression(stride, offset *big.Int) (progression, error) {
+	p := allIntegers()
+	if stride != nil {
+		if stride.Sign() <= 0 {
+			return p, fmt.Errorf(
+				"%w: stride = %v must be positive",
+				ErrBadInput, stride)
+		}
+		p.stride = stride
+	}
+	if offset != nil {
+		p.offset = offset
+	}
+	return p, nil
+}
+
+// Returns whether p is the progression of all integers.
+func (p progression) isAll() bool {
+	return p.stride.Cmp(big.NewInt(1)) == 0
+}
+
+// Returns the smallest member of p that is >= x.
+func (p progression) alignUp(x *big.Int) *big.Int {
+	// y = x + ((offset - x) mod stride).
+	var y big.Int
+	y.Sub(p.offset, x)
+	y.Mod(&y, p.stride)
+	y.Add(&y, x)
+	return &y
+}
+
+// Returns the number of members of p in [start, end).
+func (p progression) countIn(start, end *big.Int) *big.Int {
+	first := p.alignUp(start)
+	count := &big.Int{}
+	if first.Cmp(end) >= 0 {
+		return count
+	}
+	// count = floor((end - 1 - first) / stride) + 1.
+	count.Sub(end, first)
+	count.Sub(count, big.NewInt(1))
+	count.Div(count, p.stride)
+	count.Add(count, big.NewInt(1))
+	return count
+}
@@ -0,0 +1,95 @@
+package aks
+
+import "context"
+import "math/big"
+import "testing"
+
+// alignUp should return the smallest member of the progression that
+// is >= its argument.
+func TestProgressionAlignUp(t *testing.T) {
+	p, err := newProgression(big.NewInt(4), big.NewInt(3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []int64{3, 3, 3, 3, 7, 7, 7, 7, 11}
+	for i, e := range expected {
+		x := p.alignUp(big.NewInt(int64(i))).Int64()
+		if x != e {
+			t.Error(i, x, e)
+		}
+	}
+	// Offsets may be out of range.
+	p, _ = newProgression(big.NewInt(4), big.NewInt(-1))
+	x := p.alignUp(big.NewInt(1)).Int64()
+	if x != 3 {
+		t.Error(x)
+	}
+}
+
+// countIn should count the members of the progression in a range.
+func TestProgressionCountIn(t *testing.T) {
+	p, _ := newProgression(big.NewInt(4), big.NewInt(3))
+	counts := [][3]int64{
+		{0, 0, 0}, {0, 3, 0}, {0, 4, 1}, {3, 4, 1}, {4, 7, 0},
+		{3, 12, 3}, {1, 100, 25},
+	}
+	for _, c := range counts {
+		count := p.countIn(big.NewInt(c[0]), big.NewInt(c[1])).Int64()
+		if count != c[2] {
+			t.Error(c, count)
+		}
+	}
+}
+
+// A non-positive stride should be rejected.
+func TestNewProgressionBadStride(t *testing.T) {
+	if _, err := newProgression(big.NewInt(0), nil); err == nil {
+		t.Error(err)
+	}
+}
+
+// Splitting the witness search of a prime with strides should test
+// each number exactly once across all offsets, and each part alone
+// should be undetermined.
+func TestRunAKSStride(t *testing.T) {
+	n := big.NewInt(1000003)
+	stride := big.NewInt(3)
+	var total int64
+	var M *big.Int
+	for offset := int64(0); offset < 3; offset++ {
+		o := &recordingObserver{}
+		result, err := RunAKS(context.Background(), n, &AKSOptions{
+			Stride:   stride,
+			Offset:   big.NewInt(offset),
+			Observer: o,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result.Verdict != Undetermined {
+			t.Error(offset, result.Verdict)
+		}
+		for _, a := range o.tested {
+			if a.Int64()%3 != offset {
+				t.Error(offset, a)
+			}
+		}
+		total += int64(len(o.tested))
+		M = result.M
+	}
+	if total != M.Int64()-1 {
+		t.Error(total, M)
+	}
+}
+
+// GetAKSWitnessWithStride should find witnesses at the given offset.
+func TestGetAKSWitnessWithStride(t *testing.T) {
+	n := big.NewInt(2993374621)
+	r := mustCalculateAKSModulus(n, t)
+	a, err := GetAKSWitnessWithStride(
+		context.Background(), n, r, big.NewInt(1), big.NewInt(20),
+		big.NewInt(5), big.NewInt(2), 2, nullLogger)
+	if err != nil || a == nil || a.Int64()%5 != 2 {
+		t.Error(a, err)
+	}
+}
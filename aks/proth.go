@@ -0,0 +1,133 @@
+package aks
+
+import "fmt"
+import "io"
+import "math/big"
+
+// The largest base ProveProth tries. For prime n, the first base
+// that is a quadratic non-residue works, and there is always a small
+// one.
+const prothMaxBase = 1000
+
+// A ProthCertificate proves N = K * 2^M + 1 with K < 2^M prime by
+// Proth's theorem: if A^((N - 1)/2) = -1 (mod N) for some A, then N
+// is prime.
+type ProthCertificate struct {
+	N *big.Int
+	K *big.Int
+	M uint
+	A *big.Int
+}
+
+// Returns k and m such that n = k * 2^m + 1 with k odd and k < 2^m,
+// or nil if n has no such form.
+func getProthForm(n *big.Int) (k *big.Int, m uint) {
+	if n.Cmp(big.NewInt(3)) < 0 {
+		return nil, 0
+	}
+	k = new(big.Int).Sub(n, big.NewInt(1))
+	m = k.TrailingZeroBits()
+	k.Rsh(k, m)
+	if k.BitLen() > int(m) {
+		return nil, 0
+	}
+	return k, m
+}
+
+// Returns whether n is a Proth number, i.e., n = k * 2^m + 1 for some
+// odd k < 2^m, which ProveProth can prove prime (or composite) with a
+// single modular exponentiation.
+func IsProthNumber(n *big.Int) bool {
+	k, _ := getProthForm(n)
+	return k != nil
+}
+
+// Returns a certificate proving the Proth number n prime with Proth's
+// theorem, using the first base with Jacobi symbol -1 mod n. Returns
+// an error wrapping ErrComposite if n is composite (which this
+// almost always decides), ErrProofNotFound if no base up to
+// prothMaxBase has Jacobi symbol -1 mod n, or ErrBadInput if n isn't
+// a Proth number.
+func ProveProth(n *big.Int) (*ProthCertificate, error) {
+	k, m := getProthForm(n)
+	if k == nil {
+		return nil, fmt.Errorf(
+			"%w: n = %v isn't a Proth number", ErrBadInput, n)
+	}
+	// Squares have no quadratic non-residues with Jacobi symbol
+	// -1, so the search below would fail.
+	if root, exact := sqrtExact(n); exact {
+		return nil, fmt.Errorf(
+			"%w: n = %v has factor %v", ErrComposite, n, root)
+	}
+	// For prime n, any quadratic non-residue a has a^((n - 1)/2)
+	// = -1 by Euler's criterion, so if that fails, n is
+	// composite.
+	nMinusOne := new(big.Int).Sub(n, big.NewInt(1))
+	e := new(big.Int).Rsh(nMinusOne, 1)
+	var x big.Int
+	for b := int64(2); b <= prothMaxBase; b++ {
+		a := big.NewInt(b)
+		if a.Cmp(n) >= 0 {
+			break
+		}
+		switch big.Jacobi(a, n) {
+		case 0:
+			return nil, fmt.Errorf(
+				"%w: n = %v has factor %v", ErrComposite, n,
+				new(big.Int).GCD(nil, nil, a, n))
+		case -1:
+			if x.Exp(a, e, n).Cmp(nMinusOne) != 0 {
+				return nil, fmt.Errorf(
+					"%w: n = %v fails Proth's test with "+
+						"base %v", ErrComposite, n, a)
+			}
+			return &ProthCertificate{
+				N: new(big.Int).Set(n),
+				K: k,
+				M: m,
+				A: a,
+			}, nil
+		}
+	}
+	return nil, fmt.Errorf(
+		"%w: no base up to %d is a quadratic non-residue mod n = %v",
+		ErrProofNotFound, prothMaxBase, n)
+}
+
+// Returns nil if c proves c.N prime, and an error wrapping
+// ErrInvalidCertificate otherwise.
+func (c *ProthCertificate) Verify() error {
+	if c.N == nil || c.K == nil || c.A == nil {
+		return fmt.Errorf(
+			"%w: N, K, and A must be set", ErrInvalidCertificate)
+	}
+	var n big.Int
+	n.Lsh(c.K, c.M)
+	n.Add(&n, big.NewInt(1))
+	if c.K.Sign() <= 0 || c.K.BitLen() > int(c.M) || n.Cmp(c.N) != 0 {
+		return fmt.Errorf(
+			"%w: N = %v must be K * 2^M + 1 = %v * 2^%d + 1 "+
+				"with 0 < K < 2^M",
+			ErrInvalidCertificate, c.N, c.K, c.M)
+	}
+	if c.A.Sign() <= 0 || c.A.Cmp(c.N) >= 0 {
+		return fmt.Errorf(
+			"%w: A = %v must be in [1, N = %v)",
+			ErrInvalidCertificate, c.A, c.N)
+	}
+	var e, x big.Int
+	e.Rsh(c.N, 1)
+	n.Sub(c.N, big.NewInt(1))
+	if x.Exp(c.A, &e, c.N).Cmp(&n) != 0 {
+		return fmt.Errorf(
+			"%w: %v^((N - 1)/2) != -1 (mod N = %v)",
+			ErrInvalidCertificate, c.A, c.N)
+	}
+	return nil
+}
+
+// Writes c to w as indented JSON.
+func (c *ProthCertificate) WriteJSON(w io.Writer) error {
+	return writeIndentedJSON(w, c)
+}
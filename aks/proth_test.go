@@ -0,0 +1,127 @@
+package aks
+
+import "bytes"
+import "encoding/json"
+import "errors"
+import "math/big"
+import "testing"
+
+// Returns k * 2^m + 1.
+func makeProthNumber(k int64, m uint) *big.Int {
+	n := new(big.Int).Lsh(big.NewInt(k), m)
+	return n.Add(n, big.NewInt(1))
+}
+
+// Proth numbers should be recognized, and no others.
+func TestIsProthNumber(t *testing.T) {
+	var proth []int64
+	for n := int64(0); n < 100; n++ {
+		if IsProthNumber(big.NewInt(n)) {
+			proth = append(proth, n)
+		}
+	}
+	// OEIS A080075.
+	expected := []int64{3, 5, 9, 13, 17, 25, 33, 41, 49, 57, 65, 81, 97}
+	if len(proth) != len(expected) {
+		t.Fatal(proth)
+	}
+	for i := range proth {
+		if proth[i] != expected[i] {
+			t.Error(proth)
+		}
+	}
+}
+
+// ProveProth should decide every Proth number, and its certificates
+// should verify.
+func TestProveProth(t *testing.T) {
+	for n := int64(3); n < 5000; n++ {
+		bigN := big.NewInt(n)
+		if !IsProthNumber(bigN) {
+			if c, err := ProveProth(bigN); !errors.Is(
+				err, ErrBadInput) {
+				t.Error(n, c, err)
+			}
+			continue
+		}
+		c, err := ProveProth(bigN)
+		if bigN.ProbablyPrime(0) {
+			if err != nil {
+				t.Fatal(n, err)
+			}
+			if err := c.Verify(); err != nil {
+				t.Error(n, err)
+			}
+		} else if !errors.Is(err, ErrComposite) {
+			t.Error(n, c, err)
+		}
+	}
+
+	// 3 * 2^189 + 1 is prime, and 3 * 2^190 + 1 is divisible by 7.
+	n := makeProthNumber(3, 189)
+	c, err := ProveProth(n)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.K.Int64() != 3 || c.M != 189 {
+		t.Error(c.K, c.M)
+	}
+	if err := c.Verify(); err != nil {
+		t.Error(err)
+	}
+	if c, err := ProveProth(makeProthNumber(3, 190)); !errors.Is(
+		err, ErrComposite) {
+		t.Error(c, err)
+	}
+	// 2^64 + 1 = 274177 * 67280421310721.
+	if c, err := ProveProth(makeProthNumber(1, 64)); !errors.Is(
+		err, ErrComposite) {
+		t.Error(c, err)
+	}
+}
+
+// Tampering with a certificate should make it fail verification, and
+// it should survive a round trip through JSON.
+func TestProthCertificateVerify(t *testing.T) {
+	c, err := ProveProth(makeProthNumber(3, 189))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := c.WriteJSON(&buf); err != nil {
+		t.Fatal(err)
+	}
+	var decoded ProthCertificate
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if err := decoded.Verify(); err != nil {
+		t.Error(err)
+	}
+
+	tamperings := []func(c *ProthCertificate){
+		func(c *ProthCertificate) { c.N.Add(c.N, big.NewInt(2)) },
+		func(c *ProthCertificate) { c.K.SetInt64(5) },
+		func(c *ProthCertificate) { c.M++ },
+		func(c *ProthCertificate) { c.A.SetInt64(1) },
+		func(c *ProthCertificate) { c.A.Add(c.A, c.N) },
+		func(c *ProthCertificate) { c.A = nil },
+		// 2^64 + 1 has the right form, but no base works.
+		func(c *ProthCertificate) {
+			c.N = makeProthNumber(1, 64)
+			c.K.SetInt64(1)
+			c.M = 64
+		},
+	}
+	for i, tamper := range tamperings {
+		var tampered ProthCertificate
+		if err := json.Unmarshal(buf.Bytes(), &tampered); err != nil {
+			t.Fatal(err)
+		}
+		tamper(&tampered)
+		if err := tampered.Verify(); !errors.Is(
+			err, ErrInvalidCertificate) {
+			t.Error(i, err)
+		}
+	}
+}
@@ -0,0 +1,321 @@
+// Package prover decides whether a number is prime with whichever of
+// the methods in the aks and aks/ecpp packages suits it best, and
+// reports which one it used. It lives in its own package so that it
+// can use aks/ecpp, which itself uses the aks package.
+//
+// Prove tries, in order: trial division, which settles small numbers
+// and those with small factors; deterministic Miller-Rabin for numbers
+// below 2^64; the Lucas-Lehmer test, Pepin's test, and Proth's
+// theorem for numbers of those special forms; the Baillie-PSW test,
+// which quickly rules out almost all other composites; Pocklington's
+// theorem or the N+1 test when n - 1 or n + 1 factors easily; and
+// finally AKS for numbers small enough for it (as configured), or
+// ECPP otherwise.
+package prover
+
+import "github.com/akalin/aks-go/aks"
+import "github.com/akalin/aks-go/aks/ecpp"
+import "context"
+import "errors"
+import "fmt"
+import "io"
+import "math/big"
+
+// The default for Options.TrialDivisionBound.
+const DefaultTrialDivisionBound = 1 << 16
+
+// n - 1 and n + 1 are trial divided up to this bound to decide
+// whether Pocklington's theorem or the N+1 test is worth trying.
+const neighborTrialDivisionBound = 1 << 16
+
+// A Method is the way a Proof was found.
+type Method int
+
+const (
+	MethodTrialDivision Method = iota
+	// Miller-Rabin with bases known to have no strong
+	// pseudoprimes below 2^64, as in aks.IsPrimeUint64.
+	MethodMillerRabin
+	// Only used for composites, since passing doesn't prove
+	// primality.
+	MethodBPSW
+	MethodLucasLehmer
+	MethodPepin
+	MethodProth
+	MethodPocklington
+	MethodNPlusOne
+	MethodAKS
+	MethodECPP
+)
+
+// fmt.Stringer implementation.
+func (m Method) String() string {
+	switch m {
+	case MethodTrialDivision:
+		return "trial division"
+	case MethodMillerRabin:
+		return "deterministic Miller-Rabin"
+	case MethodBPSW:
+		return "Baillie-PSW"
+	case MethodLucasLehmer:
+		return "Lucas-Lehmer"
+	case MethodPepin:
+		return "Pepin's test"
+	case MethodProth:
+		return "Proth's theorem"
+	case MethodPocklington:
+		return "Pocklington's theorem"
+	case MethodNPlusOne:
+		return "the N+1 test"
+	case MethodAKS:
+		return "AKS"
+	case MethodECPP:
+		return "ECPP"
+	}
+	return fmt.Sprintf("Method(%d)", int(m))
+}
+
+// A Certificate is a proof of primality that can be checked
+// independently of how it was found, such as an
+// *aks.PocklingtonCertificate or an *ecpp.Certificate.
+type Certificate interface {
+	// Returns nil if the certificate is valid, and an error
+	// wrapping aks.ErrInvalidCertificate otherwise.
+	Verify() error
+	WriteJSON(w io.Writer) error
+}
+
+// Holds the parameters for Prove.
+type Options struct {
+	// Trial division is done up to this bound first. If zero,
+	// DefaultTrialDivisionBound is used.
+	TrialDivisionBound uint64
+	// Numbers of at most this many bits that no faster method
+	// handles are tested with AKS, and larger ones are proven
+	// with ECPP. Since numbers below 2^64 are handled by
+	// deterministic Miller-Rabin, and AKS takes minutes for
+	// numbers not much larger, if zero, AKS is never used.
+	AKSMaxBits int
+	// The options passed to aks.RunAKS, which may be nil.
+	AKS *aks.AKSOptions
+}
+
+// Returns a copy of opts, which may be nil, with defaults filled in.
+func (opts *Options) withDefaults() Options {
+	var o Options
+	if opts != nil {
+		o = *opts
+	}
+	if o.TrialDivisionBound == 0 {
+		o.TrialDivisionBound = DefaultTrialDivisionBound
+	}
+	return o
+}
+
+// Holds the result of Prove.
+type Proof struct {
+	N       *big.Int
+	Verdict aks.Verdict
+	Method  Method
+	// A non-trivial factor of N, if Method is
+	// MethodTrialDivision and N is composite.
+	Factor *big.Int
+	// A certificate of N's primality, if N is prime and Method
+	// produces one, i.e., is MethodProth, MethodPocklington,
+	// MethodNPlusOne, or MethodECPP.
+	Certificate Certificate
+	// The result of the AKS test, if Method is MethodAKS.
+	AKSResult *aks.PrimalityResult
+}
+
+// Decides whether n >= 2 is prime with the method that suits n best,
+// as configured by opts, which may be nil. Returns an error wrapping
+// aks.ErrBadInput if n < 2, or aks.ErrProofNotFound if ECPP fails
+// (which is rare).
+func Prove(n *big.Int, opts *Options) (*Proof, error) {
+	return ProveContext(context.Background(), n, opts)
+}
+
+// Like Prove, but returns ctx.Err() if ctx is cancelled before the
+// proof is done, along with the partial AKS result if AKS was
+// running.
+func ProveContext(
+	ctx context.Context, n *big.Int, opts *Options) (*Proof, error) {
+	if n.Cmp(big.NewInt(2)) < 0 {
+		return nil, fmt.Errorf(
+			"%w: n = %v must be >= 2", aks.ErrBadInput, n)
+	}
+	o := opts.withDefaults()
+	proof := &Proof{N: new(big.Int).Set(n), Verdict: aks.Prime}
+
+	bound := new(big.Int).SetUint64(o.TrialDivisionBound)
+	factor, err := aks.GetFirstFactorBelow(n, bound)
+	if err != nil {
+		return nil, err
+	}
+	var boundSq big.Int
+	if factor != nil || boundSq.Mul(bound, bound).Cmp(n) > 0 {
+		proof.Method = MethodTrialDivision
+		if factor != nil {
+			proof.Verdict = aks.Composite
+			proof.Factor = factor
+		}
+		return proof, nil
+	}
+
+	if n.IsUint64() {
+		proof.Method = MethodMillerRabin
+		if !aks.IsPrimeUint64(n.Uint64()) {
+			proof.Verdict = aks.Composite
+		}
+		return proof, nil
+	}
+
+	// These are exact and about as fast as Baillie-PSW.
+	found, err := proveSpecialForm(n, proof)
+	if found || err != nil {
+		return proof, err
+	}
+
+	if isPrime, _ := aks.BailliePSW(n); !isPrime {
+		proof.Method = MethodBPSW
+		proof.Verdict = aks.Composite
+		return proof, nil
+	}
+
+	if found, err := proveWithNeighbors(n, proof); found || err != nil {
+		return proof, err
+	}
+
+	if n.BitLen() <= o.AKSMaxBits {
+		proof.Method = MethodAKS
+		result, err := aks.RunAKS(ctx, n, o.AKS)
+		proof.AKSResult = result
+		if result != nil {
+			proof.Verdict = result.Verdict
+		}
+		return proof, err
+	}
+
+	proof.Method = MethodECPP
+	c, err := ecpp.ProveContext(ctx, n)
+	switch {
+	case errors.Is(err, aks.ErrComposite):
+		proof.Verdict = aks.Composite
+	case err != nil:
+		return nil, err
+	default:
+		proof.Certificate = c
+	}
+	return proof, nil
+}
+
+// Proves n prime or composite with the Lucas-Lehmer test, Pepin's
+// test, or Proth's theorem, if it has one of their forms, and fills
+// in proof. Returns false if n has none of those forms.
+func proveSpecialForm(n *big.Int, proof *Proof) (bool, error) {
+	var isPrime bool
+	var err error
+	switch {
+	case aks.IsMersenneNumber(n):
+		proof.Method = MethodLucasLehmer
+		isPrime, err = aks.LucasLehmer(n)
+	case aks.IsFermatNumber(n):
+		proof.Method = MethodPepin
+		isPrime, err = aks.Pepin(n)
+	case aks.IsProthNumber(n):
+		proof.Method = MethodProth
+		c, proveErr := aks.ProveProth(n)
+		switch {
+		case errors.Is(proveErr, aks.ErrProofNotFound):
+			// Fall back to the other methods.
+			return false, nil
+		case errors.Is(proveErr, aks.ErrComposite):
+		case proveErr != nil:
+			err = proveErr
+		default:
+			isPrime = true
+			proof.Certificate = c
+		}
+	default:
+		return false, nil
+	}
+	if err != nil {
+		return true, err
+	}
+	if !isPrime {
+		proof.Verdict = aks.Composite
+	}
+	return true, nil
+}
+
+// The methods proveWithNeighbors tries, in order, each with the
+// neighbor n + offset of n that it needs to factor easily. A variable
+// so that tests can make a method fail.
+var neighborProvers = []struct {
+	method Method
+	offset int64
+	prove  func(n *big.Int) (Certificate, error)
+}{
+	{MethodPocklington, -1, func(n *big.Int) (Certificate, error) {
+		return aks.ProvePocklington(n)
+	}},
+	{MethodNPlusOne, 1, func(n *big.Int) (Certificate, error) {
+		return aks.ProveNPlusOne(n)
+	}},
+}
+
+// Proves n prime with Pocklington's theorem if n - 1 factors easily,
+// and then with the N+1 test if n + 1 does, and fills in proof.
+// Returns false if neither factors easily, or if no proof is found
+// with the ones that do.
+func proveWithNeighbors(n *big.Int, proof *Proof) (bool, error) {
+	var m big.Int
+	for _, neighbor := range neighborProvers {
+		m.Add(n, big.NewInt(neighbor.offset))
+		if !factorsEasily(n, &m) {
+			continue
+		}
+		c, err := neighbor.prove(n)
+		if errors.Is(err, aks.ErrProofNotFound) {
+			continue
+		}
+		proof.Method = neighbor.method
+		switch {
+		case errors.Is(err, aks.ErrComposite):
+			proof.Verdict = aks.Composite
+			return true, nil
+		case err != nil:
+			return true, err
+		}
+		proof.Certificate = c
+		return true, nil
+	}
+	return false, nil
+}
+
+// Returns whether enough of m = n - 1 or n + 1 factors by trial
+// division up to neighborTrialDivisionBound for Pocklington's theorem
+// or the N+1 test to be likely to work, i.e., whether the part F that
+// factors is above sqrt(n), or what's left is a probable prime that
+// can be proven recursively.
+func factorsEasily(n, m *big.Int) bool {
+	bound := big.NewInt(neighborTrialDivisionBound)
+	F := big.NewInt(1)
+	cofactor := big.NewInt(1)
+	var pk big.Int
+	aks.TrialDivide(m, func(p, k *big.Int) bool {
+		if p.Cmp(bound) > 0 {
+			// What's left after trial division.
+			cofactor.Set(p)
+		} else {
+			F.Mul(F, pk.Exp(p, k, nil))
+		}
+		return true
+	}, bound)
+	if pk.Mul(F, F).Cmp(n) > 0 {
+		return true
+	}
+	isPrime, _ := aks.BailliePSW(cofactor)
+	return isPrime
+}
@@ -0,0 +1,168 @@
+package prover
+
+import "github.com/akalin/aks-go/aks"
+import "context"
+import "errors"
+import "math/big"
+import "testing"
+
+// Returns the smallest prime of the form 2kq + sign, with k >= 1.
+func findPrimeNearMultiple(q *big.Int, sign int64) *big.Int {
+	var p big.Int
+	for k := int64(1); ; k++ {
+		p.Mul(q, big.NewInt(2*k))
+		p.Add(&p, big.NewInt(sign))
+		if p.ProbablyPrime(20) {
+			return &p
+		}
+	}
+}
+
+// Returns the smallest prime >= n.
+func nextPrime(n *big.Int) *big.Int {
+	p := new(big.Int).Set(n)
+	for !p.ProbablyPrime(20) {
+		p.Add(p, big.NewInt(1))
+	}
+	return p
+}
+
+// Returns x * 2^k + c.
+func shiftAdd(x int64, k uint, c int64) *big.Int {
+	n := new(big.Int).Lsh(big.NewInt(x), k)
+	return n.Add(n, big.NewInt(c))
+}
+
+// Prove should pick the expected method for numbers of various forms
+// and sizes, reach the right verdict, and give certificates that
+// verify.
+func TestProve(t *testing.T) {
+	m127 := shiftAdd(1, 127, -1)
+	p80 := nextPrime(shiftAdd(1, 80, 0))
+	p90 := nextPrime(shiftAdd(1, 90, 0))
+	for _, c := range []struct {
+		n       *big.Int
+		verdict aks.Verdict
+		method  Method
+	}{
+		{big.NewInt(2), aks.Prime, MethodTrialDivision},
+		{big.NewInt(1000003), aks.Prime, MethodTrialDivision},
+		{big.NewInt(1000003 * 1009), aks.Composite,
+			MethodTrialDivision},
+		{shiftAdd(1, 61, -1), aks.Prime, MethodMillerRabin},
+		{big.NewInt(2147483647 * 2147483629), aks.Composite,
+			MethodMillerRabin},
+		{m127, aks.Prime, MethodLucasLehmer},
+		{shiftAdd(1, 257, -1), aks.Composite, MethodLucasLehmer},
+		{shiftAdd(1, 128, 1), aks.Composite, MethodPepin},
+		{shiftAdd(3, 189, 1), aks.Prime, MethodProth},
+		{shiftAdd(7, 192, 1), aks.Composite, MethodProth},
+		{new(big.Int).Mul(p80, p90), aks.Composite, MethodBPSW},
+		{findPrimeNearMultiple(m127, 1), aks.Prime,
+			MethodPocklington},
+		{findPrimeNearMultiple(m127, -1), aks.Prime,
+			MethodNPlusOne},
+		{nextPrime(shiftAdd(1, 200, 0)), aks.Prime, MethodECPP},
+	} {
+		proof, err := Prove(c.n, nil)
+		if err != nil {
+			t.Fatal(c.n, err)
+		}
+		if proof.N.Cmp(c.n) != 0 || proof.Verdict != c.verdict ||
+			proof.Method != c.method {
+			t.Error(c.n, proof.N, proof.Verdict, proof.Method)
+		}
+		if (proof.Factor != nil) != (c.verdict == aks.Composite &&
+			c.method == MethodTrialDivision) {
+			t.Error(c.n, proof.Factor)
+		}
+		if proof.Certificate != nil {
+			if err := proof.Certificate.Verify(); err != nil {
+				t.Error(c.n, err)
+			}
+		}
+		hasCertificate := c.verdict == aks.Prime &&
+			c.method >= MethodProth && c.method != MethodAKS
+		if (proof.Certificate != nil) != hasCertificate {
+			t.Error(c.n, proof.Certificate)
+		}
+	}
+}
+
+// With AKSMaxBits set, Prove should use AKS for numbers no faster
+// method handles.
+func TestProveAKS(t *testing.T) {
+	n := nextPrime(shiftAdd(1, 70, 0))
+	one := big.NewInt(1)
+	for factorsEasily(n, new(big.Int).Sub(n, one)) ||
+		factorsEasily(n, new(big.Int).Add(n, one)) {
+		n = nextPrime(n.Add(n, one))
+	}
+	proof, err := Prove(n, &Options{
+		AKSMaxBits: n.BitLen(),
+		// Only search part of the range, which is enough to
+		// check that AKS was used.
+		AKS: &aks.AKSOptions{
+			Verbosity: aks.VerbositySilent,
+			End:       big.NewInt(2),
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if proof.Method != MethodAKS || proof.Verdict != aks.Undetermined ||
+		proof.AKSResult == nil || proof.AKSResult.N.Cmp(n) != 0 {
+		t.Error(proof.Method, proof.Verdict, proof.AKSResult)
+	}
+}
+
+// If Pocklington's theorem fails for n, proveWithNeighbors should
+// still try the N+1 test when n + 1 factors easily too, and only give
+// up once that fails as well.
+func TestProveWithNeighborsFallback(t *testing.T) {
+	n := nextPrime(shiftAdd(1, 70, 0))
+	one := big.NewInt(1)
+	for !factorsEasily(n, new(big.Int).Sub(n, one)) ||
+		!factorsEasily(n, new(big.Int).Add(n, one)) {
+		n = nextPrime(n.Add(n, one))
+	}
+
+	saved := append(neighborProvers[:0:0], neighborProvers...)
+	defer func() { neighborProvers = saved }()
+	neighborProvers[0].prove = func(n *big.Int) (Certificate, error) {
+		return nil, aks.ErrProofNotFound
+	}
+	var proof Proof
+	found, err := proveWithNeighbors(n, &proof)
+	if !found || err != nil || proof.Method != MethodNPlusOne ||
+		proof.Certificate == nil {
+		t.Fatal(n, found, err, proof.Method)
+	}
+	if err := proof.Certificate.Verify(); err != nil {
+		t.Error(n, err)
+	}
+
+	neighborProvers[1].prove = neighborProvers[0].prove
+	proof = Proof{}
+	if found, err := proveWithNeighbors(n, &proof); found || err != nil {
+		t.Error(n, found, err, proof.Method)
+	}
+}
+
+// Prove should reject bad input and stop when its context is
+// cancelled.
+func TestProveErrors(t *testing.T) {
+	for _, n := range []*big.Int{big.NewInt(-1), big.NewInt(1)} {
+		if proof, err := Prove(n, nil); !errors.Is(
+			err, aks.ErrBadInput) {
+			t.Error(n, proof, err)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	n := nextPrime(shiftAdd(1, 200, 0))
+	if proof, err := ProveContext(ctx, n, nil); err != context.Canceled {
+		t.Error(proof, err)
+	}
+}
@@ -0,0 +1,201 @@
+package aks
+
+import "encoding/json"
+import "fmt"
+import "iter"
+import "math/big"
+
+// A Poly is a polynomial mod (N, X^R - 1), i.e. one of the
+// polynomials the AKS witness test computes with, backed by one of
+// the polynomial backends. Its coefficients are always in [0, N).
+//
+// A Poly can be encoded with encoding/gob and encoding/json. The
+// encoding doesn't depend on the backend. Decoding into a zero Poly
+// picks the backend as AutoPolyBackend would, and decoding into an
+// existing Poly keeps its backend.
+//
+// A Poly isn't safe to use from multiple goroutines at once.
+type Poly struct {
+	n, r    big.Int
+	backend PolyBackend
+	p       poly
+}
+
+// Makes a Poly representing the zero polynomial mod (N, X^R - 1) with
+// the given backend, which may be AutoPolyBackend. N and R must be >=
+// 2 and R must fit into an int.
+func NewPoly(N, R *big.Int, backend PolyBackend) (*Poly, error) {
+	if err := checkAKSWitnessArgs(N, R, 1); err != nil {
+		return nil, err
+	}
+	if backend == AutoPolyBackend {
+		backend = pickPolyBackend(N, R)
+	}
+	ring, err := newPolyRing(N, R, backend)
+	if err != nil {
+		return nil, err
+	}
+	p := &Poly{backend: backend, p: ring.newPoly()}
+	p.n.Set(N)
+	p.r.Set(R)
+	return p, nil
+}
+
+// Returns a copy of N.
+func (p *Poly) N() *big.Int {
+	return new(big.Int).Set(&p.n)
+}
+
+// Returns a copy of R.
+func (p *Poly) R() *big.Int {
+	return new(big.Int).Set(&p.r)
+}
+
+// Returns the backend used, which is never AutoPolyBackend.
+func (p *Poly) Backend() PolyBackend {
+	return p.backend
+}
+
+// Returns the degree of p, or -1 if p is the zero polynomial.
+func (p *Poly) Degree() int {
+	for i := int(p.r.Int64()) - 1; i >= 0; i-- {
+		if p.p.coefficient(i).Sign() != 0 {
+			return i
+		}
+	}
+	return -1
+}
+
+// Returns a copy of the coefficient of x^i. Panics if i isn't in
+// [0, R).
+func (p *Poly) Coefficient(i int) *big.Int {
+	p.checkIndex(i)
+	return p.p.coefficient(i)
+}
+
+// Sets the coefficient of x^i to v mod N. Panics if i isn't in
+// [0, R).
+func (p *Poly) SetCoefficient(i int, v *big.Int) {
+	p.checkIndex(i)
+	var c big.Int
+	c.Mod(v, &p.n)
+	p.p.setCoefficient(i, &c)
+}
+
+// Panics if i isn't a valid index for p's coefficients.
+func (p *Poly) checkIndex(i int) {
+	if i < 0 || int64(i) >= p.r.Int64() {
+		panic(fmt.Sprintf("index %d out of range [0, %v)", i, &p.r))
+	}
+}
+
+// Returns an iterator over the degrees and copies of the non-zero
+// coefficients of p, in order of increasing degree. p mustn't be
+// modified during the iteration.
+func (p *Poly) Terms() iter.Seq2[int, *big.Int] {
+	return func(yield func(int, *big.Int) bool) {
+		rInt := int(p.r.Int64())
+		for i := 0; i < rInt; i++ {
+			c := p.p.coefficient(i)
+			if c.Sign() != 0 && !yield(i, c) {
+				return
+			}
+		}
+	}
+}
+
+// Sets p to X^k + a mod (N, X^R - 1).
+func (p *Poly) Set(a, k *big.Int) {
+	p.p.Set(*a, *k, p.n)
+}
+
+// Returns a new Poly equal to p, with the same backend.
+func (p *Poly) Clone() *Poly {
+	c := &Poly{backend: p.backend, p: p.p.clone()}
+	c.n.Set(&p.n)
+	c.r.Set(&p.r)
+	return c
+}
+
+// Sets p to q, which may have a different backend; p keeps its own.
+// Panics with an error wrapping ErrBadInput if p and q aren't mod
+// the same N and R.
+func (p *Poly) CopyFrom(q *Poly) {
+	if p.n.Cmp(&q.n) != 0 || p.r.Cmp(&q.r) != 0 {
+		panicModulusMismatch(
+			"copy", &p.n, int(p.r.Int64()), &q.n, int(q.r.Int64()))
+	}
+	if p.backend == q.backend {
+		p.p.copyFrom(q.p)
+		return
+	}
+	rInt := int(p.r.Int64())
+	for i := 0; i < rInt; i++ {
+		p.p.setCoefficient(i, q.p.coefficient(i))
+	}
+}
+
+// Returns whether p and q have the same N, R, and coefficients. They
+// may have different backends.
+func (p *Poly) Eq(q *Poly) bool {
+	if p.n.Cmp(&q.n) != 0 || p.r.Cmp(&q.r) != 0 {
+		return false
+	}
+	if p.backend == q.backend {
+		return p.p.Eq(q.p)
+	}
+	rInt := int(p.r.Int64())
+	for i := 0; i < rInt; i++ {
+		if p.p.coefficient(i).Cmp(q.p.coefficient(i)) != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// fmt.Formatter implementation.
+func (p *Poly) Format(f fmt.State, c rune) {
+	p.p.Format(f, c)
+}
+
+// gob.GobEncoder implementation.
+func (p *Poly) GobEncode() ([]byte, error) {
+	return marshalPoly(p.p, p.n, p.r, gobMarshal)
+}
+
+// gob.GobDecoder implementation.
+func (p *Poly) GobDecode(data []byte) error {
+	return p.unmarshal(data, gobUnmarshal)
+}
+
+// json.Marshaler implementation.
+func (p *Poly) MarshalJSON() ([]byte, error) {
+	return marshalPoly(p.p, p.n, p.r, json.Marshal)
+}
+
+// json.Unmarshaler implementation.
+func (p *Poly) UnmarshalJSON(data []byte) error {
+	return p.unmarshal(data, json.Unmarshal)
+}
+
+// Sets p to data, as marshaled by GobEncode or MarshalJSON, with
+// unmarshal. Returns an error wrapping ErrBadInput if the encoded
+// polynomial is invalid or unsupported by p's backend.
+func (p *Poly) unmarshal(
+	data []byte, unmarshal func([]byte, interface{}) error) error {
+	var e encodedPoly
+	if err := unmarshal(data, &e); err != nil {
+		return err
+	}
+	q, err := e.decode(p.backend)
+	if err != nil {
+		return err
+	}
+	if p.backend == AutoPolyBackend {
+		p.backend = pickPolyBackend(e.N, e.R)
+	}
+	p.n.Set(e.N)
+	p.r.Set(e.R)
+	p.p = q
+	return nil
+}
@@ -0,0 +1,217 @@
+package aks
+
+import "encoding/json"
+import "errors"
+import "fmt"
+import "math/big"
+import "testing"
+
+// Polys should be buildable and inspectable coefficient by
+// coefficient with every backend.
+func TestPoly(t *testing.T) {
+	N := big.NewInt(101)
+	R := big.NewInt(53)
+	for _, backend := range []PolyBackend{
+		AutoPolyBackend, BigIntPolyBackend, WordPolyBackend,
+		NTTPolyBackend,
+	} {
+		p, err := NewPoly(N, R, backend)
+		if err != nil {
+			t.Fatal(backend, err)
+		}
+		if p.Backend() == AutoPolyBackend {
+			t.Error(backend)
+		}
+		if p.Degree() != -1 {
+			t.Error(backend, p.Degree())
+		}
+
+		p.SetCoefficient(0, big.NewInt(7))
+		p.SetCoefficient(5, big.NewInt(3))
+		p.SetCoefficient(1, big.NewInt(-99))
+		if str := fmt.Sprint(p); str != "3x^5 + 2x + 7" {
+			t.Error(backend, str)
+		}
+		if p.Degree() != 5 {
+			t.Error(backend, p.Degree())
+		}
+		if c := p.Coefficient(1); c.Cmp(big.NewInt(2)) != 0 {
+			t.Error(backend, c)
+		}
+		if c := p.Coefficient(52); c.Sign() != 0 {
+			t.Error(backend, c)
+		}
+
+		var terms []string
+		for i, c := range p.Terms() {
+			terms = append(terms, fmt.Sprintf("%d:%v", i, c))
+		}
+		if str := fmt.Sprint(terms); str != "[0:7 1:2 5:3]" {
+			t.Error(backend, str)
+		}
+		for i := range p.Terms() {
+			if i != 0 {
+				t.Error(backend, i)
+			}
+			break
+		}
+
+		q, err := NewPoly(N, R, BigIntPolyBackend)
+		if err != nil {
+			t.Fatal(err)
+		}
+		q.Set(big.NewInt(7), big.NewInt(5))
+		if p.Eq(q) {
+			t.Error(backend, p, q)
+		}
+		q.SetCoefficient(5, big.NewInt(3))
+		q.SetCoefficient(1, big.NewInt(2))
+		if !p.Eq(q) {
+			t.Error(backend, p, q)
+		}
+	}
+}
+
+// NewPoly should reject bad input, and the accessors should panic
+// for out-of-range indices.
+func TestPolyBadInput(t *testing.T) {
+	if _, err := NewPoly(
+		big.NewInt(1), big.NewInt(5),
+		AutoPolyBackend); !errors.Is(err, ErrBadInput) {
+		t.Error(err)
+	}
+	if _, err := NewPoly(
+		new(big.Int).Lsh(big.NewInt(1), 64), big.NewInt(5),
+		WordPolyBackend); !errors.Is(err, ErrBadInput) {
+		t.Error(err)
+	}
+
+	p, err := NewPoly(big.NewInt(101), big.NewInt(5), AutoPolyBackend)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, i := range []int{-1, 5} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Error(i)
+				}
+			}()
+			p.Coefficient(i)
+		}()
+	}
+}
+
+// A Poly should decode to an equal Poly, keeping the backend of the
+// Poly decoded into, or picking one for a zero Poly.
+func TestPolyEncoding(t *testing.T) {
+	N := big.NewInt(1000003)
+	R := big.NewInt(53)
+	p, err := NewPoly(N, R, BigIntPolyBackend)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.Set(big.NewInt(999999), big.NewInt(7))
+	p.SetCoefficient(30, big.NewInt(12345))
+	for _, encoding := range encodings {
+		data, err := encoding.marshal(p)
+		if err != nil {
+			t.Fatal(encoding.name, err)
+		}
+
+		var decoded Poly
+		if err := encoding.unmarshal(data, &decoded); err != nil {
+			t.Fatal(encoding.name, err)
+		}
+		if !decoded.Eq(p) || decoded.Backend() != WordPolyBackend {
+			t.Error(encoding.name, &decoded, decoded.Backend())
+		}
+
+		q, err := NewPoly(big.NewInt(101), big.NewInt(5), NTTPolyBackend)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := encoding.unmarshal(data, q); err != nil {
+			t.Fatal(encoding.name, err)
+		}
+		if !q.Eq(p) || q.Backend() != NTTPolyBackend {
+			t.Error(encoding.name, q, q.Backend())
+		}
+		if q.N().Cmp(N) != 0 || q.R().Cmp(R) != 0 {
+			t.Error(encoding.name, q.N(), q.R())
+		}
+	}
+
+	// A Poly too large for a word can't be decoded into a Poly
+	// with WordPolyBackend.
+	large, err := NewPoly(
+		new(big.Int).Lsh(big.NewInt(1), 64), R, BigIntPolyBackend)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := json.Marshal(large)
+	if err != nil {
+		t.Fatal(err)
+	}
+	q, err := NewPoly(N, R, WordPolyBackend)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(data, q); !errors.Is(err, ErrBadInput) {
+		t.Error(err)
+	}
+	if q.N().Cmp(N) != 0 || q.Backend() != WordPolyBackend {
+		t.Error(q.N(), q.Backend())
+	}
+}
+
+// Clone should make an independent copy, and CopyFrom should copy
+// between Polys of any backends, but not of different moduli.
+func TestPolyCloneAndCopyFrom(t *testing.T) {
+	N := big.NewInt(101)
+	R := big.NewInt(53)
+	backends := []PolyBackend{
+		BigIntPolyBackend, WordPolyBackend, NTTPolyBackend,
+	}
+	for _, backend := range backends {
+		p, err := NewPoly(N, R, backend)
+		if err != nil {
+			t.Fatal(backend, err)
+		}
+		p.Set(big.NewInt(7), big.NewInt(5))
+		c := p.Clone()
+		if !c.Eq(p) || c.Backend() != backend {
+			t.Error(backend, c, c.Backend())
+		}
+		c.SetCoefficient(3, big.NewInt(1))
+		if str := fmt.Sprint(p); str != "x^5 + 7" {
+			t.Error(backend, str)
+		}
+
+		for _, qBackend := range backends {
+			q, err := NewPoly(N, R, qBackend)
+			if err != nil {
+				t.Fatal(qBackend, err)
+			}
+			q.SetCoefficient(52, big.NewInt(9))
+			q.CopyFrom(c)
+			if !q.Eq(c) || q.Backend() != qBackend {
+				t.Error(backend, qBackend, q, q.Backend())
+			}
+		}
+
+		other, err := NewPoly(N, big.NewInt(59), backend)
+		if err != nil {
+			t.Fatal(backend, err)
+		}
+		func() {
+			defer func() {
+				err, _ := recover().(error)
+				if !errors.Is(err, ErrBadInput) {
+					t.Error(backend, err)
+				}
+			}()
+			p.CopyFrom(other)
+		}()
+	}
+}
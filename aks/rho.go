@@ -0,0 +1,256 @@
+package aks
+
+import "context"
+import "fmt"
+import "math/big"
+import "sort"
+
+// The number of rounds factorize and PollardRho pass to
+// big.Int.ProbablyPrime to decide whether a number is prime. (It is
+// exact for numbers less than 2^64 regardless.)
+const primalityRounds = 20
+
+// factorize only does trial division up to this bound before
+// switching to Pollard's rho algorithm, which finds a factor p in
+// about sqrt(p) steps instead of p/4.
+var rhoTrialDivisionBound = big.NewInt(1 << 16)
+
+// The number of steps pollardRhoBrent takes between gcds.
+const rhoBatchSize = 128
+
+// The number of steps factorize lets Pollard's rho algorithm take
+// before switching to ECM, which is enough to find factors up to
+// around 2^36 most of the time.
+const rhoMaxSteps = 1 << 18
+
+// Returns a nontrivial factor of n using Pollard's rho algorithm with
+// Brent's cycle detection, which takes about sqrt(p) steps for the
+// smallest prime factor p of n. Returns an error wrapping ErrBadInput
+// if n < 2 or n is prime (as decided by big.Int.ProbablyPrime, which
+// is exact for n < 2^64).
+func PollardRho(n *big.Int) (*big.Int, error) {
+	if n.Cmp(big.NewInt(2)) < 0 {
+		return nil, fmt.Errorf("%w: n = %v must be >= 2", ErrBadInput, n)
+	}
+	if n.ProbablyPrime(primalityRounds) {
+		return nil, fmt.Errorf("%w: n = %v is prime", ErrBadInput, n)
+	}
+	return pollardRho(n, 0), nil
+}
+
+// Like PollardRho, but assumes n is composite. If maxSteps is
+// positive, gives up and returns nil after trying a single sequence
+// for about that many steps.
+func pollardRho(n *big.Int, maxSteps int) *big.Int {
+	if n.Bit(0) == 0 {
+		return big.NewInt(2)
+	}
+	if maxSteps > 0 {
+		return pollardRhoBrent(n, big.NewInt(1), maxSteps)
+	}
+	// Each c gives a different pseudorandom sequence. Failure
+	// (finding n itself) is rare, so this rarely gets past c = 1.
+	one := big.NewInt(1)
+	for c := big.NewInt(1); ; c.Add(c, one) {
+		if d := pollardRhoBrent(n, c, 0); d != nil {
+			return d
+		}
+	}
+}
+
+// Runs Pollard's rho algorithm with Brent's cycle detection on the
+// sequence x_0 = 2, x_{i+1} = x_i^2 + c (mod n) to find a nontrivial
+// factor of n, which must be odd and composite. Returns nil if the
+// cycle is found modulo every factor of n at once, or if maxSteps is
+// positive and no factor is found within about that many steps.
+func pollardRhoBrent(n, c *big.Int, maxSteps int) *big.Int {
+	one := big.NewInt(1)
+	f := func(x *big.Int) {
+		x.Mul(x, x)
+		x.Add(x, c)
+		x.Mod(x, n)
+	}
+
+	// Compare x_i against x_j for j in (i, 2i], where i runs
+	// through the powers of 2, and accumulate the differences
+	// into a product so only one gcd is needed per batch.
+	var x, ys, diff, g big.Int
+	y := big.NewInt(2)
+	q := big.NewInt(1)
+	g.Set(one)
+	for i := 1; g.Cmp(one) == 0; i *= 2 {
+		if maxSteps > 0 && 2*i > maxSteps {
+			return nil
+		}
+		x.Set(y)
+		for j := 0; j < i; j++ {
+			f(y)
+		}
+		for k := 0; k < i && g.Cmp(one) == 0; k += rhoBatchSize {
+			ys.Set(y)
+			for j := 0; j < rhoBatchSize && j < i-k; j++ {
+				f(y)
+				diff.Sub(&x, y)
+				q.Mul(q, diff.Abs(&diff))
+				q.Mod(q, n)
+			}
+			g.GCD(nil, nil, q, n)
+		}
+	}
+	if g.Cmp(n) == 0 {
+		// The batch overshot, so redo it one step at a time
+		// from its start.
+		for g.Cmp(one) == 0 || g.Cmp(n) == 0 {
+			f(&ys)
+			diff.Sub(&x, &ys)
+			g.GCD(nil, nil, diff.Abs(&diff), n)
+			if g.Cmp(n) == 0 {
+				return nil
+			}
+		}
+	}
+	return &g
+}
+
+// Like trialDivide(n, factorFn, nil), but only does trial division
+// up to rhoTrialDivisionBound, and then finds the prime factors of
+// what is left with SQUFOF (if it fits) or Pollard's rho algorithm,
+// switching to ECM with the default options if rho doesn't find a
+// factor within rhoMaxSteps (and back to rho, without a limit, if ECM
+// doesn't either). Cofactors are taken to be prime if isProbablePrime
+// says so, which is exact below 2^64.
+func factorize(n *big.Int, factorFn FactorFunction) {
+	factorizeWithin(n, factorFn, nil)
+}
+
+// Like factorize, but doesn't fall back to rho without a limit:
+// whatever both rho and ECM fail to split is left unfactored, and
+// isn't passed to factorFn. Returns the product of the parts left
+// unfactored, which is 1 if n was factored completely (or if factorFn
+// stopped early).
+func factorizeBounded(n *big.Int, factorFn FactorFunction) *big.Int {
+	unfactored := big.NewInt(1)
+	factorizeWithin(n, factorFn, unfactored)
+	return unfactored
+}
+
+// Does the work for factorize and factorizeBounded, where unfactored
+// is nil for the former.
+func factorizeWithin(
+	n *big.Int, factorFn FactorFunction, unfactored *big.Int) {
+	if n.Sign() < 0 {
+		panic("negative n")
+	}
+	if n.Sign() == 0 {
+		return
+	}
+
+	root, _ := sqrtExact(n)
+	upperBound := min(root, rhoTrialDivisionBound)
+	var rest *big.Int
+	stopped := false
+	trialDivide(n, func(p, m *big.Int) bool {
+		// Every factor found by division is at most
+		// upperBound, so anything larger is what is left.
+		if p.Cmp(upperBound) > 0 {
+			rest = p
+			return true
+		}
+		if !factorFn(p, m) {
+			stopped = true
+			return false
+		}
+		return true
+	}, upperBound)
+	if stopped || rest == nil {
+		return
+	}
+
+	primes := appendPrimeFactors(nil, rest, unfactored)
+	if unfactored != nil {
+		// Make sure the primes found are divided out of the
+		// unfactored part completely, so that their
+		// multiplicities are right.
+		var q, r big.Int
+		for _, p := range primes {
+			for {
+				q.QuoRem(unfactored, p, &r)
+				if r.Sign() != 0 {
+					break
+				}
+				unfactored.Set(&q)
+				primes = append(primes, p)
+			}
+		}
+	}
+	sort.Slice(primes, func(i, j int) bool {
+		return primes[i].Cmp(primes[j]) < 0
+	})
+	one := big.NewInt(1)
+	for i := 0; i < len(primes); {
+		m := big.NewInt(1)
+		j := i + 1
+		for ; j < len(primes) && primes[j].Cmp(primes[i]) == 0; j++ {
+			m.Add(m, one)
+		}
+		if !factorFn(primes[i], m) {
+			return
+		}
+		i = j
+	}
+}
+
+// Appends the prime factors of n > 1 to primes, with multiplicity and
+// in no particular order. If unfactored isn't nil, parts of n that
+// can't be split without running rho without a limit are multiplied
+// into it instead.
+func appendPrimeFactors(
+	primes []*big.Int, n *big.Int, unfactored *big.Int) []*big.Int {
+	if isProbablePrime(n) {
+		return append(primes, n)
+	}
+	// Pollard's rho algorithm takes about sqrt(p) steps on p^k,
+	// so factor the base of perfect powers instead.
+	if b, k := getPerfectPower(n); k > 1 {
+		var bUnfactored *big.Int
+		if unfactored != nil {
+			bUnfactored = big.NewInt(1)
+		}
+		for _, p := range appendPrimeFactors(nil, b, bUnfactored) {
+			for i := int64(0); i < k; i++ {
+				primes = append(primes, p)
+			}
+		}
+		if unfactored != nil {
+			bUnfactored.Exp(bUnfactored, big.NewInt(k), nil)
+			unfactored.Mul(unfactored, bUnfactored)
+		}
+		return primes
+	}
+	// SQUFOF is faster than rho for n this small, since it works
+	// with machine words.
+	var d *big.Int
+	if n.BitLen() <= squfofMaxBits {
+		if f := squfof(n.Int64()); f != 0 {
+			d = big.NewInt(f)
+		}
+	}
+	if d == nil {
+		d = pollardRho(n, rhoMaxSteps)
+	}
+	if d == nil {
+		o := (*ECMOptions)(nil).withDefaults()
+		// This can't fail, since the context is never cancelled.
+		d, _ = ecm(context.Background(), n, &o)
+	}
+	if d == nil {
+		if unfactored != nil {
+			unfactored.Mul(unfactored, n)
+			return primes
+		}
+		d = pollardRho(n, 0)
+	}
+	primes = appendPrimeFactors(primes, d, unfactored)
+	return appendPrimeFactors(
+		primes, new(big.Int).Quo(n, d), unfactored)
+}
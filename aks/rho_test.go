@@ -0,0 +1,127 @@
+package aks
+
+import "errors"
+import "fmt"
+import "math/big"
+import "testing"
+
+// Returns the product of the given numbers, which are in decimal.
+func mustMultiply(t *testing.T, factors ...string) *big.Int {
+	product := big.NewInt(1)
+	for _, s := range factors {
+		x, ok := new(big.Int).SetString(s, 10)
+		if !ok {
+			t.Fatal(s)
+		}
+		product.Mul(product, x)
+	}
+	return product
+}
+
+// PollardRho should find a nontrivial factor of composites and
+// reject primes and numbers less than 2.
+func TestPollardRho(t *testing.T) {
+	for _, n := range []*big.Int{
+		big.NewInt(4),
+		big.NewInt(10403),
+		mustMultiply(t, "1000003", "1000033"),
+		mustMultiply(t, "274177", "67280421310721"),
+		mustMultiply(t, "4294967311", "4294967311"),
+		mustMultiply(t, "1000003", "1000003", "1000003"),
+	} {
+		d, err := PollardRho(n)
+		if err != nil {
+			t.Fatal(n, err)
+		}
+		var m big.Int
+		if d.Cmp(big.NewInt(1)) <= 0 || d.Cmp(n) >= 0 ||
+			m.Mod(n, d).Sign() != 0 {
+			t.Error(n, d)
+		}
+	}
+
+	for _, n := range []int64{-1, 0, 1, 2, 13, 1000003} {
+		if d, err := PollardRho(big.NewInt(n)); !errors.Is(
+			err, ErrBadInput) {
+			t.Error(n, d, err)
+		}
+	}
+}
+
+// Returns the factors passed by factorize or trialDivide as a string.
+func getFactorString(
+	n *big.Int, factor func(*big.Int, FactorFunction)) string {
+	s := ""
+	factor(n, func(p, m *big.Int) bool {
+		s += fmt.Sprintf("%v^%v ", p, m)
+		return true
+	})
+	return s
+}
+
+// factorize should give the same factors as trialDivide, and should
+// find large ones quickly.
+func TestFactorize(t *testing.T) {
+	trialDivideAll := func(n *big.Int, factorFn FactorFunction) {
+		trialDivide(n, factorFn, nil)
+	}
+	for _, n := range []int64{
+		0, 1, 2, 65536, 65537, 65537 * 65537, 65539 * 65543,
+		2 * 3 * 3 * 1000003, 1000003 * 1000033,
+	} {
+		x := big.NewInt(n)
+		actual := getFactorString(x, factorize)
+		expected := getFactorString(x, trialDivideAll)
+		if actual != expected {
+			t.Error(n, actual, expected)
+		}
+	}
+
+	// 2^61 - 1 is prime.
+	n := mustMultiply(t, "12", "274177", "1000003", "1000003",
+		"2305843009213693951", "2305843009213693951")
+	expected := "2^2 3^1 274177^1 1000003^2 2305843009213693951^2 "
+	if s := getFactorString(n, factorize); s != expected {
+		t.Error(s)
+	}
+
+	// Stopping early should work in either stage.
+	for _, stopAt := range []int{1, 3} {
+		count := 0
+		factorize(n, func(p, m *big.Int) bool {
+			count++
+			return count < stopAt
+		})
+		if count != stopAt {
+			t.Error(stopAt, count)
+		}
+	}
+}
+
+// factorizeBounded should factor numbers it can split completely,
+// just like factorize.
+func TestFactorizeBounded(t *testing.T) {
+	n := mustMultiply(t, "12", "274177", "1000003", "1000003",
+		"2305843009213693951", "2305843009213693951")
+	s := ""
+	c := factorizeBounded(n, func(p, m *big.Int) bool {
+		s += fmt.Sprintf("%v^%v ", p, m)
+		return true
+	})
+	if s != getFactorString(n, factorize) || c.Int64() != 1 {
+		t.Error(s, c)
+	}
+}
+
+// EulerPhi should handle numbers with large prime factors.
+func TestEulerPhiLargeFactors(t *testing.T) {
+	n := mustMultiply(t, "1099511627791", "1099511627831")
+	phi, err := EulerPhi(n)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := mustMultiply(t, "1099511627790", "1099511627830")
+	if phi.Cmp(expected) != 0 {
+		t.Error(phi, expected)
+	}
+}
@@ -0,0 +1,239 @@
+package aks
+
+import "iter"
+import "sort"
+import "sync"
+
+// The size of the first segment Primes sieves. Segments start small
+// and double up to maxPrimeSegmentSize, so that taking just the first
+// few primes from Primes is cheap.
+const minPrimeSegmentSize = 1 << 8
+
+// The size of the largest segment Primes sieves, which is small
+// enough to fit in a typical L1 or L2 cache.
+const maxPrimeSegmentSize = 1 << 16
+
+// Primes below this are looked up in a table instead of being
+// sieved, which makes the trial division of large numbers by them
+// much faster. The table takes about 300 KB.
+const smallPrimeTableBound = 1000000
+
+var smallPrimeTableOnce sync.Once
+var smallPrimeTable []uint32
+
+// Returns the primes below smallPrimeTableBound, which are found the
+// first time this is called.
+func getSmallPrimeTable() []uint32 {
+	smallPrimeTableOnce.Do(func() {
+		primes := getPrimesIn(2, smallPrimeTableBound)
+		smallPrimeTable = make([]uint32, len(primes))
+		for i, p := range primes {
+			smallPrimeTable[i] = uint32(p)
+		}
+	})
+	return smallPrimeTable
+}
+
+// Returns the primes in [start, end) in increasing order, using a
+// sieve of Eratosthenes on just that range (with the primes up to
+// sqrt(end) found by sieving those first). Uses O(end - start +
+// sqrt(end)) memory; use Primes instead for large ranges.
+func getPrimesIn(start, end uint64) []uint64 {
+	if start < 2 {
+		start = 2
+	}
+	if end <= start {
+		return nil
+	}
+
+	sqrtEnd := floorSqrt(end - 1)
+	var basePrimes []uint64
+	if sqrtEnd >= 2 {
+		basePrimes = getPrimesIn(2, sqrtEnd+1)
+	}
+
+	composite := make([]bool, end-start)
+	markComposites(composite, start, basePrimes)
+
+	var primes []uint64
+	for i, isComposite := range composite {
+		if !isComposite {
+			primes = append(primes, start+uint64(i))
+		}
+	}
+	return primes
+}
+
+// Sets composite[i] for each i such that start + i is a multiple of
+// some p in primes other than p itself. primes must contain every
+// prime up to sqrt(start + len(composite) - 1) for the unset entries
+// to be exactly the primes.
+func markComposites(composite []bool, start uint64, primes []uint64) {
+	end := start + uint64(len(composite))
+	for _, p := range primes {
+		// Start at the first multiple of p in the range, but
+		// no lower than p^2 so that p itself is kept.
+		first := start + (p-start%p)%p
+		if first < start {
+			// There is no multiple of p in [start, 2^64).
+			continue
+		}
+		if first < p*p {
+			first = p * p
+		}
+		// m wraps around instead of reaching end if end is
+		// within p of 2^64.
+		for m := first; m < end && m >= first; m += p {
+			composite[m-start] = true
+		}
+	}
+}
+
+// Returns an iterator over the primes in [start, end) in increasing
+// order. Primes below 10^6 come from a table; larger ones are found
+// with a segmented sieve of Eratosthenes, which starts each segment
+// from a wheel pattern with the multiples of 2, 3, 5, and 7 already
+// marked. Only one segment of at most maxPrimeSegmentSize numbers is
+// sieved at a time, and the primes to sieve with are found as they're
+// needed, so the memory used is O(sqrt(p)) for the largest prime p
+// reached, no matter how large the range is. The iterator can be used
+// more than once.
+func Primes(start, end uint64) iter.Seq[uint64] {
+	return func(yield func(uint64) bool) {
+		// Don't change start, so that the iterator can be
+		// reused.
+		lo := start
+		if lo < 2 {
+			lo = 2
+		}
+		if end <= lo {
+			return
+		}
+		if lo < smallPrimeTableBound {
+			table := getSmallPrimeTable()
+			i := sort.Search(len(table), func(i int) bool {
+				return uint64(table[i]) >= lo
+			})
+			for _, p := range table[i:] {
+				if uint64(p) >= end || !yield(uint64(p)) {
+					return
+				}
+			}
+			lo = smallPrimeTableBound
+			if end <= lo {
+				return
+			}
+		}
+		sievePrimes(lo, end, primeSieveWheel, yield)
+	}
+}
+
+// Passes the primes in [start, end) to yield in increasing order,
+// until it returns false, sieving a segment at a time as described
+// in Primes. The multiples of w's primes are marked by copying w's
+// pattern instead of one by one, so start must be greater than w's
+// largest prime.
+func sievePrimes(
+	start, end uint64, w *sieveWheel, yield func(uint64) bool) {
+	if end <= start {
+		return
+	}
+	segmentSize := uint64(minPrimeSegmentSize)
+	// The primes below baseEnd.
+	var basePrimes []uint64
+	baseEnd := uint64(2)
+	maxBaseEnd := floorSqrt(end-1) + 1
+	var composite []bool
+	for lo := start; lo < end; {
+		hi := end
+		if end-lo > segmentSize {
+			hi = lo + segmentSize
+		}
+		if sqrtHi := floorSqrt(hi-1) + 1; sqrtHi > baseEnd {
+			// Find more primes than needed for this
+			// segment, so that this is done only O(log
+			// end) times.
+			newBaseEnd := 2 * baseEnd
+			if newBaseEnd < sqrtHi {
+				newBaseEnd = sqrtHi
+			}
+			if newBaseEnd > maxBaseEnd {
+				newBaseEnd = maxBaseEnd
+			}
+			basePrimes = append(basePrimes,
+				getPrimesIn(baseEnd, newBaseEnd)...)
+			baseEnd = newBaseEnd
+		}
+
+		if uint64(cap(composite)) < hi-lo {
+			composite = make([]bool, hi-lo)
+		}
+		composite = composite[:hi-lo]
+		w.fill(composite, lo)
+		// basePrimes starts with whichever of w's primes
+		// it has, which are already marked.
+		wheelPrimeCount := len(w.primes)
+		if wheelPrimeCount > len(basePrimes) {
+			wheelPrimeCount = len(basePrimes)
+		}
+		markComposites(
+			composite, lo, basePrimes[wheelPrimeCount:])
+		for i, isComposite := range composite {
+			if !isComposite && !yield(lo+uint64(i)) {
+				return
+			}
+		}
+
+		lo = hi
+		if segmentSize < maxPrimeSegmentSize {
+			segmentSize *= 2
+		}
+	}
+}
+
+// Holds which residues mod the product of the first few primes are
+// divisible by one of them, so that a sieve segment can start out
+// with their multiples already marked.
+type sieveWheel struct {
+	primes  []uint64
+	modulus uint64
+	// pattern[i] is whether i shares a factor with modulus.
+	pattern []bool
+}
+
+// The number of primes in the wheel Primes sieves with. The mod-210
+// wheel for {2, 3, 5, 7} makes sieving about 20% faster than no wheel
+// at all; the mod-2310 wheel for {2, 3, 5, 7, 11} is no faster still,
+// since by then marking multiples is no longer what takes the most
+// time.
+const primeSieveWheelSize = 4
+
+var primeSieveWheel = newSieveWheel(primeSieveWheelSize)
+
+// Returns the wheel for the first k >= 0 primes. k should be small,
+// since the wheel's pattern takes a byte for each residue.
+func newSieveWheel(k int) *sieveWheel {
+	primes := getPrimesIn(2, 24)[:k]
+	modulus := uint64(1)
+	for _, p := range primes {
+		modulus *= p
+	}
+	pattern := make([]bool, modulus)
+	for _, p := range primes {
+		for m := p; m < modulus; m += p {
+			pattern[m] = true
+		}
+	}
+	pattern[0] = k > 0
+	return &sieveWheel{primes, modulus, pattern}
+}
+
+// Sets composite[i] to whether start + i is divisible by one of w's
+// primes.
+func (w *sieveWheel) fill(composite []bool, start uint64) {
+	offset := start % w.modulus
+	for i := 0; i < len(composite); {
+		i += copy(composite[i:], w.pattern[offset:])
+		offset = 0
+	}
+}
@@ -0,0 +1,98 @@
+package aks
+
+import "fmt"
+import "math"
+import "math/big"
+import "testing"
+
+// getPrimesIn should agree with big.Int.ProbablyPrime, including at
+// the edges of the range.
+func TestGetPrimesIn(t *testing.T) {
+	if str := fmt.Sprint(getPrimesIn(0, 30)); str !=
+		"[2 3 5 7 11 13 17 19 23 29]" {
+		t.Error(str)
+	}
+	for _, r := range [][2]uint64{
+		{0, 0}, {0, 2}, {2, 3}, {24, 29}, {1000, 1100}, {7919, 7920},
+		{1 << 32, 1<<32 + 1000},
+	} {
+		var expected []uint64
+		for i := r[0]; i < r[1]; i++ {
+			if new(big.Int).SetUint64(i).ProbablyPrime(0) {
+				expected = append(expected, i)
+			}
+		}
+		primes := getPrimesIn(r[0], r[1])
+		if fmt.Sprint(primes) != fmt.Sprint(expected) {
+			t.Error(r, primes, expected)
+		}
+	}
+}
+
+// Primes should agree with getPrimesIn, including across segment
+// boundaries, and stop early when asked to.
+func TestPrimes(t *testing.T) {
+	for _, r := range [][2]uint64{
+		{0, 0}, {0, 2}, {0, 30}, {5, 3}, {0, 1 << 20},
+		{999000, smallPrimeTableBound},
+		{smallPrimeTableBound - 100, smallPrimeTableBound + 100},
+		{smallPrimeTableBound, smallPrimeTableBound + 100},
+		{1000, 1000 + maxPrimeSegmentSize + 1},
+		{1 << 48, 1<<48 + 3*maxPrimeSegmentSize},
+	} {
+		var primes []uint64
+		for p := range Primes(r[0], r[1]) {
+			primes = append(primes, p)
+		}
+		if expected := getPrimesIn(r[0], r[1]); fmt.Sprint(primes) !=
+			fmt.Sprint(expected) {
+			t.Error(r, len(primes), len(expected))
+		}
+	}
+
+	primesTo30 := Primes(0, math.MaxUint64)
+	// Run the iterator twice to check that it can be reused.
+	for i := 0; i < 2; i++ {
+		var primes []uint64
+		for p := range primesTo30 {
+			if p > 30 {
+				break
+			}
+			primes = append(primes, p)
+		}
+		if str := fmt.Sprint(primes); str !=
+			"[2 3 5 7 11 13 17 19 23 29]" {
+			t.Error(i, str)
+		}
+	}
+}
+
+// The table of small primes should have all 78498 primes below 10^6.
+func TestSmallPrimeTable(t *testing.T) {
+	table := getSmallPrimeTable()
+	if len(table) != 78498 || table[0] != 2 ||
+		table[len(table)-1] != 999983 {
+		t.Error(len(table), table[0], table[len(table)-1])
+	}
+}
+
+// sievePrimes should agree with getPrimesIn for every wheel size.
+func TestSievePrimesWheels(t *testing.T) {
+	for k := 0; k <= 5; k++ {
+		w := newSieveWheel(k)
+		for _, r := range [][2]uint64{
+			{12, 12}, {12, 13}, {13, 10000},
+			{1 << 32, 1<<32 + 3*maxPrimeSegmentSize + 7},
+		} {
+			var primes []uint64
+			sievePrimes(r[0], r[1], w, func(p uint64) bool {
+				primes = append(primes, p)
+				return true
+			})
+			expected := getPrimesIn(r[0], r[1])
+			if fmt.Sprint(primes) != fmt.Sprint(expected) {
+				t.Error(k, r, len(primes), len(expected))
+			}
+		}
+	}
+}
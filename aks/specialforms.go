@@ -0,0 +1,89 @@
+package aks
+
+import "fmt"
+import "math/big"
+
+// Returns whether n = 2^p - 1 for some p >= 2, which LucasLehmer can
+// test for primality with p - 2 squarings mod n.
+func IsMersenneNumber(n *big.Int) bool {
+	if n.Cmp(big.NewInt(3)) < 0 {
+		return false
+	}
+	var m big.Int
+	m.Add(n, big.NewInt(1))
+	return m.TrailingZeroBits() == uint(n.BitLen())
+}
+
+// Returns whether n = 2^(2^k) + 1 for some k >= 0, which Pepin can
+// test for primality with a single modular exponentiation.
+func IsFermatNumber(n *big.Int) bool {
+	if n.Cmp(big.NewInt(3)) < 0 {
+		return false
+	}
+	var m big.Int
+	m.Sub(n, big.NewInt(1))
+	e := m.TrailingZeroBits()
+	return m.BitLen() == int(e)+1 && e&(e-1) == 0
+}
+
+// Returns x mod 2^p - 1 for x >= 0, by repeatedly adding the high bits
+// to the low ones, which is much faster than dividing.
+func reduceMersenne(x *big.Int, p uint, n *big.Int) *big.Int {
+	var hi big.Int
+	for x.BitLen() > int(p) {
+		hi.Rsh(x, p)
+		x.And(x, n)
+		x.Add(x, &hi)
+	}
+	if x.Cmp(n) == 0 {
+		x.SetInt64(0)
+	}
+	return x
+}
+
+// Returns whether the Mersenne number n = 2^p - 1 is prime, exactly,
+// with the Lucas-Lehmer test: for odd prime p, n is prime if and only
+// if s_(p-2) = 0 (mod n), where s_0 = 4 and s_(i+1) = s_i^2 - 2.
+// Returns an error wrapping ErrBadInput if n isn't a Mersenne number.
+func LucasLehmer(n *big.Int) (bool, error) {
+	if !IsMersenneNumber(n) {
+		return false, fmt.Errorf(
+			"%w: n = %v isn't a Mersenne number", ErrBadInput, n)
+	}
+	p := uint(n.BitLen())
+	if p == 2 {
+		return true, nil
+	}
+	// 2^p - 1 is composite for composite p.
+	if !IsPrimeUint64(uint64(p)) {
+		return false, nil
+	}
+	s := big.NewInt(4)
+	two := big.NewInt(2)
+	for i := uint(0); i < p-2; i++ {
+		s.Mul(s, s)
+		s.Add(s, n)
+		s.Sub(s, two)
+		reduceMersenne(s, p, n)
+	}
+	return s.Sign() == 0, nil
+}
+
+// Returns whether the Fermat number n = 2^(2^k) + 1 is prime, exactly,
+// with Pepin's test: for k >= 1, n is prime if and only if 3^((n -
+// 1)/2) = -1 (mod n). Returns an error wrapping ErrBadInput if n isn't
+// a Fermat number.
+func Pepin(n *big.Int) (bool, error) {
+	if !IsFermatNumber(n) {
+		return false, fmt.Errorf(
+			"%w: n = %v isn't a Fermat number", ErrBadInput, n)
+	}
+	if n.Cmp(big.NewInt(3)) == 0 {
+		return true, nil
+	}
+	var e, x, nMinusOne big.Int
+	nMinusOne.Sub(n, big.NewInt(1))
+	e.Rsh(&nMinusOne, 1)
+	x.Exp(big.NewInt(3), &e, n)
+	return x.Cmp(&nMinusOne) == 0, nil
+}
@@ -0,0 +1,68 @@
+package aks
+
+import "errors"
+import "math/big"
+import "testing"
+
+// LucasLehmer should find exactly the Mersenne primes 2^p - 1 for p up
+// to 1300.
+func TestLucasLehmer(t *testing.T) {
+	mersennePrimeExponents := map[int]bool{
+		2: true, 3: true, 5: true, 7: true, 13: true, 17: true,
+		19: true, 31: true, 61: true, 89: true, 107: true,
+		127: true, 521: true, 607: true, 1279: true,
+	}
+	for p := 2; p <= 1300; p++ {
+		n := new(big.Int).Lsh(big.NewInt(1), uint(p))
+		n.Sub(n, big.NewInt(1))
+		if !IsMersenneNumber(n) {
+			t.Fatal(p)
+		}
+		isPrime, err := LucasLehmer(n)
+		if err != nil {
+			t.Fatal(p, err)
+		}
+		if isPrime != mersennePrimeExponents[p] {
+			t.Error(p, isPrime)
+		}
+	}
+
+	for _, n := range []int64{0, 1, 2, 5, 8, 9, 15 * 2} {
+		if IsMersenneNumber(big.NewInt(n)) {
+			t.Error(n)
+		}
+		if isPrime, err := LucasLehmer(big.NewInt(n)); !errors.Is(
+			err, ErrBadInput) {
+			t.Error(n, isPrime, err)
+		}
+	}
+}
+
+// Pepin should find exactly the Fermat primes F_0 to F_4 among F_0
+// to F_12.
+func TestPepin(t *testing.T) {
+	for k := uint(0); k <= 12; k++ {
+		n := new(big.Int).Lsh(big.NewInt(1), 1<<k)
+		n.Add(n, big.NewInt(1))
+		if !IsFermatNumber(n) {
+			t.Fatal(k)
+		}
+		isPrime, err := Pepin(n)
+		if err != nil {
+			t.Fatal(k, err)
+		}
+		if isPrime != (k <= 4) {
+			t.Error(k, isPrime)
+		}
+	}
+
+	for _, n := range []int64{0, 1, 2, 4, 9, 33, 65, 129} {
+		if IsFermatNumber(big.NewInt(n)) {
+			t.Error(n)
+		}
+		if isPrime, err := Pepin(big.NewInt(n)); !errors.Is(
+			err, ErrBadInput) {
+			t.Error(n, isPrime, err)
+		}
+	}
+}
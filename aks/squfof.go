@@ -0,0 +1,124 @@
+package aks
+
+import "fmt"
+import "math/big"
+
+// SQUFOF only works for n of at most this many bits, since it does
+// its arithmetic on k*n for small multipliers k in an int64.
+const squfofMaxBits = 62
+
+// The multipliers squfof tries, which are the squarefree products of
+// 3, 5, 7, and 11. Some k*n take far fewer steps than others, so
+// trying several makes the running time much more predictable.
+var squfofMultipliers = []int64{
+	1, 3, 5, 7, 11, 3 * 5, 3 * 7, 3 * 11, 5 * 7, 5 * 11, 7 * 11,
+	3 * 5 * 7, 3 * 5 * 11, 3 * 7 * 11, 5 * 7 * 11, 3 * 5 * 7 * 11,
+}
+
+// Returns a nontrivial factor of n using Shanks' square forms
+// factorization, which takes about n^(1/4) steps of word-sized
+// arithmetic regardless of the size of its factors. Returns nil if
+// none of the multipliers it tries works, which is rare, or an error
+// wrapping ErrBadInput if n < 2, n >= 2^62, or n is prime.
+func SQUFOF(n *big.Int) (*big.Int, error) {
+	if n.Cmp(big.NewInt(2)) < 0 || n.BitLen() > squfofMaxBits {
+		return nil, fmt.Errorf(
+			"%w: n = %v must be >= 2 and < 2^%d",
+			ErrBadInput, n, squfofMaxBits)
+	}
+	if IsPrimeUint64(n.Uint64()) {
+		return nil, fmt.Errorf("%w: n = %v is prime", ErrBadInput, n)
+	}
+	// squfof often fails on perfect powers, but they're easy to
+	// factor anyway.
+	if b, k := getPerfectPower(n); k > 1 {
+		return b, nil
+	}
+	d := squfof(n.Int64())
+	if d == 0 {
+		return nil, nil
+	}
+	return big.NewInt(d), nil
+}
+
+// Returns the greatest common divisor of the non-negative x and y.
+func gcdInt64(x, y int64) int64 {
+	for y != 0 {
+		x, y = y, x%y
+	}
+	return x
+}
+
+// Like SQUFOF, but takes a composite n < 2^62 that shouldn't be a
+// perfect power, and returns 0 if it fails.
+func squfof(n int64) int64 {
+	if n%2 == 0 {
+		return 2
+	}
+	if s := int64(floorSqrt(uint64(n))); s*s == n {
+		return s
+	}
+
+	const maxInt64 = 1<<63 - 1
+	for _, k := range squfofMultipliers {
+		if k > maxInt64/n {
+			break
+		}
+		if g := gcdInt64(n, k); g > 1 && g < n {
+			return g
+		}
+		kn := k * n
+		p0 := int64(floorSqrt(uint64(kn)))
+		// The square form is usually found within about
+		// 2 sqrt(2 sqrt(kn)) steps; give up on k after three
+		// times that.
+		bound := 6 * int64(floorSqrt(uint64(2*p0)))
+
+		// Go through the forward cycle of reduced forms of
+		// discriminant 4kn until one at an even position is a
+		// square r^2.
+		p, pPrev := p0, p0
+		q, qPrev := kn-p0*p0, int64(1)
+		var r int64
+		i := int64(2)
+		for ; i < bound; i++ {
+			b := (p0 + p) / q
+			p = b*q - p
+			oldQ := q
+			q = qPrev + b*(pPrev-p)
+			r = int64(floorSqrt(uint64(q)))
+			if i%2 == 0 && r*r == q {
+				break
+			}
+			qPrev = oldQ
+			pPrev = p
+		}
+		if i >= bound {
+			continue
+		}
+
+		// Start from the square root of that form and go
+		// until the P values repeat, which gives a form whose
+		// Q shares a factor with n (if we're lucky).
+		b := (p0 - p) / r
+		p = b*r + p
+		pPrev = p
+		qPrev = r
+		q = (kn - pPrev*pPrev) / qPrev
+		for i = 0; i < bound; i++ {
+			b := (p0 + p) / q
+			pPrev = p
+			p = b*q - p
+			oldQ := q
+			q = qPrev + b*(pPrev-p)
+			qPrev = oldQ
+			if p == pPrev {
+				break
+			}
+		}
+		if g := gcdInt64(n, qPrev); g > 1 && g < n {
+			return g
+		}
+	}
+	return 0
+}
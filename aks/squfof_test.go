@@ -0,0 +1,54 @@
+package aks
+
+import "errors"
+import "math/big"
+import "testing"
+
+// SQUFOF should find a nontrivial factor of composites less than
+// 2^62 and reject everything else.
+func TestSQUFOF(t *testing.T) {
+	for _, n := range []*big.Int{
+		big.NewInt(4),
+		big.NewInt(15),
+		big.NewInt(10403),
+		big.NewInt(11 * 191),
+		mustMultiply(t, "1000003", "1000033"),
+		mustMultiply(t, "1000003", "1000003"),
+		mustMultiply(t, "1000003", "1000003", "1000003"),
+		mustMultiply(t, "1073741789", "1073741783"),
+		mustMultiply(t, "3", "1537228672809129301"),
+		mustMultiply(t, "2147483647", "2147483629"),
+	} {
+		d, err := SQUFOF(n)
+		if err != nil {
+			t.Fatal(n, err)
+		}
+		var m big.Int
+		if d == nil || d.Cmp(big.NewInt(1)) <= 0 || d.Cmp(n) >= 0 ||
+			m.Mod(n, d).Sign() != 0 {
+			t.Error(n, d)
+		}
+	}
+
+	tooBig := new(big.Int).Lsh(big.NewInt(1), squfofMaxBits)
+	for _, n := range []*big.Int{
+		big.NewInt(-1), big.NewInt(0), big.NewInt(1), big.NewInt(2),
+		big.NewInt(1000003), tooBig,
+	} {
+		if d, err := SQUFOF(n); !errors.Is(err, ErrBadInput) {
+			t.Error(n, d, err)
+		}
+	}
+}
+
+// squfof should factor every odd composite in a range.
+func TestSQUFOFSmall(t *testing.T) {
+	for n := int64(9); n < 20000; n += 2 {
+		if big.NewInt(n).ProbablyPrime(0) {
+			continue
+		}
+		if d := squfof(n); d <= 1 || d >= n || n%d != 0 {
+			t.Error(n, d)
+		}
+	}
+}
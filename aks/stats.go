@@ -0,0 +1,182 @@
+package aks
+
+import "fmt"
+import "math/big"
+import "math/bits"
+import "sync"
+import "time"
+
+// Statistics about an AKS witness search, useful for tuning the
+// number of jobs.
+type Stats struct {
+	// The number of AKS witness candidates tested.
+	WitnessesTested int64
+	// The number of polynomial multiplications performed.
+	Multiplications int64
+	// The total time spent testing candidates, summed over all
+	// jobs. This is dominated by polynomial multiplications.
+	MulTime time.Duration
+	// The wall-clock time taken by the search.
+	Elapsed time.Duration
+	// WitnessesTested divided by Elapsed.
+	WitnessesPerSecond float64
+	// The largest number of bytes allocated for polynomial
+	// coefficients at any one time.
+	PeakPhiBytes int64
+	// The number of bytes currently allocated for polynomial
+	// coefficients.
+	PhiBytes int64
+}
+
+// Returns the number of multiplications bigIntPoly.Pow does with
+// exponent N.
+func calculatePowMultiplicationCount(N *big.Int) int64 {
+	count := int64(0)
+	for i := N.BitLen() - 2; i >= 0; i-- {
+		count++
+		if N.Bit(i) != 0 {
+			count++
+		}
+	}
+	return count
+}
+
+// Returns the number of multiplications testing a single AKS witness
+// of n takes when raising to the nth power with chain, or with binary
+// exponentiation if chain is nil.
+func calculateWitnessMultiplicationCount(
+	n *big.Int, chain *AdditionChain) int64 {
+	if chain != nil {
+		return int64(chain.MultiplicationCount())
+	}
+	return calculatePowMultiplicationCount(n)
+}
+
+// Holds the AKS parameters for N along with estimates of the
+// resources needed to search for its AKS witnesses, for sizing a job
+// before running it.
+type JobEstimate struct {
+	N, R, M *big.Int
+	// The polynomial backend picked by AutoPolyBackend.
+	Backend PolyBackend
+	// The number of machine words BigIntPolyBackend uses to hold
+	// each polynomial coefficient.
+	K int
+	// The number of bytes each job allocates for polynomial
+	// coefficients.
+	PhiBytesPerJob int64
+	// The number of polynomial multiplications needed to test a
+	// single candidate, and to test all candidates in [1, M).
+	MultiplicationsPerWitness int64
+	TotalMultiplications      *big.Int
+}
+
+// Returns a JobEstimate for the AKS witness search for n >= 2 with
+// the given options (which may be nil), without allocating any
+// polynomials. Only opts.AdditionChain and opts.PowWindowBits are
+// used, for the number of multiplications.
+func EstimateJob(n *big.Int, opts *AKSOptions) (*JobEstimate, error) {
+	o := opts.withDefaults()
+	chain, err := o.getAdditionChain(n)
+	if err != nil {
+		return nil, err
+	}
+	r, err := CalculateAKSModulus(n)
+	if err != nil {
+		return nil, err
+	}
+	M, err := CalculateAKSUpperBound(n, r)
+	if err != nil {
+		return nil, err
+	}
+	if !fitsInInt(r) {
+		return nil, fmt.Errorf("%w: r = %v", ErrRTooLarge, r)
+	}
+	k := calculateCoefficientWordCount(*n, *r)
+	backend := pickPolyBackend(n, r)
+	ring, err := newPolyRing(n, r, backend)
+	if err != nil {
+		return nil, err
+	}
+	mulsPerWitness := calculateWitnessMultiplicationCount(n, chain)
+	var totalMuls big.Int
+	totalMuls.Sub(M, big.NewInt(1))
+	totalMuls.Mul(&totalMuls, big.NewInt(mulsPerWitness))
+	// Each job has three polynomials.
+	phiBytesPerJob := 3 * ring.getPolyBytes()
+	return &JobEstimate{
+		N:                         n,
+		R:                         r,
+		M:                         M,
+		Backend:                   backend,
+		K:                         k,
+		PhiBytesPerJob:            phiBytesPerJob,
+		MultiplicationsPerWitness: mulsPerWitness,
+		TotalMultiplications:      &totalMuls,
+	}, nil
+}
+
+// poly implementation.
+func (p *bigIntPoly) getPhiBytes() int64 {
+	return calculateBigWordBytes(int64(cap(p.phi.Bits())))
+}
+
+// Returns the number of bytes taken up by the given number of
+// big.Words.
+func calculateBigWordBytes(wordCount int64) int64 {
+	// A big.Word is a uint.
+	return wordCount * bits.UintSize / 8
+}
+
+// Accumulates Stats from multiple goroutines.
+type statsCollector struct {
+	lock            sync.Mutex
+	stats           Stats
+	mulsPerTest     int64
+	currentPhiBytes int64
+	startTime       time.Time
+}
+
+// Makes a statsCollector for a witness search for AKS witnesses of n
+// that raises to the nth power with chain, or with binary
+// exponentiation if chain is nil.
+func newStatsCollector(n *big.Int, chain *AdditionChain) *statsCollector {
+	return &statsCollector{
+		mulsPerTest: calculateWitnessMultiplicationCount(n, chain),
+		startTime:   time.Now(),
+	}
+}
+
+// Records that a witness test took the given amount of time.
+func (c *statsCollector) witnessTested(d time.Duration) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.stats.WitnessesTested++
+	c.stats.Multiplications += c.mulsPerTest
+	c.stats.MulTime += d
+}
+
+// Records that the given number of bytes were allocated (if bytes is
+// positive) or freed (if bytes is negative) for polynomials.
+func (c *statsCollector) phiAllocated(bytes int64) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.currentPhiBytes += bytes
+	if c.currentPhiBytes > c.stats.PeakPhiBytes {
+		c.stats.PeakPhiBytes = c.currentPhiBytes
+	}
+}
+
+// Returns the stats collected so far.
+func (c *statsCollector) getStats() *Stats {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	stats := c.stats
+	stats.PhiBytes = c.currentPhiBytes
+	stats.Elapsed = time.Since(c.startTime)
+	if stats.Elapsed > 0 {
+		stats.WitnessesPerSecond =
+			float64(stats.WitnessesTested) / stats.Elapsed.Seconds()
+	}
+	return &stats
+}
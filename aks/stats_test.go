@@ -0,0 +1,110 @@
+package aks
+
+import "context"
+import "math/big"
+import "testing"
+
+// The multiplication count should match the square-and-multiply
+// loop in bigIntPoly.Pow.
+func TestCalculatePowMultiplicationCount(t *testing.T) {
+	// 13 = 1101b: three squarings and two multiplications.
+	count := calculatePowMultiplicationCount(big.NewInt(13))
+	if count != 5 {
+		t.Error(count)
+	}
+	count = calculatePowMultiplicationCount(big.NewInt(1))
+	if count != 0 {
+		t.Error(count)
+	}
+}
+
+// RunAKS should report stats for the witness search.
+func TestRunAKSStats(t *testing.T) {
+	n := big.NewInt(1000003)
+	result, err := RunAKS(context.Background(), n, &AKSOptions{Jobs: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	stats := result.Stats
+	if stats == nil {
+		t.Fatal(stats)
+	}
+	tested := result.M.Int64() - 1
+	if stats.WitnessesTested != tested {
+		t.Error(stats.WitnessesTested, tested)
+	}
+	muls := tested * calculatePowMultiplicationCount(n)
+	if stats.Multiplications != muls {
+		t.Error(stats.Multiplications, muls)
+	}
+	if stats.MulTime <= 0 || stats.Elapsed <= 0 ||
+		stats.WitnessesPerSecond <= 0 {
+		t.Error(stats)
+	}
+
+	ring, err := newPolyRing(n, result.R, AutoPolyBackend)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := ring.newPoly()
+	if stats.PeakPhiBytes < 3*p.getPhiBytes() ||
+		stats.PeakPhiBytes > 2*3*p.getPhiBytes() {
+		t.Error(stats.PeakPhiBytes, p.getPhiBytes())
+	}
+}
+
+// EstimateJob should match what a witness search actually allocates
+// and does.
+func TestEstimateJob(t *testing.T) {
+	n := big.NewInt(1000003)
+	e, err := EstimateJob(n, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := RunAKS(context.Background(), n, &AKSOptions{Jobs: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.R.Cmp(result.R) != 0 || e.M.Cmp(result.M) != 0 {
+		t.Error(e.R, e.M, result.R, result.M)
+	}
+	// n is small enough for wordPoly to be fastest.
+	if e.Backend != WordPolyBackend {
+		t.Error(e.Backend)
+	}
+	p := newBigIntPoly(*n, *e.R)
+	if e.K != p.k {
+		t.Error(e.K, p.k)
+	}
+	if e.PhiBytesPerJob != result.Stats.PeakPhiBytes {
+		t.Error(e.PhiBytesPerJob, result.Stats.PeakPhiBytes)
+	}
+	if e.TotalMultiplications.Int64() != result.Stats.Multiplications {
+		t.Error(e.TotalMultiplications, result.Stats.Multiplications)
+	}
+
+	// With sliding windows, the estimate and the stats should
+	// both count the multiplications the addition chain does.
+	opts := &AKSOptions{Jobs: 1, PowWindowBits: 3}
+	windowE, err := EstimateJob(n, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if windowE.MultiplicationsPerWitness >= e.MultiplicationsPerWitness {
+		t.Error(windowE.MultiplicationsPerWitness,
+			e.MultiplicationsPerWitness)
+	}
+	result, err = RunAKS(context.Background(), n, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if windowE.TotalMultiplications.Int64() !=
+		result.Stats.Multiplications {
+		t.Error(windowE.TotalMultiplications,
+			result.Stats.Multiplications)
+	}
+
+	if _, err := EstimateJob(big.NewInt(1), nil); err == nil {
+		t.Error("expected error")
+	}
+}
@@ -0,0 +1,98 @@
+package aks
+
+import "math/big"
+import "net/http"
+import "sync"
+import "time"
+
+// Holds a snapshot of the progress of a primality test, as served by
+// StatusTracker.
+type Status struct {
+	N, R, M *big.Int
+	Phase   Phase
+	// The number of witnesses tested in this run, and the
+	// estimate made after the last one (if any).
+	Tested   int64
+	Estimate *Estimate
+	// The estimated completion time, or nil if it is not yet
+	// known.
+	ETA *time.Time
+	// The AKS witness found, if any.
+	Witness *big.Int
+	// How long the test has been running.
+	Elapsed time.Duration
+}
+
+// A StatusTracker is a ProgressObserver that keeps track of the
+// Status of a primality test of a single number. It is also an
+// http.Handler serving that status as JSON, so that long runs can
+// be checked on, e.g. by registering it as /status.
+type StatusTracker struct {
+	lock      sync.Mutex
+	status    Status
+	startTime time.Time
+}
+
+// Makes a StatusTracker for a primality test of n >= 2.
+func NewStatusTracker(n *big.Int) (*StatusTracker, error) {
+	r, err := CalculateAKSModulus(n)
+	if err != nil {
+		return nil, err
+	}
+	M, err := CalculateAKSUpperBound(n, r)
+	if err != nil {
+		return nil, err
+	}
+	return &StatusTracker{
+		status:    Status{N: n, R: r, M: M},
+		startTime: time.Now(),
+	}, nil
+}
+
+// Returns a snapshot of the current status.
+func (t *StatusTracker) GetStatus() Status {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	status := t.status
+	status.Elapsed = time.Since(t.startTime)
+	return status
+}
+
+// ProgressObserver implementation.
+func (t *StatusTracker) OnWitnessTested(
+	a *big.Int, isWitness bool, d time.Duration) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.status.Tested++
+	if isWitness {
+		t.status.Witness = a
+	}
+}
+
+// ProgressObserver implementation.
+func (t *StatusTracker) OnPhaseChange(phase Phase) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.status.Phase = phase
+}
+
+// ProgressObserver implementation.
+func (t *StatusTracker) OnEstimate(e Estimate) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.status.Estimate = &e
+	t.status.ETA = nil
+	if e.Remaining >= 0 {
+		eta := time.Now().Add(e.Remaining)
+		t.status.ETA = &eta
+	}
+}
+
+// http.Handler implementation.
+func (t *StatusTracker) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	status := t.GetStatus()
+	if err := writeIndentedJSON(w, &status); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,63 @@
+package aks
+
+import "context"
+import "encoding/json"
+import "math/big"
+import "net/http/httptest"
+import "testing"
+
+// A StatusTracker should serve the final status of a run as JSON.
+func TestStatusTracker(t *testing.T) {
+	n := big.NewInt(1000003)
+	tracker, err := NewStatusTracker(n)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := RunAKS(context.Background(), n, &AKSOptions{
+		Observer: tracker,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := httptest.NewRecorder()
+	tracker.ServeHTTP(w, httptest.NewRequest("GET", "/status", nil))
+	if w.Code != 200 {
+		t.Fatal(w.Code)
+	}
+	var status Status
+	if err := json.Unmarshal(w.Body.Bytes(), &status); err != nil {
+		t.Fatal(err)
+	}
+	var count big.Int
+	count.Sub(result.M, big.NewInt(1))
+	if status.N.Cmp(n) != 0 || status.R.Cmp(result.R) != 0 ||
+		status.M.Cmp(result.M) != 0 || status.Phase != PhaseDone ||
+		status.Tested != count.Int64() || status.Witness != nil {
+		t.Error(status)
+	}
+	if status.Estimate == nil || status.Estimate.Done.Cmp(&count) != 0 ||
+		status.ETA == nil {
+		t.Error(status.Estimate, status.ETA)
+	}
+}
+
+// A StatusTracker should record the witness found for a composite.
+func TestStatusTrackerWitness(t *testing.T) {
+	n := big.NewInt(2993374621)
+	tracker, err := NewStatusTracker(n)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := RunAKS(context.Background(), n, &AKSOptions{
+		Observer: tracker,
+		SkipBPSW: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	status := tracker.GetStatus()
+	if status.Witness == nil || status.Witness.Cmp(result.Witness) != 0 {
+		t.Error(status.Witness, result.Witness)
+	}
+}
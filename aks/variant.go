@@ -0,0 +1,78 @@
+package aks
+
+import "fmt"
+import "math/big"
+
+// An AKSVariant is the version of the AKS theorem that RunAKS uses to
+// pick the AKS modulus r and upper bound M. Every variant except
+// AKSVariantConjecture proves n prime if n has no factors less than M
+// and no number in [1, M) is an AKS witness of n with parameter r;
+// they differ in the r and M they need to do so.
+type AKSVariant int
+
+const (
+	// The theorem from the AKS paper, with r and M from
+	// CalculateAKSModulus and CalculateAKSUpperBound (or their
+	// sharp versions, if AKSOptions.SharpBounds is set). This is
+	// the zero value.
+	AKSVariantClassic AKSVariant = iota
+	// Bernstein's version, with r and M from
+	// CalculateBernsteinAKSParams.
+	AKSVariantBernstein
+	// Not rigorous: r is from CalculateConjectureAKSModulus, M is
+	// r + 1 (so that trial division rules out r dividing n), and
+	// the only number tested is n - 1. A Prime verdict reached
+	// this way, with MethodAKSConjecture, assumes the unproven
+	// AKS conjecture, but takes a single witness test with a
+	// small r.
+	AKSVariantConjecture
+)
+
+// fmt.Stringer implementation.
+func (v AKSVariant) String() string {
+	switch v {
+	case AKSVariantClassic:
+		return "classic"
+	case AKSVariantBernstein:
+		return "bernstein"
+	case AKSVariantConjecture:
+		return "conjecture"
+	}
+	return fmt.Sprintf("AKSVariant(%d)", int(v))
+}
+
+// Returns the AKS modulus and upper bound for n as configured by o.
+// Returns an error wrapping ErrBadInput if o.Variant is unknown.
+func calculateAKSParams(n *big.Int, o *AKSOptions) (r, M *big.Int, err error) {
+	switch o.Variant {
+	case AKSVariantClassic:
+		calculateModulus := CalculateAKSModulus
+		calculateUpperBound := CalculateAKSUpperBound
+		if o.SharpBounds {
+			calculateModulus = CalculateSharpAKSModulus
+			calculateUpperBound = CalculateSharpAKSUpperBound
+		}
+		r, err = calculateModulus(n)
+		if err != nil {
+			return nil, nil, err
+		}
+		M, err = calculateUpperBound(n, r)
+		if err != nil {
+			return nil, nil, err
+		}
+		return r, M, nil
+
+	case AKSVariantBernstein:
+		return CalculateBernsteinAKSParams(n)
+
+	case AKSVariantConjecture:
+		r, err = CalculateConjectureAKSModulus(n)
+		if err != nil {
+			return nil, nil, err
+		}
+		M = new(big.Int).Add(r, big.NewInt(1))
+		return r, M, nil
+	}
+	return nil, nil, fmt.Errorf(
+		"%w: unknown AKS variant %v", ErrBadInput, o.Variant)
+}
@@ -0,0 +1,472 @@
+package aks
+
+import "encoding/json"
+import "fmt"
+import "math/big"
+import "math/bits"
+
+// A word is a coefficient of a wordPoly.
+type word uint64
+
+// The number of bits in a word.
+const wordBits = 64
+
+// A wordPoly represents a polynomial with word coefficients mod (N,
+// X^R - 1), for N that fits into a word.
+//
+// Like bigIntPoly, a wordPoly is multiplied by packing it into a
+// big.Int (Kronecker substitution) so that big.Int's fast
+// multiplication can be used. Unlike bigIntPoly, each coefficient
+// is packed into only as many bits as the product needs (instead of
+// a whole number of big.Words), and reducing the product mod N is
+// done with word arithmetic. This makes wordPoly faster than
+// bigIntPoly when the former packs coefficients tighter; see
+// pickPolyBackend.
+//
+// If N is odd (which it is for any N that gets to the AKS witness
+// search), coefficients are stored in Montgomery form, i.e. the
+// coefficient c is stored as c*2^128 mod N, so that the product can
+// be reduced mod N with multiplications instead of divisions.
+type wordPoly struct {
+	n word
+	// -1/n mod 2^64 if n is odd, or 0 if n is even and Montgomery
+	// form isn't used.
+	nInv word
+	// The number of bits a coefficient needs in the product of
+	// two polynomials.
+	b uint
+	// The R coefficients of the polynomial, in order of
+	// increasing degree, each less than n and in Montgomery form
+	// if nInv is non-zero.
+	coefficients []word
+	// Scratch space for packed polynomials, with capacity for a
+	// product of two polynomials.
+	packed []big.Word
+	phi    big.Int
+}
+
+// Returns whether N fits into a word, i.e. whether polynomials mod
+// (N, X^R - 1) can be wordPolys.
+func fitsInWord(N *big.Int) bool {
+	return N.Sign() >= 0 && N.BitLen() <= wordBits
+}
+
+// Builds a new wordPoly representing the zero polynomial mod (N, X^R
+// - 1). N must fit into a word and R must fit into an int.
+func newWordPoly(N, R big.Int) *wordPoly {
+	rInt := int(R.Int64())
+	b := calculateWordPolyCoefficientBits(N, R)
+	n := N.Uint64()
+	return &wordPoly{
+		n:            word(n),
+		nInv:         word(calculateMontgomeryInverse(n)),
+		b:            b,
+		coefficients: make([]word, rInt),
+		packed:       make([]big.Word, 2*getPackedWordCount(rInt, b)),
+	}
+}
+
+// Returns the number of big.Words needed to hold count coefficients
+// of b bits each.
+func getPackedWordCount(count int, b uint) int {
+	return int(uint(count)*b/bits.UintSize) + 1
+}
+
+// Returns the number of bits a coefficient of a wordPoly mod (N, X^R
+// - 1) needs in the product of two polynomials, i.e. the bit length
+// of R*(N - 1)^2, which is less than 2^192 since N fits into a word
+// and R fits into an int.
+func calculateWordPolyCoefficientBits(N, R big.Int) uint {
+	var maxCoefficient big.Int
+	maxCoefficient.Sub(&N, big.NewInt(1))
+	maxCoefficient.Mul(&maxCoefficient, &maxCoefficient)
+	maxCoefficient.Mul(&maxCoefficient, &R)
+	if maxCoefficient.Sign() == 0 {
+		return 1
+	}
+	return uint(maxCoefficient.BitLen())
+}
+
+// Returns -1/n mod 2^64 if n is odd, or 0 otherwise.
+func calculateMontgomeryInverse(n uint64) uint64 {
+	if n%2 == 0 {
+		return 0
+	}
+	// Newton's method doubles the number of correct low bits of
+	// 1/n each iteration, starting with 3 since n*n = 1 mod 8.
+	inv := n
+	for i := 0; i < 5; i++ {
+		inv *= 2 - n*inv
+	}
+	return -inv
+}
+
+// Returns c in the form it is stored in p.coefficients.
+func (p *wordPoly) toStored(c uint64) word {
+	if p.nInv == 0 {
+		return word(c)
+	}
+	var x big.Int
+	x.SetUint64(c)
+	x.Lsh(&x, 128)
+	x.Mod(&x, new(big.Int).SetUint64(uint64(p.n)))
+	return word(x.Uint64())
+}
+
+// Returns x*2^-128 mod p.n if p.nInv is non-zero, or x mod p.n
+// otherwise, where x is a 192-bit number with the given limbs, least
+// significant first, at most R*(p.n - 1)^2 for some R < 2^63, which
+// is the most that mul and mulSparse accumulate (see mul). When
+// multiplying two polynomials in Montgomery form, each coefficient c
+// of the product comes out as c*2^128*2^128 mod p.n, so this returns
+// c in Montgomery form.
+func (p *wordPoly) reduce(x [3]uint64) uint64 {
+	n := uint64(p.n)
+	if p.nInv == 0 {
+		return remLimbs(x, n)
+	}
+
+	// Do two rounds of Montgomery reduction, each of which
+	// divides x by 2^64 mod n by adding a multiple m*n of n, with
+	// m < 2^64, that zeroes out its lowest limb. Since n < 2^64,
+	// the first round leaves x less than (R*n^2 + 2^64*n)/2^64 <
+	// (R + 1)*n < 2^127, so it fits into two limbs, and the
+	// second leaves it less than ((R + 1)*n + 2^64*n)/2^64 < 2*n.
+	// Neither sum overflows 192 bits, since R*n^2 + 2^64*n <
+	// 2^63*2^128 + 2^128.
+	nInv := uint64(p.nInv)
+	hi, lo := bits.Mul64(x[0]*nInv, n)
+	_, carry := bits.Add64(x[0], lo, 0)
+	x[0], carry = bits.Add64(x[1], hi, carry)
+	x[1] = x[2] + carry
+
+	hi, lo = bits.Mul64(x[0]*nInv, n)
+	_, carry = bits.Add64(x[0], lo, 0)
+	x[0], carry = bits.Add64(x[1], hi, carry)
+	x[1] = carry
+
+	// x[0] + x[1]*2^64 < 2n, so subtract n if necessary.
+	r, borrow := bits.Sub64(x[0], n, 0)
+	if x[1] == 0 && borrow != 0 {
+		return x[0]
+	}
+	return r
+}
+
+// poly implementation.
+func (p *wordPoly) Set(a, k, N big.Int) {
+	for i := range p.coefficients {
+		p.coefficients[i] = 0
+	}
+	var aModN, kModR big.Int
+	aModN.Mod(&a, &N)
+	kModR.Mod(&k, big.NewInt(int64(len(p.coefficients))))
+	c0 := aModN.Uint64()
+	i := int(kModR.Int64())
+	ci := uint64(1) % uint64(p.n)
+	if i == 0 {
+		ci = (c0 + ci) % uint64(p.n)
+	}
+	p.coefficients[0] = p.toStored(c0)
+	p.coefficients[i] = p.toStored(ci)
+}
+
+// poly implementation.
+func (p *wordPoly) copyFrom(q poly) {
+	qPoly := q.(*wordPoly)
+	p.checkModulus("copy", qPoly)
+	copy(p.coefficients, qPoly.coefficients)
+}
+
+// poly implementation.
+func (p *wordPoly) clone() poly {
+	c := *p
+	c.coefficients = make([]word, len(p.coefficients))
+	copy(c.coefficients, p.coefficients)
+	c.packed = make([]big.Word, len(p.packed))
+	c.phi = big.Int{}
+	return &c
+}
+
+// poly implementation.
+func (p *wordPoly) Eq(q poly) bool {
+	qPoly := q.(*wordPoly)
+	p.checkModulus("compare", qPoly)
+	qCoefficients := qPoly.coefficients
+	for i, c := range p.coefficients {
+		if c != qCoefficients[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// ORs x into words starting at the given bit offset.
+func orBits(words []big.Word, offset uint, x uint64) {
+	for x != 0 {
+		i := offset / bits.UintSize
+		s := offset % bits.UintSize
+		words[i] |= big.Word(x << s)
+		x >>= bits.UintSize - s
+		offset += bits.UintSize - s
+	}
+}
+
+// Returns bits [64*i, 64*i + 64) of words, treating words past the
+// end of words as zero.
+func getUint64(words []big.Word, i uint) uint64 {
+	if bits.UintSize == 64 {
+		if i >= uint(len(words)) {
+			return 0
+		}
+		return uint64(words[i])
+	}
+	return getUint32(words, 2*i) | getUint32(words, 2*i+1)<<32
+}
+
+// Like getUint64, but for bits [32*i, 32*i + 32) on platforms where a
+// big.Word has 32 bits.
+func getUint32(words []big.Word, i uint) uint64 {
+	if i >= uint(len(words)) {
+		return 0
+	}
+	return uint64(words[i]) & (1<<32 - 1)
+}
+
+// Returns the 0 < width <= 192 bits of words starting at the given
+// bit offset as a 192-bit number with 64-bit limbs, least
+// significant first. Words past the end of words are treated as
+// zero.
+func getBits(words []big.Word, offset, width uint) (limbs [3]uint64) {
+	i := offset / 64
+	s := offset % 64
+	limbCount := (width + 63) / 64
+	current := getUint64(words, i)
+	for j := uint(0); j < limbCount; j++ {
+		next := getUint64(words, i+j+1)
+		// If s is 0, next << 64 is 0.
+		limbs[j] = current>>s | next<<(64-s)
+		current = next
+	}
+	// Clear the bits of the top limb past width.
+	limbs[limbCount-1] &= ^uint64(0) >> (64*limbCount - width)
+	return limbs
+}
+
+// Returns x + y, where the sum must fit into 192 bits.
+func addLimbs(x, y [3]uint64) [3]uint64 {
+	var carry uint64
+	x[0], carry = bits.Add64(x[0], y[0], 0)
+	x[1], carry = bits.Add64(x[1], y[1], carry)
+	x[2] += y[2] + carry
+	return x
+}
+
+// Returns the 192-bit number with the given limbs mod n, which must
+// be positive.
+func remLimbs(limbs [3]uint64, n uint64) uint64 {
+	r := limbs[2] % n
+	r = bits.Rem64(r, limbs[1], n)
+	return bits.Rem64(r, limbs[0], n)
+}
+
+// Packs p's coefficients into p.phi, i.e. sets p.phi to p(2^p.b).
+func (p *wordPoly) pack() {
+	packed := p.packed[:getPackedWordCount(len(p.coefficients), p.b)]
+	for i := range packed {
+		packed[i] = 0
+	}
+	for i, c := range p.coefficients {
+		orBits(packed, uint(i)*p.b, uint64(c))
+	}
+	p.phi.SetBits(packed)
+}
+
+// poly implementation.
+func (p *wordPoly) Mul(q poly, N big.Int, tmp poly) {
+	qPoly := q.(*wordPoly)
+	p.checkModulus("multiply", qPoly)
+	p.mul(qPoly, tmp.(*wordPoly))
+}
+
+// Calls panicModulusMismatch if p and q aren't mod the same N and R.
+func (p *wordPoly) checkModulus(op string, q *wordPoly) {
+	if q.n != p.n || len(q.coefficients) != len(p.coefficients) {
+		var pN, qN big.Int
+		pN.SetUint64(uint64(p.n))
+		qN.SetUint64(uint64(q.n))
+		panicModulusMismatch(
+			op, &pN, len(p.coefficients), &qN, len(q.coefficients))
+	}
+}
+
+// Like Mul, but without the type assertions.
+func (p *wordPoly) mul(q *wordPoly, tmp *wordPoly) {
+	p.pack()
+	if q != p {
+		q.pack()
+	}
+	// Multiply into tmp's scratch space. (big.Int.Mul, and so
+	// parallelMul, squares faster if its arguments are the same.)
+	tmp.phi.SetBits(tmp.packed[:0])
+	parallelMul(&tmp.phi, &p.phi, &q.phi)
+	productWords := tmp.phi.Bits()
+
+	// Unpack the coefficients of the product, reduce it mod X^R
+	// - 1, and then reduce each coefficient mod n. Coefficients k
+	// and k + R of the product together are the sum of exactly R
+	// products of coefficients less than n, so their sum is at
+	// most R*(n - 1)^2 < 2^191. Thus each one fits into p.b bits
+	// without spilling into its neighbor, and their sum fits into
+	// three limbs, as reduce needs.
+	R := uint(len(p.coefficients))
+	for k := uint(0); k < R; k++ {
+		c := addLimbs(
+			getBits(productWords, k*p.b, p.b),
+			getBits(productWords, (k+R)*p.b, p.b))
+		tmp.coefficients[k] = word(p.reduce(c))
+	}
+	p.coefficients, tmp.coefficients = tmp.coefficients, p.coefficients
+}
+
+// Like mul, but only multiplies by the terms of q with the given
+// degrees, which must include all of q's non-zero coefficients (see
+// getSparseDegrees).
+func (p *wordPoly) mulSparse(q *wordPoly, degrees []int, tmp *wordPoly) {
+	// Each sum below is of at most maxSparseMulTerms products of
+	// coefficients less than n, well within reduce's bound.
+	R := len(p.coefficients)
+	for k := 0; k < R; k++ {
+		var c [3]uint64
+		for _, d := range degrees {
+			i := k - d
+			if i < 0 {
+				i += R
+			}
+			hi, lo := bits.Mul64(
+				uint64(p.coefficients[i]), uint64(q.coefficients[d]))
+			c = addLimbs(c, [3]uint64{lo, hi, 0})
+		}
+		tmp.coefficients[k] = word(p.reduce(c))
+	}
+	p.coefficients, tmp.coefficients = tmp.coefficients, p.coefficients
+}
+
+// poly implementation.
+func (p *wordPoly) Pow(N big.Int, tmp1, tmp2 poly) {
+	p.powCancelable(N, tmp1, tmp2, nil, nil)
+}
+
+// poly implementation.
+func (p *wordPoly) powCancelable(
+	N big.Int, tmp1Poly, tmp2Poly poly,
+	done <-chan struct{}, onMul func()) bool {
+	tmp1 := tmp1Poly.(*wordPoly)
+	tmp2 := tmp2Poly.(*wordPoly)
+	copy(tmp1.coefficients, p.coefficients)
+	degrees := getSparseDegrees(p, len(p.coefficients))
+
+	for i := N.BitLen() - 2; i >= 0; i-- {
+		select {
+		case <-done:
+			return false
+		default:
+		}
+		tmp1.mul(tmp1, tmp2)
+		if onMul != nil {
+			onMul()
+		}
+		if N.Bit(i) != 0 {
+			if degrees != nil {
+				tmp1.mulSparse(p, degrees, tmp2)
+			} else {
+				tmp1.mul(p, tmp2)
+			}
+			if onMul != nil {
+				onMul()
+			}
+		}
+	}
+
+	p.coefficients, tmp1.coefficients = tmp1.coefficients, p.coefficients
+	return true
+}
+
+// poly implementation.
+func (p *wordPoly) coefficient(i int) *big.Int {
+	c := uint64(p.coefficients[i])
+	if p.nInv != 0 {
+		// Reducing c takes it out of Montgomery form.
+		c = p.reduce([3]uint64{c, 0, 0})
+	}
+	return new(big.Int).SetUint64(c)
+}
+
+// poly implementation.
+func (p *wordPoly) setCoefficient(i int, c *big.Int) {
+	p.coefficients[i] = p.toStored(c.Uint64())
+}
+
+// poly implementation.
+func (p *wordPoly) getPhiBytes() int64 {
+	return calculateWordPolyBytes(cap(p.coefficients), cap(p.packed))
+}
+
+// Returns the number of bytes allocated for a wordPoly with the
+// given number of coefficients and packed words.
+func calculateWordPolyBytes(coefficientCount, packedWordCount int) int64 {
+	return int64(coefficientCount)*wordBits/8 +
+		int64(packedWordCount)*bits.UintSize/8
+}
+
+// fmt.Formatter implementation.
+func (p *wordPoly) Format(f fmt.State, c rune) {
+	coefficientCount := len(p.coefficients)
+	for coefficientCount > 0 && p.coefficients[coefficientCount-1] == 0 {
+		coefficientCount--
+	}
+	formatPoly(f, coefficientCount, func(i int) big.Int {
+		return *p.coefficient(i)
+	})
+}
+
+// Returns the N and R that p is mod, for marshalPoly.
+func (p *wordPoly) modulus() (N, R big.Int) {
+	N.SetUint64(uint64(p.n))
+	R.SetInt64(int64(len(p.coefficients)))
+	return N, R
+}
+
+// gob.GobEncoder implementation.
+func (p *wordPoly) GobEncode() ([]byte, error) {
+	N, R := p.modulus()
+	return marshalPoly(p, N, R, gobMarshal)
+}
+
+// gob.GobDecoder implementation.
+func (p *wordPoly) GobDecode(data []byte) error {
+	return p.unmarshal(data, gobUnmarshal)
+}
+
+// json.Marshaler implementation.
+func (p *wordPoly) MarshalJSON() ([]byte, error) {
+	N, R := p.modulus()
+	return marshalPoly(p, N, R, json.Marshal)
+}
+
+// json.Unmarshaler implementation.
+func (p *wordPoly) UnmarshalJSON(data []byte) error {
+	return p.unmarshal(data, json.Unmarshal)
+}
+
+// Sets p to data, as marshaled by GobEncode or MarshalJSON (possibly
+// by another backend), with unmarshal.
+func (p *wordPoly) unmarshal(
+	data []byte, unmarshal func([]byte, interface{}) error) error {
+	q, err := unmarshalPoly(data, unmarshal, WordPolyBackend)
+	if err != nil {
+		return err
+	}
+	*p = *q.(*wordPoly)
+	return nil
+}
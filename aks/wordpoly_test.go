@@ -0,0 +1,203 @@
+package aks
+
+import "math/big"
+import "math/rand"
+import "testing"
+
+// getBits should read back what orBits writes, at any offset and
+// width.
+func TestOrBitsGetBits(t *testing.T) {
+	widths := []uint{1, 31, 32, 33, 63, 64, 65, 100, 128, 129, 191, 192}
+	for _, width := range widths {
+		for offset := uint(0); offset < 130; offset += 13 {
+			words := make([]big.Word, 12)
+			var expected [3]uint64
+			for j := uint(0); j < 3 && 64*j < width; j++ {
+				x := uint64(0xfedcba9876543210) >> j
+				if width < 64*(j+1) {
+					x &= 1<<(width-64*j) - 1
+				}
+				orBits(words, offset+64*j, x)
+				expected[j] = x
+			}
+			limbs := getBits(words, offset, width)
+			if limbs != expected {
+				t.Error(width, offset, limbs, expected)
+			}
+		}
+	}
+
+	// Words past the end are treated as zero.
+	limbs := getBits([]big.Word{1}, 0, 192)
+	if limbs != [3]uint64{1, 0, 0} {
+		t.Error(limbs)
+	}
+}
+
+// remLimbs should match big.Int.Mod.
+func TestRemLimbs(t *testing.T) {
+	limbs := [3]uint64{^uint64(0), 12345, ^uint64(0) - 1}
+	var x big.Int
+	for j := len(limbs) - 1; j >= 0; j-- {
+		x.Lsh(&x, 64)
+		x.Or(&x, new(big.Int).SetUint64(limbs[j]))
+	}
+	for _, n := range []uint64{1, 3, 1 << 32, ^uint64(0)} {
+		var expected big.Int
+		expected.Mod(&x, new(big.Int).SetUint64(n))
+		if r := remLimbs(limbs, n); r != expected.Uint64() {
+			t.Error(n, r, &expected)
+		}
+	}
+}
+
+// Multiplying random wordPolys should give the same results as
+// multiplying the equivalent bigIntPolys.
+func TestWordPolyMulMatchesBigIntPoly(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	for _, nStr := range []string{
+		"2", "101", "65537", "4294967291",
+		// The largest prime less than 2^64.
+		"18446744073709551557",
+	} {
+		var N big.Int
+		N.SetString(nStr, 10)
+		R := *big.NewInt(53)
+		p := newWordPoly(N, R)
+		q := newWordPoly(N, R)
+		tmp := newWordPoly(N, R)
+		pBig := newBigIntPoly(N, R)
+		qBig := newBigIntPoly(N, R)
+		tmpBig := newBigIntPoly(N, R)
+		for i := 0; i < 10; i++ {
+			var a big.Int
+			a.Rand(rng, &N)
+			k := *big.NewInt(rng.Int63n(53))
+			p.Set(a, k, N)
+			pBig.Set(a, k, N)
+			q.Set(*big.NewInt(int64(i)), *big.NewInt(1), N)
+			qBig.Set(*big.NewInt(int64(i)), *big.NewInt(1), N)
+			for j := 0; j < 5; j++ {
+				p.Mul(q, N, tmp)
+				pBig.Mul(qBig, N, tmpBig)
+				p.Mul(p, N, tmp)
+				pBig.Mul(pBig, N, tmpBig)
+			}
+			for j := 0; j < 53; j++ {
+				if p.coefficient(j).Cmp(pBig.coefficient(j)) != 0 {
+					t.Fatal(&N, i, j, p, pBig)
+				}
+			}
+		}
+	}
+}
+
+// calculateMontgomeryInverse(n) should return -1/n mod 2^64 for odd n,
+// and 0 for even n.
+func TestCalculateMontgomeryInverse(t *testing.T) {
+	for _, n := range []uint64{1, 3, 101, 4294967291, ^uint64(0)} {
+		inv := calculateMontgomeryInverse(n)
+		if n*inv != ^uint64(0) {
+			t.Error(n, inv)
+		}
+	}
+	if inv := calculateMontgomeryInverse(100); inv != 0 {
+		t.Error(inv)
+	}
+}
+
+// Moduli at the extremes of what a wordPoly can handle, or right
+// next to a power of two.
+var extremeWordPolyModuli = []string{
+	"3", "4294967291", "4294967295", "4294967296", "4294967311",
+	"9223372036854775808", "9223372036854775837",
+	// The largest prime less than 2^64.
+	"18446744073709551557",
+	"18446744073709551614", "18446744073709551615",
+}
+
+// reduce should handle the largest sum of products of R stored
+// coefficients that mul can give it, even for the largest R.
+func TestWordPolyReduceExtremes(t *testing.T) {
+	for _, nStr := range extremeWordPolyModuli {
+		var N big.Int
+		N.SetString(nStr, 10)
+		for _, r := range []int64{2, 53, maxInt} {
+			p := newWordPoly(N, *big.NewInt(2))
+			var x big.Int
+			x.Sub(&N, big.NewInt(1))
+			x.Mul(&x, &x)
+			x.Mul(&x, big.NewInt(r))
+			if x.BitLen() > 192 {
+				t.Fatal(&N, r, &x)
+			}
+			var limbs [3]uint64
+			var rest big.Int
+			rest.Set(&x)
+			for i := range limbs {
+				limbs[i] = new(big.Int).And(
+					&rest, new(big.Int).SetUint64(^uint64(0))).Uint64()
+				rest.Rsh(&rest, 64)
+			}
+
+			var expected big.Int
+			expected.Set(&x)
+			if p.nInv != 0 {
+				var inv big.Int
+				inv.Lsh(big.NewInt(1), 128)
+				inv.ModInverse(&inv, &N)
+				expected.Mul(&expected, &inv)
+			}
+			expected.Mod(&expected, &N)
+			if c := p.reduce(limbs); c != expected.Uint64() {
+				t.Error(&N, r, c, &expected)
+			}
+		}
+	}
+}
+
+// Multiplying wordPolys whose coefficients are all N - 1, which
+// maximizes the sums that mul and mulSparse accumulate, should give
+// the same results as multiplying the equivalent bigIntPolys.
+func TestWordPolyMulExtremes(t *testing.T) {
+	for _, nStr := range extremeWordPolyModuli {
+		var N big.Int
+		N.SetString(nStr, 10)
+		var maxC big.Int
+		maxC.Sub(&N, big.NewInt(1))
+		for _, R := range []big.Int{*big.NewInt(2), *big.NewInt(257)} {
+			p := newWordPoly(N, R)
+			q := newWordPoly(N, R)
+			tmp1 := newWordPoly(N, R)
+			tmp2 := newWordPoly(N, R)
+			pBig := newBigIntPoly(N, R)
+			qBig := newBigIntPoly(N, R)
+			tmpBig1 := newBigIntPoly(N, R)
+			tmpBig2 := newBigIntPoly(N, R)
+			rInt := int(R.Int64())
+			for i := 0; i < rInt; i++ {
+				p.setCoefficient(i, &maxC)
+				pBig.setCoefficient(i, &maxC)
+				q.setCoefficient(i, &maxC)
+				qBig.setCoefficient(i, &maxC)
+			}
+			p.Mul(q, N, tmp1)
+			pBig.Mul(qBig, N, tmpBig1)
+			p.Mul(p, N, tmp1)
+			pBig.Mul(pBig, N, tmpBig1)
+			// (X + (N - 1))^N exercises mulSparse too.
+			q.Set(maxC, *big.NewInt(1), N)
+			qBig.Set(maxC, *big.NewInt(1), N)
+			q.Pow(N, tmp1, tmp2)
+			qBig.Pow(N, tmpBig1, tmpBig2)
+			for i := 0; i < rInt; i++ {
+				if p.coefficient(i).Cmp(pBig.coefficient(i)) != 0 {
+					t.Fatal(&N, &R, i, p, pBig)
+				}
+				if q.coefficient(i).Cmp(qBig.coefficient(i)) != 0 {
+					t.Fatal(&N, &R, i, q, qBig)
+				}
+			}
+		}
+	}
+}
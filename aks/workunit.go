@@ -0,0 +1,260 @@
+package aks
+
+import "context"
+import "encoding/json"
+import "fmt"
+import "io"
+import "math/big"
+
+// A WorkUnit is part of the AKS witness search for N, namely the
+// range [Start, End) of candidates, which can be run independently
+// of the other parts (e.g., on another machine).
+type WorkUnit struct {
+	N, R, M    *big.Int
+	Start, End *big.Int
+}
+
+// Holds the outcome of running a WorkUnit.
+type WorkUnitResult struct {
+	WorkUnit
+	// The AKS witness found, if any.
+	Witness *big.Int
+	// The ranges of candidates found not to be AKS witnesses, in
+	// ascending order. If no witness was found and the unit
+	// wasn't cancelled, this is just [Start, End).
+	Completed []Range
+}
+
+// Splits the AKS witness search for n >= 2 into count work units of
+// roughly equal size.
+func MakeWorkUnits(n *big.Int, count int) ([]WorkUnit, error) {
+	if count <= 0 {
+		return nil, fmt.Errorf(
+			"%w: count = %d must be positive", ErrBadInput, count)
+	}
+	r, err := CalculateAKSModulus(n)
+	if err != nil {
+		return nil, err
+	}
+	M, err := CalculateAKSUpperBound(n, r)
+	if err != nil {
+		return nil, err
+	}
+
+	// Split [1, M) into count pieces.
+	var size big.Int
+	size.Sub(M, big.NewInt(1))
+	units := make([]WorkUnit, count)
+	for i := 0; i < count; i++ {
+		start := big.NewInt(int64(i))
+		start.Mul(start, &size)
+		start.Div(start, big.NewInt(int64(count)))
+		start.Add(start, big.NewInt(1))
+		end := big.NewInt(int64(i + 1))
+		end.Mul(end, &size)
+		end.Div(end, big.NewInt(int64(count)))
+		end.Add(end, big.NewInt(1))
+		units[i] = WorkUnit{n, r, M, start, end}
+	}
+	return units, nil
+}
+
+// Returns an error if u is inconsistent.
+func (u *WorkUnit) check() error {
+	if u.N == nil || u.R == nil || u.M == nil ||
+		u.Start == nil || u.End == nil {
+		return fmt.Errorf("%w: incomplete work unit", ErrBadInput)
+	}
+	r, err := CalculateAKSModulus(u.N)
+	if err != nil {
+		return err
+	}
+	M, err := CalculateAKSUpperBound(u.N, r)
+	if err != nil {
+		return err
+	}
+	if u.R.Cmp(r) != 0 || u.M.Cmp(M) != 0 {
+		return fmt.Errorf(
+			"%w: work unit has r = %v, M = %v, expected %v, %v",
+			ErrBadInput, u.R, u.M, r, M)
+	}
+	return nil
+}
+
+// Writes units to w as a JSON document.
+func ExportWorkUnits(w io.Writer, units []WorkUnit) error {
+	return writeIndentedJSON(w, units)
+}
+
+// Reads work units written by ExportWorkUnits.
+func ImportWorkUnits(r io.Reader) ([]WorkUnit, error) {
+	var units []WorkUnit
+	if err := json.NewDecoder(r).Decode(&units); err != nil {
+		return nil, err
+	}
+	for i := range units {
+		if err := units[i].check(); err != nil {
+			return nil, err
+		}
+	}
+	return units, nil
+}
+
+// Writes results to w as a JSON document.
+func ExportWorkUnitResults(w io.Writer, results []WorkUnitResult) error {
+	return writeIndentedJSON(w, results)
+}
+
+// Reads work unit results written by ExportWorkUnitResults.
+func ImportWorkUnitResults(r io.Reader) ([]WorkUnitResult, error) {
+	var results []WorkUnitResult
+	if err := json.NewDecoder(r).Decode(&results); err != nil {
+		return nil, err
+	}
+	for i := range results {
+		if err := results[i].check(); err != nil {
+			return nil, err
+		}
+		for _, r := range results[i].Completed {
+			if r.Start == nil || r.End == nil {
+				return nil, fmt.Errorf(
+					"%w: invalid range", ErrBadInput)
+			}
+		}
+	}
+	return results, nil
+}
+
+// Writes v to w as indented JSON.
+func writeIndentedJSON(w io.Writer, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(data, '\n'))
+	return err
+}
+
+// Searches the range of unit for AKS witnesses as configured by opts
+// (which may be nil, and whose Start, End, and trial division
+// options are ignored). Unlike RunAKS, no trial division is done;
+// that is left to MergeWorkUnitResults. Returns the partial result
+// along with ctx.Err() if ctx is cancelled.
+func RunWorkUnit(
+	ctx context.Context,
+	unit WorkUnit,
+	opts *AKSOptions) (*WorkUnitResult, error) {
+	if err := unit.check(); err != nil {
+		return nil, err
+	}
+	o := opts.withDefaults()
+	prog, err := newProgression(o.Stride, o.Offset)
+	if err != nil {
+		return nil, err
+	}
+	completed := &rangeSet{}
+	if o.Resume != nil {
+		completed, err = o.Resume.completedSet(unit.N, unit.R)
+		if err != nil {
+			return nil, err
+		}
+	}
+	chain, err := o.getAdditionChain(unit.N)
+	if err != nil {
+		return nil, err
+	}
+	a, err := searchAKSWitnesses(
+		ctx, unit.N, unit.R, unit.Start, unit.End, prog, &o,
+		completed, newStatsCollector(unit.N, chain))
+	result := &WorkUnitResult{
+		WorkUnit: unit,
+		Witness:  a,
+	}
+	// Only report the completed ranges within the unit.
+	inUnit := &rangeSet{}
+	for _, r := range completed.ranges {
+		inUnit.addRange(max(r.Start, unit.Start), min(r.End, unit.End))
+	}
+	result.Completed = inUnit.getRanges()
+	return result, err
+}
+
+// Combines the results of running work units for the same number n
+// into a result for n, as if RunAKS had been run with the default
+// options. Trial division is done here, and the verdict is Prime
+// only if the results cover all of [1, M). Since results may come
+// from untrusted files, the smallest witness, if any, is checked
+// before it's used, and an error wrapping ErrBadInput is returned
+// if it isn't actually a witness.
+func MergeWorkUnitResults(results []WorkUnitResult) (*PrimalityResult, error) {
+	if len(results) == 0 {
+		return nil, fmt.Errorf("%w: no results to merge", ErrBadInput)
+	}
+	first := results[0].WorkUnit
+	one := big.NewInt(1)
+	result := &PrimalityResult{
+		N:                  first.N,
+		R:                  first.R,
+		M:                  first.M,
+		Start:              one,
+		End:                first.M,
+		TrialDivisionBound: first.M,
+	}
+	if err := first.check(); err != nil {
+		return nil, err
+	}
+
+	completed := &rangeSet{}
+	for _, r := range results {
+		if r.N.Cmp(first.N) != 0 {
+			return nil, fmt.Errorf(
+				"%w: results are for both %v and %v",
+				ErrBadInput, first.N, r.N)
+		}
+		for _, c := range r.Completed {
+			completed.addRange(c.Start, c.End)
+		}
+		if r.Witness != nil && (result.Witness == nil ||
+			r.Witness.Cmp(result.Witness) < 0) {
+			result.Witness = r.Witness
+		}
+	}
+
+	var err error
+	result.Factor, err = GetFirstFactorBelow(first.N, first.M)
+	if err != nil {
+		return nil, err
+	}
+	var mSq big.Int
+	mSq.Mul(first.M, first.M)
+	switch {
+	case result.Factor != nil:
+		result.Verdict = Composite
+		result.Method = MethodTrialDivision
+		result.Witness = nil
+	case mSq.Cmp(first.N) > 0:
+		result.Verdict = Prime
+		result.Method = MethodSqrtBound
+		result.Witness = nil
+	case result.Witness != nil:
+		isWitness, err := IsAKSWitness(
+			first.N, first.R, result.Witness)
+		if err != nil {
+			return nil, err
+		}
+		if !isWitness {
+			return nil, fmt.Errorf(
+				"%w: %v is not an AKS witness of %v",
+				ErrBadInput, result.Witness, first.N)
+		}
+		result.Verdict = Composite
+		result.Method = MethodAKS
+	case completed.containsRange(one, first.M):
+		result.Verdict = Prime
+		result.Method = MethodAKS
+	default:
+		result.Verdict = Undetermined
+		result.Method = MethodAKS
+	}
+	return result, nil
+}
@@ -0,0 +1,134 @@
+package aks
+
+import "bytes"
+import "context"
+import "errors"
+import "math/big"
+import "testing"
+
+// Work units should partition [1, M).
+func TestMakeWorkUnits(t *testing.T) {
+	n := big.NewInt(1000003)
+	units, err := MakeWorkUnits(n, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(units) != 3 {
+		t.Fatal(units)
+	}
+	expectedStart := big.NewInt(1)
+	for _, unit := range units {
+		if unit.Start.Cmp(expectedStart) != 0 {
+			t.Error(unit.Start, expectedStart)
+		}
+		expectedStart = unit.End
+	}
+	if expectedStart.Cmp(units[0].M) != 0 {
+		t.Error(expectedStart, units[0].M)
+	}
+}
+
+// Running and merging all the work units for a prime should prove
+// it prime, even after a round trip through JSON, and merging only
+// some of them should be inconclusive.
+func TestRunAndMergeWorkUnits(t *testing.T) {
+	n := big.NewInt(1000003)
+	units, err := MakeWorkUnits(n, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := ExportWorkUnits(&buf, units); err != nil {
+		t.Fatal(err)
+	}
+	units, err = ImportWorkUnits(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var results []WorkUnitResult
+	for _, unit := range units {
+		result, err := RunWorkUnit(context.Background(), unit, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		results = append(results, *result)
+	}
+	buf.Reset()
+	if err := ExportWorkUnitResults(&buf, results); err != nil {
+		t.Fatal(err)
+	}
+	results, err = ImportWorkUnitResults(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := MergeWorkUnitResults(results)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Verdict != Prime || result.Method != MethodAKS {
+		t.Error(result.Verdict, result.Method)
+	}
+
+	result, err = MergeWorkUnitResults(results[:1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Verdict != Undetermined {
+		t.Error(result.Verdict)
+	}
+}
+
+// A witness found by any work unit should make the merged result
+// composite.
+func TestMergeWorkUnitResultsWitness(t *testing.T) {
+	n := big.NewInt(2993374621)
+	units, err := MakeWorkUnits(n, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := RunWorkUnit(context.Background(), units[0], nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Witness == nil {
+		t.Fatal(result)
+	}
+	merged, err := MergeWorkUnitResults([]WorkUnitResult{*result})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if merged.Verdict != Composite || merged.Witness == nil {
+		t.Error(merged.Verdict, merged.Witness)
+	}
+}
+
+// A result claiming a witness that isn't one shouldn't make the
+// merged result composite.
+func TestMergeWorkUnitResultsBogusWitness(t *testing.T) {
+	units, err := MakeWorkUnits(big.NewInt(1000003), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result := WorkUnitResult{WorkUnit: units[0], Witness: big.NewInt(1)}
+	if merged, err := MergeWorkUnitResults(
+		[]WorkUnitResult{result}); !errors.Is(err, ErrBadInput) {
+		t.Error(merged, err)
+	}
+}
+
+// Importing a work unit with the wrong parameters should fail.
+func TestImportWorkUnitsMismatch(t *testing.T) {
+	units := []WorkUnit{{
+		big.NewInt(1000003), big.NewInt(5), big.NewInt(401),
+		big.NewInt(1), big.NewInt(401),
+	}}
+	var buf bytes.Buffer
+	if err := ExportWorkUnits(&buf, units); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ImportWorkUnits(&buf); err == nil {
+		t.Error(err)
+	}
+}
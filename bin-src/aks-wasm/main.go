@@ -0,0 +1,87 @@
+//go:build js && wasm
+
+// A WebAssembly build of the AKS primality test for browser demos.
+// It defines a global JavaScript function
+//
+//	aksTest(number, onProgress, onDone)
+//
+// which tests the decimal string number for primality in the
+// background, calling onProgress(done, total) as AKS witnesses are
+// tested (with both arguments as decimal strings) and finally
+// onDone(verdict, method, error) with the strings describing the
+// result, or with an empty verdict and an error message on failure.
+// aksTest returns a function that cancels the test.
+package main
+
+import "github.com/akalin/aks-go/aks"
+import "context"
+import "fmt"
+import "math/big"
+import "syscall/js"
+import "time"
+
+// Forwards witness search estimates to a JavaScript callback.
+type jsObserver struct {
+	onProgress js.Value
+}
+
+// aks.ProgressObserver implementation.
+func (o jsObserver) OnWitnessTested(
+	a *big.Int, isWitness bool, d time.Duration) {
+}
+
+// aks.ProgressObserver implementation.
+func (o jsObserver) OnPhaseChange(phase aks.Phase) {}
+
+// aks.ProgressObserver implementation.
+func (o jsObserver) OnEstimate(e aks.Estimate) {
+	o.onProgress.Invoke(e.Done.String(), e.Total.String())
+}
+
+// Runs the primality test for aksTest and reports its result to
+// onDone.
+func runTest(ctx context.Context, s string, onProgress, onDone js.Value) {
+	n, ok := new(big.Int).SetString(s, 10)
+	if !ok || n.Cmp(big.NewInt(2)) < 0 {
+		onDone.Invoke("", "", fmt.Sprintf("invalid number %q", s))
+		return
+	}
+	lastYield := time.Now()
+	result, err := aks.RunAKS(ctx, n, &aks.AKSOptions{
+		// There is only one thread, so more jobs would just
+		// use more memory.
+		Jobs: 1,
+		// The pure Go backends are the only ones available
+		// without cgo.
+		Backend:  aks.AutoPolyBackend,
+		Observer: jsObserver{onProgress},
+		// Blocking on a timer lets the browser's event loop
+		// run, so the page stays responsive.
+		Yield: func() {
+			if time.Since(lastYield) >= 50*time.Millisecond {
+				time.Sleep(time.Millisecond)
+				lastYield = time.Now()
+			}
+		},
+	})
+	if err != nil {
+		onDone.Invoke("", "", err.Error())
+		return
+	}
+	onDone.Invoke(result.Verdict.String(), result.Method.String(), "")
+}
+
+func main() {
+	js.Global().Set("aksTest", js.FuncOf(
+		func(this js.Value, args []js.Value) interface{} {
+			ctx, cancel := context.WithCancel(context.Background())
+			go runTest(ctx, args[0].String(), args[1], args[2])
+			return js.FuncOf(
+				func(this js.Value, args []js.Value) interface{} {
+					cancel()
+					return nil
+				})
+		}))
+	// Keep the exported function around.
+	select {}
+}
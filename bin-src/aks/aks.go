@@ -1,73 +1,374 @@
 package main
 
 import "github.com/akalin/aks-go/aks"
+import "context"
+import "errors"
+import "expvar"
 import "flag"
 import "fmt"
+import "io/ioutil"
 import "log"
 import "math/big"
+import "net"
+import "net/http"
 import "os"
+import "os/signal"
 import "runtime"
-import "runtime/pprof"
+import "syscall"
+import "time"
+
+// A subcommand of the aks binary, other than the default "test".
+type command struct {
+	name, usage, description string
+	run                      func(args []string)
+}
+
+var commands []command
+
+func init() {
+	commands = []command{
+		{"test", "[options] number", "test number for primality " +
+			"(the default)", runTest},
+		{"params", "[options] number", "print the AKS parameters " +
+			"for number and estimate the resources needed to " +
+			"test it", runParams},
+		{"witness", "[options] number a", "check whether a is " +
+			"an AKS witness of number", runWitness},
+		{"factor", "[options] number", "report the factors of " +
+			"number found by trial division", runFactor},
+		{"compare", "[options] number", "compare how the Fermat, " +
+			"Solovay-Strassen, and Miller-Rabin tests and AKS " +
+			"behave on number", runCompare},
+		{"range", "[options] start end", "print the primes in " +
+			"[start, end)", runRange},
+		{"genprime", "[options] bits", "generate a random prime " +
+			"with the given number of bits and a certificate " +
+			"proving it prime", runGenPrime},
+		{"pocklington", "[options] number", "prove number prime " +
+			"with Pocklington's N-1 test, which is much faster " +
+			"than AKS if enough of number - 1 can be factored, " +
+			"and print the certificate", runPocklington},
+		{"nplusone", "[options] number", "like pocklington, but " +
+			"with the N+1 test, which needs number + 1 to be " +
+			"factored instead (or both number + 1 and number " +
+			"- 1, partially)", runNPlusOne},
+		{"proth", "[options] number", "prove number = k * 2^m + 1 " +
+			"with k < 2^m prime (or composite) with Proth's " +
+			"theorem and print the certificate", runProth},
+		{"ecpp", "[options] number", "prove number prime with " +
+			"elliptic curve primality proving, which works for " +
+			"primes of hundreds of digits of any form, and " +
+			"print the certificate", runECPP},
+		{"prove", "[options] number", "decide whether number is " +
+			"prime with whichever method suits it best, and " +
+			"print the certificate, if any", runProve},
+		{"aprcl", "number", "test number for primality with the " +
+			"APR-CL test, which is exact and handles numbers of " +
+			"hundreds of digits, but gives no certificate",
+			runAPRCL},
+		{"serve", "[options]", "serve an HTTP API for submitting " +
+			"and monitoring primality tests", runServe},
+		{"coordinate", "[options] number", "split the AKS " +
+			"witness search for number among workers", runCoordinate},
+		{"worker", "[options]", "search for AKS witnesses for " +
+			"a coordinator", runWorker},
+		{"repl", "[options]", "interactively test numbers and " +
+			"compute orders and totients", runRepl},
+	}
+}
+
+// The values of the -v flag.
+var verbosities = map[string]aks.Verbosity{
+	"silent":  aks.VerbositySilent,
+	"normal":  aks.VerbosityNormal,
+	"verbose": aks.VerbosityVerbose,
+	"debug":   aks.VerbosityDebug,
+}
+
+// The values of the -backend flag.
+var backends = map[string]aks.PolyBackend{
+	"auto":   aks.AutoPolyBackend,
+	"bigint": aks.BigIntPolyBackend,
+	"word":   aks.WordPolyBackend,
+	"ntt":    aks.NTTPolyBackend,
+	"mpz":    aks.MPZPolyBackend,
+	"flint":  aks.FLINTPolyBackend,
+	"opencl": aks.OpenCLPolyBackend,
+}
+
+// The values of the -order flag.
+var witnessOrders = map[string]aks.WitnessOrder{
+	"ascending":  aks.WitnessOrderAscending,
+	"descending": aks.WitnessOrderDescending,
+	"random":     aks.WitnessOrderRandom,
+}
+
+// The values of the -variant flag.
+var aksVariants = map[string]aks.AKSVariant{
+	"classic":    aks.AKSVariantClassic,
+	"bernstein":  aks.AKSVariantBernstein,
+	"conjecture": aks.AKSVariantConjecture,
+}
+
+// Prints the usage of the aks binary, along with the flags in fs.
+func printUsage(fs *flag.FlagSet) {
+	fmt.Fprintf(os.Stderr, "%s [test] [options] number\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "%s <command> [arguments]\n\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "Commands:\n")
+	for _, c := range commands {
+		fmt.Fprintf(os.Stderr, "  %s %s\n    \t%s\n",
+			c.name, c.usage, c.description)
+	}
+	fmt.Fprintf(os.Stderr, "\nOptions for test:\n")
+	fs.PrintDefaults()
+}
 
 func main() {
-	jobs := flag.Int(
+	args := os.Args[1:]
+	if len(args) > 0 {
+		for _, c := range commands {
+			if c.name == args[0] {
+				c.run(args[1:])
+				return
+			}
+		}
+	}
+	runTest(args)
+}
+
+// Runs the test subcommand with the given arguments.
+func runTest(args []string) {
+	fs := flag.NewFlagSet("test", flag.ExitOnError)
+	fs.Usage = func() { printUsage(fs) }
+	jobs := fs.Int(
 		"j", runtime.NumCPU(), "how many processing jobs to spawn")
-	startStr := flag.String(
+	startStr := fs.String(
 		"start", "", "the lower bound to use (defaults to 1)")
-	endStr := flag.String(
+	endStr := fs.String(
 		"end", "", "the upper bound to use (defaults to M)")
-	cpuProfilePath :=
-		flag.String("cpuprofile", "",
-			"Write a CPU profile to the specified file "+
-				"before exiting.")
+	strideStr := fs.String(
+		"stride", "",
+		"only test witnesses congruent to -offset modulo -stride "+
+			"(defaults to 1)")
+	offsetStr := fs.String(
+		"offset", "", "see -stride (defaults to 0)")
+	checkpointPath := fs.String(
+		"checkpoint", "",
+		"periodically save the progress of the AKS witness search "+
+			"to the specified file (defaults to the -resume file)")
+	checkpointInterval := fs.Duration(
+		"checkpoint-interval", aks.DefaultCheckpointInterval,
+		"how often to save checkpoints")
+	resumePath := fs.String(
+		"resume", "",
+		"resume the AKS witness search from the specified checkpoint")
+	certificatePath := fs.String(
+		"certificate", "",
+		"write a JSON certificate of the run to the specified file")
+	split := fs.Int(
+		"split", 0,
+		"instead of testing the number, write the specified number "+
+			"of JSON work units for it to stdout")
+	runUnitsPath := fs.String(
+		"run-units", "",
+		"instead of testing a number, run the JSON work units in the "+
+			"specified file and write the results as JSON to stdout")
+	inputPath := fs.String(
+		"input", "",
+		"instead of testing a single number, test each number in "+
+			"the specified file (or stdin if \"-\"), one per "+
+			"line, and print a result line for each")
+	csvPath := fs.String(
+		"csv", "",
+		"with -input, also append a CSV row for each number to the "+
+			"specified file")
+	merge := fs.Bool(
+		"merge", false,
+		"instead of testing a number, merge the JSON work unit "+
+			"results in the files given as arguments")
+	httpAddr := fs.String(
+		"http", "",
+		"serve the status of the test as JSON at /status, "+
+			"Prometheus metrics at /metrics, and expvar "+
+			"variables at /debug/vars on the specified address "+
+			"(e.g., localhost:8080)")
+	verbosityStr := fs.String(
+		"v", "normal",
+		"how much to log to stderr: silent (errors only), normal "+
+			"(phase changes), verbose (each witness test), or "+
+			"debug (each polynomial multiplication)")
+	backendStr := fs.String(
+		"backend", "auto",
+		"the polynomial implementation to use: auto (picks the "+
+			"fastest one for the number), bigint, word "+
+			"(only for numbers < 2^64), ntt, mpz (only if built "+
+			"with -tags gmp), flint (only if built with -tags "+
+			"flint), or opencl (only for odd numbers, and only if "+
+			"built with -tags opencl)")
+	windowBits := fs.Int(
+		"window", 1,
+		"raise polynomials to the nth power with sliding windows of "+
+			"up to this many bits, which does fewer "+
+			"multiplications but uses more memory (1 means "+
+			"binary exponentiation)")
+	orderStr := fs.String(
+		"order", "ascending",
+		"the order in which to test AKS witnesses: ascending, "+
+			"descending, or random (see -seed)")
+	seed := fs.Int64(
+		"seed", 1, "the seed for -order random and -ecm")
+	primesOnly := fs.Bool(
+		"primes", false,
+		"only test prime AKS witnesses, which finds witnesses of "+
+			"composites sooner but can't prove a number prime; "+
+			"use -resume with the checkpoint to finish the proof")
+	sharp := fs.Bool(
+		"sharp", false,
+		"use lg(n) instead of its ceiling to pick the AKS "+
+			"parameters, which usually gives a smaller r")
+	variantStr := fs.String(
+		"variant", "classic",
+		"the version of the AKS theorem used to pick r and M: "+
+			"classic, bernstein, or conjecture (fast, but "+
+			"assumes the unproven AKS conjecture)")
+	ecmCurves := fs.Int(
+		"ecm", 0,
+		"if positive, look for a factor of n with this many ECM "+
+			"curves before searching for AKS witnesses")
+	bpsw := fs.Bool(
+		"bpsw", true,
+		"check n with the Baillie-PSW test before searching for AKS "+
+			"witnesses, which finds almost all composites "+
+			"instantly (use -bpsw=false to always do the search)")
+	profiles := addProfileFlags(fs)
 
-	flag.Parse()
+	fs.Parse(args)
 
 	runtime.GOMAXPROCS(*jobs)
 
-	if flag.NArg() < 1 {
-		fmt.Fprintf(os.Stderr, "%s [options] [number]\n", os.Args[0])
-		flag.PrintDefaults()
+	if fs.NArg() < 1 && len(*runUnitsPath) == 0 &&
+		len(*inputPath) == 0 {
+		fs.Usage()
 		os.Exit(-1)
 	}
 
-	if len(*cpuProfilePath) > 0 {
-		f, err := os.Create(*cpuProfilePath)
-		if err != nil {
-			log.Fatal(err)
+	// os.Exit skips deferred calls, so stopProfiles must also be
+	// called before that.
+	stopProfiles := profiles.start()
+	defer stopProfiles()
+
+	verbosity, ok := verbosities[*verbosityStr]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "unknown verbosity %s\n", *verbosityStr)
+		os.Exit(-1)
+	}
+	backend, ok := backends[*backendStr]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "unknown backend %s\n", *backendStr)
+		os.Exit(-1)
+	}
+	if !backend.IsAvailable() {
+		fmt.Fprintf(os.Stderr,
+			"backend %s is not available in this build; "+
+				"available backends: %v\n",
+			backend, aks.AvailablePolyBackends())
+		os.Exit(-1)
+	}
+	order, ok := witnessOrders[*orderStr]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "unknown order %s\n", *orderStr)
+		os.Exit(-1)
+	}
+	variant, ok := aksVariants[*variantStr]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "unknown variant %s\n", *variantStr)
+		os.Exit(-1)
+	}
+	// The aks package decides what to log to logger based on
+	// verbosity; infoLogger is for messages of our own at
+	// aks.VerbosityNormal.
+	logger := log.New(os.Stderr, "", 0)
+	infoLogger := logger
+	if verbosity < aks.VerbosityNormal {
+		infoLogger = log.New(ioutil.Discard, "", 0)
+	}
+	baseOpts := aks.AKSOptions{
+		Jobs:               *jobs,
+		Logger:             logger,
+		Verbosity:          verbosity,
+		Backend:            backend,
+		PowWindowBits:      *windowBits,
+		WitnessOrder:       order,
+		WitnessOrderSeed:   *seed,
+		PrimeWitnessesOnly: *primesOnly,
+		SharpBounds:        *sharp,
+		Variant:            variant,
+		SkipBPSW:           !*bpsw,
+	}
+	if *ecmCurves > 0 {
+		baseOpts.ECM = &aks.ECMOptions{
+			Curves: *ecmCurves,
+			Seed:   *seed,
 		}
+	}
 
-		pprof.StartCPUProfile(f)
-		defer pprof.StopCPUProfile()
+	if len(*runUnitsPath) > 0 {
+		runWorkUnits(*runUnitsPath, &baseOpts, infoLogger)
+		return
 	}
 
-	var start big.Int
-	if len(*startStr) > 0 {
-		_, parsed := start.SetString(*startStr, 10)
-		if !parsed {
-			fmt.Fprintf(
-				os.Stderr, "could not parse %s\n", *startStr)
-			os.Exit(-1)
+	if len(*inputPath) > 0 {
+		ctx, stop := signal.NotifyContext(
+			context.Background(), os.Interrupt, syscall.SIGTERM)
+		var results *resultCSVWriter
+		if len(*csvPath) > 0 {
+			var err error
+			results, err = openResultCSV(*csvPath)
+			if err != nil {
+				log.Fatal(err)
+			}
+		}
+		ok := runBatch(ctx, *inputPath, &baseOpts, results)
+		stop()
+		if results != nil {
+			if err := results.close(); err != nil {
+				log.Fatal(err)
+			}
 		}
+		if !ok {
+			stopProfiles()
+			os.Exit(1)
+		}
+		return
 	}
 
-	var end big.Int
+	if *merge {
+		mergeWorkUnitResults(fs.Args())
+		return
+	}
+
+	start := &big.Int{}
+	if len(*startStr) > 0 {
+		start = parseFlagNumber(*startStr)
+	}
+
+	end := &big.Int{}
 	if len(*endStr) > 0 {
-		_, parsed := end.SetString(*endStr, 10)
-		if !parsed {
-			fmt.Fprintf(os.Stderr, "could not parse %s\n", *endStr)
-			os.Exit(-1)
-		}
+		end = parseFlagNumber(*endStr)
 	}
 
-	var n big.Int
-	_, parsed := n.SetString(flag.Arg(0), 10)
-	if !parsed {
-		fmt.Fprintf(os.Stderr, "could not parse %s\n", flag.Arg(0))
-		os.Exit(-1)
+	var stride *big.Int
+	if len(*strideStr) > 0 {
+		stride = parseFlagNumber(*strideStr)
 	}
 
-	one := big.NewInt(1)
+	offset := &big.Int{}
+	if len(*offsetStr) > 0 {
+		offset = parseFlagNumber(*offsetStr)
+	}
+
+	n := parseFlagNumber(fs.Arg(0))
+
 	two := big.NewInt(2)
 
 	if n.Cmp(two) < 0 {
@@ -75,41 +376,217 @@ func main() {
 		os.Exit(-1)
 	}
 
-	r := aks.CalculateAKSModulus(&n)
-	M := aks.CalculateAKSUpperBound(&n, r)
+	if *split > 0 {
+		units, err := aks.MakeWorkUnits(n, *split)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if err := aks.ExportWorkUnits(os.Stdout, units); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
 
-	if start.Cmp(one) < 0 {
-		start.Set(one)
+	var resume *aks.Checkpoint
+	if len(*resumePath) > 0 {
+		var err error
+		resume, err = aks.LoadCheckpoint(*resumePath)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if len(*checkpointPath) == 0 {
+			*checkpointPath = *resumePath
+		}
 	}
-	if end.Sign() <= 0 {
-		end.Set(M)
+
+	var observer aks.ProgressObserver
+	if len(*httpAddr) > 0 {
+		tracker, err := aks.NewStatusTracker(n)
+		if err != nil {
+			log.Fatal(err)
+		}
+		metrics := aks.NewMetrics(*jobs)
+		mux := http.NewServeMux()
+		mux.Handle("/status", tracker)
+		mux.Handle("/metrics", metrics)
+		mux.Handle("/debug/vars", expvar.Handler())
+		if err := startHTTPServer(
+			*httpAddr, mux, infoLogger); err != nil {
+			log.Fatal(err)
+		}
+		observer = aks.MultiObserver(tracker, metrics)
 	}
+
+	// On SIGINT or SIGTERM, stop the search and save its progress.
+	// A second signal kills the process as usual.
+	ctx, stop := signal.NotifyContext(
+		context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+
+	opts := baseOpts
+	opts.Start = start
+	opts.End = end
+	opts.Stride = stride
+	opts.Offset = offset
+	opts.CheckpointPath = *checkpointPath
+	opts.CheckpointInterval = *checkpointInterval
+	opts.Resume = resume
+	opts.Observer = observer
+	opts.GenerateCertificate = len(*certificatePath) > 0
+	result, err := aks.RunAKS(ctx, n, &opts)
+	if errors.Is(err, context.Canceled) && result != nil {
+		logStats(infoLogger, result.Stats)
+		saveInterrupted(result, *checkpointPath)
+		stopProfiles()
+		os.Exit(1)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	logStats(infoLogger, result.Stats)
+
+	if result.Certificate != nil {
+		if err := writeCertificate(
+			*certificatePath, result.Certificate); err != nil {
+			log.Fatal(err)
+		}
+	}
+
 	fmt.Printf("n = %v, r = %v, M = %v, start = %v, end = %v\n",
-		&n, r, M, &start, &end)
-	factor := aks.GetFirstFactorBelow(&n, M)
-	if factor != nil {
-		fmt.Printf("n has factor %v\n", factor)
+		n, result.R, result.M, result.Start, result.End)
+	if result.Method == aks.MethodTrialDivision {
+		fmt.Printf("n has factor %v\n", result.Factor)
 		return
 	}
 
-	fmt.Printf("n has no factor less than %v\n", M)
-	// M^2 > N iff M > floor(sqrt(N)).
-	var mSq big.Int
-	mSq.Mul(M, M)
-	if mSq.Cmp(&n) > 0 {
+	fmt.Printf("n has no factor less than %v\n", result.M)
+	if result.Method == aks.MethodECM {
+		fmt.Printf("n has factor %v (found with ECM)\n", result.Factor)
+		return
+	}
+	if result.Method == aks.MethodBPSW {
+		fmt.Printf("n is composite (failed the Baillie-PSW test)\n")
+		return
+	}
+	if result.Method == aks.MethodSqrtBound {
 		fmt.Printf("%v is greater than sqrt(%v), so %v is prime\n",
-			M, &n, &n)
+			result.M, n, n)
 		return
 	}
 
-	logger := log.New(os.Stderr, "", 0)
-	a := aks.GetAKSWitness(&n, r, &start, &end, *jobs, logger)
-	if a != nil {
-		fmt.Printf("n is composite with AKS witness %v\n", a)
-	} else if start.Cmp(one) > 0 || end.Cmp(M) < 0 {
-		fmt.Printf("n has no AKS witnesses >= %v and < %v\n",
-			&start, &end)
-	} else {
-		fmt.Printf("n is prime\n")
+	switch result.Verdict {
+	case aks.Composite:
+		fmt.Printf("n is composite with AKS witness %v\n",
+			result.Witness)
+	case aks.Undetermined:
+		if stride != nil {
+			fmt.Printf("n has no AKS witnesses >= %v and < %v "+
+				"congruent to %v mod %v\n", result.Start,
+				result.End, offset, stride)
+		} else {
+			fmt.Printf("n has no AKS witnesses >= %v and < %v\n",
+				result.Start, result.End)
+		}
+	default:
+		if result.Method == aks.MethodAKSConjecture {
+			fmt.Printf("n is prime, assuming the AKS conjecture\n")
+		} else {
+			fmt.Printf("n is prime\n")
+		}
+	}
+}
+
+// Logs the given stats, if any.
+func logStats(logger *log.Logger, stats *aks.Stats) {
+	if stats == nil {
+		return
+	}
+	logger.Printf("Tested %d witnesses with %d multiplications "+
+		"in %v (%.2f witnesses/s, %v per witness per job, "+
+		"%d bytes of polynomials)\n",
+		stats.WitnessesTested, stats.Multiplications,
+		stats.Elapsed, stats.WitnessesPerSecond,
+		averageDuration(stats.MulTime, stats.WitnessesTested),
+		stats.PeakPhiBytes)
+}
+
+// Makes sure the progress of the interrupted run with the given
+// partial result is saved, to checkpointPath if non-empty (where
+// RunAKS has already saved it) or to a file named after n otherwise,
+// and prints a summary.
+func saveInterrupted(result *aks.PrimalityResult, checkpointPath string) {
+	if len(checkpointPath) == 0 {
+		checkpointPath = fmt.Sprintf("aks-%v.checkpoint", result.N)
+		c := aks.Checkpoint{
+			N:         result.N,
+			R:         result.R,
+			Completed: result.Completed,
+		}
+		if err := c.Save(checkpointPath); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	var done big.Int
+	for _, r := range result.Completed {
+		var size big.Int
+		size.Sub(r.End, r.Start)
+		done.Add(&done, &size)
+	}
+	fmt.Printf("n = %v, r = %v, M = %v, start = %v, end = %v\n",
+		result.N, result.R, result.M, result.Start, result.End)
+	fmt.Printf("interrupted with %v numbers known not to be AKS "+
+		"witnesses; resume with -resume %s\n", &done, checkpointPath)
+}
+
+// Writes the given certificate as JSON to the file at path.
+func writeCertificate(path string, c *aks.Certificate) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	err = c.WriteJSON(f)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// Starts serving handler on the given address in the background,
+// logging any errors after it has started listening.
+func startHTTPServer(
+	addr string, handler http.Handler, logger *log.Logger) error {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	logger.Printf("Serving HTTP on %v\n", l.Addr())
+	go func() {
+		logger.Print(http.Serve(l, handler))
+	}()
+	return nil
+}
+
+// Returns total / count, or 0 if count is 0.
+func averageDuration(total time.Duration, count int64) time.Duration {
+	if count == 0 {
+		return 0
+	}
+	return total / time.Duration(count)
+}
+
+// Parses s as a number or expression (see aks.ParseNumber), exiting
+// if it can't be.
+func parseFlagNumber(s string) *big.Int {
+	x, err := aks.ParseNumber(s)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(-1)
 	}
+	return x
 }
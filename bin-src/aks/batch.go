@@ -0,0 +1,81 @@
+package main
+
+import "github.com/akalin/aks-go/aks"
+import "bufio"
+import "context"
+import "fmt"
+import "io"
+import "log"
+import "os"
+import "strings"
+import "time"
+
+// Tests each number in the file at path (or stdin if path is "-"),
+// one per line, in sequence with the given options, and prints a
+// result line for each. If results is non-nil, a row is also
+// appended to it for each number. Blank lines and lines starting
+// with '#' are skipped. Returns whether every number was tested
+// successfully.
+func runBatch(
+	ctx context.Context,
+	path string,
+	opts *aks.AKSOptions,
+	results *resultCSVWriter) bool {
+	var r io.Reader = os.Stdin
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer f.Close()
+		r = f
+	}
+
+	ok := true
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if len(line) == 0 || strings.HasPrefix(line, "#") {
+			continue
+		}
+		n, err := aks.ParseNumber(line)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			ok = false
+			continue
+		}
+		startTime := time.Now()
+		result, err := aks.RunAKS(ctx, n, opts)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v: %v\n", n, err)
+			ok = false
+			if ctx.Err() != nil {
+				return false
+			}
+			continue
+		}
+		fmt.Println(formatBatchResult(result))
+		if results != nil {
+			err := results.write(result, time.Since(startTime))
+			if err != nil {
+				log.Fatal(err)
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		log.Fatal(err)
+	}
+	return ok
+}
+
+// Returns a one-line summary of the given result.
+func formatBatchResult(result *aks.PrimalityResult) string {
+	s := fmt.Sprintf("%v: %v (%v)", result.N, result.Verdict, result.Method)
+	switch {
+	case result.Factor != nil:
+		s += fmt.Sprintf(" with factor %v", result.Factor)
+	case result.Witness != nil:
+		s += fmt.Sprintf(" with AKS witness %v", result.Witness)
+	}
+	return s
+}
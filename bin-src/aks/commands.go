@@ -0,0 +1,178 @@
+package main
+
+import "github.com/akalin/aks-go/aks"
+import "github.com/akalin/aks-go/aks/aprcl"
+import "errors"
+import "flag"
+import "fmt"
+import "log"
+import "math/big"
+import "os"
+import "runtime"
+
+// Makes a flag set for the named subcommand whose usage line
+// describes the given positional arguments.
+func newCommandFlagSet(name, usage string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "%s %s %s\n", os.Args[0], name, usage)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// Parses args with fs, and exits with a usage message unless there
+// are exactly argCount positional arguments.
+func parseCommandArgs(fs *flag.FlagSet, args []string, argCount int) {
+	fs.Parse(args)
+	if fs.NArg() != argCount {
+		fs.Usage()
+		os.Exit(-1)
+	}
+}
+
+// Returns the AKS parameters r and M for n, exiting on error.
+func mustCalculateParams(n *big.Int) (r, M *big.Int) {
+	r, err := aks.CalculateAKSModulus(n)
+	if err != nil {
+		log.Fatal(err)
+	}
+	M, err = aks.CalculateAKSUpperBound(n, r)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return r, M
+}
+
+// Runs the params subcommand with the given arguments.
+func runParams(args []string) {
+	fs := newCommandFlagSet("params", "[options] number")
+	jobs := fs.Int(
+		"j", runtime.NumCPU(),
+		"how many processing jobs to estimate memory usage for")
+	windowBits := fs.Int(
+		"window", 1,
+		"estimate multiplications for sliding windows of up to this "+
+			"many bits, as with the -window option of the main "+
+			"command (1 means binary exponentiation)")
+	parseCommandArgs(fs, args, 1)
+	n := parseFlagNumber(fs.Arg(0))
+	e, err := aks.EstimateJob(
+		n, &aks.AKSOptions{PowWindowBits: *windowBits})
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("n = %v, r = %v, M = %v\n", n, e.R, e.M)
+	fmt.Printf("%v polynomial backend; k = %d words per coefficient "+
+		"with the bigint backend\n", e.Backend, e.K)
+	fmt.Printf("%d bytes of polynomials per job, %d bytes for %d jobs\n",
+		e.PhiBytesPerJob, e.PhiBytesPerJob*int64(*jobs), *jobs)
+	fmt.Printf("%d multiplications per witness, %v multiplications "+
+		"for all witnesses\n", e.MultiplicationsPerWitness,
+		e.TotalMultiplications)
+}
+
+// Runs the witness subcommand with the given arguments.
+func runWitness(args []string) {
+	fs := newCommandFlagSet("witness", "[options] number a")
+	explain := fs.Bool(
+		"explain", false,
+		"print both sides of the AKS congruence and the first "+
+			"coefficient where they differ")
+	parseCommandArgs(fs, args, 2)
+	n := parseFlagNumber(fs.Arg(0))
+	a := parseFlagNumber(fs.Arg(1))
+	r, _ := mustCalculateParams(n)
+	e, err := aks.ExplainAKSWitness(n, r, a)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("n = %v, r = %v\n", n, r)
+	if *explain {
+		fmt.Printf("(x + %v)^n mod (n, x^r - 1) = %s\n", a, e.LHS)
+		fmt.Printf("x^n + %v mod (n, x^r - 1) = %s\n", a, e.RHS)
+		if e.IsWitness() {
+			fmt.Printf("the coefficients of x^%d differ: %v != %v\n",
+				e.FirstDifference, e.LHSCoefficient,
+				e.RHSCoefficient)
+		}
+	}
+	if e.IsWitness() {
+		fmt.Printf("%v is an AKS witness of n, so n is composite\n", a)
+	} else {
+		fmt.Printf("%v is not an AKS witness of n\n", a)
+	}
+}
+
+// Runs the factor subcommand with the given arguments.
+func runFactor(args []string) {
+	fs := newCommandFlagSet("factor", "[options] number")
+	boundStr := fs.String(
+		"bound", "",
+		"only try factors less than this (defaults to M)")
+	parseCommandArgs(fs, args, 1)
+	n := parseFlagNumber(fs.Arg(0))
+	var bound *big.Int
+	if len(*boundStr) > 0 {
+		bound = parseFlagNumber(*boundStr)
+	} else {
+		_, bound = mustCalculateParams(n)
+	}
+
+	fmt.Printf("n = %v, bound = %v\n", n, bound)
+	printFactors(n, bound)
+}
+
+// Prints the prime factors of n less than bound found by trial
+// division, along with what's known about the remaining cofactor.
+func printFactors(n, bound *big.Int) {
+	var maxFactor big.Int
+	maxFactor.Sub(bound, big.NewInt(1))
+	if maxFactor.Sign() < 0 {
+		maxFactor.SetInt64(0)
+	}
+	f, err := aks.FactorBounded(n, &maxFactor)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	for _, pk := range f.Factors {
+		switch {
+		case pk.P.Cmp(bound) < 0:
+			fmt.Printf("factor %v^%v\n", pk.P, pk.K)
+		case pk.P.Cmp(n) == 0:
+			fmt.Printf("n is prime, and has no factor less "+
+				"than %v\n", bound)
+		default:
+			fmt.Printf("factor %v^1 (prime, with no factor "+
+				"less than %v)\n", pk.P, bound)
+		}
+	}
+	switch {
+	case !f.Complete:
+		fmt.Printf("remaining cofactor %v is composite, but has no "+
+			"factor less than %v\n", f.Cofactor, bound)
+	case len(f.Factors) != 1 || f.Factors[0].P.Cmp(n) != 0:
+		fmt.Printf("n is completely factored\n")
+	}
+}
+
+// Runs the aprcl subcommand with the given arguments.
+func runAPRCL(args []string) {
+	fs := newCommandFlagSet("aprcl", "number")
+	parseCommandArgs(fs, args, 1)
+	n := parseFlagNumber(fs.Arg(0))
+
+	isPrime, err := aprcl.IsPrime(n)
+	switch {
+	case errors.Is(err, aks.ErrProofNotFound):
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	case err != nil:
+		log.Fatal(err)
+	case isPrime:
+		fmt.Printf("n is prime\n")
+	default:
+		fmt.Printf("n is composite\n")
+	}
+}
@@ -0,0 +1,91 @@
+package main
+
+import "github.com/akalin/aks-go/aks"
+import "context"
+import "fmt"
+import "log"
+import "math/big"
+import "math/rand"
+
+// Returns how many of the given bases n passes test with, where bases
+// is nil to mean all of [1, n - 1].
+func countPassingBases(
+	test aks.ProbabilisticTest, n *big.Int, bases []*big.Int) int64 {
+	passes := func(base *big.Int) bool {
+		ok, err := test.Passes(n, base)
+		if err != nil {
+			log.Fatal(err)
+		}
+		return ok
+	}
+	var count int64
+	if bases == nil {
+		one := big.NewInt(1)
+		for a := big.NewInt(1); a.Cmp(n) < 0; a.Add(a, one) {
+			if passes(a) {
+				count++
+			}
+		}
+		return count
+	}
+	for _, base := range bases {
+		if passes(base) {
+			count++
+		}
+	}
+	return count
+}
+
+// Runs the compare subcommand with the given arguments.
+func runCompare(args []string) {
+	fs := newCommandFlagSet("compare", "[options] number")
+	samples := fs.Int64(
+		"samples", 10000,
+		"test every base if number - 1 is at most this, and this "+
+			"many random bases otherwise")
+	seed := fs.Int64("seed", 1, "the seed for picking random bases")
+	parseCommandArgs(fs, args, 1)
+	n := parseFlagNumber(fs.Arg(0))
+	if n.Cmp(big.NewInt(3)) < 0 || n.Bit(0) == 0 {
+		log.Fatalf("number must be odd and >= 3")
+	}
+
+	var bases []*big.Int
+	baseCount := new(big.Int).Sub(n, big.NewInt(1))
+	if baseCount.Cmp(big.NewInt(*samples)) > 0 {
+		rnd := rand.New(rand.NewSource(*seed))
+		for i := int64(0); i < *samples; i++ {
+			base := new(big.Int).Rand(rnd, baseCount)
+			bases = append(bases, base.Add(base, big.NewInt(1)))
+		}
+		baseCount.SetInt64(*samples)
+		fmt.Printf("n = %v; testing %v random bases in [1, n)\n",
+			n, baseCount)
+	} else {
+		fmt.Printf("n = %v; testing all %v bases in [1, n)\n",
+			n, baseCount)
+	}
+	for _, test := range aks.ProbabilisticTests {
+		count := countPassingBases(test, n, bases)
+		fmt.Printf("%s: passes with %d of %v bases\n",
+			test.Name(), count, baseCount)
+	}
+
+	result, err := aks.RunAKS(context.Background(), n, &aks.AKSOptions{
+		Verbosity: aks.VerbositySilent,
+		SkipBPSW:  true,
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	switch {
+	case result.Factor != nil:
+		fmt.Printf("AKS: %v (factor %v found by %v)\n",
+			result.Verdict, result.Factor, result.Method)
+	case result.Witness != nil:
+		fmt.Printf("AKS: %v (witness %v)\n",
+			result.Verdict, result.Witness)
+	default:
+		fmt.Printf("AKS: %v (%v)\n", result.Verdict, result.Method)
+	}
+}
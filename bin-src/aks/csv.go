@@ -0,0 +1,77 @@
+package main
+
+import "github.com/akalin/aks-go/aks"
+import "encoding/csv"
+import "fmt"
+import "math/big"
+import "os"
+import "time"
+
+// Appends a row per tested number to a CSV file.
+type resultCSVWriter struct {
+	f *os.File
+	w *csv.Writer
+}
+
+// The header row of the CSV file.
+var resultCSVHeader = []string{
+	"n", "verdict", "method", "witness", "factor", "r", "M",
+	"elapsed_seconds",
+}
+
+// Opens the CSV file at path for appending, creating it (with a
+// header row) if it doesn't exist or is empty.
+func openResultCSV(path string) (*resultCSVWriter, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0666)
+	if err != nil {
+		return nil, err
+	}
+	c := &resultCSVWriter{f, csv.NewWriter(f)}
+	info, err := f.Stat()
+	if err == nil && info.Size() == 0 {
+		err = c.writeRow(resultCSVHeader)
+	}
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// Writes and flushes a single row.
+func (c *resultCSVWriter) writeRow(row []string) error {
+	if err := c.w.Write(row); err != nil {
+		return err
+	}
+	c.w.Flush()
+	return c.w.Error()
+}
+
+// Returns x as a decimal string, or the empty string if x is nil.
+func formatOptionalInt(x *big.Int) string {
+	if x == nil {
+		return ""
+	}
+	return x.String()
+}
+
+// Appends a row for the given result, which took the given time to
+// compute. Any of the fields of result other than N may be nil.
+func (c *resultCSVWriter) write(
+	result *aks.PrimalityResult, elapsed time.Duration) error {
+	return c.writeRow([]string{
+		result.N.String(),
+		result.Verdict.String(),
+		result.Method.String(),
+		formatOptionalInt(result.Witness),
+		formatOptionalInt(result.Factor),
+		formatOptionalInt(result.R),
+		formatOptionalInt(result.M),
+		fmt.Sprintf("%.6f", elapsed.Seconds()),
+	})
+}
+
+// Closes the underlying file.
+func (c *resultCSVWriter) close() error {
+	return c.f.Close()
+}
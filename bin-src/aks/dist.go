@@ -0,0 +1,158 @@
+package main
+
+import "github.com/akalin/aks-go/aks"
+import "github.com/akalin/aks-go/aks/dist"
+import "context"
+import "errors"
+import "fmt"
+import "log"
+import "net"
+import "os"
+import "os/signal"
+import "runtime"
+import "syscall"
+import "time"
+
+// Runs the coordinate subcommand with the given arguments.
+func runCoordinate(args []string) {
+	fs := newCommandFlagSet("coordinate", "[options] number")
+	addr := fs.String(
+		"addr", ":7070", "the address to serve workers on")
+	chunkStr := fs.String(
+		"chunk", dist.DefaultChunkSize.String(),
+		"how many witness candidates to lease to a worker at a time")
+	leaseDuration := fs.Duration(
+		"lease", dist.DefaultLeaseDuration,
+		"how long a worker has to complete a lease before it is "+
+			"reassigned")
+	checkpointPath := fs.String(
+		"checkpoint", "",
+		"save the progress of the search to the specified file "+
+			"after each completed lease (defaults to the -resume "+
+			"file)")
+	resumePath := fs.String(
+		"resume", "",
+		"resume the search from the specified checkpoint")
+	linger := fs.Duration(
+		"linger", 5*time.Second,
+		"how long to keep serving workers after the search is "+
+			"finished")
+	parseCommandArgs(fs, args, 1)
+	n := parseFlagNumber(fs.Arg(0))
+
+	opts := dist.CoordinatorOptions{
+		ChunkSize:      parseFlagNumber(*chunkStr),
+		LeaseDuration:  *leaseDuration,
+		CheckpointPath: *checkpointPath,
+	}
+	if len(*resumePath) > 0 {
+		resume, err := aks.LoadCheckpoint(*resumePath)
+		if err != nil {
+			log.Fatal(err)
+		}
+		opts.Resume = resume
+		if len(opts.CheckpointPath) == 0 {
+			opts.CheckpointPath = *resumePath
+		}
+	}
+	c, err := dist.NewCoordinator(n, &opts)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	l, err := net.Listen("tcp", *addr)
+	if err != nil {
+		log.Fatal(err)
+	}
+	logger := log.New(os.Stderr, "", 0)
+	unit := c.GetWorkUnit()
+	logger.Printf("Coordinating the search of [%v, %v) for n = %v on %v\n",
+		unit.Start, unit.End, n, l.Addr())
+	serveErrCh := make(chan error, 1)
+	go func() {
+		serveErrCh <- dist.Serve(l, c)
+	}()
+	doneCh := make(chan struct{})
+	go func() {
+		c.Wait()
+		close(doneCh)
+	}()
+	select {
+	case err := <-serveErrCh:
+		log.Fatal(err)
+	case <-doneCh:
+	}
+	// Keep answering workers for a bit, so that they find out
+	// that there's no more work instead of losing their
+	// connections.
+	time.Sleep(*linger)
+	l.Close()
+
+	result, err := c.GetResult()
+	if err != nil {
+		log.Fatal(err)
+	}
+	printMergedResult(result)
+}
+
+// Runs the worker subcommand with the given arguments.
+func runWorker(args []string) {
+	fs := newCommandFlagSet("worker", "[options]")
+	coordinator := fs.String(
+		"coordinator", "",
+		"the host:port of the coordinator to get work from")
+	jobs := fs.Int(
+		"j", runtime.NumCPU(), "how many processing jobs to spawn")
+	workerID := fs.String(
+		"id", "",
+		"the name to identify this worker to the coordinator "+
+			"(defaults to <hostname>-<pid>)")
+	retryInterval := fs.Duration(
+		"retry", dist.DefaultRetryInterval,
+		"how long to wait before asking for more work when none "+
+			"is available")
+	parseCommandArgs(fs, args, 0)
+	if len(*coordinator) == 0 {
+		fs.Usage()
+		os.Exit(-1)
+	}
+	if len(*workerID) == 0 {
+		hostname, err := os.Hostname()
+		if err != nil {
+			hostname = "worker"
+		}
+		*workerID = fmt.Sprintf("%s-%d", hostname, os.Getpid())
+	}
+
+	runtime.GOMAXPROCS(*jobs)
+
+	client, err := dist.Dial(*coordinator)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer client.Close()
+
+	// An interrupted lease isn't reported, so the coordinator
+	// reassigns it once it expires.
+	ctx, stop := signal.NotifyContext(
+		context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	logger := log.New(os.Stderr, "", 0)
+	logger.Printf("Working for %v as %s\n", *coordinator, *workerID)
+	startTime := time.Now()
+	completed, err := dist.RunWorker(ctx, client, *workerID,
+		&dist.WorkerOptions{
+			AKSOptions: &aks.AKSOptions{
+				Jobs:      *jobs,
+				Logger:    logger,
+				Verbosity: aks.VerbositySilent,
+			},
+			RetryInterval: *retryInterval,
+		})
+	logger.Printf("Completed %d leases in %v\n",
+		completed, time.Since(startTime))
+	if err != nil && !errors.Is(err, context.Canceled) {
+		log.Fatal(err)
+	}
+}
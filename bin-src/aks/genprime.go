@@ -0,0 +1,128 @@
+package main
+
+import "github.com/akalin/aks-go/aks"
+import "context"
+import "crypto/rand"
+import "fmt"
+import "log"
+import "math"
+import "math/big"
+import mathrand "math/rand"
+import "os"
+import "os/signal"
+import "runtime"
+import "strconv"
+import "syscall"
+
+// Candidates with a factor below this are discarded before the
+// Miller-Rabin test.
+const genPrimeTrialDivisionBound = 1000
+
+// The number of Miller-Rabin rounds used to filter candidates.
+const genPrimeMillerRabinRounds = 20
+
+// Returns a random odd number with exactly the given number of bits,
+// which must be at least 2.
+func randomOddNumber(bits int) (*big.Int, error) {
+	var min big.Int
+	min.Lsh(big.NewInt(1), uint(bits-1))
+	n, err := rand.Int(rand.Reader, &min)
+	if err != nil {
+		return nil, err
+	}
+	n.Add(n, &min)
+	n.SetBit(n, 0, 1)
+	return n, nil
+}
+
+// Returns a random number with the given number of bits that is
+// probably prime, along with the number of candidates tried.
+func findProbablePrime(bits int) (n *big.Int, tried int, err error) {
+	bound := big.NewInt(genPrimeTrialDivisionBound)
+	// The Miller-Rabin bases don't need to be unpredictable, just
+	// different from run to run.
+	seed, err := rand.Int(rand.Reader, big.NewInt(math.MaxInt64))
+	if err != nil {
+		return nil, 0, err
+	}
+	rnd := mathrand.New(mathrand.NewSource(seed.Int64()))
+	for {
+		n, err := randomOddNumber(bits)
+		if err != nil {
+			return nil, tried, err
+		}
+		tried++
+		factor, err := aks.GetFirstFactorBelow(n, bound)
+		if err != nil {
+			return nil, tried, err
+		}
+		if factor != nil {
+			continue
+		}
+		isPrime, err := aks.MillerRabin(
+			n, genPrimeMillerRabinRounds, rnd)
+		if err != nil {
+			return nil, tried, err
+		}
+		if isPrime {
+			return n, tried, nil
+		}
+	}
+}
+
+// Runs the genprime subcommand with the given arguments.
+func runGenPrime(args []string) {
+	fs := newCommandFlagSet("genprime", "[options] bits")
+	jobs := fs.Int(
+		"j", runtime.NumCPU(), "how many processing jobs to spawn")
+	certificatePath := fs.String(
+		"certificate", "",
+		"write the JSON certificate to the specified file instead "+
+			"of stdout")
+	parseCommandArgs(fs, args, 1)
+	bits, err := strconv.Atoi(fs.Arg(0))
+	if err != nil || bits < 2 {
+		fmt.Fprintf(os.Stderr, "bits must be an integer >= 2\n")
+		os.Exit(-1)
+	}
+
+	ctx, stop := signal.NotifyContext(
+		context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	logger := log.New(os.Stderr, "", 0)
+	for {
+		n, tried, err := findProbablePrime(bits)
+		if err != nil {
+			log.Fatal(err)
+		}
+		logger.Printf("Found probable prime %v after %d candidates; "+
+			"proving it prime\n", n, tried)
+		result, err := aks.RunAKS(ctx, n, &aks.AKSOptions{
+			Jobs:                *jobs,
+			Verbosity:           aks.VerbositySilent,
+			GenerateCertificate: true,
+		})
+		if err != nil {
+			log.Fatal(err)
+		}
+		if result.Verdict != aks.Prime {
+			// Only possible if Miller-Rabin was wrong,
+			// which is astronomically unlikely.
+			logger.Printf("%v is actually %v; trying again\n",
+				n, result.Verdict)
+			continue
+		}
+
+		fmt.Println(n)
+		if len(*certificatePath) > 0 {
+			err = writeCertificate(
+				*certificatePath, result.Certificate)
+		} else {
+			err = result.Certificate.WriteJSON(os.Stdout)
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+}
@@ -0,0 +1,101 @@
+package main
+
+import "flag"
+import "log"
+import "os"
+import "runtime"
+import "runtime/pprof"
+import "runtime/trace"
+import "sync"
+
+// Holds the values of the profiling flags.
+type profileFlags struct {
+	cpuPath, memPath, blockPath, tracePath *string
+}
+
+// Adds the profiling flags to fs.
+func addProfileFlags(fs *flag.FlagSet) *profileFlags {
+	return &profileFlags{
+		cpuPath: fs.String("cpuprofile", "",
+			"Write a CPU profile to the specified file "+
+				"before exiting."),
+		memPath: fs.String("memprofile", "",
+			"Write a heap profile to the specified file "+
+				"before exiting."),
+		blockPath: fs.String("blockprofile", "",
+			"Write a goroutine blocking profile to the "+
+				"specified file before exiting."),
+		tracePath: fs.String("trace", "",
+			"Write an execution trace to the specified file "+
+				"before exiting."),
+	}
+}
+
+// Creates the file at path, exiting on error.
+func mustCreate(path string) *os.File {
+	f, err := os.Create(path)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return f
+}
+
+// Starts the requested profiles, and returns a function that stops
+// them and writes them out. The returned function must be called
+// before exiting; calls after the first have no effect.
+func (p *profileFlags) start() func() {
+	var stops []func()
+	if len(*p.cpuPath) > 0 {
+		f := mustCreate(*p.cpuPath)
+		if err := pprof.StartCPUProfile(f); err != nil {
+			log.Fatal(err)
+		}
+		stops = append(stops, func() {
+			pprof.StopCPUProfile()
+			f.Close()
+		})
+	}
+
+	if len(*p.tracePath) > 0 {
+		f := mustCreate(*p.tracePath)
+		if err := trace.Start(f); err != nil {
+			log.Fatal(err)
+		}
+		stops = append(stops, func() {
+			trace.Stop()
+			f.Close()
+		})
+	}
+
+	if len(*p.blockPath) > 0 {
+		f := mustCreate(*p.blockPath)
+		runtime.SetBlockProfileRate(1)
+		stops = append(stops, func() {
+			if err := pprof.Lookup("block").WriteTo(f, 0); err != nil {
+				log.Print(err)
+			}
+			f.Close()
+		})
+	}
+
+	if len(*p.memPath) > 0 {
+		f := mustCreate(*p.memPath)
+		stops = append(stops, func() {
+			// Get up-to-date statistics.
+			runtime.GC()
+			if err := pprof.WriteHeapProfile(f); err != nil {
+				log.Print(err)
+			}
+			f.Close()
+		})
+	}
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			for _, stop := range stops {
+				stop()
+			}
+		})
+	}
+}
@@ -0,0 +1,132 @@
+package main
+
+import "github.com/akalin/aks-go/aks"
+import "github.com/akalin/aks-go/aks/ecpp"
+import "github.com/akalin/aks-go/aks/prover"
+import "errors"
+import "fmt"
+import "io"
+import "log"
+import "math/big"
+import "os"
+
+// A primality certificate that can be written as JSON, such as
+// aks.PocklingtonCertificate.
+type jsonCertificate interface {
+	WriteJSON(w io.Writer) error
+}
+
+// Runs a subcommand with the given name and arguments that proves a
+// number prime with prove, which is described by theorem in messages.
+func runProveCommand(
+	name, theorem string, args []string,
+	prove func(n *big.Int) (jsonCertificate, error)) {
+	fs := newCommandFlagSet(name, "[options] number")
+	certificatePath := fs.String(
+		"certificate", "",
+		"write the JSON certificate to the specified file instead "+
+			"of stdout")
+	parseCommandArgs(fs, args, 1)
+	n := parseFlagNumber(fs.Arg(0))
+
+	c, err := prove(n)
+	switch {
+	case errors.Is(err, aks.ErrComposite):
+		fmt.Printf("%v\n", err)
+		return
+	case errors.Is(err, aks.ErrProofNotFound):
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	case err != nil:
+		log.Fatal(err)
+	}
+
+	fmt.Fprintf(os.Stderr, "n = %v is prime by %s\n", n, theorem)
+	writeJSONCertificate(c, *certificatePath)
+}
+
+// Writes c as JSON to the file at path, or to stdout if path is empty.
+func writeJSONCertificate(c jsonCertificate, path string) {
+	var err error
+	if len(path) > 0 {
+		f, createErr := os.Create(path)
+		if createErr != nil {
+			log.Fatal(createErr)
+		}
+		err = c.WriteJSON(f)
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+	} else {
+		err = c.WriteJSON(os.Stdout)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// Runs the pocklington subcommand with the given arguments.
+func runPocklington(args []string) {
+	runProveCommand("pocklington", "Pocklington's theorem", args,
+		func(n *big.Int) (jsonCertificate, error) {
+			return aks.ProvePocklington(n)
+		})
+}
+
+// Runs the proth subcommand with the given arguments.
+func runProth(args []string) {
+	runProveCommand("proth", "Proth's theorem", args,
+		func(n *big.Int) (jsonCertificate, error) {
+			return aks.ProveProth(n)
+		})
+}
+
+// Runs the nplusone subcommand with the given arguments.
+func runNPlusOne(args []string) {
+	runProveCommand("nplusone", "the N+1 test", args,
+		func(n *big.Int) (jsonCertificate, error) {
+			return aks.ProveNPlusOne(n)
+		})
+}
+
+// Runs the ecpp subcommand with the given arguments.
+func runECPP(args []string) {
+	runProveCommand("ecpp", "elliptic curve primality proving", args,
+		func(n *big.Int) (jsonCertificate, error) {
+			return ecpp.Prove(n)
+		})
+}
+
+// Runs the prove subcommand with the given arguments.
+func runProve(args []string) {
+	fs := newCommandFlagSet("prove", "[options] number")
+	certificatePath := fs.String(
+		"certificate", "",
+		"write the JSON certificate, if any, to the specified file "+
+			"instead of stdout")
+	aksMaxBits := fs.Int(
+		"aks-max-bits", 0,
+		"use AKS instead of ECPP for numbers of at most this many "+
+			"bits that no faster method handles")
+	parseCommandArgs(fs, args, 1)
+	n := parseFlagNumber(fs.Arg(0))
+
+	proof, err := prover.Prove(
+		n, &prover.Options{AKSMaxBits: *aksMaxBits})
+	switch {
+	case errors.Is(err, aks.ErrProofNotFound):
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	case err != nil:
+		log.Fatal(err)
+	}
+
+	fmt.Fprintf(os.Stderr, "n = %v is %v by %v\n",
+		n, proof.Verdict, proof.Method)
+	if proof.Factor != nil {
+		fmt.Fprintf(os.Stderr, "%v divides n\n", proof.Factor)
+	}
+	if proof.Certificate != nil {
+		writeJSONCertificate(proof.Certificate, *certificatePath)
+	}
+}
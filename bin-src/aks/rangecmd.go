@@ -0,0 +1,165 @@
+package main
+
+import "github.com/akalin/aks-go/aks"
+import "context"
+import "fmt"
+import "io/ioutil"
+import "log"
+import "math/big"
+import "os"
+import "os/signal"
+import "runtime"
+import "syscall"
+import "time"
+
+// The largest prime used to sieve out small factors in range mode;
+// larger factors are left to aks.RunAKS.
+const maxRangeSievePrime = 1 << 24
+
+// The number of candidates sieved at once in range mode.
+const rangeChunkSize = 1 << 16
+
+// Returns a slice whose ith element is the smallest prime in primes
+// (which must be in ascending order) that properly divides start + i,
+// or 0 if there is none, for 0 <= i < count.
+func sieveSmallFactors(start *big.Int, count int, primes []uint32) []uint32 {
+	factors := make([]uint32, count)
+	var end, pSq, rem big.Int
+	end.Add(start, big.NewInt(int64(count)))
+	for _, p := range primes {
+		bigP := big.NewInt(int64(p))
+		pSq.Mul(bigP, bigP)
+		if pSq.Cmp(&end) >= 0 {
+			break
+		}
+		// Find the offset of the first multiple of p that is
+		// >= max(start, p^2), which skips p itself.
+		var first int
+		if pSq.Cmp(start) >= 0 {
+			var offset big.Int
+			offset.Sub(&pSq, start)
+			first = int(offset.Int64())
+		} else {
+			rem.Mod(start, bigP)
+			first = int((uint64(p) - rem.Uint64()) % uint64(p))
+		}
+		for i := first; i < count; i += int(p) {
+			if factors[i] == 0 {
+				factors[i] = p
+			}
+		}
+	}
+	return factors
+}
+
+// Runs the range subcommand with the given arguments.
+func runRange(args []string) {
+	fs := newCommandFlagSet("range", "[options] start end")
+	jobs := fs.Int(
+		"j", runtime.NumCPU(), "how many processing jobs to spawn")
+	odd := fs.Bool("odd", false, "only test odd numbers")
+	csvPath := fs.String(
+		"csv", "",
+		"also append a CSV row for each number to the specified file")
+	parseCommandArgs(fs, args, 2)
+	start := parseFlagNumber(fs.Arg(0))
+	end := parseFlagNumber(fs.Arg(1))
+	two := big.NewInt(2)
+	if start.Cmp(two) < 0 {
+		start = two
+	}
+
+	var results *resultCSVWriter
+	if len(*csvPath) > 0 {
+		var err error
+		results, err = openResultCSV(*csvPath)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer results.close()
+	}
+
+	// Sieve with primes up to sqrt(end), so that the sieve alone
+	// finds all composites if end is small enough.
+	bound := maxRangeSievePrime
+	var sqrtEnd big.Int
+	sqrtEnd.Sqrt(end)
+	if sqrtEnd.Cmp(big.NewInt(maxRangeSievePrime)) < 0 {
+		bound = int(sqrtEnd.Int64())
+	}
+	var primes []uint32
+	for p := range aks.Primes(2, uint64(bound)+1) {
+		primes = append(primes, uint32(p))
+	}
+	// Numbers less than sieveLimit = (bound + 1)^2 that survive
+	// the sieve are prime.
+	trialDivisionBound := big.NewInt(int64(bound) + 1)
+	var sieveLimit big.Int
+	sieveLimit.Mul(trialDivisionBound, trialDivisionBound)
+
+	ctx, stop := signal.NotifyContext(
+		context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	opts := &aks.AKSOptions{
+		Jobs:      *jobs,
+		Logger:    log.New(ioutil.Discard, "", 0),
+		Verbosity: aks.VerbositySilent,
+	}
+
+	primeCount := 0
+	var chunkStart big.Int
+	chunkStart.Set(start)
+	for chunkStart.Cmp(end) < 0 {
+		var remaining big.Int
+		remaining.Sub(end, &chunkStart)
+		count := rangeChunkSize
+		if remaining.Cmp(big.NewInt(rangeChunkSize)) < 0 {
+			count = int(remaining.Int64())
+		}
+		factors := sieveSmallFactors(&chunkStart, count, primes)
+		for i := 0; i < count; i++ {
+			var n big.Int
+			n.Add(&chunkStart, big.NewInt(int64(i)))
+			if *odd && n.Bit(0) == 0 {
+				continue
+			}
+			startTime := time.Now()
+			var result *aks.PrimalityResult
+			switch {
+			case factors[i] != 0:
+				result = &aks.PrimalityResult{
+					N:                  &n,
+					Verdict:            aks.Composite,
+					Method:             aks.MethodTrialDivision,
+					TrialDivisionBound: trialDivisionBound,
+					Factor:             big.NewInt(int64(factors[i])),
+				}
+			case n.Cmp(&sieveLimit) < 0:
+				result = &aks.PrimalityResult{
+					N:                  &n,
+					Verdict:            aks.Prime,
+					Method:             aks.MethodSqrtBound,
+					TrialDivisionBound: trialDivisionBound,
+				}
+			default:
+				var err error
+				result, err = aks.RunAKS(ctx, &n, opts)
+				if err != nil {
+					log.Fatalf("%v: %v", &n, err)
+				}
+			}
+			if result.Verdict == aks.Prime {
+				fmt.Println(&n)
+				primeCount++
+			}
+			if results != nil {
+				err := results.write(result, time.Since(startTime))
+				if err != nil {
+					log.Fatal(err)
+				}
+			}
+		}
+		chunkStart.Add(&chunkStart, big.NewInt(int64(count)))
+	}
+	fmt.Fprintf(os.Stderr, "%d primes found\n", primeCount)
+}
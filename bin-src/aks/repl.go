@@ -0,0 +1,206 @@
+package main
+
+import "github.com/akalin/aks-go/aks"
+import "bufio"
+import "context"
+import "fmt"
+import "math/big"
+import "os"
+import "os/signal"
+import "runtime"
+import "strings"
+
+// A command of the REPL, whose arguments are numbers.
+type replCommand struct {
+	name, args, description string
+	run                     func(args []*big.Int) error
+}
+
+var replCommands []replCommand
+
+func init() {
+	replCommands = []replCommand{
+		{"test", "n", "test n for primality (also done for a " +
+			"bare number)", replTest},
+		{"factor", "n", "factor n by trial division below M",
+			replFactor},
+		{"order", "a n", "print the multiplicative order of a " +
+			"modulo n", replOrder},
+		{"phi", "n", "print Euler's totient of n", replPhi},
+		{"params", "n", "print the AKS parameters r and M for n",
+			replParams},
+		{"witness", "n a", "check whether a is an AKS witness of n",
+			replWitness},
+	}
+}
+
+// The jobs used by the test command of the REPL.
+var replJobs int
+
+// Runs the repl subcommand with the given arguments.
+func runRepl(args []string) {
+	fs := newCommandFlagSet("repl", "[options]")
+	jobs := fs.Int(
+		"j", runtime.NumCPU(), "how many processing jobs to spawn "+
+			"for the test command")
+	parseCommandArgs(fs, args, 0)
+	replJobs = *jobs
+
+	fmt.Println("Type a number, a command, or \"help\".")
+	scanner := bufio.NewScanner(os.Stdin)
+	for {
+		fmt.Print("> ")
+		if !scanner.Scan() {
+			break
+		}
+		if !evalReplLine(scanner.Text()) {
+			return
+		}
+	}
+	fmt.Println()
+	if err := scanner.Err(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+// Evaluates a line typed into the REPL, printing the answer or
+// error. Returns false if the REPL should exit.
+func evalReplLine(line string) bool {
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return true
+	}
+	switch fields[0] {
+	case "quit", "exit":
+		return false
+	case "help":
+		printReplHelp()
+		return true
+	}
+
+	var command *replCommand
+	for i := range replCommands {
+		if replCommands[i].name == fields[0] {
+			command = &replCommands[i]
+			fields = fields[1:]
+			break
+		}
+	}
+	if command == nil {
+		// A bare number is tested.
+		command = &replCommands[0]
+	}
+	argCount := len(strings.Fields(command.args))
+	if len(fields) != argCount {
+		fmt.Printf("usage: %s %s\n", command.name, command.args)
+		return true
+	}
+	args := make([]*big.Int, argCount)
+	for i, field := range fields {
+		x, err := aks.ParseNumber(field)
+		if err != nil {
+			fmt.Println(err)
+			return true
+		}
+		args[i] = x
+	}
+	if err := command.run(args); err != nil {
+		fmt.Println(err)
+	}
+	return true
+}
+
+// Prints the commands of the REPL.
+func printReplHelp() {
+	fmt.Println("Commands (numbers may be expressions without spaces, " +
+		"e.g. 2^31-1):")
+	for _, c := range replCommands {
+		fmt.Printf("  %s %s\n    \t%s\n", c.name, c.args, c.description)
+	}
+	fmt.Println("  quit\n    \texit the REPL")
+}
+
+// Runs the test command of the REPL. An interrupt cancels the test
+// instead of exiting.
+func replTest(args []*big.Int) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	result, err := aks.RunAKS(ctx, args[0], &aks.AKSOptions{
+		Jobs:      replJobs,
+		Verbosity: aks.VerbositySilent,
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Println(formatBatchResult(result))
+	return nil
+}
+
+// Runs the factor command of the REPL.
+func replFactor(args []*big.Int) error {
+	r, err := aks.CalculateAKSModulus(args[0])
+	if err != nil {
+		return err
+	}
+	M, err := aks.CalculateAKSUpperBound(args[0], r)
+	if err != nil {
+		return err
+	}
+	printFactors(args[0], M)
+	return nil
+}
+
+// Runs the order command of the REPL.
+func replOrder(args []*big.Int) error {
+	o, err := aks.MultiplicativeOrder(args[0], args[1])
+	if err != nil {
+		return err
+	}
+	fmt.Println(o)
+	return nil
+}
+
+// Runs the phi command of the REPL.
+func replPhi(args []*big.Int) error {
+	phi, err := aks.EulerPhi(args[0])
+	if err != nil {
+		return err
+	}
+	fmt.Println(phi)
+	return nil
+}
+
+// Runs the params command of the REPL.
+func replParams(args []*big.Int) error {
+	r, err := aks.CalculateAKSModulus(args[0])
+	if err != nil {
+		return err
+	}
+	M, err := aks.CalculateAKSUpperBound(args[0], r)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("r = %v, M = %v\n", r, M)
+	return nil
+}
+
+// Runs the witness command of the REPL.
+func replWitness(args []*big.Int) error {
+	r, err := aks.CalculateAKSModulus(args[0])
+	if err != nil {
+		return err
+	}
+	isWitness, err := aks.IsAKSWitness(args[0], r, args[1])
+	if err != nil {
+		return err
+	}
+	if isWitness {
+		fmt.Printf("%v is an AKS witness of %v (r = %v)\n",
+			args[1], args[0], r)
+	} else {
+		fmt.Printf("%v is not an AKS witness of %v (r = %v)\n",
+			args[1], args[0], r)
+	}
+	return nil
+}
@@ -0,0 +1,275 @@
+package main
+
+import "github.com/akalin/aks-go/aks"
+import "context"
+import "encoding/json"
+import "errors"
+import "fmt"
+import "log"
+import "math/big"
+import "net"
+import "net/http"
+import "os"
+import "runtime"
+import "strconv"
+import "strings"
+import "sync"
+import "time"
+
+// The states of a job run by a jobServer.
+const (
+	jobQueued    = "queued"
+	jobRunning   = "running"
+	jobDone      = "done"
+	jobCancelled = "cancelled"
+	jobFailed    = "failed"
+)
+
+// The largest request body a jobServer reads for /test.
+const maxTestRequestBytes = 1 << 16
+
+// The longest expression a jobServer parses for /test, which is
+// plenty for any number AKS can test in a reasonable time.
+const maxTestExpressionLength = 1 << 10
+
+// The most bits a number submitted to a jobServer may have. Much
+// larger numbers would never finish, and ParseNumber's own limit is
+// meant for memory, not time.
+const maxTestBits = 1 << 14
+
+// A primality test submitted to a jobServer. The exported fields are
+// what's served for the job.
+type job struct {
+	ID      string
+	N       *big.Int
+	State   string
+	Created time.Time
+	// The progress of the test, while it's running.
+	Status *aks.Status `json:",omitempty"`
+	// The (possibly partial) result of the test, once it has
+	// finished or was cancelled.
+	Result *aks.PrimalityResult `json:",omitempty"`
+	Error  string               `json:",omitempty"`
+
+	cancel  context.CancelFunc
+	tracker *aks.StatusTracker
+}
+
+// A jobServer runs primality tests submitted over HTTP in the
+// background. It serves:
+//
+//	POST /test with a JSON body {"N": "<number>"}, where the number
+//	    may be an expression (see aks.ParseNumber) of up to
+//	    maxTestExpressionLength characters, which submits a job and
+//	    returns it with status 202.
+//	GET /jobs/<id>, which returns the job with its progress or
+//	    result.
+//	DELETE /jobs/<id>, which cancels the job and returns it.
+type jobServer struct {
+	opts aks.AKSOptions
+	// Holds a token for each running job, to limit how many run
+	// at once.
+	slots  chan struct{}
+	logger *log.Logger
+
+	lock   sync.Mutex
+	nextID int
+	jobs   map[string]*job
+}
+
+// Makes a jobServer that runs up to concurrent tests at once with
+// the given options.
+func newJobServer(
+	opts aks.AKSOptions, concurrent int, logger *log.Logger) *jobServer {
+	return &jobServer{
+		opts:   opts,
+		slots:  make(chan struct{}, concurrent),
+		logger: logger,
+		jobs:   make(map[string]*job),
+	}
+}
+
+// Returns a handler serving the jobServer's API.
+func (s *jobServer) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/test", s.handleTest)
+	mux.HandleFunc("/jobs/", s.handleJob)
+	return mux
+}
+
+// Writes v as indented JSON with the given status code.
+func writeJSONResponse(w http.ResponseWriter, code int, v interface{}) {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	w.Write(append(data, '\n'))
+}
+
+// Responds with a 405 listing the allowed methods.
+func methodNotAllowed(w http.ResponseWriter, allowed string) {
+	w.Header().Set("Allow", allowed)
+	http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+}
+
+// Returns a copy of j to serve. s.lock must be held.
+func (s *jobServer) snapshotLocked(j *job) job {
+	snapshot := *j
+	if j.State == jobRunning {
+		status := j.tracker.GetStatus()
+		snapshot.Status = &status
+	}
+	return snapshot
+}
+
+// Handles /test.
+func (s *jobServer) handleTest(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		methodNotAllowed(w, http.MethodPost)
+		return
+	}
+	req.Body = http.MaxBytesReader(w, req.Body, maxTestRequestBytes)
+	var body struct{ N string }
+	if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if len(body.N) > maxTestExpressionLength {
+		http.Error(w, fmt.Sprintf("N must be at most %d characters",
+			maxTestExpressionLength), http.StatusBadRequest)
+		return
+	}
+	n, err := aks.ParseNumber(body.N)
+	if err == nil && n.BitLen() > maxTestBits {
+		err = fmt.Errorf("N must have at most %d bits", maxTestBits)
+	}
+	var tracker *aks.StatusTracker
+	if err == nil {
+		tracker, err = aks.NewStatusTracker(n)
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s.lock.Lock()
+	s.nextID++
+	j := &job{
+		ID:      strconv.Itoa(s.nextID),
+		N:       n,
+		State:   jobQueued,
+		Created: time.Now(),
+		cancel:  cancel,
+		tracker: tracker,
+	}
+	s.jobs[j.ID] = j
+	snapshot := s.snapshotLocked(j)
+	s.lock.Unlock()
+
+	s.logger.Printf("Job %s: testing %v\n", j.ID, n)
+	go s.run(ctx, j)
+
+	w.Header().Set("Location", "/jobs/"+j.ID)
+	writeJSONResponse(w, http.StatusAccepted, &snapshot)
+}
+
+// Handles /jobs/<id>.
+func (s *jobServer) handleJob(w http.ResponseWriter, req *http.Request) {
+	id := strings.TrimPrefix(req.URL.Path, "/jobs/")
+	s.lock.Lock()
+	j := s.jobs[id]
+	s.lock.Unlock()
+	if j == nil {
+		http.NotFound(w, req)
+		return
+	}
+
+	switch req.Method {
+	case http.MethodGet:
+	case http.MethodDelete:
+		// The job's state is updated when RunAKS returns,
+		// which may be after the response is written.
+		j.cancel()
+	default:
+		methodNotAllowed(w, "GET, DELETE")
+		return
+	}
+	s.lock.Lock()
+	snapshot := s.snapshotLocked(j)
+	s.lock.Unlock()
+	writeJSONResponse(w, http.StatusOK, &snapshot)
+}
+
+// Runs the test for j once there's a free slot, and records its
+// outcome.
+func (s *jobServer) run(ctx context.Context, j *job) {
+	defer j.cancel()
+	select {
+	case s.slots <- struct{}{}:
+		defer func() { <-s.slots }()
+	case <-ctx.Done():
+		s.finish(j, nil, ctx.Err())
+		return
+	}
+
+	s.lock.Lock()
+	j.State = jobRunning
+	s.lock.Unlock()
+	opts := s.opts
+	opts.Observer = j.tracker
+	result, err := aks.RunAKS(ctx, j.N, &opts)
+	s.finish(j, result, err)
+}
+
+// Records the outcome of j.
+func (s *jobServer) finish(j *job, result *aks.PrimalityResult, err error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	j.Result = result
+	switch {
+	case err == nil:
+		j.State = jobDone
+		s.logger.Printf("Job %s: %v is %v\n", j.ID, j.N, result.Verdict)
+	case errors.Is(err, context.Canceled):
+		j.State = jobCancelled
+		s.logger.Printf("Job %s: cancelled\n", j.ID)
+	default:
+		j.State = jobFailed
+		j.Error = err.Error()
+		s.logger.Printf("Job %s: %v\n", j.ID, err)
+	}
+}
+
+// Runs the serve subcommand with the given arguments.
+func runServe(args []string) {
+	fs := newCommandFlagSet("serve", "[options]")
+	addr := fs.String(
+		"addr", "localhost:8080", "the address to serve HTTP on")
+	jobs := fs.Int(
+		"j", runtime.NumCPU(), "how many processing jobs to spawn "+
+			"for each test")
+	concurrent := fs.Int(
+		"concurrent", 1,
+		"how many tests to run at once; further tests are queued")
+	parseCommandArgs(fs, args, 0)
+	if *concurrent < 1 {
+		fs.Usage()
+		os.Exit(-1)
+	}
+
+	logger := log.New(os.Stderr, "", 0)
+	s := newJobServer(aks.AKSOptions{
+		Jobs:      *jobs,
+		Verbosity: aks.VerbositySilent,
+	}, *concurrent, logger)
+	l, err := net.Listen("tcp", *addr)
+	if err != nil {
+		log.Fatal(err)
+	}
+	logger.Printf("Serving HTTP on %v\n", l.Addr())
+	log.Fatal(http.Serve(l, s.handler()))
+}
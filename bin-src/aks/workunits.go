@@ -0,0 +1,80 @@
+package main
+
+import "github.com/akalin/aks-go/aks"
+import "context"
+import "fmt"
+import "log"
+import "os"
+
+// Runs the work units in the file at path with the given options and
+// writes the results to stdout. Each unit is announced to logger.
+func runWorkUnits(path string, opts *aks.AKSOptions, logger *log.Logger) {
+	f, err := os.Open(path)
+	if err != nil {
+		log.Fatal(err)
+	}
+	units, err := aks.ImportWorkUnits(f)
+	f.Close()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var results []aks.WorkUnitResult
+	for _, unit := range units {
+		logger.Printf("Running work unit for n = %v, start = %v, "+
+			"end = %v\n", unit.N, unit.Start, unit.End)
+		result, err := aks.RunWorkUnit(
+			context.Background(), unit, opts)
+		if err != nil {
+			log.Fatal(err)
+		}
+		results = append(results, *result)
+	}
+	if err := aks.ExportWorkUnitResults(os.Stdout, results); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// Merges the work unit results in the files at the given paths and
+// prints the verdict.
+func mergeWorkUnitResults(paths []string) {
+	var results []aks.WorkUnitResult
+	for _, path := range paths {
+		f, err := os.Open(path)
+		if err != nil {
+			log.Fatal(err)
+		}
+		fileResults, err := aks.ImportWorkUnitResults(f)
+		f.Close()
+		if err != nil {
+			log.Fatalf("%s: %v", path, err)
+		}
+		results = append(results, fileResults...)
+	}
+
+	result, err := aks.MergeWorkUnitResults(results)
+	if err != nil {
+		log.Fatal(err)
+	}
+	printMergedResult(result)
+}
+
+// Prints the verdict of a result from aks.MergeWorkUnitResults.
+func printMergedResult(result *aks.PrimalityResult) {
+	fmt.Printf("n = %v, r = %v, M = %v\n", result.N, result.R, result.M)
+	switch {
+	case result.Factor != nil:
+		fmt.Printf("n has factor %v\n", result.Factor)
+	case result.Method == aks.MethodSqrtBound:
+		fmt.Printf("%v is greater than sqrt(%v), so %v is prime\n",
+			result.M, result.N, result.N)
+	case result.Witness != nil:
+		fmt.Printf("n is composite with AKS witness %v\n",
+			result.Witness)
+	case result.Verdict == aks.Prime:
+		fmt.Printf("n is prime\n")
+	default:
+		fmt.Printf("the results do not cover all AKS witnesses " +
+			"below M\n")
+	}
+}
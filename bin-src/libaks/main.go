@@ -0,0 +1,165 @@
+// A C library exporting the AKS primality test, for use from other
+// languages. Build it with
+//
+//	go build -buildmode=c-shared -o libaks.so
+//
+// or, for a static library,
+//
+//	go build -buildmode=c-archive -o libaks.a
+//
+// either of which also writes the header libaks.h declaring the
+// functions below. Numbers are passed in and out as NUL-terminated
+// strings in any form accepted by aks.ParseNumber (e.g., "2^31-1").
+package main
+
+/*
+#include <stdlib.h>
+
+// The verdict of aks_is_prime.
+enum {
+	AKS_ERROR = -1,
+	AKS_COMPOSITE = 0,
+	AKS_PRIME = 1,
+};
+
+// The result of aks_is_prime or aks_find_witness, which must be
+// freed with aks_free_result. String members may be NULL.
+typedef struct {
+	// One of the AKS_* values above. Always AKS_ERROR if error is
+	// non-NULL.
+	int verdict;
+	// How the verdict was reached, e.g. "trial division".
+	char *method;
+	// The factor found by trial division, if any, in decimal.
+	char *factor;
+	// The AKS witness found, if any, in decimal.
+	char *witness;
+	// A description of what went wrong, if anything.
+	char *error;
+} aks_result;
+*/
+import "C"
+
+import "github.com/akalin/aks-go/aks"
+import "context"
+import "io/ioutil"
+import "log"
+import "math/big"
+import "unsafe"
+
+// Returns a C copy of x's decimal representation, or NULL if x is
+// nil.
+func cBigInt(x *big.Int) *C.char {
+	if x == nil {
+		return nil
+	}
+	return C.CString(x.String())
+}
+
+// Returns a new aks_result with the given error.
+func newErrorResult(err error) *C.aks_result {
+	result := newResult()
+	result.verdict = C.AKS_ERROR
+	result.error = C.CString(err.Error())
+	return result
+}
+
+// Returns a new zeroed aks_result allocated with malloc.
+func newResult() *C.aks_result {
+	result := (*C.aks_result)(C.calloc(1, C.sizeof_aks_result))
+	if result == nil {
+		panic("out of memory")
+	}
+	return result
+}
+
+// Parses the C strings in cs as numbers, in order.
+func parseNumbers(cs ...*C.char) ([]*big.Int, error) {
+	var xs []*big.Int
+	for _, c := range cs {
+		x, err := aks.ParseNumber(C.GoString(c))
+		if err != nil {
+			return nil, err
+		}
+		xs = append(xs, x)
+	}
+	return xs, nil
+}
+
+// Tests n for primality with the AKS algorithm, using jobs threads
+// (or one per CPU if jobs isn't positive).
+//
+//export aks_is_prime
+func aks_is_prime(n *C.char, jobs C.int) *C.aks_result {
+	xs, err := parseNumbers(n)
+	if err != nil {
+		return newErrorResult(err)
+	}
+	r, err := aks.RunAKS(context.Background(), xs[0], &aks.AKSOptions{
+		Jobs: int(jobs),
+	})
+	if err != nil {
+		return newErrorResult(err)
+	}
+	result := newResult()
+	if r.Verdict == aks.Prime {
+		result.verdict = C.AKS_PRIME
+	} else {
+		result.verdict = C.AKS_COMPOSITE
+	}
+	result.method = C.CString(r.Method.String())
+	result.factor = cBigInt(r.Factor)
+	result.witness = cBigInt(r.Witness)
+	return result
+}
+
+// Searches [start, end) for an AKS witness of n with parameter r,
+// testing up to jobs numbers at once. If one is found, the result's
+// verdict is AKS_COMPOSITE and its witness is set; otherwise, the
+// verdict is AKS_PRIME, which only means n is prime if r and
+// [start, end) are the AKS parameters of n.
+//
+//export aks_find_witness
+func aks_find_witness(
+	n, r, start, end *C.char, jobs C.int) *C.aks_result {
+	xs, err := parseNumbers(n, r, start, end)
+	if err != nil {
+		return newErrorResult(err)
+	}
+	if jobs <= 0 {
+		jobs = 1
+	}
+	a, err := aks.GetAKSWitness(
+		xs[0], xs[1], xs[2], xs[3], int(jobs),
+		log.New(ioutil.Discard, "", 0))
+	if err != nil {
+		return newErrorResult(err)
+	}
+	result := newResult()
+	if a != nil {
+		result.verdict = C.AKS_COMPOSITE
+	} else {
+		result.verdict = C.AKS_PRIME
+	}
+	result.method = C.CString(aks.MethodAKS.String())
+	result.witness = cBigInt(a)
+	return result
+}
+
+// Frees result and its strings. result may be NULL.
+//
+//export aks_free_result
+func aks_free_result(result *C.aks_result) {
+	if result == nil {
+		return
+	}
+	for _, s := range []*C.char{
+		result.method, result.factor, result.witness, result.error,
+	} {
+		C.free(unsafe.Pointer(s))
+	}
+	C.free(unsafe.Pointer(result))
+}
+
+// Needed for -buildmode=c-shared and -buildmode=c-archive.
+func main() {}